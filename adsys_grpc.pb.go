@@ -19,18 +19,29 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Service_Cat_FullMethodName                     = "/service/Cat"
-	Service_Version_FullMethodName                 = "/service/Version"
-	Service_Status_FullMethodName                  = "/service/Status"
-	Service_Stop_FullMethodName                    = "/service/Stop"
-	Service_UpdatePolicy_FullMethodName            = "/service/UpdatePolicy"
-	Service_DumpPolicies_FullMethodName            = "/service/DumpPolicies"
-	Service_DumpPoliciesDefinitions_FullMethodName = "/service/DumpPoliciesDefinitions"
-	Service_GetDoc_FullMethodName                  = "/service/GetDoc"
-	Service_ListDoc_FullMethodName                 = "/service/ListDoc"
-	Service_ListUsers_FullMethodName               = "/service/ListUsers"
-	Service_GPOListScript_FullMethodName           = "/service/GPOListScript"
-	Service_CertAutoEnrollScript_FullMethodName    = "/service/CertAutoEnrollScript"
+	Service_Cat_FullMethodName                      = "/service/Cat"
+	Service_Version_FullMethodName                  = "/service/Version"
+	Service_Status_FullMethodName                   = "/service/Status"
+	Service_Ready_FullMethodName                    = "/service/Ready"
+	Service_Stop_FullMethodName                     = "/service/Stop"
+	Service_UpdatePolicy_FullMethodName             = "/service/UpdatePolicy"
+	Service_UpdatePolicyWithProgress_FullMethodName = "/service/UpdatePolicyWithProgress"
+	Service_DumpPolicies_FullMethodName             = "/service/DumpPolicies"
+	Service_ExportPolicies_FullMethodName           = "/service/ExportPolicies"
+	Service_ImportPolicies_FullMethodName           = "/service/ImportPolicies"
+	Service_SimulatePolicies_FullMethodName         = "/service/SimulatePolicies"
+	Service_ListCache_FullMethodName                = "/service/ListCache"
+	Service_PurgeCache_FullMethodName               = "/service/PurgeCache"
+	Service_DumpPoliciesDefinitions_FullMethodName  = "/service/DumpPoliciesDefinitions"
+	Service_ScriptsLogs_FullMethodName              = "/service/ScriptsLogs"
+	Service_CertificateStatus_FullMethodName        = "/service/CertificateStatus"
+	Service_Doctor_FullMethodName                   = "/service/Doctor"
+	Service_GetDoc_FullMethodName                   = "/service/GetDoc"
+	Service_ListDoc_FullMethodName                  = "/service/ListDoc"
+	Service_ListUsers_FullMethodName                = "/service/ListUsers"
+	Service_GPOList_FullMethodName                  = "/service/GPOList"
+	Service_GPOListScript_FullMethodName            = "/service/GPOListScript"
+	Service_CertAutoEnrollScript_FullMethodName     = "/service/CertAutoEnrollScript"
 )
 
 // ServiceClient is the client API for Service service.
@@ -39,14 +50,25 @@ const (
 type ServiceClient interface {
 	Cat(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
 	Version(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
-	Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	Ready(ctx context.Context, in *ReadyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReadyResponse], error)
 	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error)
-	UpdatePolicy(ctx context.Context, in *UpdatePolicyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error)
+	UpdatePolicy(ctx context.Context, in *UpdatePolicyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	UpdatePolicyWithProgress(ctx context.Context, in *UpdatePolicyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UpdatePolicyProgress], error)
 	DumpPolicies(ctx context.Context, in *DumpPoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	ExportPolicies(ctx context.Context, in *ExportPoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	ImportPolicies(ctx context.Context, in *ImportPoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error)
+	SimulatePolicies(ctx context.Context, in *SimulatePoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	ListCache(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	PurgeCache(ctx context.Context, in *PurgeCacheRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error)
 	DumpPoliciesDefinitions(ctx context.Context, in *DumpPolicyDefinitionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DumpPolicyDefinitionsResponse], error)
+	ScriptsLogs(ctx context.Context, in *ScriptsLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	CertificateStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	Doctor(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
 	GetDoc(ctx context.Context, in *GetDocRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
 	ListDoc(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListDocReponse], error)
 	ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
+	GPOList(ctx context.Context, in *GPOListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
 	GPOListScript(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
 	CertAutoEnrollScript(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error)
 }
@@ -97,13 +119,13 @@ func (c *serviceClient) Version(ctx context.Context, in *Empty, opts ...grpc.Cal
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_VersionClient = grpc.ServerStreamingClient[StringResponse]
 
-func (c *serviceClient) Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+func (c *serviceClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[2], Service_Status_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &grpc.GenericClientStream[Empty, StringResponse]{ClientStream: stream}
+	x := &grpc.GenericClientStream[StatusRequest, StringResponse]{ClientStream: stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -116,9 +138,28 @@ func (c *serviceClient) Status(ctx context.Context, in *Empty, opts ...grpc.Call
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_StatusClient = grpc.ServerStreamingClient[StringResponse]
 
+func (c *serviceClient) Ready(ctx context.Context, in *ReadyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ReadyResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[3], Service_Ready_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ReadyRequest, ReadyResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ReadyClient = grpc.ServerStreamingClient[ReadyResponse]
+
 func (c *serviceClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[3], Service_Stop_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[4], Service_Stop_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -135,13 +176,13 @@ func (c *serviceClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_StopClient = grpc.ServerStreamingClient[Empty]
 
-func (c *serviceClient) UpdatePolicy(ctx context.Context, in *UpdatePolicyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error) {
+func (c *serviceClient) UpdatePolicy(ctx context.Context, in *UpdatePolicyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[4], Service_UpdatePolicy_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[5], Service_UpdatePolicy_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &grpc.GenericClientStream[UpdatePolicyRequest, Empty]{ClientStream: stream}
+	x := &grpc.GenericClientStream[UpdatePolicyRequest, StringResponse]{ClientStream: stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -152,11 +193,30 @@ func (c *serviceClient) UpdatePolicy(ctx context.Context, in *UpdatePolicyReques
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type Service_UpdatePolicyClient = grpc.ServerStreamingClient[Empty]
+type Service_UpdatePolicyClient = grpc.ServerStreamingClient[StringResponse]
+
+func (c *serviceClient) UpdatePolicyWithProgress(ctx context.Context, in *UpdatePolicyRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UpdatePolicyProgress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[6], Service_UpdatePolicyWithProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UpdatePolicyRequest, UpdatePolicyProgress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_UpdatePolicyWithProgressClient = grpc.ServerStreamingClient[UpdatePolicyProgress]
 
 func (c *serviceClient) DumpPolicies(ctx context.Context, in *DumpPoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[5], Service_DumpPolicies_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[7], Service_DumpPolicies_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -173,9 +233,104 @@ func (c *serviceClient) DumpPolicies(ctx context.Context, in *DumpPoliciesReques
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_DumpPoliciesClient = grpc.ServerStreamingClient[StringResponse]
 
+func (c *serviceClient) ExportPolicies(ctx context.Context, in *ExportPoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[8], Service_ExportPolicies_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportPoliciesRequest, StringResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ExportPoliciesClient = grpc.ServerStreamingClient[StringResponse]
+
+func (c *serviceClient) ImportPolicies(ctx context.Context, in *ImportPoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[9], Service_ImportPolicies_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportPoliciesRequest, Empty]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ImportPoliciesClient = grpc.ServerStreamingClient[Empty]
+
+func (c *serviceClient) SimulatePolicies(ctx context.Context, in *SimulatePoliciesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[10], Service_SimulatePolicies_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SimulatePoliciesRequest, StringResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_SimulatePoliciesClient = grpc.ServerStreamingClient[StringResponse]
+
+func (c *serviceClient) ListCache(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[11], Service_ListCache_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Empty, StringResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ListCacheClient = grpc.ServerStreamingClient[StringResponse]
+
+func (c *serviceClient) PurgeCache(ctx context.Context, in *PurgeCacheRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Empty], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[12], Service_PurgeCache_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PurgeCacheRequest, Empty]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_PurgeCacheClient = grpc.ServerStreamingClient[Empty]
+
 func (c *serviceClient) DumpPoliciesDefinitions(ctx context.Context, in *DumpPolicyDefinitionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DumpPolicyDefinitionsResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[6], Service_DumpPoliciesDefinitions_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[13], Service_DumpPoliciesDefinitions_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -192,9 +347,66 @@ func (c *serviceClient) DumpPoliciesDefinitions(ctx context.Context, in *DumpPol
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_DumpPoliciesDefinitionsClient = grpc.ServerStreamingClient[DumpPolicyDefinitionsResponse]
 
+func (c *serviceClient) ScriptsLogs(ctx context.Context, in *ScriptsLogsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[14], Service_ScriptsLogs_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ScriptsLogsRequest, StringResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ScriptsLogsClient = grpc.ServerStreamingClient[StringResponse]
+
+func (c *serviceClient) CertificateStatus(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[15], Service_CertificateStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Empty, StringResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_CertificateStatusClient = grpc.ServerStreamingClient[StringResponse]
+
+func (c *serviceClient) Doctor(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[16], Service_Doctor_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Empty, StringResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_DoctorClient = grpc.ServerStreamingClient[StringResponse]
+
 func (c *serviceClient) GetDoc(ctx context.Context, in *GetDocRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[7], Service_GetDoc_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[17], Service_GetDoc_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -213,7 +425,7 @@ type Service_GetDocClient = grpc.ServerStreamingClient[StringResponse]
 
 func (c *serviceClient) ListDoc(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListDocReponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[8], Service_ListDoc_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[18], Service_ListDoc_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -232,7 +444,7 @@ type Service_ListDocClient = grpc.ServerStreamingClient[ListDocReponse]
 
 func (c *serviceClient) ListUsers(ctx context.Context, in *ListUsersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[9], Service_ListUsers_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[19], Service_ListUsers_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -249,9 +461,28 @@ func (c *serviceClient) ListUsers(ctx context.Context, in *ListUsersRequest, opt
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_ListUsersClient = grpc.ServerStreamingClient[StringResponse]
 
+func (c *serviceClient) GPOList(ctx context.Context, in *GPOListRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[20], Service_GPOList_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GPOListRequest, StringResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_GPOListClient = grpc.ServerStreamingClient[StringResponse]
+
 func (c *serviceClient) GPOListScript(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[10], Service_GPOListScript_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[21], Service_GPOListScript_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -270,7 +501,7 @@ type Service_GPOListScriptClient = grpc.ServerStreamingClient[StringResponse]
 
 func (c *serviceClient) CertAutoEnrollScript(ctx context.Context, in *Empty, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StringResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[11], Service_CertAutoEnrollScript_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Service_ServiceDesc.Streams[22], Service_CertAutoEnrollScript_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -293,14 +524,25 @@ type Service_CertAutoEnrollScriptClient = grpc.ServerStreamingClient[StringRespo
 type ServiceServer interface {
 	Cat(*Empty, grpc.ServerStreamingServer[StringResponse]) error
 	Version(*Empty, grpc.ServerStreamingServer[StringResponse]) error
-	Status(*Empty, grpc.ServerStreamingServer[StringResponse]) error
+	Status(*StatusRequest, grpc.ServerStreamingServer[StringResponse]) error
+	Ready(*ReadyRequest, grpc.ServerStreamingServer[ReadyResponse]) error
 	Stop(*StopRequest, grpc.ServerStreamingServer[Empty]) error
-	UpdatePolicy(*UpdatePolicyRequest, grpc.ServerStreamingServer[Empty]) error
+	UpdatePolicy(*UpdatePolicyRequest, grpc.ServerStreamingServer[StringResponse]) error
+	UpdatePolicyWithProgress(*UpdatePolicyRequest, grpc.ServerStreamingServer[UpdatePolicyProgress]) error
 	DumpPolicies(*DumpPoliciesRequest, grpc.ServerStreamingServer[StringResponse]) error
+	ExportPolicies(*ExportPoliciesRequest, grpc.ServerStreamingServer[StringResponse]) error
+	ImportPolicies(*ImportPoliciesRequest, grpc.ServerStreamingServer[Empty]) error
+	SimulatePolicies(*SimulatePoliciesRequest, grpc.ServerStreamingServer[StringResponse]) error
+	ListCache(*Empty, grpc.ServerStreamingServer[StringResponse]) error
+	PurgeCache(*PurgeCacheRequest, grpc.ServerStreamingServer[Empty]) error
 	DumpPoliciesDefinitions(*DumpPolicyDefinitionsRequest, grpc.ServerStreamingServer[DumpPolicyDefinitionsResponse]) error
+	ScriptsLogs(*ScriptsLogsRequest, grpc.ServerStreamingServer[StringResponse]) error
+	CertificateStatus(*Empty, grpc.ServerStreamingServer[StringResponse]) error
+	Doctor(*Empty, grpc.ServerStreamingServer[StringResponse]) error
 	GetDoc(*GetDocRequest, grpc.ServerStreamingServer[StringResponse]) error
 	ListDoc(*Empty, grpc.ServerStreamingServer[ListDocReponse]) error
 	ListUsers(*ListUsersRequest, grpc.ServerStreamingServer[StringResponse]) error
+	GPOList(*GPOListRequest, grpc.ServerStreamingServer[StringResponse]) error
 	GPOListScript(*Empty, grpc.ServerStreamingServer[StringResponse]) error
 	CertAutoEnrollScript(*Empty, grpc.ServerStreamingServer[StringResponse]) error
 	mustEmbedUnimplementedServiceServer()
@@ -319,21 +561,51 @@ func (UnimplementedServiceServer) Cat(*Empty, grpc.ServerStreamingServer[StringR
 func (UnimplementedServiceServer) Version(*Empty, grpc.ServerStreamingServer[StringResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method Version not implemented")
 }
-func (UnimplementedServiceServer) Status(*Empty, grpc.ServerStreamingServer[StringResponse]) error {
+func (UnimplementedServiceServer) Status(*StatusRequest, grpc.ServerStreamingServer[StringResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method Status not implemented")
 }
+func (UnimplementedServiceServer) Ready(*ReadyRequest, grpc.ServerStreamingServer[ReadyResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Ready not implemented")
+}
 func (UnimplementedServiceServer) Stop(*StopRequest, grpc.ServerStreamingServer[Empty]) error {
 	return status.Errorf(codes.Unimplemented, "method Stop not implemented")
 }
-func (UnimplementedServiceServer) UpdatePolicy(*UpdatePolicyRequest, grpc.ServerStreamingServer[Empty]) error {
+func (UnimplementedServiceServer) UpdatePolicy(*UpdatePolicyRequest, grpc.ServerStreamingServer[StringResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method UpdatePolicy not implemented")
 }
+func (UnimplementedServiceServer) UpdatePolicyWithProgress(*UpdatePolicyRequest, grpc.ServerStreamingServer[UpdatePolicyProgress]) error {
+	return status.Errorf(codes.Unimplemented, "method UpdatePolicyWithProgress not implemented")
+}
 func (UnimplementedServiceServer) DumpPolicies(*DumpPoliciesRequest, grpc.ServerStreamingServer[StringResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method DumpPolicies not implemented")
 }
+func (UnimplementedServiceServer) ExportPolicies(*ExportPoliciesRequest, grpc.ServerStreamingServer[StringResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ExportPolicies not implemented")
+}
+func (UnimplementedServiceServer) ImportPolicies(*ImportPoliciesRequest, grpc.ServerStreamingServer[Empty]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportPolicies not implemented")
+}
+func (UnimplementedServiceServer) SimulatePolicies(*SimulatePoliciesRequest, grpc.ServerStreamingServer[StringResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method SimulatePolicies not implemented")
+}
+func (UnimplementedServiceServer) ListCache(*Empty, grpc.ServerStreamingServer[StringResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ListCache not implemented")
+}
+func (UnimplementedServiceServer) PurgeCache(*PurgeCacheRequest, grpc.ServerStreamingServer[Empty]) error {
+	return status.Errorf(codes.Unimplemented, "method PurgeCache not implemented")
+}
 func (UnimplementedServiceServer) DumpPoliciesDefinitions(*DumpPolicyDefinitionsRequest, grpc.ServerStreamingServer[DumpPolicyDefinitionsResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method DumpPoliciesDefinitions not implemented")
 }
+func (UnimplementedServiceServer) ScriptsLogs(*ScriptsLogsRequest, grpc.ServerStreamingServer[StringResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ScriptsLogs not implemented")
+}
+func (UnimplementedServiceServer) CertificateStatus(*Empty, grpc.ServerStreamingServer[StringResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method CertificateStatus not implemented")
+}
+func (UnimplementedServiceServer) Doctor(*Empty, grpc.ServerStreamingServer[StringResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Doctor not implemented")
+}
 func (UnimplementedServiceServer) GetDoc(*GetDocRequest, grpc.ServerStreamingServer[StringResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method GetDoc not implemented")
 }
@@ -343,6 +615,9 @@ func (UnimplementedServiceServer) ListDoc(*Empty, grpc.ServerStreamingServer[Lis
 func (UnimplementedServiceServer) ListUsers(*ListUsersRequest, grpc.ServerStreamingServer[StringResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method ListUsers not implemented")
 }
+func (UnimplementedServiceServer) GPOList(*GPOListRequest, grpc.ServerStreamingServer[StringResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GPOList not implemented")
+}
 func (UnimplementedServiceServer) GPOListScript(*Empty, grpc.ServerStreamingServer[StringResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method GPOListScript not implemented")
 }
@@ -393,16 +668,27 @@ func _Service_Version_Handler(srv interface{}, stream grpc.ServerStream) error {
 type Service_VersionServer = grpc.ServerStreamingServer[StringResponse]
 
 func _Service_Status_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(Empty)
+	m := new(StatusRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).Status(m, &grpc.GenericServerStream[Empty, StringResponse]{ServerStream: stream})
+	return srv.(ServiceServer).Status(m, &grpc.GenericServerStream[StatusRequest, StringResponse]{ServerStream: stream})
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_StatusServer = grpc.ServerStreamingServer[StringResponse]
 
+func _Service_Ready_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).Ready(m, &grpc.GenericServerStream[ReadyRequest, ReadyResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ReadyServer = grpc.ServerStreamingServer[ReadyResponse]
+
 func _Service_Stop_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(StopRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -419,11 +705,22 @@ func _Service_UpdatePolicy_Handler(srv interface{}, stream grpc.ServerStream) er
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(ServiceServer).UpdatePolicy(m, &grpc.GenericServerStream[UpdatePolicyRequest, Empty]{ServerStream: stream})
+	return srv.(ServiceServer).UpdatePolicy(m, &grpc.GenericServerStream[UpdatePolicyRequest, StringResponse]{ServerStream: stream})
 }
 
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
-type Service_UpdatePolicyServer = grpc.ServerStreamingServer[Empty]
+type Service_UpdatePolicyServer = grpc.ServerStreamingServer[StringResponse]
+
+func _Service_UpdatePolicyWithProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UpdatePolicyRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).UpdatePolicyWithProgress(m, &grpc.GenericServerStream[UpdatePolicyRequest, UpdatePolicyProgress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_UpdatePolicyWithProgressServer = grpc.ServerStreamingServer[UpdatePolicyProgress]
 
 func _Service_DumpPolicies_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(DumpPoliciesRequest)
@@ -436,6 +733,61 @@ func _Service_DumpPolicies_Handler(srv interface{}, stream grpc.ServerStream) er
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_DumpPoliciesServer = grpc.ServerStreamingServer[StringResponse]
 
+func _Service_ExportPolicies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportPoliciesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).ExportPolicies(m, &grpc.GenericServerStream[ExportPoliciesRequest, StringResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ExportPoliciesServer = grpc.ServerStreamingServer[StringResponse]
+
+func _Service_ImportPolicies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ImportPoliciesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).ImportPolicies(m, &grpc.GenericServerStream[ImportPoliciesRequest, Empty]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ImportPoliciesServer = grpc.ServerStreamingServer[Empty]
+
+func _Service_SimulatePolicies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SimulatePoliciesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).SimulatePolicies(m, &grpc.GenericServerStream[SimulatePoliciesRequest, StringResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_SimulatePoliciesServer = grpc.ServerStreamingServer[StringResponse]
+
+func _Service_ListCache_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).ListCache(m, &grpc.GenericServerStream[Empty, StringResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ListCacheServer = grpc.ServerStreamingServer[StringResponse]
+
+func _Service_PurgeCache_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PurgeCacheRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).PurgeCache(m, &grpc.GenericServerStream[PurgeCacheRequest, Empty]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_PurgeCacheServer = grpc.ServerStreamingServer[Empty]
+
 func _Service_DumpPoliciesDefinitions_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(DumpPolicyDefinitionsRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -447,6 +799,39 @@ func _Service_DumpPoliciesDefinitions_Handler(srv interface{}, stream grpc.Serve
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_DumpPoliciesDefinitionsServer = grpc.ServerStreamingServer[DumpPolicyDefinitionsResponse]
 
+func _Service_ScriptsLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScriptsLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).ScriptsLogs(m, &grpc.GenericServerStream[ScriptsLogsRequest, StringResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_ScriptsLogsServer = grpc.ServerStreamingServer[StringResponse]
+
+func _Service_CertificateStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).CertificateStatus(m, &grpc.GenericServerStream[Empty, StringResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_CertificateStatusServer = grpc.ServerStreamingServer[StringResponse]
+
+func _Service_Doctor_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).Doctor(m, &grpc.GenericServerStream[Empty, StringResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_DoctorServer = grpc.ServerStreamingServer[StringResponse]
+
 func _Service_GetDoc_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(GetDocRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -480,6 +865,17 @@ func _Service_ListUsers_Handler(srv interface{}, stream grpc.ServerStream) error
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Service_ListUsersServer = grpc.ServerStreamingServer[StringResponse]
 
+func _Service_GPOList_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GPOListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ServiceServer).GPOList(m, &grpc.GenericServerStream[GPOListRequest, StringResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Service_GPOListServer = grpc.ServerStreamingServer[StringResponse]
+
 func _Service_GPOListScript_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(Empty)
 	if err := stream.RecvMsg(m); err != nil {
@@ -525,6 +921,11 @@ var Service_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Service_Status_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "Ready",
+			Handler:       _Service_Ready_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "Stop",
 			Handler:       _Service_Stop_Handler,
@@ -535,16 +936,61 @@ var Service_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Service_UpdatePolicy_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "UpdatePolicyWithProgress",
+			Handler:       _Service_UpdatePolicyWithProgress_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "DumpPolicies",
 			Handler:       _Service_DumpPolicies_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ExportPolicies",
+			Handler:       _Service_ExportPolicies_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportPolicies",
+			Handler:       _Service_ImportPolicies_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SimulatePolicies",
+			Handler:       _Service_SimulatePolicies_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListCache",
+			Handler:       _Service_ListCache_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PurgeCache",
+			Handler:       _Service_PurgeCache_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "DumpPoliciesDefinitions",
 			Handler:       _Service_DumpPoliciesDefinitions_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ScriptsLogs",
+			Handler:       _Service_ScriptsLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "CertificateStatus",
+			Handler:       _Service_CertificateStatus_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Doctor",
+			Handler:       _Service_Doctor_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "GetDoc",
 			Handler:       _Service_GetDoc_Handler,
@@ -560,6 +1006,11 @@ var Service_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Service_ListUsers_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GPOList",
+			Handler:       _Service_GPOList_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "GPOListScript",
 			Handler:       _Service_GPOListScript_Handler,
@@ -48,6 +48,7 @@ func New() *App {
 	a.installExpand()
 	a.installAdmx()
 	a.installDoc()
+	a.installValidate()
 
 	return &a
 }
@@ -84,13 +85,15 @@ The generated definition file will be of the form expanded_policies.RELEASE.yaml
 
 func (a *App) installAdmx() {
 	var autoDetectReleases, allowMissingKeys *bool
+	var translations *map[string]string
 	cmd := &cobra.Command{
-		Use:   "admx CATEGORIES_DEF.YAML SOURCE DEST",
+		Use:   "admx CATEGORIES_DEF.YAML [CATEGORIES_DEF.YAML...] SOURCE DEST",
 		Short: gotext.Get("Create finale admx and adml files"),
-		Long:  gotext.Get("Collects all intermediary policy definition files in SOURCE directory to create admx and adml templates in DEST, based on CATEGORIES_DEF.yaml."),
-		Args:  cobra.ExactArgs(3),
+		Long: gotext.Get(`Collects all intermediary policy definition files in SOURCE directory to create admx and adml templates in DEST, based on CATEGORIES_DEF.yaml.
+When multiple category definition files are given, their categories are merged in a single tree: categories with the same name at the same level are only defined once.`),
+		Args: cobra.MinimumNArgs(3),
 		RunE: func(_ *cobra.Command, args []string) error {
-			return admxgen.GenerateAD(args[0], args[1], args[2], *autoDetectReleases, *allowMissingKeys)
+			return admxgen.GenerateAD(args[:len(args)-2], args[len(args)-2], args[len(args)-1], *autoDetectReleases, *allowMissingKeys, *translations)
 		},
 	}
 	autoDetectReleases = cmd.Flags().BoolP("auto-detect-releases", "a", false, gotext.Get("override supported releases in categories definition file and will takes all yaml files in SOURCE directory and use the basename as their versions."))
@@ -99,17 +102,33 @@ func (a *App) installAdmx() {
 	allowMissingKeys = cmd.Flags().BoolP("allow-missing-keys", "k", false, gotext.Get(`avoid fail but display a warning if some keys are not available in a release. This is the case when news keys are added to non-lts releases.`))
 	decorate.LogOnError(a.viper.BindPFlag("allow-missing-keys", cmd.Flags().Lookup("allow-missing-keys")))
 
+	translations = cmd.Flags().StringToStringP("translations", "t", nil, gotext.Get(`translation files per locale, as LOCALE=FILE, to generate an adml in the LOCALE subdirectory of DEST for each of them. A translation file maps the adml string ids to their translation. Untranslated strings fall back to the base ones.`))
+
 	a.rootCmd.AddCommand(cmd)
 }
 
 func (a *App) installDoc() {
 	cmd := &cobra.Command{
-		Use:   "doc CATEGORIES_DEF.YAML SOURCE DEST",
+		Use:   "doc CATEGORIES_DEF.YAML [CATEGORIES_DEF.YAML...] SOURCE DEST",
 		Short: gotext.Get("Create markdown documentation"),
-		Long:  gotext.Get("Collects all intermediary policy definition files in SOURCE directory to create markdown documentation in DEST, based on CATEGORIES_DEF.yaml."),
-		Args:  cobra.ExactArgs(3),
+		Long:  gotext.Get("Collects all intermediary policy definition files in SOURCE directory to create markdown documentation in DEST, based on CATEGORIES_DEF.yaml files, merged as for the admx command."),
+		Args:  cobra.MinimumNArgs(3),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return admxgen.GenerateDoc(args[:len(args)-2], args[len(args)-2], args[len(args)-1])
+		},
+	}
+
+	a.rootCmd.AddCommand(cmd)
+}
+
+func (a *App) installValidate() {
+	cmd := &cobra.Command{
+		Use:   "validate FILE.ADMX",
+		Short: gotext.Get("Check an admx file against the subset of the PolicyDefinitions schema admxgen generates"),
+		Long:  gotext.Get("Checks FILE.ADMX against the subset of the PolicyDefinitions schema covering the elements admxgen generates, reporting the offending elements with their line. This is not a full validation against the PolicyDefinitions XSD: elements admxgen never generates, like presentations or list boxes, are reported as unknown."),
+		Args:  cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
-			return admxgen.GenerateDoc(args[0], args[1], args[2])
+			return admxgen.Validate(args[0])
 		},
 	}
 
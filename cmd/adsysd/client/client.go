@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/ubuntu/adsys/internal/cmdhandler"
 	"github.com/ubuntu/adsys/internal/config"
+	adsysconfig "github.com/ubuntu/adsys/internal/config/adsys"
 	"github.com/ubuntu/adsys/internal/consts"
 	"github.com/ubuntu/adsys/internal/grpc/grpcerror"
 	"github.com/ubuntu/decorate"
@@ -27,14 +28,7 @@ type App struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	config daemonConfig
-}
-
-type daemonConfig struct {
-	Verbose            int
-	Socket             string
-	ClientTimeout      int  `mapstructure:"client_timeout"`
-	DetectCachedTicket bool `mapstructure:"detect_cached_ticket"`
+	config adsysconfig.ClientConfig
 }
 
 // New registers commands and return a new App.
@@ -59,7 +53,7 @@ func New() *App {
 			// command parsing has been successful. Returns runtime (or configuration) error now and so, don’t print usage.
 			a.rootCmd.SilenceUsage = true
 			err := config.Init("adsys", a.rootCmd, a.viper, func(refreshed bool) error {
-				var newConfig daemonConfig
+				var newConfig adsysconfig.ClientConfig
 				if err := config.LoadConfig(&newConfig, a.viper); err != nil {
 					return err
 				}
@@ -105,7 +99,9 @@ func New() *App {
 	decorate.LogOnError(a.viper.BindPFlag("client_timeout", a.rootCmd.PersistentFlags().Lookup("timeout")))
 
 	// subcommands
+	a.installConfig()
 	a.installDoc()
+	a.installDoctor()
 	a.installPolicy()
 	a.installService()
 	a.installVersion()
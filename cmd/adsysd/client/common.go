@@ -4,7 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 
+	"github.com/leonelquinteros/gotext"
+	"github.com/spf13/cobra"
 	"github.com/ubuntu/adsys"
 )
 
@@ -32,3 +36,23 @@ func singleMsg(stream recver) (msg string, err error) {
 
 	return msg, nil
 }
+
+// outputFormats are the formats the status commands can print their output in. The first one is the default.
+var outputFormats = []string{"text", "json"}
+
+// addFormatFlag adds to cmd the flag selecting the output format, with its completion.
+func addFormatFlag(cmd *cobra.Command) *string {
+	format := cmd.Flags().StringP("format", "", outputFormats[0], gotext.Get("output format: %s.", strings.Join(outputFormats, ", ")))
+	_ = cmd.RegisterFlagCompletionFunc("format", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return outputFormats, cobra.ShellCompDirectiveNoFileComp
+	})
+	return format
+}
+
+// checkFormat returns an error if format is not one of the output formats.
+func checkFormat(format string) error {
+	if !slices.Contains(outputFormats, format) {
+		return errors.New(gotext.Get("unsupported output format %q, expected one of: %s", format, strings.Join(outputFormats, ", ")))
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/spf13/cobra"
+	"github.com/ubuntu/adsys/internal/cmdhandler"
+	adsysconfig "github.com/ubuntu/adsys/internal/config/adsys"
+)
+
+func (a *App) installConfig() {
+	mainCmd := &cobra.Command{
+		Use:   "config COMMAND",
+		Short: gotext.Get("Configuration management"),
+		Args:  cmdhandler.SubcommandsRequiredWithSuggestions,
+		RunE:  cmdhandler.NoCmd,
+	}
+	a.rootCmd.AddCommand(mainCmd)
+
+	cmd := &cobra.Command{
+		Use:   "validate FILE",
+		Short: gotext.Get("Validate a configuration file"),
+		Long: gotext.Get(`Validate a configuration file of adsysd and adsysctl, as the daemon does when starting.
+The command fails on any unknown setting or value of the wrong type, listing them all.`),
+		Args: cobra.ExactArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return []string{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+		},
+		RunE: func(_ *cobra.Command, args []string) error { return a.validateConfig(args[0]) },
+	}
+	mainCmd.AddCommand(cmd)
+}
+
+// validateConfig checks the configuration file path and prints its validity.
+func (a *App) validateConfig(path string) error {
+	if err := adsysconfig.ValidateConfigFile(path); err != nil {
+		return err
+	}
+	fmt.Println(gotext.Get("Configuration file %s is valid", path))
+	return nil
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/spf13/cobra"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/adsysservice"
+	"github.com/ubuntu/adsys/internal/cmdhandler"
+)
+
+func (a *App) installDoctor() {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: gotext.Get("Diagnose the machine configuration"),
+		Long: gotext.Get(`Check the machine configuration adsys relies on: the AD backend, the clock skew with the domain controller, the machine keytab, SYSVOL access, the cache directory and dconf.
+Each check passes, warns or fails, with a hint to fix the issue. The command fails if any check fails.`),
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cmdhandler.NoValidArgs,
+		RunE:              func(_ *cobra.Command, _ []string) error { return a.doctor() },
+	}
+	a.rootCmd.AddCommand(cmd)
+}
+
+// doctor prints the report of the service checks.
+// The report is printed even if the service returns an error because of failed checks.
+func (a *App) doctor() error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.Doctor(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fmt.Print(r.GetMsg())
+	}
+}
@@ -2,23 +2,31 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/user"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/fatih/color"
 	"github.com/leonelquinteros/gotext"
 	"github.com/spf13/cobra"
 	"github.com/ubuntu/adsys"
 	"github.com/ubuntu/adsys/internal/ad"
+	"github.com/ubuntu/adsys/internal/ad/admxgen"
 	"github.com/ubuntu/adsys/internal/adsysservice"
 	"github.com/ubuntu/adsys/internal/cmdhandler"
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies"
+	policydefinitions "github.com/ubuntu/adsys/policies"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sys/unix"
 )
@@ -47,7 +55,25 @@ func (a *App) installPolicy() {
 	distro = mainCmd.Flags().StringP("distro", "", consts.DistroID, gotext.Get("distro for which to retrieve policy definition."))
 	policyCmd.AddCommand(mainCmd)
 
-	var details, all, nocolor, isMachine *bool
+	var keysManager, keysFormat *string
+	keysCmd := &cobra.Command{
+		Use:   "keys",
+		Short: gotext.Get("List the policy keys supported by the policy managers, with their value type and whether they can be locked"),
+		Long: gotext.Get(`List the policy keys supported by the policy managers, with their value type and whether they can be locked.
+The keys are read from the policy definitions shipped with adsys, for all supported releases: the daemon is not needed.`),
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cmdhandler.NoValidArgs,
+		RunE:              func(_ *cobra.Command, _ []string) error { return a.listPolicyKeys(*keysManager, *keysFormat) },
+	}
+	keysManager = keysCmd.Flags().StringP("manager", "", "", gotext.Get("only list the keys of the given policy manager. Policy managers are: %s.", strings.Join(policies.Managers, ", ")))
+	_ = keysCmd.RegisterFlagCompletionFunc("manager", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return policies.Managers, cobra.ShellCompDirectiveNoFileComp
+	})
+	keysFormat = addFormatFlag(keysCmd)
+	policyCmd.AddCommand(keysCmd)
+
+	var details, all, sources, nocolor, isMachine *bool
+	var appliedFormat *string
 	appliedCmd := &cobra.Command{
 		Use:   "applied [USER_NAME]",
 		Short: gotext.Get("Print last applied GPOs for current or given user/machine"),
@@ -64,16 +90,175 @@ func (a *App) installPolicy() {
 			if len(args) > 0 {
 				target = args[0]
 			}
-			return a.dumpPolicies(target, *details, *all, *nocolor, *isMachine)
+			return a.dumpPolicies(target, *details, *all, *sources, *nocolor, *isMachine, *appliedFormat)
 		},
 	}
 	details = appliedCmd.Flags().BoolP("details", "", false, gotext.Get("show applied rules in addition to GPOs."))
 	all = appliedCmd.Flags().BoolP("all", "a", false, gotext.Get("show overridden rules in each GPOs."))
+	sources = appliedCmd.Flags().BoolP("sources", "", false, gotext.Get("show the rules applied after merging all GPOs, with the GPOs they come from."))
 	nocolor = appliedCmd.Flags().BoolP("no-color", "", false, gotext.Get("don't display colorized version."))
 	isMachine = appliedCmd.Flags().BoolP("machine", "m", false, gotext.Get("show applied rules to the machine."))
+	appliedFormat = addFormatFlag(appliedCmd)
 	policyCmd.AddCommand(appliedCmd)
 	cmdhandler.RegisterAlias(appliedCmd, &a.rootCmd)
 
+	var exportMachine *bool
+	exportCmd := &cobra.Command{
+		Use:   "export FILE [USER_NAME]",
+		Short: gotext.Get("Export the policies applied to current or given user/machine to a snapshot file"),
+		Args:  cobra.RangeArgs(1, 2),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			switch len(args) {
+			case 0:
+				return nil, cobra.ShellCompDirectiveDefault
+			case 1:
+				return a.users(false), cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 1 {
+				target = args[1]
+			}
+			return a.exportPolicies(args[0], target, *exportMachine)
+		},
+	}
+	exportMachine = exportCmd.Flags().BoolP("machine", "m", false, gotext.Get("export the policies applied to the machine."))
+	policyCmd.AddCommand(exportCmd)
+
+	var importMachine *bool
+	importCmd := &cobra.Command{
+		Use:   "import FILE [USER_NAME]",
+		Short: gotext.Get("Import a snapshot of policies for current or given user/machine"),
+		Long: gotext.Get(`Import a snapshot of policies exported with the export command, for current or given user/machine.
+Imported policies are applied instead of the AD ones on next update only if the use_imported_policies daemon setting is true.`),
+		Args: cobra.RangeArgs(1, 2),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			switch len(args) {
+			case 0:
+				return nil, cobra.ShellCompDirectiveDefault
+			case 1:
+				return a.users(false), cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 1 {
+				target = args[1]
+			}
+			return a.importPolicies(args[0], target, *importMachine)
+		},
+	}
+	importMachine = importCmd.Flags().BoolP("machine", "m", false, gotext.Get("import the policies for the machine."))
+	policyCmd.AddCommand(importCmd)
+
+	var simulateDirs *[]string
+	var simulateScope *string
+	simulateCmd := &cobra.Command{
+		Use:   "simulate [USER_NAME]",
+		Short: gotext.Get("Print what applying local GPOs would do for current or given user/machine, without changing anything"),
+		Long: gotext.Get(`Print what applying local GPO directories would do for current or given user/machine, without changing anything.
+Each GPO directory has the same layout as in SYSVOL, with its Machine/Registry.pol and User/Registry.pol files.
+GPOs are merged in the order of the --gpo-dir flags, the first one having the highest precedence. Nothing is downloaded
+nor applied, and the output of each policy manager is printed instead.`),
+		Args: cmdhandler.ZeroOrNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return a.users(false), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return a.simulatePolicies(*simulateDirs, target, *simulateScope)
+		},
+	}
+	simulateDirs = simulateCmd.Flags().StringSliceP("gpo-dir", "", nil, gotext.Get("GPO directory to simulate. Can be repeated, from the highest precedence GPO to the lowest."))
+	_ = simulateCmd.MarkFlagRequired("gpo-dir")
+	_ = simulateCmd.MarkFlagDirname("gpo-dir")
+	simulateScope = simulateCmd.Flags().StringP("scope", "", "user", gotext.Get("scope of the simulated policies: user or machine."))
+	_ = simulateCmd.RegisterFlagCompletionFunc("scope", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return []string{"user", "machine"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	policyCmd.AddCommand(simulateCmd)
+
+	var logsMachine *bool
+	var logsCount *int
+	scriptsLogCmd := &cobra.Command{
+		Use:   "scripts-log [USER_NAME]",
+		Short: gotext.Get("Print the output of the last scripts runs for current or given user/machine"),
+		Args:  cmdhandler.ZeroOrNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			return a.users(true), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return a.scriptsLogs(target, *logsMachine, *logsCount)
+		},
+	}
+	logsMachine = scriptsLogCmd.Flags().BoolP("machine", "m", false, gotext.Get("show the output of the machine scripts."))
+	logsCount = scriptsLogCmd.Flags().IntP("count", "n", 1, gotext.Get("number of most recent runs to show. 0 for all saved runs."))
+	policyCmd.AddCommand(scriptsLogCmd)
+
+	cacheCmd := &cobra.Command{
+		Use:   "cache COMMAND",
+		Short: gotext.Get("Manage the policies cached by the daemon"),
+		Args:  cmdhandler.SubcommandsRequiredWithSuggestions,
+		RunE:  cmdhandler.NoCmd,
+	}
+	policyCmd.AddCommand(cacheCmd)
+	cacheListCmd := &cobra.Command{
+		Use:               "list",
+		Short:             gotext.Get("List the users and machine with policies cached, with their size and last update time"),
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cmdhandler.NoValidArgs,
+		RunE:              func(_ *cobra.Command, _ []string) error { return a.listCache() },
+	}
+	cacheCmd.AddCommand(cacheListCmd)
+	var cachePurgeUser *string
+	var cachePurgeMachine, cachePurgeAll *bool
+	cachePurgeCmd := &cobra.Command{
+		Use:   "purge",
+		Short: gotext.Get("Remove the policies cached for a given user, the machine or all of them"),
+		Long: gotext.Get(`Remove the policies cached for a given user, the machine or all of them.
+Applied policies are kept, and all the GPOs of the purged users or machine are downloaded again on their next update.`),
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cmdhandler.NoValidArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return a.purgeCache(*cachePurgeUser, *cachePurgeMachine, *cachePurgeAll)
+		},
+	}
+	cachePurgeUser = cachePurgeCmd.Flags().StringP("user", "u", "", gotext.Get("purge the policies cached for this user."))
+	_ = cachePurgeCmd.RegisterFlagCompletionFunc("user", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return a.users(false), cobra.ShellCompDirectiveNoFileComp
+	})
+	cachePurgeMachine = cachePurgeCmd.Flags().BoolP("machine", "m", false, gotext.Get("purge the policies cached for the machine."))
+	cachePurgeAll = cachePurgeCmd.Flags().BoolP("all", "a", false, gotext.Get("purge the policies cached for the machine and all the users."))
+	cachePurgeCmd.MarkFlagsMutuallyExclusive("user", "machine", "all")
+	cachePurgeCmd.MarkFlagsOneRequired("user", "machine", "all")
+	cacheCmd.AddCommand(cachePurgeCmd)
+
+	certStatusCmd := &cobra.Command{
+		Use:               "cert-status",
+		Short:             gotext.Get("Print the status of the certificates enrolled by the machine"),
+		Args:              cobra.NoArgs,
+		ValidArgsFunction: cmdhandler.NoValidArgs,
+		RunE:              func(_ *cobra.Command, _ []string) error { return a.certificateStatus() },
+	}
+	policyCmd.AddCommand(certStatusCmd)
+
 	debugCmd := &cobra.Command{
 		Use:    "debug",
 		Short:  gotext.Get("Debug various policy infos"),
@@ -90,6 +275,29 @@ func (a *App) installPolicy() {
 		RunE:              func(_ *cobra.Command, _ []string) error { return a.dumpGPOListScript() },
 	}
 	debugCmd.AddCommand(gpoListCmd)
+	var gpoListMachine *bool
+	gpoListGPOsCmd := &cobra.Command{
+		Use:   "gpolist [USER_NAME]",
+		Short: gotext.Get("List the GPOs resolved from AD for current or given user/machine, in the order they apply"),
+		Long: gotext.Get(`List the GPOs resolved from AD for current or given user/machine, highest priority first.
+Nothing is downloaded nor applied.`),
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 || *gpoListMachine {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return a.users(false), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+			return a.listGPOs(target, *gpoListMachine)
+		},
+	}
+	gpoListMachine = gpoListGPOsCmd.Flags().BoolP("machine", "m", false, gotext.Get("list the GPOs of the machine."))
+	debugCmd.AddCommand(gpoListGPOsCmd)
 	certEnrollCmd := &cobra.Command{
 		Use:               "cert-autoenroll-script",
 		Short:             gotext.Get("Write certificate autoenrollment python embedded script in current directory"),
@@ -115,7 +323,8 @@ The command is a no-op if the ticket is not present on disk or the detect_cached
 	}
 	debugCmd.AddCommand(ticketPathCmd)
 
-	var updateMachine, updateAll *bool
+	var updateMachine, updateAll, updateDryRun, updateForce, updateTimings, updateProgress *bool
+	var updateOnly *[]string
 	updateCmd := &cobra.Command{
 		Use:   "update [USER_NAME KERBEROS_TICKET_PATH]",
 		Short: gotext.Get("Updates/Create a policy for current user or given user with its kerberos ticket"),
@@ -142,11 +351,21 @@ The command is a no-op if the ticket is not present on disk or the detect_cached
 			if len(args) > 0 {
 				user, krb5cc = args[0], args[1]
 			}
-			return a.update(*updateMachine, *updateAll, user, krb5cc)
+			return a.update(*updateMachine, *updateAll, *updateDryRun, *updateForce, *updateTimings, *updateProgress, *updateOnly, user, krb5cc)
 		},
 	}
 	updateMachine = updateCmd.Flags().BoolP("machine", "m", false, gotext.Get("machine updates the policy of the computer."))
 	updateAll = updateCmd.Flags().BoolP("all", "a", false, gotext.Get("all updates the policy of the computer and all the logged in users. -m or USER_NAME/TICKET cannot be used with this option."))
+	updateDryRun = updateCmd.Flags().BoolP("dry-run", "", false, gotext.Get("only print the changes which would be applied, without modifying the system."))
+	updateForce = updateCmd.Flags().BoolP("force-refresh", "", false, gotext.Get("download again all GPOs, even if they are up to date."))
+	updateOnly = updateCmd.Flags().StringSliceP("only", "", nil, gotext.Get("only run the given policy managers, skipping the others entirely. Can be repeated. Policy managers are: %s.", strings.Join(policies.Managers, ", ")))
+	_ = updateCmd.RegisterFlagCompletionFunc("only", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return policies.Managers, cobra.ShellCompDirectiveNoFileComp
+	})
+	updateCmd.MarkFlagsMutuallyExclusive("only", "dry-run")
+	updateTimings = updateCmd.Flags().BoolP("timings", "", false, gotext.Get("print how long each policy manager took to apply the policies."))
+	updateCmd.MarkFlagsMutuallyExclusive("timings", "dry-run")
+	updateProgress = updateCmd.Flags().BoolP("progress", "", false, gotext.Get("print the progress of the update on stderr while policies are applied."))
 	policyCmd.AddCommand(updateCmd)
 	cmdhandler.RegisterAlias(updateCmd, &a.rootCmd)
 
@@ -223,7 +442,58 @@ func (a App) getPolicyDefinitions(format, distroID string) (err error) {
 	return nil
 }
 
-func (a *App) dumpPolicies(target string, showDetails, showOverridden, nocolor, isMachine bool) error {
+// listPolicyKeys prints the policy keys of manager, or of all managers if empty, from the policy definitions compiled
+// into the binary. No daemon is needed.
+func (a App) listPolicyKeys(manager, format string) (err error) {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+	if manager != "" && !slices.Contains(policies.Managers, manager) {
+		return errors.New(gotext.Get("unknown policy manager %q, expected one of: %s", manager, strings.Join(policies.Managers, ", ")))
+	}
+
+	admx, err := policydefinitions.All.ReadFile(fmt.Sprintf("%s/all/%s.admx", consts.DistroID, consts.DistroID))
+	if err != nil {
+		return err
+	}
+	keys, err := admxgen.PolicyKeys(admx, consts.DistroID, manager)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if keys == nil {
+			keys = []admxgen.PolicyKey{}
+		}
+		d, err := json.MarshalIndent(keys, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(d))
+		return nil
+	}
+
+	if len(keys) == 0 {
+		fmt.Println(gotext.Get("No policy keys defined"))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", gotext.Get("MANAGER"), gotext.Get("KEY"), gotext.Get("CLASS"), gotext.Get("TYPE"), gotext.Get("LOCKABLE"))
+	for _, k := range keys {
+		lockable := gotext.Get("no")
+		if k.Lockable {
+			lockable = gotext.Get("yes")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.Manager, k.Key, k.Class, k.Type, lockable)
+	}
+	return w.Flush()
+}
+
+func (a *App) dumpPolicies(target string, showDetails, showOverridden, showSources, nocolor, isMachine bool, format string) error {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
 	// incompatible options
 	if showOverridden && !showDetails {
 		showDetails = true
@@ -257,6 +527,8 @@ func (a *App) dumpPolicies(target string, showDetails, showOverridden, nocolor,
 		IsComputer: isMachine,
 		Details:    showDetails,
 		All:        showOverridden,
+		Sources:    showSources,
+		Format:     format,
 	})
 	if err != nil {
 		return err
@@ -267,6 +539,12 @@ func (a *App) dumpPolicies(target string, showDetails, showOverridden, nocolor,
 		return err
 	}
 
+	// The JSON document is printed as is, to be parsed by other tools.
+	if format == "json" {
+		fmt.Println(policies)
+		return nil
+	}
+
 	if nocolor {
 		color.NoColor = true
 	}
@@ -279,6 +557,273 @@ func (a *App) dumpPolicies(target string, showDetails, showOverridden, nocolor,
 	return nil
 }
 
+// snapshotTarget returns the target of a snapshot export or import: the machine, the given user or the current one.
+func snapshotTarget(target string, isMachine bool) (string, error) {
+	if isMachine && target != "" {
+		return "", errors.New(gotext.Get("user arguments cannot be used with machine snapshots"))
+	}
+	if isMachine || target != "" {
+		return target, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve current user: %w", err)
+	}
+	return u.Username, nil
+}
+
+func (a *App) exportPolicies(p, target string, isMachine bool) error {
+	target, err := snapshotTarget(target, isMachine)
+	if err != nil {
+		return err
+	}
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.ExportPolicies(a.ctx, &adsys.ExportPoliciesRequest{
+		Target:     target,
+		IsComputer: isMachine,
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, []byte(snapshot), 0600)
+}
+
+func (a *App) importPolicies(p, target string, isMachine bool) error {
+	target, err := snapshotTarget(target, isMachine)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.ImportPolicies(a.ctx, &adsys.ImportPoliciesRequest{
+		Target:     target,
+		IsComputer: isMachine,
+		Snapshot:   string(snapshot),
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := stream.Recv(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+func (a *App) simulatePolicies(dirs []string, target, scope string) error {
+	if scope != "user" && scope != "machine" {
+		return errors.New(gotext.Get("invalid scope %q, expected user or machine", scope))
+	}
+	isMachine := scope == "machine"
+	target, err := snapshotTarget(target, isMachine)
+	if err != nil {
+		return err
+	}
+
+	var gpos []*adsys.SimulatedGPO
+	for _, dir := range dirs {
+		gpo, err := readLocalGPO(dir)
+		if err != nil {
+			return err
+		}
+		gpos = append(gpos, gpo)
+	}
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.SimulatePolicies(a.ctx, &adsys.SimulatePoliciesRequest{
+		Target:     target,
+		IsComputer: isMachine,
+		Gpos:       gpos,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+	fmt.Print(msg)
+
+	return nil
+}
+
+// readLocalGPO returns the GPO stored in dir, named after it, with the content of its registry files.
+// As in SYSVOL, the class directories may be uppercase.
+func readLocalGPO(dir string) (*adsys.SimulatedGPO, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(gotext.Get("%s is not a GPO directory", dir))
+	}
+
+	readRegistry := func(classes ...string) ([]byte, error) {
+		for _, class := range classes {
+			content, err := os.ReadFile(filepath.Join(dir, class, "Registry.pol"))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return content, err
+		}
+		return nil, nil
+	}
+
+	machineRegistry, err := readRegistry("Machine", "MACHINE")
+	if err != nil {
+		return nil, err
+	}
+	userRegistry, err := readRegistry("User", "USER")
+	if err != nil {
+		return nil, err
+	}
+	if machineRegistry == nil && userRegistry == nil {
+		return nil, errors.New(gotext.Get("%s is not a GPO directory: no Machine/Registry.pol nor User/Registry.pol file", dir))
+	}
+
+	return &adsys.SimulatedGPO{
+		Name:            filepath.Base(filepath.Clean(dir)),
+		MachineRegistry: machineRegistry,
+		UserRegistry:    userRegistry,
+	}, nil
+}
+
+func (a *App) listCache() error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.ListCache(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	list, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+	fmt.Print(list)
+
+	return nil
+}
+
+func (a *App) purgeCache(target string, isMachine, all bool) error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.PurgeCache(a.ctx, &adsys.PurgeCacheRequest{
+		Target:     target,
+		IsComputer: isMachine,
+		All:        all,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := stream.Recv(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+func (a *App) scriptsLogs(target string, isMachine bool, count int) error {
+	if count < 0 {
+		return errors.New(gotext.Get("count must be positive, got %d", count))
+	}
+	if isMachine && target != "" {
+		return errors.New(gotext.Get("can't show the output of user %q scripts with the machine ones", target))
+	}
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Show current user logs
+	if target == "" && !isMachine {
+		u, err := user.Current()
+		if err != nil {
+			return fmt.Errorf("failed to retrieve current user: %w", err)
+		}
+		target = u.Username
+	}
+
+	stream, err := client.ScriptsLogs(a.ctx, &adsys.ScriptsLogsRequest{
+		Target:     target,
+		IsComputer: isMachine,
+		Count:      int32(count),
+	})
+	if err != nil {
+		return err
+	}
+
+	logs, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+	fmt.Print(logs)
+
+	return nil
+}
+
+func (a *App) certificateStatus() error {
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.CertificateStatus(a.ctx, &adsys.Empty{})
+	if err != nil {
+		return err
+	}
+
+	status, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+	fmt.Print(status)
+
+	return nil
+}
+
 func (a *App) dumpGPOListScript() error {
 	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
 	if err != nil {
@@ -299,6 +844,64 @@ func (a *App) dumpGPOListScript() error {
 	return os.WriteFile("adsys-gpolist", []byte(script), 0600)
 }
 
+func (a *App) listGPOs(target string, isMachine bool) error {
+	if isMachine && target != "" {
+		return errors.New(gotext.Get("user arguments cannot be used with machine GPO list"))
+	}
+
+	// get target for computer
+	if isMachine {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		// for malconfigured machines where /proc/sys/kernel/hostname returns the fqdn and not only the machine name, strip it
+		target, _, _ = strings.Cut(hostname, ".")
+	}
+
+	// List for current user, with their ticket
+	var krb5cc string
+	if target == "" {
+		u, err := user.Current()
+		if err != nil {
+			return fmt.Errorf("failed to retrieve current user: %w", err)
+		}
+		target = u.Username
+		krb5cc = strings.TrimPrefix(os.Getenv("KRB5CCNAME"), "FILE:")
+		if krb5cc == "" && a.config.DetectCachedTicket {
+			krb5cc, err = ad.TicketPath()
+			// Don't return an error as we might still have a cached ticket
+			// under /run/adsys/krb5cc
+			if err != nil {
+				log.Warningf(a.ctx, "Failed to get ticket path: %v", err)
+			}
+		}
+	}
+
+	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	stream, err := client.GPOList(a.ctx, &adsys.GPOListRequest{
+		IsComputer: isMachine,
+		Target:     target,
+		Krb5Cc:     krb5cc,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg, err := singleMsg(stream)
+	if err != nil {
+		return err
+	}
+	fmt.Print(msg)
+
+	return nil
+}
+
 func (a *App) dumpCertEnrollScript() error {
 	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
 	if err != nil {
@@ -384,8 +987,12 @@ func colorizePolicies(policies string) (string, error) {
 			// Policy entry
 			prefix := strings.TrimSpace(strings.Split(e, " ")[0])
 
-			var overridden, disabledKey bool
+			var overridden, disabledKey, deletedKey bool
 			switch prefix {
+			case "*":
+				// GPOs the entry comes from
+				out.Println(color.HiBlackString("          %s", gotext.Get("from %s", strings.TrimSpace(e[1:]))))
+				continue
 			case "-":
 				overridden = true
 				e = e[2:]
@@ -396,6 +1003,13 @@ func colorizePolicies(policies string) (string, error) {
 				overridden = true
 				disabledKey = true
 				e = e[3:]
+			case "x":
+				deletedKey = true
+				e = e[2:]
+			case "-x":
+				overridden = true
+				deletedKey = true
+				e = e[3:]
 			default:
 				if len(e) > 0 {
 					e = e[1:]
@@ -403,6 +1017,9 @@ func colorizePolicies(policies string) (string, error) {
 			}
 
 			indent := "        - "
+			if deletedKey {
+				e = gotext.Get("%s: Deleted", e)
+			}
 			if disabledKey {
 				if currentPoliciesType == "dconf" {
 					e = gotext.Get("%s: Locked to system default", e)
@@ -417,6 +1034,14 @@ func colorizePolicies(policies string) (string, error) {
 			}
 			out.Println(e)
 
+		} else if e := strings.TrimPrefix(l, "!!!"); e != l {
+			// Conflicting key
+			out.Println(color.YellowString("    - %s", strings.TrimSpace(e)))
+
+		} else if e := strings.TrimPrefix(l, "!!"); e != l {
+			// Conflicts
+			out.Println(fmt.Sprintf("- %s", color.YellowString(strings.TrimSpace(e))))
+
 		} else if e := strings.TrimPrefix(l, "**"); e != l {
 			// Type of policy
 			e = strings.TrimSpace(e)
@@ -426,7 +1051,8 @@ func colorizePolicies(policies string) (string, error) {
 		} else if e := strings.TrimPrefix(l, "*"); e != l {
 			// GPO
 			e = strings.TrimSpace(e)
-			i := strings.LastIndex(e, " ")
+			// The GPO ID can be followed by its enforcement.
+			i := strings.LastIndex(e, " (")
 			gpoName := e[:i]
 			gpoID := e[i:]
 			out.Println(fmt.Sprintf("- %s%s", color.MagentaString(gpoName), gpoID))
@@ -459,7 +1085,7 @@ func (s *stringsBuilderWithError) Println(l string) {
 	_, s.err = s.Builder.WriteString(l)
 }
 
-func (a *App) update(isComputer, updateAll bool, target, krb5cc string) error {
+func (a *App) update(isComputer, updateAll, dryRun, forceRefresh, timings, progress bool, managers []string, target, krb5cc string) error {
 	// incompatible options
 	if updateAll && (isComputer || target != "" || krb5cc != "") {
 		return errors.New(gotext.Get("machine or user arguments cannot be used with update all"))
@@ -467,6 +1093,11 @@ func (a *App) update(isComputer, updateAll bool, target, krb5cc string) error {
 	if isComputer && (target != "" || krb5cc != "") {
 		return errors.New(gotext.Get("user arguments cannot be used with machine update"))
 	}
+	for _, m := range managers {
+		if !slices.Contains(policies.Managers, m) {
+			return errors.New(gotext.Get("unknown policy manager %q, expected one of: %s", m, strings.Join(policies.Managers, ", ")))
+		}
+	}
 
 	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
 	if err != nil {
@@ -502,22 +1133,94 @@ func (a *App) update(isComputer, updateAll bool, target, krb5cc string) error {
 		}
 	}
 
-	stream, err := client.UpdatePolicy(a.ctx, &adsys.UpdatePolicyRequest{
-		IsComputer: isComputer,
-		All:        updateAll,
-		Target:     target,
-		Krb5Cc:     krb5cc})
+	req := &adsys.UpdatePolicyRequest{
+		IsComputer:   isComputer,
+		All:          updateAll,
+		Target:       target,
+		Krb5Cc:       krb5cc,
+		DryRun:       dryRun,
+		ForceRefresh: forceRefresh,
+		Managers:     managers,
+		Timings:      timings}
+	if progress {
+		return a.updateWithProgress(client, req)
+	}
+
+	stream, err := client.UpdatePolicy(a.ctx, req)
 	if err != nil {
 		return err
 	}
 
-	if _, err := stream.Recv(); err != nil && !errors.Is(err, io.EOF) {
+	// Changes are only streamed on dry run, and timings on request
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Print(r.GetMsg())
+	}
+
+	return nil
+}
+
+// updateWithProgress updates the policies as requested by req, rendering the progress of the update on stderr.
+// Changes and timings are still printed on stdout.
+func (a *App) updateWithProgress(client *adsysservice.AdSysClient, req *adsys.UpdatePolicyRequest) error {
+	stream, err := client.UpdatePolicyWithProgress(a.ctx, req)
+	if err != nil {
 		return err
 	}
 
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		// The error of a failed update is returned once its final progress is rendered.
+		if err != nil {
+			return err
+		}
+		if r.GetMsg() != "" {
+			fmt.Print(r.GetMsg())
+			continue
+		}
+		fmt.Fprint(os.Stderr, formatProgress(r))
+	}
+
 	return nil
 }
 
+// formatProgress returns the line rendering the progress p of a policy update.
+func formatProgress(p *adsys.UpdatePolicyProgress) string {
+	// The error itself is reported as the command one.
+	if p.GetDone() && p.GetError() != "" {
+		return gotext.Get("policy update failed") + "\n"
+	}
+
+	var state string
+	switch {
+	case p.GetDone():
+		state = gotext.Get("policy update completed")
+	case p.GetStage() == policies.StageFetch:
+		state = gotext.Get("fetching GPOs")
+	case p.GetStage() == policies.StageApply && p.GetManager() == "":
+		state = gotext.Get("applying policies")
+	case p.GetStage() == policies.StageApply:
+		state = gotext.Get("%s policy manager completed", p.GetManager())
+	case p.GetStage() == policies.StageFlush:
+		state = gotext.Get("compiling dconf databases")
+	default:
+		state = p.GetStage()
+	}
+	if p.GetTarget() != "" {
+		state = fmt.Sprintf("%s: %s", p.GetTarget(), state)
+	}
+	return fmt.Sprintf("[%3d%%] %s\n", p.GetPercentage(), state)
+}
+
 func (a *App) purge(isComputer, purgeAll bool, target string) error {
 	// incompatible options
 	if purgeAll && target != "" {
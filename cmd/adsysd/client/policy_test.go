@@ -6,6 +6,8 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -51,3 +53,32 @@ Policies from user configuration:
 	want := testutils.LoadWithUpdateFromGolden(t, got)
 	require.Equal(t, want, got, "colorizePolicies returned expected formatted output")
 }
+
+func TestFormatProgress(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		progress *adsys.UpdatePolicyProgress
+
+		want string
+	}{
+		"Fetch stage": {progress: &adsys.UpdatePolicyProgress{Target: "host", Stage: policies.StageFetch}, want: "[  0%] host: fetching GPOs\n"},
+		"Apply stage start": {progress: &adsys.UpdatePolicyProgress{Target: "host", Stage: policies.StageApply, Percentage: 10},
+			want: "[ 10%] host: applying policies\n"},
+		"Apply stage manager completion": {progress: &adsys.UpdatePolicyProgress{Target: "host", Stage: policies.StageApply, Manager: "dconf", Percentage: 20},
+			want: "[ 20%] host: dconf policy manager completed\n"},
+		"Flush stage of all objects": {progress: &adsys.UpdatePolicyProgress{Stage: policies.StageFlush, Percentage: 90},
+			want: "[ 90%] compiling dconf databases\n"},
+		"Unknown stage is printed as is": {progress: &adsys.UpdatePolicyProgress{Stage: "unknown", Percentage: 50}, want: "[ 50%] unknown\n"},
+		"Completed update":               {progress: &adsys.UpdatePolicyProgress{Done: true, Percentage: 100}, want: "[100%] policy update completed\n"},
+		"Failed update":                  {progress: &adsys.UpdatePolicyProgress{Done: true, Error: "some error"}, want: "policy update failed\n"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := formatProgress(tc.progress)
+			require.Equal(t, tc.want, got, "formatProgress should return the expected progress line")
+		})
+	}
+}
@@ -32,13 +32,17 @@ func (a *App) installService() {
 	}
 	mainCmd.AddCommand(cmd)
 
+	var statusFormat *string
+	var statusWaitReady *bool
 	cmd = &cobra.Command{
 		Use:               "status",
 		Short:             gotext.Get("Print service status"),
 		Args:              cobra.NoArgs,
 		ValidArgsFunction: cmdhandler.NoValidArgs,
-		RunE:              func(_ *cobra.Command, _ []string) error { return a.getStatus() },
+		RunE:              func(_ *cobra.Command, _ []string) error { return a.getStatus(*statusFormat, *statusWaitReady) },
 	}
+	statusFormat = addFormatFlag(cmd)
+	statusWaitReady = cmd.Flags().BoolP("wait-ready", "", false, gotext.Get("wait for the service to be fully initialized before printing its status, up to the client timeout."))
 	mainCmd.AddCommand(cmd)
 
 	var stopForce *bool
@@ -82,14 +86,29 @@ func (a *App) serviceCat() error {
 }
 
 // getStatus returns the current server status.
-func (a App) getStatus() (err error) {
+// If waitReady is true, it waits first for the server to be ready.
+func (a App) getStatus(format string, waitReady bool) (err error) {
+	if err := checkFormat(format); err != nil {
+		return err
+	}
+
 	client, err := adsysservice.NewClient(a.config.Socket, a.getTimeout())
 	if err != nil {
 		return err
 	}
 	defer client.Close()
 
-	stream, err := client.Status(a.ctx, &adsys.Empty{})
+	if waitReady {
+		ready, err := client.Ready(a.ctx, &adsys.ReadyRequest{Wait: true})
+		if err != nil {
+			return err
+		}
+		if _, err := ready.Recv(); err != nil {
+			return err
+		}
+	}
+
+	stream, err := client.Status(a.ctx, &adsys.StatusRequest{Format: format})
 	if err != nil {
 		return err
 	}
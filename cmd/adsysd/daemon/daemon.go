@@ -3,18 +3,21 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"github.com/ubuntu/adsys/internal/ad/backends/sss"
-	"github.com/ubuntu/adsys/internal/ad/backends/winbind"
 	"github.com/ubuntu/adsys/internal/adsysservice"
 	"github.com/ubuntu/adsys/internal/cmdhandler"
 	"github.com/ubuntu/adsys/internal/config"
+	adsysconfig "github.com/ubuntu/adsys/internal/config/adsys"
 	"github.com/ubuntu/adsys/internal/consts"
 	"github.com/ubuntu/adsys/internal/daemon"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
@@ -29,35 +32,15 @@ type App struct {
 	rootCmd cobra.Command
 	viper   *viper.Viper
 
-	config daemonConfig
-	daemon *daemon.Daemon
+	// configMu protects config and service from concurrent reloads.
+	configMu sync.Mutex
+	config   adsysconfig.DaemonConfig
+	daemon   *daemon.Daemon
+	service  *adsysservice.Service
 
 	ready chan struct{}
 }
 
-type daemonConfig struct {
-	Verbose int
-
-	Socket   string
-	CacheDir string `mapstructure:"cache_dir"`
-	StateDir string `mapstructure:"state_dir"`
-	RunDir   string `mapstructure:"run_dir"`
-
-	DconfDir       string `mapstructure:"dconf_dir"`
-	SudoersDir     string `mapstructure:"sudoers_dir"`
-	PolicyKitDir   string `mapstructure:"policykit_dir"`
-	ApparmorDir    string `mapstructure:"apparmor_dir"`
-	ApparmorFsDir  string `mapstructure:"apparmorfs_dir"`
-	SystemUnitDir  string `mapstructure:"systemunit_dir"`
-	GlobalTrustDir string `mapstructure:"global_trust_dir"`
-
-	AdBackend     string         `mapstructure:"ad_backend"`
-	SSSdConfig    sss.Config     `mapstructure:"sssd"`
-	WinbindConfig winbind.Config `mapstructure:"winbind"`
-
-	ServiceTimeout int `mapstructure:"service_timeout"`
-}
-
 // New registers commands and return a new App.
 func New() *App {
 	a := App{ready: make(chan struct{})}
@@ -68,43 +51,7 @@ func New() *App {
 		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
 			// command parsing has been successful. Returns runtime (or configuration) error now and so, don’t print usage.
 			a.rootCmd.SilenceUsage = true
-			err := config.Init("adsys", a.rootCmd, a.viper, func(refreshed bool) error {
-				var newConfig daemonConfig
-				if err := config.LoadConfig(&newConfig, a.viper); err != nil {
-					return err
-				}
-
-				// First run: just init configuration.
-				if !refreshed {
-					a.config = newConfig
-					return nil
-				}
-
-				// Config reload
-
-				// No change in config file: skip.
-				if a.config == newConfig {
-					return nil
-				}
-
-				// Reload necessary parts
-				oldVerbose := a.config.Verbose
-				oldSocket := a.config.Socket
-				oldTimeout := a.config.ServiceTimeout
-				a.config = newConfig
-				if oldVerbose != a.config.Verbose {
-					config.SetVerboseMode(a.config.Verbose)
-				}
-				if oldSocket != a.config.Socket {
-					if err := a.changeServerSocket(a.config.Socket); err != nil {
-						log.Error(context.Background(), err)
-					}
-				}
-				if oldTimeout != a.config.ServiceTimeout {
-					a.changeServiceTimeout(time.Duration(a.config.ServiceTimeout) * time.Second)
-				}
-				return nil
-			})
+			err := config.Init("adsys", a.rootCmd, a.viper, a.loadConfig)
 			// Set configured verbose status for the daemon.
 			config.SetVerboseMode(a.config.Verbose)
 			return err
@@ -125,21 +72,46 @@ func New() *App {
 				adsysservice.WithADBackend(a.config.AdBackend),
 				adsysservice.WithSSSConfig(a.config.SSSdConfig),
 				adsysservice.WithWinbindConfig(a.config.WinbindConfig),
+				adsysservice.WithLocalConfig(a.config.LocalConfig),
+				adsysservice.WithCertificateRenewalLeadTime(time.Duration(a.config.CertificateRenewalLeadDays)*24*time.Hour),
+				adsysservice.WithGPOLinkOrder(a.config.GPOLinkOrder),
+				adsysservice.WithLoopbackProcessing(a.config.LoopbackProcessing),
+				adsysservice.WithDconfUserLayers(a.config.DconfUserLayers),
+				adsysservice.WithGPODownloadConcurrency(a.config.GPODownloadConcurrency),
+				adsysservice.WithImportedPolicies(a.config.UseImportedPolicies),
+				adsysservice.WithMaxCacheAge(time.Duration(a.config.OfflineMaxCacheAgeDays)*24*time.Hour),
+				adsysservice.WithPreferredServer(a.config.PreferredADServer),
+				adsysservice.WithSite(a.config.ADSite),
+				adsysservice.WithUnsignedSYSVOL(a.config.AllowUnsignedSYSVOL),
+				adsysservice.WithSYSVOLEncryption(a.config.RequireSYSVOLEncryption),
+				adsysservice.WithTicketRenewalThreshold(time.Duration(a.config.TicketRenewalMinutes)*time.Minute),
+				adsysservice.WithApplyTimeout(time.Duration(a.config.ApplyTimeout)*time.Second),
+				adsysservice.WithApplyLockTimeout(time.Duration(a.config.ApplyLockTimeout)*time.Second),
+				adsysservice.WithAllowedGroups(a.config.AllowedGroups),
+				adsysservice.WithMetricsAddress(a.config.MetricsAddress),
+				adsysservice.WithAuditLog(a.config.AuditLog, a.config.AuditLogFormat),
+				adsysservice.WithRefreshRateLimit(time.Duration(a.config.RefreshMinIntervalSeconds)*time.Second,
+					time.Duration(a.config.RefreshCoalesceWindowMs)*time.Millisecond),
+				adsysservice.WithRefreshOnNetworkUp(a.config.RefreshOnNetworkUp),
+				adsysservice.WithDisabledManagers(a.config.DisabledManagers, a.config.CleanupDisabledManagers),
 			)
 			if err != nil {
 				close(a.ready)
 				return err
 			}
 
-			timeout := time.Duration(a.config.ServiceTimeout) * time.Second
 			d, err := daemon.New(adsys.RegisterGRPCServer, a.config.Socket,
-				daemon.WithTimeout(timeout),
-				daemon.WithServerQuit(adsys.Quit))
+				daemon.WithTimeout(serviceTimeout(a.config)),
+				daemon.WithServerQuit(adsys.Quit),
+				daemon.WithReadiness(adsys.Initialize))
 			if err != nil {
 				close(a.ready)
 				return err
 			}
+			a.configMu.Lock()
 			a.daemon = d
+			a.service = adsys
+			a.configMu.Unlock()
 			close(a.ready)
 			return a.daemon.Listen()
 		},
@@ -174,6 +146,128 @@ func New() *App {
 	return &a
 }
 
+// liveSettings are the configuration settings, by field name, which can be changed while the daemon is running.
+// The AD backend, directories and policy managers are set up once when starting: changing them requires a restart.
+var liveSettings = map[string]bool{
+	"Verbose":                true,
+	"Socket":                 true,
+	"ServiceTimeout":         true,
+	"GPODownloadConcurrency": true,
+	"OfflineMaxCacheAgeDays": true,
+}
+
+// loadConfig decodes the configuration. On first load, it initializes the configuration.
+// When refreshed, it applies in place the settings which can be changed while running.
+func (a *App) loadConfig(refreshed bool) error {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+
+	// Unknown settings and values of the wrong type are rejected, instead of being ignored or converted.
+	if configFile := a.viper.ConfigFileUsed(); configFile != "" {
+		if err := adsysconfig.ValidateConfigFile(configFile); err != nil {
+			return err
+		}
+	}
+
+	var newConfig adsysconfig.DaemonConfig
+	if err := config.LoadConfig(&newConfig, a.viper); err != nil {
+		return err
+	}
+
+	// First run: just init configuration.
+	if !refreshed {
+		a.config = newConfig
+		return nil
+	}
+
+	// No change in config file: skip.
+	if reflect.DeepEqual(a.config, newConfig) {
+		return nil
+	}
+
+	return a.applyConfig(newConfig)
+}
+
+// applyConfig reloads the settings of newConfig which can be changed while running.
+// The other settings keep their current value and an error listing them is returned, until the daemon restarts.
+// Policy applications in progress complete with the settings they started with.
+func (a *App) applyConfig(newConfig adsysconfig.DaemonConfig) error {
+	old := a.config
+	var errs []error
+
+	var rejected []string
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(&newConfig).Elem()
+	for i := 0; i < newValue.NumField(); i++ {
+		field := newValue.Type().Field(i)
+		if liveSettings[field.Name] || reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		rejected = append(rejected, name)
+		newValue.Field(i).Set(oldValue.Field(i))
+	}
+	if len(rejected) > 0 {
+		errs = append(errs, errors.New(gotext.Get("configuration changes of %s can't be applied while running: restart adsysd to apply them", strings.Join(rejected, ", "))))
+	}
+
+	if a.service != nil && old.GPODownloadConcurrency != newConfig.GPODownloadConcurrency {
+		if err := a.service.SetGPODownloadConcurrency(newConfig.GPODownloadConcurrency); err != nil {
+			errs = append(errs, err)
+			newConfig.GPODownloadConcurrency = old.GPODownloadConcurrency
+		}
+	}
+	if a.service != nil && old.OfflineMaxCacheAgeDays != newConfig.OfflineMaxCacheAgeDays {
+		if err := a.service.SetMaxCacheAge(time.Duration(newConfig.OfflineMaxCacheAgeDays) * 24 * time.Hour); err != nil {
+			errs = append(errs, err)
+			newConfig.OfflineMaxCacheAgeDays = old.OfflineMaxCacheAgeDays
+		}
+	}
+
+	a.config = newConfig
+	if old.Verbose != a.config.Verbose {
+		config.SetVerboseMode(a.config.Verbose)
+	}
+	if old.Socket != a.config.Socket {
+		if err := a.changeServerSocket(a.config.Socket); err != nil {
+			log.Error(context.Background(), err)
+		}
+	}
+	if serviceTimeout(old) != serviceTimeout(a.config) {
+		a.changeServiceTimeout(serviceTimeout(a.config))
+	}
+
+	return errors.Join(errs...)
+}
+
+// serviceTimeout returns the time without activity before the daemon exits, 0 for no timeout.
+// The daemon keeps running when refreshing the machine policy on network changes, to be notified of them.
+func serviceTimeout(c adsysconfig.DaemonConfig) time.Duration {
+	if c.RefreshOnNetworkUp {
+		return 0
+	}
+	return time.Duration(c.ServiceTimeout) * time.Second
+}
+
+// reloadConfigFile reads the configuration file again, if any, and reloads the configuration.
+func (a *App) reloadConfigFile() error {
+	configFile := a.viper.ConfigFileUsed()
+	if configFile == "" {
+		return nil
+	}
+	log.Infof(context.Background(), "Reloading configuration file %q.", configFile)
+
+	a.configMu.Lock()
+	err := a.viper.ReadInConfig()
+	a.configMu.Unlock()
+	if err != nil {
+		return errors.New(gotext.Get("invalid configuration file: %v", err))
+	}
+	return a.loadConfig(true)
+}
+
 // changeServerSocket change the socket on server.
 func (a *App) changeServerSocket(socket string) error {
 	if a.daemon == nil {
@@ -196,15 +290,19 @@ func (a *App) Run() error {
 }
 
 // UsageError returns if the error is a command parsing or runtime one.
-func (a App) UsageError() bool {
+func (a *App) UsageError() bool {
 	return !a.rootCmd.SilenceUsage
 }
 
-// Hup prints all goroutine stack traces and return false to signal you shouldn't quit.
-func (a App) Hup() (shouldQuit bool) {
+// Hup prints all goroutine stack traces, reloads the configuration file and return false to signal you shouldn't quit.
+func (a *App) Hup() (shouldQuit bool) {
 	buf := make([]byte, 1<<16)
 	runtime.Stack(buf, true)
 	fmt.Printf("%s", buf)
+
+	if err := a.reloadConfigFile(); err != nil {
+		log.Warningf(context.Background(), "Error while refreshing configuration: %v", err)
+	}
 	return false
 }
 
@@ -218,7 +316,7 @@ func (a *App) Quit() {
 }
 
 // RootCmd returns a copy of the root command for the app. Shouldn’t be in general necessary apart when running generators.
-func (a App) RootCmd() cobra.Command {
+func (a *App) RootCmd() cobra.Command {
 	return a.rootCmd
 }
 
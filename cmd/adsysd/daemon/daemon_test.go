@@ -243,6 +243,34 @@ func TestConfigLoad(t *testing.T) {
 	require.Equal(t, 1, a.Verbosity(), "Verbosity is set from config")
 }
 
+func TestConfigLoadFailsOnInvalidConfigFile(t *testing.T) {
+	tests := map[string]struct {
+		extra []string
+
+		wantErrs []string
+	}{
+		"Error on unknown setting":         {extra: []string{"cache_directory: /tmp/cache"}, wantErrs: []string{`unknown setting "cache_directory"`}},
+		"Error on unknown nested setting":  {extra: []string{"winbind:", "  addomain: example.com"}, wantErrs: []string{`unknown setting "winbind.addomain"`}},
+		"Error on wrong type of setting":   {extra: []string{"gpo_download_concurrency: two"}, wantErrs: []string{`setting "gpo_download_concurrency" expects a value of type int`}},
+		"Error on all invalid settings":    {extra: []string{"cache_directory: /tmp/cache", "allowed_groups: admins"}, wantErrs: []string{`"cache_directory"`, `"allowed_groups"`}},
+		"Error on wrong type of list item": {extra: []string{"allowed_groups:", "  - [admins]"}, wantErrs: []string{`"allowed_groups"`}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			configFile := writeConfig(t, t.TempDir(), "adsys.socket", 1, 10, tc.extra...)
+
+			a := daemon.New()
+			a.SetArgs("-c", configFile)
+
+			err := a.Run()
+			require.Error(t, err, "Run should exit with an error")
+			for _, want := range tc.wantErrs {
+				require.ErrorContains(t, err, want, "Error should point at the invalid setting")
+			}
+		})
+	}
+}
+
 func TestConfigChange(t *testing.T) {
 	dir := t.TempDir()
 	configFile := writeConfig(t, dir, "adsys.socket", 1, 10)
@@ -266,9 +294,73 @@ func TestConfigChange(t *testing.T) {
 	require.Contains(t, logs, "changed. Reloading", "Config file has changed")
 }
 
-// writeConfig is a helper to generate a config file for adsysd.
+func TestConfigReloadOnSigHup(t *testing.T) {
+	tests := map[string]struct {
+		verbose int
+		extra   []string
+
+		wantLevel logrus.Level
+		wantLogs  []string
+	}{
+		"Log level is changed":                          {verbose: 2, wantLevel: logrus.DebugLevel},
+		"GPO download concurrency is changed":           {extra: []string{"gpo_download_concurrency: 2"}},
+		"Offline maximum cache age is changed":          {extra: []string{"offline_max_cache_age_days: 7"}},
+		"Live changes are applied along the other ones": {verbose: 2, extra: []string{"ad_backend: winbind"}, wantLevel: logrus.DebugLevel, wantLogs: []string{"ad_backend"}},
+
+		"Error on changing AD backend while running":  {extra: []string{"ad_backend: winbind"}, wantLogs: []string{"ad_backend", "restart adsysd"}},
+		"Error on changing directories while running": {extra: []string{"dconf_dir: /tmp/dconf"}, wantLogs: []string{"dconf_dir", "restart adsysd"}},
+		"Error on invalid GPO download concurrency":   {extra: []string{"gpo_download_concurrency: -1"}, wantLogs: []string{"GPO download concurrency must be at least 1"}},
+		"Error on unknown setting":                    {extra: []string{"gpo_download_concurency: 2"}, wantLogs: []string{`unknown setting "gpo_download_concurency"`}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.wantLevel == 0 {
+				tc.wantLevel = logrus.InfoLevel
+			}
+
+			dir := t.TempDir()
+			configFile := writeConfig(t, dir, "adsys.socket", 1, 10)
+
+			a, wait := startDaemon(t, false, "-c", configFile)
+			defer wait()
+			defer a.Quit()
+
+			require.Equal(t, logrus.InfoLevel, logrus.GetLevel(), "Setup: log level is set from config")
+
+			out := captureLogs(t)
+
+			verbose := tc.verbose
+			if verbose == 0 {
+				verbose = 1
+			}
+			writeConfig(t, dir, "adsys.socket", verbose, 10, tc.extra...)
+
+			// Discard the printed stacktrace.
+			orig := os.Stdout
+			devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			require.NoError(t, err, "Setup: can't open /dev/null")
+			os.Stdout = devNull
+			a.Hup()
+			os.Stdout = orig
+			devNull.Close()
+
+			logs := out()
+			require.Equal(t, tc.wantLevel, logrus.GetLevel(), "Log level should be set from reloaded config")
+			require.Equal(t, verbose, a.Verbosity(), "Verbosity should be set from reloaded config")
+			require.Equal(t, "sssd", a.ADBackend(), "AD backend should only be set when starting")
+			for _, want := range tc.wantLogs {
+				require.Contains(t, logs, want, "Reload should log the rejected changes")
+			}
+			if len(tc.wantLogs) == 0 {
+				require.NotContains(t, logs, "Error while refreshing configuration", "Reload should not log any errors")
+			}
+		})
+	}
+}
+
+// writeConfig is a helper to generate a config file for adsysd, with the extra settings appended.
 // It returns the path to the config file.
-func writeConfig(t *testing.T, dir, socketName string, verbose, serviceTimeout int) string {
+func writeConfig(t *testing.T, dir, socketName string, verbose, serviceTimeout int, extra ...string) string {
 	t.Helper()
 
 	configFile := filepath.Join(dir, "config.yaml")
@@ -290,6 +382,9 @@ sssd:
 		filepath.Join(dir, "cache"),
 		filepath.Join(dir, "run"),
 		serviceTimeout))
+	for _, e := range extra {
+		data = append(data, []byte(e+"\n")...)
+	}
 
 	testutils.WriteFile(t, configFile, data, os.ModePerm)
 	return configFile
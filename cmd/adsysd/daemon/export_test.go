@@ -14,6 +14,14 @@ func (a *App) IsReady(timeout time.Duration) error {
 	return nil
 }
 
-func (a App) Verbosity() int {
+func (a *App) Verbosity() int {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
 	return a.config.Verbose
 }
+
+func (a *App) ADBackend() string {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	return a.config.AdBackend
+}
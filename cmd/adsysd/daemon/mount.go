@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"context"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/ubuntu/adsys/internal/policies/mount"
@@ -13,11 +14,19 @@ func (a *App) installMount() {
 		Short:  "Mount the locations listed in the specified file for the current user",
 		Args:   cobra.ExactArgs(1),
 		Hidden: true,
-		RunE:   func(_ *cobra.Command, args []string) error { return runMounts(args[0]) },
+		RunE:   func(_ *cobra.Command, args []string) error { return a.runMounts(args[0]) },
 	}
 	a.rootCmd.AddCommand(cmd)
 }
 
-func runMounts(filepath string) error {
-	return mount.RunMountForCurrentUser(context.Background(), filepath)
+func (a *App) runMounts(filepath string) error {
+	var opts []mount.Option
+	// A negative number of retries disables them, while 0 keeps the default.
+	if a.config.MountRetries != 0 {
+		opts = append(opts, mount.WithRetries(max(a.config.MountRetries, 0)))
+	}
+	if a.config.MountRetryDelaySeconds > 0 {
+		opts = append(opts, mount.WithRetryDelay(time.Duration(a.config.MountRetryDelaySeconds)*time.Second))
+	}
+	return mount.RunMountForCurrentUser(context.Background(), filepath, opts...)
 }
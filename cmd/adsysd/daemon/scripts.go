@@ -18,14 +18,18 @@ func (a *App) installRunScripts() {
 		Short:  gotext.Get("Runs scripts in the given subdirectory"),
 		Args:   cobra.ExactArgs(1),
 		Hidden: true,
-		RunE:   func(_ *cobra.Command, args []string) error { return runScripts(args[0], *allowOrderMissing) },
+		RunE:   func(_ *cobra.Command, args []string) error { return a.runScripts(args[0], *allowOrderMissing) },
 	}
 	allowOrderMissing = cmd.Flags().BoolP("allow-order-missing", "", false, gotext.Get("allow ORDER_FILE to be missing once the scripts are ready."))
 	a.rootCmd.AddCommand(cmd)
 }
 
-func runScripts(orderFile string, allowOrderMissing bool) error {
-	if err := scripts.RunScripts(context.Background(), orderFile, allowOrderMissing); err != nil {
+func (a *App) runScripts(orderFile string, allowOrderMissing bool) error {
+	var opts []scripts.Option
+	if a.config.StateDir != "" {
+		opts = append(opts, scripts.WithStateDir(a.config.StateDir))
+	}
+	if err := scripts.RunScripts(context.Background(), orderFile, allowOrderMissing, opts...); err != nil {
 		return err
 	}
 
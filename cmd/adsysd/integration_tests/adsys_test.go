@@ -12,7 +12,9 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -229,6 +231,10 @@ func createConf(t *testing.T, opts ...confOption) (conf string) {
 		o(&args)
 	}
 
+	// The tests run as the current user, who needs to be allowed to request privileged operations.
+	g, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	require.NoError(t, err, "Setup: can't get group of current user")
+
 	// Create config
 	confFile := filepath.Join(args.adsysDir, "adsys.yaml")
 	confData := []byte(fmt.Sprintf(`
@@ -258,9 +264,11 @@ apparmor_dir: %[1]s/apparmor.d/adsys
 apparmorfs_dir: %[1]s/apparmorfs
 systemunit_dir: %[1]s/systemd/system
 global_trust_dir: %[1]s/share/ca-certificates
+allowed_groups:
+  - %[4]s
 
 detect_cached_ticket: %[3]t
-`, args.adsysDir, args.backend, args.detectCachedTicket))
+`, args.adsysDir, args.backend, args.detectCachedTicket, g.Name))
 
 	testutils.WriteFile(t, confFile, confData, os.ModePerm)
 	require.NoError(t, os.MkdirAll(filepath.Join(args.adsysDir, "dconf"), 0750), "Setup: should create dconf dir")
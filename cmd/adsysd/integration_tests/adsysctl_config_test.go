@@ -0,0 +1,60 @@
+package adsys_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		extra   string
+		example bool
+		noFile  bool
+
+		wantErrs []string
+	}{
+		"Valid configuration":                      {},
+		"Valid configuration with client settings": {extra: "client_timeout: 60\n"},
+		"Example configuration is valid":           {example: true},
+
+		// Error cases
+		"Error on unknown setting":               {extra: "cache_directory: /tmp/cache\n", wantErrs: []string{`unknown setting "cache_directory"`}},
+		"Error on unknown nested setting":        {extra: "winbind:\n  addomain: example.com\n", wantErrs: []string{`unknown setting "winbind.addomain"`}},
+		"Error on wrong type of setting":         {extra: "client_timeout: one minute\n", wantErrs: []string{`setting "client_timeout" expects a value of type int, got one minute`}},
+		"Error on wrong type of nested setting":  {extra: "winbind: example.com\n", wantErrs: []string{`setting "winbind" expects a map of settings`}},
+		"Error on multiple invalid settings":     {extra: "cache_directory: /tmp/cache\nallowed_groups: admins\n", wantErrs: []string{`"cache_directory"`, `"allowed_groups"`}},
+		"Error on missing file":                  {noFile: true, wantErrs: []string{"no such file or directory"}},
+		"Error on invalid configuration content": {extra: "socket: [\n", wantErrs: []string{"invalid configuration file"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			conf := createConf(t)
+
+			file := filepath.Join(t.TempDir(), "adsys.yaml")
+			switch {
+			case tc.example:
+				file = filepath.Join(rootProjectDir, "conf.example", "adsys.yaml")
+			case !tc.noFile:
+				data, err := os.ReadFile(conf)
+				require.NoError(t, err, "Setup: can't read configuration file")
+				testutils.WriteFile(t, file, append(data, []byte(tc.extra)...), os.ModePerm)
+			}
+
+			// The daemon is not needed to validate a configuration file.
+			out, err := runClient(t, conf, "config", "validate", file)
+			if tc.wantErrs != nil {
+				require.Error(t, err, "client should exit with an error")
+				for _, want := range tc.wantErrs {
+					require.ErrorContains(t, err, want, "Error should point at the invalid setting")
+				}
+				return
+			}
+			require.NoError(t, err, "client should exit with no error")
+			require.Contains(t, out, "is valid", "client should report the configuration file as valid")
+		})
+	}
+}
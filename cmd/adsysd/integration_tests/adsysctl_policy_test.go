@@ -3,18 +3,25 @@ package adsys_test
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
+	"github.com/ubuntu/adsys"
+	"github.com/ubuntu/adsys/internal/adsysservice"
 	"github.com/ubuntu/adsys/internal/consts"
 	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/testutils"
@@ -77,6 +84,58 @@ func TestPolicyAdmx(t *testing.T) {
 	}
 }
 
+func TestPolicyKeys(t *testing.T) {
+	tests := map[string]struct {
+		manager string
+		format  string
+
+		wantKeys    []string
+		wantNotKeys []string
+		wantErr     bool
+	}{
+		"List keys of all managers": {wantKeys: []string{"/org/gnome/desktop/interface/clock-format", "/client-admins", "/system-mounts"}},
+		"List keys of dconf manager": {manager: "dconf",
+			wantKeys:    []string{"/org/gnome/desktop/interface/clock-format", "/org/gnome/shell/favorite-apps"},
+			wantNotKeys: []string{"/client-admins"}},
+		"List keys of privilege manager": {manager: "privilege",
+			wantKeys:    []string{"/client-admins", "/allow-local-admins"},
+			wantNotKeys: []string{"/org/gnome/desktop/interface/clock-format"}},
+		"List keys in JSON":                      {manager: "privilege", format: "json", wantKeys: []string{`"key": "/client-admins"`, `"lockable": false`}},
+		"Manager without policy keys lists none": {manager: "certificate", wantKeys: []string{"No policy keys defined"}},
+
+		// Error cases
+		"Error on unknown manager": {manager: "doesnotexist", wantErr: true},
+		"Error on unknown format":  {format: "xml", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			conf := createConf(t)
+
+			// The daemon is not needed to list the keys compiled into the binary.
+			args := []string{"policy", "keys"}
+			if tc.manager != "" {
+				args = append(args, "--manager", tc.manager)
+			}
+			if tc.format != "" {
+				args = append(args, "--format", tc.format)
+			}
+			out, err := runClient(t, conf, args...)
+			if tc.wantErr {
+				require.Error(t, err, "client should exit with an error")
+				return
+			}
+			require.NoError(t, err, "client should exit with no error")
+
+			for _, want := range tc.wantKeys {
+				require.Contains(t, out, want, "client should list the supported keys")
+			}
+			for _, notWant := range tc.wantNotKeys {
+				require.NotContains(t, out, notWant, "client should only list the keys of the requested manager")
+			}
+		})
+	}
+}
+
 func TestPolicyApplied(t *testing.T) {
 	currentUser := "adsystestuser@example.com"
 
@@ -118,6 +177,7 @@ func TestPolicyApplied(t *testing.T) {
 		"Error on unexisting user":                                  {args: []string{"doesnotexists@example.com"}, wantErr: true},
 		"Error on user name without domain and no default domain":   {args: []string{"doesnotexists"}, wantErr: true},
 		"Error on applied denied":                                   {systemAnswer: "polkit_no", wantErr: true},
+		"Error on unsupported output format":                        {args: []string{"--format", "yaml"}, wantErr: true},
 		"Error on daemon not responding":                            {daemonNotStarted: true, wantErr: true},
 	}
 	for name, tc := range tests {
@@ -1198,8 +1258,177 @@ func TestPolicyUpdate(t *testing.T) {
 					"Setup: can't rename current user directory to generic CURRENT_UID")
 			}
 
-			testutils.CompareTreesWithFiltering(t, filepath.Join(adsysDir, "run", "users"), filepath.Join(goldenPath, "run", "users"), update)
-			testutils.CompareTreesWithFiltering(t, filepath.Join(adsysDir, "run", "machine"), filepath.Join(goldenPath, "run", "machine"), update)
+			// The scripts environment contains the machine hostname. Its content is covered by the scripts manager tests.
+			ignoreScriptsEnv := testutils.WithIgnoredFiles(testutils.IgnoreDconfDB, func(p string, _ []byte) bool {
+				return filepath.Base(p) == ".environment"
+			})
+			testutils.CompareTreesWithFiltering(t, filepath.Join(adsysDir, "run", "users"), filepath.Join(goldenPath, "run", "users"), update, ignoreScriptsEnv)
+			testutils.CompareTreesWithFiltering(t, filepath.Join(adsysDir, "run", "machine"), filepath.Join(goldenPath, "run", "machine"), update, ignoreScriptsEnv)
+		})
+	}
+}
+
+func TestPolicyUpdateProgress(t *testing.T) {
+	currentUser := "adsystestuser@example.com"
+
+	// We setup and rerun in a subprocess because the test users must exist on the machine for the authorizer.
+	if setupSubprocessForTest(t, currentUser) {
+		return
+	}
+
+	t.Setenv("ADSYS_TESTS_MOCK_SMBDOMAIN", "example.com")
+	t.Setenv("ADSYS_SKIP_ROOT_CALLS", "TRUE")
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get current host")
+
+	tests := map[string]struct {
+		systemAnswer string
+
+		wantErr bool
+	}{
+		"Progress of machine update ends with its completion": {},
+
+		// Error cases
+		"Error on Polkit denying update ends the progress with the error": {systemAnswer: "polkit_no", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.systemAnswer == "" {
+				tc.systemAnswer = "polkit_yes"
+			}
+			dbusAnswer(t, tc.systemAnswer)
+
+			adsysDir := t.TempDir()
+			machineCCache := filepath.Join(adsysDir, "sss_cache", "ccache_EXAMPLE.COM")
+			testutils.WriteFile(t, machineCCache, []byte("Some data for the mock"), 0600)
+
+			conf := createConf(t, confWithAdsysDir(adsysDir))
+			defer runDaemon(t, conf)()
+
+			client, err := adsysservice.NewClient(filepath.Join(adsysDir, "socket"), time.Minute)
+			require.NoError(t, err, "Setup: can't create client")
+			defer client.Close()
+
+			stream, err := client.UpdatePolicyWithProgress(context.Background(), &adsys.UpdatePolicyRequest{IsComputer: true})
+			require.NoError(t, err, "UpdatePolicyWithProgress should start streaming")
+
+			var progress []*adsys.UpdatePolicyProgress
+			for {
+				p, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					require.True(t, tc.wantErr, "Stream should not end with an error but got: %v", err)
+					break
+				}
+				progress = append(progress, p)
+			}
+			require.NotEmpty(t, progress, "Progress should be streamed")
+
+			// The stream ends with the completion of the update.
+			last := progress[len(progress)-1]
+			require.True(t, last.GetDone(), "Last message should mark the update as done")
+			if tc.wantErr {
+				require.NotEmpty(t, last.GetError(), "Last message should report the error of the update")
+				return
+			}
+			require.Empty(t, last.GetError(), "Last message should not report any error")
+			require.Equal(t, int32(100), last.GetPercentage(), "Last message should report a complete update")
+
+			var stages, managers []string
+			var percentage int32
+			for _, p := range progress[:len(progress)-1] {
+				require.False(t, p.GetDone(), "Only the last message should mark the update as done")
+				// Changes printed in dry-run mode are not part of the progress.
+				if p.GetStage() == "" {
+					continue
+				}
+				require.GreaterOrEqual(t, p.GetPercentage(), percentage, "Percentage should never decrease")
+				percentage = p.GetPercentage()
+				if len(stages) == 0 || stages[len(stages)-1] != p.GetStage() {
+					stages = append(stages, p.GetStage())
+				}
+				if p.GetStage() != policies.StageFlush {
+					require.Equal(t, hostname, p.GetTarget(), "Progress should report the updated object")
+				}
+				if p.GetManager() != "" {
+					managers = append(managers, p.GetManager())
+				}
+			}
+			require.Equal(t, []string{policies.StageFetch, policies.StageApply, policies.StageFlush}, stages, "Progress should report the stages in order")
+			require.ElementsMatch(t, policies.Managers, managers, "Progress should report each policy manager once")
+			require.Equal(t, "gdm", managers[len(managers)-1], "Progress should report the gdm policy manager last, as it runs after dconf")
+		})
+	}
+}
+
+func TestPolicyCachePurge(t *testing.T) {
+	currentUser := "adsystestuser@example.com"
+
+	// We setup and rerun in a subprocess because the test users must exist on the machine for the authorizer.
+	if setupSubprocessForTest(t, currentUser, "userintegrationtest@example.com") {
+		return
+	}
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get current hostname")
+
+	tests := map[string]struct {
+		args             []string
+		systemAnswer     string
+		daemonNotStarted bool
+
+		wantPurged []string
+		wantErr    bool
+	}{
+		"Purge user cache":                 {args: []string{"--user", "userintegrationtest@example.com"}, wantPurged: []string{"userintegrationtest@example.com"}},
+		"Purge user cache with mixed case": {args: []string{"--user", "UserIntegrationTest@example.com"}, wantPurged: []string{"userintegrationtest@example.com"}},
+		"Purge machine cache":              {args: []string{"--machine"}, wantPurged: []string{hostname}},
+		"Purge all caches":                 {args: []string{"--all"}, wantPurged: []string{hostname, currentUser, "userintegrationtest@example.com"}},
+
+		// Error cases
+		"Error on user without cache":      {args: []string{"--user", "doesnotexists@example.com"}, wantErr: true},
+		"Error on missing object to purge": {wantErr: true},
+		"Error on user and all together":   {args: []string{"--user", currentUser, "--all"}, wantErr: true},
+		"Error on purge denied":            {args: []string{"--all"}, systemAnswer: "polkit_no", wantErr: true},
+		"Error on daemon not responding":   {args: []string{"--all"}, daemonNotStarted: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.systemAnswer == "" {
+				tc.systemAnswer = "polkit_yes"
+			}
+			dbusAnswer(t, tc.systemAnswer)
+
+			dir := t.TempDir()
+			cached := []string{hostname, currentUser, "userintegrationtest@example.com"}
+			for _, objectName := range cached {
+				testutils.CreatePath(t, filepath.Join(dir, "cache", "policies", objectName, "policies"))
+			}
+			conf := createConf(t, confWithAdsysDir(dir))
+
+			if !tc.daemonNotStarted {
+				defer runDaemon(t, conf)()
+			}
+
+			args := append([]string{"policy", "cache", "purge"}, tc.args...)
+			_, err := runClient(t, conf, args...)
+			if tc.wantErr {
+				require.Error(t, err, "client should exit with an error")
+				return
+			}
+			require.NoError(t, err, "client should exit with no error")
+
+			for _, objectName := range cached {
+				p := filepath.Join(dir, "cache", "policies", objectName)
+				if slices.Contains(tc.wantPurged, objectName) {
+					require.NoDirExists(t, p, "Policies cache of %q should be purged", objectName)
+					continue
+				}
+				require.DirExists(t, p, "Policies cache of %q should be kept", objectName)
+			}
 		})
 	}
 }
@@ -1341,6 +1570,9 @@ func TestPolicyCompletion(t *testing.T) {
 		"Dump policy definitions specifies available types":   {args: "admx", wantOut: "lts-only all"},
 		"Dump policy definitions with type already filled in": {args: "admx lts-only"},
 
+		"Keys manager option specifies available managers": {args: "keys --manager", wantOut: "dconf privilege scripts mount apparmor proxy certificate hosts timesync gdm"},
+		"Keys doesn't take arguments":                      {args: "keys"},
+
 		"Applied returns list of available users":            {args: "applied", wantOut: "adsystestuser@example.com otheruser@example.com"},
 		"Applied with user arg doesn't return anything":      {args: "applied someuser"},
 		"Applied with RO ccache dir doesn't return anything": {args: "applied", krb5DirNotAccessible: true},
@@ -273,16 +273,19 @@ func TestServiceStatus(t *testing.T) {
 		daemonNotStarted    bool
 		noCacheUsersMachine bool
 		krb5ccNoCache       bool
+		waitReady           bool
 
 		wantErr bool
 	}{
-		"Status with users and machines":          {systemAnswer: "polkit_yes"},
-		"Status offline cache":                    {sssdConf: "sssd.conf-offline", systemAnswer: "polkit_yes"},
-		"Status no user connected and no machine": {noCacheUsersMachine: true, systemAnswer: "polkit_yes"},
-		"Status is always authorized":             {systemAnswer: "polkit_no"},
-		"Status on user connected with no cache":  {krb5ccNoCache: true, systemAnswer: "polkit_yes"},
-		"Status with static AD server":            {sssdConf: "sssd.conf-example.com_static-server", systemAnswer: "polkit_yes"},
-		"Status with empty dynamic AD server":     {sssdConf: "sssd.conf-online_no_active_server", systemAnswer: "polkit_yes"},
+		"Status with users and machines":           {systemAnswer: "polkit_yes"},
+		"Status waits for the daemon to be ready":  {waitReady: true, systemAnswer: "polkit_yes"},
+		"Status waits for the daemon when offline": {waitReady: true, sssdConf: "sssd.conf-offline", systemAnswer: "polkit_yes"},
+		"Status offline cache":                     {sssdConf: "sssd.conf-offline", systemAnswer: "polkit_yes"},
+		"Status no user connected and no machine":  {noCacheUsersMachine: true, systemAnswer: "polkit_yes"},
+		"Status is always authorized":              {systemAnswer: "polkit_no"},
+		"Status on user connected with no cache":   {krb5ccNoCache: true, systemAnswer: "polkit_yes"},
+		"Status with static AD server":             {sssdConf: "sssd.conf-example.com_static-server", systemAnswer: "polkit_yes"},
+		"Status with empty dynamic AD server":      {sssdConf: "sssd.conf-online_no_active_server", systemAnswer: "polkit_yes"},
 
 		// Refresh time exception
 		"No startup time leads to unknown refresh time":           {systemAnswer: "no_startup_time"},
@@ -294,7 +297,8 @@ func TestServiceStatus(t *testing.T) {
 		"Ubuntu Pro subscription is not active": {systemAnswer: "subscription_disabled"},
 
 		// Error cases
-		"Error on daemon not responding": {daemonNotStarted: true, wantErr: true},
+		"Error on daemon not responding":              {daemonNotStarted: true, wantErr: true},
+		"Error on daemon not responding when waiting": {daemonNotStarted: true, waitReady: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -349,7 +353,11 @@ func TestServiceStatus(t *testing.T) {
 				require.NoError(t, err, "Setup: can’t delete gpo rules cache directory")
 			}
 
-			got, err := runClient(t, conf, "service", "status")
+			args := []string{"service", "status"}
+			if tc.waitReady {
+				args = append(args, "--wait-ready")
+			}
+			got, err := runClient(t, conf, args...)
 			if tc.wantErr {
 				require.Error(t, err, "client should exit with an error")
 				return
@@ -153,6 +153,7 @@ func New(opts ...option) *App {
 				context.Background(),
 				watchdservice.WithName(a.options.name),
 				watchdservice.WithDirs(a.config.Dirs),
+				watchdservice.WithDryRun(a.config.DryRun),
 				watchdservice.WithConfig(configFile))
 
 			if err != nil {
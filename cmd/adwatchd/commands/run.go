@@ -9,6 +9,7 @@ import (
 	"github.com/leonelquinteros/gotext"
 	"github.com/spf13/cobra"
 	"github.com/ubuntu/adsys/internal/cmdhandler"
+	"github.com/ubuntu/adsys/internal/config"
 	watchdconfig "github.com/ubuntu/adsys/internal/config/watchd"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/decorate"
@@ -22,6 +23,7 @@ func (a *App) installRun() {
 
 The program will monitor the configured directories for changes and bump the appropriate GPT.ini versions anytime a change is detected.
 If a GPT.ini file does not exist for a directory, a warning will be issued and the file will be created. If the GPT.ini file is incompatible or malformed, the program will report an error.
+With --dry-run, the detected changes and the version bumps they would trigger are only logged, without modifying any GPT.ini file.
 `),
 		Args: cobra.NoArgs,
 		RunE: func(_ *cobra.Command, _ []string) error {
@@ -41,6 +43,11 @@ If a GPT.ini file does not exist for a directory, a warning will be issued and t
 				}
 			}
 
+			// Dry run reports what would be done at the info level.
+			if a.config.DryRun && a.config.Verbose < 1 {
+				config.SetVerboseMode(1)
+			}
+
 			return a.service.Run(context.Background())
 		},
 	}
@@ -62,6 +69,12 @@ If a GPT.ini file does not exist for a directory, a warning will be issued and t
 		gotext.Get("force the program to run even if another instance is already running"),
 	)
 	decorate.LogOnError(a.viper.BindPFlag("force", cmd.Flags().Lookup("force")))
+	cmd.Flags().Bool(
+		"dry-run",
+		false,
+		gotext.Get("only log the detected changes and the version bumps they would trigger, without modifying any GPT.ini file"),
+	)
+	decorate.LogOnError(a.viper.BindPFlag("dry-run", cmd.Flags().Lookup("dry-run")))
 	cmdhandler.InstallConfigFlag(cmd, false)
 
 	a.rootCmd.AddCommand(cmd)
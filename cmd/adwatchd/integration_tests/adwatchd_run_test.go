@@ -2,6 +2,8 @@ package adwatchd_test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"syscall"
 	"testing"
@@ -65,6 +67,42 @@ func TestRunWithNoDirs(t *testing.T) {
 	require.ErrorContains(t, err, "needs at least one directory", "Run with no dirs should fail")
 }
 
+func TestRunWithDryRunDoesNotBumpVersions(t *testing.T) {
+	watchDir := t.TempDir()
+	configPath := generateConfig(t, -1, watchDir)
+
+	app := commands.New()
+
+	changeAppArgs(t, app, configPath, "run", "--force", "--dry-run")
+	done := make(chan struct{})
+	var err, appErr error
+	go func() {
+		defer close(done)
+		appErr = app.Run()
+	}()
+	app.WaitReady()
+
+	// Give time for the watcher to start
+	time.Sleep(time.Millisecond * 100)
+
+	testutils.WriteFile(t, filepath.Join(watchDir, "new"), []byte("new content"), os.ModePerm)
+	testutils.WaitForWrites(t)
+
+	err = app.Quit(syscall.SIGTERM)
+	require.NoError(t, err, "Quitting should succeed")
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		// FIXME: fix quitting on windows
+		if runtime.GOOS != "windows" {
+			t.Fatal("run hasn't exited quickly enough")
+		}
+	}
+	require.NoError(t, appErr, "App should exit without error")
+
+	require.NoFileExists(t, filepath.Join(watchDir, "GPT.INI"), "GPT.ini should not be created in dry run mode")
+}
+
 func TestRunReactsToConfigUpdates(t *testing.T) {
 	var err, appErr error
 	watchDir := t.TempDir()
@@ -11,18 +11,22 @@ import (
 	_ "embed" // embed gpolist python binary.
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys/internal/ad/backends"
 	adcommon "github.com/ubuntu/adsys/internal/ad/common"
+	"github.com/ubuntu/adsys/internal/ad/filter"
 	"github.com/ubuntu/adsys/internal/ad/registry"
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
@@ -50,6 +54,18 @@ const (
 	policyServersPrefix string = "Software/Policies/Microsoft/Cryptography/PolicyServers/"
 )
 
+// LoopbackMode is how the user policies of the GPOs linked to the machine apply to users.
+type LoopbackMode string
+
+const (
+	// LoopbackDisabled only applies to users the GPOs linked to them.
+	LoopbackDisabled LoopbackMode = ""
+	// LoopbackMerge applies to users the GPOs linked to the machine, then the ones linked to them.
+	LoopbackMerge LoopbackMode = "merge"
+	// LoopbackReplace only applies to users the GPOs linked to the machine.
+	LoopbackReplace LoopbackMode = "replace"
+)
+
 type gpo downloadable
 
 type downloadable struct {
@@ -57,6 +73,8 @@ type downloadable struct {
 	url      string
 	mu       *sync.RWMutex
 	isAssets bool
+	// enforced is set for GPOs linked with enforcement.
+	enforced bool
 
 	// This property is used to instrument the tests for concurrent download and parsing of GPOs
 	// Cf internal_test::TestFetchOneGPOWhileParsingItConcurrently()
@@ -67,6 +85,8 @@ type downloadable struct {
 type AD struct {
 	hostname      string
 	configBackend backends.Backend
+	// localBackend is set when GPOs are read from a local directory instead of the domain controller.
+	localBackend backends.LocalBackend
 
 	versionID        string
 	sysvolCacheDir   string
@@ -80,6 +100,34 @@ type AD struct {
 	withoutKerberos bool
 	gpoListCmd      []string
 	gpoListTimeout  time.Duration
+	gpoLinkOrder    bool
+	loopbackMode    LoopbackMode
+
+	// gpoDownloadConcurrency and maxCacheAge can be changed while running, with SetGPODownloadConcurrency and
+	// SetMaxCacheAge.
+	gpoDownloadConcurrency atomic.Int64
+	downloadHook           func()
+
+	maxCacheAge atomic.Int64
+
+	preferredServer string
+	site            string
+	checkServer     func(ctx context.Context, server string) bool
+	lookupSRV       func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	// smbClientHome is the home directory libsmbclient loads its configuration from, if not empty.
+	smbClientHome string
+
+	kinitCmd               []string
+	ticketRenewalThreshold time.Duration
+
+	machineFacts filter.Facts
+
+	// dcUnreachable is set while policies are applied from cache because the domain controller can't be reached.
+	dcUnreachable atomic.Bool
+
+	// refreshOnNext are the objects whose GPOs are all downloaded again on their next update.
+	refreshOnNext map[string]bool
 }
 
 type options struct {
@@ -90,9 +138,29 @@ type options struct {
 	withoutKerberos bool
 	gpoListCmd      []string
 	gpoListTimeout  time.Duration
+	gpoLinkOrder    bool
+	loopbackMode    LoopbackMode
+
+	gpoDownloadConcurrency int
+	downloadHook           func()
+
+	maxCacheAge time.Duration
+
+	preferredServer string
+	site            string
+	checkServer     func(ctx context.Context, server string) bool
+	lookupSRV       func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+	smbSigningRequired    bool
+	smbEncryptionRequired bool
+
+	kinitCmd               []string
+	ticketRenewalThreshold time.Duration
+
+	machineFacts filter.Facts
 }
 
-// Option reprents an optional function to change AD behavior.
+// Option represents an optional function to change AD behavior.
 type Option func(*options) error
 
 // WithCacheDir specifies a personalized daemon cache directory.
@@ -119,6 +187,100 @@ func WithGpoListTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithGPOLinkOrder orders GPOs by their link only, ignoring enforced links and blocked inheritance.
+func WithGPOLinkOrder(linkOrder bool) Option {
+	return func(o *options) error {
+		o.gpoLinkOrder = linkOrder
+		return nil
+	}
+}
+
+// WithLoopbackProcessing applies the user policies of the GPOs linked to the machine to all users, with a higher
+// precedence than the ones of their own GPOs in merge mode, or instead of them in replace mode.
+func WithLoopbackProcessing(mode LoopbackMode) Option {
+	return func(o *options) error {
+		switch mode {
+		case LoopbackDisabled, LoopbackMerge, LoopbackReplace:
+		default:
+			return errors.New(gotext.Get("loopback processing mode must be %q or %q, got %q", LoopbackMerge, LoopbackReplace, mode))
+		}
+		o.loopbackMode = mode
+		return nil
+	}
+}
+
+// WithGPODownloadConcurrency specifies how many GPOs are downloaded simultaneously from SYSVOL.
+func WithGPODownloadConcurrency(n int) Option {
+	return func(o *options) error {
+		if n < 1 {
+			return errors.New(gotext.Get("GPO download concurrency must be at least 1, got %d", n))
+		}
+		o.gpoDownloadConcurrency = n
+		return nil
+	}
+}
+
+// WithMaxCacheAge refuses to apply cached policies older than maxAge when AD can't be reached.
+// A zero maxAge always applies cached policies.
+func WithMaxCacheAge(maxAge time.Duration) Option {
+	return func(o *options) error {
+		if maxAge < 0 {
+			return errors.New(gotext.Get("maximum cache age must be positive, got %s", maxAge))
+		}
+		o.maxCacheAge = maxAge
+		return nil
+	}
+}
+
+// WithSMBSigning requires the SYSVOL connections to domain controllers to be signed, if required is set. Signing is
+// required by default.
+func WithSMBSigning(required bool) Option {
+	return func(o *options) error {
+		o.smbSigningRequired = required
+		return nil
+	}
+}
+
+// WithSMBEncryption requires the SYSVOL connections to domain controllers to be signed and encrypted, if required is
+// set.
+func WithSMBEncryption(required bool) Option {
+	return func(o *options) error {
+		o.smbEncryptionRequired = required
+		return nil
+	}
+}
+
+// WithPreferredServer fetches GPOs from server if it is reachable, instead of the one discovered by the backend.
+func WithPreferredServer(server string) Option {
+	return func(o *options) error {
+		o.preferredServer = strings.TrimPrefix(server, "ldap://")
+		return nil
+	}
+}
+
+// WithSite fetches GPOs from the first reachable domain controller of the AD site, in the order advertised by DNS,
+// instead of the one discovered by the backend.
+func WithSite(site string) Option {
+	return func(o *options) error {
+		o.site = site
+		return nil
+	}
+}
+
+// WithTicketRenewalThreshold renews the machine ticket when it expires within threshold, before fetching GPOs.
+func WithTicketRenewalThreshold(threshold time.Duration) Option {
+	return func(o *options) error {
+		if threshold < 0 {
+			return errors.New(gotext.Get("ticket renewal threshold must be positive, got %s", threshold))
+		}
+		o.ticketRenewalThreshold = threshold
+		return nil
+	}
+}
+
+// gpoListConnectionFailed is the exit code of the GPO list script when it can't connect to the domain controller.
+const gpoListConnectionFailed = 2
+
 // AdsysGpoListCode is the embedded script which request
 // Samba to get our GPO list for the given object.
 //
@@ -141,6 +303,12 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 		gpoListCmd:     []string{"python3", "-c", AdsysGpoListCode},
 		versionID:      versionID,
 		gpoListTimeout: 30 * time.Second, // this is used in tests and set to consts.DefaultGpoListTimeout in production
+
+		gpoDownloadConcurrency: consts.DefaultGpoDownloadConcurrency,
+		lookupSRV:              net.DefaultResolver.LookupSRV,
+		smbSigningRequired:     true,
+		kinitCmd:               []string{"kinit"},
+		ticketRenewalThreshold: defaultTicketRenewalThreshold,
 	}
 	// applied options
 	for _, o := range opts {
@@ -149,6 +317,12 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 		}
 	}
 
+	if args.machineFacts == nil {
+		if args.machineFacts, err = filter.GetFacts("/", hostname); err != nil {
+			return nil, err
+		}
+	}
+
 	krb5CacheDir := filepath.Join(args.runDir, "krb5cc")
 	if err := os.MkdirAll(filepath.Join(krb5CacheDir, "tracking"), 0700); err != nil {
 		return nil, err
@@ -170,7 +344,7 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 	}
 	log.Debugf(ctx, "Backend is SSSD. AD domain: %q, server from configuration: %q", domain, serverFQDN)
 
-	return &AD{
+	ad = &AD{
 		hostname:         hostname,
 		configBackend:    configBackend,
 		versionID:        args.versionID,
@@ -181,7 +355,59 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 		downloadables:  make(map[string]*downloadable),
 		gpoListCmd:     args.gpoListCmd,
 		gpoListTimeout: args.gpoListTimeout,
-	}, nil
+		gpoLinkOrder:   args.gpoLinkOrder,
+		loopbackMode:   args.loopbackMode,
+
+		downloadHook: args.downloadHook,
+
+		preferredServer: args.preferredServer,
+		site:            args.site,
+		checkServer:     args.checkServer,
+		lookupSRV:       args.lookupSRV,
+
+		kinitCmd:               args.kinitCmd,
+		ticketRenewalThreshold: args.ticketRenewalThreshold,
+
+		machineFacts: args.machineFacts,
+
+		refreshOnNext: make(map[string]bool),
+	}
+	if localBackend, ok := configBackend.(backends.LocalBackend); ok {
+		log.Debugf(ctx, "GPOs are read from local directory %s", localBackend.SysvolDir())
+		ad.localBackend = localBackend
+	}
+	// Anonymous sessions, used in tests, can't be signed.
+	if !args.withoutKerberos && (args.smbSigningRequired || args.smbEncryptionRequired) {
+		ad.smbClientHome = filepath.Join(args.runDir, "smbclient")
+		if err := writeSMBClientConf(ad.smbClientHome, defaultSambaConf, args.smbEncryptionRequired); err != nil {
+			return nil, err
+		}
+	}
+	ad.gpoDownloadConcurrency.Store(int64(args.gpoDownloadConcurrency))
+	ad.maxCacheAge.Store(int64(args.maxCacheAge))
+	return ad, nil
+}
+
+// SetGPODownloadConcurrency changes how many GPOs are downloaded simultaneously from SYSVOL.
+// Downloads in progress complete with the previous concurrency.
+func (ad *AD) SetGPODownloadConcurrency(n int) error {
+	var o options
+	if err := WithGPODownloadConcurrency(n)(&o); err != nil {
+		return err
+	}
+	ad.gpoDownloadConcurrency.Store(int64(o.gpoDownloadConcurrency))
+	return nil
+}
+
+// SetMaxCacheAge changes the maximum age of the cached policies applied when AD can't be reached.
+// A zero maxAge always applies cached policies.
+func (ad *AD) SetMaxCacheAge(maxAge time.Duration) error {
+	var o options
+	if err := WithMaxCacheAge(maxAge)(&o); err != nil {
+		return err
+	}
+	ad.maxCacheAge.Store(int64(o.maxCacheAge))
+	return nil
 }
 
 // GetPolicies returns all policy entries, stacked in order of priority.GetPolicies
@@ -189,126 +415,291 @@ func New(ctx context.Context, configBackend backends.Backend, hostname string, o
 // It uses the given krb5 ticket reference to authenticate to AD.
 // userKrb5CCName has no impact for computer object and is ignored. If empty, we will expect to find one cached
 // ticket <krb5CCDir>/<objectName>.
+// If forceRefresh is true, all GPOs are downloaded again, even if their cached version is up to date.
 // The GPOs are returned from the highest priority in the hierarchy, with enforcement in reverse order
-// to the lowest priority.
-func (ad *AD) GetPolicies(ctx context.Context, objectName string, objectClass ObjectClass, userKrb5CCName string) (pols policies.Policies, err error) {
+// to the lowest priority, unless GPOs are ordered by their link only.
+func (ad *AD) GetPolicies(ctx context.Context, objectName string, objectClass ObjectClass, userKrb5CCName string, forceRefresh bool) (pols policies.Policies, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't get policies for %q", objectName))
 
-	log.Debugf(ctx, "GetPolicies for %q, type %q", objectName, objectClass)
+	l, err := ad.resolveGPOs(ctx, objectName, objectClass, userKrb5CCName, forceRefresh)
+	if err != nil {
+		return pols, err
+	}
+	if l.offlineReason != "" {
+		return ad.cachedPolicies(ctx, objectName, l.offlineReason)
+	}
 
-	if objectClass == UserObject && !strings.Contains(objectName, "@") {
-		return pols, errors.New(gotext.Get("user name %q should be of the form %s@DOMAIN", objectName, objectName))
+	ad.Lock()
+	defer ad.Unlock()
+	pols, err = ad.fetchAndParse(ctx, l, l.downloadables)
+	if errors.Is(err, errSysvolUnreachable) {
+		log.Debugf(ctx, "Can't download GPOs: %v", err)
+		return ad.cachedPolicies(ctx, objectName, gotext.Get("SYSVOL of %s is unreachable", l.adServerFQDN))
 	}
+	return pols, err
+}
 
-	if objectClass == ComputerObject && objectName != ad.hostname {
-		return pols, errors.New(gotext.Get("requested a type computer of %q which isn't current host %q", objectName, ad.hostname))
+// UserPolicies are the policies of a user returned by GetUsersPolicies, or the error which prevented getting them.
+type UserPolicies struct {
+	User     string
+	Policies policies.Policies
+	Err      error
+}
+
+// GetUsersPolicies returns the policies of users, as GetPolicies does for each of them with their cached ticket,
+// in one pass: GPOs and assets shared by multiple users are only checked and downloaded once from SYSVOL.
+// Each user gets its own result, in the users order: failing to get the policies of a user doesn't prevent getting
+// the ones of the others.
+func (ad *AD) GetUsersPolicies(ctx context.Context, users []string, forceRefresh bool) []UserPolicies {
+	log.Debugf(ctx, "GetUsersPolicies for %v", users)
+
+	results := make([]UserPolicies, len(users))
+	lists := make([]gpoList, len(users))
+	// The GPO lists of each user are independent queries to AD.
+	var wg sync.WaitGroup
+	for i, user := range users {
+		results[i].User = user
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lists[i], results[i].Err = ad.resolveGPOs(ctx, user, UserObject, "", forceRefresh)
+		}()
 	}
+	wg.Wait()
 
-	krb5CCPath := filepath.Join(ad.krb5CacheDir, objectName)
-	krb5CCSymlink := filepath.Join(ad.krb5CacheDir, "tracking", objectName)
-	// Create a ccache symlink on first fetch for future calls (on refresh for instance)
-	if userKrb5CCName != "" || objectClass == ComputerObject {
-		src := userKrb5CCName
-		// there is no env var for machine: get sss ccache
-		if objectClass == ComputerObject {
-			src, err = ad.configBackend.HostKrb5CCName()
-			if err != nil {
+	ad.Lock()
+	defer ad.Unlock()
+
+	// fetched are the downloadables already checked against SYSVOL during this pass, and if they were downloaded
+	// again regardless of their version.
+	fetched := make(map[string]bool)
+	for i, l := range lists {
+		results[i].Policies, results[i].Err = func() (pols policies.Policies, err error) {
+			defer decorate.OnError(&err, gotext.Get("can't get policies for %q", l.objectName))
+
+			if results[i].Err != nil {
+				return pols, results[i].Err
+			}
+			if l.offlineReason != "" {
+				return ad.cachedPolicies(ctx, l.objectName, l.offlineReason)
+			}
+
+			force := l.forceRefresh || ad.refreshOnNext[l.objectName]
+			toFetch := make(map[string]string)
+			for name, url := range l.downloadables {
+				if forced, ok := fetched[name]; ok && (forced || !force) {
+					continue
+				}
+				toFetch[name] = url
+			}
+			log.Debugf(ctx, "%d GPOs and assets of %q were already fetched in this pass", len(l.downloadables)-len(toFetch), l.objectName)
+
+			pols, err = ad.fetchAndParse(ctx, l, toFetch)
+			if errors.Is(err, errSysvolUnreachable) {
+				log.Debugf(ctx, "Can't download GPOs: %v", err)
+				return ad.cachedPolicies(ctx, l.objectName, gotext.Get("SYSVOL of %s is unreachable", l.adServerFQDN))
+			} else if err != nil {
 				return pols, err
 			}
-		}
+			for name := range toFetch {
+				fetched[name] = fetched[name] || force
+			}
+			return pols, nil
+		}()
+	}
 
-		// Create a symlink to the ccache file
-		if err := ad.ensureKrb5CCSymlink(src, krb5CCSymlink); err != nil {
-			return pols, err
-		}
+	return results
+}
+
+// gpoList is the list of GPOs applying to an object, resolved from AD, and what needs to be fetched to parse them.
+type gpoList struct {
+	objectName   string
+	objectClass  ObjectClass
+	krb5CCPath   string
+	adServerFQDN string
+	forceRefresh bool
+
+	gpos []gpo
+	// downloadables are the GPOs and assets URLs, by name.
+	downloadables map[string]string
+
+	// offlineReason is set when AD can't be reached: cached policies are returned instead.
+	offlineReason string
+}
+
+// resolveGPOs lists the GPOs applying to objectName from AD, as GetPolicies does.
+func (ad *AD) resolveGPOs(ctx context.Context, objectName string, objectClass ObjectClass, userKrb5CCName string, forceRefresh bool) (l gpoList, err error) {
+	log.Debugf(ctx, "GetPolicies for %q, type %q", objectName, objectClass)
+
+	l = gpoList{objectName: objectName, objectClass: objectClass}
+
+	if objectClass == UserObject && !strings.Contains(objectName, "@") {
+		return l, errors.New(gotext.Get("user name %q should be of the form %s@DOMAIN", objectName, objectName))
 	}
 
-	// Ensure we have an up-to-date copy of the ccache file
-	if err := ad.ensureKrb5CCCopy(krb5CCSymlink, krb5CCPath); err != nil {
-		return pols, err
+	if objectClass == ComputerObject && objectName != ad.hostname {
+		return l, errors.New(gotext.Get("requested a type computer of %q which isn't current host %q", objectName, ad.hostname))
+	}
+
+	if ad.localBackend != nil {
+		return ad.resolveLocalGPOs(ctx, l, forceRefresh)
+	}
+
+	if l.krb5CCPath, err = ad.prepareKrb5CC(objectName, objectClass, userKrb5CCName); err != nil {
+		return l, err
 	}
 
 	var online bool
 	if online, err = ad.configBackend.IsOnline(); err != nil {
-		return pols, err
+		return l, err
 	}
 
-	// If sssd returns that we are offline, returns the cache list of GPOs if present
+	// If sssd returns that we are offline, returns the cache list of GPOs if present.
+	// A corrupted cache is discarded and never applied.
 	if !online {
-		var cachedPolicies policies.Policies
-		if cachedPolicies, err = policies.NewFromCache(ctx, filepath.Join(ad.policiesCacheDir, objectName)); err != nil {
-			return cachedPolicies, errors.New(gotext.Get("machine is offline and policies cache is unavailable: %v", err))
+		l.offlineReason = gotext.Get("machine is offline")
+		return l, nil
+	}
+
+	// Long running daemons can end up with an expired machine ticket: renew it before contacting AD.
+	if objectClass == ComputerObject {
+		if err := ad.ensureMachineTicket(ctx, l.krb5CCPath); err != nil {
+			return l, err
 		}
+	}
 
-		log.Infof(ctx, "Can't reach AD: machine is offline and %q policies are applied using previous online update", objectName)
-		return cachedPolicies, nil
+	// A corrupted policies cache may come with corrupted downloaded GPOs: download all of them again.
+	if err := policies.VerifyCache(ctx, filepath.Join(ad.policiesCacheDir, objectName)); errors.Is(err, policies.ErrCorruptedCache) {
+		log.Warningf(ctx, "Policies cache of %q is corrupted, downloading all GPOs again", objectName)
+		forceRefresh = true
+	} else if err != nil {
+		return l, err
 	}
+	l.forceRefresh = forceRefresh
 
 	// We need an AD DC to connect to
-	adServerFQDN, err := ad.configBackend.ServerFQDN(ctx)
-	if err != nil {
-		return policies.Policies{}, errors.New(gotext.Get("can't get current Server FQDN: %v", err))
+	l.adServerFQDN, err = ad.serverFQDN(ctx)
+	if errors.Is(err, backends.ErrNoActiveServer) {
+		l.offlineReason = gotext.Get("no domain controller is reachable")
+		return l, nil
+	} else if err != nil {
+		return l, errors.New(gotext.Get("can't get current Server FQDN: %v", err))
 	}
 
-	// Otherwise, try fetching the GPO list from LDAP
-	args := append([]string{}, ad.gpoListCmd...) // Copy gpoListCmd to prevent data race
-	scriptArgs := []string{"--objectclass", string(objectClass), adServerFQDN, objectName}
-	cmdArgs := append(args, scriptArgs...)
-	cmdCtx, cancel := context.WithTimeout(ctx, time.Second*10)
-	defer cancel()
-	log.Debugf(ctx, "Getting gpo list with arguments: %q", strings.Join(scriptArgs, " "))
-	// #nosec G204 - cmdArgs is under our control (python embedded script or mock for tests)
-	cmd := exec.CommandContext(cmdCtx, cmdArgs[0], cmdArgs[1:]...)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("KRB5CCNAME=%s", krb5CCPath))
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	l.downloadables = make(map[string]string)
 
-	smbsafe.WaitExec()
-	err = cmd.Run()
-	smbsafe.DoneExec()
-	if err != nil {
-		return pols, errors.New(gotext.Get("failed to retrieve the list of GPO (exited with %d): %v\n%s", cmd.ProcessState.ExitCode(), err, stderr.String()))
+	// With loopback processing, the user policies of the GPOs linked to the machine apply to the user first.
+	if objectClass == UserObject && ad.loopbackMode != LoopbackDisabled {
+		machineKrb5CCPath, err := ad.prepareKrb5CC(ad.hostname, ComputerObject, "")
+		if err != nil {
+			return l, err
+		}
+		if err := ad.ensureMachineTicket(ctx, machineKrb5CCPath); err != nil {
+			return l, err
+		}
+		// Their user configuration applies: the GPOs which have it disabled are skipped, but not the ones which
+		// have their computer configuration disabled.
+		stdout, err := ad.runGPOList(ctx, machineKrb5CCPath, l.adServerFQDN, ad.hostname, ComputerObject, "--configuration", string(UserObject))
+		if errors.Is(err, errGPOListConnectionFailed) {
+			log.Debug(ctx, err)
+			l.offlineReason = gotext.Get("domain controller %s is unreachable", l.adServerFQDN)
+			return l, nil
+		} else if err != nil {
+			return l, err
+		}
+		log.Debugf(ctx, "Loopback processing in %s mode: adding the GPOs of %q to %q", ad.loopbackMode, ad.hostname, objectName)
+		if err := l.addGPOs(ctx, stdout); err != nil {
+			return l, err
+		}
+
+		// In replace mode, the GPOs linked to the user are ignored.
+		if ad.loopbackMode == LoopbackReplace {
+			return l, nil
+		}
+	}
+
+	// Try fetching the GPO list of the object from LDAP
+	stdout, err := ad.runGPOList(ctx, l.krb5CCPath, l.adServerFQDN, objectName, objectClass)
+	if errors.Is(err, errGPOListConnectionFailed) {
+		log.Debug(ctx, err)
+		l.offlineReason = gotext.Get("domain controller %s is unreachable", l.adServerFQDN)
+		return l, nil
+	} else if err != nil {
+		return l, err
 	}
 
-	downloadables := make(map[string]string)
-	var orderedGPOs []gpo
-	scanner := bufio.NewScanner(&stdout)
+	if err := l.addGPOs(ctx, stdout); err != nil {
+		return l, err
+	}
+
+	return l, nil
+}
+
+// addGPOs appends the GPOs listed in the GPO list script output to l, after the ones already there, with the assets
+// next to them.
+// A GPO already in l, like one linked both to the machine and the user with loopback processing, is only kept in its
+// first position.
+func (l *gpoList) addGPOs(ctx context.Context, gpoListOutput io.Reader) error {
+	scanner := bufio.NewScanner(gpoListOutput)
 	for scanner.Scan() {
 		t := scanner.Text()
-		res := strings.SplitN(t, "\t", 2)
-		gpoName, gpoURL := res[0], res[1]
-		log.Debugf(ctx, "GPO %q for %q available at %q", gpoName, objectName, gpoURL)
-		downloadables[gpoName] = gpoURL
-		orderedGPOs = append(orderedGPOs, gpo{name: gpoName, url: gpoURL})
-
-		if _, ok := downloadables["assets"]; ok {
-			continue
-		}
-		u, err := url.Parse(gpoURL)
-		if err != nil {
-			return pols, err
+		// Enforced GPOs are flagged in an optional third field.
+		res := strings.SplitN(t, "\t", 3)
+		enforced := len(res) > 2 && res[2] == "enforced"
+		if err := l.addGPO(ctx, res[0], res[1], enforced); err != nil {
+			return err
 		}
-		// Assets are in <root>/DistroID, while GPOs are in <root>/Policies/<gpoName>
-		u.Path = filepath.Join(filepath.Dir(filepath.Dir(u.Path)), consts.DistroID)
-		downloadables["assets"] = u.String()
 	}
-	if err := scanner.Err(); err != nil {
-		return pols, err
+	return scanner.Err()
+}
+
+// addGPO appends the GPO gpoName available at gpoURL to l, as addGPOs does.
+func (l *gpoList) addGPO(ctx context.Context, gpoName, gpoURL string, enforced bool) error {
+	if _, ok := l.downloadables[gpoName]; ok {
+		log.Debugf(ctx, "GPO %q for %q is already listed with a higher precedence", gpoName, l.objectName)
+		return nil
 	}
+	log.Debugf(ctx, "GPO %q for %q available at %q (enforced: %t)", gpoName, l.objectName, gpoURL, enforced)
+	l.downloadables[gpoName] = gpoURL
+	l.gpos = append(l.gpos, gpo{name: gpoName, url: gpoURL, enforced: enforced})
 
-	ad.Lock()
-	defer ad.Unlock()
-	assetsWereRefresh, err := ad.fetch(ctx, krb5CCPath, downloadables)
+	if _, ok := l.downloadables["assets"]; ok {
+		return nil
+	}
+	u, err := url.Parse(gpoURL)
 	if err != nil {
-		return pols, err
+		return err
+	}
+	// Assets are in <root>/DistroID, while GPOs are in <root>/Policies/<gpoName>
+	u.Path = filepath.Join(filepath.Dir(filepath.Dir(u.Path)), consts.DistroID)
+	l.downloadables["assets"] = u.String()
+	return nil
+}
+
+// fetchAndParse downloads toFetch, the downloadables of l which are not up to date yet, and returns the policies
+// parsed from the GPOs of l. An errSysvolUnreachable error is returned if SYSVOL can't be reached.
+// It must be called with ad locked.
+func (ad *AD) fetchAndParse(ctx context.Context, l gpoList, toFetch map[string]string) (pols policies.Policies, err error) {
+	forceRefresh := l.forceRefresh
+	if ad.refreshOnNext[l.objectName] {
+		log.Debugf(ctx, "Policies cache of %q was purged, downloading all GPOs again", l.objectName)
+		forceRefresh = true
+	}
+	// Nothing is fetched from SYSVOL if all GPOs and assets were already fetched.
+	var assetsWereRefresh bool
+	if len(toFetch) > 0 {
+		if assetsWereRefresh, err = ad.fetch(ctx, l.krb5CCPath, toFetch, forceRefresh); err != nil {
+			return pols, err
+		}
 	}
+	ad.dcUnreachable.Store(false)
+	delete(ad.refreshOnNext, l.objectName)
 
 	var errg errgroup.Group
 	// Parse policies
 	var gposRules []policies.GPO
 	errg.Go(func() (err error) {
-		gposRules, err = ad.parseGPOs(ctx, orderedGPOs, objectClass)
+		gposRules, err = ad.parseGPOs(ctx, l.gpos, l.objectClass)
 		return err
 	})
 
@@ -353,7 +744,189 @@ func (ad *AD) GetPolicies(ctx context.Context, objectName string, objectClass Ob
 		return pols, fmt.Errorf("one or more error while parsing downloaded elements: %w", err)
 	}
 
-	return policies.New(ctx, gposRules, assetsDbPath)
+	if pols, err = policies.New(ctx, gposRules, assetsDbPath); err != nil {
+		return pols, err
+	}
+	pols.FetchedAt = time.Now()
+	return pols, nil
+}
+
+// GPOLink is a GPO applying to an object, as resolved from AD.
+type GPOLink struct {
+	Name string
+	// ID is the GUID of the GPO.
+	ID  string
+	URL string
+	// LinkPath is the distinguished name of the container the GPO is linked to.
+	LinkPath string
+	Enforced bool
+	// InheritanceBlocked is set when the linked container blocks inheritance from its parents.
+	InheritanceBlocked bool
+}
+
+// ListGPOs returns the GPOs applying to objectName, in the order they are resolved from AD, with the highest priority
+// first. Nothing is downloaded nor applied.
+func (ad *AD) ListGPOs(ctx context.Context, objectName string, objectClass ObjectClass, userKrb5CCName string) (gpos []GPOLink, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't list GPOs for %q", objectName))
+
+	if objectClass == ComputerObject && objectName != ad.hostname {
+		return nil, errors.New(gotext.Get("requested a type computer of %q which isn't current host %q", objectName, ad.hostname))
+	}
+
+	if ad.localBackend != nil {
+		return ad.listLocalGPOs(objectClass)
+	}
+
+	krb5CCPath, err := ad.prepareKrb5CC(objectName, objectClass, userKrb5CCName)
+	if err != nil {
+		return nil, err
+	}
+
+	if online, err := ad.configBackend.IsOnline(); err != nil {
+		return nil, err
+	} else if !online {
+		return nil, errors.New(gotext.Get("machine is offline"))
+	}
+	if objectClass == ComputerObject {
+		if err := ad.ensureMachineTicket(ctx, krb5CCPath); err != nil {
+			return nil, err
+		}
+	}
+
+	adServerFQDN, err := ad.serverFQDN(ctx)
+	if err != nil {
+		return nil, errors.New(gotext.Get("can't get current Server FQDN: %v", err))
+	}
+
+	stdout, err := ad.runGPOList(ctx, krb5CCPath, adServerFQDN, objectName, objectClass, "--details")
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		res := strings.Split(scanner.Text(), "\t")
+		if len(res) < 5 {
+			return nil, errors.New(gotext.Get("unexpected GPO list entry: %q", scanner.Text()))
+		}
+		gpos = append(gpos, GPOLink{
+			Name:               res[0],
+			ID:                 filepath.Base(res[1]),
+			URL:                res[1],
+			Enforced:           res[2] == "enforced",
+			LinkPath:           res[3],
+			InheritanceBlocked: res[4] == "blocked",
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return gpos, nil
+}
+
+// errGPOListConnectionFailed is returned when the GPO list script can't connect to the domain controller.
+var errGPOListConnectionFailed = errors.New(gotext.Get("can't connect to domain controller"))
+
+// runGPOList runs the GPO list script for objectName on adServerFQDN and returns its output.
+func (ad *AD) runGPOList(ctx context.Context, krb5CCPath, adServerFQDN, objectName string, objectClass ObjectClass, extraArgs ...string) (*bytes.Buffer, error) {
+	args := append([]string{}, ad.gpoListCmd...) // Copy gpoListCmd to prevent data race
+	scriptArgs := append([]string{"--objectclass", string(objectClass)}, extraArgs...)
+	scriptArgs = append(scriptArgs, adServerFQDN, objectName)
+	if ad.gpoLinkOrder {
+		scriptArgs = append(scriptArgs, "--link-order")
+	}
+	cmdArgs := append(args, scriptArgs...)
+	cmdCtx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+	log.Debugf(ctx, "Getting gpo list with arguments: %q", strings.Join(scriptArgs, " "))
+	// #nosec G204 - cmdArgs is under our control (python embedded script or mock for tests)
+	cmd := exec.CommandContext(cmdCtx, cmdArgs[0], cmdArgs[1:]...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("KRB5CCNAME=%s", krb5CCPath))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	smbsafe.WaitExec()
+	err := cmd.Run()
+	smbsafe.DoneExec()
+	if err != nil && cmd.ProcessState != nil && cmd.ProcessState.ExitCode() == gpoListConnectionFailed {
+		return nil, fmt.Errorf("%w %s: %s", errGPOListConnectionFailed, adServerFQDN, stderr.String())
+	} else if err != nil {
+		return nil, errors.New(gotext.Get("failed to retrieve the list of GPO (exited with %d): %v\n%s", cmd.ProcessState.ExitCode(), err, stderr.String()))
+	}
+
+	return &stdout, nil
+}
+
+// prepareKrb5CC returns the path of an up-to-date copy of the ticket cache of objectName.
+// The machine ticket is requested from the backend. For users, userKrb5CCName is tracked for future calls if set.
+func (ad *AD) prepareKrb5CC(objectName string, objectClass ObjectClass, userKrb5CCName string) (krb5CCPath string, err error) {
+	krb5CCPath = filepath.Join(ad.krb5CacheDir, objectName)
+	krb5CCSymlink := filepath.Join(ad.krb5CacheDir, "tracking", objectName)
+	// Create a ccache symlink on first fetch for future calls (on refresh for instance)
+	if userKrb5CCName != "" || objectClass == ComputerObject {
+		src := userKrb5CCName
+		// there is no env var for machine: get sss ccache
+		if objectClass == ComputerObject {
+			src, err = ad.configBackend.HostKrb5CCName()
+			if err != nil {
+				return "", err
+			}
+		}
+
+		// Create a symlink to the ccache file
+		if err := ad.ensureKrb5CCSymlink(src, krb5CCSymlink); err != nil {
+			return "", err
+		}
+	}
+
+	// Ensure we have an up-to-date copy of the ccache file
+	if err := ad.ensureKrb5CCCopy(krb5CCSymlink, krb5CCPath); err != nil {
+		return "", err
+	}
+
+	return krb5CCPath, nil
+}
+
+// cachedPolicies returns the policies of objectName from its last online update, when AD can't be reached because of
+// reason. Caches older than the maximum cache age are refused.
+func (ad *AD) cachedPolicies(ctx context.Context, objectName, reason string) (pols policies.Policies, err error) {
+	p := filepath.Join(ad.policiesCacheDir, objectName)
+	if pols, err = policies.NewFromCache(ctx, p); err != nil {
+		return pols, errors.New(gotext.Get("%s and policies cache is unavailable: %v", reason, err))
+	}
+
+	// Caches saved before the fetch time was recorded are as old as their last save.
+	fetchedAt := pols.FetchedAt
+	if fetchedAt.IsZero() {
+		info, err := os.Stat(p)
+		if err != nil {
+			_ = pols.Close()
+			return policies.Policies{}, errors.New(gotext.Get("%s and policies cache is unavailable: %v", reason, err))
+		}
+		fetchedAt = info.ModTime()
+	}
+	if maxAge := time.Duration(ad.maxCacheAge.Load()); maxAge > 0 && time.Since(fetchedAt) > maxAge {
+		_ = pols.Close()
+		return policies.Policies{}, errors.New(gotext.Get("%s and policies cache from %s is older than the maximum cache age of %s",
+			reason, fetchedAt.Format(time.RFC3339), maxAge))
+	}
+
+	if online, err := ad.configBackend.IsOnline(); err == nil && online {
+		ad.dcUnreachable.Store(true)
+	}
+	log.Warningf(ctx, "Can't reach AD: %s. Offline, using cached policies of %q from %s", reason, objectName, fetchedAt.Format(time.RFC3339))
+	return pols, nil
+}
+
+// RefreshOnNextUpdate downloads again all the GPOs of objectName on its next successful update, even if they are up
+// to date. This is used once its policies cache is purged.
+func (ad *AD) RefreshOnNextUpdate(objectName string) {
+	ad.Lock()
+	defer ad.Unlock()
+
+	ad.refreshOnNext[objectName] = true
 }
 
 // ListUsers returns the list of users on the system based on their cached policy information.
@@ -481,14 +1054,13 @@ func safeCopyFile(src, dst string, mode os.FileMode) error {
 }
 
 func (ad *AD) parseGPOs(ctx context.Context, gpos []gpo, objectClass ObjectClass) (r []policies.GPO, err error) {
-	keyFilterPrefix := fmt.Sprintf("%s/%s/", adcommon.KeyPrefix, consts.DistroID)
-
 	for _, g := range gpos {
 		name, url := g.name, g.url
 		gpoWithRules := policies.GPO{
-			ID:    filepath.Base(url),
-			Name:  name,
-			Rules: make(map[string][]entry.Entry),
+			ID:       filepath.Base(url),
+			Name:     name,
+			Enforced: g.enforced,
+			Rules:    make(map[string][]entry.Entry),
 		}
 		r = append(r, gpoWithRules)
 		if err := func() error {
@@ -496,111 +1068,166 @@ func (ad *AD) parseGPOs(ctx context.Context, gpos []gpo, objectClass ObjectClass
 			defer ad.downloadables[name].mu.RUnlock()
 			_ = ad.downloadables[name].testConcurrent
 
-			log.Debugf(ctx, "Parsing GPO %q", name)
+			return ad.parseGPORules(ctx, name, filepath.Join(ad.sysvolCacheDir, "Policies", filepath.Base(url)), objectClass, gpoWithRules.Rules)
+		}(); err != nil {
+			return r, err
+		}
+	}
 
-			// We need to consider the uppercase version of the name as well,
-			// which could occur in some of the default GPOs such as Default
-			// Domain Policy.
-			classes := []string{"User", "USER"}
-			if objectClass == ComputerObject {
-				classes = []string{"Machine", "MACHINE"}
-			}
+	return r, nil
+}
 
-			var err error
-			var f *os.File
-			for _, class := range classes {
-				var e error
-				f, e = os.Open(filepath.Join(ad.sysvolCacheDir, "Policies", filepath.Base(url), class, "Registry.pol"))
-
-				// We only care about the first error which is caused by opening
-				// the capitalized version of the class, instead of the
-				// uppercase version which is less common and more of an edge case.
-				if e != nil && err == nil {
-					err = e
-				} else if e == nil {
-					err = nil
-					break
-				}
-			}
+// parseGPORules adds to rules the policies for objectClass of the GPO name, stored in gpoDir.
+func (ad *AD) parseGPORules(ctx context.Context, name, gpoDir string, objectClass ObjectClass, rules map[string][]entry.Entry) error {
+	keyFilterPrefix := fmt.Sprintf("%s/%s/", adcommon.KeyPrefix, consts.DistroID)
 
-			if errors.Is(err, fs.ErrNotExist) {
-				log.Debugf(ctx, "Policy %q doesn't have any policy for class %q %s", name, objectClass, err)
-				return nil
-			} else if err != nil {
-				return err
-			}
-			defer decorate.LogFuncOnErrorContext(ctx, f.Close)
+	log.Debugf(ctx, "Parsing GPO %q", name)
 
-			// Decode and apply policies in gpo order. First win
-			pols, err := registry.DecodePolicy(f)
-			if err != nil {
-				return errors.New(gotext.Get("%s: %v", f.Name(), err))
-			}
+	match, err := ad.matchesMachineFilter(ctx, gpoDir)
+	if err != nil {
+		return errors.New(gotext.Get("GPO %q: %v", name, err))
+	}
+	if !match {
+		log.Infof(ctx, "Skipping GPO %q: the machine doesn't match its filter", name)
+		return nil
+	}
 
-			// filter keys to be overridden
-			var currentKey string
-			var overrideEnabled bool
-			for _, pol := range pols {
-				// Rewrite the certificate autoenrollment key so we can easily
-				// use it in the policy manager
-				if pol.Key == certAutoEnrollKey {
-					pol.Key = fmt.Sprintf("%scertificate/autoenroll/all", keyFilterPrefix)
-				}
+	f, err := openRegistryPol(gpoDir, objectClass)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Debugf(ctx, "Policy %q doesn't have any policy for class %q %s", name, objectClass, err)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer decorate.LogFuncOnErrorContext(ctx, f.Close)
 
-				if strings.HasPrefix(pol.Key, policyServersPrefix) {
-					pol.Key = fmt.Sprintf("%scertificate/%s/all", keyFilterPrefix, pol.Key)
-				}
+	// The class of the registry file is the scope the policies are applied to.
+	scope := UserObject
+	if strings.EqualFold(filepath.Base(filepath.Dir(f.Name())), "Machine") {
+		scope = ComputerObject
+	}
 
-				// Only consider supported policies for this distro
-				if !strings.HasPrefix(pol.Key, keyFilterPrefix) {
-					continue
-				}
-				if pol.Err != nil {
-					return errors.New(gotext.Get("%s: %v", f.Name(), pol.Err))
-				}
-				pol.Key = strings.TrimPrefix(pol.Key, keyFilterPrefix)
+	// Decode and apply policies in gpo order. First win
+	pols, err := registry.DecodePolicy(f)
+	if err != nil {
+		return errors.New(gotext.Get("%s: %v", f.Name(), err))
+	}
 
-				// Some keys can be overridden
-				releaseID := filepath.Base(pol.Key)
-				keyType := strings.Split(pol.Key, "/")[0]
-				pol.Key = filepath.Dir(strings.TrimPrefix(pol.Key, keyType+"/"))
+	// filter keys to be overridden
+	var currentKey string
+	var overrideEnabled bool
+	var scopeErrs []error
+	for _, pol := range pols {
+		// Rewrite the certificate autoenrollment key so we can easily
+		// use it in the policy manager
+		if pol.Key == certAutoEnrollKey {
+			pol.Key = fmt.Sprintf("%scertificate/autoenroll/all", keyFilterPrefix)
+		}
 
-				if releaseID == "all" {
-					currentKey = pol.Key
-					overrideEnabled = false
-					gpoWithRules.Rules[keyType] = append(gpoWithRules.Rules[keyType], pol)
-					continue
-				}
+		if strings.HasPrefix(pol.Key, policyServersPrefix) {
+			pol.Key = fmt.Sprintf("%scertificate/%s/all", keyFilterPrefix, pol.Key)
+		}
 
-				// This is not an "all" key and the key name don’t match
-				// This shouldn’t happen with our admx, but just to stay safe…
-				if currentKey != pol.Key {
-					continue
-				}
+		// Only consider supported policies for this distro
+		if !strings.HasPrefix(pol.Key, keyFilterPrefix) {
+			continue
+		}
+		if pol.Err != nil {
+			return errors.New(gotext.Get("%s: %v", f.Name(), pol.Err))
+		}
+		pol.Key = strings.TrimPrefix(pol.Key, keyFilterPrefix)
 
-				if strings.HasPrefix(releaseID, "Override"+ad.versionID) && pol.Value == "true" {
-					overrideEnabled = true
-					continue
-				}
-				// Check we have a matching override
-				if !overrideEnabled || releaseID != ad.versionID {
-					continue
-				}
+		// The machine attributes filter was already evaluated and is not applied.
+		if strings.HasPrefix(pol.Key, filterKeyType+"/") {
+			continue
+		}
 
-				// Matching enabled override
-				// Replace value with the override content
-				iLast := len(gpoWithRules.Rules[keyType]) - 1
-				p := gpoWithRules.Rules[keyType][iLast]
-				p.Value = pol.Value
-				gpoWithRules.Rules[keyType][iLast] = p
+		// Deletions apply to the whole key, which has no release ID.
+		if pol.Delete {
+			keyType, key, found := strings.Cut(pol.Key, "/")
+			if !found {
+				continue
 			}
-			return nil
-		}(); err != nil {
-			return r, err
+			pol.Key = key
+			rules[keyType] = append(rules[keyType], pol)
+			continue
 		}
+
+		// Some keys can be overridden
+		releaseID := filepath.Base(pol.Key)
+		keyType := strings.Split(pol.Key, "/")[0]
+		pol.Key = filepath.Dir(strings.TrimPrefix(pol.Key, keyType+"/"))
+
+		if releaseID == "all" {
+			// Policies of the other class would end up in the wrong database: they are dropped, along
+			// with their overrides. They fail the machine policy and are only reported for users.
+			if class, ok := intendedClass(keyType, pol.Key); ok && class != scope {
+				currentKey = ""
+				msg := gotext.Get("%s: %s policy %q is meant for %s objects, not %s ones", f.Name(), keyType, pol.Key, class, scope)
+				if scope == ComputerObject {
+					scopeErrs = append(scopeErrs, errors.New(msg))
+				} else {
+					log.Warning(ctx, msg)
+				}
+				continue
+			}
+			currentKey = pol.Key
+			overrideEnabled = false
+			rules[keyType] = append(rules[keyType], pol)
+			continue
+		}
+
+		// This is not an "all" key and the key name don’t match
+		// This shouldn’t happen with our admx, but just to stay safe…
+		if currentKey != pol.Key {
+			continue
+		}
+
+		if strings.HasPrefix(releaseID, "Override"+ad.versionID) && pol.Value == "true" {
+			overrideEnabled = true
+			continue
+		}
+		// Check we have a matching override
+		if !overrideEnabled || releaseID != ad.versionID {
+			continue
+		}
+
+		// Matching enabled override
+		// Replace value with the override content
+		iLast := len(rules[keyType]) - 1
+		p := rules[keyType][iLast]
+		p.Value = pol.Value
+		rules[keyType][iLast] = p
 	}
+	return errors.Join(scopeErrs...)
+}
 
-	return r, nil
+// openRegistryPol opens the registry file for objectClass of the GPO stored in gpoDir.
+func openRegistryPol(gpoDir string, objectClass ObjectClass) (f *os.File, err error) {
+	// We need to consider the uppercase version of the name as well,
+	// which could occur in some of the default GPOs such as Default
+	// Domain Policy.
+	classes := []string{"User", "USER"}
+	if objectClass == ComputerObject {
+		classes = []string{"Machine", "MACHINE"}
+	}
+
+	for _, class := range classes {
+		var e error
+		f, e = os.Open(filepath.Join(gpoDir, class, "Registry.pol"))
+
+		// We only care about the first error which is caused by opening
+		// the capitalized version of the class, instead of the
+		// uppercase version which is less common and more of an edge case.
+		if e != nil && err == nil {
+			err = e
+		} else if e == nil {
+			err = nil
+			break
+		}
+	}
+
+	return f, err
 }
 
 // GetInfo returns all information from the selected backend: static and dynamic part.
@@ -615,6 +1242,8 @@ func (ad *AD) GetInfo(ctx context.Context) (msg string) {
 		online = fmt.Sprint(gotext.Get("**Can't check if we have an active connection**\n"))
 	} else if !isOnline {
 		online = fmt.Sprint(gotext.Get("**Offline mode** using cached policies\n"))
+	} else if ad.dcUnreachable.Load() {
+		online = fmt.Sprint(gotext.Get("**Offline mode** domain controller is unreachable, using cached policies\n"))
 	}
 	domain := ad.configBackend.Domain()
 	server, err := ad.configBackend.ServerFQDN(ctx)
@@ -622,7 +1251,15 @@ func (ad *AD) GetInfo(ctx context.Context) (msg string) {
 		server = "Unknown"
 	}
 
-	return gotext.Get("%s\n%sDomain: %s\nServer FQDN: %s", config, online, domain, server)
+	var preferred string
+	if ad.preferredServer != "" {
+		preferred += gotext.Get("\nPreferred server: %s", ad.preferredServer)
+	}
+	if ad.site != "" {
+		preferred += gotext.Get("\nPreferred site: %s", ad.site)
+	}
+
+	return gotext.Get("%s\n%sDomain: %s\nServer FQDN: %s%s", config, online, domain, server, preferred)
 }
 
 // NormalizeTargetName transforms the specified target to values adsys knows.
@@ -2,21 +2,29 @@ package ad_test
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/adsys/internal/ad"
 	"github.com/ubuntu/adsys/internal/ad/backends"
+	"github.com/ubuntu/adsys/internal/ad/backends/local"
 	"github.com/ubuntu/adsys/internal/ad/backends/mock"
+	"github.com/ubuntu/adsys/internal/ad/filter"
 	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/testutils"
@@ -33,6 +41,7 @@ func TestNew(t *testing.T) {
 		cacheDirRO             bool
 		runDirRO               bool
 		backendServerFQDNError error
+		loopback               ad.LoopbackMode
 
 		wantErr bool
 	}{
@@ -43,6 +52,7 @@ func TestNew(t *testing.T) {
 		"failed to create Sysvol cache directory":    {cacheDirRO: true, wantErr: true},
 		"failed to create Policies cache directory":  {sysvolCacheDirExists: true, cacheDirRO: true, wantErr: true},
 		"error on backend ServerFQDN random failure": {backendServerFQDNError: errors.New("Some failure on ServerFQDN"), wantErr: true},
+		"error on invalid loopback processing mode":  {loopback: "invalid", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -62,7 +72,8 @@ func TestNew(t *testing.T) {
 
 			adc, err := ad.New(context.Background(), mock.Backend{ErrServerFQDN: tc.backendServerFQDNError}, hostname,
 				ad.WithRunDir(runDir),
-				ad.WithCacheDir(cacheDir))
+				ad.WithCacheDir(cacheDir),
+				ad.WithLoopbackProcessing(tc.loopback))
 			if tc.wantErr {
 				require.NotNil(t, err, "AD creation should have failed")
 				return
@@ -106,14 +117,18 @@ func TestGetPolicies(t *testing.T) {
 					  is modified for the host, it'll defeat the strategy to set default values.
 	*/
 
+	labFacts := filter.Facts{"hostname": "lab-1", "os.version_id": "22.04"}
+
 	tests := map[string]struct {
 		objectName         string
 		objectClass        ad.ObjectClass
 		userKrb5CCBaseName string
 
-		backend     mock.Backend
-		versionID   string
-		gpoListArgs []string
+		backend      mock.Backend
+		versionID    string
+		machineFacts filter.Facts
+		gpoListArgs  []string
+		loopback     ad.LoopbackMode
 
 		turnKrb5CCCacheRO bool
 		existing          map[string]string
@@ -153,6 +168,76 @@ func TestGetPolicies(t *testing.T) {
 			want:        policies.Policies{GPOs: []policies.GPO{{ID: "machine-only", Name: "machine-only-name", Rules: make(map[string][]entry.Entry)}}},
 		},
 
+		// Scope cases
+		"Policies scoped to users, user object": {
+			gpoListArgs: []string{"gpoonly.com", "bob:right-scope"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "right-scope", Name: "right-scope-name", Rules: map[string][]entry.Entry{
+					"dconf":   {{Key: "A", Value: "rightScopeA"}},
+					"scripts": {{Key: "logon", Value: "script.sh"}},
+				}}}},
+		},
+		"Policies scoped to the machine, computer object": {
+			objectName:  hostname,
+			objectClass: ad.ComputerObject,
+			gpoListArgs: []string{"gpoonly.com", hostname + ":right-scope"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "right-scope", Name: "right-scope-name", Rules: map[string][]entry.Entry{
+					"dconf":     {{Key: "A", Value: "rightScopeA"}},
+					"privilege": {{Key: "client-admins", Value: "bob@gpoonly.com"}},
+					"scripts":   {{Key: "startup", Value: "script.sh"}},
+				}}}},
+		},
+		"Policies scoped to the machine are ignored for user object": {
+			gpoListArgs: []string{"gpoonly.com", "bob:wrong-scope"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "wrong-scope", Name: "wrong-scope-name", Rules: map[string][]entry.Entry{
+					"dconf":   {{Key: "A", Value: "wrongScopeA"}},
+					"scripts": {{Key: "logon", Value: "script.sh"}},
+				}}}},
+		},
+
+		// Machine attributes filter cases
+		"Machine matching GPO filter, computer object": {
+			objectName:   hostname,
+			objectClass:  ad.ComputerObject,
+			machineFacts: labFacts,
+			gpoListArgs:  []string{"gpoonly.com", hostname + ":machine-filter"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "machine-filter", Name: "machine-filter-name", Rules: map[string][]entry.Entry{
+					"dconf": {{Key: "A", Value: "machineFilterA"}},
+				}}}},
+		},
+		"Machine matching GPO filter, user object": {
+			machineFacts: labFacts,
+			gpoListArgs:  []string{"gpoonly.com", "bob:machine-filter"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "machine-filter", Name: "machine-filter-name", Rules: map[string][]entry.Entry{
+					"dconf": {{Key: "A", Value: "machineFilterA"}},
+				}}}},
+		},
+		"Machine not matching GPO filter, computer object, policy is empty": {
+			objectName:   hostname,
+			objectClass:  ad.ComputerObject,
+			machineFacts: filter.Facts{"hostname": "web-1", "os.version_id": "22.04"},
+			gpoListArgs:  []string{"gpoonly.com", hostname + ":machine-filter"},
+			want:         policies.Policies{GPOs: []policies.GPO{{ID: "machine-filter", Name: "machine-filter-name", Rules: make(map[string][]entry.Entry)}}},
+		},
+		"Machine not matching GPO filter, user object, policy is empty": {
+			machineFacts: filter.Facts{"hostname": "lab-1", "os.version_id": "20.04"},
+			gpoListArgs:  []string{"gpoonly.com", "bob:machine-filter"},
+			want:         policies.Policies{GPOs: []policies.GPO{{ID: "machine-filter", Name: "machine-filter-name", Rules: make(map[string][]entry.Entry)}}},
+		},
+		"Machine not matching GPO filter only skips the filtered GPO": {
+			machineFacts: filter.Facts{"hostname": "web-1", "os.version_id": "22.04"},
+			gpoListArgs:  []string{"gpoonly.com", "bob:machine-filter::bob:one-value"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "machine-filter", Name: "machine-filter-name", Rules: make(map[string][]entry.Entry)},
+				{ID: "one-value", Name: "one-value-name", Rules: map[string][]entry.Entry{
+					"dconf": {{Key: "C", Value: "oneValueC"}},
+				}}}},
+		},
+
 		// Assets cases
 		"Standard policy with assets, downloads assets": {
 			objectName:  hostname,
@@ -349,6 +434,97 @@ func TestGetPolicies(t *testing.T) {
 				standardUserGPO("standard")}},
 		},
 
+		// Loopback processing cases
+		"Loopback processing in merge mode, machine GPOs take precedence over user ones": {
+			gpoListArgs: []string{"gpoonly.com", hostname + ":user-only::bob:standard"},
+			loopback:    ad.LoopbackMerge,
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "user-only", Name: "user-only-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "A", Value: "userOnlyA"},
+						{Key: "B", Value: "userOnlyB"},
+					}}},
+				standardUserGPO("standard"),
+			}},
+		},
+		"Loopback processing in replace mode, only machine GPOs apply": {
+			gpoListArgs: []string{"gpoonly.com", hostname + ":user-only::bob:standard"},
+			loopback:    ad.LoopbackReplace,
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "user-only", Name: "user-only-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "A", Value: "userOnlyA"},
+						{Key: "B", Value: "userOnlyB"},
+					}}},
+			}},
+		},
+		"Loopback processing in merge mode, GPO linked to machine and user applies once with machine precedence": {
+			gpoListArgs: []string{"gpoonly.com", hostname + ":standard::bob:one-value::bob:standard"},
+			loopback:    ad.LoopbackMerge,
+			want: policies.Policies{GPOs: []policies.GPO{
+				standardUserGPO("standard"),
+				{ID: "one-value", Name: "one-value-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "C", Value: "oneValueC"},
+					}}},
+			}},
+		},
+		"Loopback processing in replace mode, machine GPOs without user policy are empty": {
+			gpoListArgs: []string{"gpoonly.com", hostname + ":machine-only::bob:standard"},
+			loopback:    ad.LoopbackReplace,
+			want:        policies.Policies{GPOs: []policies.GPO{{ID: "machine-only", Name: "machine-only-name", Rules: make(map[string][]entry.Entry)}}},
+		},
+		"Loopback processing applies machine GPOs with their computer configuration disabled": {
+			gpoListArgs: []string{"gpoonly.com", hostname + ":user-only+computerdisabled::bob:standard"},
+			loopback:    ad.LoopbackMerge,
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "user-only", Name: "user-only-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "A", Value: "userOnlyA"},
+						{Key: "B", Value: "userOnlyB"},
+					}}},
+				standardUserGPO("standard"),
+			}},
+		},
+		"Loopback processing skips machine GPOs with their user configuration disabled": {
+			gpoListArgs: []string{"gpoonly.com", hostname + ":standard+userdisabled::bob:one-value"},
+			loopback:    ad.LoopbackMerge,
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "one-value", Name: "one-value-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "C", Value: "oneValueC"},
+					}}},
+			}},
+		},
+		"Loopback processing is ignored for computer object": {
+			objectName:  hostname,
+			objectClass: ad.ComputerObject,
+			gpoListArgs: []string{"gpoonly.com", hostname + ":standard::bob:user-only"},
+			loopback:    ad.LoopbackReplace,
+			want:        policies.Policies{GPOs: []policies.GPO{standardComputerGPO("standard")}},
+		},
+
+		// GPO status cases
+		"GPO with its user configuration disabled is skipped, user object": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard+userdisabled::bob:one-value"},
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "one-value", Name: "one-value-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "C", Value: "oneValueC"},
+					}}},
+			}},
+		},
+		"GPO with its computer configuration disabled is skipped, computer object": {
+			objectName:  hostname,
+			objectClass: ad.ComputerObject,
+			gpoListArgs: []string{"gpoonly.com", hostname + ":standard+computerdisabled"},
+			want:        policies.Policies{},
+		},
+		"GPO with its computer configuration disabled applies, user object": {
+			gpoListArgs: []string{"gpoonly.com", "bob:standard+computerdisabled"},
+			want:        policies.Policies{GPOs: []policies.GPO{standardUserGPO("standard")}},
+		},
+
 		"Disabled value overrides non disabled one": {
 			gpoListArgs: []string{"gpoonly.com", "bob:disabled-value::bob:standard"},
 			want: policies.Policies{GPOs: []policies.GPO{
@@ -452,6 +628,24 @@ func TestGetPolicies(t *testing.T) {
 		},
 
 		// Error cases
+		"Error on invalid GPO filter, computer object": {
+			objectName:   hostname,
+			objectClass:  ad.ComputerObject,
+			machineFacts: labFacts,
+			gpoListArgs:  []string{"gpoonly.com", hostname + ":invalid-machine-filter"},
+			wantErr:      true,
+		},
+		"Error on invalid GPO filter, user object": {
+			machineFacts: labFacts,
+			gpoListArgs:  []string{"gpoonly.com", "bob:invalid-machine-filter"},
+			wantErr:      true,
+		},
+		"Error on policies scoped to users for computer object": {
+			objectName:  hostname,
+			objectClass: ad.ComputerObject,
+			gpoListArgs: []string{"gpoonly.com", hostname + ":wrong-scope"},
+			wantErr:     true,
+		},
 		"Machine doesn’t match": {
 			objectName:  "NotHostname",
 			objectClass: ad.ComputerObject,
@@ -509,6 +703,16 @@ func TestGetPolicies(t *testing.T) {
 			gpoListArgs: []string{"gpoonly.com", hostname + ":standard"},
 			wantErr:     true,
 		},
+		"Error on backend HostKrb5CCName call failed with loopback processing, user object": {
+			backend: mock.Backend{
+				Dom:           "gpoonly.com",
+				Online:        true,
+				ErrKrb5CCName: true,
+			},
+			gpoListArgs: []string{"gpoonly.com", hostname + ":user-only::bob:standard"},
+			loopback:    ad.LoopbackMerge,
+			wantErr:     true,
+		},
 		"Error on user without @ in name": {
 			objectName:  "bob",
 			gpoListArgs: []string{"gpoonly.com", "bob:standard"},
@@ -549,7 +753,7 @@ func TestGetPolicies(t *testing.T) {
 				tc.objectClass = ad.UserObject
 			}
 
-			if tc.backend == (mock.Backend{}) {
+			if reflect.ValueOf(tc.backend).IsZero() {
 				tc.backend = mock.Backend{
 					Dom:    "gpoonly.com",
 					Online: true,
@@ -585,7 +789,9 @@ func TestGetPolicies(t *testing.T) {
 			adc, err := ad.New(context.Background(), tc.backend, hostname,
 				ad.WithCacheDir(cachedir), ad.WithRunDir(rundir), ad.WithoutKerberos(),
 				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)),
-				ad.WithVersionID(tc.versionID))
+				ad.WithVersionID(tc.versionID),
+				ad.WithMachineFacts(tc.machineFacts),
+				ad.WithLoopbackProcessing(tc.loopback))
 			require.NoError(t, err, "Setup: cannot create ad object")
 
 			if tc.turnKrb5CCCacheRO {
@@ -597,7 +803,7 @@ func TestGetPolicies(t *testing.T) {
 				testutils.Copy(t, src, filepath.Join(adc.SysvolCacheDir(), n))
 			}
 
-			entries, err := adc.GetPolicies(context.Background(), tc.objectName, tc.objectClass, krb5CCName)
+			entries, err := adc.GetPolicies(context.Background(), tc.objectName, tc.objectClass, krb5CCName, false)
 			if tc.wantErr {
 				require.Error(t, err, "GetPolicies should have errored out")
 				return
@@ -632,8 +838,17 @@ func TestGetPoliciesOffline(t *testing.T) {
 		domainToCache string
 		backend       mock.Backend
 		gpoListArgs   []string
+		corruptCache  bool
+		maxCacheAge   time.Duration
+		// changedMaxCacheAge is the maximum cache age set once the ad object is created.
+		changedMaxCacheAge time.Duration
+		cacheAge           time.Duration
+		// cacheWithoutFetchTime is a cache saved before the fetch time was recorded in it: its age is the one of the
+		// cache directory.
+		cacheWithoutFetchTime bool
 
 		wantAssets bool
+		wantStatus string
 		wantErr    bool
 	}{
 		"Offline, get from cache, gpo only": {
@@ -671,15 +886,145 @@ func TestGetPoliciesOffline(t *testing.T) {
 			wantAssets:  true,
 		},
 
-		"Error on SSSD reports online, but we are actually offline when fetching gpo list, even with a cache": {
+		"SSSD reports online, but domain controller is unreachable when fetching gpo list, use cache": {
 			domainToCache: "assetsandgpo.com",
 			backend: mock.Backend{
 				Dom:    "assetsandgpo.com",
 				Online: true,
 			},
 			gpoListArgs: []string{"-Exit2-"},
+			wantAssets:  true,
+			wantStatus:  "domain controller is unreachable",
+		},
+		"SSSD reports online, but SYSVOL is unreachable when downloading GPOs, use cache": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: true,
+			},
+			gpoListArgs: []string{"-UnreachableSysvol-", "gpoonly.com", "useroffline:standard"},
+			wantStatus:  "domain controller is unreachable",
+		},
+		"No active domain controller, use cache": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:           "gpoonly.com",
+				Online:        true,
+				ErrServerFQDN: backends.ErrNoActiveServer,
+			},
+			gpoListArgs: []string{"-Exit2-"}, // this should not be used
+			wantStatus:  "domain controller is unreachable",
+		},
+		"Offline, cache is more recent than maximum cache age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge: 24 * time.Hour,
+			cacheAge:    time.Hour,
+		},
+		"Offline, cache is more recent than maximum cache age changed while running": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge:        time.Hour,
+			changedMaxCacheAge: 24 * time.Hour,
+			cacheAge:           2 * time.Hour,
+		},
+		"Offline, cache without fetch time is more recent than maximum cache age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge:           24 * time.Hour,
+			cacheAge:              time.Hour,
+			cacheWithoutFetchTime: true,
+		},
+		"Domain controller is unreachable, cache is more recent than maximum cache age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: true,
+			},
+			gpoListArgs: []string{"-Exit2-"},
+			maxCacheAge: 24 * time.Hour,
+			cacheAge:    time.Hour,
+			wantStatus:  "domain controller is unreachable",
+		},
+
+		"Error offline with cache older than maximum cache age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge: 24 * time.Hour,
+			cacheAge:    48 * time.Hour,
+			wantErr:     true,
+		},
+		"Error offline with cache older than maximum cache age changed while running": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			changedMaxCacheAge: 24 * time.Hour,
+			cacheAge:           48 * time.Hour,
+			wantErr:            true,
+		},
+		"Error offline with cache without fetch time older than maximum cache age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: false,
+			},
+			maxCacheAge:           24 * time.Hour,
+			cacheAge:              48 * time.Hour,
+			cacheWithoutFetchTime: true,
+			wantErr:               true,
+		},
+		"Error on domain controller unreachable with cache older than maximum cache age": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: true,
+			},
+			gpoListArgs: []string{"-Exit2-"},
+			maxCacheAge: 24 * time.Hour,
+			cacheAge:    48 * time.Hour,
+			wantErr:     true,
+		},
+		"Error on gpo list failing while domain controller is reachable, even with a cache": {
+			domainToCache: "gpoonly.com",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: true,
+			},
+			gpoListArgs: []string{"-Exit1-"},
+			wantErr:     true,
+		},
+		"Error on domain controller unreachable with no cache": {
+			domainToCache: "",
+			backend: mock.Backend{
+				Dom:    "gpoonly.com",
+				Online: true,
+			},
+			gpoListArgs: []string{"-Exit2-"},
 			wantErr:     true,
 		},
+		"Error offline with corrupted cache": {
+			domainToCache: "assetsandgpo.com",
+			backend: mock.Backend{
+				Dom:    "assetsandgpo.com",
+				Online: false,
+			},
+			corruptCache: true,
+			wantErr:      true,
+		},
 		"Error offline with no cache": {
 			domainToCache: "",
 			backend: mock.Backend{
@@ -700,8 +1045,11 @@ func TestGetPoliciesOffline(t *testing.T) {
 			cachedir, rundir := t.TempDir(), t.TempDir()
 			adc, err := ad.New(context.Background(), tc.backend, hostname,
 				ad.WithCacheDir(cachedir), ad.WithRunDir(rundir), ad.WithoutKerberos(),
-				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)))
+				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)), ad.WithMaxCacheAge(tc.maxCacheAge))
 			require.NoError(t, err, "Setup: cannot create ad object")
+			if tc.changedMaxCacheAge != 0 {
+				require.NoError(t, adc.SetMaxCacheAge(tc.changedMaxCacheAge), "Setup: cannot change maximum cache age")
+			}
 
 			objectName := fmt.Sprintf("useroffline@%s", strings.ToUpper(tc.backend.Dom))
 			objectClass := ad.UserObject
@@ -724,15 +1072,35 @@ func TestGetPoliciesOffline(t *testing.T) {
 					ad.WithGPOListCmd(mockGPOListCmd(t, tc.domainToCache, fmt.Sprintf("useroffline:standard::%s:standard", hostname))))
 				require.NoError(t, err, "Setup: cannot create ad object")
 
-				initialPolicies, err = adcForCache.GetPolicies(context.Background(), objectNameForCache, objectClass, krb5CCNameForCache)
+				initialPolicies, err = adcForCache.GetPolicies(context.Background(), objectNameForCache, objectClass, krb5CCNameForCache, false)
 				require.NoError(t, err, "Setup: caching with getPolicies failed")
 
-				// Save it and copy to finale destination
+				require.False(t, initialPolicies.FetchedAt.IsZero(), "Setup: policies fetched online should record their fetch time")
+				lastUpdate := time.Now().Add(-tc.cacheAge)
+				initialPolicies.FetchedAt = lastUpdate
+				if tc.cacheWithoutFetchTime {
+					initialPolicies.FetchedAt = time.Time{}
+				}
+
+				// Save it and copy to finale destination. The cache is saved again on each application, even from
+				// itself: only its fetch time gives its age.
 				err = initialPolicies.Save(filepath.Join(adc.PoliciesCacheDir(), objectName))
 				require.NoError(t, err, "Setup: cannot create policy cache file for finale user")
+
+				if tc.corruptCache {
+					err = os.WriteFile(filepath.Join(adc.PoliciesCacheDir(), objectName, "assets.db"), []byte("corrupted"), 0600)
+					require.NoError(t, err, "Setup: cannot corrupt policy cache file")
+				}
+				if tc.cacheWithoutFetchTime {
+					err = os.Chtimes(filepath.Join(adc.PoliciesCacheDir(), objectName), lastUpdate, lastUpdate)
+					require.NoError(t, err, "Setup: cannot change policy cache modification time")
+				}
 			}
 
-			entries, err := adc.GetPolicies(context.Background(), objectName, objectClass, krb5CCName)
+			entries, err := adc.GetPolicies(context.Background(), objectName, objectClass, krb5CCName, false)
+			if tc.corruptCache {
+				require.NoFileExists(t, filepath.Join(adc.PoliciesCacheDir(), objectName, "assets.db"), "Corrupted policies cache should be discarded")
+			}
 			if tc.wantErr {
 				require.NotNil(t, err, "GetPolicies should have errored out")
 				return
@@ -743,6 +1111,10 @@ func TestGetPoliciesOffline(t *testing.T) {
 			require.NotEqual(t, 0, len(entries.GPOs), "GetPolicies should return at least one GPO list when not failing")
 
 			assertEqualPolicies(t, initialPolicies, entries, tc.wantAssets)
+
+			if tc.wantStatus != "" {
+				require.Contains(t, adc.GetInfo(context.Background()), tc.wantStatus, "GetInfo should report policies are applied from cache")
+			}
 		})
 	}
 }
@@ -764,6 +1136,8 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 		restart       bool
 		modifyKrb5CC  bool
 		symlinkKrb5CC bool
+		corruptCache  bool
+		purgeCache    bool
 
 		wantErr bool
 	}{
@@ -806,6 +1180,20 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 			userKrb5CCBaseName2: "EMPTY",
 			symlinkKrb5CC:       true,
 		},
+		"Second call with a corrupted policies cache downloads GPOs again": {
+			objectName1:         "bob@ASSETSANDGPO.COM",
+			objectName2:         "bob@ASSETSANDGPO.COM",
+			userKrb5CCBaseName1: "bob",
+			userKrb5CCBaseName2: "EMPTY",
+			corruptCache:        true,
+		},
+		"Second call after purging the policies cache downloads GPOs again": {
+			objectName1:         "bob@ASSETSANDGPO.COM",
+			objectName2:         "bob@ASSETSANDGPO.COM",
+			userKrb5CCBaseName1: "bob",
+			userKrb5CCBaseName2: "EMPTY",
+			purgeCache:          true,
+		},
 
 		// Machine for assets cases
 		"Second machine call is a refresh (without Krb5CCName specified)": {
@@ -856,7 +1244,7 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 			require.NoError(t, err, "Setup: cannot create ad object")
 
 			// First call
-			entries, err := adc.GetPolicies(context.Background(), tc.objectName1, objectClass, krb5CCName)
+			entries, err := adc.GetPolicies(context.Background(), tc.objectName1, objectClass, krb5CCName, false)
 			require.NoError(t, err, "GetPolicies should return no error")
 
 			wantPolicyDir := filepath.Join("testdata", "sysvolcache", strings.ToLower(tc.objectName1))
@@ -879,6 +1267,24 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 				require.NoError(t, err, "Setup: cannot create symlink")
 			}
 
+			policiesCache := filepath.Join(adc.PoliciesCacheDir(), tc.objectName1)
+			if tc.corruptCache {
+				require.NoError(t, entries.Save(policiesCache), "Setup: cannot save policies cache")
+				err = os.WriteFile(filepath.Join(policiesCache, "policies"), []byte("corrupted"), 0600)
+				require.NoError(t, err, "Setup: cannot corrupt policies cache")
+				// Downloaded GPOs are left as is, unless they are downloaded again.
+				err = os.WriteFile(filepath.Join(adc.SysvolCacheDir(), "Policies", "standard", "User", "Registry.pol"), []byte("corrupted"), 0600)
+				require.NoError(t, err, "Setup: cannot corrupt downloaded GPO")
+			}
+			if tc.purgeCache {
+				require.NoError(t, entries.Save(policiesCache), "Setup: cannot save policies cache")
+				require.NoError(t, os.RemoveAll(policiesCache), "Setup: cannot purge policies cache")
+				// Downloaded GPOs are left as is, unless they are downloaded again.
+				err = os.WriteFile(filepath.Join(adc.SysvolCacheDir(), "Policies", "standard", "User", "Registry.pol"), []byte("corrupted"), 0600)
+				require.NoError(t, err, "Setup: cannot corrupt downloaded GPO")
+				adc.RefreshOnNextUpdate(tc.objectName1)
+			}
+
 			// Recreate the ticket if needed or reset it to empty for refresh
 			if tc.userKrb5CCBaseName2 != "" {
 				if tc.userKrb5CCBaseName2 == "EMPTY" {
@@ -897,7 +1303,7 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 			}
 
 			// Second call
-			entries, err = adc.GetPolicies(context.Background(), tc.objectName2, objectClass, krb5CCName)
+			entries, err = adc.GetPolicies(context.Background(), tc.objectName2, objectClass, krb5CCName, false)
 			require.NoError(t, err, "GetPolicies should return no error")
 
 			if tc.modifyKrb5CC {
@@ -910,6 +1316,10 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 			require.NoError(t, err, "Setup: can't stat krb5cc file")
 			require.True(t, stat.Mode().IsRegular(), "krb5cc file should be a regular file")
 
+			if tc.corruptCache {
+				require.NoFileExists(t, filepath.Join(policiesCache, "policies"), "Corrupted policies cache should be discarded")
+			}
+
 			wantPolicyDir = filepath.Join("testdata", "sysvolcache", strings.ToLower(tc.objectName2))
 			if tc.objectName2 == hostname {
 				wantPolicyDir = filepath.Join("testdata", "sysvolcache", "machine")
@@ -921,55 +1331,288 @@ func TestGetPoliciesWorkflows(t *testing.T) {
 	}
 }
 
-func TestGetPoliciesConcurrently(t *testing.T) {
-	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+func TestGetPoliciesRenewsMachineTicket(t *testing.T) {
+	t.Parallel()
 
 	hostname, err := os.Hostname()
-	require.NoError(t, err, "Setup: failed to get hostname")
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
 
 	tests := map[string]struct {
-		objectName1  string
-		objectName2  string
-		objectClass1 ad.ObjectClass
-		objectClass2 ad.ObjectClass
-		gpo1         string
-		gpo2         string
-
-		wantErr bool
+		ticketExpiresIn  time.Duration
+		invalidTicket    bool
+		renewalThreshold time.Duration
+		kinitBehavior    string
+		acquireFails     bool
+		acquireExpired   bool
+
+		wantKinitCalls []string
+		wantAcquired   bool
+		wantErr        bool
 	}{
-		"Same user, same GPO": {
-			objectName1:  "bob@ASSETSANDGPO.COM",
-			objectName2:  "bob@ASSETSANDGPO.COM",
-			objectClass1: ad.UserObject,
-			objectClass2: ad.UserObject,
-			gpo1:         "standard",
-			gpo2:         "standard",
-		},
-		// We can’t run this test currently as the mock will always return the same value for bob (both gpos):
-		// both calls are identical.
-		/*"Same user, different GPOs": {
-		objectName1: "bob@ASSETSANDGPO.COM",
-		objectName2: "bob@ASSETSANDGPO.COM",
-		objectClass1: ad.UserObject,
-		objectClass2: ad.UserObject,
-		gpo1:        "standard",
-		gpo2:        "one-value",
-		},*/
-		"Different users, same GPO": {
-			objectName1:  "bob@ASSETSANDGPO.COM",
-			objectName2:  "sponge@ASSETSANDGPO.COM",
-			objectClass1: ad.UserObject,
-			objectClass2: ad.UserObject,
-			gpo1:         "standard",
-			gpo2:         "standard",
-		},
-		"Different users, different GPO": {
-			objectName1:  "bob@ASSETSANDGPO.COM",
-			objectName2:  "carol@ASSETSANDGPO.COM",
-			objectClass1: ad.UserObject,
-			objectClass2: ad.UserObject,
-			gpo1:         "standard",
-			gpo2:         "one-value",
+		"Valid machine ticket is not renewed":                                     {ticketExpiresIn: time.Hour},
+		"Machine ticket expiring after a custom renewal threshold is not renewed": {ticketExpiresIn: 2 * time.Minute, renewalThreshold: time.Minute},
+		"Unreadable ticket cache is used as is":                                   {invalidTicket: true},
+
+		"Expired machine ticket is renewed":                               {ticketExpiresIn: -time.Hour, wantKinitCalls: []string{"-R -c KRB5CC"}},
+		"Machine ticket expiring within the renewal threshold is renewed": {ticketExpiresIn: 2 * time.Minute, wantKinitCalls: []string{"-R -c KRB5CC"}},
+		"Machine ticket expiring within a custom renewal threshold is renewed": {ticketExpiresIn: 30 * time.Minute, renewalThreshold: time.Hour,
+			wantKinitCalls: []string{"-R -c KRB5CC"}},
+		"Machine ticket is acquired from the backend if it can't be renewed": {ticketExpiresIn: -time.Hour, kinitBehavior: "-RenewFails-",
+			wantKinitCalls: []string{"-R -c KRB5CC"}, wantAcquired: true},
+		"Machine ticket is acquired from the backend if renewal does not extend it": {ticketExpiresIn: -time.Hour, kinitBehavior: "-RenewExpired-",
+			wantKinitCalls: []string{"-R -c KRB5CC"}, wantAcquired: true},
+
+		// Error cases
+		"Error when machine ticket can't be renewed nor acquired": {ticketExpiresIn: -time.Hour, kinitBehavior: "-RenewFails-", acquireFails: true, wantErr: true},
+		"Error when acquired machine ticket is expired":           {ticketExpiresIn: -time.Hour, kinitBehavior: "-RenewFails-", acquireExpired: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			hostKrb5CC := filepath.Join(t.TempDir(), "host_krb5cc")
+			if tc.invalidTicket {
+				require.NoError(t, os.WriteFile(hostKrb5CC, []byte("KRB5 Ticket file content"), 0600), "Setup: can't write host ticket")
+			} else {
+				writeCCache(t, hostKrb5CC, time.Now().Add(tc.ticketExpiresIn))
+			}
+
+			// The first call to the backend gives the current ticket, the other ones acquire a new one.
+			var backendCalls atomic.Int32
+			acquire := func(path string) error {
+				if backendCalls.Add(1) == 1 {
+					return nil
+				}
+				if tc.acquireFails {
+					return errors.New("machine ticket acquisition failure requested in mock")
+				}
+				endTime := time.Now().Add(10 * time.Hour)
+				if tc.acquireExpired {
+					endTime = time.Now().Add(-time.Minute)
+				}
+				return writeCCacheFile(path, endTime)
+			}
+
+			kinitCallsFile := filepath.Join(t.TempDir(), "kinit-calls")
+			kinitCmd := []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockKinit", "--", kinitCallsFile, tc.kinitBehavior}
+
+			opts := []ad.Option{ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+				ad.WithGPOListCmd(mockGPOListCmd(t, "example.com", "nobody:standard")), ad.WithKinitCmd(kinitCmd)}
+			if tc.renewalThreshold != 0 {
+				opts = append(opts, ad.WithTicketRenewalThreshold(tc.renewalThreshold))
+			}
+			adc, err := ad.New(context.Background(),
+				mock.Backend{Dom: "example.com", ServURL: "myserver.example.com", Online: true, HostKrb5CCNamePath: hostKrb5CC, AcquireHostKrb5CC: acquire},
+				hostname, opts...)
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			_, err = adc.GetPolicies(context.Background(), hostname, ad.ComputerObject, "", false)
+
+			var gotKinitCalls []string
+			if d, err := os.ReadFile(kinitCallsFile); err == nil {
+				krb5CCPath := filepath.Join(adc.Krb5CacheDir(), hostname)
+				gotKinitCalls = strings.Split(strings.TrimSpace(strings.ReplaceAll(string(d), krb5CCPath, "KRB5CC")), "\n")
+			}
+
+			if tc.wantErr {
+				require.Error(t, err, "GetPolicies should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "GetPolicies should return no error but got one")
+			require.Equal(t, tc.wantKinitCalls, gotKinitCalls, "kinit should be called to renew the machine ticket when needed")
+			require.Equal(t, tc.wantAcquired, backendCalls.Load() > 1, "machine ticket should be acquired from the backend when it can't be renewed")
+		})
+	}
+}
+
+func TestGetPoliciesFromLocalSource(t *testing.T) {
+	t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		sysvol      string
+		manifest    string
+		objectClass ad.ObjectClass
+		loopback    ad.LoopbackMode
+
+		want             policies.Policies
+		wantAssetsEquals string
+		wantErr          bool
+	}{
+		"User policies are applied from the manifest": {
+			manifest: "user:\n  - {id: standard, name: standard-name}\n",
+			want:     policies.Policies{GPOs: []policies.GPO{standardUserGPO("standard")}},
+		},
+		"Computer policies are applied from the manifest": {
+			manifest:    "computer:\n  - {id: standard, name: standard-name}\n",
+			objectClass: ad.ComputerObject,
+			want:        policies.Policies{GPOs: []policies.GPO{standardComputerGPO("standard")}},
+		},
+		"GPOs are applied in manifest order": {
+			manifest: "user:\n  - {id: one-value, name: one-value-name}\n  - {id: standard, name: standard-name}\n",
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "one-value", Name: "one-value-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "C", Value: "oneValueC"},
+					}}},
+				standardUserGPO("standard"),
+			}},
+		},
+		"GPO name defaults to its ID and enforced flag is kept": {
+			manifest: "user:\n  - {id: standard, enforced: true}\n",
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "standard", Name: "standard", Enforced: true, Rules: standardUserGPO("standard").Rules},
+			}},
+		},
+		"Object with no GPO in the manifest has no policies": {
+			manifest: "computer:\n  - {id: standard, name: standard-name}\n",
+		},
+		"Assets are copied from the directory": {
+			sysvol:           "assetsandgpo.com",
+			manifest:         "user:\n  - {id: standard, name: standard-name}\n",
+			want:             policies.Policies{GPOs: []policies.GPO{standardUserGPO("standard")}},
+			wantAssetsEquals: "testdata/AD/SYSVOL/assetsandgpo.com/Ubuntu",
+		},
+
+		// Loopback processing cases
+		"Loopback processing in merge mode, computer GPOs take precedence over user ones": {
+			manifest: "computer:\n  - {id: user-only, name: user-only-name}\nuser:\n  - {id: standard, name: standard-name}\n",
+			loopback: ad.LoopbackMerge,
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "user-only", Name: "user-only-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "A", Value: "userOnlyA"},
+						{Key: "B", Value: "userOnlyB"},
+					}}},
+				standardUserGPO("standard"),
+			}},
+		},
+		"Loopback processing in replace mode, only computer GPOs apply": {
+			manifest: "computer:\n  - {id: user-only, name: user-only-name}\nuser:\n  - {id: standard, name: standard-name}\n",
+			loopback: ad.LoopbackReplace,
+			want: policies.Policies{GPOs: []policies.GPO{
+				{ID: "user-only", Name: "user-only-name", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: "A", Value: "userOnlyA"},
+						{Key: "B", Value: "userOnlyB"},
+					}}},
+			}},
+		},
+
+		// Error cases
+		"Error on missing manifest":         {manifest: "-", wantErr: true},
+		"Error on invalid manifest":         {manifest: "user: [\n", wantErr: true},
+		"Error on GPO missing in directory": {manifest: "user:\n  - {id: doesnotexist}\n", wantErr: true},
+		"Error on GPO without GPT.INI":      {manifest: "user:\n  - {id: no-gpt-ini}\n", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.sysvol == "" {
+				tc.sysvol = "gpoonly.com"
+			}
+			objectName := "bob@GPOONLY.COM"
+			if tc.objectClass == "" {
+				tc.objectClass = ad.UserObject
+			}
+			if tc.objectClass == ad.ComputerObject {
+				objectName = hostname
+			}
+
+			dir := filepath.Join(t.TempDir(), "sysvol")
+			testutils.Copy(t, filepath.Join("testdata", "AD", "SYSVOL", tc.sysvol), dir)
+			if tc.manifest != "-" {
+				testutils.WriteFile(t, filepath.Join(dir, local.ManifestName), []byte(tc.manifest), 0600)
+			}
+
+			backend, err := local.New(context.Background(), local.Config{Directory: dir, Domain: "gpoonly.com"})
+			require.NoError(t, err, "Setup: cannot create local backend")
+
+			// No GPO list command, Kerberos ticket nor SYSVOL connection is used with a local source.
+			adc, err := ad.New(context.Background(), backend, hostname,
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()),
+				ad.WithGPOListCmd([]string{"false"}),
+				ad.WithLoopbackProcessing(tc.loopback))
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			entries, err := adc.GetPolicies(context.Background(), objectName, tc.objectClass, "", false)
+			if tc.wantErr {
+				require.Error(t, err, "GetPolicies should have errored out")
+				return
+			}
+			require.NoError(t, err, "GetPolicies should return no error")
+
+			// Compare GPOs
+			require.Equal(t, tc.want.GPOs, entries.GPOs, "GetPolicies returns expected GPO entries in correct order")
+
+			// Compare assets
+			uncompressedAssets := t.TempDir()
+			require.NoError(t, os.RemoveAll(uncompressedAssets), "Teardown: can’t remove uncompressed assets directory for saving assets")
+			err = entries.SaveAssetsTo(context.Background(), ".", uncompressedAssets, -1, -1)
+			if tc.wantAssetsEquals == "" {
+				require.Error(t, err, "Teardown: policies should have no assets to uncompress")
+				return
+			}
+			require.NoError(t, err, "Teardown: SaveAssetsTo should deserialize successfully.")
+			testutils.CompareTreesWithFiltering(t, uncompressedAssets, tc.wantAssetsEquals, false)
+		})
+	}
+}
+
+func TestGetPoliciesConcurrently(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		objectName1  string
+		objectName2  string
+		objectClass1 ad.ObjectClass
+		objectClass2 ad.ObjectClass
+		gpo1         string
+		gpo2         string
+
+		wantErr bool
+	}{
+		"Same user, same GPO": {
+			objectName1:  "bob@ASSETSANDGPO.COM",
+			objectName2:  "bob@ASSETSANDGPO.COM",
+			objectClass1: ad.UserObject,
+			objectClass2: ad.UserObject,
+			gpo1:         "standard",
+			gpo2:         "standard",
+		},
+		// We can’t run this test currently as the mock will always return the same value for bob (both gpos):
+		// both calls are identical.
+		/*"Same user, different GPOs": {
+		objectName1: "bob@ASSETSANDGPO.COM",
+		objectName2: "bob@ASSETSANDGPO.COM",
+		objectClass1: ad.UserObject,
+		objectClass2: ad.UserObject,
+		gpo1:        "standard",
+		gpo2:        "one-value",
+		},*/
+		"Different users, same GPO": {
+			objectName1:  "bob@ASSETSANDGPO.COM",
+			objectName2:  "sponge@ASSETSANDGPO.COM",
+			objectClass1: ad.UserObject,
+			objectClass2: ad.UserObject,
+			gpo1:         "standard",
+			gpo2:         "standard",
+		},
+		"Different users, different GPO": {
+			objectName1:  "bob@ASSETSANDGPO.COM",
+			objectName2:  "carol@ASSETSANDGPO.COM",
+			objectClass1: ad.UserObject,
+			objectClass2: ad.UserObject,
+			gpo1:         "standard",
+			gpo2:         "one-value",
 		},
 
 		// Machines and assets cases
@@ -1030,7 +1673,7 @@ func TestGetPoliciesConcurrently(t *testing.T) {
 			wg.Add(2)
 			go func() {
 				defer wg.Done()
-				got1, err := adc.GetPolicies(context.Background(), tc.objectName1, tc.objectClass1, krb5CCName1)
+				got1, err := adc.GetPolicies(context.Background(), tc.objectName1, tc.objectClass1, krb5CCName1, false)
 				require.NoError(t, err, "GetPolicies should return no error")
 
 				wantPolicyDir := filepath.Join("testdata", "sysvolcache", strings.ToLower(tc.objectName1))
@@ -1043,7 +1686,7 @@ func TestGetPoliciesConcurrently(t *testing.T) {
 			}()
 			go func() {
 				defer wg.Done()
-				got2, err := adc.GetPolicies(context.Background(), tc.objectName2, tc.objectClass2, krb5CCName2)
+				got2, err := adc.GetPolicies(context.Background(), tc.objectName2, tc.objectClass2, krb5CCName2, false)
 				require.NoError(t, err, "GetPolicies should return no error")
 
 				wantPolicyDir := filepath.Join("testdata", "sysvolcache", strings.ToLower(tc.objectName2))
@@ -1059,6 +1702,105 @@ func TestGetPoliciesConcurrently(t *testing.T) {
 	}
 }
 
+func TestGetUsersPolicies(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		users         []string
+		gpoListMeta   string
+		withoutTicket []string
+
+		// wantDownloads is the number of GPOs and assets checked against SYSVOL.
+		wantDownloads int32
+		wantErrFor    []string
+	}{
+		"Users sharing GPOs fetch them once": {
+			users:         []string{"bob@ASSETSANDGPO.COM", "sponge@ASSETSANDGPO.COM"},
+			gpoListMeta:   "bob:standard::sponge:standard",
+			wantDownloads: 2,
+		},
+		"Users with different GPOs share assets": {
+			users:         []string{"bob@ASSETSANDGPO.COM", "carol@ASSETSANDGPO.COM"},
+			gpoListMeta:   "bob:standard::carol:one-value",
+			wantDownloads: 3,
+		},
+		"Users sharing some GPOs fetch each of them once": {
+			users:         []string{"bob@ASSETSANDGPO.COM", "sponge@ASSETSANDGPO.COM", "carol@ASSETSANDGPO.COM"},
+			gpoListMeta:   "bob:standard::sponge:standard::carol:one-value",
+			wantDownloads: 3,
+		},
+		"No users fetch nothing": {wantDownloads: 0},
+
+		// Error cases
+		"Error on a user without ticket does not prevent the others": {
+			users:         []string{"bob@ASSETSANDGPO.COM", "carol@ASSETSANDGPO.COM", "sponge@ASSETSANDGPO.COM"},
+			gpoListMeta:   "bob:standard::sponge:standard::carol:one-value",
+			withoutTicket: []string{"carol@ASSETSANDGPO.COM"},
+			wantDownloads: 2,
+			wantErrFor:    []string{"carol@ASSETSANDGPO.COM"},
+		},
+		"Error on invalid user name does not prevent the others": {
+			users:         []string{"bob", "sponge@ASSETSANDGPO.COM"},
+			gpoListMeta:   "bob:standard::sponge:standard",
+			wantDownloads: 2,
+			wantErrFor:    []string{"bob"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+			cachedir, rundir := t.TempDir(), t.TempDir()
+
+			backend := mock.Backend{
+				Dom:                "assetsandgpo.com",
+				ServURL:            "UNUSED:1636",
+				HostKrb5CCNamePath: filepath.Join(t.TempDir(), "host_ccache"),
+				Online:             true,
+			}
+			testutils.CreatePath(t, backend.HostKrb5CCNamePath)
+
+			var downloads atomic.Int32
+			adc, err := ad.New(context.Background(), backend, hostname,
+				ad.WithCacheDir(cachedir), ad.WithRunDir(rundir), ad.WithoutKerberos(),
+				ad.WithGPOListCmd(mockGPOListCmd(t, "assetsandgpo.com", tc.gpoListMeta)),
+				ad.WithDownloadHook(func() { downloads.Add(1) }))
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			// Users are updated with the tickets cached on their login.
+			trackingDir := filepath.Join(adc.Krb5CacheDir(), "tracking")
+			require.NoError(t, os.MkdirAll(trackingDir, 0700), "Setup: can't create ticket tracking directory")
+			for _, user := range tc.users {
+				if slices.Contains(tc.withoutTicket, user) {
+					continue
+				}
+				require.NoError(t, os.Symlink(setKrb5CC(t, user), filepath.Join(trackingDir, user)), "Setup: can't track user ticket")
+			}
+
+			got := adc.GetUsersPolicies(context.Background(), tc.users, false)
+
+			require.Len(t, got, len(tc.users), "GetUsersPolicies should return a result per user")
+			for i, r := range got {
+				require.Equal(t, tc.users[i], r.User, "GetUsersPolicies should return results in the users order")
+				if slices.Contains(tc.wantErrFor, r.User) {
+					require.Error(t, r.Err, "GetUsersPolicies should have failed for %q but didn't", r.User)
+					continue
+				}
+				require.NoError(t, r.Err, "GetUsersPolicies should not fail for %q", r.User)
+
+				want, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "sysvolcache", strings.ToLower(r.User)))
+				require.NoError(t, err, "Setup: can't load wanted policies")
+				assertEqualPolicies(t, want, r.Policies, false)
+			}
+			require.Equal(t, tc.wantDownloads, downloads.Load(), "GPOs and assets shared by users should be fetched once")
+		})
+	}
+}
+
 func TestListUsers(t *testing.T) {
 	t.Parallel()
 
@@ -1202,6 +1944,123 @@ func TestListUsers(t *testing.T) {
 	}
 }
 
+func TestListGPOs(t *testing.T) {
+	t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	gpoLink := func(name string, enforced, blocked bool) ad.GPOLink {
+		return ad.GPOLink{
+			Name:               name + "-name",
+			ID:                 name,
+			URL:                fmt.Sprintf("smb://localhost:%d/SYSVOL/example.com/Policies/%s", ad.SmbPort, name),
+			LinkPath:           fmt.Sprintf("OU=%s,DC=example,DC=com", name),
+			Enforced:           enforced,
+			InheritanceBlocked: blocked,
+		}
+	}
+
+	tests := map[string]struct {
+		objectName        string
+		objectClass       ad.ObjectClass
+		gpoListArgs       []string
+		backend           mock.Backend
+		withoutUserKrb5CC bool
+
+		want    []ad.GPOLink
+		wantErr bool
+	}{
+		"List user GPOs in resolved order": {
+			gpoListArgs: []string{"example.com", "bob:one+enforced::alice:other::bob:two::bob:three+blocked"},
+			want:        []ad.GPOLink{gpoLink("one", true, false), gpoLink("two", false, false), gpoLink("three", false, true)},
+		},
+		"List machine GPOs": {
+			objectName:  hostname,
+			objectClass: ad.ComputerObject,
+			gpoListArgs: []string{"example.com", fmt.Sprintf("%s:machine::bob:one", hostname)},
+			want:        []ad.GPOLink{gpoLink("machine", false, false)},
+		},
+		"Object without GPOs": {
+			gpoListArgs: []string{"example.com", "alice:other"},
+		},
+
+		// Error cases
+		"Error on machine offline": {
+			backend: mock.Backend{Dom: "example.com", ServURL: "myserver.example.com", Online: false},
+			wantErr: true,
+		},
+		"Error on IsOnline failing": {
+			backend: mock.Backend{Dom: "example.com", ServURL: "myserver.example.com", ErrIsOnline: true},
+			wantErr: true,
+		},
+		"Error on no active server": {
+			backend: mock.Backend{Dom: "example.com", Online: true, ErrServerFQDN: backends.ErrNoActiveServer},
+			wantErr: true,
+		},
+		"Error on domain controller unreachable": {
+			gpoListArgs: []string{"-Exit2-"},
+			wantErr:     true,
+		},
+		"Error on GPO list failing": {
+			gpoListArgs: []string{"-Exit1-"},
+			wantErr:     true,
+		},
+		"Error on user without ticket": {
+			withoutUserKrb5CC: true,
+			wantErr:           true,
+		},
+		"Error on computer which is not current host": {
+			objectName:  "othermachine",
+			objectClass: ad.ComputerObject,
+			wantErr:     true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.objectName == "" {
+				tc.objectName = "bob@EXAMPLE.COM"
+			}
+			if tc.objectClass == "" {
+				tc.objectClass = ad.UserObject
+			}
+			if tc.backend.Dom == "" {
+				tc.backend = mock.Backend{Dom: "example.com", ServURL: "myserver.example.com", Online: true}
+			}
+			if tc.gpoListArgs == nil {
+				tc.gpoListArgs = []string{"example.com", "bob:one"}
+			}
+			tc.backend.HostKrb5CCNamePath = setKrb5CC(t, "host")
+
+			var krb5CC string
+			if tc.objectClass == ad.UserObject && !tc.withoutUserKrb5CC {
+				krb5CC = setKrb5CC(t, "bob")
+			}
+
+			adc, err := ad.New(context.Background(), tc.backend, hostname,
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithoutKerberos(),
+				ad.WithGPOListCmd(mockGPOListCmd(t, tc.gpoListArgs...)))
+			require.NoError(t, err, "Setup: cannot create ad object")
+
+			got, err := adc.ListGPOs(context.Background(), tc.objectName, tc.objectClass, krb5CC)
+			if tc.wantErr {
+				require.Error(t, err, "ListGPOs should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "ListGPOs should return no error but got one")
+			require.Equal(t, tc.want, got, "ListGPOs should return the GPOs in resolved order")
+
+			// Nothing is downloaded
+			entries, err := os.ReadDir(filepath.Join(adc.SysvolCacheDir(), "Policies"))
+			require.NoError(t, err, "Setup: can't read sysvol cache directory")
+			require.Empty(t, entries, "ListGPOs should not download any GPO")
+		})
+	}
+}
+
 func TestGetInfo(t *testing.T) {
 	t.Parallel()
 
@@ -1209,12 +2068,15 @@ func TestGetInfo(t *testing.T) {
 	require.NoError(t, err, "Setup: failed to get hostname for tests.")
 
 	tests := map[string]struct {
-		online        bool
-		errIsOnline   bool
-		ErrServerFQDN error
+		online          bool
+		errIsOnline     bool
+		ErrServerFQDN   error
+		preferredServer string
+		site            string
 	}{
-		"Info reported from backend, online":  {online: true},
-		"Info reported from backend, offline": {online: false},
+		"Info reported from backend, online":     {online: true},
+		"Info reported from backend, offline":    {online: false},
+		"Info reports preferred server and site": {online: true, preferredServer: "dc1.example.com", site: "Paris"},
 
 		"Report unknown state if IsOnline calls fail": {errIsOnline: true},
 		// This error is skipped by New(), but not by GetInfo
@@ -1231,7 +2093,8 @@ func TestGetInfo(t *testing.T) {
 					Online:      tc.online,
 					ErrIsOnline: tc.errIsOnline, ErrServerFQDN: tc.ErrServerFQDN},
 				hostname,
-				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()))
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()),
+				ad.WithPreferredServer(tc.preferredServer), ad.WithSite(tc.site))
 			require.NoError(t, err, "Setup: New should return no error")
 
 			msg := adc.GetInfo(context.Background())
@@ -1241,6 +2104,84 @@ func TestGetInfo(t *testing.T) {
 	}
 }
 
+func TestServerFQDN(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		preferredServer string
+		site            string
+		siteServers     []string
+		errSiteLookup   bool
+		errServerFQDN   error
+		reachable       []string
+
+		want    string
+		wantErr bool
+	}{
+		"No preference uses backend server": {want: "backend.example.com"},
+
+		// Preferred server
+		"Preferred server is used when reachable":                {preferredServer: "dc1.example.com", reachable: []string{"dc1.example.com"}, want: "dc1.example.com"},
+		"Preferred server with ldap prefix is used":              {preferredServer: "ldap://dc1.example.com", reachable: []string{"dc1.example.com"}, want: "dc1.example.com"},
+		"Preferred server unreachable falls back to backend":     {preferredServer: "dc1.example.com", want: "backend.example.com"},
+		"Preferred server unreachable falls back to site server": {preferredServer: "dc1.example.com", site: "Paris", siteServers: []string{"dc2.example.com."}, reachable: []string{"dc2.example.com"}, want: "dc2.example.com"},
+		"Preferred server is used before site servers": {preferredServer: "dc1.example.com", site: "Paris", siteServers: []string{"dc2.example.com."},
+			reachable: []string{"dc1.example.com", "dc2.example.com"}, want: "dc1.example.com"},
+
+		// Site
+		"First site server is used when reachable":       {site: "Paris", siteServers: []string{"dc2.example.com.", "dc3.example.com."}, reachable: []string{"dc2.example.com", "dc3.example.com"}, want: "dc2.example.com"},
+		"Next site server is used when first is down":    {site: "Paris", siteServers: []string{"dc2.example.com.", "dc3.example.com."}, reachable: []string{"dc3.example.com"}, want: "dc3.example.com"},
+		"No site server reachable falls back to backend": {site: "Paris", siteServers: []string{"dc2.example.com."}, want: "backend.example.com"},
+		"Site without servers falls back to backend":     {site: "Paris", want: "backend.example.com"},
+		"Site lookup failing falls back to backend":      {site: "Paris", errSiteLookup: true, want: "backend.example.com"},
+
+		// Error cases
+		"Error when falling back on backend without active server": {preferredServer: "dc1.example.com", errServerFQDN: backends.ErrNoActiveServer, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			hostname, err := os.Hostname()
+			require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+			lookupSRV := func(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+				require.Equal(t, "ldap", service, "SRV lookup should be done for LDAP service")
+				require.Equal(t, "tcp", proto, "SRV lookup should be done for TCP protocol")
+				require.Equal(t, tc.site+"._sites.dc._msdcs.example.com", name, "SRV lookup should be done for site domain controllers")
+				if tc.errSiteLookup {
+					return "", nil, errors.New("SRV lookup failed")
+				}
+				var records []*net.SRV
+				for _, s := range tc.siteServers {
+					records = append(records, &net.SRV{Target: s, Port: 389})
+				}
+				return "", records, nil
+			}
+			checkServer := func(_ context.Context, server string) bool {
+				return slices.Contains(tc.reachable, server)
+			}
+
+			adc, err := ad.New(context.Background(),
+				mock.Backend{Dom: "example.com", ServURL: "backend.example.com", Online: true, ErrServerFQDN: tc.errServerFQDN},
+				hostname,
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()),
+				ad.WithPreferredServer(tc.preferredServer), ad.WithSite(tc.site),
+				ad.WithServerCheck(checkServer), ad.WithSRVLookup(lookupSRV))
+			require.NoError(t, err, "Setup: New should return no error")
+
+			got, err := adc.ServerFQDN(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "ServerFQDN should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "ServerFQDN should return no error but got one")
+			require.Equal(t, tc.want, got, "ServerFQDN should return the expected domain controller")
+		})
+	}
+}
+
 func TestNormalizeTargetName(t *testing.T) {
 	t.Parallel()
 
@@ -1298,6 +2239,75 @@ func TestNormalizeTargetName(t *testing.T) {
 	}
 }
 
+func TestParseLocalGPOs(t *testing.T) {
+	t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		gpos         []string
+		objectClass  ad.ObjectClass
+		machineFacts filter.Facts
+
+		want    []policies.GPO
+		wantErr bool
+	}{
+		"Standard policy, user object":     {gpos: []string{"standard"}, want: []policies.GPO{standardUserGPO("standard")}},
+		"Standard policy, computer object": {gpos: []string{"standard"}, objectClass: ad.ComputerObject, want: []policies.GPO{standardComputerGPO("standard")}},
+		"Multiple GPOs keep their order": {gpos: []string{"one-value", "standard"}, want: []policies.GPO{
+			{ID: "one-value", Name: "one-value-name", Rules: map[string][]entry.Entry{
+				"dconf": {{Key: "C", Value: "oneValueC"}}}},
+			standardUserGPO("standard"),
+		}},
+		"User only policy, computer object, policy is empty": {gpos: []string{"user-only"}, objectClass: ad.ComputerObject,
+			want: []policies.GPO{{ID: "user-only", Name: "user-only-name", Rules: make(map[string][]entry.Entry)}}},
+		"Machine not matching GPO filter, user object, policy is empty": {gpos: []string{"machine-filter"}, machineFacts: filter.Facts{"hostname": "lab-1", "os.version_id": "20.04"},
+			want: []policies.GPO{{ID: "machine-filter", Name: "machine-filter-name", Rules: make(map[string][]entry.Entry)}}},
+		"No GPO": {},
+
+		// Error cases
+		"Error on corrupted policy": {gpos: []string{"corrupted-policy"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.objectClass == "" {
+				tc.objectClass = ad.UserObject
+			}
+
+			adc, err := ad.New(context.Background(), mock.Backend{Dom: "gpoonly.com", ServURL: "myserver.gpoonly.com"}, hostname,
+				ad.WithCacheDir(t.TempDir()), ad.WithRunDir(t.TempDir()), ad.WithMachineFacts(tc.machineFacts))
+			require.NoError(t, err, "Setup: New should return no error")
+
+			var gpos []ad.LocalGPO
+			for _, n := range tc.gpos {
+				gpoDir := filepath.Join("testdata", "AD", "SYSVOL", "gpoonly.com", "Policies", n)
+				gpo := ad.LocalGPO{Name: n + "-name"}
+				// Missing registry files are left empty.
+				gpo.MachineRegistry, _ = os.ReadFile(filepath.Join(gpoDir, "Machine", "Registry.pol"))
+				gpo.UserRegistry, _ = os.ReadFile(filepath.Join(gpoDir, "User", "Registry.pol"))
+				gpos = append(gpos, gpo)
+			}
+
+			got, err := adc.ParseLocalGPOs(context.Background(), gpos, tc.objectClass)
+			if tc.wantErr {
+				require.Error(t, err, "ParseLocalGPOs should return an error and didn't")
+				return
+			}
+			require.NoError(t, err, "ParseLocalGPOs should return no error")
+
+			// Local GPOs are identified by their name only.
+			for i := range tc.want {
+				tc.want[i].ID = tc.want[i].Name
+			}
+			assert.Equal(t, tc.want, got.GPOs, "ParseLocalGPOs should return the expected GPOs")
+		})
+	}
+}
+
 func TestMockGPOList(_ *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
@@ -1329,6 +2339,29 @@ func TestMockGPOList(_ *testing.T) {
 		fmt.Fprint(os.Stderr, "Error during gpo list requested with exit 2")
 		os.Exit(2)
 	}
+	// simulating account not found with Exit 1
+	if args[0] == "-Exit1-" {
+		fmt.Fprint(os.Stderr, "Error during gpo list requested with exit 1")
+		os.Exit(1)
+	}
+	// simulating a SYSVOL share on which nothing listens
+	smbPort := ad.SmbPort
+	if args[0] == "-UnreachableSysvol-" {
+		smbPort = 1
+		args = args[1:]
+	}
+
+	// Print the details of GPO links if requested
+	details := slices.Contains(args, "--details")
+
+	// GPOs with the configuration of the object class, or the requested one, disabled are skipped
+	var configuration string
+	if i := slices.Index(args, "--objectclass"); i != -1 {
+		configuration = args[i+1]
+	}
+	if i := slices.Index(args, "--configuration"); i != -1 {
+		configuration = args[i+1]
+	}
 
 	// Get Domain
 	domain := args[0]
@@ -1349,7 +2382,24 @@ func TestMockGPOList(_ *testing.T) {
 	}
 
 	for _, gpo := range gpos {
-		fmt.Fprintf(os.Stdout, "%s-name\tsmb://localhost:%d/SYSVOL/%s/Policies/%s\n", gpo, ad.SmbPort, domain, gpo)
+		// GPOs can be flagged as in "GPO+enforced+blocked+userdisabled"
+		gpo, flags, _ := strings.Cut(gpo, "+")
+		if strings.Contains(flags, configuration+"disabled") {
+			continue
+		}
+		url := fmt.Sprintf("smb://localhost:%d/SYSVOL/%s/Policies/%s", smbPort, domain, gpo)
+		if !details {
+			fmt.Fprintf(os.Stdout, "%s-name\t%s\n", gpo, url)
+			continue
+		}
+		var enforced, blocked string
+		if strings.Contains(flags, "enforced") {
+			enforced = "enforced"
+		}
+		if strings.Contains(flags, "blocked") {
+			blocked = "blocked"
+		}
+		fmt.Fprintf(os.Stdout, "%s-name\t%s\t%s\tOU=%s,DC=%s\t%s\n", gpo, url, enforced, gpo, strings.ReplaceAll(domain, ".", ",DC="), blocked)
 	}
 }
 
@@ -1361,6 +2411,80 @@ func mockGPOListCmd(t *testing.T, args ...string) []string {
 	return cmdArgs
 }
 
+func TestMockKinit(_ *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	var callsFile, behavior string
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] == "--" {
+			callsFile, behavior = args[1], args[2]
+			args = args[3:]
+			break
+		}
+		args = args[1:]
+	}
+
+	f, err := os.OpenFile(callsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Setup: failed to open kinit calls file: %v", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	fmt.Fprintln(f, strings.Join(args, " "))
+
+	if behavior == "-RenewFails-" {
+		fmt.Fprint(os.Stderr, "kinit failure requested in mock")
+		os.Exit(1)
+	}
+
+	endTime := time.Now().Add(10 * time.Hour)
+	if behavior == "-RenewExpired-" {
+		endTime = time.Now().Add(-time.Minute)
+	}
+	if err := writeCCacheFile(args[len(args)-1], endTime); err != nil {
+		fmt.Fprintf(os.Stderr, "Setup: failed to write ticket cache: %v", err)
+		os.Exit(1)
+	}
+}
+
+// writeCCache writes a file ticket cache at path with a ticket granting ticket expiring at endTime.
+func writeCCache(t *testing.T, path string, endTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, writeCCacheFile(path, endTime), "Setup: failed to write ticket cache")
+}
+
+// ccacheTimes are the offsets of the end times of the credentials in the version 4 ticket cache of
+// testdata/TestGetPoliciesRenewsMachineTicket/krb5cc, with their recorded value.
+// The cache holds configuration entries, a ticket granting ticket and a service ticket for HOST$@EXAMPLE.COM, with
+// aes256 session keys.
+var ccacheTimes = struct {
+	tgtOffset, serviceOffset int
+	endTime                  uint32
+}{tgtOffset: 349, serviceOffset: 1705, endTime: 1760461200}
+
+// writeCCacheFile writes the reference ticket cache at path, with its ticket granting ticket expiring at endTime and
+// its service ticket outliving it.
+func writeCCacheFile(path string, endTime time.Time) error {
+	d, err := os.ReadFile(filepath.Join("testdata", "TestGetPoliciesRenewsMachineTicket", "krb5cc"))
+	if err != nil {
+		return err
+	}
+
+	for offset, t := range map[int]time.Time{ccacheTimes.tgtOffset: endTime, ccacheTimes.serviceOffset: endTime.Add(time.Hour)} {
+		if got := binary.BigEndian.Uint32(d[offset:]); got != ccacheTimes.endTime {
+			return fmt.Errorf("unexpected end time %d at offset %d of reference ticket cache", got, offset)
+		}
+		binary.BigEndian.PutUint32(d[offset:], uint32(t.Unix()))
+	}
+
+	return os.WriteFile(path, d, 0600)
+}
+
 // setKrb5CC create a temporary file for a KRB5 ticket.
 // It will be automatically purged when the test ends.
 func setKrb5CC(t *testing.T, ccRootName string) string {
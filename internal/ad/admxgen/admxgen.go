@@ -9,6 +9,8 @@ Package admxgen generates admx and adml from a category and multiple policies pe
 		  including any supported release information for a given policy. It can also adjust the default value information if it
 		  differs between releases.
 		- Finally, we are taking this expandedCategories object and outputing the administrative template from it.
+		  The ADMX is checked against the subset of the PolicyDefinitions schema covering the elements we generate
+		  before being written: this is not a full validation against the PolicyDefinitions XSD.
 
 
 	    categories.yaml --------------------------------------------|
@@ -44,6 +46,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"text/template"
@@ -458,7 +461,10 @@ func (g generator) toID(key string, s ...string) string {
 	return r + re.ReplaceAllString(cases.Title(language.Und, cases.NoLower).String(key), "")
 }
 
-func (g generator) expandedCategoriesToADMX(expandedCategories []expandedCategory, dest string) (err error) {
+// expandedCategoriesToADMX generates the ADMX and the base ADML in dest. An ADML is generated too in the locale
+// subdirectory of dest for each locale of translations, which maps string IDs to their translated strings.
+// Strings without translation fall back to the base ones.
+func (g generator) expandedCategoriesToADMX(expandedCategories []expandedCategory, dest string, translations map[string]map[string]string) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't generate ADMX files"))
 
 	var inputCategories []categoryForADMX
@@ -479,39 +485,105 @@ func (g generator) expandedCategoriesToADMX(expandedCategories []expandedCategor
 		return errors.New(gotext.Get("can't create destination directory for AD policies: %v", err))
 	}
 
-	funcMap := template.FuncMap{
-		"toID": g.toID,
-	}
-
 	// Create admx
 
+	var admx strings.Builder
+	t := template.Must(template.New("admx.template").Funcs(template.FuncMap{"toID": g.toID}).Parse(admxTemplate))
+	if err := t.Execute(&admx, input); err != nil {
+		return err
+	}
+	// GPMC rejects the whole file on schema violation: don't write it. Only the part of the schema covering the
+	// elements we generate is checked.
+	if err := validateADMX([]byte(admx.String())); err != nil {
+		return errors.New(gotext.Get("generated admx does not match the PolicyDefinitions schema:\n%v", err))
+	}
 	f, err := os.Create(filepath.Join(dest, g.distroID+".admx"))
 	if err != nil {
 		return errors.New(gotext.Get("can't create admx file: %v", err))
 	}
 	defer decorate.LogFuncOnError(f.Close)
-	t := template.Must(template.New("admx.template").Funcs(funcMap).Parse(admxTemplate))
-	err = t.Execute(f, input)
-	if err != nil {
+	if _, err := f.WriteString(admx.String()); err != nil {
 		return err
 	}
 
-	// Create adml
+	// Create base adml, collecting the strings it defines
 
-	f, err = os.Create(filepath.Join(dest, g.distroID+".adml"))
-	if err != nil {
-		return errors.New(gotext.Get("can't create admx file: %v", err))
-	}
-	defer decorate.LogFuncOnError(f.Close)
-	t = template.Must(template.New("adml.template").Funcs(funcMap).Parse(admlTemplate))
-	err = t.Execute(f, input)
-	if err != nil {
+	baseStrings := make(map[string]string)
+	if err := g.writeADML(filepath.Join(dest, g.distroID+".adml"), input, func(id, base string) string {
+		baseStrings[id] = base
+		return base
+	}); err != nil {
 		return err
 	}
 
+	for _, m := range admxStringRefRe.FindAllStringSubmatch(admx.String(), -1) {
+		if _, ok := baseStrings[m[1]]; !ok {
+			return errors.New(gotext.Get("string %q referenced by the admx is not defined in the adml", m[1]))
+		}
+	}
+
+	// Create localized adml
+
+	locales := make([]string, 0, len(translations))
+	for locale := range translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		var ids []string
+		for id := range translations[locale] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			if _, ok := baseStrings[id]; !ok {
+				return errors.New(gotext.Get("translation for %s references unknown string %q", locale, id))
+			}
+		}
+
+		var untranslated []string
+		tr := func(id, base string) string {
+			if s := translations[locale][id]; s != "" {
+				return s
+			}
+			if !slices.Contains(untranslated, id) {
+				untranslated = append(untranslated, id)
+			}
+			return base
+		}
+		if err := os.MkdirAll(filepath.Join(dest, locale), 0750); err != nil {
+			return errors.New(gotext.Get("can't create destination directory for %s adml: %v", locale, err))
+		}
+		if err := g.writeADML(filepath.Join(dest, locale, g.distroID+".adml"), input, tr); err != nil {
+			return err
+		}
+		if len(untranslated) > 0 {
+			log.Warningf(context.Background(), "%d strings are not translated for %s, using the base ones: %s", len(untranslated), locale, strings.Join(untranslated, ", "))
+		}
+	}
+
 	return nil
 }
 
+// admxStringRefRe matches the references to ADML strings in an ADMX.
+var admxStringRefRe = regexp.MustCompile(`\$\(string\.([^)]+)\)`)
+
+// writeADML generates the adml at path from input, where tr returns the string to use for each string ID.
+func (g generator) writeADML(path string, input any, tr func(id, base string) string) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.New(gotext.Get("can't create adml file: %v", err))
+	}
+	defer decorate.LogFuncOnError(f.Close)
+
+	funcMap := template.FuncMap{
+		"toID": g.toID,
+		"tr":   tr,
+	}
+	t := template.Must(template.New("adml.template").Funcs(funcMap).Parse(admlTemplate))
+	return t.Execute(f, input)
+}
+
 func expandedCategoriesToMD(expandedCategories []expandedCategory, rootDest string, currentRelPath string) (err error) {
 	computerDest, userDest := filepath.Join(rootDest, "Computer Policies", currentRelPath), filepath.Join(rootDest, "User Policies", currentRelPath)
 	// bootstrap first directories
@@ -1,8 +1,10 @@
 package admxgen_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"testing"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/ubuntu/adsys/internal/ad/admxgen"
 	"github.com/ubuntu/adsys/internal/ad/admxgen/common"
 	"github.com/ubuntu/adsys/internal/testutils"
+	policydefinitions "github.com/ubuntu/adsys/policies"
 	"gopkg.in/yaml.v3"
 )
 
@@ -87,23 +90,45 @@ func TestGenerateAD(t *testing.T) {
 	tests := map[string]struct {
 		autoDetectReleases bool
 		destIsFile         bool
+		locales            []string
+		// mergedDefinitions loads all category definition files from the test directory.
+		mergedDefinitions bool
+		src               string
 
 		wantErr bool
 	}{
-		"releases from yaml":                      {},
-		"autodetect overrides releases from yaml": {autoDetectReleases: true},
+		"releases from yaml":                            {},
+		"autodetect overrides releases from yaml":       {autoDetectReleases: true},
+		"translations for two locales":                  {locales: []string{"fr-FR", "de-DE"}},
+		"merge definition files into nested categories": {mergedDefinitions: true, src: "src with multiple policies"},
 
 		// Error cases
-		"invalid definition file":  {wantErr: true},
-		"category expansion fails": {wantErr: true},
-		"admx generation fails":    {destIsFile: true, wantErr: true},
+		"invalid definition file":                             {wantErr: true},
+		"category expansion fails":                            {wantErr: true},
+		"admx generation fails":                               {destIsFile: true, wantErr: true},
+		"unknown string in translation":                       {locales: []string{"fr-FR"}, wantErr: true},
+		"invalid translation locale":                          {locales: []string{"not_a-valid-locale!"}, wantErr: true},
+		"invalid translation file":                            {locales: []string{"fr-FR"}, wantErr: true},
+		"missing translation file":                            {locales: []string{"fr-FR"}, wantErr: true},
+		"error on conflicting category definitions":           {mergedDefinitions: true, src: "src with multiple policies", wantErr: true},
+		"error on policy listed in multiple definition files": {mergedDefinitions: true, src: "src with multiple policies", wantErr: true},
+		"error on conflicting distro ids":                     {mergedDefinitions: true, src: "src with multiple policies", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			catDef := filepath.Join(testutils.TestFamilyPath(t), name+".yaml")
-			src := filepath.Join(testutils.TestFamilyPath(t), "src")
+			if tc.src == "" {
+				tc.src = "src"
+			}
+
+			catDefs := []string{filepath.Join(testutils.TestFamilyPath(t), name+".yaml")}
+			if tc.mergedDefinitions {
+				var err error
+				catDefs, err = filepath.Glob(filepath.Join(testutils.TestFamilyPath(t), name, "*.yaml"))
+				require.NoError(t, err, "Setup: should list category definition files")
+			}
+			src := filepath.Join(testutils.TestFamilyPath(t), tc.src)
 			dst := t.TempDir()
 
 			if tc.destIsFile {
@@ -113,7 +138,12 @@ func TestGenerateAD(t *testing.T) {
 				require.NoError(t, err, "Setup: should create a file as destination")
 			}
 
-			err := admxgen.GenerateAD(catDef, src, dst, tc.autoDetectReleases, false)
+			translations := make(map[string]string)
+			for _, locale := range tc.locales {
+				translations[locale] = filepath.Join(testutils.TestFamilyPath(t), "translations", name, locale+".yaml")
+			}
+
+			err := admxgen.GenerateAD(catDefs, src, dst, tc.autoDetectReleases, false, translations)
 			if tc.wantErr {
 				require.Error(t, err, "admx should have errored out")
 				return
@@ -133,6 +163,146 @@ func TestGenerateAD(t *testing.T) {
 
 			assert.Equal(t, wantADMX, string(gotADMX), "expected and got admx content differs")
 			assert.Equal(t, wantADML, string(gotADML), "expected and got adml content differs")
+
+			for _, locale := range tc.locales {
+				gotLocalizedADML, err := os.ReadFile(filepath.Join(dst, locale, "Ubuntu.adml"))
+				require.NoError(t, err, "should be able to read destination adml file for %s", locale)
+
+				goldPath := testutils.GoldenPath(t) + "." + locale + ".adml"
+				want := testutils.LoadWithUpdateFromGolden(t, string(gotLocalizedADML), testutils.WithGoldenPath(goldPath))
+				assert.Equal(t, want, string(gotLocalizedADML), "expected and got adml content differs for %s", locale)
+			}
+		})
+	}
+}
+
+func TestGenerateADPresentations(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		wantPresentation string
+	}{
+		"enum key":                        {wantPresentation: "dropdownList"},
+		"ranged integer key":              {wantPresentation: "decimalTextBox"},
+		"integer key without range":       {wantPresentation: "decimalTextBox"},
+		"integer key with negative range": {wantPresentation: "textBox"},
+		"text key":                        {wantPresentation: "textBox"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			defs := filepath.Join(testutils.TestFamilyPath(t), "defs", name)
+			root := filepath.Join(testutils.TestFamilyPath(t), "system")
+			src := t.TempDir()
+			dst := t.TempDir()
+
+			err := admxgen.Expand(defs, src, root, "")
+			require.NoError(t, err, "Setup: expand failed but shouldn't have")
+
+			err = admxgen.GenerateAD([]string{filepath.Join(defs, "categories.yaml")}, src, dst, false, false, nil)
+			require.NoError(t, err, "admx failed but shouldn't have")
+
+			gotADMX, err := os.ReadFile(filepath.Join(dst, "Ubuntu.admx"))
+			require.NoError(t, err, "should be able to read destination admx file")
+			gotADML, err := os.ReadFile(filepath.Join(dst, "Ubuntu.adml"))
+			require.NoError(t, err, "should be able to read destination adml file")
+
+			presentations := regexp.MustCompile(`(?s)<presentationTable>.*</presentationTable>`).Find(gotADML)
+			require.NotNil(t, presentations, "adml should have a presentation table")
+			assert.Contains(t, string(presentations), "<"+tc.wantPresentation+" ", "presentation should use the expected element")
+
+			wantADMX := testutils.LoadWithUpdateFromGolden(t, string(gotADMX), testutils.WithGoldenPath(testutils.GoldenPath(t)+".admx"))
+			wantPresentations := testutils.LoadWithUpdateFromGolden(t, string(presentations), testutils.WithGoldenPath(testutils.GoldenPath(t)+".presentations"))
+
+			assert.Equal(t, wantADMX, string(gotADMX), "expected and got admx content differs")
+			assert.Equal(t, wantPresentations, string(presentations), "expected and got presentations differ")
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		wantErr bool
+	}{
+		"valid admx": {},
+
+		// Error cases
+		"admx with schema violations": {wantErr: true},
+		"malformed xml":               {wantErr: true},
+		"not an admx":                 {wantErr: true},
+		"missing file":                {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := admxgen.Validate(filepath.Join(testutils.TestFamilyPath(t), name+".admx"))
+			if !tc.wantErr {
+				require.NoError(t, err, "Validate failed but shouldn't have")
+				return
+			}
+			require.Error(t, err, "Validate should have errored out")
+
+			want := testutils.LoadWithUpdateFromGolden(t, err.Error())
+			assert.Equal(t, want, err.Error(), "Validate should report the offending elements")
+		})
+	}
+}
+
+func TestPolicyKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		admx    string
+		format  string
+		manager string
+
+		wantNoKeys bool
+		wantErr    bool
+	}{
+		"dconf keys":                         {manager: "dconf"},
+		"privilege keys":                     {manager: "privilege"},
+		"gdm keys are lockable under dconf":  {manager: "gdm"},
+		"keys of all managers":               {},
+		"keys of lts only releases":          {format: "lts-only", manager: "privilege"},
+		"no keys for manager without policy": {manager: "doesnotexist", wantNoKeys: true},
+		"policy without elements is boolean": {admx: "policy without elements"},
+
+		// Error cases
+		"error on malformed xml":               {admx: "malformed xml", wantErr: true},
+		"error on key of another distribution": {admx: "key of another distribution", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.format == "" {
+				tc.format = "all"
+			}
+			// Keys are listed from the definitions compiled into the binary by default.
+			admx, err := policydefinitions.All.ReadFile(fmt.Sprintf("Ubuntu/%s/Ubuntu.admx", tc.format))
+			require.NoError(t, err, "Setup: can't read embedded ADMX")
+			if tc.admx != "" {
+				admx, err = os.ReadFile(filepath.Join(testutils.TestFamilyPath(t), tc.admx+".admx"))
+				require.NoError(t, err, "Setup: can't read ADMX")
+			}
+
+			got, err := admxgen.PolicyKeys(admx, "Ubuntu", tc.manager)
+			if tc.wantErr {
+				require.Error(t, err, "PolicyKeys should have errored out")
+				return
+			}
+			require.NoError(t, err, "PolicyKeys failed but shouldn't have")
+			if tc.wantNoKeys {
+				require.Empty(t, got, "PolicyKeys should return no keys")
+				return
+			}
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "PolicyKeys should return the expected keys")
 		})
 	}
 }
@@ -168,7 +338,7 @@ func TestGenerateDoc(t *testing.T) {
 				require.NoError(t, err, "Setup: should create a file as destination")
 			}
 
-			err := admxgen.GenerateDoc(catDef, src, dst)
+			err := admxgen.GenerateDoc([]string{catDef}, src, dst)
 			if tc.wantErr {
 				require.Error(t, err, "GenerateDoc should have errored out")
 				return
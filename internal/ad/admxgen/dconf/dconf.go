@@ -28,10 +28,6 @@ type Policy struct {
 	Class      string
 }
 
-// TODO:
-//     - if Min or Max < 0 -> text + explaintext
-// Double with range: explaintext
-
 // handle per Releases
 
 // schemasPath is the path to the directory that contains dconf schemas and overrides.
@@ -175,11 +171,43 @@ func inflateToExpandedPolicies(policies []Policy, release, currentSessions strin
 			ep.RangeValues.Max = strings.Split(ep.RangeValues.Max, ".")[0]
 		}
 
+		// ADMX decimal values are unsigned: negative ranges are entered as text, as doubles are.
+		if m.widgetType == common.WidgetTypeDecimal &&
+			(strings.HasPrefix(ep.RangeValues.Min, "-") || strings.HasPrefix(ep.RangeValues.Max, "-")) {
+			ep.ElementType = common.WidgetTypeText
+		}
+		if ep.ElementType == common.WidgetTypeText && ep.RangeValues != (common.DecimalRange{}) {
+			ep.ExplainText = strings.TrimSpace(ep.ExplainText + " " + rangeExplainText(ep.RangeValues))
+			ep.RangeValues = common.DecimalRange{}
+		}
+
 		r = append(r, ep)
 	}
 	return r, nil
 }
 
+// rangeExplainText describes the valid values of r, for keys whose widget can't enforce the range.
+func rangeExplainText(r common.DecimalRange) string {
+	min, max := formatRangeValue(r.Min), formatRangeValue(r.Max)
+	switch {
+	case min != "" && max != "":
+		return gotext.Get("Valid values range from %s to %s.", min, max)
+	case min != "":
+		return gotext.Get("Minimum value is %s.", min)
+	default:
+		return gotext.Get("Maximum value is %s.", max)
+	}
+}
+
+// formatRangeValue returns v without its trailing zeros, as the schema ranges are loaded with a fixed precision.
+func formatRangeValue(v string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
 // default are separated in a different map as defaults can be different for different object path from the same schema.
 // it is thus indexed only by object path.
 type schemaEntry struct {
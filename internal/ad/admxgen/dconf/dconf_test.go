@@ -31,6 +31,7 @@ func TestGenerate(t *testing.T) {
 		"One boolean key":                      {root: "simple"},
 		"One decimal key":                      {root: "simple"},
 		"One decimal key with range":           {root: "simple"},
+		"One decimal key with positive range":  {root: "simple"},
 		"One decimal key with min only":        {root: "simple"},
 		"One decimal key with max only":        {root: "simple"},
 		"Long decimal key":                     {root: "simple"},
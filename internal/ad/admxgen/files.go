@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
@@ -15,6 +16,7 @@ import (
 	adcommon "github.com/ubuntu/adsys/internal/ad/common"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/text/language"
 	"gopkg.in/yaml.v3"
 )
 
@@ -120,9 +122,17 @@ type categoryFileStruct struct {
 }
 
 // GenerateAD creates and merge all policies into ADMX/ADML files.
-func GenerateAD(categoryDefinition, src, dst string, autoDetectReleases, allowMissingKeys bool) error {
+// The categories of all categoryDefinitions files are merged into a single category tree.
+// translations maps locales, like fr-FR, to translation files: an ADML is generated in the locale subdirectory of dst
+// for each of them.
+func GenerateAD(categoryDefinitions []string, src, dst string, autoDetectReleases, allowMissingKeys bool, translations map[string]string) error {
 	// Load all expanded categories
-	policies, catfs, err := loadDefinitions(categoryDefinition, src)
+	policies, catfs, err := loadDefinitions(categoryDefinitions, src)
+	if err != nil {
+		return err
+	}
+
+	localizedStrings, err := loadTranslations(translations)
 	if err != nil {
 		return err
 	}
@@ -151,7 +161,7 @@ func GenerateAD(categoryDefinition, src, dst string, autoDetectReleases, allowMi
 	if err != nil {
 		return err
 	}
-	err = g.expandedCategoriesToADMX(ec, dst)
+	err = g.expandedCategoriesToADMX(ec, dst, localizedStrings)
 	if err != nil {
 		return err
 	}
@@ -160,9 +170,10 @@ func GenerateAD(categoryDefinition, src, dst string, autoDetectReleases, allowMi
 }
 
 // GenerateDoc creates and merge all policies into documentation files.
-func GenerateDoc(categoryDefinition, src, dst string) error {
+// As with GenerateAD, the categories of all categoryDefinitions files are merged.
+func GenerateDoc(categoryDefinitions []string, src, dst string) error {
 	// Load all expanded categories
-	policies, catfs, err := loadDefinitions(categoryDefinition, src)
+	policies, catfs, err := loadDefinitions(categoryDefinitions, src)
 	if err != nil {
 		return err
 	}
@@ -197,7 +208,7 @@ func GenerateDoc(categoryDefinition, src, dst string) error {
 	return nil
 }
 
-func loadDefinitions(categoryDefinition, src string) (ep []common.ExpandedPolicy, cfs categoryFileStruct, err error) {
+func loadDefinitions(categoryDefinitions []string, src string) (ep []common.ExpandedPolicy, cfs categoryFileStruct, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't load category definition"))
 
 	var nilCategoryFileStruct categoryFileStruct
@@ -226,16 +237,123 @@ func loadDefinitions(categoryDefinition, src string) (ep []common.ExpandedPolicy
 		policies = append(policies, p...)
 	}
 
-	// Load categories and meta
+	// Load categories and meta, merging all definition files in a single category tree
+	if len(categoryDefinitions) == 0 {
+		return nil, nilCategoryFileStruct, errors.New(gotext.Get("no category definition file"))
+	}
 	var catfs categoryFileStruct
-	catsDef, err := os.ReadFile(categoryDefinition)
-	if err != nil {
-		return nil, nilCategoryFileStruct, err
+	for _, categoryDefinition := range categoryDefinitions {
+		var c categoryFileStruct
+		catsDef, err := os.ReadFile(categoryDefinition)
+		if err != nil {
+			return nil, nilCategoryFileStruct, err
+		}
+		err = yaml.Unmarshal(catsDef, &c)
+		if err != nil {
+			return nil, nilCategoryFileStruct, fmt.Errorf("trying to load %s: %w", categoryDefinition, err)
+		}
+		if err := catfs.merge(c); err != nil {
+			return nil, nilCategoryFileStruct, fmt.Errorf("trying to merge %s: %w", categoryDefinition, err)
+		}
 	}
-	err = yaml.Unmarshal(catsDef, &catfs)
+
+	return policies, catfs, nil
+}
+
+// merge adds the definitions of other to cfs.
+// Supported releases are the union of both, ordered from the oldest. Categories are merged by display name at each level of the
+// tree, so that the shared parent categories are only defined once.
+func (cfs *categoryFileStruct) merge(other categoryFileStruct) error {
+	if cfs.DistroID == "" {
+		cfs.DistroID = other.DistroID
+	} else if other.DistroID != "" && other.DistroID != cfs.DistroID {
+		return errors.New(gotext.Get("distro id %q differs from previously defined %q", other.DistroID, cfs.DistroID))
+	}
+
+	if len(cfs.SupportedReleases) == 0 {
+		cfs.SupportedReleases = other.SupportedReleases
+	} else {
+		for _, r := range other.SupportedReleases {
+			if !slices.Contains(cfs.SupportedReleases, r) {
+				cfs.SupportedReleases = append(cfs.SupportedReleases, r)
+			}
+		}
+		sort.Strings(cfs.SupportedReleases)
+	}
+
+	categories, err := mergeCategories(cfs.Categories, other.Categories)
 	if err != nil {
-		return nil, nilCategoryFileStruct, fmt.Errorf("trying to load %s: %w", categoryDefinition, err)
+		return err
 	}
+	cfs.Categories = categories
+	return nil
+}
 
-	return policies, catfs, nil
+// mergeCategories merges the categories of other into categories, matching them by display name.
+// A category defined in both must have the same attributes, unless they are unset in one of them.
+func mergeCategories(categories, other []category) ([]category, error) {
+	for _, o := range other {
+		i := slices.IndexFunc(categories, func(c category) bool { return c.DisplayName == o.DisplayName })
+		if i == -1 {
+			categories = append(categories, o)
+			continue
+		}
+
+		c := &categories[i]
+		for _, attr := range []struct {
+			name              string
+			value, otherValue *string
+		}{
+			{"parent", &c.Parent, &o.Parent},
+			{"defaultpolicyclass", &c.DefaultPolicyClass, &o.DefaultPolicyClass},
+			{"prefix", &c.Prefix, &o.Prefix},
+		} {
+			if *attr.value == "" {
+				*attr.value = *attr.otherValue
+				continue
+			}
+			if *attr.otherValue != "" && *attr.otherValue != *attr.value {
+				return nil, errors.New(gotext.Get("conflicting definitions of category %q: %s is %q and %q", c.DisplayName, attr.name, *attr.value, *attr.otherValue))
+			}
+		}
+
+		for _, p := range o.Policies {
+			if slices.Contains(c.Policies, p) {
+				return nil, errors.New(gotext.Get("policy %s is listed multiple times in category %q", p, c.DisplayName))
+			}
+			c.Policies = append(c.Policies, p)
+		}
+
+		children, err := mergeCategories(c.Children, o.Children)
+		if err != nil {
+			return nil, err
+		}
+		c.Children = children
+	}
+	return categories, nil
+}
+
+// loadTranslations loads the translation files of each locale. A translation file maps the string IDs of the ADML
+// to their translated strings.
+func loadTranslations(translations map[string]string) (localizedStrings map[string]map[string]string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't load translations"))
+
+	localizedStrings = make(map[string]map[string]string)
+	for locale, path := range translations {
+		if _, err := language.Parse(locale); err != nil {
+			return nil, errors.New(gotext.Get("invalid locale %q: %v", locale, err))
+		}
+
+		d, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var strs map[string]string
+		if err := yaml.Unmarshal(d, &strs); err != nil {
+			return nil, fmt.Errorf("trying to load %s: %w", path, err)
+		}
+		localizedStrings[locale] = strs
+	}
+
+	return localizedStrings, nil
 }
@@ -89,7 +89,7 @@ func TestGenerateExpandedCategories(t *testing.T) {
 			t.Parallel()
 
 			policies, catfs, err := loadDefinitions(
-				filepath.Join(testutils.TestFamilyPath(t), "defs", categoryDefinition)+".yaml",
+				[]string{filepath.Join(testutils.TestFamilyPath(t), "defs", categoryDefinition) + ".yaml"},
 				filepath.Join(testutils.TestFamilyPath(t), "defs", name))
 
 			if tc.wantErrLoadDefinitions {
@@ -160,7 +160,8 @@ func TestExpandedCategoriesToADMX(t *testing.T) {
 		"no meta at all":   {},
 
 		// Error Cases
-		"error on destination creation": {destIsFile: true, wantErr: true},
+		"error on destination creation":     {destIsFile: true, wantErr: true},
+		"error on admx not matching schema": {wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -187,7 +188,7 @@ func TestExpandedCategoriesToADMX(t *testing.T) {
 			g := generator{
 				distroID: tc.distroID,
 			}
-			err = g.expandedCategoriesToADMX(ec, dst)
+			err = g.expandedCategoriesToADMX(ec, dst, nil)
 			if tc.wantErr {
 				require.Error(t, err, "expandedCategoriesToADMX should have errored out")
 				return
@@ -0,0 +1,97 @@
+package admxgen
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/ad/admxgen/common"
+	"github.com/ubuntu/decorate"
+)
+
+// PolicyKey is a key supported by a policy manager, as defined in an ADMX file.
+type PolicyKey struct {
+	Manager string `json:"manager"`
+	// Key is relative to the manager, like /org/gnome/desktop/interface/clock-format for dconf.
+	Key   string `json:"key"`
+	Class string `json:"class"`
+	// Type is the type of the value of the key in the GPO settings dialog.
+	Type common.WidgetType `json:"type"`
+	// Lockable is true for the keys the manager can lock, to prevent users from changing them.
+	Lockable bool `json:"lockable"`
+}
+
+// admxPolicies is the list of policies of an ADMX file.
+type admxPolicies struct {
+	Policies []struct {
+		Class    string `xml:"class,attr"`
+		Key      string `xml:"key,attr"`
+		Elements struct {
+			Elements []struct {
+				XMLName   xml.Name
+				ValueName string `xml:"valueName,attr"`
+			} `xml:",any"`
+		} `xml:"elements"`
+	} `xml:"policies>policy"`
+}
+
+// PolicyKeys returns the keys defined in admx for the policy managers of distroID, sorted by manager, key and class.
+// If manager is not empty, only its keys are returned.
+func PolicyKeys(admx []byte, distroID, manager string) (keys []PolicyKey, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't list policy keys"))
+
+	var p admxPolicies
+	if err := xml.Unmarshal(admx, &p); err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf(`Software\Policies\%s\`, distroID)
+	for _, policy := range p.Policies {
+		key, ok := strings.CutPrefix(policy.Key, prefix)
+		if !ok {
+			return nil, errors.New(gotext.Get("policy key %q is not a key of %s", policy.Key, distroID))
+		}
+		m, key, _ := strings.Cut(strings.ReplaceAll(key, `\`, "/"), "/")
+		if manager != "" && m != manager {
+			continue
+		}
+
+		// Policies without elements are only enabled or disabled. Others have an element per release: the one for all
+		// releases is the reference, if any.
+		t := common.WidgetTypeBool
+		var found bool
+		for _, e := range policy.Elements.Elements {
+			if strings.HasPrefix(e.ValueName, "Override") || (found && e.ValueName != "all") {
+				continue
+			}
+			t, found = common.WidgetType(e.XMLName.Local), true
+			if t == "enum" {
+				t = common.WidgetTypeDropdownList
+			}
+		}
+
+		keys = append(keys, PolicyKey{
+			Manager: m,
+			Key:     "/" + key,
+			Class:   policy.Class,
+			Type:    t,
+			// dconf keys, including the ones of the login screen, are locked by default.
+			Lockable: m == "dconf" || (m == "gdm" && strings.HasPrefix(key, "dconf/")),
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Manager != keys[j].Manager {
+			return keys[i].Manager < keys[j].Manager
+		}
+		if keys[i].Key != keys[j].Key {
+			return keys[i].Key < keys[j].Key
+		}
+		return keys[i].Class < keys[j].Class
+	})
+
+	return keys, nil
+}
@@ -0,0 +1,350 @@
+package admxgen
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+// policyDefinitionsNamespace is the XML namespace of ADMX files.
+const policyDefinitionsNamespace = "http://schemas.microsoft.com/GroupPolicy/2006/07/PolicyDefinitions"
+
+// attributeRule is how an attribute value is validated: it must match pattern or be one of values.
+type attributeRule struct {
+	required bool
+	pattern  *regexp.Regexp
+	values   []string
+}
+
+// childRule is how many times a child element can occur. A max of 0 means unbounded.
+type childRule struct {
+	min, max int
+}
+
+// elementRule is the definition of an element of the PolicyDefinitions schema.
+type elementRule struct {
+	attributes map[string]attributeRule
+	children   map[string]childRule
+	// anyAttribute allows attributes which are not listed, like namespace declarations.
+	anyAttribute bool
+}
+
+var (
+	itemNamePattern        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+	itemReferencePattern   = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.-]*:)?[A-Za-z_][A-Za-z0-9_.-]*$`)
+	stringReferencePattern = regexp.MustCompile(`^\$\(string\.[A-Za-z_][A-Za-z0-9_.-]*\)$`)
+	presentationRefPattern = regexp.MustCompile(`^\$\(presentation\.[A-Za-z_][A-Za-z0-9_.-]*\)$`)
+	versionPattern         = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+	unsignedIntPattern     = regexp.MustCompile(`^[0-9]+$`)
+	nonEmptyPattern        = regexp.MustCompile(`\S`)
+	booleanValues          = []string{"true", "false"}
+)
+
+// valueChildren are the possible children of registry value elements, like enabledValue.
+var valueChildren = map[string]childRule{"delete": {0, 1}, "decimal": {0, 1}, "longDecimal": {0, 1}, "string": {0, 1}}
+
+// policyElementAttributes are the attributes common to all policy elements.
+var policyElementAttributes = map[string]attributeRule{
+	"id":        {required: true, pattern: itemNamePattern},
+	"key":       {pattern: nonEmptyPattern},
+	"valueName": {pattern: nonEmptyPattern},
+}
+
+// policyDefinitionsSchema is the part of the PolicyDefinitions schema GPMC enforces on the ADMX elements we generate,
+// indexed by element name. It is transcribed from the XSD, which is not bundled as Go has no XSD validator: the other
+// elements of the schema, which admxgen never generates, are reported as unknown.
+var policyDefinitionsSchema = map[string]elementRule{
+	"policyDefinitions": {
+		attributes: map[string]attributeRule{
+			"revision":      {required: true, pattern: versionPattern},
+			"schemaVersion": {required: true, pattern: versionPattern},
+		},
+		children: map[string]childRule{
+			"policyNamespaces": {1, 1},
+			"resources":        {1, 1},
+			"supportedOn":      {0, 1},
+			"categories":       {0, 1},
+			"policies":         {0, 1},
+		},
+		anyAttribute: true,
+	},
+	"policyNamespaces": {children: map[string]childRule{"target": {1, 1}, "using": {0, 0}}},
+	"target": {attributes: map[string]attributeRule{
+		"prefix":    {required: true, pattern: itemNamePattern},
+		"namespace": {required: true, pattern: nonEmptyPattern},
+	}},
+	"using": {attributes: map[string]attributeRule{
+		"prefix":    {required: true, pattern: itemNamePattern},
+		"namespace": {required: true, pattern: nonEmptyPattern},
+	}},
+	"resources": {attributes: map[string]attributeRule{
+		"minRequiredRevision": {required: true, pattern: versionPattern},
+		"fallbackCulture":     {pattern: nonEmptyPattern},
+	}},
+	"categories": {children: map[string]childRule{"category": {1, 0}}},
+	"category": {
+		attributes: map[string]attributeRule{
+			"name":        {required: true, pattern: itemNamePattern},
+			"displayName": {required: true, pattern: stringReferencePattern},
+			"explainText": {pattern: stringReferencePattern},
+		},
+		children: map[string]childRule{"parentCategory": {0, 1}},
+	},
+	"parentCategory": {attributes: map[string]attributeRule{"ref": {required: true, pattern: itemReferencePattern}}},
+	"supportedOn":    {attributes: map[string]attributeRule{"ref": {required: true, pattern: itemReferencePattern}}},
+	"policies":       {children: map[string]childRule{"policy": {1, 0}}},
+	"policy": {
+		attributes: map[string]attributeRule{
+			"name":         {required: true, pattern: itemNamePattern},
+			"class":        {required: true, values: []string{"User", "Machine", "Both"}},
+			"displayName":  {required: true, pattern: stringReferencePattern},
+			"explainText":  {pattern: stringReferencePattern},
+			"presentation": {pattern: presentationRefPattern},
+			"key":          {required: true, pattern: nonEmptyPattern},
+			"valueName":    {pattern: nonEmptyPattern},
+		},
+		children: map[string]childRule{
+			"parentCategory": {1, 1},
+			"supportedOn":    {1, 1},
+			"enabledValue":   {0, 1},
+			"disabledValue":  {0, 1},
+			"elements":       {0, 1},
+		},
+	},
+	"enabledValue":  {children: valueChildren},
+	"disabledValue": {children: valueChildren},
+	"trueValue":     {children: valueChildren},
+	"falseValue":    {children: valueChildren},
+	"value":         {children: valueChildren},
+	"string":        {},
+	"delete":        {},
+	"elements": {children: map[string]childRule{
+		"boolean": {0, 0}, "decimal": {0, 0}, "longDecimal": {0, 0}, "text": {0, 0}, "multiText": {0, 0}, "enum": {0, 0},
+	}},
+	"boolean": {
+		attributes: policyElementAttributes,
+		children:   map[string]childRule{"trueValue": {0, 1}, "falseValue": {0, 1}},
+	},
+	"decimal":     {attributes: withAttributes(policyElementAttributes, decimalAttributes)},
+	"longDecimal": {attributes: withAttributes(policyElementAttributes, decimalAttributes)},
+	"text": {attributes: withAttributes(policyElementAttributes, map[string]attributeRule{
+		"required":   {values: booleanValues},
+		"maxLength":  {pattern: unsignedIntPattern},
+		"expandable": {values: booleanValues},
+		"soft":       {values: booleanValues},
+	})},
+	"multiText": {attributes: withAttributes(policyElementAttributes, map[string]attributeRule{
+		"required":   {values: booleanValues},
+		"maxLength":  {pattern: unsignedIntPattern},
+		"maxStrings": {pattern: unsignedIntPattern},
+		"soft":       {values: booleanValues},
+	})},
+	"enum": {
+		attributes: withAttributes(policyElementAttributes, map[string]attributeRule{"required": {values: booleanValues}}),
+		children:   map[string]childRule{"item": {1, 0}},
+	},
+	"item": {
+		attributes: map[string]attributeRule{"displayName": {required: true, pattern: stringReferencePattern}},
+		children:   map[string]childRule{"value": {1, 1}},
+	},
+}
+
+// decimalAttributes are the attributes specific to decimal and longDecimal elements.
+var decimalAttributes = map[string]attributeRule{
+	"required":    {values: booleanValues},
+	"minValue":    {pattern: unsignedIntPattern},
+	"maxValue":    {pattern: unsignedIntPattern},
+	"storeAsText": {values: booleanValues},
+	"soft":        {values: booleanValues},
+}
+
+// withAttributes returns the union of attribute rules.
+func withAttributes(rules ...map[string]attributeRule) map[string]attributeRule {
+	r := make(map[string]attributeRule)
+	for _, rule := range rules {
+		for k, v := range rule {
+			r[k] = v
+		}
+	}
+	return r
+}
+
+// Validate checks the ADMX file at path against the subset of the PolicyDefinitions schema covering the elements
+// admxgen generates. It is not a full validation against the PolicyDefinitions XSD.
+func Validate(path string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid ADMX file %q", path))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return validateADMX(data)
+}
+
+// validatingElement is an element being validated, with the number of occurrences of each of its children.
+type validatingElement struct {
+	name     string
+	line     int
+	children map[string]int
+}
+
+// validateADMX checks data, an ADMX, against the subset of the PolicyDefinitions schema in policyDefinitionsSchema.
+// All violations are reported with the line of the offending element.
+func validateADMX(data []byte) error {
+	var violations []string
+	violation := func(line int, element, msg string) {
+		violations = append(violations, gotext.Get("line %d: <%s>: %s", line, element, msg))
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+	lineAt := func(offset int64) int {
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+
+	var stack []*validatingElement
+	// ids are the names of categories and policies and the ids of the elements of the current policy, which must be
+	// unique.
+	ids := make(map[string]map[string]bool)
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return errors.New(gotext.Get("line %d: malformed XML: %v", lineAt(d.InputOffset()), err))
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := t.Name.Local
+			line := lineAt(offset)
+
+			if t.Name.Space != policyDefinitionsNamespace {
+				violation(line, name, gotext.Get("element is not in the %s namespace", policyDefinitionsNamespace))
+			}
+
+			rule, known := policyDefinitionsSchema[name]
+			if len(stack) == 0 {
+				if name != "policyDefinitions" {
+					violation(line, name, gotext.Get("root element must be <policyDefinitions>"))
+				}
+			} else {
+				parent := stack[len(stack)-1]
+				parentRule, parentKnown := policyDefinitionsSchema[parent.name]
+				if _, ok := parentRule.children[name]; parentKnown && !ok {
+					violation(line, name, gotext.Get("element is not allowed in <%s>", parent.name))
+				}
+				parent.children[name]++
+			}
+			if known {
+				validateAttributes(t, rule, func(msg string) { violation(line, name, msg) })
+			} else {
+				violation(line, name, gotext.Get("unknown element, only the elements generated by admxgen are supported"))
+			}
+
+			// Check unicity of the categories, policies and policy elements identifiers.
+			var idScope, id string
+			switch name {
+			case "category", "policy":
+				idScope, id = name, attrValue(t, "name")
+				ids["element"] = make(map[string]bool)
+			case "boolean", "decimal", "longDecimal", "text", "multiText", "enum":
+				idScope, id = "element", attrValue(t, "id")
+			}
+			if id != "" {
+				if ids[idScope] == nil {
+					ids[idScope] = make(map[string]bool)
+				}
+				if ids[idScope][id] {
+					violation(line, name, gotext.Get("duplicate identifier %q", id))
+				}
+				ids[idScope][id] = true
+			}
+
+			stack = append(stack, &validatingElement{name: name, line: line, children: make(map[string]int)})
+
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			rule := policyDefinitionsSchema[e.name]
+			for _, child := range sortedKeys(rule.children) {
+				r, n := rule.children[child], e.children[child]
+				if n < r.min {
+					violation(e.line, e.name, gotext.Get("expected at least %d <%s> element(s), got %d", r.min, child, n))
+				}
+				if r.max > 0 && n > r.max {
+					violation(e.line, e.name, gotext.Get("expected at most %d <%s> element(s), got %d", r.max, child, n))
+				}
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%s", strings.Join(violations, "\n"))
+	}
+	return nil
+}
+
+// validateAttributes reports the attributes of e which are missing or invalid against rule.
+func validateAttributes(e xml.StartElement, rule elementRule, violation func(msg string)) {
+	seen := make(map[string]bool)
+	for _, a := range e.Attr {
+		// Namespace declarations and attributes of other namespaces, like xsi, are always allowed.
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" || a.Name.Space != "" {
+			continue
+		}
+		seen[a.Name.Local] = true
+
+		r, ok := rule.attributes[a.Name.Local]
+		if !ok {
+			if !rule.anyAttribute {
+				violation(gotext.Get("attribute %q is not allowed", a.Name.Local))
+			}
+			continue
+		}
+		if r.pattern != nil && !r.pattern.MatchString(a.Value) {
+			violation(gotext.Get("invalid value %q for attribute %q", a.Value, a.Name.Local))
+		}
+		if r.values != nil && !slices.Contains(r.values, a.Value) {
+			violation(gotext.Get("invalid value %q for attribute %q, expected one of: %s", a.Value, a.Name.Local, strings.Join(r.values, ", ")))
+		}
+	}
+
+	for _, name := range sortedKeys(rule.attributes) {
+		if rule.attributes[name].required && !seen[name] {
+			violation(gotext.Get("missing required attribute %q", name))
+		}
+	}
+}
+
+// attrValue returns the value of the attribute name of e, or an empty string.
+func attrValue(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// sortedKeys returns the keys of m in alphabetical order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -30,6 +30,9 @@ func TestAdsysGPOList(t *testing.T) {
 		accountName     string
 		objectClass     string
 		krb5ccNameState string
+		linkOrder       bool
+		details         bool
+		configuration   string
 
 		wantErr        bool
 		wantReturnCode int
@@ -72,6 +75,20 @@ func TestAdsysGPOList(t *testing.T) {
 		"Filter machine only GPOs": {
 			accountName: "RnDUserDep7@GPOONLY.COM",
 		},
+		"Machine only GPOs apply to computers": {
+			accountName: "hostnameRnDDep7",
+			objectClass: "computer",
+		},
+		"User configuration of computer GPOs keeps user only GPOs": {
+			accountName:   "hostname2",
+			objectClass:   "computer",
+			configuration: "user",
+		},
+		"User configuration of computer GPOs filters machine only GPOs": {
+			accountName:   "hostnameRnDDep7",
+			objectClass:   "computer",
+			configuration: "user",
+		},
 
 		// Forced GPOs and inheritance handling
 		"Forced GPO are first by reverse order": {
@@ -83,6 +100,24 @@ func TestAdsysGPOList(t *testing.T) {
 		"Forced GPO and blocked inheritance": {
 			accountName: "RnDUserWithBlockedInheritanceAndForcedPolicies@GPOONLY.COM",
 		},
+		"Link order ignores forced GPO": {
+			accountName: "RndUserSubDep2ForcedPolicy@GPOONLY.COM",
+			linkOrder:   true,
+		},
+		"Link order ignores blocked inheritance": {
+			accountName: "RnDUserWithBlockedInheritanceAndForcedPolicies@GPOONLY.COM",
+			linkOrder:   true,
+		},
+
+		// Details of GPO links
+		"Details print linked containers": {
+			accountName: "RnDUser@GPOONLY.COM",
+			details:     true,
+		},
+		"Details print forced GPO and blocked inheritance": {
+			accountName: "RnDUserWithBlockedInheritanceAndForcedPolicies@GPOONLY.COM",
+			details:     true,
+		},
 
 		// Access cases
 		"Security descriptor missing ignores GPO": { // AD is doing that for windows client
@@ -225,7 +260,17 @@ func TestAdsysGPOList(t *testing.T) {
 			}
 
 			// #nosec G204: we control the command line name and only change it for tests
-			cmd := exec.Command(adsysGPOListcmd, "--objectclass", tc.objectClass, tc.url, tc.accountName)
+			args := []string{"--objectclass", tc.objectClass, tc.url, tc.accountName}
+			if tc.linkOrder {
+				args = append(args, "--link-order")
+			}
+			if tc.details {
+				args = append(args, "--details")
+			}
+			if tc.configuration != "" {
+				args = append(args, "--configuration", tc.configuration)
+			}
+			cmd := exec.Command(adsysGPOListcmd, args...)
 			got, err := cmd.CombinedOutput()
 			if tc.wantErr {
 				require.Error(t, err, "adsys-gpostlist should have failed but didn’t")
@@ -27,6 +27,25 @@ type Backend interface {
 	Config() string
 }
 
+// LocalBackend is a backend providing the GPOs from a local directory laid out like SYSVOL, instead of a domain
+// controller: no Kerberos ticket nor connection to the domain is needed to apply them.
+type LocalBackend interface {
+	Backend
+	// SysvolDir returns the directory laid out like SYSVOL.
+	SysvolDir() string
+	// GPOs returns the GPOs applying to objectClass, "computer" or "user", from the highest priority to the lowest.
+	GPOs(objectClass string) ([]GPO, error)
+}
+
+// GPO is a GPO provided by a local backend.
+type GPO struct {
+	ID   string
+	Name string
+	// Dir is the directory of the GPO, laid out like in SYSVOL.
+	Dir      string
+	Enforced bool
+}
+
 var (
 	// ErrNoActiveServer is an error receive when there is no active server and no static configuration
 	// This is received in ServerFQDN.
@@ -0,0 +1,167 @@
+// Package local is the backend applying the GPOs of a local directory laid out like SYSVOL, without any domain
+// controller.
+//
+// The directory contains a Policies subdirectory with a directory per GPO, named after its ID, and the assets
+// directory, as SYSVOL does. The GPOs applying to the machine and to users are listed in a manifest at the root of
+// the directory, from the highest priority to the lowest:
+//
+//	computer:
+//	  - id: "{31B2F340-016D-11D2-945F-00C04FB984F9}"
+//	    name: Default Domain Policy
+//	    enforced: true
+//	user:
+//	  - id: ubuntu-desktop
+//
+// The manifest is read each time the GPOs are listed, so that a new tree can be staged without restarting the
+// daemon. No Kerberos ticket nor connection is needed: the backend is always online.
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/ad/backends"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestName is the name of the manifest listing the GPOs, at the root of the directory.
+const ManifestName = "manifest.yaml"
+
+// Local is the backend object with the directory and domain information.
+type Local struct {
+	config Config
+}
+
+// Config for local backend.
+type Config struct {
+	Directory string `mapstructure:"directory"`
+	Domain    string `mapstructure:"domain"`
+}
+
+// manifestGPO is a GPO listed in the manifest.
+type manifestGPO struct {
+	ID       string `yaml:"id"`
+	Name     string `yaml:"name"`
+	Enforced bool   `yaml:"enforced"`
+}
+
+// New returns a local backend loaded from Config.
+func New(ctx context.Context, c Config) (l Local, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't load local GPO directory configuration from %+v", c))
+
+	log.Debug(ctx, "Loading local configuration for AD backend")
+
+	if c.Directory == "" {
+		return Local{}, errors.New(gotext.Get("no GPO directory is configured"))
+	}
+	if c.Domain == "" {
+		return Local{}, errors.New(gotext.Get("no domain is configured"))
+	}
+	if c.Directory, err = filepath.Abs(c.Directory); err != nil {
+		return Local{}, err
+	}
+	info, err := os.Stat(c.Directory)
+	if err != nil {
+		return Local{}, err
+	}
+	if !info.IsDir() {
+		return Local{}, errors.New(gotext.Get("%s is not a directory", c.Directory))
+	}
+	c.Domain = strings.ToLower(c.Domain)
+
+	return Local{config: c}, nil
+}
+
+// Domain returns the configured domain.
+func (l Local) Domain() string {
+	return l.config.Domain
+}
+
+// ServerFQDN returns ErrNoActiveServer, as GPOs are read from the local directory.
+func (l Local) ServerFQDN(context.Context) (string, error) {
+	return "", backends.ErrNoActiveServer
+}
+
+// HostKrb5CCName returns an error, as no Kerberos ticket is needed to read the local directory.
+func (l Local) HostKrb5CCName() (string, error) {
+	return "", errors.New(gotext.Get("no machine ticket is used with the local backend"))
+}
+
+// DefaultDomainSuffix returns the configured domain.
+func (l Local) DefaultDomainSuffix() string {
+	return l.config.Domain
+}
+
+// IsOnline returns true, as the local directory is always available.
+func (l Local) IsOnline() (bool, error) {
+	return true, nil
+}
+
+// Config returns a stringified configuration for local backend.
+func (l Local) Config() string {
+	return fmt.Sprintf(`Current backend is local
+Directory: %s
+Domain: %s`, l.config.Directory, l.config.Domain)
+}
+
+// SysvolDir returns the directory laid out like SYSVOL.
+func (l Local) SysvolDir() string {
+	return l.config.Directory
+}
+
+// GPOs returns the GPOs listed in the manifest for objectClass, "computer" or "user", from the highest priority to
+// the lowest.
+func (l Local) GPOs(objectClass string) (gpos []backends.GPO, err error) {
+	p := filepath.Join(l.config.Directory, ManifestName)
+	defer decorate.OnError(&err, gotext.Get("can't read GPOs from manifest %s", p))
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest map[string][]manifestGPO
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// An empty manifest lists no GPO.
+	if err := dec.Decode(&manifest); err != nil && len(bytes.TrimSpace(data)) > 0 {
+		return nil, err
+	}
+	for class := range manifest {
+		if class != "computer" && class != "user" {
+			return nil, errors.New(gotext.Get("unknown object class %q, expecting computer or user", class))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, g := range manifest[objectClass] {
+		// The ID is the name of the GPO directory.
+		if g.ID == "" || g.ID == "." || g.ID == ".." || strings.ContainsAny(g.ID, `/\`) {
+			return nil, errors.New(gotext.Get("invalid GPO ID %q for %s", g.ID, objectClass))
+		}
+		if seen[g.ID] {
+			return nil, errors.New(gotext.Get("GPO %q is listed multiple times for %s", g.ID, objectClass))
+		}
+		seen[g.ID] = true
+
+		if g.Name == "" {
+			g.Name = g.ID
+		}
+		gpos = append(gpos, backends.GPO{
+			ID:       g.ID,
+			Name:     g.Name,
+			Dir:      filepath.Join(l.config.Directory, "Policies", g.ID),
+			Enforced: g.Enforced,
+		})
+	}
+
+	return gpos, nil
+}
@@ -0,0 +1,110 @@
+package local_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/ad/backends/local"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestLocal(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		directory string
+		domain    string
+
+		wantErr     bool
+		wantGPOsErr bool
+	}{
+		"Load GPOs from manifest":                   {},
+		"Domain is lowercased":                      {domain: "Example.COM"},
+		"Directory can be relative":                 {directory: "testdata/TestLocal/dirs/valid/../valid"},
+		"Empty manifest lists no GPO":               {directory: "empty"},
+		"Missing object class in manifest is empty": {directory: "only-computer"},
+
+		// Error cases
+		"Error on no directory":              {directory: "-", wantErr: true},
+		"Error on no domain":                 {domain: "-", wantErr: true},
+		"Error on missing directory":         {directory: "doesnotexist", wantErr: true},
+		"Error on directory being a file":    {directory: "file", wantErr: true},
+		"Error on missing manifest":          {directory: "no-manifest", wantGPOsErr: true},
+		"Error on invalid manifest":          {directory: "invalid-yaml", wantGPOsErr: true},
+		"Error on unknown field":             {directory: "unknown-field", wantGPOsErr: true},
+		"Error on unknown object class":      {directory: "unknown-class", wantGPOsErr: true},
+		"Error on GPO ID with a separator":   {directory: "invalid-id", wantGPOsErr: true},
+		"Error on GPO ID being parent":       {directory: "dotdot-id", wantGPOsErr: true},
+		"Error on GPO listed multiple times": {directory: "duplicate-id", wantGPOsErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dirs := filepath.Join("testdata", "TestLocal", "dirs")
+			directory := filepath.Join(dirs, "valid")
+			switch {
+			case tc.directory == "-":
+				directory = ""
+			case strings.Contains(tc.directory, "/"):
+				directory = tc.directory
+			case tc.directory != "":
+				directory = filepath.Join(dirs, tc.directory)
+			}
+			domain := "example.com"
+			if tc.domain == "-" {
+				domain = ""
+			} else if tc.domain != "" {
+				domain = tc.domain
+			}
+
+			backend, err := local.New(context.Background(), local.Config{Directory: directory, Domain: domain})
+			if tc.wantErr {
+				require.Error(t, err, "New should have errored out")
+				return
+			}
+			require.NoError(t, err, "New should not have errored out")
+
+			got := testutils.FormatBackendCalls(t, backend)
+			got += fmt.Sprintf("* SysvolDir(): %s\n", backend.SysvolDir())
+			for _, objectClass := range []string{"computer", "user"} {
+				gpos, err := backend.GPOs(objectClass)
+				if tc.wantGPOsErr {
+					require.Error(t, err, "GPOs should have errored out")
+					return
+				}
+				require.NoError(t, err, "GPOs should not have errored out")
+
+				got += fmt.Sprintf("* GPOs(%s):\n", objectClass)
+				for _, g := range gpos {
+					got += fmt.Sprintf("  - %s (%s): %s, enforced: %t\n", g.ID, g.Name, g.Dir, g.Enforced)
+				}
+			}
+
+			// The directory is made absolute: strip the path of the tests.
+			absDirs, err := filepath.Abs(dirs)
+			require.NoError(t, err, "Setup: can't get absolute path of test directories")
+			got = strings.ReplaceAll(got, absDirs, "#DIRS#")
+
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Got expected loaded values in local config object")
+		})
+	}
+}
+
+func TestMain(m *testing.M) {
+	debug := flag.Bool("verbose", false, "Print debug log level information within the test")
+	flag.Parse()
+	if *debug {
+		logrus.StandardLogger().SetLevel(logrus.DebugLevel)
+	}
+
+	m.Run()
+	testutils.MergeCoverages()
+}
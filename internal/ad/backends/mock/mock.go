@@ -11,6 +11,9 @@ type Backend struct {
 	Dom                string
 	ServURL            string
 	HostKrb5CCNamePath string
+	// AcquireHostKrb5CC, if set, is called with HostKrb5CCNamePath on each HostKrb5CCName call, like backends
+	// acquiring a new machine ticket.
+	AcquireHostKrb5CC func(path string) error
 
 	Online        bool
 	ErrIsOnline   bool
@@ -39,6 +42,11 @@ func (m Backend) HostKrb5CCName() (string, error) {
 	if m.ErrKrb5CCName {
 		return "", errors.New("HostKrb5CCName returned an error")
 	}
+	if m.AcquireHostKrb5CC != nil {
+		if err := m.AcquireHostKrb5CC(m.HostKrb5CCNamePath); err != nil {
+			return "", err
+		}
+	}
 	return m.HostKrb5CCNamePath, nil
 }
 
@@ -0,0 +1,102 @@
+package winbind
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+// keytabVersion is the file format version of MIT and Heimdal keytabs.
+const keytabVersion = 0x0502
+
+// checkKeytab returns an error if the keytab at path can't be read or doesn't contain principal.
+func checkKeytab(path, principal string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid keytab %q", path))
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	principals, err := keytabPrincipals(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(principals, principal) {
+		return errors.New(gotext.Get("keytab does not contain machine principal %q", principal))
+	}
+	return nil
+}
+
+// keytabPrincipals returns the principals of the entries of the keytab read from r, as in "name/instance@REALM".
+func keytabPrincipals(r io.Reader) (principals []string, err error) {
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return nil, errors.New(gotext.Get("can't read keytab version: %v", err))
+	}
+	if version != keytabVersion {
+		return nil, errors.New(gotext.Get("unsupported keytab version 0x%04x", version))
+	}
+
+	for {
+		var size int32
+		if err := binary.Read(r, binary.BigEndian, &size); errors.Is(err, io.EOF) {
+			return principals, nil
+		} else if err != nil {
+			return nil, errors.New(gotext.Get("can't read keytab entry: %v", err))
+		}
+
+		// Negative sizes are holes left by removed entries.
+		if size < 0 {
+			if _, err := io.CopyN(io.Discard, r, -int64(size)); err != nil {
+				return nil, errors.New(gotext.Get("can't read keytab entry: %v", err))
+			}
+			continue
+		}
+
+		entry := make([]byte, size)
+		if _, err := io.ReadFull(r, entry); err != nil {
+			return nil, errors.New(gotext.Get("can't read keytab entry: %v", err))
+		}
+		principal, err := entryPrincipal(entry)
+		if err != nil {
+			return nil, err
+		}
+		principals = append(principals, principal)
+	}
+}
+
+// entryPrincipal returns the principal of a keytab entry.
+func entryPrincipal(entry []byte) (string, error) {
+	if len(entry) < 2 {
+		return "", errors.New(gotext.Get("truncated keytab entry"))
+	}
+	numComponents := int(binary.BigEndian.Uint16(entry))
+	entry = entry[2:]
+
+	// The realm comes first, followed by the components of the principal name.
+	var parts []string
+	for i := 0; i <= numComponents; i++ {
+		if len(entry) < 2 {
+			return "", errors.New(gotext.Get("truncated keytab entry"))
+		}
+		l := int(binary.BigEndian.Uint16(entry))
+		entry = entry[2:]
+		if len(entry) < l {
+			return "", errors.New(gotext.Get("truncated keytab entry"))
+		}
+		parts = append(parts, string(entry[:l]))
+		entry = entry[l:]
+	}
+
+	return fmt.Sprintf("%s@%s", strings.Join(parts[1:], "/"), parts[0]), nil
+}
@@ -42,8 +42,12 @@ wbcErr wbcInterfaceDetails(struct wbcInterfaceDetails **details) {
     }
 
     struct wbcInterfaceDetails *info = malloc(sizeof(struct wbcInterfaceDetails));
-    // This is the only field used at the moment
+    // These are the only fields used at the moment
     info->dns_domain = "example.com";
+    info->netbios_name = "UBUNTU";
+    if (strcmp(behavior, "netbios_name_not_found") == 0) {
+        info->netbios_name = NULL;
+    }
     *details = info;
     return WBC_ERR_SUCCESS;
 }
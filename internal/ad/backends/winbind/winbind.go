@@ -23,6 +23,18 @@ char *get_domain_name() {
   return strdup(info->dns_domain);
 }
 
+char *get_netbios_name() {
+  // Get NetBIOS name of the machine, which is its account name in AD
+  wbcErr wbc_status = WBC_ERR_UNKNOWN_FAILURE;
+  struct wbcInterfaceDetails *info;
+
+  wbc_status = wbcInterfaceDetails(&info);
+  if (wbc_status != WBC_ERR_SUCCESS || info->netbios_name == NULL) {
+    return NULL;
+  }
+  return strdup(info->netbios_name);
+}
+
 char *get_dc_name(char *domain) {
   // Get DC name from domain name
   wbcErr wbc_status = WBC_ERR_UNKNOWN_FAILURE;
@@ -73,7 +85,9 @@ type Winbind struct {
 	domain              string
 	defaultDomainSuffix string
 	kinitCmd            []string
-	hostname            string
+	// netbiosName is the name of the machine account in AD.
+	netbiosName string
+	keytab      string
 
 	config Config
 }
@@ -82,6 +96,7 @@ type Winbind struct {
 type Config struct {
 	ADServer string `mapstructure:"ad_server"` // bypass winbind and use this server
 	ADDomain string `mapstructure:"ad_domain"` // bypass domain name detection and use this domain
+	Keytab   string `mapstructure:"keytab"`    // keytab of the machine account, instead of the default one
 }
 
 // Option represents an optional function to change the winbind backend.
@@ -113,12 +128,27 @@ func New(ctx context.Context, c Config, hostname string, opts ...Option) (w Winb
 		}
 	}
 
+	// The machine account is named after the NetBIOS name of the machine, which can differ from its hostname,
+	// for instance when it is truncated to 15 characters.
+	netbiosName, err := netbiosName()
+	if err != nil {
+		log.Warningf(ctx, "Using hostname %q as machine account name: %v", hostname, err)
+		netbiosName = hostname
+	}
+
+	if c.Keytab != "" {
+		if err := checkKeytab(c.Keytab, machinePrincipal(netbiosName, c.ADDomain)); err != nil {
+			return Winbind{}, err
+		}
+	}
+
 	return Winbind{
 		staticServerFQDN:    c.ADServer,
 		domain:              c.ADDomain,
 		defaultDomainSuffix: c.ADDomain,
 		kinitCmd:            args.kinitCmd,
-		hostname:            hostname,
+		netbiosName:         netbiosName,
+		keytab:              c.Keytab,
 		config:              c,
 	}, nil
 }
@@ -135,12 +165,12 @@ func (w Winbind) HostKrb5CCName() (string, error) {
 	if os.Getenv("ADSYS_SKIP_ROOT_CALLS") != "" {
 		return target, nil
 	}
-	// Uppercase domain and hostname
-	domain := strings.ToUpper(w.domain)
-	hostname := strings.ToUpper(w.hostname)
-
-	principal := fmt.Sprintf("%s$@%s", hostname, domain)
-	cmdArgs := append(w.kinitCmd, "-k", principal, "-c", target)
+	principal := machinePrincipal(w.netbiosName, w.domain)
+	cmdArgs := append(w.kinitCmd, "-k")
+	if w.keytab != "" {
+		cmdArgs = append(cmdArgs, "-t", w.keytab)
+	}
+	cmdArgs = append(cmdArgs, principal, "-c", target)
 	smbsafe.WaitExec()
 	defer smbsafe.DoneExec()
 	if cmd, err := exec.Command(cmdArgs[0], cmdArgs[1:]...).CombinedOutput(); err != nil {
@@ -151,6 +181,11 @@ func (w Winbind) HostKrb5CCName() (string, error) {
 	return target, nil
 }
 
+// machinePrincipal returns the Kerberos principal of the machine account, with uppercase account name and domain.
+func machinePrincipal(netbiosName, domain string) string {
+	return fmt.Sprintf("%s$@%s", strings.ToUpper(netbiosName), strings.ToUpper(domain))
+}
+
 // DefaultDomainSuffix returns current default domain suffix.
 func (w Winbind) DefaultDomainSuffix() string {
 	return w.defaultDomainSuffix
@@ -178,7 +213,19 @@ func (w Winbind) ServerFQDN(ctx context.Context) (serverFQDN string, err error)
 
 // Config returns a stringified configuration for Winbind backend.
 func (w Winbind) Config() string {
-	return "Current backend is Winbind"
+	adServer := w.config.ADServer
+	if adServer == "" {
+		adServer = gotext.Get("autodiscovered")
+	}
+	keytab := w.config.Keytab
+	if keytab == "" {
+		keytab = gotext.Get("default")
+	}
+	return fmt.Sprintf(`Current backend is Winbind
+Domain: %s
+Machine account: %s
+AD server: %s
+Keytab: %s`, w.domain, strings.ToUpper(w.netbiosName), adServer, keytab)
 }
 
 // IsOnline refresh and returns if we are online.
@@ -201,6 +248,15 @@ func domainName() (string, error) {
 	return C.GoString(dc), nil
 }
 
+func netbiosName() (string, error) {
+	name := C.get_netbios_name()
+	if name == nil {
+		return "", errors.New(gotext.Get("could not get NetBIOS name"))
+	}
+	defer C.free(unsafe.Pointer(name))
+	return C.GoString(name), nil
+}
+
 func dcName(domain string) (string, error) {
 	cDomain := C.CString(domain)
 	defer C.free(unsafe.Pointer(cDomain))
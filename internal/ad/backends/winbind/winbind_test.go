@@ -30,18 +30,21 @@ func TestWinbind(t *testing.T) {
 		wbclientBehavior string
 		staticADDomain   string
 		staticADServer   string
+		keytab           string
 		hostname         string
 
 		wantKinitErr bool
 		wantErr      bool
 	}{
-		"Lookup is successful":                         {},
-		"Lookup with different hostname is successful": {hostname: "mycustomhostname"},
+		"Lookup is successful": {},
+		"Lookup with hostname different from NetBIOS name is successful": {hostname: "mycustomhostname"},
+		"Lookup without NetBIOS name uses hostname":                      {wbclientBehavior: "netbios_name_not_found", hostname: "mycustomhostname"},
 
 		// Override cases
 		"Lookup with overridden ad_domain":                  {staticADDomain: "overridden.com"},
 		"Lookup with overridden ad_server":                  {staticADServer: "controller.overridden.com"},
 		"Lookup with overridden ad_server with LDAP prefix": {staticADServer: "ldap://controller.overridden.com"},
+		"Lookup with keytab":                                {keytab: "machine"},
 
 		// Error cases
 		"Error when looking up domain":     {wbclientBehavior: "domain_not_found", wantErr: true},
@@ -49,6 +52,12 @@ func TestWinbind(t *testing.T) {
 		"Error when getting online status": {wbclientBehavior: "error_getting_online_status"},
 		"Error when domain is offline":     {wbclientBehavior: "domain_is_offline"},
 		"Error when requesting krb5cc":     {wantKinitErr: true},
+
+		"Error when keytab does not exist":                   {keytab: "doesnotexist", wantErr: true},
+		"Error when keytab does not contain machine account": {keytab: "other_machine", wantErr: true},
+		"Error when keytab version is unsupported":           {keytab: "unsupported_version", wantErr: true},
+		"Error when keytab is truncated":                     {keytab: "truncated", wantErr: true},
+		"Error when keytab is empty":                         {keytab: "empty", wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -68,6 +77,9 @@ func TestWinbind(t *testing.T) {
 			if tc.staticADServer != "" {
 				config.ADServer = tc.staticADServer
 			}
+			if tc.keytab != "" {
+				config.Keytab = filepath.Join("testdata", "keytabs", tc.keytab)
+			}
 
 			kinitCmdOutputFile := filepath.Join(t.TempDir(), "kinit-output")
 			kinitCmd := []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestExecuteKinitCommand", "--", kinitCmdOutputFile}
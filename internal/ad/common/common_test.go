@@ -1,6 +1,7 @@
 package adcommon_test
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -43,3 +44,83 @@ func TestGetVersionID(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadIni(t *testing.T) {
+	t.Parallel()
+
+	gpt := map[string]map[string]string{"General": {"Version": "42", "displayName": "Stratégie réseau"}}
+	scripts := map[string]map[string]string{
+		"Startup":  {"0CmdLine": "setup.sh", "0Parameters": "--verbose"},
+		"Shutdown": {"0CmdLine": "cleanup.sh", "0Parameters": ""},
+	}
+
+	tests := map[string]struct {
+		file string
+
+		want    map[string]map[string]string
+		wantErr bool
+	}{
+		"GPT.INI in UTF-8":              {file: "GPT-utf8.ini", want: gpt},
+		"GPT.INI in UTF-8 with BOM":     {file: "GPT-utf8-bom.ini", want: gpt},
+		"GPT.INI in UTF-16LE":           {file: "GPT-utf16le.ini", want: gpt},
+		"GPT.INI in UTF-16BE":           {file: "GPT-utf16be.ini", want: gpt},
+		"scripts.ini in UTF-8":          {file: "scripts-utf8.ini", want: scripts},
+		"scripts.ini in UTF-8 with BOM": {file: "scripts-utf8-bom.ini", want: scripts},
+		"scripts.ini in UTF-16LE":       {file: "scripts-utf16le.ini", want: scripts},
+		"scripts.ini in UTF-16BE":       {file: "scripts-utf16be.ini", want: scripts},
+
+		"Error on invalid ini in UTF-16": {file: "invalid-utf16le.ini", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			content, err := os.ReadFile(filepath.Join("testdata", "ini", tc.file))
+			require.NoError(t, err, "Setup: can't read ini file")
+
+			cfg, err := adcommon.LoadIni(content)
+			if tc.wantErr {
+				require.Error(t, err, "LoadIni should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "LoadIni failed but shouldn't have")
+
+			got := make(map[string]map[string]string)
+			for _, s := range cfg.Sections() {
+				if len(s.Keys()) == 0 {
+					continue
+				}
+				got[s.Name()] = s.KeysHash()
+			}
+			require.Equal(t, tc.want, got, "LoadIni returned unexpected sections and keys")
+		})
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		content []byte
+
+		want string
+	}{
+		"UTF-8 is kept as is":                {content: []byte("Version=1\n"), want: "Version=1\n"},
+		"UTF-8 BOM is removed":               {content: []byte("\xef\xbb\xbfVersion=1\n"), want: "Version=1\n"},
+		"UTF-16LE is converted to UTF-8":     {content: []byte("\xff\xfe\xe9\x00\n\x00"), want: "é\n"},
+		"UTF-16BE is converted to UTF-8":     {content: []byte("\xfe\xff\x00\xe9\x00\n"), want: "é\n"},
+		"Content without BOM is not decoded": {content: []byte("V\x00=\x001\x00"), want: "V\x00=\x001\x00"},
+		"Empty content":                      {content: []byte{}, want: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := adcommon.DecodeText(tc.content)
+			require.NoError(t, err, "DecodeText failed but shouldn't have")
+			require.Equal(t, tc.want, string(got), "DecodeText returned unexpected content")
+		})
+	}
+}
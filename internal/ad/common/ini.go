@@ -0,0 +1,33 @@
+package adcommon
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"gopkg.in/ini.v1"
+)
+
+// DecodeText returns content converted to UTF-8, detecting its encoding from its byte order mark as Windows tools
+// may save SYSVOL files, like GPT.INI or scripts.ini, in UTF-16. The byte order mark is removed.
+// Content without byte order mark is returned as is, assumed to be UTF-8.
+func DecodeText(content []byte) (decoded []byte, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't decode text"))
+
+	// The fallback only applies without byte order mark.
+	r := transform.NewReader(bytes.NewReader(content), unicode.BOMOverride(encoding.Nop.NewDecoder()))
+	return io.ReadAll(r)
+}
+
+// LoadIni parses the ini file content, saved in UTF-8 or in UTF-16 with a byte order mark.
+func LoadIni(content []byte) (cfg *ini.File, err error) {
+	decoded, err := DecodeText(content)
+	if err != nil {
+		return nil, err
+	}
+	return ini.Load(decoded)
+}
@@ -27,68 +27,103 @@ For each logged in user (sequentially):
 <download call>
   mutex for download
   set KRB5CCNAME
-  download all GPO concurrently
+  download all GPO concurrently, up to the configured limit
+  commit them all if every download succeeded, or none of them
   unset KRB5CCNAME
   release mutex
 
 */
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/mvo5/libsmbclient-go"
+	adcommon "github.com/ubuntu/adsys/internal/ad/common"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/smbsafe"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
-	"gopkg.in/ini.v1"
 )
 
 /*
 fetch downloads a list of gpos from a url for a given kerberosTicket and stores the downloaded files in dest.
 In addition, assetsURL is always refreshed if not empty.
 Each gpo entry must be a gpo, with a name, url of the form: smb://<server>/SYSVOL/<AD domain>/<GPO_ID> and mutex.
+With a local backend, the url is of the form file://<directory>/Policies/<GPO_ID> and the gpo is copied from it.
 If krb5Ticket is empty, no authentication is done on samba.
+Gpos and assets are only downloaded if their GPT.INI version on AD is more recent than the cached one, unless
+forceRefresh is true.
+Up to the configured concurrency of gpos are downloaded simultaneously. They are only committed to the cache once
+all of them were downloaded successfully, and the previous cache content is restored if any of them can't be
+committed, so that a failure never leaves a partial set of refreshed gpos.
 This should not be called concurrently.
 
 It returns if the assets were refreshed or not.
 */
-func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[string]string) (assetsWereRefreshed bool, err error) {
+func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[string]string, forceRefresh bool) (assetsWereRefreshed bool, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't download all gpos and assets"))
 
 	// protect env variable and map creation
 	ad.fetchMu.Lock()
 	defer ad.fetchMu.Unlock()
 
-	// Set kerberos ticket.
-	const krb5TicketEnv = "KRB5CCNAME"
-	oldKrb5Ticket := os.Getenv(krb5TicketEnv)
-	if err := os.Setenv(krb5TicketEnv, krb5Ticket); err != nil {
-		return false, err
+	// GPOs of a local directory are copied without any ticket nor connection.
+	var src sysvolSource = localSysvol{}
+	if ad.localBackend == nil {
+		// Set kerberos ticket.
+		const krb5TicketEnv = "KRB5CCNAME"
+		oldKrb5Ticket := os.Getenv(krb5TicketEnv)
+		if err := os.Setenv(krb5TicketEnv, krb5Ticket); err != nil {
+			return false, err
+		}
+		defer func() {
+			if err := os.Setenv(krb5TicketEnv, oldKrb5Ticket); err != nil {
+				log.Errorf(ctx, "Couln't restore initial value for %s: %v", krb5Ticket, err)
+			}
+		}()
+
+		client, err := ad.newSMBClient(ctx)
+		if err != nil {
+			return false, err
+		}
+		defer client.Close()
+		// When testing we cannot use kerberos without a real kerberos server
+		// So we don't use kerberos in this case.
+		if !ad.withoutKerberos {
+			client.SetUseKerberos()
+		}
+		src = smbSysvol{client: client}
 	}
+
+	var stagedMu sync.Mutex
+	var toCommit []stagedDownload
+	var errs []error
+
+	// Clean up temporary directories left behind by failed or uncommitted downloads.
 	defer func() {
-		if err := os.Setenv(krb5TicketEnv, oldKrb5Ticket); err != nil {
-			log.Errorf(ctx, "Couln't restore initial value for %s: %v", krb5Ticket, err)
+		for _, s := range toCommit {
+			if s.tmpdest == "" {
+				continue
+			}
+			if err := os.RemoveAll(s.tmpdest); err != nil {
+				log.Info(ctx, gotext.Get("Could not clean up temporary directory:"), err)
+			}
 		}
 	}()
 
-	client := libsmbclient.New()
-	defer client.Close()
-	// When testing we cannot use kerberos without a real kerberos server
-	// So we don't use kerberos in this case
-	if !ad.withoutKerberos {
-		client.SetUseKerberos()
-	}
-
 	var errg errgroup.Group
+	errg.SetLimit(int(ad.gpoDownloadConcurrency.Load()))
 	for name, url := range downloadables {
 		g, ok := ad.downloadables[name]
 		if !ok {
@@ -104,7 +139,22 @@ func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[st
 			g = ad.downloadables[name]
 		}
 		errg.Go(func() (err error) {
-			defer decorate.OnError(&err, gotext.Get("can't download %q", g.name))
+			// Errors are aggregated: carry on with the other downloads to report all of them.
+			defer func() {
+				if err == nil {
+					return
+				}
+				stagedMu.Lock()
+				defer stagedMu.Unlock()
+				if isConnectionError(err) {
+					errs = append(errs, fmt.Errorf("%w: %s", errSysvolUnreachable, gotext.Get("can't download %q: %v", g.name, err)))
+					return
+				}
+				errs = append(errs, errors.New(gotext.Get("can't download %q: %v", g.name, err)))
+			}()
+			if ad.downloadHook != nil {
+				ad.downloadHook()
+			}
 
 			smbsafe.WaitSmb()
 			defer smbsafe.DoneSmb()
@@ -117,16 +167,15 @@ func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[st
 			}
 
 			// Look at GPO version and compare with the one on AD to decide if we redownload or not
-			shouldDownload, err := needsDownload(ctx, client, g, dest)
+			shouldDownload, err := needsDownload(ctx, src, g, dest, forceRefresh)
 			if err != nil {
 				if g.isAssets && errors.Is(err, errNoGPTINI) {
 					log.Info(ctx, "No assets directory with GPT.INI file found on AD, skipping assets download")
 					if _, err := os.Stat(dest); err == nil {
 						// we remove the assets existing directory. We need to repack the db.
-						assetsWereRefreshed = true
-						if err := os.RemoveAll(dest); err != nil {
-							return err
-						}
+						stagedMu.Lock()
+						defer stagedMu.Unlock()
+						toCommit = append(toCommit, stagedDownload{g: g, dest: dest})
 					}
 					return nil
 				}
@@ -144,29 +193,187 @@ func (ad *AD) fetch(ctx context.Context, krb5Ticket string, downloadables map[st
 			}
 
 			log.Infof(ctx, "Downloading %q", g.name)
-			g.mu.Lock()
-			defer g.mu.Unlock()
-			g.testConcurrent = true
-			if g.isAssets {
-				assetsWereRefreshed = true
+			tmpdest, err := downloadDir(ctx, src, g.url, dest)
+			if err != nil {
+				return err
 			}
-
-			return downloadDir(ctx, client, g.url, dest)
+			stagedMu.Lock()
+			defer stagedMu.Unlock()
+			toCommit = append(toCommit, stagedDownload{g: g, dest: dest, tmpdest: tmpdest})
+			return nil
 		})
 	}
 
-	if err := errg.Wait(); err != nil {
-		return false, fmt.Errorf("one or more error while fetching GPOs and assets: %w", err)
+	_ = errg.Wait()
+	if errs != nil {
+		return false, fmt.Errorf("one or more error while fetching GPOs and assets: %w", errors.Join(errs...))
+	}
+
+	// All downloads succeeded: commit them to the cache.
+	if err := commitDownloads(ctx, toCommit); err != nil {
+		return false, err
+	}
+	for _, s := range toCommit {
+		if s.g.isAssets {
+			assetsWereRefreshed = true
+		}
 	}
+	toCommit = nil
 
 	return assetsWereRefreshed, nil
 }
 
+// stagedDownload is a downloadable refreshed in a temporary directory, committed once all downloads succeeded.
+// An empty tmpdest removes the downloadable from the cache.
+type stagedDownload struct {
+	g       *downloadable
+	dest    string
+	tmpdest string
+}
+
+// commitDownloads replaces the cached content of each staged downloadable in its dest with the one downloaded in its
+// tmpdest, or removes it if tmpdest is empty.
+// The previous content is moved aside until all of them are committed: on error, it is restored and the downloaded
+// content is moved back to tmpdest, so that the cache is never left with only a part of the refreshed downloadables.
+func commitDownloads(ctx context.Context, staged []stagedDownload) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't commit downloaded gpos and assets"))
+
+	// Readers only hold one downloadable lock at a time: taking them all in the same order can't deadlock.
+	staged = slices.Clone(staged)
+	slices.SortFunc(staged, func(a, b stagedDownload) int { return strings.Compare(a.g.name, b.g.name) })
+	for _, s := range staged {
+		s.g.mu.Lock()
+		defer s.g.mu.Unlock()
+		s.g.testConcurrent = true
+	}
+
+	// committed is a downloadable moved in the cache, with its previous content moved in backup, if any.
+	type committed struct {
+		stagedDownload
+		backup    string
+		installed bool
+	}
+	var done []*committed
+	defer func() {
+		if err == nil {
+			for _, c := range done {
+				if c.backup == "" {
+					continue
+				}
+				if err := os.RemoveAll(c.backup); err != nil {
+					log.Info(ctx, gotext.Get("Could not clean up previous cache content:"), err)
+				}
+			}
+			return
+		}
+
+		// Roll back in reverse order.
+		for i := len(done) - 1; i >= 0; i-- {
+			c := done[i]
+			if c.installed {
+				if err := os.Rename(c.dest, c.tmpdest); err != nil {
+					log.Warningf(ctx, "Could not move back downloaded %q: %v", c.g.name, err)
+				}
+			}
+			if c.backup != "" {
+				if err := os.Rename(c.backup, c.dest); err != nil {
+					log.Warningf(ctx, "Could not restore previous cache content of %q: %v", c.g.name, err)
+				}
+			}
+		}
+	}()
+
+	for _, s := range staged {
+		c := &committed{stagedDownload: s}
+		if _, err := os.Stat(s.dest); err == nil {
+			// Reserve a unique name next to dest, on the same filesystem. It is free again for the rename, which doesn't
+			// replace directories: downloads are not fetched concurrently.
+			backup, err := os.MkdirTemp(filepath.Dir(s.dest), fmt.Sprintf("%s.old.*", filepath.Base(s.dest)))
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(backup); err != nil {
+				return err
+			}
+			if err := os.Rename(s.dest, backup); err != nil {
+				return err
+			}
+			c.backup = backup
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		done = append(done, c)
+
+		if s.tmpdest == "" {
+			continue
+		}
+		if err := os.Rename(s.tmpdest, s.dest); err != nil {
+			return err
+		}
+		c.installed = true
+	}
+
+	return nil
+}
+
 var errNoGPTINI = errors.New("no GPT.INI file")
 
+// sysvolSource is where GPOs and assets are fetched from.
+type sysvolSource interface {
+	// readGPTIni returns the content of the GPT.INI file of the directory at url.
+	readGPTIni(url string) ([]byte, error)
+	// download copies the directory at url to dest, recursively.
+	download(ctx context.Context, url, dest string) error
+}
+
+// smbSysvol fetches GPOs and assets from SYSVOL on a domain controller.
+type smbSysvol struct {
+	client *libsmbclient.Client
+}
+
+func (s smbSysvol) readGPTIni(url string) ([]byte, error) {
+	f, err := s.client.Open(fmt.Sprintf("%s/GPT.INI", url), 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// Read() is on *libsmbclient.File, not libsmbclient.File
+	pf := &f
+	return io.ReadAll(pf)
+}
+
+func (s smbSysvol) download(ctx context.Context, url, dest string) error {
+	// Check if we have a file or a directory
+	d, err := s.client.Opendir(url)
+	if err != nil {
+		return err
+	}
+
+	// It is a directory: recursive download
+	if err := d.Closedir(); err != nil {
+		return errors.New(gotext.Get("could not close directory: %v", err))
+	}
+
+	return downloadRecursive(ctx, s.client, url, dest)
+}
+
+// errSysvolUnreachable is returned when GPOs can't be downloaded because SYSVOL can't be reached.
+var errSysvolUnreachable = errors.New(gotext.Get("SYSVOL is unreachable"))
+
+// isConnectionError returns if err is due to the SMB server not being reachable.
+// libsmbclient only reports the errno message, so we can't check the error type.
+func isConnectionError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.ECONNREFUSED, syscall.EHOSTUNREACH, syscall.ENETUNREACH, syscall.EHOSTDOWN, syscall.ETIMEDOUT} {
+		if strings.Contains(err.Error(), errno.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
 // needsDownload returns if the downloadable should be refreshed.
-// This is done by comparing GPT.INI Version= content.
-func needsDownload(ctx context.Context, client *libsmbclient.Client, g *downloadable, localPath string) (updateNeeded bool, err error) {
+// This is done by comparing GPT.INI Version= content, unless force is true and the remote GPT.INI is valid.
+func needsDownload(ctx context.Context, src sysvolSource, g *downloadable, localPath string, force bool) (updateNeeded bool, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't check if %s needs refreshing", g.name))
 
 	g.mu.RLock()
@@ -183,19 +390,20 @@ func needsDownload(ctx context.Context, client *libsmbclient.Client, g *download
 		}
 	}
 
-	f, err := client.Open(fmt.Sprintf("%s/GPT.INI", g.url), 0, 0)
+	gptIni, err := src.readGPTIni(g.url)
 	if err != nil {
 		// nolint:errorlint // We cannot have multiple error wrapping directives in a single call
 		return false, fmt.Errorf("%w: %v", errNoGPTINI, err)
 	}
-	defer f.Close()
-	// Read() is on *libsmbclient.File, not libsmbclient.File
-	pf := &f
-	if remoteVersion, err = getGPOVersion(ctx, pf, g.name); err != nil {
+	if remoteVersion, err = getGPOVersion(ctx, bytes.NewReader(gptIni), g.name); err != nil {
 		return false, err
 	}
 
 	log.Debugf(ctx, "Local version for %q: %d, remote version: %d", g.name, localVersion, remoteVersion)
+	if force {
+		log.Debugf(ctx, "Forcing refresh of %q", g.name)
+		return true, nil
+	}
 	if localVersion >= remoteVersion {
 		return false, nil
 	}
@@ -211,7 +419,8 @@ func getGPOVersion(ctx context.Context, r io.Reader, downloadableName string) (v
 		return 0, err
 	}
 
-	cfg, err := ini.Load(buf)
+	// GPT.INI can be saved in UTF-16 by Windows tools.
+	cfg, err := adcommon.LoadIni(buf)
 	if err != nil {
 		return 0, err
 	}
@@ -230,46 +439,26 @@ func getGPOVersion(ctx context.Context, r io.Reader, downloadableName string) (v
 	return version, nil
 }
 
-// downloadDir will dl in a temporary directory and only commit it if fully downloaded without any errors.
-func downloadDir(ctx context.Context, client *libsmbclient.Client, url, dest string) (err error) {
+// downloadDir will dl in a temporary directory next to dest, returned only if fully downloaded without any errors.
+// It is up to the caller to commit or remove the returned directory.
+func downloadDir(ctx context.Context, src sysvolSource, url, dest string) (tmpdest string, err error) {
 	defer decorate.OnError(&err, gotext.Get("download %q failed", url))
 
 	smbsafe.WaitSmb()
 	defer smbsafe.DoneSmb()
 
-	// Check if we have a file or a directory
-	d, err := client.Opendir(url)
-	if err != nil {
-		return err
-	}
-
-	// It is a directory: recursive download
-	if err := d.Closedir(); err != nil {
-		return errors.New(gotext.Get("could not close directory: %v", err))
-	}
-
-	tmpdest, err := os.MkdirTemp(filepath.Dir(dest), fmt.Sprintf("%s.*", filepath.Base(dest)))
+	tmpdest, err = os.MkdirTemp(filepath.Dir(dest), fmt.Sprintf("%s.*", filepath.Base(dest)))
 	if err != nil {
-		return err
+		return "", err
 	}
-	// Always to try remove temporary directory, so that in case of any failures, it’s not left behind
-	defer func() {
+	if err := src.download(ctx, url, tmpdest); err != nil {
+		// Always to try remove temporary directory, so that in case of any failures, it’s not left behind
 		if err := os.RemoveAll(tmpdest); err != nil {
 			log.Info(ctx, gotext.Get("Could not clean up temporary directory:"), err)
 		}
-	}()
-	if err := downloadRecursive(ctx, client, url, tmpdest); err != nil {
-		return err
-	}
-	// Remove previous download content
-	if err := os.RemoveAll(dest); err != nil {
-		return err
+		return "", err
 	}
-	// Rename temporary directory to final location
-	if err := os.Rename(tmpdest, dest); err != nil {
-		return err
-	}
-	return nil
+	return tmpdest, nil
 }
 
 func downloadRecursive(ctx context.Context, client *libsmbclient.Client, url, dest string) error {
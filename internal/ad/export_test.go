@@ -1,10 +1,21 @@
 package ad
 
+import "context"
+
 var (
-	WithoutKerberos = withoutKerberos
-	WithGPOListCmd  = withGPOListCmd
+	WithoutKerberos  = withoutKerberos
+	WithGPOListCmd   = withGPOListCmd
+	WithServerCheck  = withServerCheck
+	WithSRVLookup    = withSRVLookup
+	WithKinitCmd     = withKinitCmd
+	WithDownloadHook = withDownloadHook
 )
 
+// ServerFQDN returns the domain controller GPOs are fetched from.
+func (ad *AD) ServerFQDN(ctx context.Context) (string, error) {
+	return ad.serverFQDN(ctx)
+}
+
 func (ad *AD) SysvolCacheDir() string {
 	return ad.sysvolCacheDir
 }
@@ -0,0 +1,422 @@
+// Package filter evaluates the machine attributes filters of GPOs, emulating WMI filters.
+//
+// A filter is an expression evaluated against facts of the local machine. A GPO with a filter which doesn’t
+// match is skipped. The expression compares facts to quoted strings:
+//
+//	hostname matches "lab-*" && (os.version_id >= "22.04" || arch == "arm64")
+//
+// Available facts are:
+//   - hostname: the machine name, without its domain.
+//   - arch: the CPU architecture, like amd64 or arm64.
+//   - os.<field>: any field of os-release, in lower case, like os.id, os.version_id or os.version_codename.
+//     Fields missing from os-release are empty.
+//
+// Comparison operators are == and != for exact matches, matches for shell patterns, like "lab-*" or
+// "web-[0-9]?", and <, <=, > and >= comparing versions component by component, like "22.04" < "22.10".
+// Comparisons are combined with && (and), || (or) and ! (not), and grouped with parentheses, && having
+// precedence over ||.
+//
+// There is no function call nor any other side effect. The size and nesting of expressions are limited.
+package filter
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// maxLength is the maximum length of a filter expression.
+	maxLength = 4096
+	// maxDepth is the maximum nesting of a filter expression.
+	maxDepth = 32
+
+	osFactPrefix = "os."
+)
+
+// Facts are the attributes of the machine a filter is evaluated against, by name.
+type Facts map[string]string
+
+// GetFacts returns the facts of the machine named hostname, reading os-release from root.
+func GetFacts(root, hostname string) (facts Facts, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get machine facts"))
+
+	facts = Facts{
+		"hostname": hostname,
+		"arch":     runtime.GOARCH,
+	}
+
+	file, err := os.Open(filepath.Join(root, "etc/os-release"))
+	if err != nil {
+		return nil, err
+	}
+	defer decorate.LogFuncOnError(file.Close)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		if u, err := strconv.Unquote(v); err == nil {
+			v = u
+		} else {
+			v = strings.Trim(v, `"'`)
+		}
+		facts[osFactPrefix+strings.ToLower(k)] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return facts, nil
+}
+
+// Filter is a parsed filter expression.
+type Filter struct {
+	root node
+}
+
+// Parse parses the filter expression expr.
+func Parse(expr string) (f Filter, err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid filter %q", expr))
+
+	if len(expr) > maxLength {
+		return Filter{}, errors.New(gotext.Get("filter is longer than %d characters", maxLength))
+	}
+
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return Filter{}, err
+	}
+	p := parser{tokens: tokens}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return Filter{}, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return Filter{}, errors.New(gotext.Get("unexpected %q", t.value))
+	}
+
+	return Filter{root: root}, nil
+}
+
+// Match returns if facts match the filter.
+func (f Filter) Match(facts Facts) bool {
+	return f.root.eval(facts)
+}
+
+// Evaluate parses the filter expression expr and returns if facts match it.
+func Evaluate(expr string, facts Facts) (bool, error) {
+	f, err := Parse(expr)
+	if err != nil {
+		return false, err
+	}
+	return f.Match(facts), nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOperator
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// operators are the comparison operators, the longest first for tokenizing.
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// tokenize splits expr into tokens.
+func tokenize(expr string) (tokens []token, err error) {
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "("})
+			i++
+			continue
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")"})
+			i++
+			continue
+		case strings.HasPrefix(expr[i:], "&&"):
+			tokens = append(tokens, token{tokenAnd, "&&"})
+			i += 2
+			continue
+		case strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, token{tokenOr, "||"})
+			i += 2
+			continue
+		case c == '"':
+			s, n, err := readString(expr[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{tokenString, s})
+			i += n
+			continue
+		case isIdentChar(rune(c)):
+			n := strings.IndexFunc(expr[i:], func(r rune) bool { return !isIdentChar(r) })
+			if n == -1 {
+				n = len(expr) - i
+			}
+			ident := expr[i : i+n]
+			kind := tokenIdent
+			if ident == "matches" {
+				kind = tokenOperator
+			}
+			tokens = append(tokens, token{kind, ident})
+			i += n
+			continue
+		}
+
+		var op string
+		for _, o := range operators {
+			if strings.HasPrefix(expr[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op != "" {
+			tokens = append(tokens, token{tokenOperator, op})
+			i += len(op)
+			continue
+		}
+		if c == '!' {
+			tokens = append(tokens, token{tokenNot, "!"})
+			i++
+			continue
+		}
+		return nil, errors.New(gotext.Get("unexpected character %q", c))
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// readString reads the double quoted string starting s, where \" and \\ are escaped.
+// It returns the unquoted string and the number of bytes read.
+func readString(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 == len(s) || (s[i+1] != '"' && s[i+1] != '\\') {
+				return "", 0, errors.New(gotext.Get(`only \" and \\ can be escaped in strings`))
+			}
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return "", 0, errors.New(gotext.Get("string %s is not terminated", s))
+}
+
+func isIdentChar(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-')
+}
+
+// parser is a recursive descent parser for the filter grammar:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" or ")" | comparison
+//	comparison = fact operator string
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr(depth int) (node, error) {
+	if depth > maxDepth {
+		return nil, errors.New(gotext.Get("filter is nested more than %d times", maxDepth))
+	}
+
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (node, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary(depth int) (node, error) {
+	switch t := p.next(); t.kind {
+	case tokenNot:
+		if depth+1 > maxDepth {
+			return nil, errors.New(gotext.Get("filter is nested more than %d times", maxDepth))
+		}
+		n, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case tokenOpen:
+		n, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenClose {
+			return nil, errors.New(gotext.Get("missing closing parenthesis"))
+		}
+		return n, nil
+	case tokenIdent:
+		return p.parseComparison(t.value)
+	case tokenEOF:
+		return nil, errors.New(gotext.Get("unexpected end of filter"))
+	default:
+		return nil, errors.New(gotext.Get("unexpected %q, expecting a fact", t.value))
+	}
+}
+
+func (p *parser) parseComparison(fact string) (node, error) {
+	if fact != "hostname" && fact != "arch" && (!strings.HasPrefix(fact, osFactPrefix) || fact == osFactPrefix) {
+		return nil, errors.New(gotext.Get("unknown fact %q", fact))
+	}
+
+	op := p.next()
+	if op.kind != tokenOperator {
+		return nil, errors.New(gotext.Get("expecting a comparison operator after %q", fact))
+	}
+	value := p.next()
+	if value.kind != tokenString {
+		return nil, errors.New(gotext.Get("expecting a quoted string after %q", op.value))
+	}
+	if op.value == "matches" {
+		if _, err := path.Match(value.value, ""); err != nil {
+			return nil, errors.New(gotext.Get("invalid pattern %q", value.value))
+		}
+	}
+
+	return comparisonNode{fact: fact, op: op.value, value: value.value}, nil
+}
+
+type node interface {
+	eval(facts Facts) bool
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(facts Facts) bool { return n.left.eval(facts) || n.right.eval(facts) }
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(facts Facts) bool { return n.left.eval(facts) && n.right.eval(facts) }
+
+type notNode struct{ n node }
+
+func (n notNode) eval(facts Facts) bool { return !n.n.eval(facts) }
+
+type comparisonNode struct {
+	fact  string
+	op    string
+	value string
+}
+
+func (n comparisonNode) eval(facts Facts) bool {
+	v := facts[n.fact]
+	switch n.op {
+	case "==":
+		return v == n.value
+	case "!=":
+		return v != n.value
+	case "matches":
+		// The pattern was validated on parsing.
+		match, _ := path.Match(n.value, v)
+		return match
+	case "<":
+		return compareVersions(v, n.value) < 0
+	case "<=":
+		return compareVersions(v, n.value) <= 0
+	case ">":
+		return compareVersions(v, n.value) > 0
+	case ">=":
+		return compareVersions(v, n.value) >= 0
+	}
+	return false
+}
+
+// compareVersions compares the dot separated components of versions a and b, numerically when both are numbers.
+// A version with more components is greater than its prefix.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		if aErr == nil && bErr == nil {
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,143 @@
+package filter_test
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/ad/filter"
+)
+
+func TestGetFacts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		want    filter.Facts
+		wantErr bool
+	}{
+		"Read os-release fields": {
+			want: filter.Facts{
+				"hostname":            "myhost",
+				"arch":                runtime.GOARCH,
+				"os.pretty_name":      "Ubuntu 22.04.3 LTS",
+				"os.name":             "Ubuntu",
+				"os.version_id":       "22.04",
+				"os.version":          "22.04.3 LTS (Jammy Jellyfish)",
+				"os.version_codename": "jammy",
+				"os.id":               "ubuntu",
+				"os.id_like":          "debian",
+				"os.home_url":         "https://www.ubuntu.com/",
+			},
+		},
+
+		"Error on missing os-release file": {wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			facts, err := filter.GetFacts(filepath.Join("testdata", name), "myhost")
+			if tc.wantErr {
+				require.Error(t, err, "GetFacts should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "GetFacts failed but shouldn't have")
+
+			require.Equal(t, tc.want, facts, "GetFacts returned unexpected facts")
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	facts := filter.Facts{
+		"hostname":            "lab-42",
+		"arch":                "amd64",
+		"os.id":               "ubuntu",
+		"os.version_id":       "22.04",
+		"os.version_codename": "jammy",
+	}
+
+	tests := map[string]struct {
+		expr string
+
+		want    bool
+		wantErr bool
+	}{
+		// Comparisons
+		"Equal fact matches":                          {expr: `os.id == "ubuntu"`, want: true},
+		"Equal fact does not match":                   {expr: `os.id == "debian"`, want: false},
+		"Different fact matches":                      {expr: `arch != "arm64"`, want: true},
+		"Different fact does not match":               {expr: `arch != "amd64"`, want: false},
+		"Pattern matches":                             {expr: `hostname matches "lab-*"`, want: true},
+		"Pattern with class matches":                  {expr: `hostname matches "lab-[0-9][0-9]"`, want: true},
+		"Pattern does not match":                      {expr: `hostname matches "web-?"`, want: false},
+		"Pattern must match the whole fact":           {expr: `hostname matches "lab"`, want: false},
+		"Higher version matches":                      {expr: `os.version_id > "20.04"`, want: true},
+		"Version components are compared as numbers":  {expr: `os.version_id > "9.10"`, want: true},
+		"Same version matches greater or equal":       {expr: `os.version_id >= "22.04"`, want: true},
+		"Same version matches lower or equal":         {expr: `os.version_id <= "22.04"`, want: true},
+		"Lower version does not match":                {expr: `os.version_id < "20.04"`, want: false},
+		"Longer version is greater than its prefix":   {expr: `os.version_id < "22.04.1"`, want: true},
+		"Missing os-release field is empty":           {expr: `os.variant_id == ""`, want: true},
+		"Escaped quotes and backslashes in strings":   {expr: `os.id != "a\"b\\c"`, want: true},
+		"Spaces are not significant between tokens":   {expr: "os.id==\"ubuntu\"&&\n\tarch==\"amd64\"", want: true},
+		"Fact values are compared with case":          {expr: `os.id == "Ubuntu"`, want: false},
+		"Wildcard pattern matches a missing field":    {expr: `os.build_id matches "*"`, want: true},
+		"Version compared with non numeric component": {expr: `os.version_codename > "focal"`, want: true},
+
+		// Logical operators
+		"And matches when both match":             {expr: `os.id == "ubuntu" && arch == "amd64"`, want: true},
+		"And does not match when one doesn't":     {expr: `os.id == "ubuntu" && arch == "arm64"`, want: false},
+		"Or matches when one matches":             {expr: `os.id == "debian" || arch == "amd64"`, want: true},
+		"Or does not match when none match":       {expr: `os.id == "debian" || arch == "arm64"`, want: false},
+		"Not inverts the match":                   {expr: `!(arch == "arm64")`, want: true},
+		"Not applies to the comparison":           {expr: `!arch == "arm64"`, want: true},
+		"And has precedence over or":              {expr: `os.id == "ubuntu" || arch == "arm64" && hostname == "web"`, want: true},
+		"Parentheses group expressions":           {expr: `(os.id == "ubuntu" || arch == "arm64") && hostname == "web"`, want: false},
+		"Nested parentheses":                      {expr: `((hostname matches "lab-*") && !(os.version_id < "22.04"))`, want: true},
+		"Maximum nesting is allowed":              {expr: strings.Repeat("(", 32) + `arch == "amd64"` + strings.Repeat(")", 32), want: true},
+		"Double negation keeps the initial match": {expr: `!!(arch == "amd64")`, want: true},
+
+		// Error cases
+		"Error on empty filter":                   {expr: "", wantErr: true},
+		"Error on unknown fact":                   {expr: `kernel == "6.0"`, wantErr: true},
+		"Error on os prefix without a field":      {expr: `os. == "ubuntu"`, wantErr: true},
+		"Error on missing operator":               {expr: `arch "amd64"`, wantErr: true},
+		"Error on unknown operator":               {expr: `arch = "amd64"`, wantErr: true},
+		"Error on unquoted value":                 {expr: `arch == amd64`, wantErr: true},
+		"Error on unterminated string":            {expr: `arch == "amd64`, wantErr: true},
+		"Error on invalid escape in string":       {expr: `arch == "amd\64"`, wantErr: true},
+		"Error on invalid pattern":                {expr: `hostname matches "lab-[0-9"`, wantErr: true},
+		"Error on value compared to a fact":       {expr: `"amd64" == arch`, wantErr: true},
+		"Error on missing closing parenthesis":    {expr: `(arch == "amd64"`, wantErr: true},
+		"Error on unexpected closing parenthesis": {expr: `arch == "amd64")`, wantErr: true},
+		"Error on dangling logical operator":      {expr: `arch == "amd64" &&`, wantErr: true},
+		"Error on single ampersand":               {expr: `arch == "amd64" & os.id == "ubuntu"`, wantErr: true},
+		"Error on unexpected character":           {expr: `arch == "amd64"; os.id == "ubuntu"`, wantErr: true},
+		"Error on non ASCII fact":                 {expr: `hôstname == "lab"`, wantErr: true},
+		"Error on function call":                  {expr: `exec("rm")`, wantErr: true},
+		"Error on too deep nesting":               {expr: strings.Repeat("(", 33) + `arch == "amd64"` + strings.Repeat(")", 33), wantErr: true},
+		"Error on too many negations":             {expr: strings.Repeat("!", 33) + `arch == "amd64"`, wantErr: true},
+		"Error on too long filter":                {expr: `hostname == "` + strings.Repeat("a", 4096) + `"`, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := filter.Evaluate(tc.expr, facts)
+			if tc.wantErr {
+				require.Error(t, err, "Evaluate should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "Evaluate failed but shouldn't have")
+
+			require.Equal(t, tc.want, got, "Evaluate returned an unexpected match")
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	adcommon "github.com/ubuntu/adsys/internal/ad/common"
+	"github.com/ubuntu/adsys/internal/ad/filter"
+	"github.com/ubuntu/adsys/internal/ad/registry"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// filterKeyType is the type of the policy keys filtering GPOs. They are not applied by any manager.
+	filterKeyType = "filter"
+	// machineFilterKey is the policy key of the machine attributes filter of a GPO.
+	machineFilterKey = filterKeyType + "/machine-attributes/all"
+)
+
+// matchesMachineFilter returns if the machine matches the machine attributes filter of the GPO stored in gpoDir.
+// Like WMI filters, the filter is set in the machine policies of the GPO and applies to the whole GPO, for the
+// machine and its users. GPOs without a filter always match.
+func (ad *AD) matchesMachineFilter(ctx context.Context, gpoDir string) (match bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't evaluate machine attributes filter"))
+
+	f, err := openRegistryPol(gpoDir, ComputerObject)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer decorate.LogFuncOnErrorContext(ctx, f.Close)
+
+	pols, err := registry.DecodePolicy(f)
+	if err != nil {
+		return false, errors.New(gotext.Get("%s: %v", f.Name(), err))
+	}
+
+	key := fmt.Sprintf("%s/%s/%s", adcommon.KeyPrefix, consts.DistroID, machineFilterKey)
+	for _, pol := range pols {
+		if pol.Key != key {
+			continue
+		}
+		if pol.Err != nil {
+			return false, errors.New(gotext.Get("%s: %v", f.Name(), pol.Err))
+		}
+		expr := strings.TrimSpace(pol.Value)
+		if pol.Disabled || expr == "" {
+			return true, nil
+		}
+
+		log.Debugf(ctx, "Evaluating machine attributes filter %q", expr)
+		return filter.Evaluate(expr, ad.machineFacts)
+	}
+
+	return true, nil
+}
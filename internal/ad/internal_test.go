@@ -9,13 +9,16 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
 	"github.com/ubuntu/adsys/internal/ad/backends/mock"
+	"github.com/ubuntu/adsys/internal/consts"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -32,6 +35,7 @@ func TestFetch(t *testing.T) {
 		concurrentGposDownload []string
 		existing               map[string]string
 		makeReadOnlyOnSource   []string
+		forceRefresh           bool
 
 		want                map[string]string
 		wantAssetsRefreshed bool
@@ -136,6 +140,22 @@ func TestFetch(t *testing.T) {
 			},
 		},
 
+		"local gpo more recent than AD one is downloaded again when forced": {
+			gpos:         []string{"gpo2"},
+			existing:     map[string]string{"Policies/gpo2": "Policies/new_version"},
+			forceRefresh: true,
+			want:         map[string]string{"Policies/gpo2": "Policies/gpo2"},
+		},
+		"two gpos, one up to date, one new, both downloaded when forced": {
+			gpos:         []string{"gpo1", "gpo2"},
+			existing:     map[string]string{"Policies/gpo1": "Policies/new_version"},
+			forceRefresh: true,
+			want: map[string]string{
+				"Policies/gpo1": "Policies/gpo1",
+				"Policies/gpo2": "Policies/gpo2",
+			},
+		},
+
 		"Local gpo redownloaded on missing GPT.INI": {
 			gpos:     []string{"gpo1"},
 			existing: map[string]string{"Policies/gpo1": "Policies/missing_gpt_ini"},
@@ -193,6 +213,14 @@ func TestFetch(t *testing.T) {
 			want:                map[string]string{"assets": "Distro"},
 			wantAssetsRefreshed: false,
 		},
+		"assets are updated when forced even if version matches": {
+			adDomain:            "assetsonly.com",
+			assetsURL:           "Distrolowercasegptextension",
+			existing:            map[string]string{"assets": "Distro"},
+			forceRefresh:        true,
+			want:                map[string]string{"assets": "Distrolowercasegptextension"},
+			wantAssetsRefreshed: true,
+		},
 		"existing assets are kept if no assets downloadable provided": {
 			adDomain:            "assetsonly.com",
 			assetsURL:           "",
@@ -252,10 +280,24 @@ func TestFetch(t *testing.T) {
 			gpos: []string{"missing_gpt_ini"}, want: nil, wantErr: true},
 		"Error remote version NaN": {
 			gpos: []string{"gpt_ini_version_NaN"}, want: nil, wantErr: true},
-		"Error keeps downloading other GPOS": {
+		"Error missing remote GPT.INI even when forced": {
+			gpos: []string{"missing_gpt_ini"}, forceRefresh: true, want: nil, wantErr: true},
+		"Error does not commit other downloaded GPOs": {
 			gpos:    []string{"missing_gpt_ini", "gpo2"},
-			want:    map[string]string{"Policies/gpo2": "Policies/gpo2"},
+			want:    nil,
 			wantErr: true},
+		"Error does not refresh other existing GPOs": {
+			gpos:     []string{"missing_gpt_ini", "gpo1"},
+			existing: map[string]string{"Policies/gpo1": "Policies/old_version"},
+			want:     map[string]string{"Policies/gpo1": "Policies/old_version"},
+			wantErr:  true},
+		"Error does not remove existing assets": {
+			adDomain:  "fakegpo.com",
+			gpos:      []string{"missing_gpt_ini"},
+			assetsURL: "Distro",
+			existing:  map[string]string{"assets": "Policies/gpo1"},
+			want:      map[string]string{"assets": "Policies/gpo1"},
+			wantErr:   true},
 		/*
 			This is to cover the error case on os.Removall() to clean up the directory. However
 			Marking the assets/ directory or any subelement read only doesn’t help.
@@ -322,7 +364,7 @@ func TestFetch(t *testing.T) {
 
 			var assetsRefreshed bool
 			if tc.concurrentGposDownload == nil {
-				assetsRefreshed, err = adc.fetch(context.Background(), "", downloadables)
+				assetsRefreshed, err = adc.fetch(context.Background(), "", downloadables, tc.forceRefresh)
 				if tc.wantErr {
 					require.NotNil(t, err, "fetch should return an error but didn't")
 				} else {
@@ -341,7 +383,7 @@ func TestFetch(t *testing.T) {
 				var assetsRefreshed1, assetsRefreshed2 bool
 				go func() {
 					defer wg.Done()
-					assetsRefreshed1, err = adc.fetch(context.Background(), "", downloadables)
+					assetsRefreshed1, err = adc.fetch(context.Background(), "", downloadables, false)
 					if tc.wantErr {
 						require.NotNil(t, err, "fetch should return an error but didn't")
 					} else {
@@ -351,7 +393,7 @@ func TestFetch(t *testing.T) {
 				go func() {
 					defer wg.Done()
 					var err2 error
-					assetsRefreshed2, err2 = adc.fetch(context.Background(), "", concurrentGpos)
+					assetsRefreshed2, err2 = adc.fetch(context.Background(), "", concurrentGpos, false)
 					if tc.wantErr {
 						require.NotNil(t, err2, "fetch should return an error but didn't")
 					} else {
@@ -398,6 +440,87 @@ func TestFetch(t *testing.T) {
 	}
 }
 
+func TestFetchConcurrency(t *testing.T) {
+	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	gpos := []string{"gpo1", "gpo2", "old_version", "new_version", "gpt_ini_version_missing"}
+
+	tests := map[string]struct {
+		concurrency int
+		// changedConcurrency is the limit set once the ad object is created.
+		changedConcurrency int
+
+		wantMaxConcurrent int32
+		wantErr           bool
+	}{
+		"Downloads up to default limit simultaneously": {wantMaxConcurrent: consts.DefaultGpoDownloadConcurrency},
+		"Downloads up to given limit simultaneously":   {concurrency: 2, wantMaxConcurrent: 2},
+		"Downloads one GPO at a time":                  {concurrency: 1, wantMaxConcurrent: 1},
+		"Limit higher than the number of GPOs":         {concurrency: 10, wantMaxConcurrent: int32(len(gpos))},
+		"Downloads up to limit changed while running":  {concurrency: 1, changedConcurrency: 2, wantMaxConcurrent: 2},
+
+		"Error on limit lower than 1":         {concurrency: -1, wantErr: true},
+		"Error on changed limit lower than 1": {changedConcurrency: -1, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
+
+			dest, rundir := t.TempDir(), t.TempDir()
+
+			var concurrent, maxConcurrent atomic.Int32
+			opts := []Option{WithCacheDir(dest), WithRunDir(rundir), withoutKerberos(),
+				withDownloadHook(func() {
+					n := concurrent.Add(1)
+					defer concurrent.Add(-1)
+					for {
+						m := maxConcurrent.Load()
+						if n <= m || maxConcurrent.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					// Let other downloads start while this one holds its slot.
+					time.Sleep(100 * time.Millisecond)
+				}),
+			}
+			if tc.concurrency != 0 {
+				opts = append(opts, WithGPODownloadConcurrency(tc.concurrency))
+			}
+			adc, err := New(context.Background(), mock.Backend{}, hostname, opts...)
+			if tc.wantErr && tc.changedConcurrency == 0 {
+				require.Error(t, err, "New should return an error but didn't")
+				return
+			}
+			require.NoError(t, err, "Setup: cannot create ad object")
+			if tc.changedConcurrency != 0 {
+				err := adc.SetGPODownloadConcurrency(tc.changedConcurrency)
+				if tc.wantErr {
+					require.Error(t, err, "SetGPODownloadConcurrency should return an error but didn't")
+					return
+				}
+				require.NoError(t, err, "Setup: cannot change GPO download concurrency")
+			}
+
+			downloadables := make(map[string]string)
+			for _, n := range gpos {
+				downloadables[n+"-name"] = fmt.Sprintf("smb://localhost:%d/SYSVOL/fakegpo.com/Policies/%s", SmbPort, n)
+			}
+
+			_, err = adc.fetch(context.Background(), "", downloadables, false)
+			require.NoError(t, err, "fetch returned an error but shouldn't")
+
+			require.Equal(t, tc.wantMaxConcurrent, maxConcurrent.Load(), "fetch should download GPOs simultaneously up to the limit")
+			for _, n := range gpos {
+				require.DirExists(t, filepath.Join(adc.sysvolCacheDir, "Policies", n), "all GPOs should be downloaded")
+			}
+		})
+	}
+}
+
 func TestFetchWithUnreadableFile(t *testing.T) {
 	t.Parallel() // libsmbclient overrides SIGCHILD, but we have one global lock
 
@@ -436,7 +559,7 @@ func TestFetchWithUnreadableFile(t *testing.T) {
 					"Setup: can't copy initial gpo directory")
 			}
 
-			assetsRefreshed, err := adc.fetch(context.Background(), "", downloadables)
+			assetsRefreshed, err := adc.fetch(context.Background(), "", downloadables, false)
 			require.NotNil(t, err, "fetch should return an error but didn't")
 
 			if !tc.withExistingGPO {
@@ -482,7 +605,7 @@ func TestFetchTweakSysvolCacheDir(t *testing.T) {
 				testutils.MakeReadOnly(t, filepath.Join(adc.sysvolCacheDir, "Policies"))
 			}
 
-			assetsRefreshed, err := adc.fetch(context.Background(), "", map[string]string{"gpo1-name": fmt.Sprintf("smb://localhost:%d/SYSVOL/fakegpo.com/Policies/gpo1", SmbPort)})
+			assetsRefreshed, err := adc.fetch(context.Background(), "", map[string]string{"gpo1-name": fmt.Sprintf("smb://localhost:%d/SYSVOL/fakegpo.com/Policies/gpo1", SmbPort)}, false)
 
 			require.NotNil(t, err, "fetch should return an error but didn't")
 			assert.NoDirExists(t, filepath.Join(adc.sysvolCacheDir, "Policies", "gpo1"), "gpo1 shouldn't be downloaded")
@@ -528,7 +651,7 @@ func TestFetchOneGPOWhileParsingItConcurrently(t *testing.T) {
 	go func() {
 		defer wg.Done()
 
-		assetsRefreshed, err := adc.fetch(context.Background(), "", gpos)
+		assetsRefreshed, err := adc.fetch(context.Background(), "", gpos, false)
 		require.NoError(t, err, "fetch returned an error but shouldn't")
 		assert.False(t, assetsRefreshed, "we haven't refreshed assets")
 	}()
@@ -558,7 +681,7 @@ func TestParseGPOConcurrent(t *testing.T) {
 		"standard-name": fmt.Sprintf("smb://localhost:%d/SYSVOL/gpoonly.com/Policies/standard", SmbPort),
 	}
 	orderedGPOs := []gpo{{name: "standard-name", url: gpos["standard-name"]}}
-	assetsRefreshed, err := adc.fetch(context.Background(), "", gpos)
+	assetsRefreshed, err := adc.fetch(context.Background(), "", gpos, false)
 	require.NoError(t, err, "Setup: couldn’t do initial GPO fetch as returned an error but shouldn't")
 	assert.False(t, assetsRefreshed, "we haven't refreshed assets")
 
@@ -576,6 +699,134 @@ func TestParseGPOConcurrent(t *testing.T) {
 	wg.Wait()
 }
 
+func TestWriteSMBClientConf(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		requireEncryption bool
+		noSambaConf       bool
+		homeIsFile        bool
+
+		wantErr bool
+	}{
+		"Signing is required":                          {},
+		"Signing and encryption are required":          {requireEncryption: true},
+		"Missing system configuration is not included": {noSambaConf: true},
+
+		"Error when configuration directory can't be created": {homeIsFile: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			home := filepath.Join(t.TempDir(), "smbclient")
+			if tc.homeIsFile {
+				require.NoError(t, os.WriteFile(home, nil, 0600), "Setup: can't create file as home")
+			}
+			sambaConf := filepath.Join(testutils.TestFamilyPath(t), "smb.conf")
+			if tc.noSambaConf {
+				sambaConf = filepath.Join(testutils.TestFamilyPath(t), "doesnotexist")
+			}
+
+			err := writeSMBClientConf(home, sambaConf, tc.requireEncryption)
+			if tc.wantErr {
+				require.Error(t, err, "writeSMBClientConf should return an error but didn't")
+				return
+			}
+			require.NoError(t, err, "writeSMBClientConf returned an error but shouldn't")
+
+			got, err := os.ReadFile(filepath.Join(home, ".smb", "smb.conf"))
+			require.NoError(t, err, "SMB client configuration should be written")
+			want := testutils.LoadWithUpdateFromGolden(t, string(got))
+			require.Equal(t, want, string(got), "SMB client configuration doesn't match")
+		})
+	}
+}
+
+func TestCommitDownloads(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cached     []string
+		downloaded []string
+		removed    []string
+		// missing is downloaded, but its downloaded content is gone, failing the commit.
+		missing string
+
+		wantErr bool
+	}{
+		"Downloads replace the cached content":   {cached: []string{"gpo1", "gpo3"}, downloaded: []string{"gpo1", "gpo2"}},
+		"Removed downloadables are not cached":   {cached: []string{"gpo1", "gpo2"}, removed: []string{"gpo2"}},
+		"Downloads are cached when none was yet": {downloaded: []string{"gpo1", "gpo2"}},
+
+		"Error restores the cached content of all downloads": {
+			cached:     []string{"gpo1", "gpo3"},
+			downloaded: []string{"gpo1", "gpo2"},
+			removed:    []string{"gpo3"},
+			missing:    "gpo4",
+			wantErr:    true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for _, n := range tc.cached {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, n), 0700), "Setup: can't create cached directory")
+				require.NoError(t, os.WriteFile(filepath.Join(dir, n, "content"), []byte("cached"), 0600), "Setup: can't write cached content")
+			}
+			var staged []stagedDownload
+			for _, n := range tc.downloaded {
+				tmpdest := filepath.Join(dir, n+".downloaded")
+				require.NoError(t, os.MkdirAll(tmpdest, 0700), "Setup: can't create downloaded directory")
+				require.NoError(t, os.WriteFile(filepath.Join(tmpdest, "content"), []byte("downloaded"), 0600), "Setup: can't write downloaded content")
+				staged = append(staged, stagedDownload{g: &downloadable{name: n, mu: &sync.RWMutex{}}, dest: filepath.Join(dir, n), tmpdest: tmpdest})
+			}
+			for _, n := range tc.removed {
+				staged = append(staged, stagedDownload{g: &downloadable{name: n, mu: &sync.RWMutex{}}, dest: filepath.Join(dir, n)})
+			}
+			if tc.missing != "" {
+				staged = append(staged, stagedDownload{g: &downloadable{name: tc.missing, mu: &sync.RWMutex{}}, dest: filepath.Join(dir, tc.missing), tmpdest: filepath.Join(dir, tc.missing+".downloaded")})
+			}
+
+			err := commitDownloads(context.Background(), staged)
+
+			want := make(map[string]string)
+			for _, n := range tc.cached {
+				want[n] = "cached"
+			}
+			if tc.wantErr {
+				require.Error(t, err, "commitDownloads should return an error but didn't")
+				// Downloads are moved back for the caller to clean them up.
+				for _, n := range tc.downloaded {
+					want[n+".downloaded"] = "downloaded"
+				}
+			} else {
+				require.NoError(t, err, "commitDownloads returned an error but shouldn't")
+				for _, n := range tc.downloaded {
+					want[n] = "downloaded"
+				}
+				for _, n := range tc.removed {
+					delete(want, n)
+				}
+			}
+
+			got := make(map[string]string)
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err, "Setup: can't read cache directory")
+			for _, e := range entries {
+				content, err := os.ReadFile(filepath.Join(dir, e.Name(), "content"))
+				require.NoError(t, err, "Cache directory %s should have content", e.Name())
+				got[e.Name()] = string(content)
+			}
+			require.Equal(t, want, got, "Cache content doesn't match")
+		})
+	}
+}
+
 const SmbPort = 1445
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,142 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/ad/backends"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies"
+)
+
+// localURLPrefix prefixes the URLs of the GPOs and assets of a local backend.
+const localURLPrefix = "file://"
+
+// resolveLocalGPOs lists the GPOs applying to the object of l from the manifest of the local backend, as resolveGPOs
+// does from AD. No ticket is needed.
+func (ad *AD) resolveLocalGPOs(ctx context.Context, l gpoList, forceRefresh bool) (gpoList, error) {
+	// A corrupted policies cache may come with corrupted copied GPOs: copy all of them again.
+	if err := policies.VerifyCache(ctx, filepath.Join(ad.policiesCacheDir, l.objectName)); errors.Is(err, policies.ErrCorruptedCache) {
+		log.Warningf(ctx, "Policies cache of %q is corrupted, copying all GPOs again", l.objectName)
+		forceRefresh = true
+	} else if err != nil {
+		return l, err
+	}
+	l.forceRefresh = forceRefresh
+	l.adServerFQDN = ad.localBackend.SysvolDir()
+	l.downloadables = make(map[string]string)
+
+	// With loopback processing, the user policies of the GPOs of the machine apply to the user first.
+	var classes []ObjectClass
+	if l.objectClass == UserObject && ad.loopbackMode != LoopbackDisabled {
+		log.Debugf(ctx, "Loopback processing in %s mode: adding the GPOs of %q to %q", ad.loopbackMode, ad.hostname, l.objectName)
+		classes = append(classes, ComputerObject)
+	}
+	if l.objectClass != UserObject || ad.loopbackMode != LoopbackReplace {
+		classes = append(classes, l.objectClass)
+	}
+
+	for _, class := range classes {
+		gpos, err := ad.localBackend.GPOs(string(class))
+		if err != nil {
+			return l, err
+		}
+		for _, g := range gpos {
+			if err := l.addGPO(ctx, g.Name, localGPOURL(g), g.Enforced); err != nil {
+				return l, err
+			}
+		}
+	}
+
+	return l, nil
+}
+
+// listLocalGPOs returns the GPOs listed in the manifest of the local backend for objectClass, as ListGPOs does.
+func (ad *AD) listLocalGPOs(objectClass ObjectClass) (gpos []GPOLink, err error) {
+	local, err := ad.localBackend.GPOs(string(objectClass))
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range local {
+		gpos = append(gpos, GPOLink{
+			Name:     g.Name,
+			ID:       g.ID,
+			URL:      localGPOURL(g),
+			Enforced: g.Enforced,
+		})
+	}
+	return gpos, nil
+}
+
+// localGPOURL returns the file URL of the directory of g. As SYSVOL URLs, it is not escaped, so that the GPO is
+// cached under its ID.
+func localGPOURL(g backends.GPO) string {
+	return localURLPrefix + g.Dir
+}
+
+// localSysvol copies GPOs and assets from the directory of a local backend.
+type localSysvol struct{}
+
+func (localSysvol) readGPTIni(u string) ([]byte, error) {
+	dir, err := localPath(u)
+	if err != nil {
+		return nil, err
+	}
+	// Files staged from Windows may not have the canonical case.
+	p, err := findLocalGPTIni(dir)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Clean(p))
+}
+
+func (localSysvol) download(ctx context.Context, u, dest string) error {
+	src, err := localPath(u)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0700)
+		case d.Type().IsRegular():
+			log.Debug(ctx, gotext.Get("Copying %s", p))
+			data, err := os.ReadFile(filepath.Clean(p))
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(target, data, 0600)
+		default:
+			return fmt.Errorf("unsupported type %q for entry %s", d.Type(), p)
+		}
+	})
+}
+
+// localPath returns the path of the file URL u.
+func localPath(u string) (string, error) {
+	// Assets URLs are escaped, while GPO ones are not: parsing handles both.
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "file" {
+		return "", errors.New(gotext.Get("%q is not a local directory", u))
+	}
+	return parsed.Path, nil
+}
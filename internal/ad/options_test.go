@@ -1,5 +1,12 @@
 package ad
 
+import (
+	"context"
+	"net"
+
+	"github.com/ubuntu/adsys/internal/ad/filter"
+)
+
 func withoutKerberos() Option {
 	return func(o *options) error {
 		o.withoutKerberos = true
@@ -21,3 +28,43 @@ func WithVersionID(versionID string) Option {
 		return nil
 	}
 }
+
+// WithMachineFacts specifies the machine facts GPO filters are evaluated against.
+func WithMachineFacts(facts filter.Facts) Option {
+	return func(o *options) error {
+		o.machineFacts = facts
+		return nil
+	}
+}
+
+// withDownloadHook calls hook on each GPO or assets download, while it holds its download slot.
+func withDownloadHook(hook func()) Option {
+	return func(o *options) error {
+		o.downloadHook = hook
+		return nil
+	}
+}
+
+// withServerCheck replaces the check of domain controllers being reachable.
+func withServerCheck(check func(ctx context.Context, server string) bool) Option {
+	return func(o *options) error {
+		o.checkServer = check
+		return nil
+	}
+}
+
+// withSRVLookup replaces the DNS lookup of the domain controllers of a site.
+func withSRVLookup(lookup func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)) Option {
+	return func(o *options) error {
+		o.lookupSRV = lookup
+		return nil
+	}
+}
+
+// withKinitCmd replaces the kinit command renewing the machine ticket.
+func withKinitCmd(cmd []string) Option {
+	return func(o *options) error {
+		o.kinitCmd = cmd
+		return nil
+	}
+}
@@ -39,6 +39,24 @@ func TestReadPolicy(t *testing.T) {
 					data:  []byte("\xd2\x04\x00\x00"),
 				},
 			}},
+		"one element, multitext value terminated": {
+			want: []policyRawEntry{
+				{
+					path:  defaultPath,
+					key:   defaultKey,
+					dType: dataType(7),
+					data:  []byte("B\x00\x00\x00A\x00\x00\x00\x00\x00"),
+				},
+			}},
+		"one element, qword value": {
+			want: []policyRawEntry{
+				{
+					path:  defaultPath,
+					key:   defaultKey,
+					dType: dataType(11),
+					data:  []byte("\xef\xcd\xab\x89\x67\x45\x23\x01"),
+				},
+			}},
 		"two elements": {
 			want: []policyRawEntry{
 				{
@@ -184,6 +202,21 @@ func TestReadPolicy(t *testing.T) {
 					data:  defaultData,
 				},
 			}},
+		"delete values directive": {
+			want: []policyRawEntry{
+				{
+					path:  defaultPath,
+					key:   "**delvals.",
+					dType: dataType(1),
+					data:  []byte(" \x00\x00\x00"),
+				},
+				{
+					path:  defaultPath,
+					key:   defaultKey,
+					dType: dataType(1),
+					data:  defaultData,
+				},
+			}},
 		"header only": {},
 
 		// Soft error cases
@@ -202,6 +235,8 @@ func TestReadPolicy(t *testing.T) {
 		"invalid header, header doesnt match": {wantErr: true},
 		"invalid header, header too short":    {wantErr: true},
 		"invalid header, file truncated":      {wantErr: true},
+		"invalid qword value":                 {wantErr: true},
+		"invalid multitext value":             {wantErr: true},
 		"no header":                           {wantErr: true},
 		"empty file":                          {wantErr: true},
 		"section not closed":                  {wantErr: true},
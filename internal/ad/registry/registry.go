@@ -42,6 +42,9 @@ const (
 const (
 	policyContainerName      = "metaValues"
 	policyWithNoChildrenName = "basic"
+
+	// deleteValuesName deletes all the values of its key set by GPOs with lower precedence.
+	deleteValuesName = "**delvals."
 )
 
 type meta struct {
@@ -67,6 +70,16 @@ func DecodePolicy(r io.Reader) (entries []entry.Entry, err error) {
 		var res string
 		var disabled bool
 
+		if strings.EqualFold(e.key, deleteValuesName) {
+			entries = append(entries, entry.Entry{
+				Key:    strings.ReplaceAll(e.path, `\`, `/`),
+				Delete: true,
+			})
+			continue
+		}
+
+		// **del.<name> is how GPO editors write disabled policies: it is kept as a disabled entry, which overrides
+		// the value of GPOs with lower precedence.
 		disabled = strings.HasPrefix(e.key, "**del.")
 		if disabled {
 			e.key = strings.TrimPrefix(e.key, "**del.")
@@ -134,13 +147,13 @@ func DecodePolicy(r io.Reader) (entries []entry.Entry, err error) {
 				if err != nil {
 					return nil, err
 				}
+				// lines separators for multi lines textbox are \x00, the list being terminated by an empty string
+				if t == regMultiSz {
+					res = strings.ReplaceAll(strings.TrimRight(res, "\x00"), "\x00", "\n")
+				}
 				if res == "" {
 					res = metaValues[e.key].Empty
 				}
-				// lines separators for multi lines textbox are \x00
-				if t == regMultiSz {
-					res = strings.ReplaceAll(res, "\x00", "\n")
-				}
 			case regDword:
 				var resInt uint32
 				buf := bytes.NewReader(e.data)
@@ -148,6 +161,13 @@ func DecodePolicy(r io.Reader) (entries []entry.Entry, err error) {
 					return nil, err
 				}
 				res = strconv.FormatUint(uint64(resInt), 10)
+			case regQword:
+				var resInt uint64
+				buf := bytes.NewReader(e.data)
+				if err := binary.Read(buf, binary.LittleEndian, &resInt); err != nil {
+					return nil, err
+				}
+				res = strconv.FormatUint(resInt, 10)
 			default:
 				e.err = fmt.Errorf("%d type is not supported for key %s", t, e.key)
 			}
@@ -205,9 +225,16 @@ func readPolicy(r io.Reader) (entries []policyRawEntry, err error) {
 	sectionEndNoNullChar := []byte{';', 0, ']', 0} // ;] in UTF-16 (little endian) - last field can be empty
 	dataOffset := len(sectionStart)
 	sectionEndWidth := len(sectionEnd)
+	delimiter := []byte{0, 0, ';', 0} // \0; in little endian (UTF-16)
+
+	// offset is the position in the file of the data being scanned, and recordOffset the one of the last record.
+	offset := binary.Size(header)
+	var recordOffset int
 
 	// [key;value;type;size;data]
 	scanEntries := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		defer func() { offset += advance }()
+
 		// Skip leading sectionStart.
 		start := 0
 		for ; start+dataOffset-1 < len(data); start++ {
@@ -215,6 +242,17 @@ func readPolicy(r io.Reader) (entries []policyRawEntry, err error) {
 				break
 			}
 		}
+		recordOffset = offset + start
+
+		// Binary data can end without null character: rely on the data size when it matches the record.
+		if start+dataOffset <= len(data) {
+			if end, complete := sizedRecordEnd(data[start+dataOffset:], delimiter); end >= 0 {
+				end += start + dataOffset
+				return end + dataOffset, data[start+dataOffset : end], nil
+			} else if !complete && !atEOF {
+				return start, nil, nil
+			}
+		}
 
 		// Scan until sectionEnd, marking end of word.
 		for i := start + dataOffset; i+sectionEndWidth-1 < len(data); i++ {
@@ -226,7 +264,7 @@ func readPolicy(r io.Reader) (entries []policyRawEntry, err error) {
 
 		// If we're at EOF, we have a final, non-empty, non-terminated word. Return an error.
 		if atEOF && len(data) > start {
-			return 0, nil, fmt.Errorf("item does not end with ']'")
+			return 0, nil, fmt.Errorf("item at offset %d does not end with ']'", recordOffset)
 		}
 		// Request more data.
 		return start, nil, nil
@@ -234,13 +272,12 @@ func readPolicy(r io.Reader) (entries []policyRawEntry, err error) {
 
 	s := bufio.NewScanner(r)
 	s.Split(scanEntries)
-	delimiter := []byte{0, 0, ';', 0} // \0; in little endian (UTF-16)
 	for s.Scan() {
 		var e error
 
 		elems := bytes.SplitN(s.Bytes(), delimiter, 5)
 		if len(elems) != 5 {
-			return nil, fmt.Errorf("item should contains 5 fields separated by ';': %s", strings.ToValidUTF8(s.Text(), "?"))
+			return nil, fmt.Errorf("item at offset %d should contains 5 fields separated by ';': %s", recordOffset, strings.ToValidUTF8(s.Text(), "?"))
 		}
 
 		keyPrefix, err := decodeUtf16(elems[0])
@@ -267,7 +304,14 @@ func readPolicy(r io.Reader) (entries []policyRawEntry, err error) {
 
 		t := elems[2]
 		if len(t) != 2 {
-			return nil, fmt.Errorf("invalid type: %d", t)
+			return nil, fmt.Errorf("invalid type at offset %d: %d", recordOffset, t)
+		}
+
+		// Deleted values have no data to decode.
+		if !strings.HasPrefix(keySuffix, "**del.") {
+			if err := checkData(dataType(t[0]), data); err != nil {
+				return nil, fmt.Errorf("invalid data for %s\\%s at offset %d: %w", keyPrefix, keySuffix, recordOffset, err)
+			}
 		}
 
 		entries = append(entries, policyRawEntry{
@@ -286,6 +330,51 @@ func readPolicy(r io.Reader) (entries []policyRawEntry, err error) {
 	return entries, nil
 }
 
+// sizedRecordEnd returns the end of the data of the record rec, which starts after its opening bracket, from the
+// size stored in the record. It returns -1 if the record doesn't end right after its data, or if rec is incomplete,
+// in which case complete is false.
+func sizedRecordEnd(rec, delimiter []byte) (end int, complete bool) {
+	// The delimiters consume the 2 upper bytes of type and size, which are little endian.
+	var size, pos int
+	for i := 0; i < 4; i++ {
+		idx := bytes.Index(rec[pos:], delimiter)
+		if idx < 0 {
+			return -1, false
+		}
+		if i == 3 {
+			if idx != 2 {
+				return -1, true
+			}
+			size = int(binary.LittleEndian.Uint16(rec[pos : pos+2]))
+		}
+		pos += idx + len(delimiter)
+	}
+
+	end = pos + size
+	if len(rec) < end+2 {
+		return -1, false
+	}
+	if !bytes.Equal(rec[end:end+2], []byte{']', 0}) {
+		return -1, true
+	}
+	return end, true
+}
+
+// checkData checks that data has the expected size for its type.
+func checkData(t dataType, data []byte) error {
+	switch t {
+	case regQword:
+		if len(data) != 8 {
+			return fmt.Errorf("QWORD value should be 8 bytes, got %d", len(data))
+		}
+	case regMultiSz:
+		if len(data)%2 != 0 {
+			return fmt.Errorf("%x is not a valid UTF-16 string", data)
+		}
+	}
+	return nil
+}
+
 func decodeUtf16(b []byte) (string, error) {
 	if len(b)%2 != 0 {
 		return "", fmt.Errorf("%x is not a valid UTF-16 string", b)
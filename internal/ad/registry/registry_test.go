@@ -43,6 +43,20 @@ func TestDecodePolicy(t *testing.T) {
 					Value: "B\nA",
 				},
 			}},
+		"one element, multitext value terminated": {
+			want: []entry.Entry{
+				{
+					Key:   defaultKey,
+					Value: "B\nA",
+				},
+			}},
+		"one element, qword value": {
+			want: []entry.Entry{
+				{
+					Key:   defaultKey,
+					Value: "81985529216486895",
+				},
+			}},
 		"two elements": {
 			want: []entry.Entry{
 				{
@@ -337,6 +351,18 @@ func TestDecodePolicy(t *testing.T) {
 				},
 			}},
 
+		"delete values directive": {
+			want: []entry.Entry{
+				{
+					Key:    `Software/Canonical/Ubuntu`,
+					Delete: true,
+				},
+				{
+					Key:   defaultKey,
+					Value: defaultData,
+				},
+			}},
+
 		"header only": {},
 
 		// Soft error cases
@@ -360,6 +386,8 @@ func TestDecodePolicy(t *testing.T) {
 
 		// Error cases
 		"invalid decimal value":               {wantErr: true},
+		"invalid qword value":                 {wantErr: true},
+		"invalid multitext value":             {wantErr: true},
 		"invalid header, header doesnt match": {wantErr: true},
 		"invalid header, header too short":    {wantErr: true},
 		"invalid header, file truncated":      {wantErr: true},
@@ -0,0 +1,38 @@
+package ad
+
+// machineOnlyTypes are the policy types which only apply to the machine.
+// Certificate autoenrollment is not listed: Windows clients support it for users too, and it is then
+// legitimately set in user policies.
+var machineOnlyTypes = map[string]struct{}{
+	"gdm":       {},
+	"hosts":     {},
+	"privilege": {},
+	"proxy":     {},
+	"timesync":  {},
+}
+
+// scopedKeys are the policy keys of types applying to both objects which are only meant for one of them.
+var scopedKeys = map[string]ObjectClass{
+	"apparmor/apparmor-machine":             ComputerObject,
+	"apparmor/apparmor-users":               UserObject,
+	"mount/system-mounts":                   ComputerObject,
+	"mount/user-mounts":                     UserObject,
+	"scripts/startup":                       ComputerObject,
+	"scripts/startup-async":                 ComputerObject,
+	"scripts/shutdown":                      ComputerObject,
+	"scripts/shutdown-scripts-grace-period": ComputerObject,
+	"scripts/logon":                         UserObject,
+	"scripts/logon-async":                   UserObject,
+	"scripts/logoff":                        UserObject,
+	"scripts/logoff-scripts-grace-period":   UserObject,
+}
+
+// intendedClass returns the class of object a policy key of keyType is meant for.
+// ok is false for keys which apply to both objects, like dconf ones.
+func intendedClass(keyType, key string) (class ObjectClass, ok bool) {
+	if _, machineOnly := machineOnlyTypes[keyType]; machineOnly {
+		return ComputerObject, true
+	}
+	class, ok = scopedKeys[keyType+"/"+key]
+	return class, ok
+}
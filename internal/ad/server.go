@@ -0,0 +1,100 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+const (
+	// ldapPort is the port domain controllers are contacted on to check if they are reachable.
+	ldapPort = "389"
+
+	// serverCheckTimeout is the maximum time to wait for a domain controller to answer.
+	serverCheckTimeout = 3 * time.Second
+)
+
+// CheckConnection returns an error if the backend is offline or no domain controller can be found.
+func (ad *AD) CheckConnection(ctx context.Context) error {
+	// The local directory is always available.
+	if ad.localBackend != nil {
+		return nil
+	}
+	online, err := ad.configBackend.IsOnline()
+	if err != nil {
+		return err
+	}
+	if !online {
+		return errors.New(gotext.Get("machine is offline"))
+	}
+	_, err = ad.serverFQDN(ctx)
+	return err
+}
+
+// serverFQDN returns the domain controller GPOs and SYSVOL are fetched from.
+// The preferred server is tried first, then the domain controllers of the preferred site. If none of them is
+// reachable, we fall back to the server discovered by the backend.
+func (ad *AD) serverFQDN(ctx context.Context) (string, error) {
+	if ad.preferredServer != "" {
+		if ad.isServerReachable(ctx, ad.preferredServer) {
+			log.Debugf(ctx, "Using preferred domain controller %s", ad.preferredServer)
+			return ad.preferredServer, nil
+		}
+		log.Warningf(ctx, "Preferred domain controller %s is unreachable, falling back to discovery", ad.preferredServer)
+	}
+
+	if ad.site != "" {
+		servers, err := ad.siteServers(ctx)
+		if err != nil {
+			log.Warningf(ctx, "Can't list domain controllers of site %q, falling back to discovery: %v", ad.site, err)
+		}
+		for _, server := range servers {
+			if ad.isServerReachable(ctx, server) {
+				log.Debugf(ctx, "Using domain controller %s of site %q", server, ad.site)
+				return server, nil
+			}
+		}
+		if err == nil {
+			log.Warningf(ctx, "No domain controller of site %q is reachable, falling back to discovery", ad.site)
+		}
+	}
+
+	return ad.configBackend.ServerFQDN(ctx)
+}
+
+// siteServers returns the domain controllers of the preferred site advertised by DNS, in priority order.
+func (ad *AD) siteServers(ctx context.Context) ([]string, error) {
+	name := fmt.Sprintf("%s._sites.dc._msdcs.%s", ad.site, ad.configBackend.Domain())
+	_, records, err := ad.lookupSRV(ctx, "ldap", "tcp", name)
+	if err != nil {
+		return nil, err
+	}
+
+	var servers []string
+	for _, r := range records {
+		servers = append(servers, strings.TrimSuffix(r.Target, "."))
+	}
+	return servers, nil
+}
+
+// isServerReachable returns if the domain controller accepts connections on its LDAP port.
+func (ad *AD) isServerReachable(ctx context.Context, server string) bool {
+	if ad.checkServer != nil {
+		return ad.checkServer(ctx, server)
+	}
+
+	dialer := net.Dialer{Timeout: serverCheckTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(server, ldapPort))
+	if err != nil {
+		log.Debugf(ctx, "Domain controller %s is unreachable: %v", server, err)
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
@@ -0,0 +1,68 @@
+package ad
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+// LocalGPO is a GPO provided outside of SYSVOL, with the content of its registry files.
+// A registry file is empty when the GPO has no policy for its class.
+type LocalGPO struct {
+	Name            string
+	MachineRegistry []byte
+	UserRegistry    []byte
+}
+
+// ParseLocalGPOs returns the policies of gpos, in precedence order (highest first), as they would apply to an object
+// of objectClass on this machine.
+// Their registry files are only stored in a temporary directory while being parsed: nothing is downloaded nor cached.
+func (ad *AD) ParseLocalGPOs(ctx context.Context, gpos []LocalGPO, objectClass ObjectClass) (pols policies.Policies, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't parse local GPOs"))
+
+	dir, err := os.MkdirTemp("", "adsys-local-gpos-")
+	if err != nil {
+		return pols, err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Warningf(ctx, "Can't remove temporary GPOs directory %s: %v", dir, err)
+		}
+	}()
+
+	var r []policies.GPO
+	for i, g := range gpos {
+		// GPO names are not valid paths, and may collide once sanitized.
+		gpoDir := filepath.Join(dir, strconv.Itoa(i))
+		for class, content := range map[string][]byte{"Machine": g.MachineRegistry, "User": g.UserRegistry} {
+			if len(content) == 0 {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Join(gpoDir, class), 0700); err != nil {
+				return pols, err
+			}
+			if err := os.WriteFile(filepath.Join(gpoDir, class, "Registry.pol"), content, 0600); err != nil {
+				return pols, err
+			}
+		}
+
+		gpoWithRules := policies.GPO{
+			ID:    g.Name,
+			Name:  g.Name,
+			Rules: make(map[string][]entry.Entry),
+		}
+		if err := ad.parseGPORules(ctx, g.Name, gpoDir, objectClass, gpoWithRules.Rules); err != nil {
+			return pols, err
+		}
+		r = append(r, gpoWithRules)
+	}
+
+	return policies.New(ctx, r, "")
+}
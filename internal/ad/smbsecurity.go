@@ -0,0 +1,76 @@
+package ad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/mvo5/libsmbclient-go"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// defaultSambaConf is the Samba configuration of the system, loaded by libsmbclient by default.
+const defaultSambaConf = "/etc/samba/smb.conf"
+
+// writeSMBClientConf writes the libsmbclient configuration requiring SMB signing, and SMB encryption if
+// requireEncryption is set, on the SYSVOL connections in <home>/.smb/smb.conf.
+// libsmbclient loads this configuration instead of the system one when HOME is set to home on its initialization:
+// the system configuration in sambaConf is included in it when present, so that the realm and Kerberos settings still
+// apply.
+// The connections to domain controllers which don't sign, or encrypt, them then fail.
+func writeSMBClientConf(home, sambaConf string, requireEncryption bool) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't write SMB client configuration"))
+
+	var conf strings.Builder
+	conf.WriteString("# Generated by adsys for its SYSVOL downloads, don't edit.\n")
+	// A missing included file prevents libsmbclient from loading the configuration at all.
+	if _, err := os.Stat(sambaConf); err == nil {
+		fmt.Fprintf(&conf, "include = %s\n", sambaConf)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	conf.WriteString("\n[global]\nclient signing = required\n")
+	if requireEncryption {
+		conf.WriteString("client smb encrypt = required\n")
+	}
+
+	dir := filepath.Join(home, ".smb")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	p := filepath.Join(dir, "smb.conf")
+	if err := os.WriteFile(p+".new", []byte(conf.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(p+".new", p)
+}
+
+// newSMBClient returns a libsmbclient client, loading the SMB client configuration of smbClientHome if set.
+// libsmbclient only loads its configuration on its first initialization in the process: the daemon only creates
+// clients from its single AD object. This should not be called concurrently.
+func (ad *AD) newSMBClient(ctx context.Context) (*libsmbclient.Client, error) {
+	if ad.smbClientHome == "" {
+		return libsmbclient.New(), nil
+	}
+
+	oldHome, hadHome := os.LookupEnv("HOME")
+	if err := os.Setenv("HOME", ad.smbClientHome); err != nil {
+		return nil, err
+	}
+	defer func() {
+		err := os.Unsetenv("HOME")
+		if hadHome {
+			err = os.Setenv("HOME", oldHome)
+		}
+		if err != nil {
+			log.Errorf(ctx, "Couldn't restore initial value for HOME: %v", err)
+		}
+	}()
+
+	return libsmbclient.New(), nil
+}
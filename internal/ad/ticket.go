@@ -0,0 +1,226 @@
+package ad
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/smbsafe"
+	"github.com/ubuntu/decorate"
+)
+
+// defaultTicketRenewalThreshold is how long before its expiry the machine ticket is renewed.
+const defaultTicketRenewalThreshold = 5 * time.Minute
+
+// ensureMachineTicket renews the machine ticket stored in krb5CCPath if it is expired or expires within the renewal
+// threshold. The ticket is renewed first and acquired again from the backend if it can't be renewed.
+// A ticket cache which can't be read is used as is.
+func (ad *AD) ensureMachineTicket(ctx context.Context, krb5CCPath string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't renew machine ticket"))
+
+	ad.Lock()
+	defer ad.Unlock()
+
+	endTime, err := tgtEndTime(krb5CCPath)
+	if err != nil {
+		log.Warningf(ctx, "Can't check machine ticket validity, using it as is: %v", err)
+		return nil
+	}
+	if time.Until(endTime) > ad.ticketRenewalThreshold {
+		return nil
+	}
+
+	log.Infof(ctx, "Machine ticket expires on %s, renewing it", endTime.Format(time.RFC3339))
+	if err := ad.kinit(ctx, "-R", "-c", krb5CCPath); err != nil {
+		log.Debugf(ctx, "Can't renew machine ticket: %v", err)
+	} else if endTime, err = tgtEndTime(krb5CCPath); err == nil && time.Until(endTime) > ad.ticketRenewalThreshold {
+		return nil
+	}
+
+	// The backend knows how to get the machine ticket: the keytab and NetBIOS name for winbind, sssd for sss.
+	log.Info(ctx, "Acquiring a new machine ticket from the backend")
+	src, err := ad.configBackend.HostKrb5CCName()
+	if err != nil {
+		return err
+	}
+	if err := safeCopyFile(src, krb5CCPath, 0600); err != nil {
+		return err
+	}
+	if endTime, err = tgtEndTime(krb5CCPath); err == nil && !time.Now().Before(endTime) {
+		return errors.New(gotext.Get("machine ticket from the backend expired on %s", endTime.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// kinit runs the kinit command with args.
+func (ad *AD) kinit(ctx context.Context, args ...string) error {
+	cmdArgs := append(append([]string{}, ad.kinitCmd...), args...)
+	smbsafe.WaitExec()
+	defer smbsafe.DoneExec()
+	// #nosec G204 - cmdArgs is under our control (kinit or mock for tests)
+	if out, err := exec.CommandContext(ctx, cmdArgs[0], cmdArgs[1:]...).CombinedOutput(); err != nil {
+		return errors.New(gotext.Get("kinit failed: %v\n%s", err, out))
+	}
+	return nil
+}
+
+// tgtEndTime returns the expiry time of the ticket granting ticket of the default principal of the file ticket
+// cache at path.
+func tgtEndTime(path string) (endTime time.Time, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read ticket cache %q", path))
+
+	f, err := os.Open(path)
+	if err != nil {
+		return endTime, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return endTime, err
+	}
+	switch version {
+	case 0x0504:
+		var headerLen uint16
+		if err := binary.Read(r, binary.BigEndian, &headerLen); err != nil {
+			return endTime, err
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(headerLen)); err != nil {
+			return endTime, err
+		}
+	case 0x0503:
+	default:
+		return endTime, errors.New(gotext.Get("unsupported ticket cache version 0x%04x", version))
+	}
+
+	defaultPrincipal, err := readCCachePrincipal(r)
+	if err != nil {
+		return endTime, err
+	}
+	realm := defaultPrincipal[0]
+	tgt := []string{realm, "krbtgt", realm}
+
+	var found bool
+	for {
+		if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+			break
+		}
+		server, end, err := readCCacheCredential(r, version)
+		if err != nil {
+			return endTime, err
+		}
+		if !slices.Equal(server, tgt) {
+			continue
+		}
+		found = true
+		if end.After(endTime) {
+			endTime = end
+		}
+	}
+	if !found {
+		return endTime, errors.New(gotext.Get("no ticket granting ticket for realm %s", realm))
+	}
+	return endTime, nil
+}
+
+// readCCachePrincipal reads a principal from a ticket cache, returning its realm followed by its components.
+func readCCachePrincipal(r io.Reader) (principal []string, err error) {
+	var header struct {
+		NameType      uint32
+		NumComponents uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	// The realm comes before the components.
+	for i := uint32(0); i <= header.NumComponents; i++ {
+		d, err := readCCacheData(r)
+		if err != nil {
+			return nil, err
+		}
+		principal = append(principal, string(d))
+	}
+	return principal, nil
+}
+
+// readCCacheCredential reads a credential from a ticket cache, returning its server and its expiry time.
+func readCCacheCredential(r io.Reader, version uint16) (server []string, endTime time.Time, err error) {
+	if _, err := readCCachePrincipal(r); err != nil {
+		return nil, endTime, err
+	}
+	if server, err = readCCachePrincipal(r); err != nil {
+		return nil, endTime, err
+	}
+
+	// Keyblock: its encryption type, repeated in version 3, and its key.
+	enctypeLen := int64(2)
+	if version == 0x0503 {
+		enctypeLen = 4
+	}
+	if _, err := io.CopyN(io.Discard, r, enctypeLen); err != nil {
+		return nil, endTime, err
+	}
+	if _, err := readCCacheData(r); err != nil {
+		return nil, endTime, err
+	}
+
+	var times struct {
+		AuthTime, StartTime, EndTime, RenewTill uint32
+		IsSKey                                  uint8
+		TicketFlags                             uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &times); err != nil {
+		return nil, endTime, err
+	}
+
+	// Addresses and authorization data are lists of typed data.
+	for range 2 {
+		var n uint32
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return nil, endTime, err
+		}
+		for i := uint32(0); i < n; i++ {
+			if _, err := io.CopyN(io.Discard, r, 2); err != nil {
+				return nil, endTime, err
+			}
+			if _, err := readCCacheData(r); err != nil {
+				return nil, endTime, err
+			}
+		}
+	}
+
+	// Ticket and second ticket.
+	for range 2 {
+		if _, err := readCCacheData(r); err != nil {
+			return nil, endTime, err
+		}
+	}
+
+	return server, time.Unix(int64(times.EndTime), 0), nil
+}
+
+// readCCacheData reads data from a ticket cache, prefixed by its 32 bits length.
+func readCCacheData(r io.Reader) ([]byte, error) {
+	var l uint32
+	if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+		return nil, err
+	}
+	var d bytes.Buffer
+	if _, err := io.CopyN(&d, r, int64(l)); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return d.Bytes(), nil
+}
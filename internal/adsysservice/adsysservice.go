@@ -15,8 +15,10 @@ import (
 	"github.com/ubuntu/adsys"
 	"github.com/ubuntu/adsys/internal/ad"
 	"github.com/ubuntu/adsys/internal/ad/backends"
+	"github.com/ubuntu/adsys/internal/ad/backends/local"
 	"github.com/ubuntu/adsys/internal/ad/backends/sss"
 	"github.com/ubuntu/adsys/internal/ad/backends/winbind"
+	"github.com/ubuntu/adsys/internal/audit"
 	"github.com/ubuntu/adsys/internal/authorizer"
 	"github.com/ubuntu/adsys/internal/consts"
 	"github.com/ubuntu/adsys/internal/daemon"
@@ -24,17 +26,23 @@ import (
 	"github.com/ubuntu/adsys/internal/grpc/interceptorschain"
 	"github.com/ubuntu/adsys/internal/grpc/logconnections"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/metrics"
+	"github.com/ubuntu/adsys/internal/netwatch"
 	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/adsys/internal/ratelimit"
 	"github.com/ubuntu/decorate"
 	"google.golang.org/grpc"
 )
 
+//go:generate go run ../generators/copy.go com.ubuntu.adsys.conf usr/share/dbus-1/system.d ../../generated
+
 // Service is used to implement adsys.ServiceServer.
 type Service struct {
 	adsys.UnimplementedServiceServer
 	logger *logrus.Logger
 
 	adc           *ad.AD
+	adBackend     backends.Backend
 	policyManager *policies.Manager
 
 	authorizer authorizerer
@@ -42,6 +50,13 @@ type Service struct {
 	state          state
 	initSystemTime *time.Time
 
+	useImportedPolicies bool
+	applyTimeout        time.Duration
+	refreshLimiter      *ratelimit.Limiter
+
+	metricsServer  *metrics.Server
+	networkWatcher *netwatch.Watcher
+
 	bus    *dbus.Conn
 	daemon *daemon.Daemon
 }
@@ -72,7 +87,32 @@ type options struct {
 	adBackend      string
 	sssConfig      sss.Config
 	winbindConfig  winbind.Config
+	localConfig    local.Config
 	authorizer     authorizerer
+	allowedGroups  []string
+
+	certRenewalLeadTime    time.Duration
+	gpoLinkOrder           bool
+	loopbackProcessing     string
+	dconfUserLayers        bool
+	gpoDownloadConcurrency int
+	useImportedPolicies    bool
+	maxCacheAge            time.Duration
+	preferredServer        string
+	site                   string
+	allowUnsignedSYSVOL    bool
+	requireSYSVOLEncrypt   bool
+	ticketRenewalThreshold time.Duration
+	applyTimeout           time.Duration
+	applyLockTimeout       time.Duration
+	refreshMinInterval     time.Duration
+	refreshCoalesceWindow  time.Duration
+	metricsAddress         string
+	auditLogPath           string
+	auditLogFormat         string
+	refreshOnNetworkUp     bool
+	disabledManagers       []string
+	cleanupDisabled        bool
 }
 type option func(*options) error
 
@@ -162,6 +202,183 @@ func WithGlobalTrustDir(p string) func(o *options) error {
 	}
 }
 
+// WithCertificateRenewalLeadTime specifies how long before their expiry the enrolled certificates are renewed.
+func WithCertificateRenewalLeadTime(d time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.certRenewalLeadTime = d
+		return nil
+	}
+}
+
+// WithDconfUserLayers applies the dconf policy of users in one database per GPO, layered in GPO precedence order.
+func WithDconfUserLayers(layered bool) func(o *options) error {
+	return func(o *options) error {
+		o.dconfUserLayers = layered
+		return nil
+	}
+}
+
+// WithGPOLinkOrder orders the GPOs by link order only, ignoring enforced links and blocked inheritance.
+func WithGPOLinkOrder(linkOrder bool) func(o *options) error {
+	return func(o *options) error {
+		o.gpoLinkOrder = linkOrder
+		return nil
+	}
+}
+
+// WithLoopbackProcessing applies the user policies of the GPOs linked to the machine to all users, in "merge" or
+// "replace" mode. An empty mode disables loopback processing.
+func WithLoopbackProcessing(mode string) func(o *options) error {
+	return func(o *options) error {
+		o.loopbackProcessing = mode
+		return nil
+	}
+}
+
+// WithGPODownloadConcurrency specifies how many GPOs are downloaded simultaneously from SYSVOL.
+func WithGPODownloadConcurrency(n int) func(o *options) error {
+	return func(o *options) error {
+		o.gpoDownloadConcurrency = n
+		return nil
+	}
+}
+
+// WithMaxCacheAge refuses to apply cached policies older than maxAge when AD can't be reached.
+func WithMaxCacheAge(maxAge time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.maxCacheAge = maxAge
+		return nil
+	}
+}
+
+// WithPreferredServer fetches GPOs from server if it is reachable, instead of the one discovered by the backend.
+func WithPreferredServer(server string) func(o *options) error {
+	return func(o *options) error {
+		o.preferredServer = server
+		return nil
+	}
+}
+
+// WithUnsignedSYSVOL allows downloading SYSVOL over SMB connections which aren't signed.
+func WithUnsignedSYSVOL(allow bool) func(o *options) error {
+	return func(o *options) error {
+		o.allowUnsignedSYSVOL = allow
+		return nil
+	}
+}
+
+// WithSYSVOLEncryption only downloads SYSVOL over signed and encrypted SMB connections.
+func WithSYSVOLEncryption(required bool) func(o *options) error {
+	return func(o *options) error {
+		o.requireSYSVOLEncrypt = required
+		return nil
+	}
+}
+
+// WithSite fetches GPOs from the first reachable domain controller of the AD site.
+func WithSite(site string) func(o *options) error {
+	return func(o *options) error {
+		o.site = site
+		return nil
+	}
+}
+
+// WithTicketRenewalThreshold renews the machine ticket when it expires within threshold, before fetching GPOs.
+func WithTicketRenewalThreshold(threshold time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.ticketRenewalThreshold = threshold
+		return nil
+	}
+}
+
+// WithApplyTimeout cancels a policy update of a user or the machine if it has not completed within timeout.
+// A zero timeout keeps the default one.
+func WithApplyTimeout(timeout time.Duration) func(o *options) error {
+	return func(o *options) error {
+		if timeout > 0 {
+			o.applyTimeout = timeout
+		}
+		return nil
+	}
+}
+
+// WithAllowedGroups restricts the privileged operations, like updating the machine policies or stopping the
+// service, to root and the members of groups. No group keeps the default ones.
+func WithAllowedGroups(groups []string) func(o *options) error {
+	return func(o *options) error {
+		if len(groups) > 0 {
+			o.allowedGroups = groups
+		}
+		return nil
+	}
+}
+
+// WithApplyLockTimeout fails a policy update of a user or the machine if the policy update in progress has not
+// completed within timeout. A zero timeout keeps the default one.
+func WithApplyLockTimeout(timeout time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.applyLockTimeout = timeout
+		return nil
+	}
+}
+
+// WithRefreshRateLimit coalesces the policy refreshes of the same user: the refreshes requested within window
+// before one starts, or while it runs, join it and refreshes requested within minInterval after it completed are
+// served its result. Zero durations disable the rate limiting.
+func WithRefreshRateLimit(minInterval, window time.Duration) func(o *options) error {
+	return func(o *options) error {
+		o.refreshMinInterval = minInterval
+		o.refreshCoalesceWindow = window
+		return nil
+	}
+}
+
+// WithMetricsAddress exposes the policy applications metrics in the Prometheus format on addr, a host:port bind
+// address. An empty address doesn't expose them.
+func WithMetricsAddress(addr string) func(o *options) error {
+	return func(o *options) error {
+		o.metricsAddress = addr
+		return nil
+	}
+}
+
+// WithAuditLog records the changes made by each policy application in the audit log at path, in format (json or
+// text, json if empty). An empty path doesn't record them.
+func WithAuditLog(path, format string) func(o *options) error {
+	return func(o *options) error {
+		o.auditLogPath = path
+		o.auditLogFormat = format
+		return nil
+	}
+}
+
+// WithDisabledManagers disables the policy managers named in names. What they previously applied is removed if
+// cleanup is set, and kept otherwise.
+func WithDisabledManagers(names []string, cleanup bool) func(o *options) error {
+	return func(o *options) error {
+		o.disabledManagers = names
+		o.cleanupDisabled = cleanup
+		return nil
+	}
+}
+
+// WithRefreshOnNetworkUp refreshes the machine policy each time the network connectivity comes up and Active
+// Directory can be reached.
+func WithRefreshOnNetworkUp(refresh bool) func(o *options) error {
+	return func(o *options) error {
+		o.refreshOnNetworkUp = refresh
+		return nil
+	}
+}
+
+// WithImportedPolicies applies the imported policies snapshots instead of the AD policies, for debugging purposes.
+func WithImportedPolicies(useImported bool) func(o *options) error {
+	return func(o *options) error {
+		o.useImportedPolicies = useImported
+		return nil
+	}
+}
+
 // WithADBackend specifies our specific backend to select.
 func WithADBackend(backend string) func(o *options) error {
 	return func(o *options) error {
@@ -186,6 +403,14 @@ func WithWinbindConfig(c winbind.Config) func(o *options) error {
 	}
 }
 
+// WithLocalConfig specifies our specific local GPO directory options to override.
+func WithLocalConfig(c local.Config) func(o *options) error {
+	return func(o *options) error {
+		o.localConfig = c
+		return nil
+	}
+}
+
 // New returns a new instance of an AD service.
 // If url or domain is empty, we load the missing parameters from sssd.conf, taking first
 // domain in the list if not provided.
@@ -193,7 +418,10 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 	defer decorate.OnError(&err, gotext.Get("couldn't create adsys service"))
 
 	// defaults
-	args := options{}
+	args := options{
+		applyTimeout:  consts.DefaultApplyTimeout,
+		allowedGroups: consts.DefaultAllowedGroups,
+	}
 	// applied options
 	for _, o := range opts {
 		if err := o(&args); err != nil {
@@ -232,6 +460,12 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 		_ = bus.Close()
 		return nil, err
 	}
+	// Own our well-known name so that clients can match our signals on it. Signals are still emitted without it.
+	if reply, err := bus.RequestName(consts.AdsysDbusRegisteredName, dbus.NameFlagDoNotQueue); err != nil {
+		log.Warningf(ctx, "Can't own %s dbus name: %v", consts.AdsysDbusRegisteredName, err)
+	} else if reply != dbus.RequestNameReplyPrimaryOwner {
+		log.Warningf(ctx, "Can't own %s dbus name: already owned", consts.AdsysDbusRegisteredName)
+	}
 
 	var adOptions []ad.Option
 	if args.cacheDir != "" {
@@ -241,6 +475,33 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 		adOptions = append(adOptions, ad.WithRunDir(args.runDir))
 	}
 	adOptions = append(adOptions, ad.WithGpoListTimeout(consts.DefaultGpoListTimeout))
+	if args.gpoLinkOrder {
+		adOptions = append(adOptions, ad.WithGPOLinkOrder(true))
+	}
+	if args.loopbackProcessing != "" {
+		adOptions = append(adOptions, ad.WithLoopbackProcessing(ad.LoopbackMode(args.loopbackProcessing)))
+	}
+	if args.gpoDownloadConcurrency > 0 {
+		adOptions = append(adOptions, ad.WithGPODownloadConcurrency(args.gpoDownloadConcurrency))
+	}
+	if args.maxCacheAge > 0 {
+		adOptions = append(adOptions, ad.WithMaxCacheAge(args.maxCacheAge))
+	}
+	if args.preferredServer != "" {
+		adOptions = append(adOptions, ad.WithPreferredServer(args.preferredServer))
+	}
+	if args.site != "" {
+		adOptions = append(adOptions, ad.WithSite(args.site))
+	}
+	if args.allowUnsignedSYSVOL {
+		adOptions = append(adOptions, ad.WithSMBSigning(false))
+	}
+	if args.requireSYSVOLEncrypt {
+		adOptions = append(adOptions, ad.WithSMBEncryption(true))
+	}
+	if args.ticketRenewalThreshold > 0 {
+		adOptions = append(adOptions, ad.WithTicketRenewalThreshold(args.ticketRenewalThreshold))
+	}
 
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -261,6 +522,8 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 		adBackend, err = sss.New(ctx, args.sssConfig, bus)
 	case "winbind":
 		adBackend, err = winbind.New(ctx, args.winbindConfig, hostname)
+	case "local":
+		adBackend, err = local.New(ctx, args.localConfig)
 	}
 	if err != nil {
 		return nil, errors.New(gotext.Get("could not initialize AD backend: %v", err))
@@ -272,7 +535,7 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 	}
 
 	if args.authorizer == nil {
-		args.authorizer, err = authorizer.New(bus)
+		args.authorizer, err = authorizer.New(bus, authorizer.WithAllowedGroups(args.allowedGroups))
 		if err != nil {
 			_ = bus.Close()
 			return nil, err
@@ -310,16 +573,53 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 	if args.globalTrustDir != "" {
 		policyOptions = append(policyOptions, policies.WithGlobalTrustDir(args.globalTrustDir))
 	}
+	if args.certRenewalLeadTime > 0 {
+		policyOptions = append(policyOptions, policies.WithCertificateRenewalLeadTime(args.certRenewalLeadTime))
+	}
+	if args.applyLockTimeout > 0 {
+		policyOptions = append(policyOptions, policies.WithApplyLockTimeout(args.applyLockTimeout))
+	}
+	if args.dconfUserLayers {
+		policyOptions = append(policyOptions, policies.WithDconfUserLayers(true))
+	}
+	if len(args.disabledManagers) > 0 {
+		policyOptions = append(policyOptions, policies.WithDisabledManagers(args.disabledManagers, args.cleanupDisabled))
+	}
+	var recorder *metrics.Metrics
+	if args.metricsAddress != "" {
+		recorder = metrics.New(policies.Managers...)
+		policyOptions = append(policyOptions, policies.WithMetrics(recorder))
+	}
+	if args.auditLogPath != "" {
+		var auditOptions []audit.Option
+		if args.auditLogFormat != "" {
+			auditOptions = append(auditOptions, audit.WithFormat(args.auditLogFormat))
+		}
+		auditLog, err := audit.New(args.auditLogPath, auditOptions...)
+		if err != nil {
+			return nil, err
+		}
+		policyOptions = append(policyOptions, policies.WithAuditLog(auditLog))
+	}
+	policyOptions = append(policyOptions, policies.WithDconfUpdateDebounce(consts.DefaultDconfUpdateDebounce))
 	m, err := policies.NewManager(bus, hostname, adBackend, policyOptions...)
 	if err != nil {
 		return nil, err
 	}
 
+	var metricsServer *metrics.Server
+	if recorder != nil {
+		if metricsServer, err = metrics.Listen(ctx, args.metricsAddress, recorder); err != nil {
+			return nil, err
+		}
+	}
+
 	// Init system reference time
 	initSysTime := initSystemTime(bus)
 
-	return &Service{
+	s = &Service{
 		adc:           adc,
+		adBackend:     adBackend,
 		policyManager: m,
 		authorizer:    args.authorizer,
 		state: state{
@@ -335,7 +635,20 @@ func New(ctx context.Context, opts ...option) (s *Service, err error) {
 		},
 		initSystemTime: initSysTime,
 		bus:            bus,
-	}, nil
+
+		useImportedPolicies: args.useImportedPolicies,
+		applyTimeout:        args.applyTimeout,
+		refreshLimiter:      ratelimit.New(args.refreshMinInterval, args.refreshCoalesceWindow),
+		metricsServer:       metricsServer,
+	}
+
+	if args.refreshOnNetworkUp {
+		if s.networkWatcher, err = netwatch.New(ctx, bus, s.refreshMachinePolicy); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
 }
 
 // RegisterGRPCServer registers our service with the new interceptor chains.
@@ -353,8 +666,36 @@ func (s *Service) RegisterGRPCServer(d *daemon.Daemon) *grpc.Server {
 	return srv
 }
 
+// SetGPODownloadConcurrency changes how many GPOs are downloaded simultaneously from SYSVOL while running.
+// 0 restores the default concurrency.
+func (s *Service) SetGPODownloadConcurrency(n int) error {
+	if n == 0 {
+		n = consts.DefaultGpoDownloadConcurrency
+	}
+	return s.adc.SetGPODownloadConcurrency(n)
+}
+
+// SetMaxCacheAge changes the maximum age of the cached policies applied when AD can't be reached while running.
+// 0 always applies cached policies.
+func (s *Service) SetMaxCacheAge(maxAge time.Duration) error {
+	return s.adc.SetMaxCacheAge(maxAge)
+}
+
 // Quit cleans every ressources than the service was using.
 func (s *Service) Quit(ctx context.Context) {
+	// Stop refreshing on network changes before disconnecting from dbus.
+	if s.networkWatcher != nil {
+		s.networkWatcher.Close()
+	}
+	// Don't leave behind a delayed dconf update nobody waited for, like the one of a refresh after the network came up.
+	if err := s.policyManager.FlushUpdates(ctx); err != nil {
+		log.Warning(ctx, err)
+	}
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Close(); err != nil {
+			log.Warning(ctx, gotext.Get("Can't stop metrics server: %v", err))
+		}
+	}
 	if err := s.bus.Close(); err != nil {
 		log.Warning(ctx, gotext.Get("Can't disconnect system dbus: %v", err))
 	}
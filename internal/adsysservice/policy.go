@@ -2,7 +2,12 @@ package adsysservice
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys"
@@ -13,7 +18,6 @@ import (
 	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/policies/certificate"
 	"github.com/ubuntu/decorate"
-	"golang.org/x/sync/errgroup"
 )
 
 // UpdatePolicy refreshes or creates a policy for current user or user given as argument.
@@ -21,11 +25,73 @@ import (
 func (s *Service) UpdatePolicy(r *adsys.UpdatePolicyRequest, stream adsys.Service_UpdatePolicyServer) (err error) {
 	defer decorate.OnError(&err, gotext.Get("error while updating policy"))
 
+	return s.updatePolicy(stream.Context(), r, func(changes string) {
+		if err := stream.Send(&adsys.StringResponse{
+			Msg: changes,
+		}); err != nil {
+			log.Warningf(stream.Context(), "couldn't send policy changes to client: %v", err)
+		}
+	})
+}
+
+// UpdatePolicyWithProgress updates the policies as UpdatePolicy, streaming the progress of the update.
+// The stream ends with a message marked as done, with the error of the update if it failed.
+func (s *Service) UpdatePolicyWithProgress(r *adsys.UpdatePolicyRequest, stream adsys.Service_UpdatePolicyWithProgressServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while updating policy"))
+
+	// Progress is reported concurrently when updating all users, but streams can't be used concurrently.
+	var mu sync.Mutex
+	send := func(p *adsys.UpdatePolicyProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := stream.Send(p); err != nil {
+			log.Warningf(stream.Context(), "couldn't send policy update progress to client: %v", err)
+		}
+	}
+
+	ctx := policies.WithProgressReporter(stream.Context(), func(p policies.Progress) {
+		send(&adsys.UpdatePolicyProgress{
+			Target:     p.Target,
+			Stage:      p.Stage,
+			Manager:    p.Manager,
+			Percentage: progressPercentage(p),
+		})
+	})
+	err = s.updatePolicy(ctx, r, func(changes string) {
+		send(&adsys.UpdatePolicyProgress{Msg: changes})
+	})
+
+	done := &adsys.UpdatePolicyProgress{Done: true, Percentage: 100}
+	if err != nil {
+		done = &adsys.UpdatePolicyProgress{Done: true, Error: err.Error()}
+	}
+	send(done)
+	return err
+}
+
+// progressPercentage returns the completion of the update of an object at the stage of p. Fetching the GPOs and
+// compiling the dconf databases are accounted as a tenth of the update each, the policy managers sharing the rest.
+func progressPercentage(p policies.Progress) int32 {
+	switch p.Stage {
+	case policies.StageFetch:
+		return 0
+	case policies.StageApply:
+		if p.Total == 0 {
+			return 90
+		}
+		return int32(10 + 80*p.Completed/p.Total)
+	default:
+		return 90
+	}
+}
+
+// updatePolicy updates the policies as requested by r, sending the changes or timings to send.
+func (s *Service) updatePolicy(ctx context.Context, r *adsys.UpdatePolicyRequest, send func(changes string)) (err error) {
 	objectClass := ad.UserObject
 	if r.GetIsComputer() || r.GetAll() {
 		objectClass = ad.ComputerObject
 	}
-	target, err := s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), objectClass)
+	target, err := s.adc.NormalizeTargetName(ctx, r.GetTarget(), objectClass)
 	if err != nil {
 		return err
 	}
@@ -36,52 +102,242 @@ func (s *Service) UpdatePolicy(r *adsys.UpdatePolicyRequest, stream adsys.Servic
 		targetForAuthorizer = "root"
 	}
 
-	if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, targetForAuthorizer),
+	if err := s.authorizer.IsAllowedFromContext(context.WithValue(ctx, authorizer.OnUserKey, targetForAuthorizer),
 		actions.ActionPolicyUpdate); err != nil {
 		return err
 	}
 
+	managers := r.GetManagers()
+	if len(managers) > 0 && (r.GetPurge() || r.GetDryRun()) {
+		return errors.New(gotext.Get("policy managers can't be selected when purging or computing the changes of the policies"))
+	}
+	if r.GetTimings() && r.GetDryRun() {
+		return errors.New(gotext.Get("policy managers timings can't be reported when computing the changes of the policies"))
+	}
+
 	if r.GetIsComputer() || r.GetAll() {
 		hostname := s.adc.Hostname()
 
-		err = s.updatePolicyFor(stream.Context(), true, hostname, ad.ComputerObject, "", r.GetPurge())
+		msg, err := s.updatePolicyFor(ctx, true, hostname, ad.ComputerObject, "", r.GetPurge(), r.GetDryRun(), r.GetForceRefresh(), managers, r.GetTimings())
+		sendChanges(send, msg)
 
 		if r.GetAll() {
-			users, err := s.adc.ListUsers(stream.Context(), !r.GetPurge())
+			users, err := s.adc.ListUsers(ctx, !r.GetPurge())
 			if err != nil {
 				return err
 			}
-			errg := new(errgroup.Group)
-			for _, user := range users {
-				errg.Go(func() (err error) {
-					return s.updatePolicyFor(stream.Context(), false, user, ad.UserObject, "", r.GetPurge())
-				})
+			msgs, err := s.updateUsersPolicies(ctx, users, r.GetPurge(), r.GetDryRun(), r.GetForceRefresh(), managers, r.GetTimings())
+			// Streams can't be used concurrently: send changes once we have them all.
+			for _, msg := range msgs {
+				sendChanges(send, msg)
 			}
-			if err := errg.Wait(); err != nil {
+			if err != nil {
 				return fmt.Errorf("one or more error for updating all users: %w", err)
 			}
 		}
+		if err != nil {
+			return err
+		}
+		// Recompile dconf databases only once for the whole batch.
+		policies.ReportProgress(ctx, policies.Progress{Stage: policies.StageFlush})
+		if err := s.policyManager.FlushUpdates(ctx); err != nil {
+			return err
+		}
 
-		return err
+		// Every object is now up to date: remove what deleted users and purged caches left behind.
+		// Only dconf databases are cleaned up, which is skipped if the dconf manager didn't run.
+		if r.GetAll() && !r.GetPurge() && !r.GetDryRun() && (len(managers) == 0 || slices.Contains(managers, "dconf")) {
+			return s.policyManager.CleanupOrphaned(ctx)
+		}
+		return nil
 	}
 	// Update a single user
-	return s.updatePolicyFor(stream.Context(), r.GetIsComputer(), target, objectClass, r.Krb5Cc, r.GetPurge())
+	msg, err := s.updatePolicyFor(ctx, r.GetIsComputer(), target, objectClass, r.Krb5Cc, r.GetPurge(), r.GetDryRun(), r.GetForceRefresh(), managers, r.GetTimings())
+	sendChanges(send, msg)
+	if err != nil {
+		return err
+	}
+	// Other objects updated meanwhile, like on login storms, share the same dconf update.
+	policies.ReportProgress(ctx, policies.Progress{Target: target, Stage: policies.StageFlush})
+	return s.policyManager.WaitUpdates(ctx)
+}
+
+// refreshMachinePolicy refreshes the machine policy if Active Directory can be reached, once the network came up.
+// Otherwise, the policy is not refreshed: it would only be applied again from cache.
+// Nobody waits for this refresh: dconf databases are updated once the update delay elapsed.
+func (s *Service) refreshMachinePolicy(ctx context.Context) {
+	if err := s.adc.CheckConnection(ctx); err != nil {
+		log.Infof(ctx, "Active Directory can't be reached after the network came up, not refreshing machine policy: %v", err)
+		return
+	}
+
+	log.Info(ctx, gotext.Get("Network is up: refreshing machine policy"))
+	if _, err := s.applyPolicyFor(ctx, true, s.adc.Hostname(), ad.ComputerObject, "", false, false, false, nil, false); err != nil {
+		log.Warning(ctx, gotext.Get("Machine policy refresh after the network came up failed: %v", err))
+	}
 }
 
 // updatePolicyFor updates the policy for a given object.
-func (s *Service) updatePolicyFor(ctx context.Context, isComputer bool, target string, objectClass ad.ObjectClass, krb5cc string, purge bool) (err error) {
-	var pols policies.Policies
+// If dryRun is true, the policy is not applied and the changes it would do are returned instead.
+// If forceRefresh is true, the GPOs are downloaded again even if they are up to date.
+// Imported policies are applied instead of the AD ones if the daemon is configured to use them.
+// Only the policy managers named in managers run, or all of them if it's empty.
+// If timings is true, how long each policy manager took is returned instead, even if one of them failed.
+// The update is cancelled if it takes longer than the apply timeout.
+// Plain refreshes of a user are rate limited: rapid ones share the result of a single fetch and apply.
+func (s *Service) updatePolicyFor(ctx context.Context, isComputer bool, target string, objectClass ad.ObjectClass, krb5cc string, purge, dryRun, forceRefresh bool, managers []string, timings bool) (changes string, err error) {
+	if isComputer || purge || dryRun || forceRefresh || len(managers) > 0 || timings {
+		return s.applyPolicyFor(ctx, isComputer, target, objectClass, krb5cc, purge, dryRun, forceRefresh, managers, timings)
+	}
+
+	coalesced, err := s.refreshLimiter.Do(ctx, target, func() error {
+		_, err := s.applyPolicyFor(ctx, isComputer, target, objectClass, krb5cc, false, false, false, nil, false)
+		return err
+	})
+	if coalesced {
+		log.Infof(ctx, "Policy refresh of %q coalesced with a recent one", target)
+	}
+	return "", err
+}
+
+// updateUsersPolicies updates the policies of users, as updatePolicyFor does for each of them, in one coordinated
+// pass: their policies are fetched together, so that the GPOs they share are downloaded only once, before being
+// applied to each user in turn. Users with imported policies don't fetch them from AD. Updates are not rate
+// limited.
+// The changes or timings of each user are returned in the users order. An error for a user doesn't prevent
+// updating the others: the returned error joins the errors of each user which failed.
+func (s *Service) updateUsersPolicies(ctx context.Context, users []string, purge, dryRun, forceRefresh bool, managers []string, timings bool) (msgs []string, err error) {
+	msgs = make([]string, len(users))
+	errs := make([]error, len(users))
+	pols := make([]*policies.Policies, len(users))
+
 	if !purge {
-		pols, err = s.adc.GetPolicies(ctx, target, objectClass, krb5cc)
-		if err != nil {
-			return err
+		// Fetching in the same pass is bounded by the apply timeout of a single update too.
+		fetchCtx, cancel := context.WithTimeout(ctx, s.applyTimeout)
+		defer cancel()
+
+		var toFetch []string
+		indexes := make(map[string]int)
+		for i, user := range users {
+			if s.useImportedPolicies {
+				p, imported, err := s.policyManager.ImportedPolicies(fetchCtx, user)
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				if imported {
+					log.Warningf(ctx, "Applying imported policies to %q instead of the ones from AD", user)
+					pols[i] = &p
+					continue
+				}
+			}
+			policies.ReportProgress(ctx, policies.Progress{Target: user, Stage: policies.StageFetch})
+			toFetch = append(toFetch, user)
+			indexes[user] = i
+		}
+
+		for _, r := range s.adc.GetUsersPolicies(fetchCtx, toFetch, forceRefresh) {
+			i := indexes[r.User]
+			if r.Err != nil {
+				errs[i] = r.Err
+				continue
+			}
+			pols[i] = &r.Policies
+		}
+	}
+
+	for i, user := range users {
+		if errs[i] != nil {
+			continue
+		}
+		if pols[i] == nil {
+			pols[i] = &policies.Policies{}
+		}
+		msgs[i], errs[i] = s.withApplyTimeout(ctx, user, func(ctx context.Context) (string, error) {
+			return s.applyPolicies(ctx, false, user, pols[i], dryRun, managers, timings)
+		})
+	}
+
+	// Each error already names its user.
+	return msgs, errors.Join(errs...)
+}
+
+// applyPolicyFor updates the policy for a given object, as updatePolicyFor, without rate limiting.
+func (s *Service) applyPolicyFor(ctx context.Context, isComputer bool, target string, objectClass ad.ObjectClass, krb5cc string, purge, dryRun, forceRefresh bool, managers []string, timings bool) (changes string, err error) {
+	return s.withApplyTimeout(ctx, target, func(ctx context.Context) (_ string, err error) {
+		var pols policies.Policies
+		if !purge {
+			var imported bool
+			if s.useImportedPolicies {
+				if pols, imported, err = s.policyManager.ImportedPolicies(ctx, target); err != nil {
+					return "", err
+				}
+			}
+			if imported {
+				log.Warningf(ctx, "Applying imported policies to %q instead of the ones from AD", target)
+			} else {
+				policies.ReportProgress(ctx, policies.Progress{Target: target, Stage: policies.StageFetch})
+				if pols, err = s.adc.GetPolicies(ctx, target, objectClass, krb5cc, forceRefresh); err != nil {
+					return "", err
+				}
+			}
+		}
+
+		return s.applyPolicies(ctx, isComputer, target, &pols, dryRun, managers, timings)
+	})
+}
+
+// withApplyTimeout runs the update of target by apply, which is cancelled if it takes longer than the apply timeout.
+func (s *Service) withApplyTimeout(ctx context.Context, target string, apply func(ctx context.Context) (string, error)) (changes string, err error) {
+	// Subprocesses are killed and in-flight work cancelled once the update takes too long.
+	ctx, cancel := context.WithTimeout(ctx, s.applyTimeout)
+	defer cancel()
+	defer func() {
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = errors.New(gotext.Get("policy update of %q timed out after %s: %v", target, s.applyTimeout, err))
 		}
+	}()
+
+	return apply(ctx)
+}
+
+// applyPolicies applies pols to target, or returns the changes they would do if dryRun is true.
+// If timings is true, how long each policy manager took is returned instead.
+func (s *Service) applyPolicies(ctx context.Context, isComputer bool, target string, pols *policies.Policies, dryRun bool, managers []string, timings bool) (changes string, err error) {
+	if dryRun {
+		return s.policyManager.DryRunPolicies(ctx, target, isComputer, pols)
+	}
+
+	err = s.policyManager.ApplyPoliciesOnly(ctx, target, isComputer, pols, managers)
+	if !timings {
+		return "", err
+	}
+	return formatTimings(target, s.policyManager.LastTimings(target, false)), err
+}
+
+// formatTimings returns the durations of the policy managers which ran for target.
+func formatTimings(target string, timings []policies.ManagerTiming) string {
+	var out strings.Builder
+	fmt.Fprintln(&out, gotext.Get("Policy managers timings for %s:", target))
+	if len(timings) == 0 {
+		fmt.Fprintf(&out, "  %s\n", gotext.Get("No policy manager ran"))
+	}
+	for _, t := range timings {
+		fmt.Fprintf(&out, "  %s: %s\n", t.Manager, t.Duration.Round(time.Millisecond))
 	}
+	return out.String()
+}
 
-	return s.policyManager.ApplyPolicies(ctx, target, isComputer, &pols)
+// sendChanges sends the changes computed on a dry run or the policy managers timings to the client, if any.
+func sendChanges(send func(changes string), changes string) {
+	if changes == "" {
+		return
+	}
+	send(changes)
 }
 
 // DumpPolicies displays all applied policies for a given user.
+// The JSON format returns the status of the applied policies instead.
 func (s *Service) DumpPolicies(r *adsys.DumpPoliciesRequest, stream adsys.Service_DumpPoliciesServer) (err error) {
 	defer decorate.OnError(&err, gotext.Get("error while displaying applied policies"))
 
@@ -103,10 +359,24 @@ func (s *Service) DumpPolicies(r *adsys.DumpPoliciesRequest, stream adsys.Servic
 		}
 	}
 
-	msg, err := s.policyManager.DumpPolicies(stream.Context(), target, r.GetIsComputer(), r.GetDetails(), r.GetAll())
+	asJSON, err := isJSONFormat(r.GetFormat())
 	if err != nil {
 		return err
 	}
+
+	var msg string
+	if asJSON {
+		// The JSON status always contains all the details: display options only apply to the text format.
+		status, err := s.policyManager.Status(stream.Context(), target, r.GetIsComputer())
+		if err != nil {
+			return err
+		}
+		if msg, err = marshalJSON(status); err != nil {
+			return err
+		}
+	} else if msg, err = s.policyManager.DumpPolicies(stream.Context(), target, r.GetIsComputer(), r.GetDetails(), r.GetAll(), r.GetSources()); err != nil {
+		return err
+	}
 	if err := stream.Send(&adsys.StringResponse{
 		Msg: msg,
 	}); err != nil {
@@ -116,6 +386,212 @@ func (s *Service) DumpPolicies(r *adsys.DumpPoliciesRequest, stream adsys.Servic
 	return nil
 }
 
+// ExportPolicies returns a snapshot of the policies applied to a given user or the machine.
+func (s *Service) ExportPolicies(r *adsys.ExportPoliciesRequest, stream adsys.Service_ExportPoliciesServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while exporting policies"))
+
+	// Snapshots contain all the assets of the policies: only administrators can export them.
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	var target string
+	if !r.GetIsComputer() {
+		target, err = s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), ad.UserObject)
+		if err != nil {
+			return err
+		}
+	}
+
+	snapshot, err := s.policyManager.ExportPolicies(stream.Context(), target, r.GetIsComputer())
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: snapshot,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send policies snapshot to client: %v", err)
+	}
+
+	return nil
+}
+
+// ImportPolicies stores a snapshot of policies for a given user or the machine.
+func (s *Service) ImportPolicies(r *adsys.ImportPoliciesRequest, stream adsys.Service_ImportPoliciesServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while importing policies"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	var target string
+	if !r.GetIsComputer() {
+		target, err = s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), ad.UserObject)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.policyManager.ImportPolicies(stream.Context(), target, r.GetIsComputer(), r.GetSnapshot()); err != nil {
+		return err
+	}
+	if !s.useImportedPolicies {
+		log.Warning(stream.Context(), gotext.Get("Imported policies are only applied once the daemon is configured to use them"))
+	}
+
+	return nil
+}
+
+// SimulatePolicies prints what applying the GPOs of the request would do for a given user or the machine, for each
+// policy manager. Nothing is downloaded nor applied, and the policies cache is left untouched.
+func (s *Service) SimulatePolicies(r *adsys.SimulatePoliciesRequest, stream adsys.Service_SimulatePoliciesServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while simulating policies"))
+
+	// The GPOs are provided by the client, and nothing is changed on the system.
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), authorizer.ActionAlwaysAllowed); err != nil {
+		return err
+	}
+
+	objectClass := ad.UserObject
+	target := s.adc.Hostname()
+	if r.GetIsComputer() {
+		objectClass = ad.ComputerObject
+	} else {
+		target, err = s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), ad.UserObject)
+		if err != nil {
+			return err
+		}
+	}
+
+	var gpos []ad.LocalGPO
+	for _, g := range r.GetGpos() {
+		gpos = append(gpos, ad.LocalGPO{
+			Name:            g.GetName(),
+			MachineRegistry: g.GetMachineRegistry(),
+			UserRegistry:    g.GetUserRegistry(),
+		})
+	}
+
+	pols, err := s.adc.ParseLocalGPOs(stream.Context(), gpos, objectClass)
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.policyManager.SimulatePolicies(stream.Context(), target, r.GetIsComputer(), &pols)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: msg,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send simulated policies to client: %v", err)
+	}
+	return nil
+}
+
+// ListCache lists the objects with policies cached, with their size and last update time.
+func (s *Service) ListCache(_ *adsys.Empty, stream adsys.Service_ListCacheServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while listing policies cache"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	msg, err := s.policyManager.ListCache(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: msg,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send policies cache list to client: %v", err)
+	}
+	return nil
+}
+
+// PurgeCache removes the policies cached for a given user, the machine or all objects.
+// All the GPOs of the purged objects are downloaded again on their next update.
+func (s *Service) PurgeCache(r *adsys.PurgeCacheRequest, stream adsys.Service_PurgeCacheServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while purging policies cache"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	var target string
+	if !r.GetIsComputer() && !r.GetAll() {
+		target, err = s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), ad.UserObject)
+		if err != nil {
+			return err
+		}
+	}
+
+	purged, err := s.policyManager.PurgeCache(stream.Context(), target, r.GetIsComputer(), r.GetAll())
+	for _, objectName := range purged {
+		s.adc.RefreshOnNextUpdate(objectName)
+	}
+	return err
+}
+
+// ScriptsLogs displays the output of the last scripts runs for a given user or the machine.
+func (s *Service) ScriptsLogs(r *adsys.ScriptsLogsRequest, stream adsys.Service_ScriptsLogsServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while displaying scripts logs"))
+
+	// Machine scripts output can contain system details: only administrators can read it.
+	if r.GetIsComputer() {
+		if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+			return err
+		}
+	}
+
+	var target string
+	if !r.GetIsComputer() {
+		target, err = s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), ad.UserObject)
+		if err != nil {
+			return err
+		}
+		if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, target),
+			actions.ActionPolicyDump); err != nil {
+			return err
+		}
+	}
+
+	msg, err := s.policyManager.ScriptsLogs(stream.Context(), target, r.GetIsComputer(), int(r.GetCount()))
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: msg,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send scripts logs to client: %v", err)
+	}
+
+	return nil
+}
+
+// CertificateStatus displays the certificates enrolled by the machine.
+func (s *Service) CertificateStatus(_ *adsys.Empty, stream adsys.Service_CertificateStatusServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while displaying certificate enrollment status"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	msg, err := s.policyManager.CertificateStatus(stream.Context())
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: msg,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send certificate enrollment status to client: %v", err)
+	}
+
+	return nil
+}
+
 // DumpPoliciesDefinitions dumps requested policy definitions stored in daemon at build time.
 func (s *Service) DumpPoliciesDefinitions(r *adsys.DumpPolicyDefinitionsRequest, stream adsys.Service_DumpPoliciesDefinitionsServer) (err error) {
 	defer decorate.OnError(&err, gotext.Get("error while dumping policy definitions"))
@@ -139,6 +615,66 @@ func (s *Service) DumpPoliciesDefinitions(r *adsys.DumpPolicyDefinitionsRequest,
 	return nil
 }
 
+// GPOList returns the GPOs resolved from AD for a given user or the machine, in the order they apply, without
+// downloading nor applying them.
+func (s *Service) GPOList(r *adsys.GPOListRequest, stream adsys.Service_GPOListServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while listing GPOs"))
+
+	objectClass := ad.UserObject
+	if r.GetIsComputer() {
+		objectClass = ad.ComputerObject
+	}
+	target, err := s.adc.NormalizeTargetName(stream.Context(), r.GetTarget(), objectClass)
+	if err != nil {
+		return err
+	}
+
+	// Listing GPOs contacts AD with the object credentials, as updating its policies does.
+	targetForAuthorizer := target
+	if r.GetIsComputer() {
+		targetForAuthorizer = "root"
+	}
+	if err := s.authorizer.IsAllowedFromContext(context.WithValue(stream.Context(), authorizer.OnUserKey, targetForAuthorizer),
+		actions.ActionPolicyUpdate); err != nil {
+		return err
+	}
+
+	gpos, err := s.adc.ListGPOs(stream.Context(), target, objectClass, r.GetKrb5Cc())
+	if err != nil {
+		return err
+	}
+
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: formatGPOList(target, gpos),
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send gpo list to client: %v", err)
+	}
+
+	return nil
+}
+
+// formatGPOList returns a human readable list of the GPOs applying to target, highest priority first.
+func formatGPOList(target string, gpos []ad.GPOLink) string {
+	if len(gpos) == 0 {
+		return gotext.Get("No GPO applies to %q\n", target)
+	}
+
+	var out strings.Builder
+	out.WriteString(gotext.Get("GPOs applying to %q, highest priority first:\n", target))
+	for i, g := range gpos {
+		fmt.Fprintf(&out, "%d. %s %s\n", i+1, g.Name, g.ID)
+		fmt.Fprintf(&out, "   %s\n", gotext.Get("Linked to: %s", g.LinkPath))
+		fmt.Fprintf(&out, "   %s\n", gotext.Get("Path: %s", g.URL))
+		if g.Enforced {
+			fmt.Fprintf(&out, "   %s\n", gotext.Get("Enforced"))
+		}
+		if g.InheritanceBlocked {
+			fmt.Fprintf(&out, "   %s\n", gotext.Get("Linked container blocks inheritance"))
+		}
+	}
+	return out.String()
+}
+
 // GPOListScript returns the embedded GPO python list script.
 func (s *Service) GPOListScript(_ *adsys.Empty, stream adsys.Service_GPOListScriptServer) (err error) {
 	defer decorate.OnError(&err, gotext.Get("error while getting gpo list script"))
@@ -1,6 +1,8 @@
 package adsysservice
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"slices"
@@ -13,6 +15,7 @@ import (
 	"github.com/ubuntu/adsys/internal/adsysservice/actions"
 	"github.com/ubuntu/adsys/internal/authorizer"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/doctor"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies"
 	"github.com/ubuntu/adsys/internal/stdforward"
@@ -59,13 +62,18 @@ func (ss streamWriter) Write(b []byte) (n int, err error) {
 }
 
 // Status returns internal daemon status to the client.
-func (s *Service) Status(_ *adsys.Empty, stream adsys.Service_StatusServer) (err error) {
+func (s *Service) Status(r *adsys.StatusRequest, stream adsys.Service_StatusServer) (err error) {
 	defer decorate.OnError(&err, gotext.Get("error while getting daemon status"))
 
 	if err := s.authorizer.IsAllowedFromContext(stream.Context(), authorizer.ActionAlwaysAllowed); err != nil {
 		return err
 	}
 
+	asJSON, err := isJSONFormat(r.GetFormat())
+	if err != nil {
+		return err
+	}
+
 	state := s.state
 
 	// Empty values: takes defaults from conf to avoid exposing too much data
@@ -82,62 +90,155 @@ func (s *Service) Status(_ *adsys.Empty, stream adsys.Service_StatusServer) (err
 		state.apparmorDir = consts.DefaultApparmorDir
 	}
 
-	timeout := gotext.Get("unknown")
-	socket := gotext.Get("unknown")
+	status := daemonStatus{
+		Machine:         objectUpdate{Name: s.adc.Hostname()},
+		ActiveDirectory: s.adc.GetInfo(stream.Context()),
+		Daemon: daemonInfo{
+			CachePath:     state.cacheDir,
+			RunPath:       state.runDir,
+			DconfPath:     state.dconfDir,
+			SudoersPath:   state.sudoersDir,
+			PolicyKitPath: state.policyKitDir,
+			ApparmorPath:  state.apparmorDir,
+		},
+		Errors: []string{},
+	}
 	if s.daemon != nil {
-		timeout = s.daemon.Timeout().String()
-		sock := s.daemon.GetSocketAddr()
-		if sock != "" {
-			socket = sock
+		status.Daemon.Timeout = s.daemon.Timeout().String()
+		status.Daemon.Socket = s.daemon.GetSocketAddr()
+	}
+
+	if next, err := s.nextRefreshTime(); err == nil {
+		status.NextRefresh = next
+	} else {
+		log.Warning(stream.Context(), err)
+		status.Errors = append(status.Errors, err.Error())
+	}
+
+	if t, err := s.policyManager.LastUpdateFor(stream.Context(), "", true); err == nil {
+		status.Machine.LastUpdate = &t
+	}
+
+	users, err := s.adc.ListUsers(stream.Context(), true)
+	if err == nil {
+		status.Users = []objectUpdate{}
+		for _, u := range users {
+			update := objectUpdate{Name: u}
+			if t, err := s.policyManager.LastUpdateFor(stream.Context(), u, false); err == nil {
+				update.LastUpdate = &t
+			}
+			status.Users = append(status.Users, update)
+		}
+	} else {
+		status.Errors = append(status.Errors, err.Error())
+	}
+
+	status.UbuntuPro.ProOnlyRules = slices.Clone(policies.ProOnlyRules)
+	slices.Sort(status.UbuntuPro.ProOnlyRules)
+	status.UbuntuPro.Active = s.policyManager.GetSubscriptionState(stream.Context())
+
+	var msg string
+	if asJSON {
+		if msg, err = marshalJSON(status); err != nil {
+			return err
 		}
+	} else {
+		msg = status.String()
+	}
+
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: msg,
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send status to client: %v", err)
 	}
 
-	adInfo := s.adc.GetInfo(stream.Context())
+	return nil
+}
 
+// daemonStatus is the status of the daemon, with the last policies updates of the machine and connected users.
+type daemonStatus struct {
+	Machine objectUpdate `json:"machine"`
+	// Users is nil if the connected users can't be listed.
+	Users           []objectUpdate  `json:"users"`
+	NextRefresh     *time.Time      `json:"next_refresh,omitempty"`
+	UbuntuPro       ubuntuProStatus `json:"ubuntu_pro"`
+	ActiveDirectory string          `json:"active_directory"`
+	Daemon          daemonInfo      `json:"daemon"`
+	// Errors lists the problems preventing to report part of the status.
+	Errors []string `json:"errors"`
+}
+
+// objectUpdate is the last time policies were applied to an object. LastUpdate is nil if they were never applied.
+type objectUpdate struct {
+	Name       string     `json:"name"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+}
+
+// ubuntuProStatus is the state of the Ubuntu Pro subscription, gating the policy types in ProOnlyRules.
+type ubuntuProStatus struct {
+	Active       bool     `json:"active"`
+	ProOnlyRules []string `json:"pro_only_rules"`
+}
+
+// daemonInfo is the configuration of the running daemon. Timeout and Socket are empty when unknown.
+type daemonInfo struct {
+	Timeout       string `json:"timeout,omitempty"`
+	Socket        string `json:"socket,omitempty"`
+	CachePath     string `json:"cache_path"`
+	RunPath       string `json:"run_path"`
+	DconfPath     string `json:"dconf_path"`
+	SudoersPath   string `json:"sudoers_path"`
+	PolicyKitPath string `json:"policykit_path"`
+	ApparmorPath  string `json:"apparmor_path"`
+}
+
+// String returns the human readable status of the daemon.
+func (st daemonStatus) String() string {
 	timeLayout := "Mon Jan 2 15:04"
 
+	timeout := gotext.Get("unknown")
+	if st.Daemon.Timeout != "" {
+		timeout = st.Daemon.Timeout
+	}
+	socket := gotext.Get("unknown")
+	if st.Daemon.Socket != "" {
+		socket = st.Daemon.Socket
+	}
+
 	nextRefresh := gotext.Get("unknown")
-	if next, err := s.nextRefreshTime(); err == nil {
-		nextRefresh = next.Format(timeLayout)
-	} else {
-		log.Warning(stream.Context(), err)
+	if st.NextRefresh != nil {
+		nextRefresh = st.NextRefresh.Format(timeLayout)
 	}
 
 	// FIXME: gotext.Get needs to have the arguments parsed.
 	updateFmt := "%s" + gotext.Get(", updated on ") + "%s"
 	updateMachine := gotext.Get("Machine, no gpo applied found")
-	t, err := s.policyManager.LastUpdateFor(stream.Context(), "", true)
-	if err == nil {
-		updateMachine = fmt.Sprintf(updateFmt, gotext.Get("Machine"), t.Format(timeLayout))
+	if st.Machine.LastUpdate != nil {
+		updateMachine = fmt.Sprintf(updateFmt, gotext.Get("Machine"), st.Machine.LastUpdate.Format(timeLayout))
 	}
 
 	updateUsers := fmt.Sprint(gotext.Get("Can't get connected users"))
-	users, err := s.adc.ListUsers(stream.Context(), true)
-	if err == nil {
+	if st.Users != nil {
 		updateUsers = fmt.Sprint(gotext.Get("Connected users:"))
-		for _, u := range users {
-			if t, err := s.policyManager.LastUpdateFor(stream.Context(), u, false); err == nil {
-				updateUsers = updateUsers + "\n  " + fmt.Sprintf(updateFmt, u, t.Format(timeLayout))
+		for _, u := range st.Users {
+			if u.LastUpdate != nil {
+				updateUsers = updateUsers + "\n  " + fmt.Sprintf(updateFmt, u.Name, u.LastUpdate.Format(timeLayout))
 			} else {
-				updateUsers = updateUsers + "\n  " + gotext.Get("%s, no gpo applied found", u)
+				updateUsers = updateUsers + "\n  " + gotext.Get("%s, no gpo applied found", u.Name)
 			}
 		}
-		if len(users) == 0 {
+		if len(st.Users) == 0 {
 			updateUsers = updateUsers + "\n  " + gotext.Get("None")
 		}
 	}
 
 	ubuntuProStatus := gotext.Get("Ubuntu Pro subscription is not active on this machine. Rules belonging to the following policy types will not be applied:\n")
-	proOnlyRules := slices.Clone(policies.ProOnlyRules)
-	slices.Sort(proOnlyRules)
-	ubuntuProStatus = ubuntuProStatus + "  - " + strings.Join(proOnlyRules, "\n  - ")
-
-	subscriptionEnabled := s.policyManager.GetSubscriptionState(stream.Context())
-	if subscriptionEnabled {
+	ubuntuProStatus = ubuntuProStatus + "  - " + strings.Join(st.UbuntuPro.ProOnlyRules, "\n  - ")
+	if st.UbuntuPro.Active {
 		ubuntuProStatus = gotext.Get("Ubuntu Pro subscription active.")
 	}
 
-	status := gotext.Get(`%s
+	return gotext.Get(`%s
 %s
 Next Refresh: %s
 
@@ -156,19 +257,43 @@ Daemon:
   PolicyKit path: %s
   Apparmor path: %s`, updateMachine, updateUsers, nextRefresh,
 		ubuntuProStatus,
-		strings.Join(strings.Split(adInfo, "\n"), "\n  "),
-		timeout, socket, state.cacheDir, state.runDir, state.dconfDir,
-		state.sudoersDir, state.policyKitDir, state.apparmorDir)
+		strings.Join(strings.Split(st.ActiveDirectory, "\n"), "\n  "),
+		timeout, socket, st.Daemon.CachePath, st.Daemon.RunPath, st.Daemon.DconfPath,
+		st.Daemon.SudoersPath, st.Daemon.PolicyKitPath, st.Daemon.ApparmorPath)
+}
 
-	if err := stream.Send(&adsys.StringResponse{
-		Msg: status,
-	}); err != nil {
-		log.Warningf(stream.Context(), "couldn't send status to client: %v", err)
+// Ready returns if the daemon is fully initialized. If requested, it waits for the daemon to be ready first.
+func (s *Service) Ready(r *adsys.ReadyRequest, stream adsys.Service_ReadyServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while checking if the daemon is ready"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), authorizer.ActionAlwaysAllowed); err != nil {
+		return err
 	}
 
+	if r.GetWait() {
+		if err := s.daemon.WaitReady(stream.Context()); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Send(&adsys.ReadyResponse{
+		Ready: s.daemon.Ready(),
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send daemon readiness to client: %v", err)
+	}
 	return nil
 }
 
+// Initialize checks the connection to Active Directory once the daemon is serving: the daemon is then ready.
+// Policies can still be applied from cache if Active Directory can't be reached, so a failure is only reported.
+func (s *Service) Initialize(ctx context.Context) {
+	if err := s.adc.CheckConnection(ctx); err != nil {
+		log.Warning(ctx, gotext.Get("Active Directory can't be reached on startup: %v", err))
+		return
+	}
+	log.Debug(ctx, gotext.Get("Active Directory is reachable"))
+}
+
 // Stop requests to stop the service once all connections are done. Force will shut it down immediately and drop
 // existing connections.
 func (s *Service) Stop(r *adsys.StopRequest, stream adsys.Service_StopServer) (err error) {
@@ -182,6 +307,28 @@ func (s *Service) Stop(r *adsys.StopRequest, stream adsys.Service_StopServer) (e
 	return nil
 }
 
+// Doctor runs the diagnostic checks of the machine configuration and streams their report.
+// It returns an error once the report is sent if any check failed.
+func (s *Service) Doctor(_ *adsys.Empty, stream adsys.Service_DoctorServer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("error while diagnosing the machine configuration"))
+
+	if err := s.authorizer.IsAllowedFromContext(stream.Context(), actions.ActionServiceManage); err != nil {
+		return err
+	}
+
+	report := doctor.Run(stream.Context(), doctor.DefaultChecks(s.adBackend, s.state.cacheDir)...)
+	if err := stream.Send(&adsys.StringResponse{
+		Msg: report.String(),
+	}); err != nil {
+		log.Warningf(stream.Context(), "couldn't send diagnostic report to client: %v", err)
+	}
+
+	if report.Failed() {
+		return errors.New(gotext.Get("some checks failed"))
+	}
+	return nil
+}
+
 // ListUsers returns the list of currently active users.
 func (s *Service) ListUsers(r *adsys.ListUsersRequest, stream adsys.Service_ListUsersServer) (err error) {
 	defer decorate.OnError(&err, gotext.Get("error while trying to get the list of active users"))
@@ -203,6 +350,26 @@ func (s *Service) ListUsers(r *adsys.ListUsersRequest, stream adsys.Service_List
 	return nil
 }
 
+// isJSONFormat returns if format requests a JSON output. The default text format is requested by an empty format.
+func isJSONFormat(format string) (bool, error) {
+	switch format {
+	case "", "text":
+		return false, nil
+	case "json":
+		return true, nil
+	}
+	return false, errors.New(gotext.Get("unsupported output format %q", format))
+}
+
+// marshalJSON returns the indented JSON document of v.
+func marshalJSON(v any) (string, error) {
+	d, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(d), nil
+}
+
 // nextRefreshTime returns next adsys schedule refresh call.
 func (s Service) nextRefreshTime() (next *time.Time, err error) {
 	defer decorate.OnError(&err, gotext.Get("error while trying to determine next refresh time"))
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Recorder collects the files written and the systemd units acted on by the policy managers during a policy
+// application.
+type Recorder struct {
+	mu    sync.Mutex
+	files map[string][]string
+	units map[string][]string
+}
+
+// NewRecorder returns a new recorder of the changes of a policy application.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		files: make(map[string][]string),
+		units: make(map[string][]string),
+	}
+}
+
+type recorderKey struct{}
+type managerKey struct{}
+
+// WithRecorder returns a context whose policy application records its changes in r.
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// WithManager returns a context whose changes are recorded for the policy manager named manager.
+func WithManager(ctx context.Context, manager string) context.Context {
+	return context.WithValue(ctx, managerKey{}, manager)
+}
+
+// RecordFile records that path was written by the policy manager of ctx, if ctx has a recorder.
+func RecordFile(ctx context.Context, path string) {
+	r, manager, ok := fromContext(ctx)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.files[manager], path) {
+		r.files[manager] = append(r.files[manager], path)
+	}
+}
+
+// RecordUnit records that the policy manager of ctx ran action, like "start" or "daemon-reload", on unit, if ctx has
+// a recorder. unit is empty for actions on the whole system manager.
+func RecordUnit(ctx context.Context, action, unit string) {
+	r, manager, ok := fromContext(ctx)
+	if !ok {
+		return
+	}
+
+	u := action
+	if unit != "" {
+		u = fmt.Sprintf("%s %s", action, unit)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.units[manager] = append(r.units[manager], u)
+}
+
+// Changes returns the files written and the actions on units recorded for manager, in the order they happened.
+func (r *Recorder) Changes(manager string) (files, units []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.files[manager]), slices.Clone(r.units[manager])
+}
+
+// fromContext returns the recorder and the policy manager of ctx, and false if there is no recorder.
+func fromContext(ctx context.Context) (r *Recorder, manager string, ok bool) {
+	r, ok = ctx.Value(recorderKey{}).(*Recorder)
+	if !ok {
+		return nil, "", false
+	}
+	manager, _ = ctx.Value(managerKey{}).(string)
+	return r, manager, true
+}
@@ -1,6 +1,10 @@
 // Package authorizer deals client authorization based on a definite set of polkit actions.
 // The client uid and pid are obtained via the unix socket (SO_PEERCRED) information,
 // that are attached to the grpc request by the server.
+//
+// Privileged actions, which are all actions but the ones always allowed or acting on the calling user itself, are
+// only checked by polkit for root and members of the allowed groups: other callers are denied with a
+// PermissionDenied error.
 package authorizer
 
 import (
@@ -11,6 +15,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -18,7 +23,9 @@ import (
 	"github.com/leonelquinteros/gotext"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/decorate"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type caller interface {
@@ -30,9 +37,21 @@ type Authorizer struct {
 	authority  caller
 	userLookup func(string) (*user.User, error)
 
+	allowedGroups []string
+	groupLookup   func(string) (*user.Group, error)
+	userGroupIDs  func(uid uint32) ([]string, error)
+
 	root string
 }
 
+// WithAllowedGroups restricts privileged actions to root and the members of groups.
+// No group keeps privileged actions open to any caller authorized by polkit.
+func WithAllowedGroups(groups []string) func(*Authorizer) {
+	return func(a *Authorizer) {
+		a.allowedGroups = groups
+	}
+}
+
 func withAuthority(c caller) func(*Authorizer) {
 	return func(a *Authorizer) {
 		a.authority = c
@@ -45,6 +64,18 @@ func withUserLookup(userLookup func(string) (*user.User, error)) func(*Authorize
 	}
 }
 
+func withGroupLookup(groupLookup func(string) (*user.Group, error)) func(*Authorizer) {
+	return func(a *Authorizer) {
+		a.groupLookup = groupLookup
+	}
+}
+
+func withUserGroupIDs(userGroupIDs func(uid uint32) ([]string, error)) func(*Authorizer) {
+	return func(a *Authorizer) {
+		a.userGroupIDs = userGroupIDs
+	}
+}
+
 func withRoot(root string) func(*Authorizer) {
 	return func(a *Authorizer) {
 		a.root = root
@@ -59,9 +90,11 @@ func New(bus *dbus.Conn, options ...func(*Authorizer)) (auth *Authorizer, err er
 		"/org/freedesktop/PolicyKit1/Authority")
 
 	a := Authorizer{
-		authority:  authority,
-		root:       "/",
-		userLookup: user.Lookup,
+		authority:    authority,
+		root:         "/",
+		userLookup:   user.Lookup,
+		groupLookup:  user.LookupGroup,
+		userGroupIDs: userGroupIDs,
 	}
 
 	for _, option := range options {
@@ -159,6 +192,12 @@ func (a Authorizer) isAllowed(ctx context.Context, action Action, pid int32, uid
 		}
 	}
 
+	if action.ID != action.SelfID {
+		if err := a.isInAllowedGroups(ctx, uid); err != nil {
+			return err
+		}
+	}
+
 	f, err := os.Open(filepath.Join(a.root, fmt.Sprintf("proc/%d/stat", pid)))
 	if err != nil {
 		return errors.New(gotext.Get("couldn't open stat file for process: %v", err))
@@ -196,6 +235,43 @@ func (a Authorizer) isAllowed(ctx context.Context, action Action, pid int32, uid
 	return nil
 }
 
+// isInAllowedGroups returns a PermissionDenied error if allowed groups are set and uid is not a member of any of them.
+func (a Authorizer) isInAllowedGroups(ctx context.Context, uid uint32) error {
+	if len(a.allowedGroups) == 0 {
+		return nil
+	}
+
+	gids, err := a.userGroupIDs(uid)
+	if err != nil {
+		return status.Error(codes.PermissionDenied, gotext.Get("couldn't retrieve groups of uid %d: %v", uid, err))
+	}
+
+	for _, name := range a.allowedGroups {
+		g, err := a.groupLookup(name)
+		if err != nil {
+			// Allowed groups can be missing on this system, like the legacy admin group.
+			log.Debug(ctx, gotext.Get("Ignoring allowed group %q: %v", name, err))
+			continue
+		}
+		if slices.Contains(gids, g.Gid) {
+			log.Debug(ctx, gotext.Get("Authorized for privileged actions as member of %q", name))
+			return nil
+		}
+	}
+
+	return status.Error(codes.PermissionDenied,
+		gotext.Get("uid %d is neither root nor a member of the allowed groups: %s", uid, strings.Join(a.allowedGroups, ", ")))
+}
+
+// userGroupIDs returns the ids of the groups uid is a member of, including its primary group.
+func userGroupIDs(uid uint32) ([]string, error) {
+	u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		return nil, err
+	}
+	return u.GroupIds()
+}
+
 // getStartTimeFromReader determines the start time from a process stat file content
 //
 // The implementation is intended to be compatible with polkit:
@@ -3,13 +3,16 @@ package authorizer_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os/user"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/ubuntu/adsys/internal/authorizer"
 	"github.com/ubuntu/adsys/internal/testutils"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 func TestIsAllowedFromContext(t *testing.T) {
@@ -35,8 +38,13 @@ func TestIsAllowedFromContext(t *testing.T) {
 		userUIDReturn   string
 		userLookupError bool
 
-		wantAuthorized  bool
-		wantPolkitError bool
+		allowedGroups    []string
+		callerGroupIDs   []string
+		groupsQueryError bool
+
+		wantAuthorized       bool
+		wantPolkitError      bool
+		wantPermissionDenied bool
 	}{
 		"Root is always authorized": {uid: 0, wantAuthorized: true},
 		"Valid process and ACK":     {pid: 10000, uid: 1000, wantAuthorized: true},
@@ -45,9 +53,25 @@ func TestIsAllowedFromContext(t *testing.T) {
 		"Extract current user action from request": {action: myUserOtherAction, userUIDReturn: "1000", pid: 10000, uid: 1000, wantAuthorized: true},
 		"Extract other user action from request":   {action: myUserOtherAction, userUIDReturn: "999", pid: 10000, uid: 1000, wantAuthorized: true},
 
+		// Allowed groups
+		"Root is always authorized with allowed groups":           {uid: 0, allowedGroups: []string{"sudo"}, wantAuthorized: true},
+		"Member of allowed group and ACK":                         {allowedGroups: []string{"sudo"}, callerGroupIDs: []string{"1000", "27"}, pid: 10000, uid: 1000, wantAuthorized: true},
+		"Member of allowed group and NACK":                        {allowedGroups: []string{"sudo"}, callerGroupIDs: []string{"1000", "27"}, pid: 10000, uid: 1000, wantAuthorized: false},
+		"Member of any allowed group and ACK":                     {allowedGroups: []string{"sudo", "admin"}, callerGroupIDs: []string{"1000", "118"}, pid: 10000, uid: 1000, wantAuthorized: true},
+		"Member of allowed group and ACK on other user action":    {action: myUserOtherAction, userUIDReturn: "999", allowedGroups: []string{"sudo"}, callerGroupIDs: []string{"27"}, pid: 10000, uid: 1000, wantAuthorized: true},
+		"Allowed groups missing on the system are ignored":        {allowedGroups: []string{"doesnotexist", "sudo"}, callerGroupIDs: []string{"27"}, pid: 10000, uid: 1000, wantAuthorized: true},
+		"Current user action does not require an allowed group":   {action: myUserOtherAction, userUIDReturn: "1000", allowedGroups: []string{"sudo"}, callerGroupIDs: []string{"1000"}, pid: 10000, uid: 1000, wantAuthorized: true},
+		"Always allowed action does not require an allowed group": {action: authorizer.ActionAlwaysAllowed, allowedGroups: []string{"sudo"}, callerGroupIDs: []string{"1000"}, pid: 10000, uid: 1000, wantAuthorized: true},
+
 		// Unauthorized cases
 		"Unauthorizes when user lookup returns an error": {action: myUserOtherAction, userLookupError: true, pid: 10000, uid: 1000, wantAuthorized: false},
 		"Unauthorizes when user has invalid uid":         {action: myUserOtherAction, userUIDReturn: "NaN", pid: 10000, uid: 1000, wantAuthorized: false},
+
+		// Permission denied cases, even if polkit would authorize
+		"Denies non member of allowed groups":                   {allowedGroups: []string{"sudo", "admin"}, callerGroupIDs: []string{"1000"}, pid: 10000, uid: 1000, wantPermissionDenied: true},
+		"Denies non member of allowed groups other user action": {action: myUserOtherAction, userUIDReturn: "999", allowedGroups: []string{"sudo"}, callerGroupIDs: []string{"1000"}, pid: 10000, uid: 1000, wantPermissionDenied: true},
+		"Denies when no allowed group exists on the system":     {allowedGroups: []string{"doesnotexist"}, callerGroupIDs: []string{"27"}, pid: 10000, uid: 1000, wantPermissionDenied: true},
+		"Denies when caller groups can't be retrieved":          {allowedGroups: []string{"sudo"}, groupsQueryError: true, pid: 10000, uid: 1000, wantPermissionDenied: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -74,16 +98,30 @@ func TestIsAllowedFromContext(t *testing.T) {
 					}
 				}
 			}
+			userGroupIDs := func(uid uint32) ([]string, error) {
+				if uid != tc.uid {
+					return nil, fmt.Errorf("unexpected uid %d", uid)
+				}
+				if tc.groupsQueryError {
+					return nil, errors.New("Groups error requested")
+				}
+				return tc.callerGroupIDs, nil
+			}
 			d := &authorizer.DbusMock{
-				IsAuthorized:    tc.wantAuthorized,
+				IsAuthorized:    tc.wantAuthorized || tc.wantPermissionDenied,
 				WantPolkitError: tc.wantPolkitError}
-			a, err := authorizer.New(bus, authorizer.WithAuthority(d), authorizer.WithRoot("testdata"), authorizer.WithUserLookup(userLookup))
+			a, err := authorizer.New(bus, authorizer.WithAuthority(d), authorizer.WithRoot("testdata"), authorizer.WithUserLookup(userLookup),
+				authorizer.WithAllowedGroups(tc.allowedGroups), authorizer.WithGroupLookup(groupLookup), authorizer.WithUserGroupIDs(userGroupIDs))
 			if err != nil {
 				t.Fatalf("Failed to create authorizer: %v", err)
 			}
 
 			errAllowed := a.IsAllowedFromContext(ctx, tc.action)
 
+			if tc.wantPermissionDenied {
+				assert.Equal(t, codes.PermissionDenied, status.Code(errAllowed), "IsAllowedFromContext should deny with a PermissionDenied error")
+				return
+			}
 			assert.Equal(t, tc.wantAuthorized, errAllowed == nil, "IsAllowedFromContext returned state match expectations")
 		})
 	}
@@ -144,6 +182,16 @@ func TestIsAllowedFromContextWithoutUserKey(t *testing.T) {
 	assert.Equal(t, false, errAllowed == nil, "IsAllowedFromContext must deny without peer creds info")
 }
 
+// groupLookup mocks the groups of the system as the Ubuntu administrator ones.
+func groupLookup(name string) (*user.Group, error) {
+	gids := map[string]string{"sudo": "27", "admin": "118"}
+	gid, ok := gids[name]
+	if !ok {
+		return nil, user.UnknownGroupError(name)
+	}
+	return &user.Group{Gid: gid, Name: name}, nil
+}
+
 type invalidPeerCredsInfo struct{}
 
 func (invalidPeerCredsInfo) AuthType() string { return "" }
@@ -7,9 +7,11 @@ import (
 )
 
 var (
-	WithAuthority  = withAuthority
-	WithRoot       = withRoot
-	WithUserLookup = withUserLookup
+	WithAuthority    = withAuthority
+	WithRoot         = withRoot
+	WithUserLookup   = withUserLookup
+	WithGroupLookup  = withGroupLookup
+	WithUserGroupIDs = withUserGroupIDs
 )
 
 type PeerCredsInfo = peerCredsInfo
@@ -0,0 +1,84 @@
+// Package adsys is the configuration handler for the adsysd daemon and the adsysctl client.
+//
+// Both commands read the same configuration file, each of them using its own settings.
+package adsys
+
+import (
+	"github.com/ubuntu/adsys/internal/ad/backends/local"
+	"github.com/ubuntu/adsys/internal/ad/backends/sss"
+	"github.com/ubuntu/adsys/internal/ad/backends/winbind"
+	"github.com/ubuntu/adsys/internal/config"
+)
+
+// DaemonConfig represents the configurable options of adsysd.
+type DaemonConfig struct {
+	Verbose int
+
+	Socket   string
+	CacheDir string `mapstructure:"cache_dir"`
+	StateDir string `mapstructure:"state_dir"`
+	RunDir   string `mapstructure:"run_dir"`
+
+	DconfDir       string `mapstructure:"dconf_dir"`
+	SudoersDir     string `mapstructure:"sudoers_dir"`
+	PolicyKitDir   string `mapstructure:"policykit_dir"`
+	ApparmorDir    string `mapstructure:"apparmor_dir"`
+	ApparmorFsDir  string `mapstructure:"apparmorfs_dir"`
+	SystemUnitDir  string `mapstructure:"systemunit_dir"`
+	GlobalTrustDir string `mapstructure:"global_trust_dir"`
+
+	AdBackend     string         `mapstructure:"ad_backend"`
+	SSSdConfig    sss.Config     `mapstructure:"sssd"`
+	WinbindConfig winbind.Config `mapstructure:"winbind"`
+	LocalConfig   local.Config   `mapstructure:"local"`
+
+	ServiceTimeout int `mapstructure:"service_timeout"`
+
+	CertificateRenewalLeadDays int  `mapstructure:"certificate_renewal_lead_days"`
+	GPOLinkOrder               bool `mapstructure:"gpo_link_order"`
+	DconfUserLayers            bool `mapstructure:"dconf_user_layers"`
+	GPODownloadConcurrency     int  `mapstructure:"gpo_download_concurrency"`
+	UseImportedPolicies        bool `mapstructure:"use_imported_policies"`
+	OfflineMaxCacheAgeDays     int  `mapstructure:"offline_max_cache_age_days"`
+	TicketRenewalMinutes       int  `mapstructure:"machine_ticket_renewal_minutes"`
+	ApplyTimeout               int  `mapstructure:"apply_timeout"`
+	ApplyLockTimeout           int  `mapstructure:"apply_lock_timeout"`
+	RefreshMinIntervalSeconds  int  `mapstructure:"refresh_min_interval_seconds"`
+	RefreshCoalesceWindowMs    int  `mapstructure:"refresh_coalesce_window_ms"`
+	MountRetries               int  `mapstructure:"mount_retries"`
+	MountRetryDelaySeconds     int  `mapstructure:"mount_retry_delay_seconds"`
+
+	PreferredADServer string `mapstructure:"preferred_ad_server"`
+	ADSite            string `mapstructure:"ad_site"`
+
+	LoopbackProcessing string `mapstructure:"loopback_processing"`
+
+	AllowUnsignedSYSVOL     bool `mapstructure:"allow_unsigned_sysvol"`
+	RequireSYSVOLEncryption bool `mapstructure:"require_sysvol_encryption"`
+
+	AllowedGroups []string `mapstructure:"allowed_groups"`
+
+	DisabledManagers        []string `mapstructure:"disabled_managers"`
+	CleanupDisabledManagers bool     `mapstructure:"cleanup_disabled_managers"`
+
+	MetricsAddress string `mapstructure:"metrics_address"`
+
+	AuditLog       string `mapstructure:"audit_log"`
+	AuditLogFormat string `mapstructure:"audit_log_format"`
+
+	RefreshOnNetworkUp bool `mapstructure:"refresh_on_network_up"`
+}
+
+// ClientConfig represents the configurable options of adsysctl.
+type ClientConfig struct {
+	Verbose            int
+	Socket             string
+	ClientTimeout      int  `mapstructure:"client_timeout"`
+	DetectCachedTicket bool `mapstructure:"detect_cached_ticket"`
+}
+
+// ValidateConfigFile checks that the configuration file path only contains settings of adsysd or adsysctl, with the
+// expected types.
+func ValidateConfigFile(path string) error {
+	return config.ValidateConfigFile(path, DaemonConfig{}, ClientConfig{})
+}
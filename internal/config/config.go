@@ -7,9 +7,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/leonelquinteros/gotext"
+	"github.com/mitchellh/mapstructure"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -106,3 +110,107 @@ func LoadConfig(c interface{}, viper *viper.Viper) error {
 	}
 	return nil
 }
+
+// ValidateConfigFile checks that the configuration file path only contains settings declared in schemas, with the
+// expected types. Each schema is a configuration struct, as decoded by LoadConfig: as the configuration file can be
+// shared between multiple commands, a setting is only unknown if none of the schemas declares it.
+// Contrary to LoadConfig, values are not converted: a value of the wrong type is an error.
+func ValidateConfigFile(path string, schemas ...interface{}) (err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid configuration file %s", path))
+
+	vip := viper.New()
+	vip.SetConfigFile(path)
+	if err := vip.ReadInConfig(); err != nil {
+		return err
+	}
+	settings := vip.AllSettings()
+
+	invalid := make(map[string]string)
+	unknownBySchema := make([][]string, len(schemas))
+	for i, s := range schemas {
+		unknownBySchema[i] = validateSettings(settings, reflect.TypeOf(s), "", invalid)
+	}
+
+	// Schemas without a nested struct report its settings with the parent one.
+	isUnknown := func(key string, unknown []string) bool {
+		return slices.ContainsFunc(unknown, func(u string) bool { return key == u || strings.HasPrefix(key, u+".") })
+	}
+	var unknown []string
+	for _, keys := range unknownBySchema {
+		for _, key := range keys {
+			if slices.Contains(unknown, key) {
+				continue
+			}
+			if slices.ContainsFunc(unknownBySchema, func(u []string) bool { return !isUnknown(key, u) }) {
+				continue
+			}
+			unknown = append(unknown, key)
+		}
+	}
+
+	var errs []error
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		errs = append(errs, errors.New(gotext.Get("unknown setting %q", key)))
+	}
+	keys := make([]string, 0, len(invalid))
+	for key := range invalid {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		errs = append(errs, errors.New(invalid[key]))
+	}
+	return errors.Join(errs...)
+}
+
+// validateSettings checks settings against the fields of the struct type t, prefix being the path of settings.
+// It returns the settings which are not fields of t, and records in invalid the ones whose value doesn't match the
+// type of their field.
+func validateSettings(settings map[string]interface{}, t reflect.Type, prefix string, invalid map[string]string) (unknown []string) {
+	for key, value := range settings {
+		name := prefix + key
+		field, found := settingField(t, key)
+		if !found {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				invalid[name] = gotext.Get("setting %q expects a map of settings, got %v", name, value)
+				continue
+			}
+			unknown = append(unknown, validateSettings(nested, field.Type, name+".", invalid)...)
+			continue
+		}
+
+		dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{Result: reflect.New(field.Type).Interface()})
+		if err == nil {
+			err = dec.Decode(value)
+		}
+		if err != nil {
+			invalid[name] = gotext.Get("setting %q expects a value of type %s, got %v", name, field.Type, value)
+		}
+	}
+	return unknown
+}
+
+// settingField returns the field of the struct type t the setting key is decoded to, matched as LoadConfig does.
+func settingField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -396,6 +396,88 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+func TestValidateConfigFile(t *testing.T) {
+	t.Parallel()
+
+	type nested struct {
+		Path    string
+		Retries int `mapstructure:"max_retries"`
+	}
+	type daemonConfig struct {
+		Verbose  int
+		Socket   string
+		CacheDir string   `mapstructure:"cache_dir"`
+		Groups   []string `mapstructure:"allowed_groups"`
+		Enabled  bool
+		Backend  nested `mapstructure:"backend"`
+	}
+	type clientConfig struct {
+		Verbose       int
+		Socket        string
+		ClientTimeout int `mapstructure:"client_timeout"`
+	}
+
+	tests := map[string]struct {
+		content string
+		noFile  bool
+
+		wantErrs []string
+	}{
+		"Valid configuration": {content: `
+verbose: 2
+socket: /some/socket
+cache_dir: /some/cache
+allowed_groups:
+  - group1
+  - group2
+enabled: true
+backend:
+  path: /some/path
+  max_retries: 3
+`},
+		"Valid configuration with settings of all schemas": {content: "verbose: 2\ncache_dir: /some/cache\nclient_timeout: 30"},
+		"Setting names are case insensitive":               {content: "Verbose: 2\nCACHE_DIR: /some/cache"},
+		"Empty configuration is valid":                     {content: ""},
+
+		// Error cases
+		"Error on unknown setting":                    {content: "verbose: 2\ncache_dirs: /some/cache", wantErrs: []string{`"cache_dirs"`}},
+		"Error on unknown nested setting":             {content: "backend:\n  path: /some/path\n  retries: 3", wantErrs: []string{`"backend.retries"`}},
+		"Error on multiple unknown settings":          {content: "verbosity: 2\nbackend:\n  retries: 3", wantErrs: []string{`"verbosity"`, `"backend.retries"`}},
+		"Error on wrong type":                         {content: "client_timeout: thirty", wantErrs: []string{`"client_timeout" expects a value of type int, got thirty`}},
+		"Error on wrong type of shared setting":       {content: "verbose: high", wantErrs: []string{`"verbose" expects a value of type int, got high`}},
+		"Error on wrong type of nested setting":       {content: "backend:\n  max_retries: \"3\"", wantErrs: []string{`"backend.max_retries" expects a value of type int, got 3`}},
+		"Error on wrong type of list setting":         {content: "allowed_groups: group1", wantErrs: []string{`"allowed_groups" expects a value of type []string, got group1`}},
+		"Error on wrong type of bool setting":         {content: "enabled: 1", wantErrs: []string{`"enabled" expects a value of type bool, got 1`}},
+		"Error on unknown setting and wrong type":     {content: "verbose: high\nsockets: /some/socket", wantErrs: []string{`"sockets"`, `"verbose" expects a value of type int`}},
+		"Error on setting which is not a map":         {content: "backend: /some/path", wantErrs: []string{`"backend" expects a map of settings`}},
+		"Error on invalid configuration file content": {content: "verbose: [2", wantErrs: []string{"While parsing config"}},
+		"Error on missing configuration file":         {noFile: true, wantErrs: []string{"no such file or directory"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "adsys.yaml")
+			if !tc.noFile {
+				testutils.WriteFile(t, path, []byte(tc.content), 0600)
+			}
+
+			err := config.ValidateConfigFile(path, daemonConfig{}, clientConfig{})
+			if tc.wantErrs == nil {
+				require.NoError(t, err, "ValidateConfigFile should not have errored out")
+				return
+			}
+			require.Error(t, err, "ValidateConfigFile should have errored out")
+			require.Contains(t, err.Error(), path, "Error should point at the configuration file")
+			for _, want := range tc.wantErrs {
+				require.Contains(t, err.Error(), want, "Error should point at the invalid setting")
+			}
+			// Errors of settings shared between schemas are only reported once.
+			require.Len(t, strings.Split(strings.TrimSpace(err.Error()), "\n"), len(tc.wantErrs), "Error should only report the invalid settings")
+		})
+	}
+}
+
 func chDir(t *testing.T, p string) {
 	t.Helper()
 
@@ -21,7 +21,8 @@ const CmdName = "adwatchd"
 // AppConfig represents the configurable options of the application.
 type AppConfig struct {
 	Verbose int
-	Force   bool `yaml:"-"` // This is a CLI-only option
+	Force   bool `yaml:"-"`                        // This is a CLI-only option
+	DryRun  bool `yaml:"-" mapstructure:"dry-run"` // This is a CLI-only option
 	Dirs    []string
 }
 
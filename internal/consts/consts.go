@@ -10,6 +10,10 @@ import (
 var (
 	// Version is the version of the executable.
 	Version = "dev"
+
+	// DefaultAllowedGroups are the default groups whose members, in addition to root, can request privileged
+	// operations to the daemon. They are the administrator groups of Ubuntu.
+	DefaultAllowedGroups = []string{"sudo", "admin"}
 )
 
 const (
@@ -40,9 +44,32 @@ const (
 	// DefaultServiceTimeout is the default time in seconds without any active request before the service exits.
 	DefaultServiceTimeout = 120
 
+	// DefaultApplyTimeout is the default maximum time a policy update of a user or the machine can take.
+	DefaultApplyTimeout = 30 * time.Minute
+
+	// DefaultApplyLockTimeout is the default time a policy update waits for the one in progress to complete.
+	// It matches DefaultApplyTimeout so that waiting updates don't give up on one which can still complete.
+	DefaultApplyLockTimeout = DefaultApplyTimeout
+
 	// DefaultGpoListTimeout is the default time to wait for the GPO list subcommand to finish.
 	DefaultGpoListTimeout = 10 * time.Second
 
+	// DefaultGpoDownloadConcurrency is the default number of GPOs downloaded simultaneously from SYSVOL.
+	DefaultGpoDownloadConcurrency = 4
+
+	// DefaultMountRetries is the default number of times a user mount failing with a transient error is retried.
+	DefaultMountRetries = 3
+
+	// DefaultMountRetryDelay is the default time to wait before retrying a failed user mount for the first time.
+	DefaultMountRetryDelay = 2 * time.Second
+
+	// DefaultDconfUpdateDebounce is the default time to wait for other policies to be applied before running dconf update.
+	DefaultDconfUpdateDebounce = 2 * time.Second
+
+	// DefaultNetworkUpDebounce is the default time to wait for the network connectivity to settle once it comes up,
+	// before refreshing the machine policy.
+	DefaultNetworkUpDebounce = 5 * time.Second
+
 	// DistroID is the distro ID which can be overridden at build time.
 	DistroID = "Ubuntu"
 )
@@ -54,9 +81,17 @@ const (
 
 	// AdysMachineScriptsServiceName is the machine script systemd service.
 	AdysMachineScriptsServiceName = "adsys-machine-scripts.service"
+	// AdsysUserScriptsServiceName is the user script systemd user service.
+	AdsysUserScriptsServiceName = "adsys-user-scripts.service"
 
 	// DefaultDconfDir is the default dconf directory.
 	DefaultDconfDir = "/etc/dconf"
+	// DefaultGSettingsSchemasDir is the default directory for installed GSettings schemas.
+	DefaultGSettingsSchemasDir = "/usr/share/glib-2.0/schemas"
+	// DefaultLocaleDir is the default directory for installed message catalogs.
+	DefaultLocaleDir = "/usr/share/locale"
+	// DefaultAccountsServiceUsersDir is the default directory for AccountsService user settings.
+	DefaultAccountsServiceUsersDir = "/var/lib/AccountsService/users"
 	// DefaultSudoersDir is the default directory for sudoers configuration.
 	DefaultSudoersDir = "/etc/sudoers.d"
 	// DefaultPolicyKitDir is the default directory for policykit configuration and rules.
@@ -65,8 +100,14 @@ const (
 	DefaultApparmorDir = "/etc/apparmor.d/adsys"
 	// DefaultSystemUnitDir is the default directory for systemd unit files.
 	DefaultSystemUnitDir = "/etc/systemd/system"
+	// DefaultUserUnitDir is the default directory for systemd user unit files.
+	DefaultUserUnitDir = "/etc/systemd/user"
 	// DefaultGlobalTrustDir is the default directory for the global trust store.
 	DefaultGlobalTrustDir = "/usr/local/share/ca-certificates"
+	// DefaultSnapdSocket is the default socket of the snapd REST API.
+	DefaultSnapdSocket = "/run/snapd.socket"
+	// DefaultFlatpakSystemDir is the default directory of the flatpak system installation.
+	DefaultFlatpakSystemDir = "/var/lib/flatpak"
 )
 
 // SSSD related properties.
@@ -99,6 +140,23 @@ const (
 	SystemdDbusServiceInterface = "org.freedesktop.systemd1.Service"
 )
 
+// Network connectivity related properties.
+const (
+	// NetworkManagerDbusRegisteredName is the well-known name of NetworkManager on dbus.
+	NetworkManagerDbusRegisteredName = "org.freedesktop.NetworkManager"
+	// NetworkManagerDbusObjectPath is the NetworkManager path for dbus.
+	NetworkManagerDbusObjectPath = "/org/freedesktop/NetworkManager"
+	// NetworkManagerDbusInterface is the interface we are using to access the NetworkManager state.
+	NetworkManagerDbusInterface = "org.freedesktop.NetworkManager"
+
+	// NetworkdDbusRegisteredName is the well-known name of systemd-networkd on dbus.
+	NetworkdDbusRegisteredName = "org.freedesktop.network1"
+	// NetworkdDbusObjectPath is the systemd-networkd path for dbus.
+	NetworkdDbusObjectPath = "/org/freedesktop/network1"
+	// NetworkdDbusManagerInterface is the interface we are using to access the systemd-networkd state.
+	NetworkdDbusManagerInterface = "org.freedesktop.network1.Manager"
+)
+
 // Ubuntu Advantage related properties.
 const (
 	// SubscriptionDbusRegisteredName is the well-known name of UA on dbus.
@@ -108,3 +166,15 @@ const (
 	// SubscriptionDbusInterface is the interface we are using for access dbus properties.
 	SubscriptionDbusInterface = "com.canonical.UbuntuAdvantage.Manager"
 )
+
+// adsys dbus properties.
+const (
+	// AdsysDbusRegisteredName is the well-known name of adsys on dbus.
+	AdsysDbusRegisteredName = "com.ubuntu.adsys"
+	// AdsysDbusObjectPath is the path from which adsys emits its signals.
+	AdsysDbusObjectPath = "/com/ubuntu/adsys"
+	// AdsysDbusPoliciesInterface is the interface of the signals about policies.
+	AdsysDbusPoliciesInterface = "com.ubuntu.adsys.Policies"
+	// AdsysDbusAppliedSignal is the signal emitted when a policy update of a user or the machine completes.
+	AdsysDbusAppliedSignal = "Applied"
+)
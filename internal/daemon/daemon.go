@@ -35,11 +35,15 @@ type Daemon struct {
 
 	systemdSdNotifier   func(unsetEnvironment bool, state string) (bool, error)
 	useSocketActivation bool
+
+	readiness func(context.Context)
+	ready     chan struct{}
 }
 
 type options struct {
 	idlingTimeout time.Duration
 	serverQuit    func(context.Context)
+	readiness     func(context.Context)
 
 	// private member that we export for tests.
 	systemdActivationListener func() ([]net.Listener, error)
@@ -67,6 +71,15 @@ func WithServerQuit(f func(context.Context)) func(o *options) error {
 	}
 }
 
+// WithReadiness delays the ready state of the daemon until the readiness function returns.
+// Requests are already served while it runs, so that clients can wait for the daemon to be ready.
+func WithReadiness(f func(context.Context)) func(o *options) error {
+	return func(o *options) error {
+		o.readiness = f
+		return nil
+	}
+}
+
 // New returns an new, initialized daemon server, which handles systemd activation.
 // If systemd activation is used, it will override any socket passed here.
 func New(registerGRPCServer GRPCServerRegisterer, socket string, opts ...option) (d *Daemon, err error) {
@@ -75,6 +88,7 @@ func New(registerGRPCServer GRPCServerRegisterer, socket string, opts ...option)
 	// defaults
 	args := options{
 		serverQuit:                func(context.Context) {},
+		readiness:                 func(context.Context) {},
 		systemdActivationListener: activation.Listeners,
 		systemdSdNotifier:         daemon.SdNotify,
 	}
@@ -93,6 +107,9 @@ func New(registerGRPCServer GRPCServerRegisterer, socket string, opts ...option)
 
 		lis:               make(chan net.Listener, 1),
 		systemdSdNotifier: args.systemdSdNotifier,
+
+		readiness: args.readiness,
+		ready:     make(chan struct{}),
 	}
 
 	// systemd socket activation or local creation
@@ -151,17 +168,21 @@ func (d *Daemon) UseSocket(socket string) (err error) {
 }
 
 // Listen serves on its unix socket path.
-// It handles systemd activation notification.
+// It handles systemd activation notification, sent once the daemon is ready.
 // When the server stop listening, the socket is removed automatically.
 // Configuration can be reloaded and we will then listen on the new socket.
 func (d *Daemon) Listen() (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't serve"))
 
-	if sent, err := d.systemdSdNotifier(false, "READY=1"); err != nil {
-		return errors.New(gotext.Get("couldn't send ready notification to systemd: %v", err))
-	} else if sent {
-		log.Debug(context.Background(), gotext.Get("Ready state sent to systemd"))
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	notifyErr := make(chan error, 1)
+	go func() {
+		if err := d.setReady(ctx); err != nil {
+			notifyErr <- err
+			d.Quit(true)
+		}
+	}()
 
 	lis := <-d.lis
 	d.socketMu.Lock()
@@ -191,9 +212,49 @@ func (d *Daemon) Listen() (err error) {
 	log.Debug(context.Background(), gotext.Get("Quitting"))
 	d.serverQuit(context.Background())
 
+	select {
+	case err := <-notifyErr:
+		return err
+	default:
+	}
 	return nil
 }
 
+// setReady runs the readiness function, then marks the daemon as ready and notifies systemd.
+func (d *Daemon) setReady(ctx context.Context) error {
+	d.readiness(ctx)
+	close(d.ready)
+
+	sent, err := d.systemdSdNotifier(false, "READY=1")
+	if err != nil {
+		return errors.New(gotext.Get("couldn't send ready notification to systemd: %v", err))
+	}
+	if sent {
+		log.Debug(context.Background(), gotext.Get("Ready state sent to systemd"))
+	}
+	return nil
+}
+
+// Ready returns if the daemon is ready, once its readiness function returned.
+func (d *Daemon) Ready() bool {
+	select {
+	case <-d.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitReady blocks until the daemon is ready or ctx is done.
+func (d *Daemon) WaitReady(ctx context.Context) error {
+	select {
+	case <-d.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Quit gracefully quits listening loop and stops the grpc server.
 // It can drops any existing connexion is force is true.
 func (d *Daemon) Quit(force bool) {
@@ -261,6 +261,49 @@ func TestSdNotifier(t *testing.T) {
 	}
 }
 
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	grpcRegister := &grpcServiceRegister{}
+
+	release := make(chan struct{})
+	notified := make(chan string, 1)
+	d, err := daemon.New(grpcRegister.registerGRPCServer, filepath.Join(dir, "test.sock"),
+		daemon.WithReadiness(func(context.Context) { <-release }),
+		daemon.WithSystemdSdNotifier(func(_ bool, state string) (bool, error) {
+			notified <- state
+			return true, nil
+		}))
+	require.NoError(t, err, "New should return the daemon handler")
+
+	listenErr := make(chan error)
+	go func() { listenErr <- d.Listen() }()
+	defer func() {
+		d.Quit(false)
+		require.NoError(t, <-listenErr, "Listen should return no error when stopped normally")
+	}()
+
+	// Not ready while the readiness function runs.
+	time.Sleep(10 * time.Millisecond)
+	require.False(t, d.Ready(), "Daemon should not be ready before the readiness function returns")
+	require.Empty(t, notified, "Ready state should not be sent to systemd before the readiness function returns")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, d.WaitReady(ctx), "WaitReady should return an error when the context is done before being ready")
+
+	// Ready once the readiness function returned.
+	close(release)
+	require.NoError(t, d.WaitReady(context.Background()), "WaitReady should return once the daemon is ready")
+	require.True(t, d.Ready(), "Daemon should be ready once the readiness function returned")
+	select {
+	case state := <-notified:
+		require.Equal(t, "READY=1", state, "Ready state should be sent to systemd")
+	case <-time.After(time.Second):
+		t.Fatal("Ready state should be sent to systemd once the daemon is ready")
+	}
+}
+
 func TestFailingOption(t *testing.T) {
 	t.Parallel()
 
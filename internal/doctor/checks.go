@@ -0,0 +1,215 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+)
+
+const (
+	// DefaultKeytab is the path of the machine keytab.
+	DefaultKeytab = "/etc/krb5.keytab"
+
+	// skewWarn and skewFail are the clock skews with the domain controller from which the check warns or fails.
+	// Kerberos rejects tickets with a skew of more than 5 minutes by default.
+	skewWarn = time.Minute
+	skewFail = 5 * time.Minute
+)
+
+// Backend is the AD backend the checks query.
+type Backend interface {
+	Domain() string
+	ServerFQDN(ctx context.Context) (string, error)
+	IsOnline() (bool, error)
+}
+
+// CheckBackend checks that the AD backend is online and has found a domain controller.
+func CheckBackend(b Backend) Check {
+	return Check{
+		Name: gotext.Get("AD backend"),
+		Run: func(ctx context.Context) Result {
+			online, err := b.IsOnline()
+			if err != nil {
+				return Result{
+					Status:  Fail,
+					Message: gotext.Get("can't get backend status: %v", err),
+					Hint:    gotext.Get("Check that sssd or winbind is running and that the machine is joined to the domain."),
+				}
+			}
+			if !online {
+				return Result{
+					Status:  Fail,
+					Message: gotext.Get("backend is offline for domain %q", b.Domain()),
+					Hint:    gotext.Get("Check the network connection to the domain controller, then restart the backend service."),
+				}
+			}
+			server, err := b.ServerFQDN(ctx)
+			if err != nil {
+				return Result{
+					Status:  Fail,
+					Message: gotext.Get("no domain controller found for domain %q: %v", b.Domain(), err),
+					Hint:    gotext.Get("Check the DNS configuration or set ad_server in the adsys configuration."),
+				}
+			}
+			return Result{Status: Pass, Message: gotext.Get("online, using %s for domain %q", server, b.Domain())}
+		},
+	}
+}
+
+// CheckClockSkew checks that the local clock, as returned by now, is close to the clock of the domain controller.
+// dcTime returns the time of the given server, like QueryNTP does.
+func CheckClockSkew(b Backend, dcTime func(ctx context.Context, server string) (time.Time, error), now func() time.Time) Check {
+	return Check{
+		Name: gotext.Get("Clock skew"),
+		Run: func(ctx context.Context) Result {
+			server, err := b.ServerFQDN(ctx)
+			if err != nil {
+				return Result{
+					Status:  Warn,
+					Message: gotext.Get("can't compare clocks without a domain controller: %v", err),
+					Hint:    gotext.Get("Fix the AD backend check first."),
+				}
+			}
+			t, err := dcTime(ctx, server)
+			if err != nil {
+				return Result{
+					Status:  Warn,
+					Message: gotext.Get("can't get time of %s: %v", server, err),
+					Hint:    gotext.Get("Check that the domain controller serves NTP and that UDP port 123 isn’t filtered."),
+				}
+			}
+			skew := now().Sub(t).Round(time.Second)
+			if skew < 0 {
+				skew = -skew
+			}
+
+			hint := gotext.Get("Synchronize the machine clock with the domain controller, for instance with systemd-timesyncd.")
+			switch {
+			case skew >= skewFail:
+				return Result{Status: Fail, Message: gotext.Get("clock differs from %s by %s, Kerberos authentication will fail", server, skew), Hint: hint}
+			case skew >= skewWarn:
+				return Result{Status: Warn, Message: gotext.Get("clock differs from %s by %s", server, skew), Hint: hint}
+			}
+			return Result{Status: Pass, Message: gotext.Get("clock differs from %s by %s", server, skew)}
+		},
+	}
+}
+
+// CheckKeytab checks that the machine keytab at path exists and is only readable by its owner.
+func CheckKeytab(path string) Check {
+	return Check{
+		Name: gotext.Get("Machine keytab"),
+		Run: func(ctx context.Context) Result {
+			info, err := os.Stat(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				return Result{
+					Status:  Fail,
+					Message: gotext.Get("%s doesn’t exist", path),
+					Hint:    gotext.Get("Join the machine to the domain, for instance with realm join."),
+				}
+			} else if err != nil {
+				return Result{Status: Fail, Message: gotext.Get("can't access %s: %v", path, err)}
+			}
+			if info.IsDir() {
+				return Result{
+					Status:  Fail,
+					Message: gotext.Get("%s is a directory", path),
+					Hint:    gotext.Get("Remove it and join the machine to the domain again."),
+				}
+			}
+			if info.Mode().Perm()&0077 != 0 {
+				return Result{
+					Status:  Warn,
+					Message: gotext.Get("%s is accessible by other users (%s)", path, info.Mode().Perm()),
+					Hint:    gotext.Get("Restrict its permissions with: chmod 600 %s", path),
+				}
+			}
+			return Result{Status: Pass, Message: gotext.Get("%s is present", path)}
+		},
+	}
+}
+
+// CheckSysvol checks that the SMB service of the domain controller, serving SYSVOL, can be reached with dial.
+func CheckSysvol(b Backend, dial func(ctx context.Context, network, address string) (net.Conn, error)) Check {
+	return Check{
+		Name: gotext.Get("SYSVOL"),
+		Run: func(ctx context.Context) Result {
+			server, err := b.ServerFQDN(ctx)
+			if err != nil {
+				return Result{
+					Status:  Warn,
+					Message: gotext.Get("can't reach SYSVOL without a domain controller: %v", err),
+					Hint:    gotext.Get("Fix the AD backend check first."),
+				}
+			}
+			address := net.JoinHostPort(server, "445")
+			conn, err := dial(ctx, "tcp", address)
+			if err != nil {
+				return Result{
+					Status:  Fail,
+					Message: gotext.Get("can't connect to %s: %v", address, err),
+					Hint:    gotext.Get("Check that TCP port 445 of the domain controller isn’t filtered."),
+				}
+			}
+			_ = conn.Close()
+			return Result{Status: Pass, Message: gotext.Get("smb://%s/SYSVOL is reachable", server)}
+		},
+	}
+}
+
+// CheckCacheWritable checks that files can be created in the cache directory dir.
+func CheckCacheWritable(dir string) Check {
+	return Check{
+		Name: gotext.Get("Cache directory"),
+		Run: func(ctx context.Context) Result {
+			hint := gotext.Get("Check that %s is a directory writable by root and that its filesystem isn’t full or read-only.", dir)
+			f, err := os.CreateTemp(dir, ".adsys-doctor-")
+			if err != nil {
+				return Result{Status: Fail, Message: gotext.Get("%s isn’t writable: %v", dir, err), Hint: hint}
+			}
+			_ = f.Close()
+			if err := os.Remove(f.Name()); err != nil {
+				return Result{Status: Warn, Message: gotext.Get("can't remove %s: %v", filepath.Base(f.Name()), err), Hint: hint}
+			}
+			return Result{Status: Pass, Message: gotext.Get("%s is writable", dir)}
+		},
+	}
+}
+
+// CheckDconf checks that the dconf command, needed by the dconf policy manager, is found by lookPath.
+func CheckDconf(lookPath func(file string) (string, error)) Check {
+	return Check{
+		Name: gotext.Get("dconf"),
+		Run: func(ctx context.Context) Result {
+			path, err := lookPath("dconf")
+			if err != nil {
+				return Result{
+					Status:  Fail,
+					Message: gotext.Get("dconf isn’t installed: %v", err),
+					Hint:    gotext.Get("Install it with: apt install dconf-cli"),
+				}
+			}
+			return Result{Status: Pass, Message: gotext.Get("%s is installed", path)}
+		},
+	}
+}
+
+// DefaultChecks returns all checks of the machine, using backend b and the cache directory cacheDir.
+func DefaultChecks(b Backend, cacheDir string) []Check {
+	var d net.Dialer
+	return []Check{
+		CheckBackend(b),
+		CheckClockSkew(b, QueryNTP, time.Now),
+		CheckKeytab(DefaultKeytab),
+		CheckSysvol(b, d.DialContext),
+		CheckCacheWritable(cacheDir),
+		CheckDconf(exec.LookPath),
+	}
+}
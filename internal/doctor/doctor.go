@@ -0,0 +1,107 @@
+// Package doctor diagnoses the configuration of the machine adsys relies on, like the AD backend, Kerberos, time
+// synchronization with the domain controller or SYSVOL access.
+//
+// Each check is independent: a failing or even panicking check doesn’t prevent the others from running. The
+// report lists every check with its status and, when it didn’t pass, a hint to fix the issue.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	// Pass means the check succeeded.
+	Pass Status = iota
+	// Warn means adsys works but may misbehave.
+	Warn
+	// Fail means adsys can’t work properly.
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Warn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// Result is the outcome of a check, with what was found and how to fix it if it didn’t pass.
+type Result struct {
+	Status  Status
+	Message string
+	Hint    string
+}
+
+// Check diagnoses one aspect of the configuration.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) Result
+}
+
+// CheckResult is the result of a named check.
+type CheckResult struct {
+	Name string
+	Result
+}
+
+// Report is the result of all checks, in the order they ran.
+type Report []CheckResult
+
+// Run runs all checks and returns their results. A check which panics fails.
+func Run(ctx context.Context, checks ...Check) Report {
+	report := make(Report, 0, len(checks))
+	for _, c := range checks {
+		report = append(report, CheckResult{Name: c.Name, Result: run(ctx, c)})
+	}
+	return report
+}
+
+// run runs c, recovering from any panic as a failure.
+func run(ctx context.Context, c Check) (r Result) {
+	defer func() {
+		if err := recover(); err != nil {
+			r = Result{
+				Status:  Fail,
+				Message: gotext.Get("check crashed: %v", err),
+				Hint:    gotext.Get("This is a bug in adsys, please report it."),
+			}
+		}
+	}()
+	return c.Run(ctx)
+}
+
+// Failed returns true if any check failed.
+func (r Report) Failed() bool {
+	for _, c := range r {
+		if c.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the report in a human readable form, with a summary of the statuses.
+func (r Report) String() string {
+	var out strings.Builder
+	counts := make(map[Status]int)
+	for _, c := range r {
+		counts[c.Status]++
+		fmt.Fprintf(&out, "[%s] %s: %s\n", c.Status, c.Name, c.Message)
+		if c.Status != Pass && c.Hint != "" {
+			fmt.Fprintf(&out, "       %s\n", strings.ReplaceAll(c.Hint, "\n", "\n       "))
+		}
+	}
+	fmt.Fprintln(&out, gotext.Get("%d passed, %d warnings, %d failed", counts[Pass], counts[Warn], counts[Fail]))
+	return out.String()
+}
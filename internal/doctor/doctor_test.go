@@ -0,0 +1,267 @@
+package doctor_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/doctor"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+var errMock = errors.New("mock error")
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	dcNow := time.Date(2026, time.January, 2, 3, 4, 5, 0, time.UTC)
+	// Git doesn’t keep the permissions of the fixture.
+	require.NoError(t, os.Chmod("testdata/krb5.keytab", 0600), "Setup: can't set keytab permissions")
+
+	tests := map[string]struct {
+		backend   mockBackend
+		skew      time.Duration
+		dcTimeErr bool
+		noKeytab  bool
+		dialErr   bool
+		noDconf   bool
+
+		wantFailed bool
+	}{
+		"All checks pass": {backend: mockBackend{online: true}},
+
+		"Mixed results are all reported":                {backend: mockBackend{online: true}, skew: 2 * time.Minute, noKeytab: true, noDconf: true, wantFailed: true},
+		"Offline backend skips checks needing a server": {backend: mockBackend{online: true, serverErr: true}, wantFailed: true},
+		"Clock skew warns":                              {backend: mockBackend{online: true}, skew: -90 * time.Second},
+		"Clock skew fails":                              {backend: mockBackend{online: true}, skew: 10 * time.Minute, wantFailed: true},
+		"Unreachable time server warns":                 {backend: mockBackend{online: true}, dcTimeErr: true},
+		"Unreachable SYSVOL fails":                      {backend: mockBackend{online: true}, dialErr: true, wantFailed: true},
+		"Everything fails":                              {backend: mockBackend{onlineErr: true, serverErr: true}, noKeytab: true, noDconf: true, wantFailed: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			keytab := "testdata/krb5.keytab"
+			if tc.noKeytab {
+				keytab = "testdata/nonexistent.keytab"
+			}
+
+			dcTime := func(context.Context, string) (time.Time, error) {
+				if tc.dcTimeErr {
+					return time.Time{}, errMock
+				}
+				return dcNow, nil
+			}
+			dial := func(context.Context, string, string) (net.Conn, error) {
+				if tc.dialErr {
+					return nil, errMock
+				}
+				c, s := net.Pipe()
+				_ = s.Close()
+				return c, nil
+			}
+			lookPath := func(file string) (string, error) {
+				if tc.noDconf {
+					return "", errMock
+				}
+				return "/usr/bin/" + file, nil
+			}
+
+			report := doctor.Run(context.Background(),
+				doctor.CheckBackend(tc.backend),
+				doctor.CheckClockSkew(tc.backend, dcTime, func() time.Time { return dcNow.Add(tc.skew) }),
+				doctor.CheckKeytab(keytab),
+				doctor.CheckSysvol(tc.backend, dial),
+				doctor.CheckDconf(lookPath),
+			)
+
+			require.Equal(t, tc.wantFailed, report.Failed(), "Failed returns if any check failed")
+			got := report.String()
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Report doesn't match")
+		})
+	}
+}
+
+func TestRunIsolatesChecks(t *testing.T) {
+	t.Parallel()
+
+	var ran bool
+	report := doctor.Run(context.Background(),
+		doctor.Check{Name: "panicking", Run: func(context.Context) doctor.Result { panic("boom") }},
+		doctor.Check{Name: "next", Run: func(context.Context) doctor.Result {
+			ran = true
+			return doctor.Result{Status: doctor.Pass, Message: "ok"}
+		}},
+	)
+
+	require.True(t, ran, "Checks after a panicking one should run")
+	require.Len(t, report, 2, "All checks should be reported")
+	require.Equal(t, doctor.Fail, report[0].Status, "A panicking check should fail")
+	require.Equal(t, doctor.Pass, report[1].Status, "Checks after a panicking one should report their status")
+	require.True(t, report.Failed(), "Report should be failed")
+}
+
+func TestCheckKeytab(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		perm   os.FileMode
+		isDir  bool
+		noFile bool
+
+		want doctor.Status
+	}{
+		"Keytab readable by its owner only": {perm: 0600, want: doctor.Pass},
+
+		"Keytab readable by others warns": {perm: 0644, want: doctor.Warn},
+		"Missing keytab fails":            {noFile: true, want: doctor.Fail},
+		"Keytab is a directory fails":     {isDir: true, want: doctor.Fail},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := filepath.Join(t.TempDir(), "krb5.keytab")
+			switch {
+			case tc.isDir:
+				require.NoError(t, os.Mkdir(p, 0700), "Setup: can't create directory")
+			case !tc.noFile:
+				require.NoError(t, os.WriteFile(p, []byte("keytab"), tc.perm), "Setup: can't create keytab")
+				require.NoError(t, os.Chmod(p, tc.perm), "Setup: can't set keytab permissions")
+			}
+
+			r := doctor.CheckKeytab(p).Run(context.Background())
+			require.Equal(t, tc.want, r.Status, "Unexpected status: %s", r.Message)
+		})
+	}
+}
+
+func TestCheckCacheWritable(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		isFile bool
+		noDir  bool
+
+		want doctor.Status
+	}{
+		"Writable cache directory": {want: doctor.Pass},
+
+		"Cache path is a file fails":    {isFile: true, want: doctor.Fail},
+		"Missing cache directory fails": {noDir: true, want: doctor.Fail},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			if tc.noDir {
+				dir = filepath.Join(dir, "nonexistent")
+			}
+			if tc.isFile {
+				dir = filepath.Join(dir, "file")
+				require.NoError(t, os.WriteFile(dir, nil, 0600), "Setup: can't create file")
+			}
+
+			r := doctor.CheckCacheWritable(dir).Run(context.Background())
+			require.Equal(t, tc.want, r.Status, "Unexpected status: %s", r.Message)
+
+			if tc.want != doctor.Pass {
+				return
+			}
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err, "Teardown: can't read cache directory")
+			require.Empty(t, entries, "Check should not leave any file in the cache directory")
+		})
+	}
+}
+
+func TestQueryNTP(t *testing.T) {
+	t.Parallel()
+
+	serverTime := time.Date(2026, time.January, 2, 3, 4, 5, 500_000_000, time.UTC)
+
+	tests := map[string]struct {
+		response func(req []byte) []byte
+
+		wantErr bool
+	}{
+		"Server time is returned": {response: ntpResponse(serverTime, 4, 2)},
+
+		"Error on short response":         {response: func([]byte) []byte { return []byte{0x1C} }, wantErr: true},
+		"Error on non server mode":        {response: ntpResponse(serverTime, 3, 2), wantErr: true},
+		"Error on kiss of death response": {response: ntpResponse(serverTime, 4, 0), wantErr: true},
+		"Error on no response":            {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			require.NoError(t, err, "Setup: can't listen for NTP requests")
+			t.Cleanup(func() { _ = conn.Close() })
+			go func() {
+				buf := make([]byte, 48)
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil || tc.response == nil {
+					return
+				}
+				_, _ = conn.WriteTo(tc.response(buf[:n]), addr)
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			got, err := doctor.QueryNTPAddress(ctx, conn.LocalAddr().String())
+			if tc.wantErr {
+				require.Error(t, err, "QueryNTP should have failed")
+				return
+			}
+			require.NoError(t, err, "QueryNTP should not have failed")
+			require.WithinDuration(t, serverTime, got, time.Millisecond, "QueryNTP should return the server time")
+		})
+	}
+}
+
+// ntpResponse returns a function answering an NTP request with t, mode and stratum.
+func ntpResponse(t time.Time, mode, stratum byte) func([]byte) []byte {
+	return func(req []byte) []byte {
+		resp := make([]byte, 48)
+		resp[0] = req[0]&0x38 | mode
+		resp[1] = stratum
+		binary.BigEndian.PutUint32(resp[40:44], uint32(t.Unix()+2208988800))
+		binary.BigEndian.PutUint32(resp[44:48], uint32((int64(t.Nanosecond())<<32)/int64(time.Second)))
+		return resp
+	}
+}
+
+type mockBackend struct {
+	online    bool
+	onlineErr bool
+	serverErr bool
+}
+
+func (m mockBackend) Domain() string {
+	return "example.com"
+}
+
+func (m mockBackend) ServerFQDN(context.Context) (string, error) {
+	if m.serverErr {
+		return "", errMock
+	}
+	return "dc.example.com", nil
+}
+
+func (m mockBackend) IsOnline() (bool, error) {
+	if m.onlineErr {
+		return false, errMock
+	}
+	return m.online, nil
+}
@@ -0,0 +1,4 @@
+package doctor
+
+// QueryNTPAddress queries the time of the NTP server listening on address, a host:port.
+var QueryNTPAddress = queryNTP
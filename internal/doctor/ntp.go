@@ -0,0 +1,70 @@
+package doctor
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	ntpTimeout = 5 * time.Second
+	// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and the Unix epoch.
+	ntpEpochOffset = 2208988800
+)
+
+// QueryNTP returns the time of server, as served over SNTP. The round trip time is ignored: the result is only
+// precise enough to detect a clock skew of a few seconds.
+func QueryNTP(ctx context.Context, server string) (time.Time, error) {
+	return queryNTP(ctx, net.JoinHostPort(server, "123"))
+}
+
+func queryNTP(ctx context.Context, address string) (t time.Time, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't query time over NTP from %s", address))
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", address)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(ntpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return time.Time{}, err
+	}
+
+	// Client request: no leap indicator, version 3, client mode.
+	req := make([]byte, 48)
+	req[0] = 0x1B
+	if _, err := conn.Write(req); err != nil {
+		return time.Time{}, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n < 48 {
+		return time.Time{}, errors.New(gotext.Get("response too short: %d bytes", n))
+	}
+	if mode := resp[0] & 0x07; mode != 4 {
+		return time.Time{}, errors.New(gotext.Get("unexpected response mode %d", mode))
+	}
+	if stratum := resp[1]; stratum == 0 {
+		return time.Time{}, errors.New(gotext.Get("server refused the request"))
+	}
+
+	// Transmit timestamp: seconds and fraction of seconds since the NTP epoch.
+	secs := int64(binary.BigEndian.Uint32(resp[40:44])) - ntpEpochOffset
+	frac := int64(binary.BigEndian.Uint32(resp[44:48]))
+	return time.Unix(secs, (frac*int64(time.Second))>>32), nil
+}
@@ -0,0 +1,192 @@
+// Package fileutils writes the files generated by the policy managers only when they change, so that applying the
+// same policies again doesn't trigger their side effects (dconf update, daemon reloads…).
+//
+// The generated content is compared with what is installed, and not with what was previously generated: any
+// out-of-band modification of an installed file is corrected on next apply. A file mode modified out-of-band is
+// restored without being reported as a change, as it doesn't require the side effects.
+package fileutils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/decorate"
+)
+
+// ManagedHeader is the comment heading the configuration files and systemd units generated by the policy managers.
+const ManagedHeader = `# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+`
+
+// WriteIfChanged atomically writes content to path with perm, unless path already has this content.
+// It returns true if path was written. The mode of an unchanged file is still restored to perm. The parent directory
+// of path must exist.
+func WriteIfChanged(path string, content []byte, perm fs.FileMode) (changed bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save %s", path))
+
+	installed, mode, err := fileHash(path)
+	if err == nil && installed == sha256.Sum256(content) {
+		return false, restoreMode(path, mode, perm)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	if err := os.WriteFile(path+".new", content, perm); err != nil {
+		return false, err
+	}
+	// Enforce the mode if the temporary file already existed, or was restricted by the umask.
+	if err := os.Chmod(path+".new", perm); err != nil {
+		return false, err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReplaceIfChanged renames newPath to path, unless path already has the same content as newPath: newPath is then
+// removed, after restoring the mode of path to the newPath one. It returns true if path was replaced.
+func ReplaceIfChanged(newPath, path string) (changed bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't replace %s", path))
+
+	same, err := Same(newPath, path)
+	if err != nil {
+		return false, err
+	}
+	if !same {
+		if err := os.Rename(newPath, path); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	info, err := os.Stat(newPath)
+	if err != nil {
+		return false, err
+	}
+	installed, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if err := restoreMode(path, installed.Mode().Perm(), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return false, os.Remove(newPath)
+}
+
+// Same returns true if path exists with the same content as newPath.
+func Same(newPath, path string) (same bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't compare %s to %s", newPath, path))
+
+	installed, _, err := fileHash(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	generated, _, err := fileHash(newPath)
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(installed[:], generated[:]), nil
+}
+
+// SameTree returns true if both dir and newDir exist with the same directories and files, files having the same
+// content. Trees containing anything else than directories and regular files are never the same.
+func SameTree(newDir, dir string) (same bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't compare %s to %s", newDir, dir))
+
+	installed, err := treeEntries(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	generated, err := treeEntries(newDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if len(installed) != len(generated) {
+		return false, nil
+	}
+
+	for rel, mode := range generated {
+		installedMode, ok := installed[rel]
+		if !ok || installedMode.Type() != mode.Type() {
+			return false, nil
+		}
+		if mode.IsDir() {
+			continue
+		}
+		if !mode.IsRegular() {
+			return false, nil
+		}
+		same, err := Same(filepath.Join(newDir, rel), filepath.Join(dir, rel))
+		if err != nil || !same {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// treeEntries returns the type of each entry under dir, by path relative to it.
+func treeEntries(dir string) (entries map[string]fs.FileMode, err error) {
+	entries = make(map[string]fs.FileMode)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		entries[rel] = d.Type()
+		return nil
+	})
+	return entries, err
+}
+
+// restoreMode changes the permission bits of path from mode to perm, if they differ.
+func restoreMode(path string, mode, perm fs.FileMode) error {
+	if mode == perm {
+		return nil
+	}
+	return os.Chmod(path, perm)
+}
+
+// fileHash returns the sha256 hash of the content of path, with its permission bits.
+func fileHash(path string) (hash [sha256.Size]byte, perm fs.FileMode, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return hash, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return hash, 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return hash, 0, errors.New(gotext.Get("%s is not a regular file", path))
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return hash, 0, err
+	}
+	copy(hash[:], h.Sum(nil))
+	return hash, info.Mode().Perm(), nil
+}
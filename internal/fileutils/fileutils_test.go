@@ -0,0 +1,216 @@
+package fileutils_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/fileutils"
+)
+
+func TestWriteIfChanged(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		installed     string
+		installedMode os.FileMode
+		noInstalled   bool
+		installedDir  bool
+
+		wantChanged bool
+		wantErr     bool
+	}{
+		"Write missing file":                      {noInstalled: true, wantChanged: true},
+		"Do not write file with the same content": {installed: "content\n"},
+
+		// Drift correction
+		"Write file modified out-of-band":  {installed: "modified\n", wantChanged: true},
+		"Restore mode of unchanged file":   {installed: "content\n", installedMode: 0600},
+		"Write file truncated out-of-band": {installed: "", wantChanged: true},
+		"Write file with appended content": {installed: "content\nappended\n", wantChanged: true},
+
+		// Error cases
+		"Error if the installed file is a directory": {installedDir: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := filepath.Join(t.TempDir(), "file")
+			switch {
+			case tc.installedDir:
+				require.NoError(t, os.Mkdir(p, 0750), "Setup: can't create directory")
+			case !tc.noInstalled:
+				if tc.installedMode == 0 {
+					tc.installedMode = 0644
+				}
+				require.NoError(t, os.WriteFile(p, []byte(tc.installed), tc.installedMode), "Setup: can't write installed file")
+				require.NoError(t, os.Chmod(p, tc.installedMode), "Setup: can't change installed file mode")
+			}
+			var before os.FileInfo
+			if !tc.noInstalled {
+				var err error
+				before, err = os.Stat(p)
+				require.NoError(t, err, "Setup: can't stat installed file")
+			}
+
+			changed, err := fileutils.WriteIfChanged(p, []byte("content\n"), 0644)
+			if tc.wantErr {
+				require.Error(t, err, "WriteIfChanged should fail")
+				return
+			}
+			require.NoError(t, err, "WriteIfChanged should not fail")
+			require.Equal(t, tc.wantChanged, changed, "WriteIfChanged should report whether the file changed")
+
+			got, err := os.ReadFile(p)
+			require.NoError(t, err, "File should exist")
+			require.Equal(t, "content\n", string(got), "File should have the generated content")
+			info, err := os.Stat(p)
+			require.NoError(t, err, "File should exist")
+			require.Equal(t, os.FileMode(0644), info.Mode().Perm(), "File should have the generated mode")
+			require.NoFileExists(t, p+".new", "Temporary file should not be left")
+
+			if !tc.wantChanged {
+				require.True(t, os.SameFile(before, info), "Unchanged file should not be replaced")
+			}
+		})
+	}
+}
+
+func TestReplaceIfChanged(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		installed     string
+		installedMode os.FileMode
+		noInstalled   bool
+		noNew         bool
+
+		wantChanged bool
+		wantErr     bool
+	}{
+		"Replace missing file":                      {noInstalled: true, wantChanged: true},
+		"Do not replace file with the same content": {installed: "content\n"},
+
+		// Drift correction
+		"Replace file modified out-of-band": {installed: "modified\n", wantChanged: true},
+		"Restore mode of unchanged file":    {installed: "content\n", installedMode: 0600},
+
+		// Error cases
+		"Error if the new file does not exist": {installed: "content\n", noNew: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			p := filepath.Join(dir, "file")
+			newPath := filepath.Join(dir, "file.new")
+			if !tc.noInstalled {
+				if tc.installedMode == 0 {
+					tc.installedMode = 0644
+				}
+				require.NoError(t, os.WriteFile(p, []byte(tc.installed), tc.installedMode), "Setup: can't write installed file")
+				require.NoError(t, os.Chmod(p, tc.installedMode), "Setup: can't change installed file mode")
+			}
+			if !tc.noNew {
+				require.NoError(t, os.WriteFile(newPath, []byte("content\n"), 0644), "Setup: can't write new file")
+				require.NoError(t, os.Chmod(newPath, 0644), "Setup: can't change new file mode")
+			}
+			var before os.FileInfo
+			if !tc.noInstalled {
+				var err error
+				before, err = os.Stat(p)
+				require.NoError(t, err, "Setup: can't stat installed file")
+			}
+
+			changed, err := fileutils.ReplaceIfChanged(newPath, p)
+			if tc.wantErr {
+				require.Error(t, err, "ReplaceIfChanged should fail")
+				return
+			}
+			require.NoError(t, err, "ReplaceIfChanged should not fail")
+			require.Equal(t, tc.wantChanged, changed, "ReplaceIfChanged should report whether the file changed")
+
+			got, err := os.ReadFile(p)
+			require.NoError(t, err, "File should exist")
+			require.Equal(t, "content\n", string(got), "File should have the new content")
+			info, err := os.Stat(p)
+			require.NoError(t, err, "File should exist")
+			require.Equal(t, os.FileMode(0644), info.Mode().Perm(), "File should have the new file mode")
+			require.NoFileExists(t, newPath, "New file should be consumed")
+
+			if !tc.wantChanged {
+				require.True(t, os.SameFile(before, info), "Unchanged file should not be replaced")
+			}
+		})
+	}
+}
+
+func TestSameTree(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		installed   map[string]string
+		noInstalled bool
+		noGenerated bool
+		modes       map[string]os.FileMode
+		symlink     bool
+
+		wantSame bool
+	}{
+		"Same tree": {wantSame: true},
+
+		"Tree with a modified file":   {installed: map[string]string{"usr.bin.foo": "modified", "nested/usr.bin.bar": "bar"}},
+		"Tree with a missing file":    {installed: map[string]string{"usr.bin.foo": "foo"}},
+		"Tree with an added file":     {installed: map[string]string{"usr.bin.foo": "foo", "nested/usr.bin.bar": "bar", "usr.bin.baz": "baz"}},
+		"Tree with a moved file":      {installed: map[string]string{"usr.bin.foo": "foo", "usr.bin.bar": "bar"}},
+		"Tree with a changed mode":    {modes: map[string]os.FileMode{"usr.bin.foo": 0600}, wantSame: true},
+		"Tree with a symlink":         {symlink: true},
+		"Tree which is not installed": {noInstalled: true},
+		"Tree which is not generated": {noGenerated: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			generated := map[string]string{"usr.bin.foo": "foo", "nested/usr.bin.bar": "bar"}
+			if tc.installed == nil {
+				tc.installed = generated
+			}
+
+			newDir := filepath.Join(t.TempDir(), "machine.new")
+			dir := filepath.Join(t.TempDir(), "machine")
+			if !tc.noGenerated {
+				writeTree(t, newDir, generated)
+			}
+			if !tc.noInstalled {
+				writeTree(t, dir, tc.installed)
+			}
+			for f, mode := range tc.modes {
+				require.NoError(t, os.Chmod(filepath.Join(dir, f), mode), "Setup: can't change mode")
+			}
+			if tc.symlink {
+				require.NoError(t, os.Symlink("usr.bin.foo", filepath.Join(newDir, "usr.bin.link")), "Setup: can't create symlink")
+				require.NoError(t, os.Symlink("usr.bin.foo", filepath.Join(dir, "usr.bin.link")), "Setup: can't create symlink")
+			}
+
+			same, err := fileutils.SameTree(newDir, dir)
+			require.NoError(t, err, "SameTree should not fail")
+			require.Equal(t, tc.wantSame, same, "SameTree should report whether the trees are the same")
+		})
+	}
+}
+
+// writeTree creates the files under dir, by path relative to it.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for f, content := range files {
+		p := filepath.Join(dir, f)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0750), "Setup: can't create parent directory")
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644), "Setup: can't write file")
+		require.NoError(t, os.Chmod(p, 0644), "Setup: can't change file mode")
+	}
+}
@@ -0,0 +1,226 @@
+// Package metrics records the policy applications of the daemon and exposes them over HTTP in the Prometheus text
+// format, for fleet observability.
+//
+// The following metrics are exposed:
+//   - adsys_policy_applies_total: counter of policy applications, by object type (machine or user) and result
+//     (success or failure).
+//   - adsys_policy_last_success_timestamp_seconds: gauge of the time of the last successful policy application, by
+//     object type.
+//   - adsys_policy_manager_duration_seconds: summary of the time each policy manager took to apply policies.
+//   - adsys_policy_manager_errors_total: counter of the policy applications which failed, by policy manager.
+//
+// Metrics are kept in memory and reset when the daemon restarts.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// Path is the HTTP path metrics are exposed on.
+const Path = "/metrics"
+
+const (
+	objectMachine = "machine"
+	objectUser    = "user"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Metrics records the policy applications and the policy managers runs.
+// A nil Metrics doesn’t record anything. Metrics is safe for concurrent use.
+type Metrics struct {
+	mu sync.Mutex
+
+	// applies is the number of policy applications by object type and result.
+	applies map[[2]string]uint64
+	// lastSuccess is the time of the last successful policy application by object type.
+	lastSuccess map[string]time.Time
+
+	managers        []string
+	managerDuration map[string]time.Duration
+	managerRuns     map[string]uint64
+	managerErrors   map[string]uint64
+}
+
+// New returns a Metrics recording the runs of managers. Their metrics are exposed even before they ran.
+func New(managers ...string) *Metrics {
+	m := &Metrics{
+		applies:         make(map[[2]string]uint64),
+		lastSuccess:     make(map[string]time.Time),
+		managers:        slices.Clone(managers),
+		managerDuration: make(map[string]time.Duration),
+		managerRuns:     make(map[string]uint64),
+		managerErrors:   make(map[string]uint64),
+	}
+	for _, o := range []string{objectMachine, objectUser} {
+		for _, r := range []string{resultSuccess, resultFailure} {
+			m.applies[[2]string{o, r}] = 0
+		}
+	}
+	return m
+}
+
+// RecordApply records a policy application of the machine or of a user, completed at t and which returned err.
+func (m *Metrics) RecordApply(isComputer bool, err error, t time.Time) {
+	if m == nil {
+		return
+	}
+
+	object := objectUser
+	if isComputer {
+		object = objectMachine
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.applies[[2]string{object, resultFailure}]++
+		return
+	}
+	m.applies[[2]string{object, resultSuccess}]++
+	m.lastSuccess[object] = t
+}
+
+// RecordManager records a run of manager, which took d and returned err.
+func (m *Metrics) RecordManager(manager string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !slices.Contains(m.managers, manager) {
+		m.managers = append(m.managers, manager)
+	}
+	m.managerDuration[manager] += d
+	m.managerRuns[manager]++
+	if err != nil {
+		m.managerErrors[manager]++
+	}
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (n int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+
+	writeHeader(&b, "adsys_policy_applies_total", "counter", "Number of policy applications, by object type and result.")
+	for _, o := range []string{objectMachine, objectUser} {
+		for _, r := range []string{resultSuccess, resultFailure} {
+			writeSample(&b, "adsys_policy_applies_total", labels("object", o, "result", r), float64(m.applies[[2]string{o, r}]))
+		}
+	}
+
+	writeHeader(&b, "adsys_policy_last_success_timestamp_seconds", "gauge", "Time of the last successful policy application, by object type, in seconds since the epoch.")
+	for _, o := range []string{objectMachine, objectUser} {
+		t, ok := m.lastSuccess[o]
+		if !ok {
+			continue
+		}
+		writeSample(&b, "adsys_policy_last_success_timestamp_seconds", labels("object", o), float64(t.UnixNano())/float64(time.Second))
+	}
+
+	writeHeader(&b, "adsys_policy_manager_duration_seconds", "summary", "Time policy managers took to apply policies.")
+	for _, manager := range m.managers {
+		l := labels("manager", manager)
+		writeSample(&b, "adsys_policy_manager_duration_seconds_sum", l, m.managerDuration[manager].Seconds())
+		writeSample(&b, "adsys_policy_manager_duration_seconds_count", l, float64(m.managerRuns[manager]))
+	}
+
+	writeHeader(&b, "adsys_policy_manager_errors_total", "counter", "Number of policy applications which failed, by policy manager.")
+	for _, manager := range m.managers {
+		writeSample(&b, "adsys_policy_manager_errors_total", labels("manager", manager), float64(m.managerErrors[manager]))
+	}
+
+	written, err := io.WriteString(w, b.String())
+	return int64(written), err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := m.WriteTo(w); err != nil {
+		log.Warningf(r.Context(), "Can't write metrics: %v", err)
+	}
+}
+
+func writeHeader(b *strings.Builder, name, metricType, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeSample(b *strings.Builder, name, labels string, v float64) {
+	fmt.Fprintf(b, "%s{%s} %s\n", name, labels, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+// labels formats the label names and values of kv, escaping the values.
+func labels(kv ...string) string {
+	var l []string
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(kv[i+1])
+		l = append(l, fmt.Sprintf(`%s="%s"`, kv[i], v))
+	}
+	return strings.Join(l, ",")
+}
+
+// Server exposes metrics over HTTP.
+type Server struct {
+	srv *http.Server
+	lis net.Listener
+}
+
+// Listen starts exposing m on addr, a host:port bind address, under Path.
+func Listen(ctx context.Context, addr string, m *Metrics) (s *Server, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't expose metrics on %q", addr))
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, m)
+	s = &Server{
+		srv: &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+		lis: lis,
+	}
+
+	log.Infof(ctx, "Exposing metrics on http://%s%s", lis.Addr(), Path)
+	go func() {
+		if err := s.srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warningf(context.Background(), "Metrics server stopped: %v", err)
+		}
+	}()
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.lis.Addr().String()
+}
+
+// Close stops exposing the metrics.
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
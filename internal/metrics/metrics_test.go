@@ -0,0 +1,139 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/metrics"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+// apply is a simulated policy application.
+type apply struct {
+	isComputer bool
+	// managers are the errors returned by each policy manager which ran.
+	managers map[string]error
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	errFailed := errors.New("manager failed")
+
+	tests := map[string]struct {
+		applies []apply
+	}{
+		"No policy applied exposes declared managers": {},
+
+		"Successful machine policy application": {applies: []apply{
+			{isComputer: true, managers: map[string]error{"dconf": nil, "privilege": nil}},
+		}},
+		"Successful user policy application": {applies: []apply{
+			{managers: map[string]error{"dconf": nil}},
+		}},
+		"Failed policy application counts manager errors": {applies: []apply{
+			{isComputer: true, managers: map[string]error{"dconf": nil, "privilege": errFailed}},
+		}},
+		"Multiple policy applications accumulate": {applies: []apply{
+			{isComputer: true, managers: map[string]error{"dconf": nil, "privilege": nil}},
+			{managers: map[string]error{"dconf": errFailed}},
+			{managers: map[string]error{"dconf": nil}},
+			{isComputer: true, managers: map[string]error{"dconf": nil, "privilege": errFailed}},
+		}},
+		"Failed policy application keeps last success timestamp": {applies: []apply{
+			{managers: map[string]error{"dconf": nil}},
+			{managers: map[string]error{"dconf": errFailed}},
+		}},
+		"Undeclared manager is exposed once it ran": {applies: []apply{
+			{isComputer: true, managers: map[string]error{"gdm": nil}},
+		}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := metrics.New("dconf", "privilege")
+			s, err := metrics.Listen(context.Background(), "127.0.0.1:0", m)
+			require.NoError(t, err, "Setup: Listen should not fail")
+			t.Cleanup(func() { _ = s.Close() })
+
+			now := time.Date(2026, time.January, 2, 3, 4, 5, 0, time.UTC)
+			for _, a := range tc.applies {
+				var applyErr error
+				for _, manager := range []string{"dconf", "privilege", "gdm"} {
+					err, ran := a.managers[manager]
+					if !ran {
+						continue
+					}
+					m.RecordManager(manager, 1500*time.Millisecond, err)
+					applyErr = errors.Join(applyErr, err)
+				}
+				m.RecordApply(a.isComputer, applyErr, now)
+				now = now.Add(time.Hour)
+			}
+
+			resp, err := http.Get("http://" + s.Addr() + metrics.Path)
+			require.NoError(t, err, "Scraping the metrics endpoint should not fail")
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode, "Scraping the metrics endpoint should succeed")
+			require.Contains(t, resp.Header.Get("Content-Type"), "text/plain; version=0.0.4", "Metrics should be in Prometheus text format")
+			got, err := io.ReadAll(resp.Body)
+			require.NoError(t, err, "Reading the metrics should not fail")
+
+			want := testutils.LoadWithUpdateFromGolden(t, string(got))
+			require.Equal(t, want, string(got), "Scraped metrics don't match")
+		})
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	t.Parallel()
+
+	s, err := metrics.Listen(context.Background(), "127.0.0.1:0", metrics.New())
+	require.NoError(t, err, "Setup: Listen should not fail")
+
+	resp, err := http.Post("http://"+s.Addr()+metrics.Path, "text/plain", nil)
+	require.NoError(t, err, "Posting to the metrics endpoint should not fail")
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, "Only GET is allowed on the metrics endpoint")
+
+	resp, err = http.Get("http://" + s.Addr() + "/other")
+	require.NoError(t, err, "Getting another path should not fail")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode, "Only the metrics path is served")
+
+	// The metrics are not exposed anymore once the server is closed.
+	require.NoError(t, s.Close(), "Close should not fail")
+	_, err = http.Get("http://" + s.Addr() + metrics.Path)
+	require.Error(t, err, "Scraping a closed metrics endpoint should fail")
+}
+
+func TestListenFails(t *testing.T) {
+	t.Parallel()
+
+	s, err := metrics.Listen(context.Background(), "127.0.0.1:0", metrics.New())
+	require.NoError(t, err, "Setup: Listen should not fail")
+	t.Cleanup(func() { _ = s.Close() })
+
+	_, err = metrics.Listen(context.Background(), s.Addr(), metrics.New())
+	require.Error(t, err, "Listen should fail on an address already in use")
+
+	_, err = metrics.Listen(context.Background(), "not an address", metrics.New())
+	require.Error(t, err, "Listen should fail on an invalid address")
+}
+
+func TestNilMetricsDoNotRecord(t *testing.T) {
+	t.Parallel()
+
+	var m *metrics.Metrics
+	require.NotPanics(t, func() {
+		m.RecordApply(true, nil, time.Now())
+		m.RecordManager("dconf", time.Second, nil)
+	}, "Recording with nil metrics should be a no-op")
+}
@@ -0,0 +1,36 @@
+// Package netaddr validates the host names and addresses set in the policies, before the policy managers write them
+// to the system configuration.
+package netaddr
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// hostnameLabelRe matches a label of a host name, as defined by RFC 1123.
+var hostnameLabelRe = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// ValidHostname returns true if name is a valid host name, as defined by RFC 1123. A final dot is allowed.
+// The top-level domain can't be all-numeric, so that a malformed IPv4 address isn't taken for a host name.
+func ValidHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if !hostnameLabelRe.MatchString(label) {
+			return false
+		}
+	}
+	return strings.Trim(labels[len(labels)-1], "0123456789") != ""
+}
+
+// ValidHost returns true if host is an IP address or a valid host name.
+func ValidHost(host string) bool {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return true
+	}
+	return ValidHostname(host)
+}
@@ -0,0 +1,49 @@
+package netaddr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/netaddr"
+)
+
+func TestValidHost(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		host string
+
+		wantHostname bool
+		wantHost     bool
+	}{
+		"Host name":                       {host: "server1", wantHostname: true, wantHost: true},
+		"Fully qualified host name":       {host: "server1.example.com", wantHostname: true, wantHost: true},
+		"Host name with final dot":        {host: "server1.example.com.", wantHostname: true, wantHost: true},
+		"Host name with hyphen and digit": {host: "ntp-1.example.com", wantHostname: true, wantHost: true},
+		"Label of 63 characters":          {host: strings.Repeat("a", 63) + ".example.com", wantHostname: true, wantHost: true},
+		"IPv4 address":                    {host: "10.0.0.1", wantHost: true},
+		"IPv6 address":                    {host: "fd00::1", wantHost: true},
+		"IPv6 address with zone":          {host: "fe80::1%eth0", wantHost: true},
+
+		// Invalid hosts
+		"Empty host":                       {host: ""},
+		"Only a final dot":                 {host: "."},
+		"Host name with invalid chars":     {host: "server_1.example.com"},
+		"Host name starting with a hyphen": {host: "-server1.example.com"},
+		"Host name ending with a hyphen":   {host: "server1-.example.com"},
+		"Host name with empty label":       {host: "server1..example.com"},
+		"Label of 64 characters":           {host: strings.Repeat("a", 64) + ".example.com"},
+		"Host name of 254 characters":      {host: strings.Repeat(strings.Repeat("a", 62)+".", 4) + "aa"},
+		"All-numeric top-level domain":     {host: "server1.123"},
+		"Malformed IPv4 address":           {host: "10.0.0.256"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantHostname, netaddr.ValidHostname(tc.host), "ValidHostname should return the expected validity")
+			require.Equal(t, tc.wantHost, netaddr.ValidHost(tc.host), "ValidHost should return the expected validity")
+		})
+	}
+}
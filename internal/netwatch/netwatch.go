@@ -0,0 +1,284 @@
+// Package netwatch watches the network connectivity of the machine, and notifies when it comes up.
+//
+// The connectivity is reported over dbus by NetworkManager and systemd-networkd, whichever are running: the network
+// is up as soon as one of them reports a global connectivity, and down when none of them does.
+//
+// Connectivity changes are debounced: the network has to stay up for the debounce duration before being notified,
+// so that a flapping connection only notifies once it settled.
+package netwatch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	propertiesChangedSignal = "org.freedesktop.DBus.Properties.PropertiesChanged"
+	nameOwnerChangedSignal  = "org.freedesktop.DBus.NameOwnerChanged"
+
+	// nmStateConnectedGlobal is the NetworkManager state when there is a global connectivity.
+	nmStateConnectedGlobal uint32 = 70
+	// networkdStateRoutable is the systemd-networkd operational state when there is a global connectivity.
+	networkdStateRoutable = "routable"
+)
+
+// source is a dbus service reporting the network connectivity through one of its properties.
+type source struct {
+	name     string
+	service  string
+	path     dbus.ObjectPath
+	iface    string
+	property string
+	// isUp returns true if the property value reports a global connectivity.
+	isUp func(v dbus.Variant) bool
+}
+
+var sources = []source{
+	{
+		name:     "NetworkManager",
+		service:  consts.NetworkManagerDbusRegisteredName,
+		path:     consts.NetworkManagerDbusObjectPath,
+		iface:    consts.NetworkManagerDbusInterface,
+		property: "State",
+		isUp: func(v dbus.Variant) bool {
+			state, ok := v.Value().(uint32)
+			return ok && state >= nmStateConnectedGlobal
+		},
+	},
+	{
+		name:     "systemd-networkd",
+		service:  consts.NetworkdDbusRegisteredName,
+		path:     consts.NetworkdDbusObjectPath,
+		iface:    consts.NetworkdDbusManagerInterface,
+		property: "OperationalState",
+		isUp: func(v dbus.Variant) bool {
+			state, ok := v.Value().(string)
+			return ok && state == networkdStateRoutable
+		},
+	},
+}
+
+// matches returns the dbus matches of the signals reporting connectivity changes of s.
+func (s source) matches() [][]dbus.MatchOption {
+	return [][]dbus.MatchOption{
+		{
+			dbus.WithMatchSender(s.service),
+			dbus.WithMatchObjectPath(s.path),
+			dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+			dbus.WithMatchMember("PropertiesChanged"),
+			dbus.WithMatchArg(0, s.iface),
+		},
+		// The connectivity of a service which stops is lost.
+		{
+			dbus.WithMatchSender("org.freedesktop.DBus"),
+			dbus.WithMatchInterface("org.freedesktop.DBus"),
+			dbus.WithMatchMember("NameOwnerChanged"),
+			dbus.WithMatchArg(0, s.service),
+		},
+	}
+}
+
+// currentState returns true if s currently reports a global connectivity.
+// A service which is not running reports no connectivity and is not started.
+func (s source) currentState(ctx context.Context, bus *dbus.Conn) bool {
+	var v dbus.Variant
+	err := bus.Object(s.service, s.path).CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", dbus.FlagNoAutoStart,
+		s.iface, s.property).Store(&v)
+	if err != nil {
+		log.Debugf(ctx, "Can't get %s connectivity, considering it down: %v", s.name, err)
+		return false
+	}
+	return s.isUp(v)
+}
+
+// Watcher notifies when the network connectivity of the machine comes up.
+type Watcher struct {
+	bus      *dbus.Conn
+	signals  chan *dbus.Signal
+	debounce time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	once   sync.Once
+}
+
+type options struct {
+	debounce time.Duration
+}
+
+// Option represents an optional function to change the network watcher.
+type Option func(*options)
+
+// WithDebounce overrides the default duration the network has to stay up before being notified.
+func WithDebounce(d time.Duration) Option {
+	return func(o *options) {
+		o.debounce = d
+	}
+}
+
+// New returns a watcher calling onUp each time the network connectivity comes up, until it is closed.
+// onUp is never called concurrently, and its context is cancelled once the watcher is closed.
+// The connectivity at startup is not notified.
+func New(ctx context.Context, bus *dbus.Conn, onUp func(context.Context), opts ...Option) (w *Watcher, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't watch network connectivity"))
+
+	args := options{
+		debounce: consts.DefaultNetworkUpDebounce,
+	}
+	for _, o := range opts {
+		o(&args)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w = &Watcher{
+		bus:      bus,
+		signals:  make(chan *dbus.Signal, 10),
+		debounce: args.debounce,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	// Subscribe before reading the current state, so that no change is missed in between.
+	bus.Signal(w.signals)
+	for _, s := range sources {
+		for _, m := range s.matches() {
+			if err := bus.AddMatchSignalContext(ctx, m...); err != nil {
+				cancel()
+				w.unsubscribe()
+				return nil, err
+			}
+		}
+	}
+
+	up := make(map[string]bool)
+	for _, s := range sources {
+		up[s.name] = s.currentState(ctx, bus)
+	}
+
+	go w.watch(ctx, up, onUp)
+
+	return w, nil
+}
+
+// Close stops watching the network connectivity, waiting for any onUp call in progress to return.
+func (w *Watcher) Close() {
+	w.once.Do(func() {
+		w.cancel()
+		<-w.done
+		w.unsubscribe()
+	})
+}
+
+// unsubscribe stops receiving the connectivity signals.
+func (w *Watcher) unsubscribe() {
+	w.bus.RemoveSignal(w.signals)
+	for _, s := range sources {
+		for _, m := range s.matches() {
+			_ = w.bus.RemoveMatchSignal(m...)
+		}
+	}
+}
+
+// watch tracks the connectivity of each source from their signals, starting from up, and calls onUp once the
+// network stayed up for the debounce duration.
+func (w *Watcher) watch(ctx context.Context, up map[string]bool, onUp func(context.Context)) {
+	defer close(w.done)
+
+	var timer *time.Timer
+	var settled <-chan time.Time
+	stopTimer := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		timer, settled = nil, nil
+	}
+	defer stopTimer()
+
+	isUp := anyUp(up)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig, ok := <-w.signals:
+			if !ok {
+				return
+			}
+			name, state, ok := stateChange(sig)
+			if !ok {
+				continue
+			}
+			up[name] = state
+
+			wasUp := isUp
+			isUp = anyUp(up)
+			switch {
+			case isUp && !wasUp:
+				log.Debugf(ctx, "Network connectivity came up with %s, waiting %s for it to settle", name, w.debounce)
+				stopTimer()
+				timer = time.NewTimer(w.debounce)
+				settled = timer.C
+			case !isUp && wasUp:
+				log.Debug(ctx, "Network connectivity went down")
+				stopTimer()
+			}
+		case <-settled:
+			timer, settled = nil, nil
+			log.Info(ctx, gotext.Get("Network connectivity is up"))
+			onUp(ctx)
+		}
+	}
+}
+
+// stateChange returns the name of the source whose connectivity changed in sig, and its new state.
+// ok is false if sig does not report a connectivity change.
+func stateChange(sig *dbus.Signal) (name string, isUp bool, ok bool) {
+	switch sig.Name {
+	case propertiesChangedSignal:
+		if len(sig.Body) < 2 {
+			return "", false, false
+		}
+		iface, _ := sig.Body[0].(string)
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		for _, s := range sources {
+			if sig.Path != s.path || iface != s.iface {
+				continue
+			}
+			v, found := changed[s.property]
+			if !found {
+				return "", false, false
+			}
+			return s.name, s.isUp(v), true
+		}
+	case nameOwnerChangedSignal:
+		if len(sig.Body) < 3 {
+			return "", false, false
+		}
+		service, _ := sig.Body[0].(string)
+		newOwner, _ := sig.Body[2].(string)
+		for _, s := range sources {
+			// A service which (re)starts reports its connectivity with its own signals.
+			if service != s.service || newOwner != "" {
+				continue
+			}
+			return s.name, false, true
+		}
+	}
+	return "", false, false
+}
+
+// anyUp returns true if any source reports a global connectivity.
+func anyUp(up map[string]bool) bool {
+	for _, state := range up {
+		if state {
+			return true
+		}
+	}
+	return false
+}
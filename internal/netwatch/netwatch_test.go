@@ -0,0 +1,267 @@
+package netwatch_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/netwatch"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+const debounce = 200 * time.Millisecond
+
+const (
+	nmDisconnected    uint32 = 20
+	nmConnectedSite   uint32 = 60
+	nmConnectedGlobal uint32 = 70
+)
+
+var (
+	mockConn       *dbus.Conn
+	nmProps        *prop.Properties
+	networkdProps  *prop.Properties
+	servicesByName = map[string]string{
+		"nm":       consts.NetworkManagerDbusRegisteredName,
+		"networkd": consts.NetworkdDbusRegisteredName,
+	}
+)
+
+// change is a connectivity change of a mock service.
+type change struct {
+	service string
+	// value is the new connectivity property value. The service stops if it is nil, and starts again on the next
+	// change.
+	value interface{}
+	// settle waits for the debounce duration to elapse after the change.
+	settle bool
+}
+
+func TestWatch(t *testing.T) {
+	tests := map[string]struct {
+		nmState       uint32
+		networkdState string
+		changes       []change
+
+		wantCalls int
+	}{
+		"NetworkManager global connectivity notifies once": {changes: []change{{service: "nm", value: nmConnectedGlobal}}, wantCalls: 1},
+		"systemd-networkd routable notifies once":          {changes: []change{{service: "networkd", value: "routable"}}, wantCalls: 1},
+		"Both services coming up notify once": {
+			changes:   []change{{service: "nm", value: nmConnectedGlobal}, {service: "networkd", value: "routable"}},
+			wantCalls: 1,
+		},
+		"Flapping connectivity notifies once settled": {
+			changes: []change{
+				{service: "nm", value: nmConnectedGlobal}, {service: "nm", value: nmDisconnected},
+				{service: "nm", value: nmConnectedGlobal}, {service: "nm", value: nmDisconnected},
+				{service: "nm", value: nmConnectedGlobal},
+			},
+			wantCalls: 1,
+		},
+		"Connectivity coming up again after settling notifies again": {
+			changes: []change{
+				{service: "nm", value: nmConnectedGlobal, settle: true}, {service: "nm", value: nmDisconnected, settle: true},
+				{service: "nm", value: nmConnectedGlobal},
+			},
+			wantCalls: 2,
+		},
+		"Service restarting with connectivity notifies again": {
+			changes: []change{
+				{service: "nm", value: nmConnectedGlobal, settle: true}, {service: "nm", value: nil, settle: true},
+				{service: "nm", value: nmConnectedGlobal},
+			},
+			wantCalls: 2,
+		},
+		"Connectivity of the other service going down doesn't notify again": {
+			changes: []change{
+				{service: "nm", value: nmConnectedGlobal}, {service: "networkd", value: "routable", settle: true},
+				{service: "nm", value: nmDisconnected, settle: true}, {service: "nm", value: nmConnectedGlobal},
+			},
+			wantCalls: 1,
+		},
+
+		// No notification cases
+		"Connectivity going down before settling doesn't notify": {
+			changes: []change{{service: "nm", value: nmConnectedGlobal}, {service: "nm", value: nmDisconnected}},
+		},
+		"Stopped service before settling doesn't notify": {
+			changes: []change{{service: "nm", value: nmConnectedGlobal}, {service: "nm", value: nil}},
+		},
+		"NetworkManager site connectivity doesn't notify": {changes: []change{{service: "nm", value: nmConnectedSite}}},
+		"systemd-networkd degraded doesn't notify":        {changes: []change{{service: "networkd", value: "degraded"}}},
+		"Connectivity already up at startup doesn't notify": {
+			nmState: nmConnectedGlobal,
+			changes: []change{{service: "networkd", value: "routable"}},
+		},
+		"Unchanged connectivity doesn't notify":  {networkdState: "routable", changes: []change{{service: "networkd", value: "routable"}}},
+		"No connectivity changes doesn't notify": {},
+	}
+
+	// The mock services are shared: tests can't run in parallel.
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.nmState == 0 {
+				tc.nmState = nmDisconnected
+			}
+			if tc.networkdState == "" {
+				tc.networkdState = "off"
+			}
+			nmProps.SetMust(consts.NetworkManagerDbusInterface, "State", tc.nmState)
+			networkdProps.SetMust(consts.NetworkdDbusManagerInterface, "OperationalState", tc.networkdState)
+
+			var calls atomic.Int32
+			w, err := netwatch.New(context.Background(), testutils.NewDbusConn(t), func(context.Context) { calls.Add(1) },
+				netwatch.WithDebounce(debounce))
+			require.NoError(t, err, "Setup: can't create network watcher")
+			defer w.Close()
+
+			for _, c := range tc.changes {
+				applyChange(t, c)
+				if c.settle {
+					time.Sleep(2 * debounce)
+				}
+			}
+			time.Sleep(2 * debounce)
+
+			require.EqualValues(t, tc.wantCalls, calls.Load(), "onUp should be called the expected number of times")
+		})
+	}
+}
+
+func TestCloseCancelsOnUp(t *testing.T) {
+	nmProps.SetMust(consts.NetworkManagerDbusInterface, "State", nmDisconnected)
+	networkdProps.SetMust(consts.NetworkdDbusManagerInterface, "OperationalState", "off")
+
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	w, err := netwatch.New(context.Background(), testutils.NewDbusConn(t), func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+	}, netwatch.WithDebounce(debounce))
+	require.NoError(t, err, "Setup: can't create network watcher")
+
+	nmProps.SetMust(consts.NetworkManagerDbusInterface, "State", nmConnectedGlobal)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onUp should have been called")
+	}
+
+	w.Close()
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("Close should cancel onUp and wait for it to return")
+	}
+
+	// Close can be called multiple times.
+	w.Close()
+}
+
+// applyChange applies the connectivity change c to the mock services.
+func applyChange(t *testing.T, c change) {
+	t.Helper()
+
+	service := servicesByName[c.service]
+	if c.value == nil {
+		reply, err := mockConn.ReleaseName(service)
+		require.NoError(t, err, "Setup: can't release service name")
+		require.Equal(t, dbus.ReleaseNameReplyReleased, reply, "Setup: service name should be released")
+		t.Cleanup(func() { requestName(t, service) })
+		return
+	}
+	requestName(t, service)
+
+	switch c.service {
+	case "nm":
+		nmProps.SetMust(consts.NetworkManagerDbusInterface, "State", c.value)
+	case "networkd":
+		networkdProps.SetMust(consts.NetworkdDbusManagerInterface, "OperationalState", c.value)
+	}
+	// Let the signal be delivered before the next change.
+	time.Sleep(10 * time.Millisecond)
+}
+
+// requestName owns service on the mock connection, if it doesn't already.
+func requestName(t *testing.T, service string) {
+	t.Helper()
+
+	reply, err := mockConn.RequestName(service, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err, "Setup: can't request service name")
+	if reply != dbus.RequestNameReplyPrimaryOwner && reply != dbus.RequestNameReplyAlreadyOwner {
+		t.Fatalf("Setup: can't own service name %s", service)
+	}
+}
+
+// exportService exports a mock service on conn, with the property reporting its connectivity set to value.
+func exportService(conn *dbus.Conn, service string, path dbus.ObjectPath, iface, property string, value interface{}, sig string) *prop.Properties {
+	props, err := prop.Export(conn, path, map[string]map[string]*prop.Prop{
+		iface: {
+			property: {
+				Value:    value,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: func(_ *prop.Change) *dbus.Error { return nil },
+			},
+		},
+	})
+	if err != nil {
+		log.Fatalf("Setup: could not export properties of %s: %v", service, err)
+	}
+
+	intro := fmt.Sprintf(`
+	<node>
+		<interface name="%s">
+			<property name='%s' type='%s' access="read"/>
+		</interface>%s%s</node>`, iface, property, sig, introspect.IntrospectDataString, prop.IntrospectDataString)
+	if err := conn.Export(introspect.Introspectable(intro), path, "org.freedesktop.DBus.Introspectable"); err != nil {
+		log.Fatalf("Setup: could not export introspectable object of %s: %v", service, err)
+	}
+
+	reply, err := conn.RequestName(service, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		log.Fatalf("Setup: Failed to acquire %s name on local system bus: %v", service, err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		log.Fatalf("Setup: Failed to acquire %s name on local system bus: name is already taken", service)
+	}
+
+	return props
+}
+
+func TestMain(m *testing.M) {
+	defer testutils.StartLocalSystemBus()()
+
+	conn, err := dbus.SystemBusPrivate()
+	if err != nil {
+		log.Fatalf("Setup: can't get a private system bus: %v", err)
+	}
+	defer func() {
+		if err = conn.Close(); err != nil {
+			log.Fatalf("Teardown: can't close system dbus connection: %v", err)
+		}
+	}()
+	if err = conn.Auth(nil); err != nil {
+		log.Fatalf("Setup: can't auth on private system bus: %v", err)
+	}
+	if err = conn.Hello(); err != nil {
+		log.Fatalf("Setup: can't send hello message on private system bus: %v", err)
+	}
+	mockConn = conn
+
+	nmProps = exportService(conn, consts.NetworkManagerDbusRegisteredName, consts.NetworkManagerDbusObjectPath,
+		consts.NetworkManagerDbusInterface, "State", nmDisconnected, "u")
+	networkdProps = exportService(conn, consts.NetworkdDbusRegisteredName, consts.NetworkdDbusObjectPath,
+		consts.NetworkdDbusManagerInterface, "OperationalState", "off", "s")
+
+	m.Run()
+}
@@ -10,12 +10,26 @@
 // Next, if we found no entries to apply (either to them not existing or being
 // disabled), we attempt to unload all rules managed by ADSys.
 //
+// The machine policies loaded by ADSys are recorded in the state directory.
+// Along with the policies declared by the deployed profiles, they are the only
+// ones which can be unloaded: policies from profiles not managed by ADSys are
+// left alone.
+//
 // If there are entries to apply, based on the object type (machine or user), we
 // attempt to apply them. This process is more clearly outlined in the
 // ApplyPolicy function documentation.
 //
 // If any errors occur during the policy apply process, the manager will attempt
 // to restore the initial state of the system before returning an error.
+//
+// Machine profiles are loaded in enforce mode, unless their entry line is
+// prefixed with "complain:", in which case they are loaded in complain
+// (learning) mode. The list of profiles in complain mode is kept alongside the
+// machine profiles, so that they are reloaded in the same mode when user
+// policies are applied.
+//
+// Machine profiles are not validated nor reloaded when the same profiles are
+// deployed again in the same modes, as long as their policies are still loaded.
 package apparmor
 
 import (
@@ -26,9 +40,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"slices"
 	"strings"
 	"sync"
@@ -36,12 +52,25 @@ import (
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/fileutils"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/smbsafe"
 	"github.com/ubuntu/decorate"
 )
 
+const (
+	// complainPrefix selects complain mode for a machine profile.
+	complainPrefix = "complain:"
+	// enforcePrefix selects enforce mode for a machine profile, which is the default.
+	enforcePrefix = "enforce:"
+
+	// complainProfilesFile lists the machine profiles loaded in complain mode, relative to the machine directory.
+	complainProfilesFile = "machine.complain"
+	// includesFile lists the machine files which are included by profiles, relative to the machine directory.
+	includesFile = "machine.includes"
+)
+
 // WithApparmorParserCmd overrides the default apparmor_parser command.
 func WithApparmorParserCmd(cmd []string) Option {
 	return func(o *options) {
@@ -49,6 +78,13 @@ func WithApparmorParserCmd(cmd []string) Option {
 	}
 }
 
+// WithStateDir overrides the default state directory, where the loaded machine policies are recorded.
+func WithStateDir(p string) Option {
+	return func(o *options) {
+		o.stateDir = p
+	}
+}
+
 // WithApparmorFsDir specifies a personalized directory for the apparmor
 // security filesystem.
 func WithApparmorFsDir(path string) Option {
@@ -63,6 +99,7 @@ type Manager struct {
 	apparmorCacheDir   string
 	apparmorParserCmd  []string
 	loadedPoliciesFile string
+	policiesRecord     string
 
 	mu sync.Mutex // Prevents multiple instances of apparmor from running concurrenctly
 }
@@ -70,9 +107,10 @@ type Manager struct {
 type options struct {
 	apparmorParserCmd []string
 	apparmorFsDir     string
+	stateDir          string
 }
 
-// Option reprents an optional function to change the apparmor manager.
+// Option represents an optional function to change the apparmor manager.
 type Option func(*options)
 
 // New creates a manager with a specific apparmor directory.
@@ -81,6 +119,7 @@ func New(apparmorDir string, opts ...Option) *Manager {
 	args := options{
 		apparmorParserCmd: []string{"apparmor_parser"},
 		apparmorFsDir:     "/sys/kernel/security/apparmor",
+		stateDir:          consts.DefaultStateDir,
 	}
 	// applied options
 	for _, o := range opts {
@@ -93,6 +132,7 @@ func New(apparmorDir string, opts ...Option) *Manager {
 		apparmorCacheDir:   filepath.Join(consts.DefaultCacheDir, "apparmor"),
 		apparmorParserCmd:  args.apparmorParserCmd,
 		loadedPoliciesFile: filepath.Join(args.apparmorFsDir, "profiles"),
+		policiesRecord:     filepath.Join(args.stateDir, "apparmor", "machine-policies"),
 	}
 }
 
@@ -105,11 +145,13 @@ type AssetsDumper func(ctx context.Context, relSrc, dest string, uid int, gid in
 // 2.  Create /etc/apparmor.d/adsys/<object>.new with new policy
 // 3a. Move /etc/apparmor.d/adsys/<object> to /etc/apparmor.d/adsys/<object>.old
 // 3b. Move /etc/apparmor.d/adsys/<object>.new to /etc/apparmor.d/adsys/<object>
-// 4.  Get the new list of apparmor policies
-// 5.  Compute difference between old and new list of policies, unloading the removed ones if needed
-// 6.  Run apparmor_parser -r -W -L /var/cache/adsys/apparmor on all files in /etc/apparmor.d/adsys/<object>
-// 7a. If apparmor_parser fails, move /etc/apparmor.d/adsys/<object>.old to /etc/apparmor.d/adsys/<object>
-// 7b. If apparmor_parser succeeds, remove /etc/apparmor.d/adsys/<object>.old.
+// 4a. Get the new list of apparmor policies
+// 4b. Skip steps 5 to 7 if the machine profiles and their modes are unchanged, with all their policies still loaded
+// 5.  Run apparmor_parser -Q -K on all files in /etc/apparmor.d/adsys/<object>, going to 8a if any profile is invalid
+// 6.  Compute difference between old and new list of policies, unloading the removed ones if needed
+// 7.  Run apparmor_parser -r -W -L /var/cache/adsys/apparmor on all files in /etc/apparmor.d/adsys/<object>
+// 8a. If apparmor_parser fails, move /etc/apparmor.d/adsys/<object>.old to /etc/apparmor.d/adsys/<object>
+// 8b. If apparmor_parser succeeds, remove /etc/apparmor.d/adsys/<object>.old.
 func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry, assetsDumper AssetsDumper) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't apply apparmor policy to %s", objectName))
 
@@ -162,7 +204,7 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 func (m *Manager) applyMachinePolicy(ctx context.Context, e entry.Entry, apparmorPath string, assetsDumper AssetsDumper) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't apply machine policy"))
 
-	existingProfiles, err := filesInDir(apparmorPath)
+	existingProfiles, err := m.machineProfiles()
 	if err != nil {
 		return err
 	}
@@ -172,8 +214,8 @@ func (m *Manager) applyMachinePolicy(ctx context.Context, e entry.Entry, apparmo
 	if err != nil {
 		return err
 	}
-	// Get the list of policies on the filesystem
-	prevPolicies, err := m.policiesFromFiles(ctx, existingProfiles)
+	// Get the list of policies on the filesystem and the ones we previously loaded
+	prevPolicies, err := m.managedPolicies(ctx, existingProfiles)
 	if err != nil {
 		return err
 	}
@@ -209,13 +251,27 @@ func (m *Manager) applyMachinePolicy(ctx context.Context, e entry.Entry, apparmo
 	}
 
 	// Get the list of files to run apparmor_parser on
-	filesToLoad, err := filesFromEntry(e, apparmorPath)
+	filesToLoad, complain, err := filesFromEntry(e, apparmorPath)
+	if err != nil {
+		return err
+	}
+	prevComplain, err := m.machineFiles(complainProfilesFile)
+	if err != nil {
+		return err
+	}
+
+	// Get the files included by the profiles, which are deployed without being loaded
+	includes, err := includesFromProfiles(filesToLoad, apparmorPath, m.apparmorDir)
 	if err != nil {
 		return err
 	}
 
-	// Clean up dumped asset files that are not in the policy entry
-	if err := removeUnusedAssets(apparmorPath, filesToLoad); err != nil {
+	// Clean up dumped asset files that are neither in the policy entry nor included
+	filesToKeep := slices.Clone(filesToLoad)
+	for f := range includes {
+		filesToKeep = append(filesToKeep, f)
+	}
+	if err := removeUnusedAssets(apparmorPath, filesToKeep); err != nil {
 		return err
 	}
 
@@ -228,26 +284,44 @@ func (m *Manager) applyMachinePolicy(ctx context.Context, e entry.Entry, apparmo
 	// Compute difference between the prevPolicies and newPolicies slices,
 	// removing policies that are no longer needed
 	policiesToUnload := difference(prevPolicies, newPolicies)
-	if err := m.unloadPolicies(ctx, policiesToUnload); err != nil {
+
+	// Nothing to reload if the same profiles are deployed in the same modes, and all their policies are still loaded
+	unchanged, err := fileutils.SameTree(apparmorPath, oldApparmorPath)
+	if err != nil {
 		return err
 	}
+	unchanged = unchanged && maps.Equal(complain, prevComplain) &&
+		len(policiesToUnload) == 0 && len(difference(newPolicies, prevLoadedPolicies)) == 0
+	if unchanged {
+		log.Debug(ctx, gotext.Get("Apparmor machine profiles are unchanged and loaded"))
+	} else {
+		// Validate the new profiles before touching the loaded policies, so that
+		// an invalid profile keeps the previous set loaded
+		if err := m.validateProfiles(ctx, filesToLoad); err != nil {
+			return err
+		}
 
-	if len(filesToLoad) > 0 && os.Getenv("ADSYS_SKIP_ROOT_CALLS") == "" {
-		// Run apparmor_parser on the files to load, relying on apparmor's caching mechanism
-		apparmorParserCmd := append(m.apparmorParserCmd, []string{"-r", "-W", "-L", m.apparmorCacheDir}...)
-		apparmorParserCmd = append(apparmorParserCmd, filesToLoad...)
+		if err := m.unloadPolicies(ctx, policiesToUnload); err != nil {
+			return err
+		}
 
-		// #nosec G204 - We are in control of the arguments
-		cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
-		cmd.Dir = m.apparmorDir
-		smbsafe.WaitExec()
-		out, err := cmd.CombinedOutput()
-		smbsafe.DoneExec()
-		if err != nil {
-			return errors.New(gotext.Get("failed to load apparmor rules: %v\n%s", err, string(out)))
+		if len(filesToLoad) > 0 && os.Getenv("ADSYS_SKIP_ROOT_CALLS") == "" {
+			if err := m.loadProfiles(ctx, filesToLoad, complain, prevComplain); err != nil {
+				return err
+			}
 		}
 	}
 
+	if err := m.saveMachineFiles(complainProfilesFile, apparmorPath, complain); err != nil {
+		return err
+	}
+	if err := m.saveMachineFiles(includesFile, apparmorPath, includes); err != nil {
+		return err
+	}
+	if err := m.savePoliciesRecord(newPolicies); err != nil {
+		return err
+	}
+
 	// Loading rules succeeded, remove old apparmor policy dir
 	if err := os.RemoveAll(oldApparmorPath); err != nil {
 		return errors.New(gotext.Get("can't remove old apparmor directory %q: %v", oldApparmorPath, err))
@@ -266,10 +340,13 @@ func (m *Manager) applyUserPolicy(ctx context.Context, e entry.Entry, apparmorPa
 		return err
 	}
 	defer os.RemoveAll(tmpdir)
-	profilePaths, err := filesFromEntry(e, tmpdir)
+	profilePaths, complain, err := filesFromEntry(e, tmpdir)
 	if err != nil {
 		return err
 	}
+	if len(complain) > 0 {
+		return errors.New(gotext.Get("complain mode can only be set on machine profiles"))
+	}
 
 	// The user policy is always a single file
 	if len(profilePaths) != 1 {
@@ -299,7 +376,7 @@ func (m *Manager) applyUserPolicy(ctx context.Context, e entry.Entry, apparmorPa
 	}
 
 	// Reload apparmor machine profiles to ensure that updates to the user policy are applied
-	existingProfiles, err := filesInDir(filepath.Join(m.apparmorDir, "machine"))
+	existingProfiles, err := m.machineProfiles()
 	if errors.Is(err, os.ErrNotExist) {
 		log.Warningf(ctx, gotext.Get("No apparmor machine profiles configured for this machine, skipping reload"))
 		return nil
@@ -307,15 +384,16 @@ func (m *Manager) applyUserPolicy(ctx context.Context, e entry.Entry, apparmorPa
 	if err != nil {
 		return err
 	}
-	apparmorParserCmd := append(m.apparmorParserCmd, []string{"-r", "-W", "-L", m.apparmorCacheDir}...)
-	apparmorParserCmd = append(apparmorParserCmd, existingProfiles...)
-
-	// #nosec G204 - We are in control of the arguments
-	cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
-	cmd.Dir = m.apparmorDir
-	smbsafe.WaitExec()
-	out, err := cmd.CombinedOutput()
-	smbsafe.DoneExec()
+	// Machine profiles are reloaded in the mode they were last applied with
+	complain, err = m.machineFiles(complainProfilesFile)
+	if err != nil {
+		return err
+	}
+	// Validate all profiles including the new user profile before reloading any of them
+	err = m.validateProfiles(ctx, existingProfiles)
+	if err == nil {
+		err = m.loadProfiles(ctx, existingProfiles, complain, complain)
+	}
 	if err != nil {
 		// Restore the old content
 		var restoreErr error
@@ -329,11 +407,130 @@ func (m *Manager) applyUserPolicy(ctx context.Context, e entry.Entry, apparmorPa
 		}
 
 		// Return the execution error
+		return err
+	}
+	return nil
+}
+
+// loadProfiles loads or replaces the given profiles, relying on apparmor's caching mechanism for enforced profiles.
+// Profiles in complain are loaded in complain mode, the others in enforce mode. prevComplain lists the profiles which
+// were previously in complain mode.
+func (m *Manager) loadProfiles(ctx context.Context, profiles []string, complain, prevComplain map[string]struct{}) error {
+	var enforced, complained []string
+	var switched bool
+	for _, p := range profiles {
+		if _, ok := complain[p]; ok {
+			complained = append(complained, p)
+			continue
+		}
+		if _, ok := prevComplain[p]; ok {
+			switched = true
+		}
+		enforced = append(enforced, p)
+	}
+
+	if len(enforced) > 0 {
+		args := []string{"-r", "-W", "-L", m.apparmorCacheDir}
+		// Don't trust any cached policy of profiles switching back to enforce mode, and refresh it
+		if switched {
+			args = append(args, "--skip-read-cache")
+		}
+		if err := m.runParser(ctx, append(args, enforced...)); err != nil {
+			return err
+		}
+	}
+
+	if len(complained) > 0 {
+		// Policies in complain mode are never cached, so that the cache only contains enforced policies
+		if err := m.runParser(ctx, append([]string{"-r", "-C", "--skip-cache"}, complained...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runParser runs apparmor_parser with the given arguments to load rules.
+func (m *Manager) runParser(ctx context.Context, args []string) error {
+	apparmorParserCmd := append(slices.Clone(m.apparmorParserCmd), args...)
+
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
+	cmd.Dir = m.apparmorDir
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
 		return errors.New(gotext.Get("failed to load apparmor rules: %v\n%s", err, string(out)))
 	}
 	return nil
 }
 
+// machineFiles returns the paths of the machine files recorded in the list name.
+func (m *Manager) machineFiles(name string) (files map[string]struct{}, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read apparmor machine files list %s", name))
+
+	d, err := os.ReadFile(filepath.Join(m.apparmorDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	files = make(map[string]struct{})
+	machineDir := filepath.Join(m.apparmorDir, "machine")
+	for _, p := range strings.Split(string(d), "\n") {
+		if p == "" {
+			continue
+		}
+		files[filepath.Join(machineDir, p)] = struct{}{}
+	}
+	return files, nil
+}
+
+// saveMachineFiles records in the list name the given files of apparmorPath, relative to it.
+// The list is removed if there are no files.
+func (m *Manager) saveMachineFiles(name, apparmorPath string, files map[string]struct{}) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save apparmor machine files list %s", name))
+
+	p := filepath.Join(m.apparmorDir, name)
+	if len(files) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	var relFiles []string
+	for f := range files {
+		rel, err := filepath.Rel(apparmorPath, f)
+		if err != nil {
+			return err
+		}
+		relFiles = append(relFiles, rel)
+	}
+	slices.Sort(relFiles)
+
+	_, _, err = writeIfChanged(p, strings.Join(relFiles, "\n")+"\n")
+	return err
+}
+
+// machineProfiles returns the deployed machine profiles, without the files they include.
+func (m *Manager) machineProfiles() ([]string, error) {
+	files, err := filesInDir(filepath.Join(m.apparmorDir, "machine"))
+	if err != nil {
+		return nil, err
+	}
+	includes, err := m.machineFiles(includesFile)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(files, func(f string) bool {
+		_, ok := includes[f]
+		return ok
+	}), nil
+}
+
 // unloadAllRules unloads all apparmor rules in the given directory that are
 // currently loaded in the system (present in the apparmorfs profiles file) and
 // removes the directory.
@@ -347,25 +544,32 @@ func (m *Manager) unloadAllRules(ctx context.Context, objectName string, isCompu
 	if !isComputer {
 		pathToRemove = filepath.Join(m.apparmorDir, "users", objectName)
 	}
-	// If there are no machine policies there is nothing to unload
-	if _, err := os.Stat(machinePoliciesPath); err != nil && os.IsNotExist(err) {
-		if err := os.Remove(pathToRemove); !errors.Is(err, fs.ErrNotExist) {
+	// Walk the directory and get all the files to unload
+	filesToUnload, err := m.machineProfiles()
+	noMachinePolicies := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !noMachinePolicies {
+		return err
+	}
+	// If there are no machine policies, even recorded ones, there is nothing to unload
+	if noMachinePolicies {
+		recorded, err := m.managedPolicies(ctx, nil)
+		if err != nil {
 			return err
 		}
-		return nil
+		if len(recorded) == 0 {
+			if err := os.Remove(pathToRemove); !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
 	}
 
-	// Walk the directory and get all the files to unload
-	filesToUnload, err := filesInDir(machinePoliciesPath)
-	if err != nil {
-		return err
-	}
 	// Get the currently loaded list of policies
 	prevLoadedPolicies, err := m.loadedPolicies()
 	if err != nil {
 		return err
 	}
-	policies, err := m.policiesFromFiles(ctx, filesToUnload)
+	policies, err := m.managedPolicies(ctx, filesToUnload)
 	if err != nil {
 		return err
 	}
@@ -395,9 +599,60 @@ func (m *Manager) unloadAllRules(ctx context.Context, objectName string, isCompu
 	if err := os.RemoveAll(pathToRemove); err != nil {
 		return err
 	}
+	if isComputer {
+		for _, name := range []string{complainProfilesFile, includesFile} {
+			if err := os.Remove(filepath.Join(m.apparmorDir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return m.savePoliciesRecord(nil)
+	}
 	return nil
 }
 
+// managedPolicies returns the policies managed by ADSys: the ones declared by the given profiles, and the ones we
+// recorded as loaded, even if their profile was since removed.
+func (m *Manager) managedPolicies(ctx context.Context, profiles []string) (policies []string, err error) {
+	policies, err = m.policiesFromFiles(ctx, profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := os.ReadFile(m.policiesRecord)
+	if errors.Is(err, fs.ErrNotExist) {
+		return policies, nil
+	} else if err != nil {
+		return nil, errors.New(gotext.Get("can't read loaded apparmor policies record: %v", err))
+	}
+	for _, policy := range strings.Split(string(d), "\n") {
+		if policy == "" || slices.Contains(policies, policy) {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// savePoliciesRecord records the machine policies loaded by ADSys. The record is removed if there are none.
+func (m *Manager) savePoliciesRecord(policies []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't record loaded apparmor policies"))
+
+	if len(policies) == 0 {
+		if err := os.Remove(m.policiesRecord); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	policies = slices.Clone(policies)
+	slices.Sort(policies)
+	if err := os.MkdirAll(filepath.Dir(m.policiesRecord), 0700); err != nil {
+		return err
+	}
+	_, _, err = writeIfChanged(m.policiesRecord, strings.Join(slices.Compact(policies), "\n")+"\n")
+	return err
+}
+
 // policiesFromFiles produces a list of policies from a given set of apparmor profiles.
 // A profile can have multiple policies.
 func (m *Manager) policiesFromFiles(ctx context.Context, profiles []string) (policies []string, err error) {
@@ -435,6 +690,28 @@ func (m *Manager) policiesFromFiles(ctx context.Context, profiles []string) (pol
 	return policies, nil
 }
 
+// validateProfiles parses and compiles the given profiles without loading them.
+// It returns an error with the parser diagnostics if any of the profiles is invalid.
+// No action is taken if the list of profiles is empty.
+func (m *Manager) validateProfiles(ctx context.Context, profiles []string) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	apparmorParserCmd := append(slices.Clone(m.apparmorParserCmd), "-Q", "-K")
+	apparmorParserCmd = append(apparmorParserCmd, profiles...)
+	// #nosec G204 - We are in control of the arguments
+	cmd := exec.CommandContext(ctx, apparmorParserCmd[0], apparmorParserCmd[1:]...)
+	cmd.Dir = m.apparmorDir
+	smbsafe.WaitExec()
+	out, err := cmd.CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return errors.New(gotext.Get("invalid apparmor profiles, previous rules are kept: %v\n%s", err, string(out)))
+	}
+	return nil
+}
+
 // unloadPolicies unloads the given apparmor policies.
 // It returns an error if any of the policies can't be unloaded.
 // No action is taken if the list of policies is empty.
@@ -524,12 +801,19 @@ func cleanupOldApparmorDir(ctx context.Context, oldApparmorPath, apparmorPath st
 	}
 }
 
-// filesFromEntry returns the list of files configured in the given policy entry.
-// It returns an error if the file does not exist or is a directory.
-func filesFromEntry(e entry.Entry, apparmorPath string) ([]string, error) {
-	var filesToLoad []string
+// filesFromEntry returns the list of files configured in the given policy entry, and those of them to load in
+// complain mode.
+// It returns an error if the file does not exist or is a directory, or if a file is configured with both modes.
+func filesFromEntry(e entry.Entry, apparmorPath string) (filesToLoad []string, complain map[string]struct{}, err error) {
+	complain = make(map[string]struct{})
 	for _, profile := range strings.Split(e.Value, "\n") {
 		profile = strings.TrimSpace(profile)
+		var inComplain bool
+		if p, ok := strings.CutPrefix(profile, complainPrefix); ok {
+			profile, inComplain = strings.TrimSpace(p), true
+		} else if p, ok := strings.CutPrefix(profile, enforcePrefix); ok {
+			profile = strings.TrimSpace(p)
+		}
 		if profile == "" {
 			continue
 		}
@@ -537,20 +821,113 @@ func filesFromEntry(e entry.Entry, apparmorPath string) ([]string, error) {
 		profileFilePath := filepath.Join(apparmorPath, profile)
 		info, err := os.Stat(profileFilePath)
 		if err != nil {
-			return nil, errors.New(gotext.Get("apparmor profile %q is not accessible: %v", profile, err))
+			return nil, nil, errors.New(gotext.Get("apparmor profile %q is not accessible: %v", profile, err))
 		}
 		if info.IsDir() {
-			return nil, errors.New(gotext.Get("apparmor profile %q is a directory and not a file", profile))
+			return nil, nil, errors.New(gotext.Get("apparmor profile %q is a directory and not a file", profile))
 		}
 
 		// Clean and deduplicate the profile file paths
 		cleanProfilePath := filepath.Clean(profileFilePath)
 		if slices.Contains(filesToLoad, cleanProfilePath) {
+			if _, wasComplain := complain[cleanProfilePath]; wasComplain != inComplain {
+				return nil, nil, errors.New(gotext.Get("apparmor profile %q is configured in both enforce and complain modes", profile))
+			}
 			continue
 		}
 		filesToLoad = append(filesToLoad, cleanProfilePath)
+		if inComplain {
+			complain[cleanProfilePath] = struct{}{}
+		}
+	}
+	return filesToLoad, complain, nil
+}
+
+// includeRe matches an AppArmor include rule, capturing its optional conditional and the included path either between
+// angle brackets or quotes.
+var includeRe = regexp.MustCompile(`^\s*#?include\s+(if\s+exists\s+)?(?:<([^>]+)>|"([^"]+)")`)
+
+// includesFromProfiles returns the files under apparmorPath which are included, directly or not, by the given profiles.
+// Relative quoted includes are resolved from parserDir, the apparmor_parser working directory, then from the directory
+// of the including file. Includes between angle brackets are only resolved for adsys/machine/, the other ones being
+// provided by the system.
+// It returns an error if an unconditional include is not provided.
+func includesFromProfiles(profiles []string, apparmorPath, parserDir string) (includes map[string]struct{}, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't resolve apparmor profiles includes"))
+
+	includes = make(map[string]struct{})
+	toParse := slices.Clone(profiles)
+	for len(toParse) > 0 {
+		p := toParse[0]
+		toParse = toParse[1:]
+
+		d, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(d), "\n") {
+			match := includeRe.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			conditional, angle, quoted := match[1] != "", match[2], match[3]
+
+			var candidates []string
+			switch {
+			case angle != "":
+				rel, ok := strings.CutPrefix(angle, "adsys/machine/")
+				if !ok {
+					continue
+				}
+				candidates = []string{filepath.Join(apparmorPath, rel)}
+			case filepath.IsAbs(quoted):
+				candidates = []string{quoted}
+			default:
+				candidates = []string{filepath.Join(parserDir, quoted), filepath.Join(filepath.Dir(p), quoted)}
+			}
+
+			files, found, err := resolveInclude(candidates)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				if conditional {
+					continue
+				}
+				return nil, errors.New(gotext.Get("%q included by apparmor profile %q is not provided", angle+quoted, filepath.Base(p)))
+			}
+
+			for _, f := range files {
+				// Only files we deploy are tracked, the others are provided by the system or the user policies
+				if rel, err := filepath.Rel(apparmorPath, f); err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+					continue
+				}
+				if _, ok := includes[f]; ok || slices.Contains(profiles, f) {
+					continue
+				}
+				includes[f] = struct{}{}
+				toParse = append(toParse, f)
+			}
+		}
+	}
+
+	return includes, nil
+}
+
+// resolveInclude returns the files included from the first existing candidate, which can be a file or a directory.
+// found is false if no candidate exists.
+func resolveInclude(candidates []string) (files []string, found bool, err error) {
+	for _, c := range candidates {
+		files, err := filesInDir(filepath.Clean(c))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return files, true, nil
 	}
-	return filesToLoad, nil
+	return nil, false, nil
 }
 
 // removeUnusedAssets removes all files/directories in the given directory that
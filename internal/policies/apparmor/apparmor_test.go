@@ -34,43 +34,67 @@ func TestApplyPolicy(t *testing.T) {
 		readOnlyApparmorDir    string
 		noApparmorParser       bool
 		existingLoadedPolicies []string
+		complainProfiles       []string
+		recordedPolicies       []string
+		includes               []string
 
 		saveAssetsError         bool
 		removeUnusedAssetsError bool
 		apparmorParserError     string
 
-		wantErr bool
+		wantRecordRemoved bool
+		wantErr           bool
 	}{
 		// computer cases
-		"Computer, one profile":                    {},
-		"Computer, multiple profiles,":             {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo\nusr.bin.bar\nnested/usr.bin.baz"}}},
-		"Computer, duplicated profiles":            {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo\nusr.bin.foo"}}},
-		"Computer, blank line profiles":            {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo\n\nusr.bin.bar\n"}}},
-		"Computer, profiles with whitespace":       {entries: []entry.Entry{{Key: "apparmor-machine", Value: " usr.bin.foo\n\n usr.bin.bar   \nnested/usr.bin.baz "}}},
-		"Computer, whitespace-only value":          {entries: []entry.Entry{{Key: "apparmor-machine", Value: "       "}}, noParserOutput: true},
-		"Computer, only blank profiles":            {entries: []entry.Entry{{Key: "apparmor-machine", Value: "\n\n\n"}}, noParserOutput: true},
-		"Computer, previous profiles are unloaded": {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}},
-		"Computer, user policies are unloaded":     {destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, entries: []entry.Entry{}, existingLoadedPolicies: []string{"/usr/bin/pam_binary", "/usr/bin/pam_binary//ubuntu", "/usr/bin/pam_binary//DEFAULT"}},
-		"Existing .new directory is removed":       {destsAlreadyExist: map[string]string{"only-machine": "machine.new"}},
-		"Existing .old directory is removed":       {destsAlreadyExist: map[string]string{"only-machine": "machine.old"}},
+		"Computer, one profile":                                {},
+		"Computer, multiple profiles,":                         {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo\nusr.bin.bar\nnested/usr.bin.baz"}}},
+		"Computer, duplicated profiles":                        {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo\nusr.bin.foo"}}},
+		"Computer, blank line profiles":                        {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo\n\nusr.bin.bar\n"}}},
+		"Computer, profiles with whitespace":                   {entries: []entry.Entry{{Key: "apparmor-machine", Value: " usr.bin.foo\n\n usr.bin.bar   \nnested/usr.bin.baz "}}},
+		"Computer, whitespace-only value":                      {entries: []entry.Entry{{Key: "apparmor-machine", Value: "       "}}, noParserOutput: true},
+		"Computer, only blank profiles":                        {entries: []entry.Entry{{Key: "apparmor-machine", Value: "\n\n\n"}}, noParserOutput: true},
+		"Computer, previous profiles are unloaded":             {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}},
+		"Computer, user policies are unloaded":                 {destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, entries: []entry.Entry{}, existingLoadedPolicies: []string{"/usr/bin/pam_binary", "/usr/bin/pam_binary//ubuntu", "/usr/bin/pam_binary//DEFAULT"}},
+		"Existing .new directory is removed":                   {destsAlreadyExist: map[string]string{"only-machine": "machine.new"}},
+		"Existing .old directory is removed":                   {destsAlreadyExist: map[string]string{"only-machine": "machine.old"}},
+		"Computer, profiles in complain mode":                  {entries: []entry.Entry{{Key: "apparmor-machine", Value: "complain:usr.bin.foo\nenforce:usr.bin.bar\nnested/usr.bin.baz"}}},
+		"Computer, all profiles in complain mode":              {entries: []entry.Entry{{Key: "apparmor-machine", Value: "complain:usr.bin.foo\n complain: nested/usr.bin.baz"}}},
+		"Computer, duplicated profiles in complain mode":       {entries: []entry.Entry{{Key: "apparmor-machine", Value: "complain:usr.bin.foo\ncomplain:usr.bin.foo"}}},
+		"Computer, profile switched from complain to enforce":  {destsAlreadyExist: map[string]string{"only-machine": "machine"}, complainProfiles: []string{"usr.bin.foo"}},
+		"Computer, profile kept in complain mode":              {destsAlreadyExist: map[string]string{"only-machine": "machine"}, complainProfiles: []string{"usr.bin.foo"}, entries: []entry.Entry{{Key: "apparmor-machine", Value: "complain:usr.bin.foo\nusr.bin.bar"}}},
+		"Computer, no profiles, complain mode list is removed": {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, complainProfiles: []string{"usr.bin.foo"}},
 
 		// shared cases
 		"No profiles, existing rules are removed": {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}},
 		"No profiles, apparmor directory absent":  {entries: []entry.Entry{}, noParserOutput: true},
 		"Unexpected entry key":                    {entries: []entry.Entry{{Key: "apparmor-foo", Value: "usr.bin.foo"}}, noParserOutput: true},
 
+		// includes cases
+		"Computer, profiles with includes":                       {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.with-includes\nusr.bin.foo"}}},
+		"Computer, previous includes are not parsed as profiles": {destsAlreadyExist: map[string]string{"machine-with-includes": "machine"}, includes: []string{"abstractions/adsys-common", "abstractions/nested/deep"}},
+		"User, machine profiles are reloaded without includes":   {destsAlreadyExist: map[string]string{"machine-with-includes": "machine"}, includes: []string{"abstractions/adsys-common", "abstractions/nested/deep"}, entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, user: true},
+		"Error on include not provided":                          {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.missing-include"}}, noParserOutput: true, wantErr: true},
+
+		// recorded policies cases
+		"Removed profile is unloaded, unmanaged ones are left alone": {recordedPolicies: []string{"/usr/bin/bar"}, existingLoadedPolicies: []string{"/usr/bin/bar", "/usr/bin/unmanaged"}},
+		"No profiles, recorded policies are unloaded":                {entries: []entry.Entry{}, recordedPolicies: []string{"/usr/bin/bar", "/usr/bin/foo"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/unmanaged"}, wantRecordRemoved: true},
+		"User, no entries, recorded user policies are unloaded":      {entries: []entry.Entry{}, recordedPolicies: []string{"/usr/bin/pam_binary", "/usr/bin/pam_binary//other", "/usr/bin/pam_binary//ubuntu"}, existingLoadedPolicies: []string{"/usr/bin/pam_binary", "/usr/bin/pam_binary//other", "/usr/bin/pam_binary//ubuntu"}, user: true},
+
 		// user cases
-		"User, valid mapping":                                   {destsAlreadyExist: map[string]string{"machine-with-users": "machine"}, entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, user: true},
-		"User, valid mapping, unchanged content":                {destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, entries: []entry.Entry{{Key: "apparmor-users", Value: "users/unchanged_user"}}, noParserOutput: true, user: true},
-		"User, no machine profiles":                             {entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, noParserOutput: true, user: true},
-		"User, no entries, existing user profile is deleted":    {destsAlreadyExist: map[string]string{"users": "users"}, entries: []entry.Entry{}, noParserOutput: true, user: true},
-		"User, no user profiles, machine profiles are unloaded": {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, existingLoadedPolicies: []string{"/usr/bin/pam_binary//ubuntu"}, user: true},
-		"User, error on empty user profile":                     {entries: []entry.Entry{{Key: "apparmor-users", Value: ""}}, noParserOutput: true, saveAssetsError: true, wantErr: true, user: true},
-		"User, error on save assets failing":                    {entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, noParserOutput: true, saveAssetsError: true, wantErr: true, user: true},
-		"User, error on overwriting profile contents":           {destsAlreadyExist: map[string]string{"users": "users"}, readOnlyApparmorDir: "users", entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, noParserOutput: true, wantErr: true, user: true},
-		"User, error on multiple profiles":                      {entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user\nusers/confined_user"}}, noParserOutput: true, wantErr: true, user: true},
-		"User, error on invalid user profile, restore previous": {destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, apparmorParserError: "-r", entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, wantErr: true, user: true},
-		"User, error on invalid user profile, delete previous":  {destsAlreadyExist: map[string]string{"machine-with-users": "machine"}, apparmorParserError: "-r", entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, wantErr: true, user: true},
+		"User, valid mapping":                                      {destsAlreadyExist: map[string]string{"machine-with-users": "machine"}, entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, user: true},
+		"User, valid mapping, unchanged content":                   {destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, entries: []entry.Entry{{Key: "apparmor-users", Value: "users/unchanged_user"}}, noParserOutput: true, user: true},
+		"User, no machine profiles":                                {entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, noParserOutput: true, user: true},
+		"User, machine profiles are reloaded in complain mode":     {destsAlreadyExist: map[string]string{"machine-with-users": "machine"}, complainProfiles: []string{"pam_roles"}, entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, user: true},
+		"User, no entries, existing user profile is deleted":       {destsAlreadyExist: map[string]string{"users": "users"}, entries: []entry.Entry{}, noParserOutput: true, user: true},
+		"User, no user profiles, machine profiles are unloaded":    {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, existingLoadedPolicies: []string{"/usr/bin/pam_binary//ubuntu"}, user: true},
+		"User, error on empty user profile":                        {entries: []entry.Entry{{Key: "apparmor-users", Value: ""}}, noParserOutput: true, saveAssetsError: true, wantErr: true, user: true},
+		"User, error on save assets failing":                       {entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, noParserOutput: true, saveAssetsError: true, wantErr: true, user: true},
+		"User, error on overwriting profile contents":              {destsAlreadyExist: map[string]string{"users": "users"}, readOnlyApparmorDir: "users", entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, noParserOutput: true, wantErr: true, user: true},
+		"User, error on multiple profiles":                         {entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user\nusers/confined_user"}}, noParserOutput: true, wantErr: true, user: true},
+		"User, error on profile in complain mode":                  {entries: []entry.Entry{{Key: "apparmor-users", Value: "complain:users/privileged_user"}}, noParserOutput: true, wantErr: true, user: true},
+		"User, error on invalid user profile, restore previous":    {destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, apparmorParserError: "-r", entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, wantErr: true, user: true},
+		"User, error on validating user profile, restore previous": {destsAlreadyExist: map[string]string{"machine-with-users": "machine", "users": "users"}, apparmorParserError: "-Q", entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, wantErr: true, user: true},
+		"User, error on invalid user profile, delete previous":     {destsAlreadyExist: map[string]string{"machine-with-users": "machine"}, apparmorParserError: "-r", entries: []entry.Entry{{Key: "apparmor-users", Value: "users/privileged_user"}}, wantErr: true, user: true},
 
 		// other edge cases
 		"No apparmor_parser and no entries":       {entries: []entry.Entry{}, noApparmorParser: true, noParserOutput: true},
@@ -78,22 +102,26 @@ func TestApplyPolicy(t *testing.T) {
 		"Read-only root directory and no entries": {entries: []entry.Entry{}, readOnlyApparmorDir: ".", noParserOutput: true},
 
 		// error cases
-		"Error on loading profiles failing":                {apparmorParserError: "-r", wantErr: true},
-		"Error on preprocessing new profiles failing":      {apparmorParserError: "-N", wantErr: true},
-		"Error on preprocessing old profiles failing":      {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo"}, apparmorParserError: "-N", wantErr: true},
-		"Error on unloading all profiles failing":          {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
-		"Error on unloading old profiles failing":          {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
-		"Error on save assets dumping failing":             {noParserOutput: true, saveAssetsError: true, wantErr: true},
-		"Error on removing unused assets after dump":       {noParserOutput: true, removeUnusedAssetsError: true, wantErr: true},
-		"Error on profile being a directory":               {entries: []entry.Entry{{Key: "apparmor-machine", Value: "nested/"}}, noParserOutput: true, wantErr: true},
-		"Error on absent profile":                          {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.nonexistent"}}, noParserOutput: true, wantErr: true},
-		"Error on absent loaded policies file":             {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"parseError"}, noParserOutput: true, wantErr: true},
-		"Error on file as a directory":                     {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo/notadir"}}, noParserOutput: true, wantErr: true},
-		"Error on read-only root directory with entries":   {readOnlyApparmorDir: ".", noParserOutput: true, wantErr: true},
-		"Error on read-only machine directory":             {destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine", wantErr: true},
-		"Error on read-only machine directory, no entries": {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine/nested", wantErr: true},
-		"Error on read-only .old directory":                {destsAlreadyExist: map[string]string{"only-machine": "machine.old"}, readOnlyApparmorDir: "machine.old", noParserOutput: true, wantErr: true},
-		"Error on read-only .new directory":                {destsAlreadyExist: map[string]string{"only-machine": "machine.new"}, readOnlyApparmorDir: "machine.new", noParserOutput: true, wantErr: true},
+		"Error on loading profiles failing":                                {apparmorParserError: "-r", wantErr: true},
+		"Error on validating profiles failing":                             {apparmorParserError: "-Q", wantErr: true},
+		"Error on validating profiles failing, previous profiles are kept": {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-Q", wantErr: true},
+		"Error on loading profiles in complain mode failing":               {entries: []entry.Entry{{Key: "apparmor-machine", Value: "complain:usr.bin.foo"}}, apparmorParserError: "-r", wantErr: true},
+		"Error on profile in both enforce and complain modes":              {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo\ncomplain:usr.bin.foo"}}, noParserOutput: true, wantErr: true},
+		"Error on preprocessing new profiles failing":                      {apparmorParserError: "-N", wantErr: true},
+		"Error on preprocessing old profiles failing":                      {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo"}, apparmorParserError: "-N", wantErr: true},
+		"Error on unloading all profiles failing":                          {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/foo", "/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
+		"Error on unloading old profiles failing":                          {destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"/usr/bin/bar", "/usr/bin/baz"}, apparmorParserError: "-R", wantErr: true},
+		"Error on save assets dumping failing":                             {noParserOutput: true, saveAssetsError: true, wantErr: true},
+		"Error on removing unused assets after dump":                       {noParserOutput: true, removeUnusedAssetsError: true, wantErr: true},
+		"Error on profile being a directory":                               {entries: []entry.Entry{{Key: "apparmor-machine", Value: "nested/"}}, noParserOutput: true, wantErr: true},
+		"Error on absent profile":                                          {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.nonexistent"}}, noParserOutput: true, wantErr: true},
+		"Error on absent loaded policies file":                             {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, existingLoadedPolicies: []string{"parseError"}, noParserOutput: true, wantErr: true},
+		"Error on file as a directory":                                     {entries: []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo/notadir"}}, noParserOutput: true, wantErr: true},
+		"Error on read-only root directory with entries":                   {readOnlyApparmorDir: ".", noParserOutput: true, wantErr: true},
+		"Error on read-only machine directory":                             {destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine", wantErr: true},
+		"Error on read-only machine directory, no entries":                 {entries: []entry.Entry{}, destsAlreadyExist: map[string]string{"only-machine": "machine"}, readOnlyApparmorDir: "machine/nested", wantErr: true},
+		"Error on read-only .old directory":                                {destsAlreadyExist: map[string]string{"only-machine": "machine.old"}, readOnlyApparmorDir: "machine.old", noParserOutput: true, wantErr: true},
+		"Error on read-only .new directory":                                {destsAlreadyExist: map[string]string{"only-machine": "machine.new"}, readOnlyApparmorDir: "machine.new", noParserOutput: true, wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -105,6 +133,13 @@ func TestApplyPolicy(t *testing.T) {
 			}
 
 			apparmorDir := t.TempDir()
+			stateDir := t.TempDir()
+			recordPath := filepath.Join(stateDir, "apparmor", "machine-policies")
+			if tc.recordedPolicies != nil {
+				require.NoError(t, os.MkdirAll(filepath.Dir(recordPath), 0700), "Setup: can't create state directory")
+				err := os.WriteFile(recordPath, []byte(strings.Join(tc.recordedPolicies, "\n")+"\n"), 0600)
+				require.NoError(t, err, "Setup: can't write recorded policies")
+			}
 			parserCmdOutputFile := filepath.Join(t.TempDir(), "parser-output")
 			loadedPoliciesFile := mockLoadedPoliciesFile(t, tc.existingLoadedPolicies)
 			if slices.Contains(tc.existingLoadedPolicies, "parseError") {
@@ -129,6 +164,14 @@ func TestApplyPolicy(t *testing.T) {
 						&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
 					"Setup: can't create initial apparmor dir machine profiles content")
 			}
+			if tc.complainProfiles != nil {
+				err := os.WriteFile(filepath.Join(apparmorDir, "machine.complain"), []byte(strings.Join(tc.complainProfiles, "\n")+"\n"), 0600)
+				require.NoError(t, err, "Setup: can't write profiles in complain mode")
+			}
+			if tc.includes != nil {
+				err := os.WriteFile(filepath.Join(apparmorDir, "machine.includes"), []byte(strings.Join(tc.includes, "\n")+"\n"), 0600)
+				require.NoError(t, err, "Setup: can't write included files")
+			}
 			if tc.readOnlyApparmorDir != "" {
 				testutils.MakeReadOnly(t, filepath.Join(apparmorDir, tc.readOnlyApparmorDir))
 			}
@@ -136,7 +179,8 @@ func TestApplyPolicy(t *testing.T) {
 
 			m := apparmor.New(apparmorDir,
 				apparmor.WithApparmorParserCmd(apparmorParserCmd),
-				apparmor.WithApparmorFsDir(filepath.Dir(loadedPoliciesFile)))
+				apparmor.WithApparmorFsDir(filepath.Dir(loadedPoliciesFile)),
+				apparmor.WithStateDir(stateDir))
 
 			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.user, tc.entries, mockAssetsDumper.SaveAssetsTo)
 			if tc.wantErr {
@@ -169,6 +213,9 @@ func TestApplyPolicy(t *testing.T) {
 				})
 				require.NoError(t, err, "Setup: can't restore permissions of dumped files")
 			}
+			if tc.wantRecordRemoved {
+				require.NoFileExists(t, recordPath, "Recorded policies should have been removed")
+			}
 			testutils.CompareTreesWithFiltering(t, apparmorDir, filepath.Join(testutils.GoldenPath(t), "etc", "apparmor.d", "adsys"), testutils.UpdateEnabled())
 
 			// Check that apparmor_parser was called with the expected arguments
@@ -190,6 +237,74 @@ func TestApplyPolicy(t *testing.T) {
 	}
 }
 
+func TestApplyPolicyUnchanged(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		secondEntries []entry.Entry
+		modifyProfile bool
+		removeProfile bool
+
+		wantReloaded bool
+	}{
+		"Unchanged profiles are not reloaded": {},
+
+		// Drift correction
+		"Profile modified out-of-band is reloaded": {modifyProfile: true, wantReloaded: true},
+		"Profile removed out-of-band is reloaded":  {removeProfile: true, wantReloaded: true},
+		"Profile switched to complain mode is reloaded": {
+			secondEntries: []entry.Entry{{Key: "apparmor-machine", Value: "complain:usr.bin.foo"}},
+			wantReloaded:  true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries := []entry.Entry{{Key: "apparmor-machine", Value: "usr.bin.foo"}}
+			if tc.secondEntries == nil {
+				tc.secondEntries = entries
+			}
+
+			apparmorDir := t.TempDir()
+			parserCmdOutputFile := filepath.Join(t.TempDir(), "parser-output")
+			loadedPoliciesFile := mockLoadedPoliciesFile(t, []string{"/usr/bin/foo"})
+			mockAssetsDumper := testutils.MockAssetsDumper{Path: "apparmor/", T: t}
+			m := apparmor.New(apparmorDir,
+				apparmor.WithApparmorParserCmd(mockApparmorParserCmd(t, parserCmdOutputFile)),
+				apparmor.WithApparmorFsDir(filepath.Dir(loadedPoliciesFile)),
+				apparmor.WithStateDir(t.TempDir()))
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", true, entries, mockAssetsDumper.SaveAssetsTo)
+			require.NoError(t, err, "Setup: first ApplyPolicy failed")
+			require.NoError(t, os.Remove(parserCmdOutputFile), "Setup: can't reset parser output")
+
+			profile := filepath.Join(apparmorDir, "machine", "usr.bin.foo")
+			want, err := os.ReadFile(profile)
+			require.NoError(t, err, "Setup: can't read deployed profile")
+			switch {
+			case tc.modifyProfile:
+				require.NoError(t, os.WriteFile(profile, []byte("/usr/bin/foo flags=(unconfined) {}\n"), 0600), "Setup: can't modify profile")
+			case tc.removeProfile:
+				require.NoError(t, os.Remove(profile), "Setup: can't remove profile")
+			}
+
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, tc.secondEntries, mockAssetsDumper.SaveAssetsTo)
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			got, err := os.ReadFile(profile)
+			require.NoError(t, err, "Deployed profile should exist")
+			require.Equal(t, string(want), string(got), "Deployed profile should be restored")
+
+			out, err := os.ReadFile(parserCmdOutputFile)
+			require.NoError(t, err, "Setup: can't read parser output file")
+			calls := strings.Split(string(out), "\n")
+			reloaded := slices.Contains(calls, "-Q") || slices.Contains(calls, "-r")
+			require.Equal(t, tc.wantReloaded, reloaded, "Profiles should only be validated and reloaded when changed")
+		})
+	}
+}
+
 func appendToFile(t *testing.T, path string, data []byte) {
 	t.Helper()
 
@@ -0,0 +1,63 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// ErrApplyInProgress is returned when policies can't be applied as another policy application is still in progress.
+var ErrApplyInProgress = errors.New(gotext.Get("apply in progress"))
+
+// lockApply waits for up to the apply lock timeout for the policy applications conflicting with the one of objectName
+// to complete.
+// The machine policy application runs alone, as the users ones depend on the files it updates, like the machine dconf
+// database. Users policies are applied concurrently, each user only once at a time.
+// The returned function releases the lock.
+func (m *Manager) lockApply(ctx context.Context, objectName string, isComputer bool) (unlock func(), err error) {
+	lock, tryLock, unlockMu := m.applyMu.RLock, m.applyMu.TryRLock, m.applyMu.RUnlock
+	if isComputer {
+		lock, tryLock, unlockMu = m.applyMu.Lock, m.applyMu.TryLock, m.applyMu.Unlock
+	}
+	release := func() {
+		m.applyLockHolders.Add(-1)
+		unlockMu()
+	}
+
+	if tryLock() {
+		m.applyLockHolders.Add(1)
+		return release, nil
+	}
+
+	log.Debugf(ctx, "Waiting for another policy application to complete before applying policies for %s", objectName)
+	acquired := make(chan struct{})
+	go func() {
+		lock()
+		m.applyLockHolders.Add(1)
+		close(acquired)
+	}()
+
+	timer := time.NewTimer(m.applyLockTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-acquired:
+		return release, nil
+	case <-timer.C:
+		err = fmt.Errorf("%w: %s", ErrApplyInProgress,
+			gotext.Get("another policy application did not complete within %s", m.applyLockTimeout))
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Release the lock as soon as the abandoned wait gets it.
+	go func() {
+		<-acquired
+		release()
+	}()
+	return nil, err
+}
@@ -0,0 +1,88 @@
+package policies
+
+import (
+	"context"
+	"slices"
+
+	"github.com/ubuntu/adsys/internal/audit"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+)
+
+// auditedSystemd records the actions of the policy managers on systemd units in the audit recorder of their context.
+type auditedSystemd struct {
+	systemdCaller
+}
+
+func (s auditedSystemd) StartUnit(ctx context.Context, unit string) error {
+	audit.RecordUnit(ctx, "start", unit)
+	return s.systemdCaller.StartUnit(ctx, unit)
+}
+
+func (s auditedSystemd) StopUnit(ctx context.Context, unit string) error {
+	audit.RecordUnit(ctx, "stop", unit)
+	return s.systemdCaller.StopUnit(ctx, unit)
+}
+
+func (s auditedSystemd) TryRestartUnit(ctx context.Context, unit string) error {
+	audit.RecordUnit(ctx, "try-restart", unit)
+	return s.systemdCaller.TryRestartUnit(ctx, unit)
+}
+
+func (s auditedSystemd) EnableUnit(ctx context.Context, unit string) error {
+	audit.RecordUnit(ctx, "enable", unit)
+	return s.systemdCaller.EnableUnit(ctx, unit)
+}
+
+func (s auditedSystemd) DisableUnit(ctx context.Context, unit string) error {
+	audit.RecordUnit(ctx, "disable", unit)
+	return s.systemdCaller.DisableUnit(ctx, unit)
+}
+
+func (s auditedSystemd) DaemonReload(ctx context.Context) error {
+	audit.RecordUnit(ctx, "daemon-reload", "")
+	return s.systemdCaller.DaemonReload(ctx)
+}
+
+// auditEntry returns the audit entry of the policy application of pols for objectName, which ended with applyErr.
+// It lists, in the Managers order, the policy managers recorded by r which completed with different rules than
+// previous, or made changes recorded by rec, even if they failed.
+func (m *Manager) auditEntry(objectName string, isComputer bool, pols *Policies, previous map[string][]entry.Entry, r *timingsRecorder, rec *audit.Recorder, applyErr error) audit.Entry {
+	e := audit.Entry{
+		Time:       m.now(),
+		Target:     objectName,
+		IsComputer: isComputer,
+		GPOs:       make([]audit.GPO, 0, len(pols.GPOs)),
+	}
+	if applyErr != nil {
+		e.Error = applyErr.Error()
+	}
+	for _, g := range pols.GPOs {
+		e.GPOs = append(e.GPOs, audit.GPO{ID: g.ID, Name: g.Name})
+	}
+
+	r.mu.Lock()
+	var ran []string
+	for _, t := range r.timings {
+		ran = append(ran, t.Manager)
+	}
+	completed := slices.Clone(r.completed)
+	r.mu.Unlock()
+
+	rules := pols.GetUniqueRules()
+	for _, name := range Managers {
+		if !slices.Contains(ran, name) {
+			continue
+		}
+		c := audit.ManagerChanges{Manager: name}
+		if slices.Contains(completed, name) {
+			c.Rules = audit.RuleChanges(previous[name], rules[name])
+		}
+		c.Files, c.Units = rec.Changes(name)
+		if len(c.Rules) == 0 && len(c.Files) == 0 && len(c.Units) == 0 {
+			continue
+		}
+		e.Managers = append(e.Managers, c)
+	}
+
+	return e
+}
@@ -0,0 +1,160 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// CacheEntry is the policies cached for an object on its last update.
+type CacheEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// CacheEntries returns the objects with policies cached, sorted by name.
+func (m *Manager) CacheEntries(ctx context.Context) (entries []CacheEntry, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to list policies cache"))
+
+	log.Debug(ctx, "Listing policies cache")
+
+	dirs, err := os.ReadDir(m.policiesCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil, err
+		}
+		size, err := dirSize(filepath.Join(m.policiesCacheDir, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, CacheEntry{Name: d.Name(), Size: size, ModTime: info.ModTime()})
+	}
+	return entries, nil
+}
+
+// ListCache returns a printable list of the objects with policies cached, with their size and last update time.
+func (m *Manager) ListCache(ctx context.Context) (msg string, err error) {
+	entries, err := m.CacheEntries(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return gotext.Get("No policies cached") + "\n", nil
+	}
+
+	var out strings.Builder
+	w := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", gotext.Get("OBJECT"), gotext.Get("SIZE"), gotext.Get("LAST UPDATE"))
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, formatSize(e.Size), e.ModTime.Format(time.DateTime))
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// PurgeCache removes the policies cached for objectName or the current machine.
+// If all is true, the policies cached for every object are removed instead.
+// The purge waits for any policy update of the object in progress. It returns the objects whose cache was removed.
+func (m *Manager) PurgeCache(ctx context.Context, objectName string, isMachine, all bool) (purged []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to purge policies cache"))
+
+	if isMachine {
+		objectName = m.hostname
+	}
+
+	objectNames := []string{objectName}
+	if all {
+		entries, err := m.CacheEntries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objectNames = nil
+		for _, e := range entries {
+			objectNames = append(objectNames, e.Name)
+		}
+	}
+
+	for _, n := range objectNames {
+		if err := m.purgeObjectCache(ctx, n); err != nil {
+			return purged, err
+		}
+		purged = append(purged, n)
+	}
+	return purged, nil
+}
+
+// purgeObjectCache removes the policies cached for objectName, once no policy update is in progress for it.
+func (m *Manager) purgeObjectCache(ctx context.Context, objectName string) error {
+	// Share the lock of ApplyPolicies so that we don't remove a cache being saved.
+	m.muMu.Lock()
+	if _, ok := m.objectMu[objectName]; !ok {
+		m.objectMu[objectName] = &sync.Mutex{}
+	}
+	m.objectMu[objectName].Lock()
+	defer m.objectMu[objectName].Unlock()
+	m.muMu.Unlock()
+
+	p := filepath.Join(m.policiesCacheDir, objectName)
+	if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+		return errors.New(gotext.Get("no policies cached for %q", objectName))
+	} else if err != nil {
+		return err
+	}
+
+	log.Infof(ctx, "Purging policies cache of %s", objectName)
+	return os.RemoveAll(p)
+}
+
+// dirSize returns the total size of the regular files under p.
+func dirSize(p string) (size int64, err error) {
+	err = filepath.WalkDir(p, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// formatSize returns a human readable size, in powers of 1024.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -3,6 +3,8 @@ package certificate_test
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -83,6 +85,11 @@ func TestCertAutoenrollScript(t *testing.T) {
 		missingCertmonger bool
 		missingCepces     bool
 
+		enrolledCerts   bool
+		existingNSSDB   bool
+		missingCertutil bool
+		certutilError   bool
+
 		wantErr bool
 	}{
 		"Enroll with simple configuration":                   {args: []string{"enroll", "keypress", "example.com"}},
@@ -90,7 +97,21 @@ func TestCertAutoenrollScript(t *testing.T) {
 		"Enroll with empty advanced configuration":           {args: []string{"enroll", "keypress", "example.com", "--policy_servers_json", "null"}},
 		"Enroll with valid advanced configuration":           {args: []string{"enroll", "keypress", "example.com", "--policy_servers_json", compactedJSON.String()}},
 
-		"Unenroll": {args: []string{"unenroll", "keypress", "example.com"}},
+		"Enroll for a single template":                {args: []string{"enroll", "keypress", "example.com", "--template", "Machine"}},
+		"Enroll for a template in custom directories": {args: []string{"enroll", "keypress", "example.com", "--template", "Workstation", "--cert_dir", "#STATEDIR#/custom", "--private_dir", "#STATEDIR#/custom/private"}},
+		"Enroll for an unsupported template":          {args: []string{"enroll", "keypress", "example.com", "--template", "Unsupported"}},
+
+		"Unenroll":                   {args: []string{"unenroll", "keypress", "example.com"}},
+		"Unenroll a single template": {args: []string{"unenroll", "keypress", "example.com", "--template", "Machine"}},
+
+		// NSS database cases
+		"Enroll imports certificates in new NSS database":      {args: []string{"enroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}, enrolledCerts: true},
+		"Enroll imports certificates in existing NSS database": {args: []string{"enroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}, enrolledCerts: true, existingNSSDB: true},
+		"Enroll with nothing to import in NSS database":        {args: []string{"enroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}},
+		"Enroll with certutil not installed":                   {args: []string{"enroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}, enrolledCerts: true, missingCertutil: true},
+		"Unenroll removes certificates from NSS database":      {args: []string{"unenroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}, enrolledCerts: true, existingNSSDB: true},
+		"Unenroll without NSS database":                        {args: []string{"unenroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}, enrolledCerts: true},
+		"Unenroll ignores certutil failure":                    {args: []string{"unenroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}, enrolledCerts: true, existingNSSDB: true, certutilError: true},
 
 		// Missing binary cases
 		"Enroll with certmonger not installed": {args: []string{"enroll", "keypress", "example.com"}, missingCertmonger: true},
@@ -107,6 +128,8 @@ func TestCertAutoenrollScript(t *testing.T) {
 		"Error on read-only path":   {readOnlyPath: true, args: []string{"enroll", "keypress", "example.com"}, wantErr: true},
 		"Error on enroll failure":   {autoenrollError: true, args: []string{"enroll", "keypress", "example.com"}, wantErr: true},
 		"Error on unenroll failure": {autoenrollError: true, args: []string{"unenroll", "keypress", "example.com"}, wantErr: true},
+		"Error on NSS database import failure": {
+			args: []string{"enroll", "keypress", "example.com", "--nss_db", "#STATEDIR#/nssdb"}, enrolledCerts: true, certutilError: true, wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -126,14 +149,45 @@ func TestCertAutoenrollScript(t *testing.T) {
 				require.NoError(t, err, "Setup: could not create cepces binary")
 			}
 
+			// The certutil mock records its calls and creates the database on -N.
+			certutilLog := filepath.Join(binDir, "certutil.log")
+			if !tc.missingCertutil {
+				script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\n", certutilLog)
+				if tc.certutilError {
+					script += "exit 1\n"
+				}
+				script += `[ "$1" = "-N" ] && touch "${3#sql:}/cert9.db"` + "\nexit 0\n"
+				// #nosec G306. We want this asset to be executable.
+				err := os.WriteFile(filepath.Join(binDir, "certutil"), []byte(script), 0755)
+				require.NoError(t, err, "Setup: could not create certutil binary")
+			}
+
 			// Create a dummy cache file to ensure we don't fail when removing a non-empty directory
 			testutils.CreatePath(t, filepath.Join(sambaCacheDir, "cert_gpo_state_HOST.tdb"))
 
+			if tc.enrolledCerts {
+				certDir := filepath.Join(stateDir, "certs")
+				require.NoError(t, os.MkdirAll(certDir, 0755), "Setup: could not create certificates directory")
+				testutils.WriteFile(t, filepath.Join(certDir, "galacticcafe-CA.0.crt"), []byte("root certificate"), 0644)
+				testutils.WriteFile(t, filepath.Join(certDir, "galacticcafe-CA.Machine.crt"), []byte("certificate"), 0644)
+				testutils.WriteFile(t, filepath.Join(certDir, "README"), []byte("not a certificate"), 0644)
+				require.NoError(t, os.MkdirAll(globalTrustDir, 0755), "Setup: could not create global trust directory")
+				err := os.Symlink(filepath.Join(certDir, "galacticcafe-CA.0.crt"), filepath.Join(globalTrustDir, "galacticcafe-CA.0.crt"))
+				require.NoError(t, err, "Setup: could not link root certificate")
+			}
+			if tc.existingNSSDB {
+				testutils.CreatePath(t, filepath.Join(stateDir, "nssdb", "cert9.db"))
+			}
+
 			if tc.readOnlyPath {
 				testutils.MakeReadOnly(t, stateDir)
 			}
 
-			args := append(tc.args, "--state_dir", stateDir, "--global_trust_dir", globalTrustDir)
+			var args []string
+			for _, arg := range tc.args {
+				args = append(args, strings.ReplaceAll(arg, "#STATEDIR#", stateDir))
+			}
+			args = append(args, "--state_dir", stateDir, "--global_trust_dir", globalTrustDir)
 
 			// #nosec G204: we control the command line name and only change it for tests
 			cmd := exec.Command(certAutoenrollCmd, args...)
@@ -151,11 +205,21 @@ func TestCertAutoenrollScript(t *testing.T) {
 			}
 			require.NoErrorf(t, err, "cert-autoenroll should have exited successfully: %s", string(out))
 
-			got := strings.ReplaceAll(string(out), stateDir, "#STATEDIR#")
+			got := string(out)
+			if slices.Contains(tc.args, "--nss_db") {
+				calls, err := os.ReadFile(certutilLog)
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					require.NoError(t, err, "Teardown: could not read certutil calls")
+				}
+				got += "\ncertutil calls:\n" + string(calls)
+			}
+			got = strings.ReplaceAll(got, stateDir, "#STATEDIR#")
 			want := testutils.LoadWithUpdateFromGolden(t, got)
 			require.Equal(t, want, got, "Unexpected output from cert-autoenroll script")
 
-			if slices.Contains(tc.args, "unenroll") {
+			if slices.Contains(tc.args, "unenroll") && slices.Contains(tc.args, "--template") {
+				require.DirExists(t, sambaCacheDir, "Samba cache directory should be kept when unenrolling a single template")
+			} else if slices.Contains(tc.args, "unenroll") {
 				require.NoDirExists(t, sambaCacheDir, "Samba cache directory should have been removed on unenroll")
 			}
 		})
@@ -1,18 +1,28 @@
 // Package certificate provides a manager that handles certificate
 // autoenrollment.
 //
-// This manager only applies to computer objects.
-//
 // Provided that the AD backend is online and AD CS is set up, the manager will
 // parse the relevant GPOs and delegate to an external Python script that will
 // request Samba to enroll or un-enroll the machine for certificates.
 //
+// Users are enrolled too when they log in, for instance for VPN or email
+// certificates. The script then runs as the user, with the user ticket, and
+// stores the certificates in the user home directory, under
+// ~/.local/share/adsys, before importing them in the user NSS database.
+// Machine and user enrollments are tracked separately and don't interfere.
+//
 // If the GPO is disabled/not configured, the policy manager will attempt to
 // unenroll the machine only if traces of Samba cache are found on the disk.
 // If the enroll flag is unchecked, the machine will be unenrolled, namely the
 // certificates will be removed and monitoring will stop.
 // If any errors occur during the enrollment process, the manager will log them
 // prior to failing.
+//
+// Once enrolled, a systemd timer refreshes the machine policy when the first
+// enrolled certificate enters its renewal period, a configurable lead time
+// before its expiry. The machine then enrolls again for certificates about to
+// expire. The timer is removed when the machine is unenrolled. User
+// certificates are renewed on user policy refresh.
 package certificate
 
 import (
@@ -23,11 +33,13 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/leonelquinteros/gotext"
@@ -48,6 +60,12 @@ type Manager struct {
 	globalTrustDir  string
 	certEnrollCmd   []string
 
+	systemUnitDir   string
+	renewalLeadTime time.Duration
+	systemdCaller   systemdCaller
+
+	userLookup func(string) (*user.User, error)
+
 	mu sync.Mutex // Prevents multiple instances of the certificate manager from running in parallel
 }
 
@@ -78,15 +96,27 @@ const (
 //go:embed cert-autoenroll
 var CertEnrollCode string
 
+// systemdCaller is the interface to interact with systemd.
+type systemdCaller interface {
+	StartUnit(context.Context, string) error
+	StopUnit(context.Context, string) error
+	EnableUnit(context.Context, string) error
+	DisableUnit(context.Context, string) error
+	DaemonReload(context.Context) error
+}
+
 type options struct {
 	stateDir          string
 	runDir            string
 	shareDir          string
 	globalTrustDir    string
+	systemUnitDir     string
+	renewalLeadTime   time.Duration
 	certAutoenrollCmd []string
+	userLookup        func(string) (*user.User, error)
 }
 
-// Option reprents an optional function to change the certificate manager.
+// Option represents an optional function to change the certificate manager.
 type Option func(*options)
 
 // WithStateDir overrides the default state directory.
@@ -117,6 +147,20 @@ func WithGlobalTrustDir(p string) func(*options) {
 	}
 }
 
+// WithSystemUnitDir overrides the default systemd system unit directory.
+func WithSystemUnitDir(p string) func(*options) {
+	return func(a *options) {
+		a.systemUnitDir = p
+	}
+}
+
+// WithRenewalLeadTime overrides how long before their expiry the enrolled certificates are renewed.
+func WithRenewalLeadTime(d time.Duration) func(*options) {
+	return func(a *options) {
+		a.renewalLeadTime = d
+	}
+}
+
 // WithCertAutoenrollCmd overrides the default certificate autoenroll command.
 func WithCertAutoenrollCmd(cmd []string) func(*options) {
 	return func(a *options) {
@@ -125,14 +169,17 @@ func WithCertAutoenrollCmd(cmd []string) func(*options) {
 }
 
 // New returns a new manager for the certificate policy.
-func New(domain string, opts ...Option) *Manager {
+func New(domain string, systemdCaller systemdCaller, opts ...Option) *Manager {
 	// defaults
 	args := options{
 		stateDir:          consts.DefaultStateDir,
 		runDir:            consts.DefaultRunDir,
 		shareDir:          consts.DefaultShareDir,
 		globalTrustDir:    consts.DefaultGlobalTrustDir,
+		systemUnitDir:     consts.DefaultSystemUnitDir,
+		renewalLeadTime:   defaultRenewalLeadTime,
 		certAutoenrollCmd: []string{"python3", "-c", CertEnrollCode},
+		userLookup:        user.Lookup,
 	}
 	// applied options
 	for _, o := range opts {
@@ -146,39 +193,50 @@ func New(domain string, opts ...Option) *Manager {
 		vendorPythonDir: filepath.Join(args.shareDir, "python"),
 		globalTrustDir:  args.globalTrustDir,
 		certEnrollCmd:   args.certAutoenrollCmd,
+		systemUnitDir:   args.systemUnitDir,
+		renewalLeadTime: args.renewalLeadTime,
+		systemdCaller:   systemdCaller,
+		userLookup:      args.userLookup,
 	}
 }
 
-// ApplyPolicy runs the certificate autoenrollment script to enroll or un-enroll the machine.
+// ApplyPolicy runs the certificate autoenrollment script to enroll or un-enroll the machine or the user.
 func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer, isOnline bool, entries []entry.Entry) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't apply certificate policy"))
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if !isComputer {
-		log.Debug(ctx, "Certificate policy is only supported for computers, skipping...")
-		return nil
-	}
-
 	if !isOnline {
 		log.Debug(ctx, gotext.Get("AD backend is offline, skipping certificate policy"))
 		return nil
 	}
 
 	idx := slices.IndexFunc(entries, func(e entry.Entry) bool { return e.Key == "autoenroll" })
-	if idx == -1 {
-		// If the Samba cache directory doesn't exist, we don't have anything to unenroll
-		if _, err := os.Stat(filepath.Join(m.stateDir, "samba")); err != nil && os.IsNotExist(err) {
+
+	s := m.machineScope(objectName)
+	if !isComputer {
+		// Users without policy who never enrolled have nothing to clean up: don't look them up.
+		if _, err := os.Stat(m.userEnrollmentsRecord(objectName)); idx == -1 && errors.Is(err, os.ErrNotExist) {
 			return nil
 		}
+		if s, err = m.userScope(ctx, objectName); err != nil {
+			return err
+		}
+	}
 
-		log.Debug(ctx, "Certificate autoenrollment is not configured, unenrolling machine")
-		if err := m.runScript(ctx, "unenroll", objectName); err != nil {
+	if idx == -1 {
+		previous, err := m.previousEnrollments(s)
+		if err != nil {
 			return err
 		}
+		// If the object never enrolled, we don't have anything to unenroll
+		if len(previous) == 0 {
+			return m.removeRenewal(ctx, s)
+		}
 
-		return nil
+		log.Debugf(ctx, "Certificate autoenrollment is not configured, unenrolling %s", objectName)
+		return m.unenrollAll(ctx, s, previous)
 	}
 
 	log.Debug(ctx, "ApplyPolicy certificate policy")
@@ -196,8 +254,8 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 
 	var polSrvRegistryEntries []gpoEntry
 	for _, entry := range entries {
-		// We already handled the autoenroll entry
-		if entry.Key == "autoenroll" {
+		// We already handled the autoenroll entry, and templates are not forwarded to Samba
+		if entry.Key == "autoenroll" || entry.Key == templatesKey {
 			continue
 		}
 
@@ -226,16 +284,66 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		return errors.New(gotext.Get("failed to marshal policy server registry entries: %v", err))
 	}
 
-	if err := m.runScript(ctx, action, objectName, "--policy_servers_json", string(jsonGPOData)); err != nil {
+	previous, err := m.previousEnrollments(s)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if action == "unenroll" {
+		return m.unenrollAll(ctx, s, previous, "--policy_servers_json", string(jsonGPOData))
+	}
+
+	enrollments, err := enrollmentsFromEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	// Unenroll from the templates which are not configured anymore, then enroll for the configured ones.
+	// Failing enrollments don't prevent the others from being processed: all errors are reported at the end.
+	var stale []enrollment
+	for _, e := range previous {
+		if !slices.Contains(enrollments, e) {
+			stale = append(stale, e)
+		}
+	}
+	failed, unenrollErr := m.unenroll(ctx, s, stale, "--policy_servers_json", string(jsonGPOData))
+	enrollErr := m.enroll(ctx, s, enrollments, "--policy_servers_json", string(jsonGPOData))
+
+	if err := m.saveEnrollments(s, append(enrollments, failed...)); err != nil {
+		return errors.Join(unenrollErr, enrollErr, err)
+	}
+	if err := m.scheduleRenewal(ctx, s, enrollments); err != nil {
+		return errors.Join(unenrollErr, enrollErr, err)
+	}
+
+	return errors.Join(unenrollErr, enrollErr)
 }
 
-// runScript runs the certificate autoenrollment script with the given arguments.
-func (m *Manager) runScript(ctx context.Context, action, objectName string, extraArgs ...string) error {
-	scriptArgs := []string{action, objectName, m.domain, "--state_dir", m.stateDir, "--global_trust_dir", m.globalTrustDir}
+// unenrollAll unenrolls the object of s from all its enrollments and cleans up the Samba cache.
+func (m *Manager) unenrollAll(ctx context.Context, s scope, enrollments []enrollment, extraArgs ...string) error {
+	if !slices.Contains(enrollments, enrollment{}) {
+		enrollments = append(enrollments, enrollment{})
+	}
+
+	failed, unenrollErr := m.unenroll(ctx, s, enrollments, extraArgs...)
+	// Failed enrollments are kept to be unenrolled on next policy refresh.
+	if err := m.saveEnrollments(s, failed); err != nil {
+		return errors.Join(unenrollErr, err)
+	}
+	if unenrollErr != nil {
+		return unenrollErr
+	}
+
+	return m.removeRenewal(ctx, s)
+}
+
+// runScript runs the certificate autoenrollment script with the given arguments for the object of s.
+// User enrollments run as the user and import the certificates in its NSS database.
+func (m *Manager) runScript(ctx context.Context, s scope, action string, extraArgs ...string) error {
+	scriptArgs := []string{action, s.objectName, m.domain, "--state_dir", s.stateDir, "--global_trust_dir", s.globalTrustDir}
+	if s.nssDB != "" {
+		scriptArgs = append(scriptArgs, "--nss_db", s.nssDB)
+	}
 	scriptArgs = append(scriptArgs, extraArgs...)
 	cmdArgs := append(m.certEnrollCmd, scriptArgs...)
 	cmdCtx, cancel := context.WithTimeout(ctx, time.Second*10)
@@ -243,10 +351,13 @@ func (m *Manager) runScript(ctx context.Context, action, objectName string, extr
 	log.Debugf(ctx, "Running cert autoenroll script with arguments: %q", strings.Join(scriptArgs, " "))
 	// #nosec G204 - cmdArgs is under our control (python embedded script or mock for tests)
 	cmd := exec.CommandContext(cmdCtx, cmdArgs[0], cmdArgs[1:]...)
-	cmd.Env = append(os.Environ(),
-		fmt.Sprintf("KRB5CCNAME=%s", filepath.Join(m.krb5CacheDir, objectName)),
-		fmt.Sprintf("PYTHONPATH=%s:%s", os.Getenv("PYTHONPATH"), m.vendorPythonDir),
-	)
+	cmd.Env = append(slices.Clone(s.env), fmt.Sprintf("PYTHONPATH=%s:%s", os.Getenv("PYTHONPATH"), m.vendorPythonDir))
+	if s.krb5CCName != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("KRB5CCNAME=%s", s.krb5CCName))
+	}
+	if s.cred != nil && (int(s.cred.Uid) != os.Geteuid() || int(s.cred.Gid) != os.Getegid()) {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: s.cred}
+	}
 	smbsafe.WaitExec()
 	defer smbsafe.DoneExec()
 
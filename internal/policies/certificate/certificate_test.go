@@ -2,11 +2,22 @@ package certificate_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"math/big"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/adsys/internal/policies/certificate"
@@ -20,7 +31,24 @@ const (
 	disabledValue = "32768" // string representation of 0x8000
 )
 
+// enrolledCerts are the certificates which can be present in the trust directory before applying the policy.
+var enrolledCerts = map[string]struct {
+	notAfter time.Time
+	isCA     bool
+}{
+	"machine":          {notAfter: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)},
+	"machine-later":    {notAfter: time.Date(2100, 6, 1, 0, 0, 0, 0, time.UTC)},
+	"machine-expiring": {notAfter: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	"ca":               {notAfter: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), isCA: true},
+}
+
 var enrollEntry = entry.Entry{Key: "autoenroll", Value: enrollValue}
+var unenrollEntry = entry.Entry{Key: "autoenroll", Value: unenrollValue}
+
+func templatesEntry(templates ...string) entry.Entry {
+	return entry.Entry{Key: "certificate-templates", Value: strings.Join(templates, "\n")}
+}
+
 var advancedConfigurationEntries = []entry.Entry{
 	{Key: "Software/Policies/Microsoft/Cryptography/PolicyServers/37c9dc30f207f27f61a2f7c3aed598a6e2920b54/AuthFlags", Value: "2"},
 	{Key: "Software/Policies/Microsoft/Cryptography/PolicyServers/37c9dc30f207f27f61a2f7c3aed598a6e2920b54/Cost", Value: "2147483645"},
@@ -35,14 +63,28 @@ func TestApplyPolicy(t *testing.T) {
 	tests := map[string]struct {
 		entries []entry.Entry
 
-		isUser    bool
-		isOffline bool
+		isUser          bool
+		isOffline       bool
+		noUserTicket    bool
+		userLookupError bool
 
 		autoenrollScriptError bool
+		failingTemplate       string
 		runScript             bool
 		sambaDirExists        bool
+		previousEnrollments   string
+		// otherEnrollments are the enrollments of the machine when applying the policy of the user, and conversely.
+		otherEnrollments string
 
-		wantErr bool
+		certs             []string
+		invalidCert       bool
+		existingTimer     bool
+		renewalLeadTime   time.Duration
+		mockSystemdCaller mockSystemdCaller
+
+		wantTimer              bool
+		wantEnrollmentsRemoved bool
+		wantErr                bool
 	}{
 		// No-op cases
 		"Computer, no entries":          {},
@@ -54,10 +96,57 @@ func TestApplyPolicy(t *testing.T) {
 		"Computer, configured to enroll, advanced configuration": {entries: append(advancedConfigurationEntries, enrollEntry), runScript: true},
 
 		// Unenroll cases
-		"Computer, configured to unenroll":          {entries: []entry.Entry{{Key: "autoenroll", Value: unenrollValue}}, runScript: true},
-		"Computer, no entries, Samba cache present": {sambaDirExists: true, runScript: true},
+		"Computer, configured to unenroll":          {entries: []entry.Entry{{Key: "autoenroll", Value: unenrollValue}}, runScript: true, wantEnrollmentsRemoved: true},
+		"Computer, no entries, Samba cache present": {sambaDirExists: true, runScript: true, wantEnrollmentsRemoved: true},
 
-		"User, autoenroll not supported": {isUser: true, entries: []entry.Entry{enrollEntry}},
+		// User cases
+		"User, configured to enroll":                            {isUser: true, entries: []entry.Entry{enrollEntry}, runScript: true},
+		"User, configured to enroll without kerberos ticket":    {isUser: true, noUserTicket: true, entries: []entry.Entry{enrollEntry}, runScript: true},
+		"User, configured to enroll for multiple templates":     {isUser: true, entries: []entry.Entry{enrollEntry, templatesEntry("User", "EFS=/etc/ssl/efs")}, runScript: true},
+		"User, configured to unenroll":                          {isUser: true, entries: []entry.Entry{unenrollEntry}, previousEnrollments: "- template: User\n", runScript: true, wantEnrollmentsRemoved: true},
+		"User, no entries, unenrolls from all templates":        {isUser: true, previousEnrollments: "- template: User\n- template: EFS\n", runScript: true, wantEnrollmentsRemoved: true},
+		"User, no entries, never enrolled, is not looked up":    {isUser: true, sambaDirExists: true, userLookupError: true},
+		"User, domain is offline":                               {isUser: true, entries: []entry.Entry{enrollEntry}, isOffline: true},
+		"User, enrolled certificate does not schedule renewal":  {isUser: true, entries: []entry.Entry{enrollEntry}, certs: []string{"machine"}, runScript: true},
+		"User, certificate in renewal period is enrolled again": {isUser: true, entries: []entry.Entry{enrollEntry}, certs: []string{"machine-expiring"}, runScript: true},
+
+		// Machine and user scopes
+		"User, enrolling keeps machine enrollments and renewal timer": {
+			isUser: true, entries: []entry.Entry{enrollEntry}, otherEnrollments: "- template: Machine\n", existingTimer: true, runScript: true, wantTimer: true},
+		"User, unenrolling keeps machine enrollments and renewal timer": {
+			isUser: true, previousEnrollments: "- template: User\n", otherEnrollments: "- template: Machine\n", existingTimer: true, runScript: true, wantTimer: true, wantEnrollmentsRemoved: true},
+		"Computer, unenrolling keeps user enrollments": {
+			previousEnrollments: "- template: Machine\n", otherEnrollments: "- template: User\n", runScript: true, wantEnrollmentsRemoved: true},
+
+		// Templates cases
+		"Computer, configured to enroll for multiple templates":                               {entries: []entry.Entry{enrollEntry, templatesEntry("Machine", "8021X=/etc/ssl/8021x")}, runScript: true},
+		"Computer, templates with empty lines and spaces":                                     {entries: []entry.Entry{enrollEntry, templatesEntry("", "  Machine  ", "8021X = /etc/ssl/8021x/ ", "")}, runScript: true},
+		"Computer, templates with advanced configuration":                                     {entries: append(advancedConfigurationEntries, enrollEntry, templatesEntry("Machine")), runScript: true},
+		"Computer, empty templates enroll for all templates":                                  {entries: []entry.Entry{enrollEntry, templatesEntry("")}, runScript: true},
+		"Computer, disabled templates enroll for all templates":                               {entries: []entry.Entry{enrollEntry, {Key: "certificate-templates", Value: "Machine", Disabled: true}}, runScript: true},
+		"Computer, unenroll from templates removed from policy":                               {entries: []entry.Entry{enrollEntry, templatesEntry("Machine")}, previousEnrollments: "- template: Machine\n- template: 8021X\n  cert_dir: /etc/ssl/8021x\n", runScript: true},
+		"Computer, unenroll from all templates when switching to templates":                   {entries: []entry.Entry{enrollEntry, templatesEntry("Machine")}, sambaDirExists: true, runScript: true},
+		"Computer, unenroll from templates when switching to all templates":                   {entries: []entry.Entry{enrollEntry}, previousEnrollments: "- template: Machine\n", runScript: true},
+		"Computer, unenroll from template moved to another directory":                         {entries: []entry.Entry{enrollEntry, templatesEntry("Machine=/etc/ssl/machine")}, previousEnrollments: "- template: Machine\n", runScript: true},
+		"Computer, configured to unenroll, unenrolls from all templates":                      {entries: []entry.Entry{unenrollEntry}, previousEnrollments: "- template: Machine\n- template: 8021X\n", runScript: true, wantEnrollmentsRemoved: true},
+		"Computer, no entries, unenrolls from all templates":                                  {previousEnrollments: "- template: Machine\n- template: 8021X\n", runScript: true, wantEnrollmentsRemoved: true},
+		"Computer, renewal is scheduled for the first certificate to expire across templates": {entries: []entry.Entry{enrollEntry, templatesEntry("Machine", "Workstation")}, certs: []string{"Machine/machine-later", "Workstation/machine"}, runScript: true, wantTimer: true},
+		"Computer, certificate in renewal period is enrolled again for its template only":     {entries: []entry.Entry{enrollEntry, templatesEntry("Machine", "Workstation")}, certs: []string{"Machine/machine-expiring", "Workstation/machine"}, runScript: true},
+
+		// Renewal cases
+		"Computer, enrolled certificate schedules renewal":                    {entries: []entry.Entry{enrollEntry}, certs: []string{"machine"}, runScript: true, wantTimer: true},
+		"Computer, renewal is scheduled for the first certificate to expire":  {entries: []entry.Entry{enrollEntry}, certs: []string{"machine-later", "machine"}, runScript: true, wantTimer: true},
+		"Computer, renewal is scheduled with custom lead time":                {entries: []entry.Entry{enrollEntry}, certs: []string{"machine"}, renewalLeadTime: 30 * 24 * time.Hour, runScript: true, wantTimer: true},
+		"Computer, renewal updates existing timer":                            {entries: []entry.Entry{enrollEntry}, certs: []string{"machine"}, existingTimer: true, runScript: true, wantTimer: true},
+		"Computer, renewal is not scheduled for certificate authorities":      {entries: []entry.Entry{enrollEntry}, certs: []string{"ca"}, runScript: true},
+		"Computer, certificate in renewal period is enrolled again":           {entries: []entry.Entry{enrollEntry}, certs: []string{"machine-expiring", "machine"}, runScript: true},
+		"Computer, no enrolled certificate removes renewal timer":             {entries: []entry.Entry{enrollEntry}, existingTimer: true, runScript: true},
+		"Computer, configured to unenroll, removes renewal timer":             {entries: []entry.Entry{{Key: "autoenroll", Value: unenrollValue}}, existingTimer: true, runScript: true, wantEnrollmentsRemoved: true},
+		"Computer, no entries, Samba cache present, removes renewal timer":    {sambaDirExists: true, existingTimer: true, runScript: true, wantEnrollmentsRemoved: true},
+		"Computer, no entries, removes renewal timer":                         {existingTimer: true},
+		"Computer, autoenroll disabled, keeps renewal timer":                  {entries: []entry.Entry{{Key: "autoenroll", Value: disabledValue}}, existingTimer: true, wantTimer: true},
+		"Computer, only emit a warning when starting the renewal timer fails": {entries: []entry.Entry{enrollEntry}, certs: []string{"machine"}, mockSystemdCaller: mockSystemdCaller{failOn: start}, runScript: true, wantTimer: true},
+		"Computer, only emit a warning when stopping the renewal timer fails": {existingTimer: true, mockSystemdCaller: mockSystemdCaller{failOn: stop}},
 
 		// Error cases
 		"Error on autoenroll script failure": {autoenrollScriptError: true, entries: []entry.Entry{enrollEntry}, wantErr: true},
@@ -67,6 +156,27 @@ func TestApplyPolicy(t *testing.T) {
 				enrollEntry,
 				{Key: "Software/Policies/Microsoft/Cryptography/PolicyServers/37c9dc30f207f27f61a2f7c3aed598a6e2920b54/Flags", Value: "NotANumber"},
 			}, wantErr: true},
+		"Error on invalid enrolled certificate":                  {entries: []entry.Entry{enrollEntry}, invalidCert: true, wantErr: true},
+		"Error on unenrolling certificate in renewal period":     {entries: []entry.Entry{enrollEntry}, certs: []string{"machine-expiring"}, autoenrollScriptError: true, wantErr: true},
+		"Error on daemon-reload failing when scheduling renewal": {entries: []entry.Entry{enrollEntry}, certs: []string{"machine"}, mockSystemdCaller: mockSystemdCaller{failOn: daemonReload}, wantErr: true},
+		"Error on enabling renewal timer failing":                {entries: []entry.Entry{enrollEntry}, certs: []string{"machine"}, mockSystemdCaller: mockSystemdCaller{failOn: enable}, wantErr: true},
+		"Error on disabling renewal timer failing":               {existingTimer: true, mockSystemdCaller: mockSystemdCaller{failOn: disable}, wantErr: true},
+		"Error on daemon-reload failing when removing renewal":   {existingTimer: true, mockSystemdCaller: mockSystemdCaller{failOn: daemonReload}, wantErr: true},
+
+		// Templates error cases
+		"Error on one template failing to enroll, others are enrolled": {
+			entries: []entry.Entry{enrollEntry, templatesEntry("Machine", "8021X", "Workstation")}, failingTemplate: "8021X", runScript: true, wantErr: true},
+		"Error on one template failing to unenroll, kept for next refresh": {
+			entries: []entry.Entry{enrollEntry, templatesEntry("Workstation")}, previousEnrollments: "- template: Machine\n- template: 8021X\n", failingTemplate: "Machine", runScript: true, wantErr: true},
+		"Error on one template failing to unenroll, others are unenrolled": {
+			entries: []entry.Entry{unenrollEntry}, previousEnrollments: "- template: Machine\n- template: 8021X\n", failingTemplate: "Machine", runScript: true, wantErr: true},
+		"Error on invalid template name":                    {entries: []entry.Entry{enrollEntry, templatesEntry("Machine/Other")}, wantErr: true},
+		"Error on relative template directory":              {entries: []entry.Entry{enrollEntry, templatesEntry("Machine=ssl/machine")}, wantErr: true},
+		"Error on template listed multiple times":           {entries: []entry.Entry{enrollEntry, templatesEntry("Machine", "Machine=/etc/ssl/machine")}, wantErr: true},
+		"Error on invalid previous enrollments":             {entries: []entry.Entry{enrollEntry}, previousEnrollments: "not a list", wantErr: true},
+		"Error on invalid previous enrollments, no entries": {previousEnrollments: "not a list", wantErr: true},
+		"Error on user lookup failure":                      {isUser: true, userLookupError: true, entries: []entry.Entry{enrollEntry}, wantErr: true},
+		"Error on user lookup failure, no entries":          {isUser: true, userLookupError: true, previousEnrollments: "- template: User\n", wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -84,51 +194,245 @@ func TestApplyPolicy(t *testing.T) {
 			}()
 
 			tmpdir := t.TempDir()
-			sambaCacheDir := filepath.Join(tmpdir, "statedir", "samba")
+			homeDir := filepath.Join(tmpdir, "home", "keypress")
+
+			// Users enroll in their home directory, and their enrollments are recorded separately from the machine ones.
+			objectStateDir := filepath.Join(tmpdir, "statedir")
+			enrollmentsPath := filepath.Join(tmpdir, "statedir", "certificate", "enrollments")
+			otherEnrollmentsPath := filepath.Join(tmpdir, "statedir", "certificate", "users", "keypress")
+			if tc.isUser {
+				objectStateDir = filepath.Join(homeDir, ".local", "share", "adsys")
+				enrollmentsPath, otherEnrollmentsPath = otherEnrollmentsPath, enrollmentsPath
+			}
+
+			sambaCacheDir := filepath.Join(objectStateDir, "samba")
 			if tc.sambaDirExists {
 				require.NoError(t, os.MkdirAll(sambaCacheDir, 0750), "Setup: Samba cache dir should be created")
 			}
 
+			certsDir := filepath.Join(objectStateDir, "certs")
+			require.NoError(t, os.MkdirAll(certsDir, 0750), "Setup: certificates dir should be created")
+			for _, name := range tc.certs {
+				c := enrolledCerts[filepath.Base(name)]
+				writeCertificate(t, filepath.Join(certsDir, name+".crt"), c.notAfter, c.isCA)
+			}
+
+			if tc.previousEnrollments != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(enrollmentsPath), 0750), "Setup: enrollments record dir should be created")
+				testutils.WriteFile(t, enrollmentsPath, []byte(tc.previousEnrollments), 0600)
+			}
+			if tc.otherEnrollments != "" {
+				require.NoError(t, os.MkdirAll(filepath.Dir(otherEnrollmentsPath), 0750), "Setup: enrollments record dir should be created")
+				testutils.WriteFile(t, otherEnrollmentsPath, []byte(tc.otherEnrollments), 0600)
+			}
+
+			// The user ticket is tracked by the AD backend.
+			if !tc.noUserTicket {
+				require.NoError(t, os.MkdirAll(filepath.Join(tmpdir, "rundir", "krb5cc", "tracking"), 0700), "Setup: ticket tracking dir should be created")
+				err := os.Symlink(filepath.Join(tmpdir, "krb5cc_keypress"), filepath.Join(tmpdir, "rundir", "krb5cc", "tracking", "keypress"))
+				require.NoError(t, err, "Setup: user ticket should be tracked")
+			}
+			userLookup := func(string) (*user.User, error) {
+				if tc.userLookupError {
+					return nil, errors.New("user lookup error")
+				}
+				return &user.User{Uid: strconv.Itoa(os.Getuid()), Gid: strconv.Itoa(os.Getgid()), Username: "keypress", HomeDir: homeDir}, nil
+			}
+			if tc.invalidCert {
+				testutils.WriteFile(t, filepath.Join(certsDir, "invalid.crt"), []byte("-----BEGIN CERTIFICATE-----\naW52YWxpZA==\n-----END CERTIFICATE-----\n"), 0600)
+			}
+
+			systemUnitDir := filepath.Join(tmpdir, "systemd")
+			timerPath := filepath.Join(systemUnitDir, "adsys-certificate-renewal.timer")
+			servicePath := filepath.Join(systemUnitDir, "adsys-certificate-renewal.service")
+			require.NoError(t, os.MkdirAll(systemUnitDir, 0750), "Setup: systemd unit dir should be created")
+			if tc.existingTimer {
+				testutils.WriteFile(t, timerPath, []byte("[Timer]\nOnCalendar=2000-01-01 00:00:00 UTC\n"), 0600)
+				testutils.WriteFile(t, servicePath, []byte("[Service]\n"), 0600)
+			}
+
 			autoenrollCmdOutputFile := filepath.Join(tmpdir, "autoenroll-output")
-			autoenrollCmd := mockAutoenrollScript(t, autoenrollCmdOutputFile, tc.autoenrollScriptError)
+			autoenrollCmd := mockAutoenrollScript(t, autoenrollCmdOutputFile, tc.autoenrollScriptError, tc.failingTemplate)
 
-			m := certificate.New(
-				"example.com",
+			opts := []certificate.Option{
 				certificate.WithStateDir(filepath.Join(tmpdir, "statedir")),
 				certificate.WithRunDir(filepath.Join(tmpdir, "rundir")),
 				certificate.WithShareDir(filepath.Join(tmpdir, "sharedir")),
+				certificate.WithSystemUnitDir(systemUnitDir),
 				certificate.WithCertAutoenrollCmd(autoenrollCmd),
-			)
+				certificate.WithUserLookup(userLookup),
+			}
+			if tc.renewalLeadTime != 0 {
+				opts = append(opts, certificate.WithRenewalLeadTime(tc.renewalLeadTime))
+			}
+			m := certificate.New("example.com", &tc.mockSystemdCaller, opts...)
 
 			err = m.ApplyPolicy(context.Background(), "keypress", !tc.isUser, !tc.isOffline, tc.entries)
 			if tc.wantErr {
 				require.Error(t, err, "ApplyPolicy should fail")
+				// Failing templates don't prevent the other ones to be processed
+				if tc.failingTemplate != "" {
+					checkScriptOutput(t, autoenrollCmdOutputFile)
+					checkEnrollments(t, enrollmentsPath, tc.wantEnrollmentsRemoved)
+				}
 				return
 			}
 			require.NoError(t, err, "ApplyPolicy should succeed")
 
+			if tc.wantTimer {
+				got, err := os.ReadFile(timerPath)
+				require.NoError(t, err, "Renewal timer should be readable")
+				want := testutils.LoadWithUpdateFromGolden(t, string(got), testutils.WithGoldenPath(testutils.GoldenPath(t)+".timer"))
+				require.Equal(t, want, string(got), "Unexpected renewal timer content")
+				require.FileExists(t, servicePath, "Renewal service should exist")
+				// Only the units generated by the policy are validated, not the fixtures of existing ones
+				// which user policies keep untouched.
+				if tc.runScript && !tc.isUser {
+					testutils.ValidateSystemdUnits(t, systemUnitDir)
+				}
+			} else {
+				require.NoFileExists(t, timerPath, "Renewal timer should not exist")
+				require.NoFileExists(t, servicePath, "Renewal service should not exist")
+			}
+
 			// Check that the autoenroll script was called with the expected arguments
 			// and that the output file was created
 			if !tc.runScript {
 				return
 			}
 
-			got, err := os.ReadFile(autoenrollCmdOutputFile)
-			require.NoError(t, err, "Setup: Autoenroll mock output should be readable")
+			checkScriptOutput(t, autoenrollCmdOutputFile)
+			checkEnrollments(t, enrollmentsPath, tc.wantEnrollmentsRemoved)
 
-			want := testutils.LoadWithUpdateFromGolden(t, string(got))
-			require.Equal(t, want, string(got), "Unexpected output from autoenroll mock")
+			if tc.otherEnrollments != "" {
+				got, err := os.ReadFile(otherEnrollmentsPath)
+				require.NoError(t, err, "Enrollments of the other scope should be readable")
+				require.Equal(t, tc.otherEnrollments, string(got), "Enrollments of the other scope should not change")
+			}
 		})
 	}
 }
 
-func mockAutoenrollScript(t *testing.T, scriptOutputFile string, autoenrollScriptError bool) []string {
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		enrollments string
+		// certs maps the fixture certificates to their installed path, relative to the state directory.
+		certs map[string]string
+		// sambaCache is set when the machine enrolled before enrollments were recorded.
+		sambaCache  bool
+		invalidCert bool
+
+		wantErr bool
+	}{
+		"Enrollment never ran": {},
+		"Enrollment for all templates": {
+			enrollments: "- {}\n",
+			certs:       map[string]string{"machine.crt": "certs/machine.crt", "ca.crt": "certs/ca.crt"},
+		},
+		"Enrollment for each template": {
+			enrollments: "- template: Machine\n- template: 8021x\n",
+			certs:       map[string]string{"machine.crt": "certs/Machine/machine.crt", "8021x.crt": "certs/8021x/8021x.crt"},
+		},
+		"Enrollment with custom certificate directory": {
+			enrollments: "- template: Machine\n  cert_dir: #CERTDIR#\n",
+			certs:       map[string]string{"machine.crt": "custom/machine.crt"},
+		},
+		"Enrollment without certificate installed": {
+			enrollments: "- template: Machine\n- template: 8021x\n",
+			certs:       map[string]string{"machine.crt": "certs/Machine/machine.crt"},
+		},
+		"Enrollment with expired certificate": {
+			enrollments: "- {}\n",
+			certs:       map[string]string{"machine.crt": "certs/machine.crt", "expired.crt": "certs/expired.crt"},
+		},
+		"Enrollment before enrollments were recorded": {
+			certs:      map[string]string{"machine.crt": "certs/machine.crt"},
+			sambaCache: true,
+		},
+
+		// Error cases
+		"Error on invalid enrollments record": {enrollments: "invalid", wantErr: true},
+		"Error on invalid certificate":        {enrollments: "- {}\n", invalidCert: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			stateDir := t.TempDir()
+			for src, dst := range tc.certs {
+				d, err := os.ReadFile(filepath.Join(testutils.TestFamilyPath(t), "certs", src))
+				require.NoError(t, err, "Setup: failed to read fixture certificate")
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(stateDir, dst)), 0700), "Setup: failed to create certificate directory")
+				testutils.WriteFile(t, filepath.Join(stateDir, dst), d, 0600)
+			}
+			if tc.sambaCache {
+				require.NoError(t, os.MkdirAll(filepath.Join(stateDir, "samba"), 0700), "Setup: failed to create Samba cache directory")
+			}
+			if tc.invalidCert {
+				require.NoError(t, os.MkdirAll(filepath.Join(stateDir, "certs"), 0700), "Setup: failed to create certificate directory")
+				testutils.WriteFile(t, filepath.Join(stateDir, "certs", "invalid.crt"), []byte("-----BEGIN CERTIFICATE-----\naW52YWxpZA==\n-----END CERTIFICATE-----\n"), 0600)
+			}
+			if tc.enrollments != "" {
+				require.NoError(t, os.MkdirAll(filepath.Join(stateDir, "certificate"), 0700), "Setup: failed to create enrollments directory")
+				enrollments := strings.ReplaceAll(tc.enrollments, "#CERTDIR#", filepath.Join(stateDir, "custom"))
+				testutils.WriteFile(t, filepath.Join(stateDir, "certificate", "enrollments"), []byte(enrollments), 0600)
+			}
+
+			m := certificate.New("example.com", &mockSystemdCaller{}, certificate.WithStateDir(stateDir))
+
+			got, err := m.Status(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "Status should fail")
+				return
+			}
+			require.NoError(t, err, "Status should succeed")
+
+			got = strings.ReplaceAll(got, stateDir, "#STATEDIR#")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Status returned unexpected output")
+		})
+	}
+}
+
+// checkScriptOutput compares the calls made to the autoenroll mock to the golden file.
+func checkScriptOutput(t *testing.T, scriptOutputFile string) {
+	t.Helper()
+
+	got, err := os.ReadFile(scriptOutputFile)
+	require.NoError(t, err, "Setup: Autoenroll mock output should be readable")
+
+	want := testutils.LoadWithUpdateFromGolden(t, string(got))
+	require.Equal(t, want, string(got), "Unexpected output from autoenroll mock")
+}
+
+// checkEnrollments compares the recorded enrollments to the golden file, or checks that there are none.
+func checkEnrollments(t *testing.T, enrollmentsPath string, wantRemoved bool) {
+	t.Helper()
+
+	if wantRemoved {
+		require.NoFileExists(t, enrollmentsPath, "Enrollments record should have been removed")
+		return
+	}
+
+	got, err := os.ReadFile(enrollmentsPath)
+	require.NoError(t, err, "Enrollments record should be readable")
+	want := testutils.LoadWithUpdateFromGolden(t, string(got), testutils.WithGoldenPath(testutils.GoldenPath(t)+".enrollments"))
+	require.Equal(t, want, string(got), "Unexpected enrollments record")
+}
+
+func mockAutoenrollScript(t *testing.T, scriptOutputFile string, autoenrollScriptError bool, failingTemplate string) []string {
 	t.Helper()
 
 	cmdArgs := []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockAutoenrollScript", "--", scriptOutputFile}
 	if autoenrollScriptError {
 		cmdArgs = append(cmdArgs, "-Exit1-")
 	}
+	if failingTemplate != "" {
+		cmdArgs = append(cmdArgs, "-Exit1OnTemplate-", failingTemplate)
+	}
 
 	return cmdArgs
 }
@@ -156,6 +460,12 @@ func TestMockAutoenrollScript(t *testing.T) {
 		os.Exit(1)
 	}
 
+	var failingTemplate string
+	if args[0] == "-Exit1OnTemplate-" {
+		failingTemplate = args[1]
+		args = args[2:]
+	}
+
 	dataToWrite := strings.Join(args, " ") + "\n"
 	dataToWrite += "KRB5CCNAME=" + os.Getenv("KRB5CCNAME") + "\n"
 	dataToWrite += "PYTHONPATH=" + os.Getenv("PYTHONPATH") + "\n"
@@ -164,8 +474,90 @@ func TestMockAutoenrollScript(t *testing.T) {
 	tmpdir := filepath.Dir(outputFile)
 	dataToWrite = strings.ReplaceAll(dataToWrite, tmpdir, "#TMPDIR#")
 
-	err := os.WriteFile(outputFile, []byte(dataToWrite), 0600)
+	// Append to the output file as the script can be called multiple times in a single policy application
+	f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	require.NoError(t, err, "Setup: Can't open output file")
+	defer f.Close()
+	_, err = f.WriteString(dataToWrite)
 	require.NoError(t, err, "Setup: Can't write script args to output file")
+
+	if failingTemplate != "" && strings.Contains(strings.Join(args, " "), "--template "+failingTemplate+" ") {
+		fmt.Fprintf(os.Stderr, "EXIT 1 requested in mock for template %s", failingTemplate)
+		os.Exit(1)
+	}
+}
+
+// writeCertificate writes a self-signed certificate expiring at notAfter to path.
+func writeCertificate(t *testing.T, path string, notAfter time.Time, isCA bool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Setup: Can't generate certificate key")
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: filepath.Base(path)},
+		NotBefore:             notAfter.AddDate(-1, 0, 0),
+		NotAfter:              notAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err, "Setup: Can't create certificate")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750), "Setup: Can't create certificate directory")
+	testutils.WriteFile(t, path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+}
+
+type failingStep uint8
+
+const (
+	none failingStep = iota
+	start
+	stop
+	enable
+	disable
+	daemonReload
+)
+
+type mockSystemdCaller struct {
+	testutils.MockSystemdCaller
+
+	failOn failingStep
+}
+
+func (s mockSystemdCaller) StartUnit(_ context.Context, _ string) error {
+	if s.failOn == start {
+		return errors.New("failed to start unit")
+	}
+	return nil
+}
+
+func (s mockSystemdCaller) StopUnit(_ context.Context, _ string) error {
+	if s.failOn == stop {
+		return errors.New("failed to stop unit")
+	}
+	return nil
+}
+
+func (s mockSystemdCaller) EnableUnit(_ context.Context, _ string) error {
+	if s.failOn == enable {
+		return errors.New("failed to enable unit")
+	}
+	return nil
+}
+
+func (s mockSystemdCaller) DisableUnit(_ context.Context, _ string) error {
+	if s.failOn == disable {
+		return errors.New("failed to disable unit")
+	}
+	return nil
+}
+
+func (s mockSystemdCaller) DaemonReload(_ context.Context) error {
+	if s.failOn == daemonReload {
+		return errors.New("failed to reload daemon")
+	}
+	return nil
 }
 
 func TestMain(m *testing.M) {
@@ -0,0 +1,10 @@
+package certificate
+
+import "os/user"
+
+// WithUserLookup allows to mock system user lookup.
+func WithUserLookup(userLookup func(string) (*user.User, error)) Option {
+	return func(o *options) {
+		o.userLookup = userLookup
+	}
+}
@@ -0,0 +1,223 @@
+package certificate
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/fileutils"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	renewalUnitName = "adsys-certificate-renewal"
+
+	// defaultRenewalLeadTime is how long before the expiry of an enrolled certificate it is renewed.
+	defaultRenewalLeadTime = 14 * 24 * time.Hour
+
+	// renewalTimeFormat is the systemd calendar format of the renewal time.
+	renewalTimeFormat = "2006-01-02 15:04:05 UTC"
+)
+
+// renewalService refreshes the machine policy, which enrolls again for certificates about to expire.
+const renewalService = fileutils.ManagedHeader + `
+[Unit]
+Description=Renew ADSys enrolled certificates
+
+[Service]
+Type=oneshot
+ExecStart=/sbin/adsysctl update --machine
+`
+
+// renewalTimer starts the renewal service once the enrolled certificates enter their renewal period.
+// Persistent catches up on a renewal time missed while the machine was off.
+const renewalTimer = fileutils.ManagedHeader + `
+[Unit]
+Description=Renew ADSys enrolled certificates before they expire
+
+[Timer]
+OnCalendar=%s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+// enrolledCertificate is a certificate enrolled by the machine, and its validity period.
+type enrolledCertificate struct {
+	path      string
+	subject   string
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// enrolledCertificates returns the machine certificates stored in the certificate directories, ordered by expiry.
+// Certificate authorities stored alongside them are not renewed by the machine and are skipped.
+func (m *Manager) enrolledCertificates(certDirs ...string) (certs []enrolledCertificate, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read enrolled certificates"))
+
+	var paths []string
+	for _, dir := range certDirs {
+		p, err := filepath.Glob(filepath.Join(dir, "*.crt"))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, p...)
+	}
+	for _, p := range paths {
+		d, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var block *pem.Block
+			block, d = pem.Decode(d)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, errors.New(gotext.Get("invalid certificate %q: %v", p, err))
+			}
+			if cert.IsCA {
+				continue
+			}
+			certs = append(certs, enrolledCertificate{
+				path:      p,
+				subject:   cert.Subject.String(),
+				notBefore: cert.NotBefore,
+				notAfter:  cert.NotAfter,
+			})
+		}
+	}
+
+	// Earliest expiry first, paths being already sorted for certificates expiring at the same time.
+	slices.SortStableFunc(certs, func(a, b enrolledCertificate) int { return a.notAfter.Compare(b.notAfter) })
+	return certs, nil
+}
+
+// needsRenewal returns true if any certificate enrolled in certDir is within its renewal period.
+func (m *Manager) needsRenewal(ctx context.Context, certDir string) (bool, error) {
+	certs, err := m.enrolledCertificates(certDir)
+	if err != nil {
+		return false, err
+	}
+	if len(certs) == 0 {
+		return false, nil
+	}
+	if renewAt := certs[0].notAfter.Add(-m.renewalLeadTime); time.Now().Before(renewAt) {
+		return false, nil
+	}
+	log.Infof(ctx, "Certificate %q expires on %s, enrolling again to renew it", certs[0].path, certs[0].notAfter.Format(time.RFC3339))
+	return true, nil
+}
+
+// scheduleRenewal registers a systemd timer refreshing the machine policy when the first certificate enrolled
+// by any of the enrollments enters its renewal period.
+// No timer is registered if no certificate was enrolled or if renewing them did not extend their validity:
+// next policy refresh will try again. User certificates are only renewed on user policy refresh.
+func (m *Manager) scheduleRenewal(ctx context.Context, s scope, enrollments []enrollment) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't schedule certificate renewal"))
+
+	if s.isUser() {
+		return nil
+	}
+
+	var certDirs []string
+	for _, e := range enrollments {
+		certDir, _ := e.certDirs(s.stateDir)
+		certDirs = append(certDirs, certDir)
+	}
+	certs, err := m.enrolledCertificates(certDirs...)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		log.Debug(ctx, "No enrolled certificate to renew")
+		return m.removeRenewal(ctx, s)
+	}
+
+	renewAt := certs[0].notAfter.Add(-m.renewalLeadTime)
+	if !time.Now().Before(renewAt) {
+		log.Warningf(ctx, "Certificate %q expires on %s, within its renewal period: renewal will be attempted on next policy refresh", certs[0].path, certs[0].notAfter.Format(time.RFC3339))
+		return m.removeRenewal(ctx, s)
+	}
+
+	timer := fmt.Sprintf(renewalTimer, renewAt.UTC().Format(renewalTimeFormat))
+	log.Debugf(ctx, "Scheduling certificate renewal on %s", renewAt.Format(time.RFC3339))
+
+	var changed bool
+	for name, content := range map[string]string{renewalUnitName + ".service": renewalService, renewalUnitName + ".timer": timer} {
+		written, err := writeIfChanged(filepath.Join(m.systemUnitDir, name), content)
+		if err != nil {
+			return err
+		}
+		changed = changed || written
+	}
+	if !changed {
+		return nil
+	}
+
+	if err := m.systemdCaller.DaemonReload(ctx); err != nil {
+		return err
+	}
+	if err := m.systemdCaller.EnableUnit(ctx, renewalUnitName+".timer"); err != nil {
+		return err
+	}
+	if err := m.systemdCaller.StartUnit(ctx, renewalUnitName+".timer"); err != nil {
+		log.Warning(ctx, gotext.Get("failed to start unit %q: %v", renewalUnitName+".timer", err))
+	}
+	return nil
+}
+
+// removeRenewal stops and removes the certificate renewal timer of the machine, if any.
+func (m *Manager) removeRenewal(ctx context.Context, s scope) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't remove certificate renewal"))
+
+	if s.isUser() {
+		return nil
+	}
+
+	timer := filepath.Join(m.systemUnitDir, renewalUnitName+".timer")
+	if _, err := os.Stat(timer); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	log.Debug(ctx, "Removing certificate renewal timer")
+	if err := m.systemdCaller.StopUnit(ctx, renewalUnitName+".timer"); err != nil {
+		log.Warning(ctx, gotext.Get("Failed to stop unit %q: %v", renewalUnitName+".timer", err))
+	}
+	if err := m.systemdCaller.DisableUnit(ctx, renewalUnitName+".timer"); err != nil {
+		return err
+	}
+	for _, p := range []string{timer, filepath.Join(m.systemUnitDir, renewalUnitName+".service")} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return m.systemdCaller.DaemonReload(ctx)
+}
+
+// writeIfChanged writes content to path if it differs from the existing one. It returns true if the file changed.
+func writeIfChanged(path string, content string) (done bool, err error) {
+	// nolint:gosec // G301 - systemd units directory is world-readable
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	//nolint:gosec // G306 - systemd units are world-readable.
+	return fileutils.WriteIfChanged(path, []byte(content), 0644)
+}
@@ -0,0 +1,103 @@
+package certificate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// scope is the object enrolling for certificates, the machine or a user, and where its enrollments are stored.
+// Each scope has its own Samba cache, certificate stores and enrollments record, so that they don't interfere.
+type scope struct {
+	objectName string
+	// stateDir stores the Samba cache and the default certificate stores of the object.
+	stateDir string
+	// record is the path of the record of the enrollments of the object.
+	record         string
+	globalTrustDir string
+	krb5CCName     string
+
+	// nssDB is the NSS database of a user, which the enrolled certificates are imported into.
+	nssDB string
+	// cred and env are the credentials and environment of the user the autoenrollment script runs as.
+	cred *syscall.Credential
+	env  []string
+}
+
+// isUser returns true if the scope is the one of a user.
+func (s scope) isUser() bool {
+	return s.cred != nil
+}
+
+// machineScope returns the scope of the machine, which enrolls as root in the state directory.
+func (m *Manager) machineScope(objectName string) scope {
+	return scope{
+		objectName:     objectName,
+		stateDir:       m.stateDir,
+		record:         filepath.Join(m.stateDir, "certificate", "enrollments"),
+		globalTrustDir: m.globalTrustDir,
+		krb5CCName:     filepath.Join(m.krb5CacheDir, objectName),
+		env:            os.Environ(),
+	}
+}
+
+// userEnrollmentsRecord returns the path of the record of the enrollments of username.
+func (m *Manager) userEnrollmentsRecord(username string) string {
+	return filepath.Join(m.stateDir, "certificate", "users", username)
+}
+
+// userScope returns the scope of username, which enrolls as the user, with the user ticket, in its home directory.
+// Root certificates are stored along the user certificates as users can't trust them system wide. The enrollments
+// record is kept in the state directory, so that the user can't tamper with the enrollments to clean up.
+func (m *Manager) userScope(ctx context.Context, username string) (s scope, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't prepare certificate enrollment of user %q", username))
+
+	u, err := m.userLookup(username)
+	if err != nil {
+		return scope{}, errors.New(gotext.Get("couldn't retrieve user for %q: %v", username, err))
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return scope{}, errors.New(gotext.Get("couldn't convert %q to a valid uid for %q", u.Uid, username))
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return scope{}, errors.New(gotext.Get("couldn't convert %q to a valid gid for %q", u.Gid, username))
+	}
+	// Only root can change the supplementary groups: they are dropped as the script doesn't need them.
+	cred := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), NoSetGroups: os.Geteuid() != 0}
+
+	// The copy of the user ticket in the run directory is only readable by root: use the ticket of the session.
+	krb5CCName, err := os.Readlink(filepath.Join(m.krb5CacheDir, "tracking", username))
+	if err != nil {
+		log.Warningf(ctx, "Could not find the kerberos ticket of %q, certificate enrollment may fail: %v", username, err)
+		krb5CCName = ""
+	}
+
+	env := slices.DeleteFunc(os.Environ(), func(e string) bool {
+		k, _, _ := strings.Cut(e, "=")
+		return k == "HOME" || k == "USER" || k == "LOGNAME"
+	})
+	env = append(env, "HOME="+u.HomeDir, "USER="+username, "LOGNAME="+username)
+
+	stateDir := filepath.Join(u.HomeDir, ".local", "share", "adsys")
+	return scope{
+		objectName:     username,
+		stateDir:       stateDir,
+		record:         m.userEnrollmentsRecord(username),
+		globalTrustDir: filepath.Join(stateDir, "ca-certificates"),
+		krb5CCName:     krb5CCName,
+		nssDB:          filepath.Join(u.HomeDir, ".pki", "nssdb"),
+		cred:           cred,
+		env:            env,
+	}, nil
+}
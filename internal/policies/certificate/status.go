@@ -0,0 +1,61 @@
+package certificate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// Status returns, for each enrollment of the machine, the certificates installed with their subject and expiry.
+// Certificate authorities are not listed.
+func (m *Manager) Status(ctx context.Context) (msg string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get certificate enrollment status"))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Debug(ctx, "Getting certificate enrollment status")
+
+	s := m.machineScope("")
+	enrollments, err := m.previousEnrollments(s)
+	if err != nil {
+		return "", err
+	}
+	if len(enrollments) == 0 {
+		return gotext.Get("Certificate autoenrollment never ran on this machine.") + "\n", nil
+	}
+
+	now := time.Now()
+	var out strings.Builder
+	for _, e := range enrollments {
+		certDir, _ := e.certDirs(s.stateDir)
+		certs, err := m.enrolledCertificates(certDir)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(&out, "%s\n", gotext.Get("Enrollment for %s:", e.description()))
+		if len(certs) == 0 {
+			fmt.Fprintf(&out, "  %s\n", gotext.Get("No certificate installed in %s", certDir))
+			continue
+		}
+		for _, c := range certs {
+			state := gotext.Get("valid")
+			if now.After(c.notAfter) {
+				state = gotext.Get("expired")
+			} else if now.Before(c.notBefore) {
+				state = gotext.Get("not yet valid")
+			}
+			fmt.Fprintf(&out, "  %s (%s)\n", filepath.Base(c.path), state)
+			fmt.Fprintf(&out, "    %s\n", gotext.Get("Subject: %s", c.subject))
+			fmt.Fprintf(&out, "    %s\n", gotext.Get("Not after: %s", c.notAfter.UTC().Format(time.RFC3339)))
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,198 @@
+package certificate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// templatesKey is the policy entry listing the certificate templates to enroll for.
+const templatesKey = "certificate-templates"
+
+// enrollment is a certificate enrollment of the machine or a user, for a single template.
+// An enrollment without template is for all the templates the object can enroll for.
+type enrollment struct {
+	Template string `yaml:"template,omitempty"`
+	// CertDir is the configured certificate store of the template, empty for the default one.
+	CertDir string `yaml:"cert_dir,omitempty"`
+}
+
+// description returns the name of the enrollment, as printed in logs and errors.
+func (e enrollment) description() string {
+	if e.Template == "" {
+		return gotext.Get("all templates")
+	}
+	return gotext.Get("template %q", e.Template)
+}
+
+// certDirs returns the directories storing the certificates and private keys of the enrollment.
+func (e enrollment) certDirs(stateDir string) (certDir, privateDir string) {
+	if e.Template == "" {
+		return filepath.Join(stateDir, "certs"), filepath.Join(stateDir, "private", "certs")
+	}
+	if e.CertDir != "" {
+		return e.CertDir, filepath.Join(e.CertDir, "private")
+	}
+	return filepath.Join(stateDir, "certs", e.Template), filepath.Join(stateDir, "private", "certs", e.Template)
+}
+
+// scriptArgs returns the arguments restricting the autoenrollment script to the enrollment.
+func (e enrollment) scriptArgs(stateDir string) []string {
+	if e.Template == "" {
+		return nil
+	}
+	certDir, privateDir := e.certDirs(stateDir)
+	return []string{"--template", e.Template, "--cert_dir", certDir, "--private_dir", privateDir}
+}
+
+// enrollmentsFromEntries returns the enrollments configured by the policy entries: one per listed template, each one
+// optionally followed by the directory storing its certificates, as in "Template=/path/to/dir".
+// If no template is listed, the machine enrolls for all the templates it can.
+func enrollmentsFromEntries(entries []entry.Entry) (enrollments []enrollment, err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid certificate templates"))
+
+	idx := slices.IndexFunc(entries, func(e entry.Entry) bool { return e.Key == templatesKey })
+	if idx == -1 || entries[idx].Disabled {
+		return []enrollment{{}}, nil
+	}
+
+	for _, line := range strings.Split(entries[idx].Value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, dir, _ := strings.Cut(line, "=")
+		name, dir = strings.TrimSpace(name), strings.TrimSpace(dir)
+		if name == "" || strings.ContainsAny(name, `/\ `) {
+			return nil, errors.New(gotext.Get("invalid template name %q", name))
+		}
+		if dir != "" && !filepath.IsAbs(dir) {
+			return nil, errors.New(gotext.Get("certificate directory %q of template %q must be an absolute path", dir, name))
+		}
+		if dir != "" {
+			dir = filepath.Clean(dir)
+		}
+		if slices.ContainsFunc(enrollments, func(e enrollment) bool { return e.Template == name }) {
+			return nil, errors.New(gotext.Get("template %q is listed multiple times", name))
+		}
+		enrollments = append(enrollments, enrollment{Template: name, CertDir: dir})
+	}
+
+	if len(enrollments) == 0 {
+		return []enrollment{{}}, nil
+	}
+	return enrollments, nil
+}
+
+// previousEnrollments returns the enrollments of the object of s when the policy was last applied.
+// Machines enrolled before enrollments were recorded have enrolled for all templates if the Samba cache exists.
+func (m *Manager) previousEnrollments(s scope) (enrollments []enrollment, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read previous certificate enrollments"))
+
+	d, err := os.ReadFile(s.record)
+	if errors.Is(err, os.ErrNotExist) {
+		if s.isUser() {
+			return nil, nil
+		}
+		if _, err := os.Stat(filepath.Join(s.stateDir, "samba")); err != nil && os.IsNotExist(err) {
+			return nil, nil
+		}
+		return []enrollment{{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(d, &enrollments); err != nil {
+		return nil, err
+	}
+	return enrollments, nil
+}
+
+// saveEnrollments records the enrollments of the object of s, or removes the record if there are none.
+func (m *Manager) saveEnrollments(s scope, enrollments []enrollment) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save certificate enrollments"))
+
+	p := s.record
+	if len(enrollments) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	d, err := yaml.Marshal(enrollments)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+".new", d, 0600); err != nil {
+		return err
+	}
+	return os.Rename(p+".new", p)
+}
+
+// unenroll unenrolls the object of s from enrollments, returning the ones which failed.
+// Enrollments for all templates are unenrolled last as this cleans up the Samba cache shared by all enrollments.
+func (m *Manager) unenroll(ctx context.Context, s scope, enrollments []enrollment, extraArgs ...string) (failed []enrollment, err error) {
+	enrollments = slices.Clone(enrollments)
+	slices.SortStableFunc(enrollments, func(a, b enrollment) int {
+		switch {
+		case a.Template == "" && b.Template != "":
+			return 1
+		case a.Template != "" && b.Template == "":
+			return -1
+		}
+		return 0
+	})
+
+	var errs []error
+	for _, e := range enrollments {
+		log.Debugf(ctx, "Unenrolling %s from %s", s.objectName, e.description())
+		args := append(e.scriptArgs(s.stateDir), extraArgs...)
+		if err := m.runScript(ctx, s, "unenroll", args...); err != nil {
+			errs = append(errs, errors.New(gotext.Get("can't unenroll from %s: %v", e.description(), err)))
+			failed = append(failed, e)
+		}
+	}
+	return failed, errors.Join(errs...)
+}
+
+// enroll enrolls the object of s for each enrollment, carrying on with the others if one fails.
+// Enrollments with a certificate in its renewal period are first unenrolled, as Samba doesn't request again
+// certificates already enrolled.
+func (m *Manager) enroll(ctx context.Context, s scope, enrollments []enrollment, extraArgs ...string) error {
+	var errs []error
+	for _, e := range enrollments {
+		args := append(e.scriptArgs(s.stateDir), extraArgs...)
+
+		certDir, _ := e.certDirs(s.stateDir)
+		renew, err := m.needsRenewal(ctx, certDir)
+		if err != nil {
+			errs = append(errs, errors.New(gotext.Get("can't enroll for %s: %v", e.description(), err)))
+			continue
+		}
+		if renew {
+			if err := m.runScript(ctx, s, "unenroll", args...); err != nil {
+				errs = append(errs, errors.New(gotext.Get("can't renew certificates of %s: %v", e.description(), err)))
+				continue
+			}
+		}
+
+		log.Debugf(ctx, "Enrolling %s for %s", s.objectName, e.description())
+		if err := m.runScript(ctx, s, "enroll", args...); err != nil {
+			errs = append(errs, errors.New(gotext.Get("can't enroll for %s: %v", e.description(), err)))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,188 @@
+package dconf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+)
+
+// Changes is the set of modifications that applying a policy would do to a dconf database.
+type Changes struct {
+	// Database is the name of the dconf database the changes apply to.
+	Database string
+
+	Added   []KeyChange
+	Changed []KeyChange
+	Removed []KeyChange
+
+	LocksAdded   []string
+	LocksRemoved []string
+
+	// Regenerated is the list of databases which would be recompiled by dconf update.
+	Regenerated []string
+}
+
+// KeyChange is a modification of a key in a dconf database.
+// Old is empty for added keys and New is empty for removed keys.
+type KeyChange struct {
+	Key string
+	Old string
+	New string
+}
+
+// IsEmpty returns true if there is no change to apply.
+func (c Changes) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Changed) == 0 && len(c.Removed) == 0 &&
+		len(c.LocksAdded) == 0 && len(c.LocksRemoved) == 0 && len(c.Regenerated) == 0
+}
+
+// String returns a human readable diff of the changes.
+func (c Changes) String() string {
+	if c.IsEmpty() {
+		return gotext.Get("No dconf change for database %s.", c.Database) + "\n"
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, gotext.Get("dconf changes for database %s:", c.Database))
+	for _, k := range c.Added {
+		fmt.Fprintf(&out, "+ %s=%s\n", k.Key, k.New)
+	}
+	for _, k := range c.Changed {
+		fmt.Fprintf(&out, "~ %s=%s -> %s\n", k.Key, k.Old, k.New)
+	}
+	for _, k := range c.Removed {
+		fmt.Fprintf(&out, "- %s=%s\n", k.Key, k.Old)
+	}
+	for _, l := range c.LocksAdded {
+		fmt.Fprintf(&out, "+ %s\n", gotext.Get("lock %s", l))
+	}
+	for _, l := range c.LocksRemoved {
+		fmt.Fprintf(&out, "- %s\n", gotext.Get("lock %s", l))
+	}
+	if len(c.Regenerated) > 0 {
+		fmt.Fprintln(&out, gotext.Get("Databases to regenerate: %s", strings.Join(c.Regenerated, ", ")))
+	}
+
+	return out.String()
+}
+
+// computeChanges compares the keyfile and locks content we want to write with the ones currently installed.
+func computeChanges(objectName, dbsPath string, isComputer bool, defaultPath, dataContent, locksPath, locksContent string) (changes Changes, err error) {
+	changes.Database = objectName
+
+	oldKeys, err := readKeyfile(defaultPath)
+	if err != nil {
+		return Changes{}, err
+	}
+	newKeys, err := parseKeyfile(dataContent)
+	if err != nil {
+		return Changes{}, err
+	}
+	for _, k := range sortedKeys(newKeys) {
+		old, ok := oldKeys[k]
+		if !ok {
+			changes.Added = append(changes.Added, KeyChange{Key: k, New: newKeys[k]})
+			continue
+		}
+		if old != newKeys[k] {
+			changes.Changed = append(changes.Changed, KeyChange{Key: k, Old: old, New: newKeys[k]})
+		}
+	}
+	for _, k := range sortedKeys(oldKeys) {
+		if _, ok := newKeys[k]; !ok {
+			changes.Removed = append(changes.Removed, KeyChange{Key: k, Old: oldKeys[k]})
+		}
+	}
+
+	oldLocks, err := readLines(locksPath)
+	if err != nil {
+		return Changes{}, err
+	}
+	newLocks := strings.Fields(locksContent)
+	for _, l := range newLocks {
+		if !slices.Contains(oldLocks, l) {
+			changes.LocksAdded = append(changes.LocksAdded, l)
+		}
+	}
+	for _, l := range oldLocks {
+		if !slices.Contains(newLocks, l) {
+			changes.LocksRemoved = append(changes.LocksRemoved, l)
+		}
+	}
+	slices.Sort(changes.LocksAdded)
+	slices.Sort(changes.LocksRemoved)
+
+	// dconf update recompiles databases which keyfiles changed, or which compiled versions are missing.
+	contentChanged := len(changes.Added) > 0 || len(changes.Changed) > 0 || len(changes.Removed) > 0 ||
+		len(changes.LocksAdded) > 0 || len(changes.LocksRemoved) > 0
+	if contentChanged || dconfNeedsUpdate(filepath.Join(dbsPath, objectName)) {
+		changes.Regenerated = append(changes.Regenerated, objectName)
+	}
+	if !isComputer && dconfNeedsUpdate(filepath.Join(dbsPath, "machine")) {
+		changes.Regenerated = append(changes.Regenerated, "machine")
+	}
+
+	return changes, nil
+}
+
+// readKeyfile returns the keys and values of a dconf keyfile, indexed by their full path.
+// A missing file is considered empty.
+func readKeyfile(path string) (map[string]string, error) {
+	d, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseKeyfile(string(d))
+}
+
+// parseKeyfile returns the keys and values of a dconf keyfile content, indexed by their full path.
+func parseKeyfile(content string) (map[string]string, error) {
+	keys := make(map[string]string)
+	var section string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		l := strings.TrimSpace(scanner.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		if strings.HasPrefix(l, "[") && strings.HasSuffix(l, "]") {
+			section = strings.Trim(l, "[]")
+			continue
+		}
+		k, v, found := strings.Cut(l, "=")
+		if !found {
+			return nil, errors.New(gotext.Get("invalid keyfile line %q", l))
+		}
+		keys[fmt.Sprintf("/%s/%s", section, k)] = v
+	}
+	return keys, scanner.Err()
+}
+
+// readLines returns non empty lines of path. A missing file is considered empty.
+func readLines(path string) ([]string, error) {
+	d, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(d)), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
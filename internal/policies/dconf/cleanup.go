@@ -0,0 +1,167 @@
+package dconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// protectedDBs are the databases which are never removed as orphaned.
+var protectedDBs = []string{"user", "machine"}
+
+// CleanupOrphaned removes the databases generated by adsys which don't correspond to any of the active
+// objects anymore. Keyfiles generated by adsys, their compiled databases and adsys entries in the
+// corresponding profiles are removed, then dconf update is run once if anything changed.
+// Databases which were not generated by adsys, as well as the default user and machine databases, are
+// never removed.
+// It returns the list of removed databases.
+func (m *Manager) CleanupOrphaned(ctx context.Context, activeObjects []string) (removed []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't clean up orphaned dconf databases"))
+
+	dconfDir := m.dconfDir
+	if dconfDir == "" {
+		dconfDir = consts.DefaultDconfDir
+	}
+
+	// No policy can be applied while we are removing databases.
+	m.dconfMu.Lock()
+	defer m.dconfMu.Unlock()
+
+	log.Debug(ctx, "Cleaning up orphaned dconf databases")
+
+	dbsPath := filepath.Join(dconfDir, "db")
+	profilesPath := filepath.Join(dconfDir, "profile")
+
+	dirs, err := os.ReadDir(dbsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, d := range dirs {
+		name, found := strings.CutSuffix(d.Name(), ".d")
+		if !found || !d.IsDir() {
+			continue
+		}
+		// Layers belong to the object they are stacked in the profile of.
+		object := name
+		if m := layerDBRe.FindStringSubmatch(name); m != nil {
+			object = m[1]
+		}
+		if slices.Contains(protectedDBs, object) || slices.Contains(activeObjects, object) {
+			continue
+		}
+
+		// Only consider databases generated by adsys.
+		if _, err := os.Stat(filepath.Join(dbsPath, d.Name(), "adsys")); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := removeDB(dbsPath, name); err != nil {
+			return nil, err
+		}
+		if err := removeFromProfile(profilesPath, object, name); err != nil {
+			return nil, err
+		}
+		log.Infof(ctx, gotext.Get("Removed orphaned dconf database %s", name))
+		removed = append(removed, name)
+	}
+
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	// Update once for all removed databases, without waiting for any debounce delay.
+	m.updateMu.Lock()
+	m.markUpdatePending()
+	m.updateMu.Unlock()
+	if err := m.FlushUpdates(ctx); err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+// removeDB removes the adsys keyfile and locks of the database name.
+// The keyfile directory and the compiled database are only removed if no other keyfile remains.
+func removeDB(dbsPath, name string) (err error) {
+	dbPath := filepath.Join(dbsPath, name+".d")
+	for _, p := range []string{filepath.Join(dbPath, "adsys"), filepath.Join(dbPath, "locks", "adsys")} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	for _, p := range []string{filepath.Join(dbPath, "locks"), dbPath} {
+		empty, err := isEmptyDir(p)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			// Other keyfiles remain: dconf update will recompile the database without our content.
+			return nil
+		}
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dbsPath, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// removeFromProfile removes the adsys database db and the machine one from the profile of object.
+// The profile is deleted if it only contained what adsys generated.
+func removeFromProfile(profilesPath, object, db string) (err error) {
+	profilePath := filepath.Join(profilesPath, object)
+	content, err := os.ReadFile(profilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var out []string
+	for _, l := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if l == "system-db:machine" || l == fmt.Sprintf("system-db:%s", db) {
+			continue
+		}
+		out = append(out, l)
+	}
+
+	if len(out) == 0 || (len(out) == 1 && out[0] == "user-db:user") {
+		return os.Remove(profilePath)
+	}
+
+	//nolint:gosec // G306 - This asset needs to be world-readable.
+	if err := os.WriteFile(profilePath+".adsys.new", []byte(strings.Join(out, "\n")), 0644); err != nil {
+		return err
+	}
+	return os.Rename(profilePath+".adsys.new", profilePath)
+}
+
+// isEmptyDir returns true if p is an empty directory. A missing directory is considered empty.
+func isEmptyDir(p string) (bool, error) {
+	entries, err := os.ReadDir(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
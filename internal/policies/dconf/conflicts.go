@@ -0,0 +1,76 @@
+package dconf
+
+import (
+	"strings"
+
+	"github.com/ubuntu/adsys/internal/policies/entry"
+)
+
+// SourcedEntry is a dconf entry tagged with the name of the GPO defining it.
+type SourcedEntry struct {
+	entry.Entry
+	GPO string
+}
+
+// Conflict is a key set to different values by multiple GPOs.
+type Conflict struct {
+	Key string
+	// Value and Disabled are the resolved state of the key, from the GPO with the highest precedence.
+	Value    string
+	Disabled bool
+	GPO      string
+	// Overridden lists the other GPOs setting the key differently, by decreasing precedence.
+	Overridden []string
+}
+
+// FindConflicts returns the keys that multiple GPOs set to different values.
+// entries are expected in GPO precedence order: the first entry for a key is the one applied.
+// Keys with an append strategy are merged between GPOs and are thus never reported.
+func FindConflicts(entries []SourcedEntry) []Conflict {
+	var keys []string
+	first := make(map[string]SourcedEntry)
+	conflicts := make(map[string]*Conflict)
+	for _, e := range entries {
+		if e.Strategy == entry.StrategyAppend {
+			continue
+		}
+
+		winner, ok := first[e.Key]
+		if !ok {
+			first[e.Key] = e
+			keys = append(keys, e.Key)
+			continue
+		}
+		if e.GPO == winner.GPO || sameValue(winner.Entry, e.Entry) {
+			continue
+		}
+
+		c, ok := conflicts[e.Key]
+		if !ok {
+			c = &Conflict{
+				Key:      e.Key,
+				Value:    winner.Value,
+				Disabled: winner.Disabled,
+				GPO:      winner.GPO,
+			}
+			conflicts[e.Key] = c
+		}
+		c.Overridden = append(c.Overridden, e.GPO)
+	}
+
+	var r []Conflict
+	for _, k := range keys {
+		if c, ok := conflicts[k]; ok {
+			r = append(r, *c)
+		}
+	}
+	return r
+}
+
+// sameValue returns if a and b result in the same key state.
+func sameValue(a, b entry.Entry) bool {
+	if a.Disabled || b.Disabled {
+		return a.Disabled == b.Disabled
+	}
+	return strings.TrimSpace(a.Value) == strings.TrimSpace(b.Value)
+}
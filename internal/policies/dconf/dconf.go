@@ -23,6 +23,27 @@
 // -> the lock will "stick" the desired value to the layer of current value of Machine. As machine doesn’t have any
 // value and is the lowest in the stack (the first one to be processed), this will thus enforce the default system
 // configuration for that setting.
+//
+// Any key is locked by default, whether a value is set or not. An entry can override this with its Lock
+// flag: a key can thus be set to a value without being locked, so that users can still change it.
+//
+// User policies can also be applied as layers, one per GPO, in precedence order. The first layer is written to
+// system-db:<username> and the next ones to system-db:<username>-layer<N>, all stacked in the user profile.
+// A key is only written in the first layer setting it and is locked there if any layer locks it.
+//
+// Relocatable schemas do not have a fixed path, so their entries need to carry the path they are
+// bound to explicitly. Those keys have the form <schema>[<path>]/<key>, where schema is the
+// relocatable schema id and path is the absolute dconf path, starting and ending with a slash.
+// The key stanza is then written under that path and the schema is checked against the list of
+// installed relocatable schemas.
+//
+// Values of enum and flags keys are validated against the members declared in the installed GSettings
+// schemas. Keys which are not part of any installed schema are written as is.
+//
+// Some keys have a localized default value in their schema. When a user policy sets such a key to its
+// untranslated default, the value is translated to the language configured in the user account, so that
+// users get the default in their own language, as GSettings would do. Users without any language set
+// use the C locale and get the value untranslated, as well as the machine policy.
 package dconf
 
 import (
@@ -35,13 +56,19 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/audit"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/fileutils"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/smbsafe"
@@ -56,18 +83,159 @@ type Manager struct {
 	// dconfUpdateMu prevents running multiple dconf update processes in parallel.
 	dconfUpdateMu sync.Mutex
 
-	dconfDir string
+	// updateMu protects the pending update state below.
+	updateMu       sync.Mutex
+	updatePending  *pendingUpdate
+	updateTimer    *time.Timer
+	updateDebounce time.Duration
+
+	// schemasMu protects the cached indexes of the installed schemas below.
+	schemasMu sync.Mutex
+	schemas   map[string]cachedSchemaIndex
+
+	dconfDir     string
+	schemasDir   string
+	localeDir    string
+	accountsDir  string
+	gsettingsCmd []string
+	runCmd       commandRunner
+}
+
+// commandRunner runs a command and returns its combined output.
+type commandRunner func(name string, args ...string) ([]byte, error)
+
+type options struct {
+	schemasDir     string
+	localeDir      string
+	accountsDir    string
+	gsettingsCmd   []string
+	updateDebounce time.Duration
+	runCmd         commandRunner
+}
+
+// Option represents an optional function to change the dconf manager.
+type Option func(*options)
+
+// WithGsettingsCmd overrides the default gsettings command.
+func WithGsettingsCmd(cmd []string) Option {
+	return func(o *options) {
+		o.gsettingsCmd = cmd
+	}
+}
+
+// WithSchemasDir overrides the directory of installed GSettings schemas, used to validate values.
+func WithSchemasDir(dir string) Option {
+	return func(o *options) {
+		o.schemasDir = dir
+	}
+}
+
+// WithLocaleDir overrides the directory of installed message catalogs, used to translate localized defaults.
+func WithLocaleDir(dir string) Option {
+	return func(o *options) {
+		o.localeDir = dir
+	}
+}
+
+// WithAccountsServiceDir overrides the directory of AccountsService user settings, used to get the user language.
+func WithAccountsServiceDir(dir string) Option {
+	return func(o *options) {
+		o.accountsDir = dir
+	}
+}
+
+// WithUpdateDebounce delays dconf update after applying a policy, so that all policies applied
+// during that delay only trigger one update. The default, 0, updates synchronously on each apply.
+func WithUpdateDebounce(d time.Duration) Option {
+	return func(o *options) {
+		o.updateDebounce = d
+	}
+}
+
+// WithCommandRunner overrides how the dconf update command is run.
+func WithCommandRunner(runner func(name string, args ...string) ([]byte, error)) Option {
+	return func(o *options) {
+		o.runCmd = runner
+	}
 }
 
 // NewWithDconfDir creates a manager with a specific dconf directory.
-func NewWithDconfDir(dir string) *Manager {
-	return &Manager{dconfDir: dir}
+func NewWithDconfDir(dir string, opts ...Option) *Manager {
+	args := options{}
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		dconfDir:       dir,
+		schemasDir:     args.schemasDir,
+		localeDir:      args.localeDir,
+		accountsDir:    args.accountsDir,
+		gsettingsCmd:   args.gsettingsCmd,
+		updateDebounce: args.updateDebounce,
+		runCmd:         args.runCmd,
+	}
 }
 
 // ApplyPolicy generates a dconf computer or user policy based on a list of entries.
 func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't apply dconf policy to %s", objectName))
 
+	_, err = m.applyPolicy(ctx, objectName, isComputer, [][]entry.Entry{entries}, false)
+	return err
+}
+
+// ApplyLayeredPolicy generates a dconf computer or user policy based on layers of entries, in precedence order,
+// highest first.
+// Each layer of a user gets its own database, stacked in that order in the user profile: the first layer is the
+// user database and the following ones are named <user>-layer<N>. A key set by multiple layers is only written in
+// the one with the highest precedence, and is locked there if any layer locks it. Layers of the machine are all
+// written in the machine database.
+func (m *Manager) ApplyLayeredPolicy(ctx context.Context, objectName string, isComputer bool, layers [][]entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply dconf policy to %s", objectName))
+
+	_, err = m.applyPolicy(ctx, objectName, isComputer, layers, false)
+	return err
+}
+
+// DryRunPolicy computes the changes that ApplyPolicy would do to the dconf database of objectName.
+// The changes are computed against the currently installed keyfiles. Nothing is written on disk and
+// dconf update is not run.
+func (m *Manager) DryRunPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (changes Changes, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't compute dconf policy changes for %s", objectName))
+
+	return m.applyPolicy(ctx, objectName, isComputer, [][]entry.Entry{entries}, true)
+}
+
+// SimulatePolicy returns the keyfile and locks ApplyPolicy would write in the dconf database of objectName, by path.
+// Nothing is read from nor written to the dconf directory, and dconf update is not run.
+func (m *Manager) SimulatePolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (files map[string]string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't simulate dconf policy for %s", objectName))
+
+	dconfDir := m.dconfDir
+	if dconfDir == "" {
+		dconfDir = consts.DefaultDconfDir
+	}
+
+	db := objectName
+	if isComputer {
+		db = "machine"
+	}
+	dataContent, locksContent, err := m.generateDB(ctx, objectName, isComputer, slices.Concat(resolveLayers([][]entry.Entry{entries})...))
+	if err != nil {
+		return nil, err
+	}
+
+	dbPath := filepath.Join(dconfDir, "db", db+".d")
+	return map[string]string{
+		filepath.Join(dbPath, "adsys"):          dataContent,
+		filepath.Join(dbPath, "locks", "adsys"): locksContent,
+	}, nil
+}
+
+// applyPolicy generates the dconf keyfiles and locks of each layer of entries and commits them on disk.
+// If dryRun is true, it only returns the changes which would be done to a single database merging all layers.
+func (m *Manager) applyPolicy(ctx context.Context, objectName string, isComputer bool, layers [][]entry.Entry, dryRun bool) (changes Changes, err error) {
 	dconfDir := m.dconfDir
 	if dconfDir == "" {
 		dconfDir = consts.DefaultDconfDir
@@ -88,56 +256,172 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 
 	log.Debugf(ctx, "Applying dconf policy to %s", objectName)
 
+	user := objectName
 	if isComputer {
 		objectName = "machine"
 	}
 	profilesPath := filepath.Join(dconfDir, "profile")
 	dbsPath := filepath.Join(dconfDir, "db")
-	dbPath := filepath.Join(dbsPath, objectName+".d")
 
 	if !isComputer {
 		if _, err := os.Stat(filepath.Join(dbsPath, "machine.d", "locks", "adsys")); err != nil {
-			return errors.New(gotext.Get("machine dconf database is required before generating a policy for an user. This one returns: %v", err))
+			return Changes{}, errors.New(gotext.Get("machine dconf database is required before generating a policy for an user. This one returns: %v", err))
 		}
 	}
 
+	layers = resolveLayers(layers)
+	// The machine database is not layered, neither are computed changes.
+	if isComputer || dryRun {
+		layers = [][]entry.Entry{slices.Concat(layers...)}
+	}
+	// Users always have their own database, even without any entry.
+	if len(layers) == 0 {
+		layers = [][]entry.Entry{nil}
+	}
+	dbs := make([]string, 0, len(layers))
+	for i := range layers {
+		dbs = append(dbs, layerDB(objectName, i))
+	}
+
 	// Create profiles for users only
-	if !isComputer {
+	if !isComputer && !dryRun {
 		//nolint:gosec // G301 - Profile must be readable by everyone
 		if err := os.MkdirAll(profilesPath, 0755); err != nil {
-			return err
+			return Changes{}, err
 		}
-		if err := writeProfile(ctx, objectName, profilesPath); err != nil {
-			return err
+		if err := writeProfile(ctx, objectName, dbs, profilesPath); err != nil {
+			return Changes{}, err
+		}
+	}
+
+	// Generate defaults and locks content of each database from policy
+	dataContents := make([]string, 0, len(layers))
+	locksContents := make([]string, 0, len(layers))
+	for _, entries := range layers {
+		dataContent, locksContent, err := m.generateDB(ctx, user, isComputer, entries)
+		if err != nil {
+			return Changes{}, err
+		}
+		dataContents = append(dataContents, dataContent)
+		locksContents = append(locksContents, locksContent)
+	}
+
+	if dryRun {
+		dbPath := filepath.Join(dbsPath, objectName+".d")
+		return computeChanges(objectName, dbsPath, isComputer, filepath.Join(dbPath, "adsys"), dataContents[0], filepath.Join(dbPath, "locks", "adsys"), locksContents[0])
+	}
+
+	var needsRefresh bool
+
+	// Commit on disk
+	for i, db := range dbs {
+		changed, err := writeDB(ctx, dbsPath, db, dataContents[i], locksContents[i])
+		if err != nil {
+			return Changes{}, err
+		}
+		needsRefresh = needsRefresh || changed
+	}
+	if !isComputer {
+		removed, err := removeStaleLayers(dbsPath, objectName, len(dbs))
+		if err != nil {
+			return Changes{}, err
 		}
+		needsRefresh = needsRefresh || removed
 	}
 
+	// update if any profile changed, or if any compiled db is missing
+	needsRefresh = needsRefresh || dconfNeedsUpdate(filepath.Join(dbsPath, "machine"))
+	if !isComputer {
+		for _, db := range dbs {
+			needsRefresh = needsRefresh || dconfNeedsUpdate(filepath.Join(dbsPath, db))
+		}
+	}
+	if !needsRefresh {
+		return Changes{}, nil
+	}
+
+	if err := m.requestUpdate(ctx); err != nil {
+		return Changes{}, err
+	}
+
+	return Changes{}, nil
+}
+
+// generateDB returns the keyfile and locks content of a database of user from entries.
+func (m *Manager) generateDB(ctx context.Context, user string, isComputer bool, entries []entry.Entry) (dataContent, locksContent string, err error) {
 	// Generate defaults and locks content from policy
 	dataWithGroups := make(map[string][]string)
 	var locks []string
 	var errMsgs []string
+	// relocatableSchemas, schemas and tr are nil until we first need them.
+	var relocatableSchemas []string
+	var schemas *schemaIndex
+	var tr *translator
 	for _, e := range entries {
 		log.Debugf(ctx, "Analyzing entry %+v", e)
 
-		if !e.Disabled {
-			section := filepath.Dir(e.Key)
+		section, key, schema, err := parseKey(e.Key)
+		if err != nil {
+			errMsgs = append(errMsgs, gotext.Get("- error on %s: %v", e.Key, err))
+			continue
+		}
+		lock := "/" + e.Key
+		if schema != "" {
+			lock = fmt.Sprintf("/%s/%s", section, key)
+			// Only query installed schemas once per database, and if we need them.
+			if relocatableSchemas == nil {
+				if relocatableSchemas, err = m.listRelocatableSchemas(ctx); err != nil {
+					return "", "", err
+				}
+			}
+			if !slices.Contains(relocatableSchemas, schema) {
+				errMsgs = append(errMsgs, gotext.Get("- error on %s: %s is not an installed relocatable schema", e.Key, schema))
+				continue
+			}
+		}
 
+		if !e.Disabled {
 			// normalize common user error cases and check gsettings schema signature match.
-			e.Value = normalizeValue(e.Meta, e.Value)
+			if e.Value, err = normalizeValue(e.Meta, e.Value); err != nil {
+				errMsgs = append(errMsgs, gotext.Get("- error on %s: %v", e.Key, err))
+				continue
+			}
 			if err := checkSignature(e.Meta, e.Value); err != nil {
 				errMsgs = append(errMsgs, gotext.Get("- error on %s: %v", e.Key, err))
 				continue
 			}
+			// check enum and flags members against the installed schemas.
+			if schemas == nil {
+				schemasDir := m.schemasDir
+				if schemasDir == "" {
+					schemasDir = consts.DefaultGSettingsSchemasDir
+				}
+				idx := m.installedSchemas(ctx, schemasDir)
+				schemas = &idx
+			}
+			if err := schemas.validate(section, key, schema, e.Meta, e.Value); err != nil {
+				errMsgs = append(errMsgs, gotext.Get("- error on %s: %v", e.Key, err))
+				continue
+			}
+			// translate localized defaults to the user language.
+			if tr == nil {
+				tr = m.newTranslator(ctx, user, isComputer)
+			}
+			e.Value = schemas.localize(ctx, tr, section, key, schema, e.Value)
 
-			l := fmt.Sprintf("%s=%s", filepath.Base(e.Key), e.Value)
+			l := fmt.Sprintf("%s=%s", key, e.Value)
 			dataWithGroups[section] = append(dataWithGroups[section], l)
 		}
-		locks = append(locks, "/"+e.Key)
+		// Keys are locked by default, unless the entry explicitly requests otherwise.
+		if e.Lock != nil && !*e.Lock {
+			continue
+		}
+		locks = append(locks, lock)
 	}
 
 	// Stop on any error
 	if errMsgs != nil {
-		return errors.New(strings.Join(errMsgs, "\n"))
+		return "", "", errors.New(strings.Join(errMsgs, "\n"))
 	}
 
 	// Prepare file contents
@@ -153,82 +437,334 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		data = append(data, dataWithGroups[s]...)
 	}
 
-	var needsRefresh bool
+	dataContent = strings.Join(data, "\n") + "\n"
+	locksContent = strings.Join(locks, "\n") + "\n"
+	return dataContent, locksContent, nil
+}
+
+// writeDB writes the keyfile and locks of the adsys database db, if they changed.
+// It returns true if any of them changed. The written files are recorded in the audit recorder of ctx, if any.
+func writeDB(ctx context.Context, dbsPath, db, dataContent, locksContent string) (changed bool, err error) {
+	dbPath := filepath.Join(dbsPath, db+".d")
 
-	// Commit on disk
 	//nolint:gosec // G301 - Locks must be readable by everyone
 	if err := os.MkdirAll(filepath.Join(dbPath, "locks"), 0755); err != nil {
-		return err
+		return false, err
 	}
 
-	defaultPath := filepath.Join(dbPath, "adsys")
-	changed, err := writeIfChanged(defaultPath, strings.Join(data, "\n")+"\n")
+	//nolint:gosec // G306 - This asset needs to be world-readable.
+	dataChanged, err := fileutils.WriteIfChanged(filepath.Join(dbPath, "adsys"), []byte(dataContent), 0644)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if dataChanged {
+		audit.RecordFile(ctx, filepath.Join(dbPath, "adsys"))
 	}
-	needsRefresh = needsRefresh || changed
 
-	locksPath := filepath.Join(dbPath, "locks", "adsys")
-	changed, err = writeIfChanged(locksPath, strings.Join(locks, "\n")+"\n")
+	//nolint:gosec // G306 - This asset needs to be world-readable.
+	locksChanged, err := fileutils.WriteIfChanged(filepath.Join(dbPath, "locks", "adsys"), []byte(locksContent), 0644)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if locksChanged {
+		audit.RecordFile(ctx, filepath.Join(dbPath, "locks", "adsys"))
 	}
-	needsRefresh = needsRefresh || changed
 
-	// update if any profile changed, or if any compiled db is missing
-	needsRefresh = needsRefresh || dconfNeedsUpdate(filepath.Join(dbsPath, "machine"))
-	if !isComputer {
-		needsRefresh = needsRefresh || dconfNeedsUpdate(filepath.Join(dbsPath, objectName))
+	return dataChanged || locksChanged, nil
+}
+
+// layerDB returns the name of the database of the layer i of objectName. The first layer is the object database.
+func layerDB(objectName string, i int) string {
+	if i == 0 {
+		return objectName
 	}
-	if !needsRefresh {
+	return fmt.Sprintf("%s-layer%d", objectName, i+1)
+}
+
+// layerDBRe matches the databases of the layers following the first one, capturing the object name and the layer.
+var layerDBRe = regexp.MustCompile(`^(.+)-layer(\d+)$`)
+
+// resolveLayers returns layers without the keys already set by a layer of higher precedence. A key locked by any
+// layer is locked in the layer setting it, so that the lock applies to the value with the highest precedence.
+// Empty layers are removed.
+func resolveLayers(layers [][]entry.Entry) (resolved [][]entry.Entry) {
+	locked := make(map[string]bool)
+	for _, entries := range layers {
+		for _, e := range entries {
+			if e.Lock == nil || *e.Lock {
+				locked[e.Key] = true
+			}
+		}
+	}
+
+	seen := make(map[string]struct{})
+	for _, entries := range layers {
+		var layer []entry.Entry
+		for _, e := range entries {
+			if _, ok := seen[e.Key]; ok {
+				continue
+			}
+			seen[e.Key] = struct{}{}
+			if locked[e.Key] {
+				lock := true
+				e.Lock = &lock
+			}
+			layer = append(layer, e)
+		}
+		if len(layer) > 0 {
+			resolved = append(resolved, layer)
+		}
+	}
+	return resolved
+}
+
+// removeStaleLayers removes the adsys databases of the layers of objectName from layer n onwards, which were
+// generated by a previous policy with more layers. It returns true if any database was removed.
+func removeStaleLayers(dbsPath, objectName string, n int) (removed bool, err error) {
+	dirs, err := os.ReadDir(dbsPath)
+	if err != nil {
+		return false, err
+	}
+	for _, d := range dirs {
+		name, found := strings.CutSuffix(d.Name(), ".d")
+		if !found || !d.IsDir() {
+			continue
+		}
+		m := layerDBRe.FindStringSubmatch(name)
+		if m == nil || m[1] != objectName {
+			continue
+		}
+		if layer, err := strconv.Atoi(m[2]); err != nil || layer <= n {
+			continue
+		}
+		if err := removeDB(dbsPath, name); err != nil {
+			return false, err
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
+// pendingUpdate is a dconf update requested by one or more policy applications. done is closed once it ran, with
+// its error in err.
+type pendingUpdate struct {
+	done chan struct{}
+	err  error
+}
+
+// requestUpdate marks the dconf databases as needing an update.
+// The update is run right away, unless a debounce delay is set: it is then only run once no other
+// update was requested during that delay, or when FlushUpdates is called.
+func (m *Manager) requestUpdate(ctx context.Context) error {
+	m.updateMu.Lock()
+	m.markUpdatePending()
+	if m.updateDebounce <= 0 {
+		m.updateMu.Unlock()
+		return m.FlushUpdates(ctx)
+	}
+	defer m.updateMu.Unlock()
+
+	log.Debugf(ctx, "Delaying dconf update by %s", m.updateDebounce)
+	if m.updateTimer != nil {
+		m.updateTimer.Stop()
+	}
+	m.updateTimer = time.AfterFunc(m.updateDebounce, func() {
+		if err := m.FlushUpdates(context.Background()); err != nil {
+			log.Warning(context.Background(), err)
+		}
+	})
+	return nil
+}
+
+// markUpdatePending records that the dconf databases need an update, if no update is pending yet.
+// updateMu must be held.
+func (m *Manager) markUpdatePending() {
+	if m.updatePending == nil {
+		m.updatePending = &pendingUpdate{done: make(chan struct{})}
+	}
+}
+
+// FlushUpdates runs any pending dconf update right away and waits for it to complete.
+// The daemon calls it once a batch of policies is applied.
+func (m *Manager) FlushUpdates(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't update dconf databases"))
+
+	// Take the update lock first, so that we wait for any update in progress.
+	m.dconfUpdateMu.Lock()
+	defer m.dconfUpdateMu.Unlock()
+
+	m.updateMu.Lock()
+	if m.updateTimer != nil {
+		m.updateTimer.Stop()
+		m.updateTimer = nil
+	}
+	pending := m.updatePending
+	m.updatePending = nil
+	m.updateMu.Unlock()
+
+	if pending == nil {
 		return nil
 	}
+	defer func() {
+		pending.err = err
+		close(pending.done)
+	}()
 
-	// request an update now that we released the read lock
-	// we will call update multiple times.
+	return m.update(ctx)
+}
+
+// WaitUpdates waits for the pending dconf update, if any, to run once its debounce delay elapsed or it is flushed,
+// and returns its error. Other policy applications can request the same update meanwhile, so that each of them gets
+// its compiled databases from a single dconf update.
+func (m *Manager) WaitUpdates(ctx context.Context) error {
+	m.updateMu.Lock()
+	pending := m.updatePending
+	m.updateMu.Unlock()
+
+	if pending == nil {
+		return nil
+	}
+	select {
+	case <-pending.done:
+		return pending.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update runs dconf update on the dconf databases.
+func (m *Manager) update(ctx context.Context) error {
+	dconfDir := m.dconfDir
+	if dconfDir == "" {
+		dconfDir = consts.DefaultDconfDir
+	}
+
+	runCmd := m.runCmd
+	if runCmd == nil {
+		runCmd = execCommand
+	}
+
+	log.Debug(ctx, "Updating dconf databases")
 	smbsafe.WaitExec()
-	m.dconfUpdateMu.Lock()
-	// #nosec G204 - we control the input
-	out, errExec := exec.Command("dconf", "update", filepath.Join(dconfDir, "db")).CombinedOutput()
-	m.dconfUpdateMu.Unlock()
+	out, errExec := runCmd("dconf", "update", filepath.Join(dconfDir, "db"))
 	smbsafe.DoneExec()
 	if errExec != nil {
-		err = errors.New(gotext.Get("dconf update failed: %v", out))
+		log.Debugf(ctx, "dconf update output:\n%s", out)
+		return errors.New(gotext.Get("dconf update failed (%v): %s", errExec, summarizeOutput(out)))
 	}
 
 	return nil
 }
 
-// writeIfChanged will only write to path if content is different from current content.
-func writeIfChanged(path string, content string) (done bool, err error) {
-	defer decorate.OnError(&err, gotext.Get("can't save %s", path))
+const (
+	// maxOutputLines is the maximum number of lines of a command output reported in errors.
+	maxOutputLines = 5
+	// maxOutputLength is the maximum length of a command output reported in errors.
+	maxOutputLength = 512
+)
 
-	if oldContent, err := os.ReadFile(path); err == nil && string(oldContent) == content {
-		return false, nil
+// summarizeOutput returns the first non empty lines of out, truncated to a reasonable length
+// to be part of an error message.
+func summarizeOutput(out []byte) string {
+	var lines []string
+	var truncated bool
+	for _, l := range strings.Split(string(out), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if len(lines) == maxOutputLines {
+			truncated = true
+			break
+		}
+		lines = append(lines, l)
+	}
+	if len(lines) == 0 {
+		return gotext.Get("no output")
 	}
 
-	//nolint:gosec // G306 - This asset needs to be world-readable.
-	if err := os.WriteFile(path+".new", []byte(content), 0644); err != nil {
-		return false, err
+	r := strings.Join(lines, "\n")
+	if len(r) > maxOutputLength {
+		// Don't cut in the middle of a multi-bytes character.
+		r = strings.ToValidUTF8(r[:maxOutputLength], "")
+		truncated = true
 	}
-	if err := os.Rename(path+".new", path); err != nil {
-		return false, err
+	if truncated {
+		r += "\n…"
 	}
+	return r
+}
 
-	return true, nil
+// execCommand runs the command and returns its combined output.
+func execCommand(name string, args ...string) ([]byte, error) {
+	// #nosec G204 - we control the input
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+// relocatableKeyRe matches keys of the form <schema>[<path>]/<key>.
+var relocatableKeyRe = regexp.MustCompile(`^([^/\[\]]+)\[([^\]]*)\]/([^/]+)$`)
+
+// parseKey returns the dconf section and key name of an entry key.
+// If the key is bound to a relocatable schema, the schema id is returned too and the section is
+// derived from the explicit path.
+func parseKey(k string) (section, key, schema string, err error) {
+	if !strings.Contains(k, "[") {
+		return filepath.Dir(k), filepath.Base(k), "", nil
+	}
+
+	m := relocatableKeyRe.FindStringSubmatch(k)
+	if m == nil {
+		return "", "", "", errors.New(gotext.Get("invalid relocatable key format, expected <schema>[<path>]/<key>"))
+	}
+	schema, path, key := m[1], m[2], m[3]
+	if path == "" {
+		return "", "", "", errors.New(gotext.Get("relocatable schema %s requires a path", schema))
+	}
+	if !strings.HasPrefix(path, "/") || !strings.HasSuffix(path, "/") || strings.Contains(path, "//") {
+		return "", "", "", errors.New(gotext.Get("invalid path %q for relocatable schema %s: it must start and end with a slash", path, schema))
+	}
+
+	return strings.Trim(path, "/"), key, schema, nil
+}
+
+// listRelocatableSchemas returns the list of relocatable schemas installed on the system.
+func (m *Manager) listRelocatableSchemas(ctx context.Context) (schemas []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't list relocatable schemas"))
+
+	cmdArgs := m.gsettingsCmd
+	if cmdArgs == nil {
+		cmdArgs = []string{"gsettings"}
+	}
+	cmdArgs = append(slices.Clone(cmdArgs), "list-relocatable-schemas")
+
+	log.Debugf(ctx, "Listing relocatable schemas with %v", cmdArgs)
+
+	smbsafe.WaitExec()
+	// #nosec G204 - we control the input
+	out, err := exec.Command(cmdArgs[0], cmdArgs[1:]...).Output()
+	smbsafe.DoneExec()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string{}, strings.Fields(string(out))...), nil
 }
 
 // writeProfile creates or updates a dconf profile file.
-// The adsys system-db should always be the first system-db in the file to enforce their values
-// (upper system-db in the profile wins).
-func writeProfile(ctx context.Context, user, profilesPath string) (err error) {
+// The adsys system-dbs, the user ones in precedence order then the machine one, should always be the last
+// system-dbs in the file to enforce their values (upper system-db in the profile wins, unless a lower one locks
+// the key).
+func writeProfile(ctx context.Context, user string, dbs []string, profilesPath string) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't update user profile %s", profilesPath))
 
 	profilePath := filepath.Join(profilesPath, user)
 	log.Debugf(ctx, "Update user profile %s", profilePath)
 
-	adsysMachineDB := "system-db:machine"
-	adsysUserDB := fmt.Sprintf("system-db:%s", user)
+	adsysDBs := make([]string, 0, len(dbs)+1)
+	for _, db := range dbs {
+		adsysDBs = append(adsysDBs, fmt.Sprintf("system-db:%s", db))
+	}
+	adsysDBs = append(adsysDBs, "system-db:machine")
 
 	// Read existing content and create file if doesn’t exists
 	content, err := os.ReadFile(profilePath)
@@ -237,19 +773,19 @@ func writeProfile(ctx context.Context, user, profilesPath string) (err error) {
 			return err
 		}
 		// #nosec G306. This asset needs to be world-readable.
-		return os.WriteFile(profilePath, []byte(fmt.Sprintf("user-db:user\n%s\n%s", adsysUserDB, adsysMachineDB)), 0644)
+		return os.WriteFile(profilePath, []byte("user-db:user\n"+strings.Join(adsysDBs, "\n")), 0644)
 	}
 
-	// Read file to insert them at the end, removing duplicates
+	// Read file to insert them at the end, removing duplicates and the layers of a previous policy
 	var out []string
 	for _, d := range bytes.Split(bytes.TrimSpace(content), []byte("\n")) {
 		// Add current line if it’s not an adsys one
-		if string(d) == adsysMachineDB || string(d) == adsysUserDB {
+		if isAdsysProfileDB(string(d), user) {
 			continue
 		}
 		out = append(out, string(d))
 	}
-	out = append(out, adsysUserDB, adsysMachineDB)
+	out = append(out, adsysDBs...)
 
 	newContent := []byte(strings.Join(out, "\n"))
 
@@ -269,6 +805,19 @@ func writeProfile(ctx context.Context, user, profilesPath string) (err error) {
 	return nil
 }
 
+// isAdsysProfileDB returns true if the profile line l is one of the adsys databases of user, including its layers.
+func isAdsysProfileDB(l, user string) bool {
+	db, found := strings.CutPrefix(l, "system-db:")
+	if !found {
+		return false
+	}
+	if db == "machine" || db == user {
+		return true
+	}
+	m := layerDBRe.FindStringSubmatch(db)
+	return m != nil && m[1] == user
+}
+
 // dconfNeedsUpdate will notify if we need to run dconf update for that binary database.
 // For now, it only checks its existence.
 func dconfNeedsUpdate(path string) bool {
@@ -280,22 +829,22 @@ func dconfNeedsUpdate(path string) bool {
 }
 
 // normalizeValue simplify user entry by handling common mistakes on key types.
-func normalizeValue(keyType, value string) string {
+func normalizeValue(keyType, value string) (string, error) {
 	value = strings.TrimSpace(value)
 	switch keyType {
 	case "s":
-		return quoteValue(value)
+		return quoteValue(value), nil
 	case "b":
-		return normalizeBoolean(value)
+		return normalizeBoolean(value), nil
 	case "i":
-		return strings.ReplaceAll(strings.ReplaceAll(value, `"`, ""), "'", "")
+		return strings.ReplaceAll(strings.ReplaceAll(value, `"`, ""), "'", ""), nil
 	case "as":
 		return quoteASVariant(value)
 	case "ai":
-		return normalizeAIVariant(value)
+		return normalizeAIVariant(value), nil
 	}
 
-	return value
+	return value, nil
 }
 
 // quoteValue ensures the string starts and ends with ' in s.
@@ -329,7 +878,9 @@ func normalizeBoolean(v string) string {
 }
 
 // quoteASVariant returns a variant array of string properly quoted and separated.
-func quoteASVariant(v string) string {
+// Quoted elements are parsed as GVariant string literals, so that they can contain commas and
+// escaped quotes. An error is returned if they are not valid literals.
+func quoteASVariant(v string) (string, error) {
 	v = strings.TrimRight(strings.TrimLeft(v, " ["), " ]")
 
 	// Remove any empty \n elements
@@ -342,18 +893,24 @@ func quoteASVariant(v string) string {
 	}
 	v = strings.Join(elems, ",")
 
+	// Empty array
+	if strings.TrimSpace(v) == "" {
+		return "[]", nil
+	}
+
 	// Quoted string case
-	if strings.HasPrefix(v, "'") && strings.HasSuffix(v, "'") {
-		// Remove leading/trailing quote and split on "','" (with optional spaces)
-		v = strings.Trim(v, "'")
-		re := regexp.MustCompile(`'\s*,\s*'`)
-		t := re.Split(v, -1)
+	if isQuoted(v) {
+		t, err := parseQuotedStrings(v)
+		if err != nil {
+			return "", err
+		}
 
 		var r []string
 		for _, e := range t {
-			r = append(r, quoteValue(e))
+			// Elements from double quoted strings can contain unescaped single quotes.
+			r = append(r, fmt.Sprintf("'%s'", strings.Join(splitOnNonEscaped(e, "'"), `\'`)))
 		}
-		return fmt.Sprintf("[%s]", strings.Join(r, ", "))
+		return fmt.Sprintf("[%s]", strings.Join(r, ", ")), nil
 	}
 
 	// Unquoted string
@@ -367,7 +924,70 @@ func quoteASVariant(v string) string {
 		r = append(r, quoteValue(e))
 	}
 
-	return fmt.Sprintf("[%s]", strings.Join(r, ", "))
+	return fmt.Sprintf("[%s]", strings.Join(r, ", ")), nil
+}
+
+// isQuoted returns true if v starts and ends with a single or double quote.
+func isQuoted(v string) bool {
+	if len(v) < 2 {
+		return false
+	}
+	return strings.ContainsAny(v[:1], `'"`) && strings.ContainsAny(v[len(v)-1:], `'"`)
+}
+
+// parseQuotedStrings parses a comma separated list of single or double quoted GVariant strings.
+// Escape sequences are kept as is in the returned elements, without their surrounding quotes.
+func parseQuotedStrings(v string) (elems []string, err error) {
+	r := []rune(v)
+	i := 0
+	skipSpaces := func() {
+		for i < len(r) && unicode.IsSpace(r[i]) {
+			i++
+		}
+	}
+
+	for {
+		skipSpaces()
+		if i >= len(r) {
+			return nil, errors.New(gotext.Get("missing element after comma in %s", v))
+		}
+		quote := r[i]
+		if quote != '\'' && quote != '"' {
+			return nil, errors.New(gotext.Get("expected a quoted string at position %d in %s", i, v))
+		}
+		i++
+
+		var elem strings.Builder
+		var closed bool
+		for i < len(r) {
+			c := r[i]
+			if c == '\\' && i+1 < len(r) {
+				elem.WriteRune(c)
+				elem.WriteRune(r[i+1])
+				i += 2
+				continue
+			}
+			i++
+			if c == quote {
+				closed = true
+				break
+			}
+			elem.WriteRune(c)
+		}
+		if !closed {
+			return nil, errors.New(gotext.Get("unterminated string in %s", v))
+		}
+		elems = append(elems, elem.String())
+
+		skipSpaces()
+		if i >= len(r) {
+			return elems, nil
+		}
+		if r[i] != ',' {
+			return nil, errors.New(gotext.Get("unexpected character %q at position %d in %s", r[i], i, v))
+		}
+		i++
+	}
 }
 
 // normalizeAIVariant returns a variant array of int with proper separator.
@@ -2,10 +2,19 @@ package dconf_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
 	"github.com/ubuntu/adsys/internal/policies/dconf"
@@ -16,10 +25,16 @@ import (
 func TestApplyPolicy(t *testing.T) {
 	t.Parallel()
 
+	locked, unlocked := true, false
+
 	tests := map[string]struct {
 		isComputer       bool
+		user             string
 		entries          []entry.Entry
 		existingDconfDir string
+		gsettingsFail    bool
+		dconfUpdateFail  bool
+		schemasDir       string
 
 		wantErr bool
 	}{
@@ -136,6 +151,9 @@ func TestApplyPolicy(t *testing.T) {
 		"Multi-lines as mixed with comma": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-as", Value: "first,second\nthird\n", Meta: "as"},
 		}},
+		"Quoted as with commas and escaped quotes inside elements": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-as", Value: `['first, with comma', "it's quoted", 'escaped \'quote\'']`, Meta: "as"},
+		}},
 		"Multi-lines ai": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-ai", Value: "1\n2\n", Meta: "ai"},
 		}},
@@ -167,6 +185,98 @@ func TestApplyPolicy(t *testing.T) {
 			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-thirdvalue'", Meta: "s"}},
 			existingDconfDir: "existing-other-user"},
 
+		// Relocatable schemas
+		"Relocatable schema key is written under its path": {entries: []entry.Entry{
+			{Key: "com.ubuntu.relocatable[/com/ubuntu/relocatable/custom0/]/key-s", Value: "'relocated'", Meta: "s"},
+		}},
+		"Relocatable and non relocatable keys are mixed": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"},
+			{Key: "com.ubuntu.relocatable[/com/ubuntu/relocatable/custom0/]/key-s", Value: "'relocated'", Meta: "s"},
+			{Key: "com.ubuntu.relocatable[/com/ubuntu/relocatable/custom1/]/key-s", Disabled: true, Meta: "s"},
+		}},
+
+		// Explicit locks
+		"Locked key without value": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Disabled: true, Lock: &locked, Meta: "s"},
+		}},
+		"Value without lock": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Lock: &unlocked, Meta: "s"},
+		}},
+		"Value with explicit lock": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Lock: &locked, Meta: "s"},
+		}},
+		"Disabled key without lock is neither set nor locked": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Disabled: true, Lock: &unlocked, Meta: "s"},
+		}},
+		"Mixed locked and unlocked keys": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Lock: &unlocked, Meta: "s"},
+			{Key: "com/ubuntu/category/key-i", Value: "42", Meta: "i"},
+			{Key: "com/ubuntu/category/key-as", Disabled: true, Lock: &locked, Meta: "as"},
+		}},
+		"Existing locks are removed when keys are now unlocked": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-thirdvalue'", Lock: &unlocked, Meta: "s"}},
+			existingDconfDir: "existing-user"},
+
+		// Enum and flags validation
+		"Enum key with a valid member": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-enum", Value: "green", Meta: "s"},
+		}},
+		"Flags key with valid members": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-flags", Value: "['first', 'third']", Meta: "as"},
+		}},
+		"Flags key with no member": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-flags", Value: "[]", Meta: "as"},
+		}},
+		"Relocatable enum key with a valid member": {entries: []entry.Entry{
+			{Key: "com.ubuntu.relocatable[/com/ubuntu/relocatable/custom0/]/key-enum", Value: "'blue'", Meta: "s"},
+		}},
+		"Disabled enum key is not validated": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-enum", Disabled: true, Meta: "s"},
+		}},
+		"Key which is not an enum in its schema is not validated": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-s", Value: "'anything'", Meta: "s"},
+		}},
+		"Key with an undefined enum is not validated": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-undefined-enum", Value: "'anything'", Meta: "s"},
+			{Key: "com/ubuntu/enums/key-undefined-flags", Value: "['anything']", Meta: "as"},
+		}},
+		"Key from an unparsable schema is not validated": {entries: []entry.Entry{
+			{Key: "com/ubuntu/invalid/key-enum", Value: "'anything'", Meta: "s"},
+		}},
+		"Enum keys are not validated when schemas are not installed": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-enum", Value: "'notamember'", Meta: "s"},
+		}, schemasDir: "-"},
+
+		// Localized defaults
+		"Localized default is translated to the user language": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n", Value: "'Hello'", Meta: "s"},
+		}, user: "frenchuser"},
+		"Localized default with a context is translated to the user language": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n-context", Value: "'Good morning'", Meta: "s"},
+		}, user: "frenchuser"},
+		"Unquoted localized default is translated to the user language": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n", Value: "Hello", Meta: "s"},
+		}, user: "frenchuser"},
+		"Value different from the localized default is not translated": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n", Value: "'Good morning'", Meta: "s"},
+		}, user: "frenchuser"},
+		"Default of a key without messages localization is not translated": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-s", Value: "'Hello'", Meta: "s"},
+			{Key: "com/ubuntu/l10n/key-l10n-time", Value: "'Hello'", Meta: "s"},
+		}, user: "frenchuser"},
+		"Localized default is not translated for user without language set": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n", Value: "'Hello'", Meta: "s"},
+		}, user: "nolocaleuser"},
+		"Localized default is not translated for user without account settings": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n", Value: "'Hello'", Meta: "s"},
+		}},
+		"Localized default is not translated for user language without catalog": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n", Value: "'Hello'", Meta: "s"},
+		}, user: "germanuser"},
+		"Localized default is not translated on machine": {entries: []entry.Entry{
+			{Key: "com/ubuntu/l10n/key-l10n", Value: "'Hello'", Meta: "s"},
+		}, isComputer: true},
+
 		"Invalid as is too robust to produce defaulting values": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-as", Value: `[value1, ] value2]`, Meta: "as"},
 		}},
@@ -178,6 +288,9 @@ func TestApplyPolicy(t *testing.T) {
 		"Error on invalid ai": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-ai", Value: "[1,b]", Meta: "ai"},
 		}, wantErr: true},
+		"Error on invalid quoted as": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-as", Value: "['first', 'sec'ond']", Meta: "as"},
+		}, wantErr: true},
 		"Error on invalid value for unnormalized type": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-i", Value: "NaN", Meta: "i"},
 		}, wantErr: true},
@@ -187,6 +300,39 @@ func TestApplyPolicy(t *testing.T) {
 		"Error on empty meta": {entries: []entry.Entry{
 			{Key: "com/ubuntu/category/key-something", Value: "value", Meta: ""},
 		}, wantErr: true},
+		"Error on relocatable schema without path": {entries: []entry.Entry{
+			{Key: "com.ubuntu.relocatable[]/key-s", Value: "'relocated'", Meta: "s"},
+		}, wantErr: true},
+		"Error on relocatable path not starting and ending with a slash": {entries: []entry.Entry{
+			{Key: "com.ubuntu.relocatable[com/ubuntu/relocatable/custom0]/key-s", Value: "'relocated'", Meta: "s"},
+		}, wantErr: true},
+		"Error on invalid relocatable key format": {entries: []entry.Entry{
+			{Key: "com.ubuntu.relocatable[/com/ubuntu/relocatable/custom0/]/sub/key-s", Value: "'relocated'", Meta: "s"},
+		}, wantErr: true},
+		"Error on schema not being relocatable": {entries: []entry.Entry{
+			{Key: "com.ubuntu.fixed[/com/ubuntu/fixed/]/key-s", Value: "'relocated'", Meta: "s"},
+		}, wantErr: true},
+		"Error on listing relocatable schemas failing": {entries: []entry.Entry{
+			{Key: "com.ubuntu.relocatable[/com/ubuntu/relocatable/custom0/]/key-s", Value: "'relocated'", Meta: "s"},
+		}, gsettingsFail: true, wantErr: true},
+		"Error on dconf update failing reports its output": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"},
+		}, dconfUpdateFail: true, wantErr: true},
+		"Error on enum key with an invalid member": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-enum", Value: "'purple'", Meta: "s"},
+		}, wantErr: true},
+		"Error on flags key with an invalid member": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-flags", Value: "['first', 'fourth']", Meta: "as"},
+		}, wantErr: true},
+		"Error on enum key with a list of values": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-enum", Value: "['red', 'blue']", Meta: "as"},
+		}, wantErr: true},
+		"Error on flags key with a single value": {entries: []entry.Entry{
+			{Key: "com/ubuntu/enums/key-flags", Value: "'first'", Meta: "s"},
+		}, wantErr: true},
+		"Error on relocatable enum key with an invalid member": {entries: []entry.Entry{
+			{Key: "com.ubuntu.relocatable[/com/ubuntu/relocatable/custom0/]/key-enum", Value: "'purple'", Meta: "s"},
+		}, wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -207,10 +353,34 @@ func TestApplyPolicy(t *testing.T) {
 					"Setup: can't create initial dconf directory")
 			}
 
-			m := dconf.NewWithDconfDir(dconfDir)
-			err := m.ApplyPolicy(context.Background(), "ubuntu", tc.isComputer, tc.entries)
+			if tc.schemasDir == "" {
+				tc.schemasDir = filepath.Join("testdata", "schemas")
+			}
+			if tc.schemasDir == "-" {
+				tc.schemasDir = filepath.Join(t.TempDir(), "doesnotexist")
+			}
+
+			if tc.user == "" {
+				tc.user = "ubuntu"
+			}
+
+			opts := []dconf.Option{
+				dconf.WithGsettingsCmd(mockGsettingsCmd(t, tc.gsettingsFail)),
+				dconf.WithSchemasDir(tc.schemasDir),
+				dconf.WithLocaleDir(filepath.Join("testdata", "locale")),
+				dconf.WithAccountsServiceDir(filepath.Join("testdata", "accounts")),
+			}
+			if tc.dconfUpdateFail {
+				opts = append(opts, dconf.WithCommandRunner(mockDconfRunner(t)))
+			}
+			m := dconf.NewWithDconfDir(dconfDir, opts...)
+			err := m.ApplyPolicy(context.Background(), tc.user, tc.isComputer, tc.entries)
 			if tc.wantErr {
 				require.NotNil(t, err, "ApplyPolicy should have failed but didn't")
+				if tc.dconfUpdateFail {
+					require.ErrorContains(t, err, "error: malformed keyfile", "ApplyPolicy error should contain dconf output")
+					require.NotContains(t, err.Error(), "line 8", "ApplyPolicy error should contain only the first lines of dconf output")
+				}
 				return
 			}
 			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
@@ -219,3 +389,576 @@ func TestApplyPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyLayeredPolicy(t *testing.T) {
+	t.Parallel()
+
+	locked, unlocked := true, false
+
+	tests := map[string]struct {
+		isComputer       bool
+		layers           [][]entry.Entry
+		existingDconfDir string
+
+		wantErr bool
+	}{
+		"Layers are stacked in the user profile in precedence order": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+			{{Key: "com/ubuntu/category/key-i", Value: "2", Meta: "i"}},
+			{{Key: "com/ubuntu/category/key-as", Value: "['third-layer']", Meta: "as"}},
+		}},
+		"Key set by multiple layers is only written in the highest one": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+			{
+				{Key: "com/ubuntu/category/key-s", Value: "'second-layer'", Meta: "s"},
+				{Key: "com/ubuntu/category/key-i", Value: "2", Meta: "i"},
+			},
+		}},
+		"Lock of a lower layer is respected by the highest one": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Lock: &unlocked, Meta: "s"}},
+			{
+				{Key: "com/ubuntu/category/key-s", Value: "'second-layer'", Lock: &locked, Meta: "s"},
+				{Key: "com/ubuntu/category/key-i", Value: "2", Lock: &unlocked, Meta: "i"},
+			},
+		}},
+		"Key unlocked in all layers is not locked": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Lock: &unlocked, Meta: "s"}},
+			{{Key: "com/ubuntu/category/key-s", Value: "'second-layer'", Lock: &unlocked, Meta: "s"}},
+		}},
+		"Disabled key in a higher layer overrides a lower layer value": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Disabled: true, Meta: "s"}},
+			{{Key: "com/ubuntu/category/key-s", Value: "'second-layer'", Meta: "s"}},
+		}},
+		"Layers without entries are not written": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+			nil,
+			{{Key: "com/ubuntu/category/key-s", Value: "'overridden'", Meta: "s"}},
+			{{Key: "com/ubuntu/category/key-i", Value: "4", Meta: "i"}},
+		}},
+		"Single layer is the user database": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+		}},
+		"No layer generates an empty user database": {},
+		"Stale layers are removed and other databases of the profile are kept": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+			{{Key: "com/ubuntu/category/key-i", Value: "2", Meta: "i"}},
+		}, existingDconfDir: "existing-layered-user"},
+		"All stale layers are removed with a single layer": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+		}, existingDconfDir: "existing-layered-user"},
+
+		// Machine cases
+		"Machine layers are merged in the machine database": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+			{
+				{Key: "com/ubuntu/category/key-s", Value: "'second-layer'", Meta: "s"},
+				{Key: "com/ubuntu/category/key-i", Value: "2", Lock: &unlocked, Meta: "i"},
+			},
+		}, isComputer: true},
+
+		// Error cases
+		"Error on invalid entry in a lower layer": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+			{{Key: "com/ubuntu/category/key-i", Value: "NaN", Meta: "i"}},
+		}, wantErr: true},
+		"Error when machine db does not exist": {layers: [][]entry.Entry{
+			{{Key: "com/ubuntu/category/key-s", Value: "'first-layer'", Meta: "s"}},
+		}, existingDconfDir: "-", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dconfDir := t.TempDir()
+
+			if tc.existingDconfDir == "" {
+				tc.existingDconfDir = "machine-base"
+			}
+			if tc.existingDconfDir != "-" {
+				require.NoError(t, os.Remove(dconfDir), "Setup: can't delete dconf base directory before recreation")
+				require.NoError(t,
+					shutil.CopyTree(
+						filepath.Join(testutils.TestFamilyPath(t), "dconf", tc.existingDconfDir), dconfDir,
+						&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+					"Setup: can't create initial dconf directory")
+			}
+
+			m := dconf.NewWithDconfDir(dconfDir,
+				dconf.WithGsettingsCmd(mockGsettingsCmd(t, false)),
+				dconf.WithSchemasDir(filepath.Join("testdata", "schemas")))
+			err := m.ApplyLayeredPolicy(context.Background(), "ubuntu", tc.isComputer, tc.layers)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyLayeredPolicy should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "ApplyLayeredPolicy failed but shouldn't have")
+
+			testutils.CompareTreesWithFiltering(t, dconfDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
+
+func mockGsettingsCmd(t *testing.T, wantFail bool) []string {
+	t.Helper()
+
+	cmdArgs := []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockGsettings", "--"}
+	if wantFail {
+		cmdArgs = append(cmdArgs, "-Exit1-")
+	}
+	return cmdArgs
+}
+
+func TestMockGsettings(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+
+	if len(args) > 0 && args[0] == "-Exit1-" {
+		fmt.Fprintln(os.Stderr, "EXIT 1 requested in mock")
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "list-relocatable-schemas" {
+		fmt.Println("com.ubuntu.relocatable")
+		fmt.Println("com.ubuntu.otherrelocatable")
+	}
+}
+
+// mockDconfRunner returns a command runner executing a failing mock dconf binary.
+func mockDconfRunner(t *testing.T) func(name string, args ...string) ([]byte, error) {
+	t.Helper()
+
+	return func(name string, args ...string) ([]byte, error) {
+		cmdArgs := append([]string{"GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockDconf", "--", name}, args...)
+		// #nosec G204 - we control the input in tests
+		return exec.Command("env", cmdArgs...).CombinedOutput()
+	}
+}
+
+func TestMockDconf(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "error: malformed keyfile com/ubuntu/category: key-s")
+	for i := 2; i <= 8; i++ {
+		fmt.Fprintf(os.Stderr, "line %d\n", i)
+	}
+	os.Exit(1)
+}
+
+func TestDryRunPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		isComputer       bool
+		entries          []entry.Entry
+		existingDconfDir string
+
+		wantErr bool
+	}{
+		"New user reports all keys as added": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"}}},
+		"User with changed and added keys": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-thirdvalue'", Meta: "s"},
+			{Key: "com/ubuntu/category2/key-s2", Value: "'onekey-s2'", Meta: "s"}},
+			existingDconfDir: "existing-user"},
+		"User with no change reports nothing": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"}},
+			existingDconfDir: "existing-user"},
+		"User with key now disabled only keeps the lock": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Disabled: true, Meta: "s"}},
+			existingDconfDir: "existing-user"},
+		"Machine with changed value": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-thirdvalue'", Meta: "s"}},
+			isComputer: true},
+		"Machine with no policy removes everything": {isComputer: true},
+		"Missing user compiled db is regenerated": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"}},
+			existingDconfDir: "missing-user-compiled-db"},
+
+		// Error cases
+		"Error when machine db does not exist": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"},
+		}, existingDconfDir: "-", wantErr: true},
+		"Error on invalid value": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-i", Value: "NaN", Meta: "i"},
+		}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dconfDir := t.TempDir()
+
+			if tc.existingDconfDir == "" {
+				tc.existingDconfDir = "machine-base"
+			}
+			if tc.existingDconfDir != "-" {
+				require.NoError(t, os.Remove(dconfDir), "Setup: can't delete dconf base directory before recreation")
+				testutils.Copy(t, filepath.Join("testdata", "TestApplyPolicy", "dconf", tc.existingDconfDir), dconfDir)
+			}
+			before := dirContent(t, dconfDir)
+
+			m := dconf.NewWithDconfDir(dconfDir, dconf.WithGsettingsCmd(mockGsettingsCmd(t, false)))
+			changes, err := m.DryRunPolicy(context.Background(), "ubuntu", tc.isComputer, tc.entries)
+			if tc.wantErr {
+				require.NotNil(t, err, "DryRunPolicy should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "DryRunPolicy failed but shouldn't have")
+
+			want := testutils.LoadWithUpdateFromGolden(t, changes.String())
+			require.Equal(t, want, changes.String(), "DryRunPolicy returned unexpected changes")
+
+			require.Equal(t, before, dirContent(t, dconfDir), "DryRunPolicy should not modify anything on disk")
+		})
+	}
+}
+
+func TestSimulatePolicy(t *testing.T) {
+	t.Parallel()
+
+	unlocked := false
+
+	tests := map[string]struct {
+		isComputer bool
+		entries    []entry.Entry
+
+		wantErr bool
+	}{
+		"User policy": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"},
+			{Key: "com/ubuntu/category2/key-s2", Value: "'onekey-s2'", Meta: "s"}}},
+		"Machine policy": {isComputer: true, entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"}}},
+		"Disabled key is only locked": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Disabled: true, Meta: "s"}}},
+		"Unlocked key": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s", Lock: &unlocked}}},
+		"No policy": {},
+
+		// Error cases
+		"Error on invalid value": {entries: []entry.Entry{
+			{Key: "com/ubuntu/category/key-i", Value: "NaN", Meta: "i"},
+		}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The user database doesn't need the machine one, and nothing is read from the dconf directory.
+			dconfDir := filepath.Join(t.TempDir(), "dconf")
+
+			m := dconf.NewWithDconfDir(dconfDir, dconf.WithGsettingsCmd(mockGsettingsCmd(t, false)))
+			files, err := m.SimulatePolicy(context.Background(), "ubuntu", tc.isComputer, tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "SimulatePolicy should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "SimulatePolicy failed but shouldn't have")
+
+			var got strings.Builder
+			paths := make([]string, 0, len(files))
+			for p := range files {
+				paths = append(paths, p)
+			}
+			sort.Strings(paths)
+			for _, p := range paths {
+				rel, err := filepath.Rel(dconfDir, p)
+				require.NoError(t, err, "SimulatePolicy should return files in the dconf directory")
+				fmt.Fprintf(&got, "%s:\n%s", rel, files[p])
+			}
+			want := testutils.LoadWithUpdateFromGolden(t, got.String())
+			require.Equal(t, want, got.String(), "SimulatePolicy returned unexpected files")
+
+			require.NoDirExists(t, dconfDir, "SimulatePolicy should not write anything on disk")
+		})
+	}
+}
+
+func TestDconfUpdateCoalescing(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		debounce   time.Duration
+		users      []string
+		noFlush    bool
+		wait       bool
+		updateFail bool
+
+		wantUpdatesBeforeFlush int
+		wantUpdates            int
+		wantErr                bool
+	}{
+		"Synchronous applies update on each apply": {users: []string{"user1", "user2", "user3"},
+			wantUpdatesBeforeFlush: 3, wantUpdates: 3},
+		"Debounced applies only update once on flush": {debounce: time.Hour, users: []string{"user1", "user2", "user3"},
+			wantUpdatesBeforeFlush: 0, wantUpdates: 1},
+		"Debounced applies update once after the delay without flush": {debounce: 50 * time.Millisecond, users: []string{"user1", "user2", "user3"},
+			noFlush: true, wantUpdates: 1},
+		"Debounced applies waited for update once after the delay": {debounce: 50 * time.Millisecond, users: []string{"user1", "user2", "user3"},
+			wait: true, wantUpdates: 1},
+		"Synchronous applies have no update to wait for": {users: []string{"user1", "user2"}, wait: true, wantUpdates: 2},
+		"Flush without any apply does not update":        {debounce: time.Hour},
+
+		// Error cases
+		"Error on flush when dconf update fails": {debounce: time.Hour, users: []string{"user1"}, updateFail: true,
+			wantUpdates: 1, wantErr: true},
+		"Error on wait when dconf update fails": {debounce: 50 * time.Millisecond, users: []string{"user1"}, wait: true, updateFail: true,
+			wantUpdates: 1, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dconfDir := t.TempDir()
+			require.NoError(t, os.Remove(dconfDir), "Setup: can't delete dconf base directory before recreation")
+			testutils.Copy(t, filepath.Join("testdata", "TestApplyPolicy", "dconf", "machine-base"), dconfDir)
+
+			var mu sync.Mutex
+			var updates int
+			runner := func(name string, args ...string) ([]byte, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				// The runner can be called from the debounce timer goroutine: don’t use require here.
+				assert.Equal(t, []string{"dconf", "update", filepath.Join(dconfDir, "db")}, append([]string{name}, args...), "Unexpected command run")
+				updates++
+				if tc.updateFail {
+					return []byte("dconf update failed"), errors.New("exit status 1")
+				}
+				return nil, nil
+			}
+			countUpdates := func() int {
+				mu.Lock()
+				defer mu.Unlock()
+				return updates
+			}
+
+			m := dconf.NewWithDconfDir(dconfDir, dconf.WithUpdateDebounce(tc.debounce), dconf.WithCommandRunner(runner))
+			for _, u := range tc.users {
+				err := m.ApplyPolicy(context.Background(), u, false, []entry.Entry{
+					{Key: "com/ubuntu/category/key-s", Value: "'onekey-s-othervalue'", Meta: "s"}})
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
+
+			if tc.noFlush {
+				require.Eventually(t, func() bool { return countUpdates() == tc.wantUpdates }, 5*time.Second, 10*time.Millisecond,
+					"dconf update should have run once the delay elapsed")
+				return
+			}
+			if tc.wait {
+				// All applications wait for the same update.
+				var wg sync.WaitGroup
+				errs := make([]error, len(tc.users))
+				for i := range tc.users {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						errs[i] = m.WaitUpdates(context.Background())
+					}()
+				}
+				wg.Wait()
+				require.Equal(t, tc.wantUpdates, countUpdates(), "Unexpected number of dconf update once waited for")
+				for _, err := range errs {
+					if tc.wantErr {
+						require.Error(t, err, "WaitUpdates should have failed but didn't")
+						continue
+					}
+					require.NoError(t, err, "WaitUpdates failed but shouldn't have")
+				}
+				return
+			}
+			require.Equal(t, tc.wantUpdatesBeforeFlush, countUpdates(), "Unexpected number of dconf update before flush")
+
+			err := m.FlushUpdates(context.Background())
+			require.Equal(t, tc.wantUpdates, countUpdates(), "Unexpected number of dconf update after flush")
+			if tc.wantErr {
+				require.Error(t, err, "FlushUpdates should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "FlushUpdates failed but shouldn't have")
+
+			// A second flush has nothing left to update.
+			require.NoError(t, m.FlushUpdates(context.Background()), "FlushUpdates failed but shouldn't have")
+			require.Equal(t, tc.wantUpdates, countUpdates(), "Flushing again should not run dconf update")
+		})
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	t.Parallel()
+
+	sourced := func(gpo, key, value string) dconf.SourcedEntry {
+		return dconf.SourcedEntry{GPO: gpo, Entry: entry.Entry{Key: key, Value: value, Meta: "s"}}
+	}
+	disabled := func(gpo, key string) dconf.SourcedEntry {
+		return dconf.SourcedEntry{GPO: gpo, Entry: entry.Entry{Key: key, Disabled: true, Meta: "s"}}
+	}
+	appended := func(gpo, key, value string) dconf.SourcedEntry {
+		return dconf.SourcedEntry{GPO: gpo, Entry: entry.Entry{Key: key, Value: value, Meta: "as", Strategy: entry.StrategyAppend}}
+	}
+
+	tests := map[string]struct {
+		entries []dconf.SourcedEntry
+
+		want []dconf.Conflict
+	}{
+		"No entries":              {},
+		"Different keys":          {entries: []dconf.SourcedEntry{sourced("GPO1", "a/key1", "v1"), sourced("GPO2", "a/key2", "v2")}},
+		"Same key and same value": {entries: []dconf.SourcedEntry{sourced("GPO1", "a/key", "v"), sourced("GPO2", "a/key", "v")}},
+		"Same value with different surrounding spaces": {entries: []dconf.SourcedEntry{sourced("GPO1", "a/key", "v"), sourced("GPO2", "a/key", " v\n")}},
+		"Same key disabled in multiple GPOs":           {entries: []dconf.SourcedEntry{disabled("GPO1", "a/key"), disabled("GPO2", "a/key")}},
+		"Same key with append strategy":                {entries: []dconf.SourcedEntry{appended("GPO1", "a/key", "'v1'"), appended("GPO2", "a/key", "'v2'")}},
+		"Same key set twice in the same GPO":           {entries: []dconf.SourcedEntry{sourced("GPO1", "a/key", "v1"), sourced("GPO1", "a/key", "v2")}},
+
+		"Same key with different values": {
+			entries: []dconf.SourcedEntry{sourced("GPO1", "a/key", "v1"), sourced("GPO2", "a/key", "v2")},
+			want:    []dconf.Conflict{{Key: "a/key", Value: "v1", GPO: "GPO1", Overridden: []string{"GPO2"}}},
+		},
+		"Value overriding a disabled key": {
+			entries: []dconf.SourcedEntry{sourced("GPO1", "a/key", "v1"), disabled("GPO2", "a/key")},
+			want:    []dconf.Conflict{{Key: "a/key", Value: "v1", GPO: "GPO1", Overridden: []string{"GPO2"}}},
+		},
+		"Disabled key overriding a value": {
+			entries: []dconf.SourcedEntry{disabled("GPO1", "a/key"), sourced("GPO2", "a/key", "v2")},
+			want:    []dconf.Conflict{{Key: "a/key", Disabled: true, GPO: "GPO1", Overridden: []string{"GPO2"}}},
+		},
+		"Only GPOs with different values are listed as overridden": {
+			entries: []dconf.SourcedEntry{sourced("GPO1", "a/key", "v1"), sourced("GPO2", "a/key", "v2"), sourced("GPO3", "a/key", "v1"), sourced("GPO4", "a/key", "v3")},
+			want:    []dconf.Conflict{{Key: "a/key", Value: "v1", GPO: "GPO1", Overridden: []string{"GPO2", "GPO4"}}},
+		},
+		"Multiple conflicts are listed in order of first definition": {
+			entries: []dconf.SourcedEntry{
+				sourced("GPO1", "a/key2", "v1"), sourced("GPO1", "a/key1", "v1"), sourced("GPO1", "a/key3", "v1"),
+				sourced("GPO2", "a/key1", "v2"), sourced("GPO2", "a/key2", "v2"), sourced("GPO2", "a/key3", "v1"),
+			},
+			want: []dconf.Conflict{
+				{Key: "a/key2", Value: "v1", GPO: "GPO1", Overridden: []string{"GPO2"}},
+				{Key: "a/key1", Value: "v1", GPO: "GPO1", Overridden: []string{"GPO2"}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := dconf.FindConflicts(tc.entries)
+			require.Equal(t, tc.want, got, "FindConflicts returned unexpected conflicts")
+		})
+	}
+}
+
+func TestCleanupOrphaned(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		activeObjects    []string
+		existingDconfDir string
+
+		wantRemoved []string
+		wantErr     bool
+	}{
+		"Remove orphaned databases and keep active ones": {
+			activeObjects: []string{"ubuntu"},
+			wantRemoved:   []string{"customprofile", "extrafiles", "otheruser"}},
+		"Keep everything when all databases are active": {
+			activeObjects: []string{"ubuntu", "otheruser", "extrafiles", "customprofile"}},
+		"Remove all adsys databases but user and machine ones when nothing is active": {
+			wantRemoved: []string{"customprofile", "extrafiles", "otheruser", "ubuntu"}},
+		"Unknown active objects are ignored": {
+			activeObjects: []string{"ubuntu", "otheruser", "extrafiles", "customprofile", "doesnotexist"}},
+		"No dconf database directory is a no-op": {existingDconfDir: "-"},
+
+		// Layered databases
+		"Remove layers of orphaned objects and keep the ones of active objects": {
+			activeObjects:    []string{"ubuntu"},
+			existingDconfDir: "layered-objects",
+			wantRemoved:      []string{"otheruser-layer2", "otheruser-layer3", "otheruser"}},
+		"Keep layers of all active objects": {
+			activeObjects:    []string{"ubuntu", "otheruser"},
+			existingDconfDir: "layered-objects"},
+
+		// Error cases
+		"Error on database directory being a file": {existingDconfDir: "db-is-a-file", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dconfDir := t.TempDir()
+
+			if tc.existingDconfDir == "" {
+				tc.existingDconfDir = "multiple-objects"
+			}
+			if tc.existingDconfDir != "-" {
+				require.NoError(t, os.Remove(dconfDir), "Setup: can't delete dconf base directory before recreation")
+				testutils.Copy(t, filepath.Join(testutils.TestFamilyPath(t), "dconf", tc.existingDconfDir), dconfDir)
+			}
+
+			m := dconf.NewWithDconfDir(dconfDir)
+			removed, err := m.CleanupOrphaned(context.Background(), tc.activeObjects)
+			if tc.wantErr {
+				require.Error(t, err, "CleanupOrphaned should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "CleanupOrphaned failed but shouldn't have")
+			require.Equal(t, tc.wantRemoved, removed, "CleanupOrphaned didn't report the expected removed databases")
+
+			if tc.existingDconfDir == "-" {
+				return
+			}
+
+			// Compiled databases are filtered out of the golden tree: check them explicitly.
+			for _, db := range removed {
+				if _, err := os.Stat(filepath.Join(dconfDir, "db", db+".d")); err == nil {
+					continue
+				}
+				require.NoFileExists(t, filepath.Join(dconfDir, "db", db), "Compiled database of removed keyfiles should be removed")
+			}
+
+			testutils.CompareTreesWithFiltering(t, dconfDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
+
+// dirContent returns the content of all files in dir, indexed by their relative path.
+func dirContent(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	content := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if de.IsDir() {
+			content[strings.TrimPrefix(path, dir)] = ""
+			return nil
+		}
+		d, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		content[strings.TrimPrefix(path, dir)] = string(d)
+		return nil
+	})
+	require.NoError(t, err, "Setup: can't read directory content")
+	return content
+}
@@ -1,9 +1,15 @@
 package dconf
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNormalize(t *testing.T) {
@@ -13,7 +19,8 @@ func TestNormalize(t *testing.T) {
 		keyType string
 		value   string
 
-		want string
+		want    string
+		wantErr bool
 	}{
 		// string cases
 		"simple quoted string":   {keyType: "s", value: "'hello world'", want: "'hello world'"},
@@ -60,10 +67,30 @@ func TestNormalize(t *testing.T) {
 		"as partially quoted with comma can lead to unexpected result":     {keyType: "as", value: "[aa,'b,b',cc]", want: `['aa', '\'b', 'b\'', 'cc']`},
 		"as partially quoted unbalanced start can lead to unexpect result": {keyType: "as", value: "['aa,'bb',cc]", want: `['\'aa', '\'bb\'', 'cc']`},
 		"as partially quoted unbalanced end can lead to unexpect result":   {keyType: "as", value: "[aa,'bb',cc']", want: `['aa', '\'bb\'', 'cc\'']`},
-		"as wrongly quoted will consider comma as part of the string":      {keyType: "as", value: "['aa,'bb',cc']", want: `['aa,\'bb\',cc']`},
 		"as with weird composition inception will be quoted":               {keyType: "as", value: "[value1, ] value2]", want: `['value1', '] value2']`},
 		"as with empty quoted can lead to unexpect result":                 {keyType: "as", value: "[aa,'bb',cc]", want: `['aa', '\'bb\'', 'cc']`},
 
+		"as quoted with commas inside elements":          {keyType: "as", value: "['a,b','c']", want: "['a,b', 'c']"},
+		"as double quoted with commas inside elements":   {keyType: "as", value: `["a,b", "c"]`, want: "['a,b', 'c']"},
+		"as mixed single and double quoted":              {keyType: "as", value: `['a', "b"]`, want: "['a', 'b']"},
+		"as quoted with escaped quotes and commas":       {keyType: "as", value: `['it\'s, ok', 'c']`, want: `['it\'s, ok', 'c']`},
+		"as double quoted with single quote is escaped":  {keyType: "as", value: `["it's"]`, want: `['it\'s']`},
+		"as double quoted with single quoted words":      {keyType: "as", value: `["say 'hi'", 'b']`, want: `['say \'hi\'', 'b']`},
+		"as quoted with double quotes inside":            {keyType: "as", value: `['say "hi"']`, want: `['say "hi"']`},
+		"as quoted with escaped backslash":               {keyType: "as", value: `['a\\', 'b']`, want: `['a\\', 'b']`},
+		"as quoted with unicode characters":              {keyType: "as", value: "['héllo, wörld', '🤪']", want: "['héllo, wörld', '🤪']"},
+		"as quoted with empty element":                   {keyType: "as", value: "['']", want: "['']"},
+		"as empty array":                                 {keyType: "as", value: "[]", want: "[]"},
+		"as empty value":                                 {keyType: "as", value: "", want: "[]"},
+		"as quoted with spaces before and after commas":  {keyType: "as", value: "[ 'a' ,   'b' ]", want: "['a', 'b']"},
+		"as quoted with leading spaces inside the array": {keyType: "as", value: "[   'a', 'b']", want: "['a', 'b']"},
+
+		"error on as wrongly quoted with comma inside the string": {keyType: "as", value: "['aa,'bb',cc']", wantErr: true},
+		"error on as quoted with unescaped quote":                 {keyType: "as", value: "['a', 'b'c']", wantErr: true},
+		"error on as quoted with empty element between commas":    {keyType: "as", value: "['a',,'b']", wantErr: true},
+		"error on as quoted with unquoted element":                {keyType: "as", value: "['a', b']", wantErr: true},
+		"error on as quoted with unterminated string":             {keyType: "as", value: `['a', "b']`, wantErr: true},
+
 		"Multi-lines as unquoted":                                                   {keyType: "as", value: "aa\nbb\ncc", want: "['aa', 'bb', 'cc']"},
 		"Multi-lines as quoted":                                                     {keyType: "as", value: "'aa'\n'bb'\n'cc'", want: "['aa', 'bb', 'cc']"},
 		"Multi-lines as with spaces inside":                                         {keyType: "as", value: "aa   \nbb\n   cc", want: "['aa', 'bb', 'cc']"},
@@ -107,8 +134,137 @@ func TestNormalize(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			got := normalizeValue(tc.keyType, tc.value)
+			got, err := normalizeValue(tc.keyType, tc.value)
+			if tc.wantErr {
+				require.Error(t, err, "normalizeValue should have errored out")
+				return
+			}
+			require.NoError(t, err, "normalizeValue should not have errored out")
 			assert.Equal(t, tc.want, got, "normalizeValue returned expected value")
 		})
 	}
 }
+
+func TestSummarizeOutput(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		out string
+
+		want string
+	}{
+		"Single line":                                    {out: "error: something failed\n", want: "error: something failed"},
+		"Empty lines and spaces are removed":             {out: "\n  first  \n\n\nsecond\n\n", want: "first\nsecond"},
+		"Only first lines are kept":                      {out: "1\n2\n3\n4\n5\n6\n7\n", want: "1\n2\n3\n4\n5\n…"},
+		"Long output is truncated":                       {out: strings.Repeat("a", 600), want: strings.Repeat("a", 512) + "\n…"},
+		"Truncation does not cut multi-bytes characters": {out: "a" + strings.Repeat("é", 300), want: "a" + strings.Repeat("é", 255) + "\n…"},
+		"No output":                                      {out: "", want: "no output"},
+		"Only spaces is no output":                       {out: "  \n \n", want: "no output"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := summarizeOutput([]byte(tc.out))
+			assert.Equal(t, tc.want, got, "summarizeOutput returned expected value")
+		})
+	}
+}
+
+func TestLocaleCandidates(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		locale string
+
+		want []string
+	}{
+		"Language only":                   {locale: "fr", want: []string{"fr"}},
+		"Language and territory":          {locale: "fr_FR", want: []string{"fr_FR", "fr"}},
+		"Language, territory and codeset": {locale: "fr_FR.UTF-8", want: []string{"fr_FR.UTF-8", "fr_FR", "fr"}},
+		"With modifier":                   {locale: "fr_FR.UTF-8@euro", want: []string{"fr_FR.UTF-8@euro", "fr_FR", "fr"}},
+		"Language with codeset":           {locale: "fr.UTF-8", want: []string{"fr.UTF-8", "fr"}},
+
+		"C locale has no candidates":     {locale: "C"},
+		"POSIX locale has no candidates": {locale: "POSIX"},
+		"Empty locale has no candidates": {locale: ""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := localeCandidates(tc.locale)
+			assert.Equal(t, tc.want, got, "localeCandidates returned expected value")
+		})
+	}
+}
+
+func TestInstalledSchemasCache(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		noEnumsFirst bool
+
+		rewriteEnums bool
+		keepStamp    bool
+		removeEnums  bool
+		addEnums     bool
+
+		wantValid bool
+	}{
+		"Unchanged schemas are not loaded again": {rewriteEnums: true, keepStamp: true},
+		"Modified schemas are loaded again":      {rewriteEnums: true, wantValid: true},
+		"Removed schemas are loaded again":       {removeEnums: true, wantValid: true},
+		"Added schemas are loaded again":         {noEnumsFirst: true, addEnums: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			schema, err := os.ReadFile(filepath.Join("testdata", "schemas", "com.ubuntu.test.gschema.xml"))
+			require.NoError(t, err, "Setup: can't read schema")
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "com.ubuntu.test.gschema.xml"), schema, 0600), "Setup: can't write schema")
+			enums, err := os.ReadFile(filepath.Join("testdata", "schemas", "com.ubuntu.enums.xml"))
+			require.NoError(t, err, "Setup: can't read enums")
+			enumsPath := filepath.Join(dir, "com.ubuntu.enums.xml")
+			if !tc.noEnumsFirst {
+				require.NoError(t, os.WriteFile(enumsPath, enums, 0600), "Setup: can't write enums")
+			}
+
+			m := NewWithDconfDir(t.TempDir())
+			idx := m.installedSchemas(context.Background(), dir)
+			// "pur" has the same length as "red", which it replaces.
+			err = idx.validate("com/ubuntu/enums", "key-enum", "", "s", "'pur'")
+			require.Equal(t, tc.noEnumsFirst, err == nil, "Setup: unexpected validation of the first index")
+
+			switch {
+			case tc.rewriteEnums:
+				fi, err := os.Stat(enumsPath)
+				require.NoError(t, err, "Setup: can't stat enums")
+				enums = []byte(strings.Replace(string(enums), `nick="red"`, `nick="pur"`, 1))
+				require.NoError(t, os.WriteFile(enumsPath, enums, 0600), "Setup: can't rewrite enums")
+				mtime := fi.ModTime().Add(time.Second)
+				if tc.keepStamp {
+					mtime = fi.ModTime()
+				}
+				require.NoError(t, os.Chtimes(enumsPath, mtime, mtime), "Setup: can't set enums modification time")
+			case tc.removeEnums:
+				require.NoError(t, os.Remove(enumsPath), "Setup: can't remove enums")
+			case tc.addEnums:
+				require.NoError(t, os.WriteFile(enumsPath, enums, 0600), "Setup: can't write enums")
+			}
+
+			idx = m.installedSchemas(context.Background(), dir)
+			err = idx.validate("com/ubuntu/enums", "key-enum", "", "s", "'pur'")
+			if tc.wantValid {
+				require.NoError(t, err, "Value should be valid with the index of the updated schemas")
+				return
+			}
+			require.Error(t, err, "Value should not be valid with the index")
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package dconf
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// defaultLocale is the locale used when none is configured: translations are not applied.
+const defaultLocale = "C"
+
+// userLocale returns the messages locale configured for user in AccountsService.
+// It falls back to the C locale if the user has no account settings or no language set.
+func userLocale(ctx context.Context, accountsDir, user string) string {
+	f, err := os.Open(filepath.Join(accountsDir, user))
+	if err != nil {
+		log.Debugf(ctx, "No account settings for %s, using %s locale: %v", user, defaultLocale, err)
+		return defaultLocale
+	}
+	defer f.Close()
+
+	var inUserSection bool
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		l := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(l, "[") {
+			inUserSection = l == "[User]"
+			continue
+		}
+		if !inUserSection {
+			continue
+		}
+		k, v, ok := strings.Cut(l, "=")
+		if !ok || strings.TrimSpace(k) != "Language" {
+			continue
+		}
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Debugf(ctx, "Can't read account settings for %s, using %s locale: %v", user, defaultLocale, err)
+	}
+
+	return defaultLocale
+}
+
+// localeCandidates returns the catalog directories to look for a locale, by decreasing precedence.
+// For instance, fr_FR.UTF-8@euro looks for fr_FR.UTF-8@euro, fr_FR and fr.
+func localeCandidates(locale string) []string {
+	if locale == "" || locale == defaultLocale || locale == "POSIX" {
+		return nil
+	}
+
+	candidates := []string{locale}
+	territory, _, _ := strings.Cut(locale, "@")
+	territory, _, _ = strings.Cut(territory, ".")
+	lang, _, _ := strings.Cut(territory, "_")
+	for _, c := range []string{territory, lang} {
+		if c != candidates[len(candidates)-1] {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// newTranslator returns a translator to the language of user. The machine policy is not translated.
+func (m *Manager) newTranslator(ctx context.Context, user string, isComputer bool) *translator {
+	localeDir := m.localeDir
+	if localeDir == "" {
+		localeDir = consts.DefaultLocaleDir
+	}
+	if isComputer {
+		return newTranslator(localeDir, defaultLocale)
+	}
+
+	accountsDir := m.accountsDir
+	if accountsDir == "" {
+		accountsDir = consts.DefaultAccountsServiceUsersDir
+	}
+	return newTranslator(localeDir, userLocale(ctx, accountsDir, user))
+}
+
+// translator translates messages to a given locale from the gettext catalogs installed in localeDir.
+type translator struct {
+	localeDir string
+	locale    string
+
+	// catalogs caches the messages of each loaded domain. A nil catalog means there is no translation.
+	catalogs map[string]map[string]string
+}
+
+func newTranslator(localeDir, locale string) *translator {
+	return &translator{
+		localeDir: localeDir,
+		locale:    locale,
+		catalogs:  make(map[string]map[string]string),
+	}
+}
+
+// translate returns the translation of msgid, in msgctxt if not empty, from domain.
+// msgid is returned unchanged if there is no translation available.
+func (t *translator) translate(ctx context.Context, domain, msgctxt, msgid string) string {
+	catalog, ok := t.catalogs[domain]
+	if !ok {
+		catalog = t.load(ctx, domain)
+		t.catalogs[domain] = catalog
+	}
+
+	id := msgid
+	if msgctxt != "" {
+		// gettext separates the context from the message with an EOT character.
+		id = msgctxt + "\x04" + msgid
+	}
+	if tr, ok := catalog[id]; ok && tr != "" {
+		return tr
+	}
+	return msgid
+}
+
+// load returns the messages of the most specific catalog of domain installed for the translator locale.
+func (t *translator) load(ctx context.Context, domain string) map[string]string {
+	if domain == "" {
+		return nil
+	}
+	for _, l := range localeCandidates(t.locale) {
+		p := filepath.Join(t.localeDir, l, "LC_MESSAGES", domain+".mo")
+		catalog, err := readMO(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Warningf(ctx, "Can't load message catalog %s: %v", p, err)
+			continue
+		}
+		return catalog
+	}
+	return nil
+}
+
+// readMO parses a gettext binary catalog and returns its translations indexed by message id.
+func readMO(p string) (map[string]string, error) {
+	d, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(d) < 20 {
+		return nil, errors.New(gotext.Get("%s is too short to be a message catalog", p))
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(d) == 0x950412de:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(d) == 0x950412de:
+		order = binary.BigEndian
+	default:
+		return nil, errors.New(gotext.Get("%s is not a message catalog", p))
+	}
+
+	n := order.Uint32(d[8:])
+	originals, translations := order.Uint32(d[12:]), order.Uint32(d[16:])
+
+	// str returns the string described at the table offset off, index i.
+	str := func(off, i uint32) (string, error) {
+		pos := uint64(off) + uint64(i)*8
+		if pos+8 > uint64(len(d)) {
+			return "", errors.New(gotext.Get("%s is a truncated message catalog", p))
+		}
+		length, start := uint64(order.Uint32(d[pos:])), uint64(order.Uint32(d[pos+4:]))
+		if start+length > uint64(len(d)) {
+			return "", errors.New(gotext.Get("%s is a truncated message catalog", p))
+		}
+		return string(d[start : start+length]), nil
+	}
+
+	catalog := make(map[string]string)
+	for i := uint32(0); i < n; i++ {
+		id, err := str(originals, i)
+		if err != nil {
+			return nil, err
+		}
+		tr, err := str(translations, i)
+		if err != nil {
+			return nil, err
+		}
+		// Only keep the singular form of plural messages.
+		id, _, _ = strings.Cut(id, "\x00")
+		tr, _, _ = strings.Cut(tr, "\x00")
+		catalog[id] = tr
+	}
+	return catalog, nil
+}
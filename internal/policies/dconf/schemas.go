@@ -0,0 +1,279 @@
+package dconf
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// schemaList is the content of a GSettings schema or enums XML file.
+type schemaList struct {
+	GettextDomain string `xml:"gettext-domain,attr"`
+	Schemas       []struct {
+		ID            string `xml:"id,attr"`
+		Path          string `xml:"path,attr"`
+		GettextDomain string `xml:"gettext-domain,attr"`
+		Keys          []struct {
+			Name    string `xml:"name,attr"`
+			Enum    string `xml:"enum,attr"`
+			Flags   string `xml:"flags,attr"`
+			Default struct {
+				L10n    string `xml:"l10n,attr"`
+				Context string `xml:"context,attr"`
+				Value   string `xml:",chardata"`
+			} `xml:"default"`
+		} `xml:"key"`
+	} `xml:"schema"`
+	Enums []xmlEnum `xml:"enum"`
+	Flags []xmlEnum `xml:"flags"`
+}
+
+type xmlEnum struct {
+	ID     string `xml:"id,attr"`
+	Values []struct {
+		Nick string `xml:"nick,attr"`
+	} `xml:"value"`
+}
+
+// keyMembers are the members a GSettings enum or flags key accepts.
+type keyMembers struct {
+	// typeID is the id of the enum or flags type.
+	typeID  string
+	flags   bool
+	members []string
+}
+
+// localizedDefault is the default value of a key, translated in the user messages locale.
+type localizedDefault struct {
+	domain  string
+	context string
+	value   string
+}
+
+// schemaIndex indexes the enum, flags and localized keys of installed schemas, by schema path and id.
+type schemaIndex struct {
+	byPath map[string]map[string]keyMembers
+	byID   map[string]map[string]keyMembers
+
+	l10nByPath map[string]map[string]localizedDefault
+	l10nByID   map[string]map[string]localizedDefault
+}
+
+// cachedSchemaIndex is the index of the schemas of a directory, along with the state of the schema files it was
+// loaded from.
+type cachedSchemaIndex struct {
+	stamp string
+	idx   schemaIndex
+}
+
+// installedSchemas returns the index of the GSettings schemas installed in dir.
+// The index is cached per directory, and only loaded again once schema files are added, removed or modified.
+func (m *Manager) installedSchemas(ctx context.Context, dir string) schemaIndex {
+	stamp := schemasStamp(dir)
+
+	m.schemasMu.Lock()
+	defer m.schemasMu.Unlock()
+
+	if c, ok := m.schemas[dir]; ok && stamp != "" && c.stamp == stamp {
+		return c.idx
+	}
+	idx := loadSchemas(ctx, dir)
+	if m.schemas == nil {
+		m.schemas = make(map[string]cachedSchemaIndex)
+	}
+	m.schemas[dir] = cachedSchemaIndex{stamp: stamp, idx: idx}
+	return idx
+}
+
+// schemasStamp returns the name, size and modification time of each schema file in dir.
+// It is empty if there are none or if any can't be checked, in which case the schemas are loaded again.
+func schemasStamp(dir string) string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		return ""
+	}
+	var stamp strings.Builder
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(&stamp, "%s %d %d\n", filepath.Base(f), fi.Size(), fi.ModTime().UnixNano())
+	}
+	return stamp.String()
+}
+
+// loadSchemas parses the GSettings schemas installed in dir.
+// Files which can't be read or parsed are skipped, and an empty index is returned if dir doesn't exist,
+// so that values are not validated against schemas we don't know about.
+func loadSchemas(ctx context.Context, dir string) schemaIndex {
+	idx := schemaIndex{
+		byPath:     make(map[string]map[string]keyMembers),
+		byID:       make(map[string]map[string]keyMembers),
+		l10nByPath: make(map[string]map[string]localizedDefault),
+		l10nByID:   make(map[string]map[string]localizedDefault),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil || len(files) == 0 {
+		log.Debugf(ctx, "No GSettings schema found in %s: values won't be validated", dir)
+		return idx
+	}
+
+	var lists []schemaList
+	// Enums and flags can be declared in another file than the schema using them.
+	enums := make(map[string][]string)
+	flags := make(map[string][]string)
+	for _, f := range files {
+		d, err := os.ReadFile(f)
+		if err != nil {
+			log.Debugf(ctx, "Can't read GSettings schema %s: %v", f, err)
+			continue
+		}
+		var l schemaList
+		if err := xml.Unmarshal(d, &l); err != nil {
+			log.Debugf(ctx, "Can't parse GSettings schema %s: %v", f, err)
+			continue
+		}
+		for _, e := range l.Enums {
+			enums[e.ID] = e.nicks()
+		}
+		for _, e := range l.Flags {
+			flags[e.ID] = e.nicks()
+		}
+		lists = append(lists, l)
+	}
+
+	for _, l := range lists {
+		for _, s := range l.Schemas {
+			domain := s.GettextDomain
+			if domain == "" {
+				domain = l.GettextDomain
+			}
+
+			keys := make(map[string]keyMembers)
+			localized := make(map[string]localizedDefault)
+			for _, k := range s.Keys {
+				// Keys whose enum or flags type couldn't be loaded are not validated, rather than rejecting any value.
+				switch {
+				case k.Enum != "":
+					members, ok := enums[k.Enum]
+					if !ok {
+						log.Debugf(ctx, "Enum %s of GSettings key %s of %s is not defined: its values won't be validated", k.Enum, k.Name, s.ID)
+						break
+					}
+					keys[k.Name] = keyMembers{typeID: k.Enum, members: members}
+				case k.Flags != "":
+					members, ok := flags[k.Flags]
+					if !ok {
+						log.Debugf(ctx, "Flags %s of GSettings key %s of %s are not defined: its values won't be validated", k.Flags, k.Name, s.ID)
+						break
+					}
+					keys[k.Name] = keyMembers{typeID: k.Flags, flags: true, members: members}
+				}
+				// Only messages are translated according to the user locale.
+				if k.Default.L10n == "messages" && domain != "" {
+					localized[k.Name] = localizedDefault{
+						domain:  domain,
+						context: k.Default.Context,
+						value:   strings.TrimSpace(k.Default.Value),
+					}
+				}
+			}
+			if len(keys) > 0 {
+				idx.byID[s.ID] = keys
+				if s.Path != "" {
+					idx.byPath[s.Path] = keys
+				}
+			}
+			if len(localized) > 0 {
+				idx.l10nByID[s.ID] = localized
+				if s.Path != "" {
+					idx.l10nByPath[s.Path] = localized
+				}
+			}
+		}
+	}
+
+	return idx
+}
+
+func (e xmlEnum) nicks() []string {
+	var r []string
+	for _, v := range e.Values {
+		r = append(r, v.Nick)
+	}
+	return r
+}
+
+// validate checks that value, of type meta, is a declared member of the enum or flags type of the key, if any.
+// Relocatable keys are looked up by their schema id, other keys by the path of their section.
+// value is expected to have been checked against meta already.
+func (idx schemaIndex) validate(section, key, schema, meta, value string) error {
+	k, ok := lookupKey(idx.byID, idx.byPath, section, key, schema)
+	if !ok {
+		return nil
+	}
+
+	sig, err := dbus.ParseSignature(meta)
+	if err != nil {
+		return err
+	}
+	v, err := dbus.ParseVariant(value, sig)
+	if err != nil {
+		return err
+	}
+
+	var vals []string
+	switch val := v.Value().(type) {
+	case string:
+		if k.flags {
+			return errors.New(gotext.Get("flags %s expects a list of values", k.typeID))
+		}
+		vals = []string{val}
+	case []string:
+		if !k.flags {
+			return errors.New(gotext.Get("enum %s expects a single value", k.typeID))
+		}
+		vals = val
+	default:
+		return errors.New(gotext.Get("%s is not a valid value for %s", value, k.typeID))
+	}
+
+	for _, val := range vals {
+		if !slices.Contains(k.members, val) {
+			return errors.New(gotext.Get("%q is not a valid member of %s. Valid members are: %s", val, k.typeID, strings.Join(k.members, ", ")))
+		}
+	}
+	return nil
+}
+
+// localize returns the translation of value in the locale of tr if value is the localized default of the key.
+// The admin then requested the default value: users get it in their own language, as GSettings would do.
+// Any other value is returned unchanged.
+func (idx schemaIndex) localize(ctx context.Context, tr *translator, section, key, schema, value string) string {
+	d, ok := lookupKey(idx.l10nByID, idx.l10nByPath, section, key, schema)
+	if !ok || strings.TrimSpace(value) != d.value {
+		return value
+	}
+	return tr.translate(ctx, d.domain, d.context, d.value)
+}
+
+// lookupKey returns the indexed key, looking it up by schema id for relocatable keys, by section path otherwise.
+func lookupKey[T any](byID, byPath map[string]map[string]T, section, key, schema string) (T, bool) {
+	keys := byID[schema]
+	if schema == "" {
+		keys = byPath["/"+strings.Trim(section, "/")+"/"]
+	}
+	k, ok := keys[key]
+	return k, ok
+}
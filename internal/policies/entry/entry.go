@@ -13,6 +13,11 @@ type Entry struct {
 	// Strategy are overlay rules for the same keys between multiple GPOs.
 	// Default (empty or unknown value) means "override".
 	Strategy string `yaml:",omitempty"`
+	// Lock requests the key to be locked, independently of its value being set or not.
+	// If nil, the manager decides depending on its default behavior.
+	Lock *bool `yaml:",omitempty"`
+	// Delete removes the key set by GPOs with lower precedence. It has no value and is not applied on its own.
+	Delete bool `yaml:",omitempty"`
 	// Err is set if there was an error parsing the entry. It is ignored if the
 	// underlying key is not supported by adsys.
 	Err error `yaml:"-"`
@@ -0,0 +1,38 @@
+package policies
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+)
+
+// cachedRules returns the rules of the policies cached for objectName, or none if there is no valid cache.
+func (m *Manager) cachedRules(ctx context.Context, objectName string) map[string][]entry.Entry {
+	pols, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, objectName))
+	if err != nil {
+		return nil
+	}
+	defer pols.Close()
+	return pols.GetUniqueRules()
+}
+
+// emitApplied emits the Applied signal on dbus once a policy update of objectName completed, successfully or not
+// depending on applyErr.
+// The signal signature is (target string, isComputer bool, success bool, error string, changed []string), where
+// changed lists, in the Managers order, the policy managers which completed with different rules than the ones
+// previously applied.
+func (m *Manager) emitApplied(ctx context.Context, objectName string, isComputer bool, changed []string, applyErr error) {
+	var errMsg string
+	if applyErr != nil {
+		errMsg = applyErr.Error()
+	}
+
+	signal := consts.AdsysDbusPoliciesInterface + "." + consts.AdsysDbusAppliedSignal
+	if err := m.bus.Emit(dbus.ObjectPath(consts.AdsysDbusObjectPath), signal, objectName, isComputer, applyErr == nil, errMsg, changed); err != nil {
+		log.Warningf(ctx, "Can't emit %s dbus signal for %s: %v", signal, objectName, err)
+	}
+}
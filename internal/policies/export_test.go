@@ -1,12 +1,17 @@
 package policies
 
 import (
+	"context"
+	"os/user"
+	"time"
+
 	"github.com/ubuntu/adsys/internal/policies/gdm"
 )
 
 const (
-	PoliciesAssetsFileName = policiesAssetsFileName
-	PoliciesFileName       = policiesFileName
+	PoliciesAssetsFileName    = policiesAssetsFileName
+	PoliciesFileName          = policiesFileName
+	PoliciesChecksumsFileName = policiesChecksumsFileName
 )
 
 // WithGDM specifies a personalized gdm manager.
@@ -17,6 +22,41 @@ func WithGDM(m *gdm.Manager) Option {
 	}
 }
 
+// WithUserLookup allows to mock system user lookup.
+func WithUserLookup(userLookup func(string) (*user.User, error)) Option {
+	return func(o *options) error {
+		o.userLookup = userLookup
+		return nil
+	}
+}
+
+// WithClock specifies a personalized clock to time the policy managers.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) error {
+		o.now = now
+		return nil
+	}
+}
+
 func (pols Policies) HasAssets() bool {
 	return pols.assets != nil
 }
+
+// LockApply holds the apply lock as the machine policy application does, or as a user one does if isComputer is false,
+// until the returned function is called.
+func (m *Manager) LockApply(ctx context.Context, isComputer bool) (unlock func(), err error) {
+	return m.lockApply(ctx, "test", isComputer)
+}
+
+// ApplyLocked returns true if a policy application holds the apply lock.
+func (m *Manager) ApplyLocked() bool {
+	return m.applyLockHolders.Load() > 0
+}
+
+// Variables returns the values of the variables expanded in the policies of objectName.
+var Variables = variables
+
+// ExpandVariables returns a copy of pols with the variables in the entry values replaced by their value in vars.
+func (pols Policies) ExpandVariables(vars map[string]string) (Policies, error) {
+	return pols.expandVariables(vars)
+}
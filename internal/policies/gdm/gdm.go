@@ -78,3 +78,20 @@ func (m *Manager) ApplyPolicy(ctx context.Context, entries []entry.Entry) (err e
 
 	return nil
 }
+
+// SimulatePolicy returns the files ApplyPolicy would write for entries, by path, without modifying the system.
+func (m *Manager) SimulatePolicy(ctx context.Context, entries []entry.Entry) (files map[string]string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't simulate gdm policy"))
+
+	var dconfEntries []entry.Entry
+	for _, e := range entries {
+		keyType, key, _ := strings.Cut(e.Key, "/")
+		if keyType != "dconf" {
+			continue
+		}
+		e.Key = key
+		dconfEntries = append(dconfEntries, e)
+	}
+
+	return m.dconf.SimulatePolicy(ctx, "gdm", false, dconfEntries)
+}
@@ -7,6 +7,8 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/policies/dconf"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 )
 
@@ -14,13 +16,19 @@ import (
 type GPO struct {
 	ID   string
 	Name string
+	// Enforced is set for GPOs linked with enforcement, which take precedence over GPOs closer to the object.
+	Enforced bool `yaml:",omitempty"`
 	// the string is the domain of rules (dconf, install…)
 	Rules map[string][]entry.Entry
 }
 
-// Format write to w a formatted GPO. overridden entries are prepended with -.
+// Format write to w a formatted GPO. overridden entries are prepended with -, deleted keys with x.
 func (g GPO) Format(w io.Writer, withRules, withOverridden bool, alreadyProcessedRules map[string]struct{}) map[string]struct{} {
-	fmt.Fprintf(w, "* %s (%s)\n", g.Name, g.ID)
+	if g.Enforced {
+		fmt.Fprintf(w, "* %s (%s) [%s]\n", g.Name, g.ID, gotext.Get("enforced"))
+	} else {
+		fmt.Fprintf(w, "* %s (%s)\n", g.Name, g.ID)
+	}
 
 	if !withRules {
 		return nil
@@ -36,6 +44,8 @@ func (g GPO) Format(w io.Writer, withRules, withOverridden bool, alreadyProcesse
 	}
 	sort.Strings(domains)
 
+	// Deletions only override the next GPOs, the GPO can set the key again.
+	var deleted []string
 	for _, d := range domains {
 		fmt.Fprintf(w, "** %s:\n", d)
 		for _, r := range g.Rules[d] {
@@ -50,6 +60,12 @@ func (g GPO) Format(w io.Writer, withRules, withOverridden bool, alreadyProcesse
 			}
 			// Trim EOL \n and replace them all with \n in text to keep each value printed in one single line
 			v := strings.ReplaceAll(strings.TrimSpace(r.Value), "\n", `\n`)
+			if r.Delete {
+				prefix += "x"
+				fmt.Fprintf(w, "%s %s\n", prefix, r.Key)
+				deleted = append(deleted, k)
+				continue
+			}
 			if r.Disabled {
 				prefix += "+"
 				fmt.Fprintf(w, "%s %s\n", prefix, r.Key)
@@ -64,6 +80,117 @@ func (g GPO) Format(w io.Writer, withRules, withOverridden bool, alreadyProcesse
 			alreadyProcessedRules[k] = struct{}{}
 		}
 	}
+	for _, k := range deleted {
+		alreadyProcessedRules[k] = struct{}{}
+	}
 
 	return alreadyProcessedRules
 }
+
+// formatResolvedRules write to w the rules resolved between all GPOs, with the GPOs they come from.
+// Each entry is followed by the GPOs it comes from, prepended with ****.
+func formatResolvedRules(w io.Writer, pols Policies) {
+	resolved := pols.ResolveRules()
+
+	var domains []string
+	for domain, entries := range resolved {
+		if len(entries) == 0 {
+			continue
+		}
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		return
+	}
+	sort.Strings(domains)
+
+	fmt.Fprintf(w, "** %s\n", gotext.Get("Applied rules:"))
+	for _, d := range domains {
+		fmt.Fprintf(w, "** %s:\n", d)
+		for _, r := range resolved[d] {
+			var sources []string
+			for _, s := range r.Sources {
+				sources = append(sources, fmt.Sprintf("%s (%s)", s.Name, s.ID))
+			}
+			if r.Disabled {
+				fmt.Fprintf(w, "***+ %s\n", r.Key)
+			} else {
+				// Trim EOL \n and replace them all with \n in text to keep each value printed in one single line
+				v := strings.ReplaceAll(strings.TrimSpace(r.Value), "\n", `\n`)
+				fmt.Fprintf(w, "*** %s: %s\n", r.Key, v)
+			}
+			fmt.Fprintf(w, "**** %s\n", strings.Join(sources, ", "))
+		}
+	}
+}
+
+// dconfConflicts returns the dconf keys that multiple gpos set to different values.
+// Keys deleted by a GPO are not set by the ones with lower precedence, which thus don't conflict.
+func dconfConflicts(gpos []GPO) []dconf.Conflict {
+	var entries []dconf.SourcedEntry
+	deleted := make(map[string]struct{})
+	for _, g := range gpos {
+		var gpoDeleted []string
+		for _, e := range g.Rules["dconf"] {
+			if e.Delete {
+				gpoDeleted = append(gpoDeleted, e.Key)
+				continue
+			}
+			if _, ok := deleted[e.Key]; ok {
+				continue
+			}
+			entries = append(entries, dconf.SourcedEntry{Entry: e, GPO: g.Name})
+		}
+		for _, k := range gpoDeleted {
+			deleted[k] = struct{}{}
+		}
+	}
+	return dconf.FindConflicts(entries)
+}
+
+// dconfLayers returns the resolved dconf entries grouped by the GPO they come from, in GPO precedence order.
+// Entries appended from multiple GPOs belong to the one with the highest precedence.
+func dconfLayers(pols *Policies) [][]entry.Entry {
+	byGPO := make(map[string][]entry.Entry)
+	for _, e := range pols.ResolveRules()["dconf"] {
+		id := e.Sources[0].ID
+		byGPO[id] = append(byGPO[id], e.Entry)
+	}
+
+	var layers [][]entry.Entry
+	for _, g := range pols.GPOs {
+		if entries, ok := byGPO[g.ID]; ok {
+			layers = append(layers, entries)
+			delete(byGPO, g.ID)
+		}
+	}
+	return layers
+}
+
+// gpoNames returns the names of the GPOs of pols, in GPO precedence order.
+func gpoNames(pols *Policies) []string {
+	names := make([]string, 0, len(pols.GPOs))
+	for _, g := range pols.GPOs {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// conflictValue returns the resolved value of c, printed in one single line.
+func conflictValue(c dconf.Conflict) string {
+	if c.Disabled {
+		return gotext.Get("Locked to system default")
+	}
+	return strings.ReplaceAll(strings.TrimSpace(c.Value), "\n", `\n`)
+}
+
+// formatConflicts write to w the formatted list of conflicts, if any. Conflicting keys are prepended with !!!.
+func formatConflicts(w io.Writer, conflicts []dconf.Conflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "!! %s\n", gotext.Get("Conflicting dconf keys:"))
+	for _, c := range conflicts {
+		fmt.Fprintf(w, "!!! %s\n", gotext.Get("%s: %s (from %s, overriding %s)", c.Key, conflictValue(c), c.GPO, strings.Join(c.Overridden, ", ")))
+	}
+}
@@ -0,0 +1,215 @@
+// Package hosts provides a manager to apply static host name mappings to the machine.
+//
+// The mappings are written to /etc/hosts, in a block delimited by markers. The block is appended at the end of the
+// file on first apply, and then updated in place. Lines outside of it are never modified: the block is only removed
+// from the file when the policy becomes empty or is disabled.
+//
+// Each mapping is an IP address followed by one or more host names, separated by spaces. Should any of them be
+// invalid, the whole policy fails and the previously applied block is kept.
+//
+// The policy only applies to the machine: user policies are ignored.
+package hosts
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/audit"
+	"github.com/ubuntu/adsys/internal/fileutils"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/netaddr"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// beginMarker and endMarker delimit the block of the hosts file managed by adsys.
+	beginMarker = "# BEGIN ADSYS MANAGED HOSTS - DO NOT EDIT"
+	endMarker   = "# END ADSYS MANAGED HOSTS"
+
+	// hostsKey is the entry key of the static host mappings.
+	hostsKey = "static-hosts"
+)
+
+// Manager prevents running multiple hosts update processes in parallel while applying the policy.
+type Manager struct {
+	hostsFile string
+	mu        sync.Mutex
+}
+
+type options struct {
+	hostsFile string
+}
+
+// Option represents an optional function to change the hosts manager.
+type Option func(*options)
+
+// WithHostsFile overrides the default hosts file.
+func WithHostsFile(p string) Option {
+	return func(o *options) {
+		o.hostsFile = p
+	}
+}
+
+// New returns a new hosts policy manager.
+func New(opts ...Option) *Manager {
+	args := options{
+		hostsFile: "/etc/hosts",
+	}
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Manager{
+		hostsFile: args.hostsFile,
+	}
+}
+
+// ApplyPolicy writes the static host mappings of entries to the adsys block of the hosts file, or removes the block
+// if there is none.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply hosts policy to %s", objectName))
+
+	// Static host mappings only apply to the machine
+	if !isComputer {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var mappings []string
+	for _, e := range entries {
+		if e.Key != hostsKey {
+			log.Warning(ctx, gotext.Get("Encountered unsupported key '%s' while parsing hosts entries, skipping it", e.Key))
+			continue
+		}
+		if e.Disabled {
+			log.Debug(ctx, gotext.Get("The entry %q is disabled and will be skipped", e.Key))
+			continue
+		}
+		if mappings, err = parseMappings(e.Value); err != nil {
+			return err
+		}
+	}
+
+	log.Debugf(ctx, "Applying hosts policy to %s", objectName)
+
+	content, mode, err := m.readHostsFile()
+	if err != nil {
+		return err
+	}
+	newContent, err := withBlock(content, mappings)
+	if err != nil {
+		return err
+	}
+	if newContent == content {
+		return nil
+	}
+
+	// The hosts file is only created when there are mappings to write.
+	changed, err := fileutils.WriteIfChanged(m.hostsFile, []byte(newContent), mode)
+	if err != nil {
+		return err
+	}
+	if changed {
+		audit.RecordFile(ctx, m.hostsFile)
+	}
+	return nil
+}
+
+// readHostsFile returns the content of the hosts file and its mode. A hosts file which doesn't exist is empty.
+func (m *Manager) readHostsFile() (content string, mode fs.FileMode, err error) {
+	// The hosts file is world-readable
+	mode = 0644
+
+	d, err := os.ReadFile(m.hostsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", mode, nil
+	} else if err != nil {
+		return "", mode, err
+	}
+
+	info, err := os.Stat(m.hostsFile)
+	if err != nil {
+		return "", mode, err
+	}
+	return string(d), info.Mode().Perm(), nil
+}
+
+// parseMappings returns the normalized static host mappings of the lines of value, in order and without duplicates.
+// Blank lines are skipped.
+func parseMappings(value string) (mappings []string, err error) {
+	for _, l := range strings.Split(value, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, errors.New(gotext.Get("invalid host mapping %q: expected an IP address followed by host names", l))
+		}
+
+		if _, err := netip.ParseAddr(fields[0]); err != nil {
+			return nil, errors.New(gotext.Get("invalid IP address %q in host mapping %q", fields[0], l))
+		}
+		for _, name := range fields[1:] {
+			if !netaddr.ValidHostname(name) {
+				return nil, errors.New(gotext.Get("invalid host name %q in host mapping %q", name, l))
+			}
+		}
+
+		mapping := strings.Join(fields, " ")
+		if slices.Contains(mappings, mapping) {
+			continue
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// withBlock returns content, the content of a hosts file, with its adsys block replaced by mappings.
+// A missing block is appended at the end of the file. The block is removed if there are no mappings.
+func withBlock(content string, mappings []string) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	isBegin := func(l string) bool { return strings.TrimSpace(l) == beginMarker }
+	isEnd := func(l string) bool { return strings.TrimSpace(l) == endMarker }
+
+	begin, end := slices.IndexFunc(lines, isBegin), len(lines)
+	if begin != -1 {
+		i := slices.IndexFunc(lines[begin:], isEnd)
+		if i == -1 {
+			return "", errors.New(gotext.Get("adsys block has no end marker %q", endMarker))
+		}
+		end = begin + i + 1
+	} else if slices.ContainsFunc(lines, isEnd) {
+		return "", errors.New(gotext.Get("adsys block has no begin marker %q", beginMarker))
+	} else {
+		begin = len(lines)
+		// Start the appended block on its own line.
+		if begin > 0 && len(mappings) > 0 && !strings.HasSuffix(lines[begin-1], "\n") {
+			lines[begin-1] += "\n"
+		}
+	}
+
+	var block []string
+	if len(mappings) > 0 {
+		block = append(block, beginMarker+"\n")
+		for _, mapping := range mappings {
+			block = append(block, mapping+"\n")
+		}
+		block = append(block, endMarker+"\n")
+	}
+
+	return strings.Join(slices.Concat(lines[:begin], block, lines[end:]), ""), nil
+}
@@ -0,0 +1,145 @@
+package hosts_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/hosts"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultMappings := []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 server1.example.com server1\n10.0.0.3 server3.example.com"}}
+
+	tests := map[string]struct {
+		entries  []entry.Entry
+		isUser   bool
+		existing string
+
+		wantNoFile bool
+		wantErr    bool
+	}{
+		// Insertion cases
+		"Insert block at the end of the hosts file":                             {entries: defaultMappings, existing: "no_block"},
+		"Insert block on its own line when the hosts file has no final newline": {entries: defaultMappings, existing: "no_trailing_newline"},
+		"Insert block in a new hosts file":                                      {entries: defaultMappings},
+		"Insert block with IPv6 mappings": {
+			entries:  []entry.Entry{{Key: "static-hosts", Value: "fd00::1 server1.example.com\nfe80::1%eth0 router.local"}},
+			existing: "no_block",
+		},
+		"Insert block with normalized and deduplicated mappings": {
+			entries:  []entry.Entry{{Key: "static-hosts", Value: "  10.0.0.1\tserver1.example.com   server1\n\n10.0.0.1 server1.example.com server1\n10.0.0.4 server4.example.com.\n"}},
+			existing: "no_block",
+		},
+
+		// Update cases
+		"Update block in place":                {entries: defaultMappings, existing: "with_block"},
+		"Unchanged block keeps the hosts file": {entries: []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 server1.example.com server1\n10.0.0.2 server2.example.com"}}, existing: "with_block"},
+		"Unsupported keys are ignored": {
+			entries:  []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 server1.example.com server1"}, {Key: "unsupported", Value: "10.0.0.2 server2"}},
+			existing: "with_block",
+		},
+
+		// Removal cases
+		"Remove block when there are no entries":                {existing: "with_block"},
+		"Remove block when the entry is disabled":               {entries: []entry.Entry{{Key: "static-hosts", Disabled: true}}, existing: "with_block"},
+		"Remove block when the entry has no values":             {entries: []entry.Entry{{Key: "static-hosts", Value: "\n  \n"}}, existing: "with_block"},
+		"No entries keeps the hosts file without block":         {existing: "no_block"},
+		"No entries keeps the hosts file without final newline": {existing: "no_trailing_newline"},
+		"No entries doesn't create the hosts file":              {wantNoFile: true},
+
+		// User cases
+		"User policy is ignored":               {entries: defaultMappings, existing: "no_block", isUser: true},
+		"User policy doesn't remove the block": {existing: "with_block", isUser: true},
+
+		// Error cases
+		"Error on invalid IP address":           {entries: []entry.Entry{{Key: "static-hosts", Value: "10.0.0.256 server1"}}, existing: "with_block", wantErr: true},
+		"Error on host name as IP address":      {entries: []entry.Entry{{Key: "static-hosts", Value: "server1 server1.example.com"}}, existing: "with_block", wantErr: true},
+		"Error on mapping without host name":    {entries: []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1"}}, existing: "with_block", wantErr: true},
+		"Error on host name with invalid chars": {entries: []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 server_1.example.com"}}, existing: "with_block", wantErr: true},
+		"Error on host name starting with hyphen": {
+			entries: []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 -server1.example.com"}}, existing: "with_block", wantErr: true,
+		},
+		"Error on host name with empty label":        {entries: []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 server1..example.com"}}, existing: "with_block", wantErr: true},
+		"Error on host name as malformed IP address": {entries: []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 10.0.0.256"}}, existing: "with_block", wantErr: true},
+		"Error on host name with too long label": {
+			entries:  []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 a123456789012345678901234567890123456789012345678901234567890123.example.com"}},
+			existing: "with_block", wantErr: true,
+		},
+		"Error on one invalid mapping among valid ones": {
+			entries:  []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 server1.example.com\n10.0.0.2 server2.example.com#comment"}},
+			existing: "with_block", wantErr: true,
+		},
+		"Error on block without end marker":          {entries: defaultMappings, existing: "missing_end_marker", wantErr: true},
+		"Error on block without begin marker":        {entries: defaultMappings, existing: "missing_begin_marker", wantErr: true},
+		"Error on removing block without end marker": {existing: "missing_end_marker", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			hostsFile := filepath.Join(t.TempDir(), "hosts")
+			if tc.existing != "" {
+				testutils.Copy(t, filepath.Join("testdata", "hosts", tc.existing), hostsFile)
+			}
+
+			m := hosts.New(hosts.WithHostsFile(hostsFile))
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.isUser, tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+
+				// The hosts file is left untouched.
+				got, err := os.ReadFile(hostsFile)
+				require.NoError(t, err, "Hosts file should still exist")
+				want, err := os.ReadFile(filepath.Join("testdata", "hosts", tc.existing))
+				require.NoError(t, err, "Setup: can't read original hosts file")
+				require.Equal(t, string(want), string(got), "Hosts file should not have been modified")
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy should succeed but didn't")
+
+			if tc.wantNoFile {
+				require.NoFileExists(t, hostsFile, "Hosts file should not have been created")
+				return
+			}
+
+			got, err := os.ReadFile(hostsFile)
+			require.NoError(t, err, "Hosts file should exist")
+			want := testutils.LoadWithUpdateFromGolden(t, string(got))
+			require.Equal(t, want, string(got), "Hosts file doesn't match golden file")
+		})
+	}
+}
+
+func TestApplyPolicyIsIdempotent(t *testing.T) {
+	t.Parallel()
+
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	testutils.Copy(t, filepath.Join("testdata", "hosts", "no_block"), hostsFile)
+	original, err := os.ReadFile(hostsFile)
+	require.NoError(t, err, "Setup: can't read hosts file")
+
+	entries := []entry.Entry{{Key: "static-hosts", Value: "10.0.0.1 server1.example.com server1"}}
+	m := hosts.New(hosts.WithHostsFile(hostsFile))
+
+	require.NoError(t, m.ApplyPolicy(context.Background(), "ubuntu", true, entries), "Setup: first ApplyPolicy should succeed")
+	first, err := os.ReadFile(hostsFile)
+	require.NoError(t, err, "Setup: can't read hosts file")
+
+	require.NoError(t, m.ApplyPolicy(context.Background(), "ubuntu", true, entries), "Second ApplyPolicy should succeed")
+	second, err := os.ReadFile(hostsFile)
+	require.NoError(t, err, "Hosts file should exist")
+	require.Equal(t, string(first), string(second), "Applying the same policy again should not change the hosts file")
+
+	require.NoError(t, m.ApplyPolicy(context.Background(), "ubuntu", true, nil), "Removing the policy should succeed")
+	removed, err := os.ReadFile(hostsFile)
+	require.NoError(t, err, "Hosts file should exist")
+	require.Equal(t, string(original), string(removed), "Removing the policy should restore the original hosts file")
+}
@@ -27,27 +27,34 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/user"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys/internal/ad/backends"
+	"github.com/ubuntu/adsys/internal/audit"
 	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/metrics"
 	"github.com/ubuntu/adsys/internal/policies/apparmor"
 	"github.com/ubuntu/adsys/internal/policies/certificate"
 	"github.com/ubuntu/adsys/internal/policies/dconf"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/policies/gdm"
+	"github.com/ubuntu/adsys/internal/policies/hosts"
 	"github.com/ubuntu/adsys/internal/policies/mount"
 	"github.com/ubuntu/adsys/internal/policies/privilege"
 	"github.com/ubuntu/adsys/internal/policies/proxy"
 	"github.com/ubuntu/adsys/internal/policies/scripts"
+	"github.com/ubuntu/adsys/internal/policies/timesync"
 	"github.com/ubuntu/adsys/internal/systemd"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
@@ -55,12 +62,17 @@ import (
 
 // ProOnlyRules are the rules that are only available for Pro subscribers. They
 // will be filtered otherwise.
-var ProOnlyRules = []string{"privilege", "scripts", "mount", "apparmor", "proxy", "certificate"}
+var ProOnlyRules = []string{"privilege", "scripts", "mount", "apparmor", "proxy", "certificate", "hosts", "timesync"}
+
+// Managers are the names of the policy managers, which are the policy types they apply. The gdm one only applies
+// to the machine.
+var Managers = []string{"dconf", "privilege", "scripts", "mount", "apparmor", "proxy", "certificate", "hosts", "timesync", "gdm"}
 
 // Manager handles all managers for various policy handlers.
 type Manager struct {
-	policiesCacheDir string
-	hostname         string
+	policiesCacheDir  string
+	snapshotsCacheDir string
+	hostname          string
 
 	backend backends.Backend
 
@@ -72,19 +84,47 @@ type Manager struct {
 	apparmor    *apparmor.Manager
 	proxy       *proxy.Manager
 	certificate *certificate.Manager
+	hosts       *hosts.Manager
+	timesync    *timesync.Manager
+
+	// dconfUserLayers applies the dconf policy of users in one database per GPO.
+	dconfUserLayers bool
 
+	// disabledManagers don't apply any rule. They only run to clean up what they previously applied if
+	// cleanupDisabled is set.
+	disabledManagers []string
+	cleanupDisabled  bool
+
+	bus              *dbus.Conn
 	subscriptionDbus dbus.BusObject
 
 	// muMu protects the objectMu mutex.
 	muMu *sync.Mutex
 	// objectMu prevents applying multiple policies concurrently for the same object.
 	objectMu map[string]*sync.Mutex
+	// applyMu serializes the machine policy application with the users ones, waiting for up to applyLockTimeout.
+	applyMu          sync.RWMutex
+	applyLockHolders atomic.Int32
+	applyLockTimeout time.Duration
+
+	metrics *metrics.Metrics
+	audit   *audit.Log
+
+	userLookup func(string) (*user.User, error)
+
+	now func() time.Time
+	// timingsMu protects timings, the time each manager took during the last policy application of each object, and
+	// unchanged, the managers which completed with the same rules as previously applied.
+	timingsMu *sync.Mutex
+	timings   map[string][]ManagerTiming
+	unchanged map[string][]string
 }
 
 // systemdCaller is the interface to interact with systemd.
 type systemdCaller interface {
 	StartUnit(context.Context, string) error
 	StopUnit(context.Context, string) error
+	TryRestartUnit(context.Context, string) error
 
 	EnableUnit(context.Context, string) error
 	DisableUnit(context.Context, string) error
@@ -104,15 +144,35 @@ type options struct {
 	apparmorFsDir  string
 	systemUnitDir  string
 	globalTrustDir string
+	hostsFile      string
+	chronyConfDir  string
+	timesyncdDir   string
 	proxyApplier   proxy.Caller
 	systemdCaller  systemdCaller
 	gdm            *gdm.Manager
 
 	apparmorParserCmd []string
 	certAutoenrollCmd []string
+	snapdSocket       string
+	flatpakDir        string
+
+	dconfUpdateDebounce time.Duration
+	dconfUserLayers     bool
+	applyLockTimeout    time.Duration
+
+	disabledManagers []string
+	cleanupDisabled  bool
+
+	certRenewalLeadTime time.Duration
+
+	metrics  *metrics.Metrics
+	auditLog *audit.Log
+
+	userLookup func(string) (*user.User, error)
+	now        func() time.Time
 }
 
-// Option reprents an optional function to change Policies behavior.
+// Option represents an optional function to change Policies behavior.
 type Option func(*options) error
 
 // WithCacheDir specifies a personalized daemon cache directory.
@@ -213,6 +273,38 @@ func WithGlobalTrustDir(p string) Option {
 	}
 }
 
+// WithHostsFile specifies a personalized hosts file.
+func WithHostsFile(p string) Option {
+	return func(o *options) error {
+		o.hostsFile = p
+		return nil
+	}
+}
+
+// WithChronyConfDir specifies a personalized chrony configuration drop-in directory.
+func WithChronyConfDir(p string) Option {
+	return func(o *options) error {
+		o.chronyConfDir = p
+		return nil
+	}
+}
+
+// WithTimesyncdConfDir specifies a personalized systemd-timesyncd configuration drop-in directory.
+func WithTimesyncdConfDir(p string) Option {
+	return func(o *options) error {
+		o.timesyncdDir = p
+		return nil
+	}
+}
+
+// WithCertificateRenewalLeadTime specifies how long before their expiry the enrolled certificates are renewed.
+func WithCertificateRenewalLeadTime(d time.Duration) Option {
+	return func(o *options) error {
+		o.certRenewalLeadTime = d
+		return nil
+	}
+}
+
 // WithProxyApplier specifies a personalized proxy applier for the proxy policy manager.
 func WithProxyApplier(p proxy.Caller) Option {
 	return func(o *options) error {
@@ -237,6 +329,84 @@ func WithCertAutoenrollCmd(cmd []string) Option {
 	}
 }
 
+// WithSnapdSocket specifies a personalized snapd REST API socket, used to apply the proxy settings to snapd.
+func WithSnapdSocket(p string) Option {
+	return func(o *options) error {
+		o.snapdSocket = p
+		return nil
+	}
+}
+
+// WithFlatpakDir specifies a personalized flatpak system installation directory, used to apply the proxy settings to
+// flatpak applications.
+func WithFlatpakDir(p string) Option {
+	return func(o *options) error {
+		o.flatpakDir = p
+		return nil
+	}
+}
+
+// WithDconfUpdateDebounce delays dconf updates to coalesce them until FlushUpdates is called.
+func WithDconfUpdateDebounce(d time.Duration) Option {
+	return func(o *options) error {
+		o.dconfUpdateDebounce = d
+		return nil
+	}
+}
+
+// WithDconfUserLayers applies the dconf policy of users in one database per GPO, stacked in the user profile in
+// GPO precedence order, instead of a single database.
+func WithDconfUserLayers(enabled bool) Option {
+	return func(o *options) error {
+		o.dconfUserLayers = enabled
+		return nil
+	}
+}
+
+// WithDisabledManagers disables the policy managers named in names: they are skipped when applying the policies,
+// leaving what they previously applied as is, unless cleanup is set. They then run without any rule, to remove it.
+func WithDisabledManagers(names []string, cleanup bool) Option {
+	return func(o *options) error {
+		for _, name := range names {
+			if !slices.Contains(Managers, name) {
+				return errors.New(gotext.Get("unknown policy manager %q to disable, expected one of: %s", name, strings.Join(Managers, ", ")))
+			}
+		}
+		// The gdm policy is applied on top of the machine dconf database.
+		if slices.Contains(names, "dconf") && !slices.Contains(names, "gdm") {
+			return errors.New(gotext.Get("the gdm policy manager relies on the dconf one: it must be disabled too"))
+		}
+		o.disabledManagers = names
+		o.cleanupDisabled = cleanup
+		return nil
+	}
+}
+
+// WithApplyLockTimeout specifies how long a policy application waits for the one in progress to complete, before
+// failing with ErrApplyInProgress.
+func WithApplyLockTimeout(d time.Duration) Option {
+	return func(o *options) error {
+		o.applyLockTimeout = d
+		return nil
+	}
+}
+
+// WithMetrics records the policy applications and the runs of each policy manager in m.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) error {
+		o.metrics = m
+		return nil
+	}
+}
+
+// WithAuditLog records the changes made by each policy application in l.
+func WithAuditLog(l *audit.Log) Option {
+	return func(o *options) error {
+		o.auditLog = l
+		return nil
+	}
+}
+
 // NewManager returns a new manager with all default policy handlers.
 func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts ...Option) (m *Manager, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't create a new policy handlers manager"))
@@ -257,6 +427,10 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 		globalTrustDir: consts.DefaultGlobalTrustDir,
 		systemdCaller:  defaultSystemdCaller,
 		gdm:            nil,
+		userLookup:     user.Lookup,
+		now:            time.Now,
+
+		applyLockTimeout: consts.DefaultApplyLockTimeout,
 	}
 	// applied options (including dconf manager used by gdm)
 	for _, o := range opts {
@@ -264,29 +438,37 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 			return nil, err
 		}
 	}
-	// dconf manager
-	dconfManager := &dconf.Manager{}
-	if args.dconfDir != "" {
-		dconfManager = dconf.NewWithDconfDir(args.dconfDir)
+	if args.auditLog != nil {
+		args.systemdCaller = auditedSystemd{args.systemdCaller}
 	}
+	// dconf manager
+	dconfManager := dconf.NewWithDconfDir(args.dconfDir, dconf.WithUpdateDebounce(args.dconfUpdateDebounce))
 
 	// privilege manager
-	privilegeManager := privilege.NewWithDirs(args.sudoersDir, args.policyKitDir)
+	privilegeManager := privilege.NewWithDirs(args.sudoersDir, args.policyKitDir,
+		privilege.WithStateDir(args.stateDir),
+		privilege.WithSystemUnitDir(args.systemUnitDir),
+		privilege.WithSystemdCaller(args.systemdCaller))
 
 	// scripts manager
-	scriptsManager, err := scripts.New(args.runDir, args.systemdCaller)
+	scriptsManager, err := scripts.New(args.runDir, args.systemdCaller,
+		scripts.WithStateDir(args.stateDir),
+		scripts.WithSystemUnitDir(args.systemUnitDir),
+		scripts.WithUserUnitDir(filepath.Join(filepath.Dir(args.systemUnitDir), "user")),
+		scripts.WithHostname(hostname),
+		scripts.WithDomain(backend.Domain()))
 	if err != nil {
 		return nil, err
 	}
 
 	// mount manager
-	mountManager, err := mount.New(args.runDir, args.systemUnitDir, args.systemdCaller)
+	mountManager, err := mount.New(args.runDir, args.systemUnitDir, args.systemdCaller, mount.WithStateDir(args.stateDir))
 	if err != nil {
 		return nil, err
 	}
 
 	// apparmor manager
-	var apparmorOptions []apparmor.Option
+	apparmorOptions := []apparmor.Option{apparmor.WithStateDir(args.stateDir)}
 	if args.apparmorParserCmd != nil {
 		apparmorOptions = append(apparmorOptions, apparmor.WithApparmorParserCmd(args.apparmorParserCmd))
 	}
@@ -296,10 +478,16 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 	apparmorManager := apparmor.New(args.apparmorDir, apparmorOptions...)
 
 	// proxy manager
-	var proxyOptions []proxy.Option
+	proxyOptions := []proxy.Option{proxy.WithStateDir(args.stateDir)}
 	if args.proxyApplier != nil {
 		proxyOptions = append(proxyOptions, proxy.WithProxyApplier(args.proxyApplier))
 	}
+	if args.snapdSocket != "" {
+		proxyOptions = append(proxyOptions, proxy.WithSnapdSocket(args.snapdSocket))
+	}
+	if args.flatpakDir != "" {
+		proxyOptions = append(proxyOptions, proxy.WithFlatpakDir(args.flatpakDir))
+	}
 	proxyManager := proxy.New(bus, proxyOptions...)
 
 	// certificate manager
@@ -308,11 +496,32 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 		certificate.WithRunDir(args.runDir),
 		certificate.WithShareDir(args.shareDir),
 		certificate.WithGlobalTrustDir(args.globalTrustDir),
+		certificate.WithSystemUnitDir(args.systemUnitDir),
 	}
 	if args.certAutoenrollCmd != nil {
 		certificateOpts = append(certificateOpts, certificate.WithCertAutoenrollCmd(args.certAutoenrollCmd))
 	}
-	certificateManager := certificate.New(backend.Domain(), certificateOpts...)
+	if args.certRenewalLeadTime > 0 {
+		certificateOpts = append(certificateOpts, certificate.WithRenewalLeadTime(args.certRenewalLeadTime))
+	}
+	certificateManager := certificate.New(backend.Domain(), args.systemdCaller, certificateOpts...)
+
+	// hosts manager
+	var hostsOptions []hosts.Option
+	if args.hostsFile != "" {
+		hostsOptions = append(hostsOptions, hosts.WithHostsFile(args.hostsFile))
+	}
+	hostsManager := hosts.New(hostsOptions...)
+
+	// timesync manager
+	var timesyncOptions []timesync.Option
+	if args.chronyConfDir != "" {
+		timesyncOptions = append(timesyncOptions, timesync.WithChronyConfDir(args.chronyConfDir))
+	}
+	if args.timesyncdDir != "" {
+		timesyncOptions = append(timesyncOptions, timesync.WithTimesyncdConfDir(args.timesyncdDir))
+	}
+	timesyncManager := timesync.New(args.systemdCaller, timesyncOptions...)
 
 	// inject applied dconf mangager if we need to build a gdm manager
 	if args.gdm == nil {
@@ -330,30 +539,85 @@ func NewManager(bus *dbus.Conn, hostname string, backend backends.Backend, opts
 		dbus.ObjectPath(consts.SubscriptionDbusObjectPath))
 
 	return &Manager{
-		backend:          backend,
-		policiesCacheDir: policiesCacheDir,
-		hostname:         hostname,
-		dconf:            dconfManager,
-		privilege:        privilegeManager,
-		scripts:          scriptsManager,
-		mount:            mountManager,
-		apparmor:         apparmorManager,
-		proxy:            proxyManager,
-		certificate:      certificateManager,
-		gdm:              args.gdm,
-
+		backend:           backend,
+		policiesCacheDir:  policiesCacheDir,
+		snapshotsCacheDir: filepath.Join(args.cacheDir, SnapshotsCacheBaseName),
+		hostname:          hostname,
+		dconf:             dconfManager,
+		dconfUserLayers:   args.dconfUserLayers,
+		disabledManagers:  args.disabledManagers,
+		cleanupDisabled:   args.cleanupDisabled,
+		privilege:         privilegeManager,
+		scripts:           scriptsManager,
+		mount:             mountManager,
+		apparmor:          apparmorManager,
+		proxy:             proxyManager,
+		certificate:       certificateManager,
+		hosts:             hostsManager,
+		timesync:          timesyncManager,
+		gdm:               args.gdm,
+
+		bus:              bus,
 		subscriptionDbus: subscriptionDbus,
 
 		muMu:     &sync.Mutex{},
 		objectMu: make(map[string]*sync.Mutex),
+
+		applyLockTimeout: args.applyLockTimeout,
+
+		metrics: args.metrics,
+		audit:   args.auditLog,
+
+		userLookup: args.userLookup,
+
+		now:       args.now,
+		timingsMu: &sync.Mutex{},
+		timings:   make(map[string][]ManagerTiming),
+		unchanged: make(map[string][]string),
 	}, nil
 }
 
 // ApplyPolicies generates a computer or user policy based on a list of entries
 // retrieved from a directory service.
+// Variables in the entry values, like %COMPUTERNAME%, are expanded before handing them to the managers.
 func (m *Manager) ApplyPolicies(ctx context.Context, objectName string, isComputer bool, pols *Policies) (err error) {
+	return m.ApplyPoliciesOnly(ctx, objectName, isComputer, pols, nil)
+}
+
+// ApplyPoliciesOnly generates a computer or user policy as ApplyPolicies, restricted to the managers named in only.
+// Other managers don't run at all: they neither apply nor clean up anything. All managers run when only is empty.
+// Disabled managers are never selected: they are skipped, or only clean up if requested.
+// As the applied policies are then partial, the policies cache is only saved when all managers run.
+// The time each manager takes is recorded and available with LastTimings, and in the metrics, if any, along with the
+// outcome of the policy application. Each completed manager is reported to the progress reporter of ctx, if any.
+// The machine policy application is serialized with the users ones: ErrApplyInProgress is returned if the one in
+// progress doesn't complete within the apply lock timeout.
+// Once done, the Applied dbus signal is emitted with the outcome and the managers which changed, and the changes are
+// recorded in the audit log, if any.
+func (m *Manager) ApplyPoliciesOnly(ctx context.Context, objectName string, isComputer bool, pols *Policies, only []string) (err error) {
 	defer decorate.OnError(&err, gotext.Get("failed to apply policy to %q", objectName))
 
+	for _, name := range only {
+		if !slices.Contains(Managers, name) {
+			return errors.New(gotext.Get("unknown policy manager %q, expected one of: %s", name, strings.Join(Managers, ", ")))
+		}
+		if m.isDisabled(name) {
+			return errors.New(gotext.Get("policy manager %q is disabled", name))
+		}
+	}
+	selected := func(name string) bool {
+		if m.isDisabled(name) && !m.cleanupDisabled {
+			return false
+		}
+		return len(only) == 0 || slices.Contains(only, name)
+	}
+
+	unlock, err := m.lockApply(ctx, objectName, isComputer)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// We have a lock per objectName to prevent multiple instances of ApplyPolicies for the same object.
 	m.muMu.Lock()
 	if _, ok := m.objectMu[objectName]; !ok {
@@ -363,17 +627,86 @@ func (m *Manager) ApplyPolicies(ctx context.Context, objectName string, isComput
 	defer m.objectMu[objectName].Unlock()
 	m.muMu.Unlock()
 
-	rules := pols.GetUniqueRules()
+	// The cache keeps the policies as defined in the GPOs: only the managers get the expanded values.
+	expanded, err := pols.expandVariables(variables(m.hostname, m.backend.Domain(), objectName, isComputer))
+	if err != nil {
+		return err
+	}
+
+	rules := expanded.GetUniqueRules()
 	action := gotext.Get("Applying")
 	if len(rules) == 0 {
 		action = gotext.Get("Unloading")
 	}
-	log.Info(ctx, gotext.Get("%s policies for %s (machine: %v)", action, objectName, isComputer))
+	if len(only) > 0 {
+		log.Info(ctx, gotext.Get("%s policies for %s (machine: %v) with managers: %s", action, objectName, isComputer, strings.Join(only, ", ")))
+		for t := range rules {
+			if !selected(t) {
+				delete(rules, t)
+			}
+		}
+	} else {
+		log.Info(ctx, gotext.Get("%s policies for %s (machine: %v)", action, objectName, isComputer))
+	}
+	// Disabled managers which clean up run without any rule.
+	for _, name := range m.disabledManagers {
+		delete(rules, name)
+	}
+	if selected("dconf") && !m.isDisabled("dconf") {
+		for _, c := range dconfConflicts(expanded.GPOs) {
+			log.Warning(ctx, gotext.Get("dconf key %q is set differently by multiple GPOs: using %s from %q, overriding %s",
+				c.Key, conflictValue(c), c.GPO, strings.Join(c.Overridden, ", ")))
+		}
+	}
+
+	// Compare with the previously applied policies to report which managers changed once completed.
+	previous := m.cachedRules(ctx, objectName)
+
+	auditRecorder := audit.NewRecorder()
+	if m.audit != nil {
+		ctx = audit.WithRecorder(ctx, auditRecorder)
+	}
+
+	// gdm only applies to the machine.
+	timings := &timingsRecorder{total: len(slices.DeleteFunc(slices.Clone(Managers), func(name string) bool {
+		return !selected(name) || (name == "gdm" && !isComputer)
+	}))}
+	ReportProgress(ctx, Progress{Target: objectName, Stage: StageApply, Total: timings.total})
+	defer func() {
+		changed, unchanged := timings.changedManagers(previous, pols.GetUniqueRules())
+		m.saveTimings(objectName, timings, unchanged)
+		m.metrics.RecordApply(isComputer, err, m.now())
+		m.emitApplied(ctx, objectName, isComputer, changed, err)
+		if m.audit != nil {
+			m.audit.WriteOrWarn(ctx, m.auditEntry(objectName, isComputer, pols, previous, timings, auditRecorder, err))
+		}
+	}()
 
 	var g errgroup.Group
+	// Each manager applies with its own context, so that its changes are recorded for it in the audit log.
+	run := func(name string, apply func(ctx context.Context) error) {
+		if !selected(name) {
+			log.Debugf(ctx, "Skipping %s policy manager", name)
+			return
+		}
+		if m.isDisabled(name) {
+			log.Debugf(ctx, "Cleaning up disabled %s policy manager", name)
+		}
+		g.Go(func() error {
+			ctx := audit.WithManager(ctx, name)
+			return m.timed(ctx, timings, objectName, name, func() error { return apply(ctx) })
+		})
+	}
 	// Applying dconf policies take a while to complete, so it's better to start applying them before
 	// querying dbus for the Pro subscription state, as it does not rely on that.
-	g.Go(func() error {
+	run("dconf", func(ctx context.Context) error {
+		if m.dconfUserLayers && !isComputer {
+			var layers [][]entry.Entry
+			if !m.isDisabled("dconf") {
+				layers = dconfLayers(&expanded)
+			}
+			return m.dconf.ApplyLayeredPolicy(ctx, objectName, isComputer, layers)
+		}
 		return m.dconf.ApplyPolicy(ctx, objectName, isComputer, rules["dconf"])
 	})
 	if !m.GetSubscriptionState(ctx) {
@@ -382,44 +715,174 @@ func (m *Manager) ApplyPolicies(ctx context.Context, objectName string, isComput
 		}
 	}
 
-	g.Go(func() error {
+	run("privilege", func(ctx context.Context) error {
 		return m.privilege.ApplyPolicy(ctx, objectName, isComputer, rules["privilege"])
 	})
-	g.Go(func() error {
-		return m.scripts.ApplyPolicy(ctx, objectName, isComputer, rules["scripts"], pols.SaveAssetsTo)
+	run("scripts", func(ctx context.Context) error {
+		return m.scripts.ApplyPolicy(ctx, objectName, isComputer, gpoNames(pols), rules["scripts"], pols.SaveAssetsTo)
 	})
-	g.Go(func() error {
+	run("mount", func(ctx context.Context) error {
 		return m.mount.ApplyPolicy(ctx, objectName, isComputer, rules["mount"])
 	})
-	g.Go(func() error {
+	run("apparmor", func(ctx context.Context) error {
 		return m.apparmor.ApplyPolicy(ctx, objectName, isComputer, rules["apparmor"], pols.SaveAssetsTo)
 	})
-	g.Go(func() error {
+	run("proxy", func(ctx context.Context) error {
 		return m.proxy.ApplyPolicy(ctx, objectName, isComputer, rules["proxy"])
 	})
-	g.Go(func() error {
+	run("certificate", func(ctx context.Context) error {
 		// Ignore error as we don't want to fail because of online status this late in the process
 		isOnline, _ := m.backend.IsOnline()
 		return m.certificate.ApplyPolicy(ctx, objectName, isComputer, isOnline, rules["certificate"])
 	})
+	run("hosts", func(ctx context.Context) error {
+		return m.hosts.ApplyPolicy(ctx, objectName, isComputer, rules["hosts"])
+	})
+	run("timesync", func(ctx context.Context) error {
+		return m.timesync.ApplyPolicy(ctx, objectName, isComputer, rules["timesync"])
+	})
 	if err := g.Wait(); err != nil {
-		return err
+		return timings.cancelledError(ctx, err)
 	}
 
-	if isComputer {
+	if isComputer && selected("gdm") {
 		// Apply GDM policy only now as we need dconf machine database to be ready first
-		if err := m.gdm.ApplyPolicy(ctx, rules["gdm"]); err != nil {
-			return err
+		ctx := audit.WithManager(ctx, "gdm")
+		if err := m.timed(ctx, timings, objectName, "gdm", func() error { return m.gdm.ApplyPolicy(ctx, rules["gdm"]) }); err != nil {
+			return timings.cancelledError(ctx, err)
 		}
 	}
 
+	// Don't save a cache of policies which were partially applied.
+	if err := ctx.Err(); err != nil {
+		return timings.cancelledError(ctx, err)
+	}
+
+	if len(only) > 0 {
+		log.Debugf(ctx, "Not saving policies cache for %s as only some managers ran", objectName)
+		return nil
+	}
+
 	// Write cache Policies
 	return pols.Save(filepath.Join(m.policiesCacheDir, objectName))
 }
 
+// DryRunPolicies returns the changes that applying pols would do for objectName, without modifying the system.
+// Only dconf policies are reported for now.
+func (m *Manager) DryRunPolicies(ctx context.Context, objectName string, isComputer bool, pols *Policies) (msg string, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to compute policy changes for %q", objectName))
+
+	m.muMu.Lock()
+	if _, ok := m.objectMu[objectName]; !ok {
+		m.objectMu[objectName] = &sync.Mutex{}
+	}
+	m.objectMu[objectName].Lock()
+	defer m.objectMu[objectName].Unlock()
+	m.muMu.Unlock()
+
+	log.Info(ctx, gotext.Get("Computing policy changes for %s (machine: %v)", objectName, isComputer))
+
+	expanded, err := pols.expandVariables(variables(m.hostname, m.backend.Domain(), objectName, isComputer))
+	if err != nil {
+		return "", err
+	}
+	rules := expanded.GetUniqueRules()
+	if m.isDisabled("dconf") {
+		if !m.cleanupDisabled {
+			return gotext.Get("dconf policy manager is disabled.") + "\n", nil
+		}
+		delete(rules, "dconf")
+	}
+	changes, err := m.dconf.DryRunPolicy(ctx, objectName, isComputer, rules["dconf"])
+	if err != nil {
+		return "", err
+	}
+
+	return changes.String(), nil
+}
+
+// isDisabled returns true if the policy manager name is disabled.
+func (m *Manager) isDisabled(name string) bool {
+	return slices.Contains(m.disabledManagers, name)
+}
+
+// FlushUpdates runs any delayed system update from previously applied policies and waits for it to complete.
+func (m *Manager) FlushUpdates(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to flush policy updates"))
+
+	return m.dconf.FlushUpdates(ctx)
+}
+
+// WaitUpdates waits for the delayed system updates from previously applied policies to complete, coalesced with the
+// ones of other policy applications requested meanwhile.
+func (m *Manager) WaitUpdates(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to wait for policy updates"))
+
+	return m.dconf.WaitUpdates(ctx)
+}
+
+// CleanupOrphaned removes policy artifacts which don't belong to any object with cached policies anymore, or to users
+// whose account doesn't exist anymore. Objects with cached policies but no GPOs are still active.
+// Only dconf databases are cleaned up for now.
+// No policy is applied meanwhile: ErrApplyInProgress is returned if the policy applications in progress don't
+// complete within the apply lock timeout.
+func (m *Manager) CleanupOrphaned(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to clean up orphaned policies"))
+
+	unlock, err := m.lockApply(ctx, "orphaned policies cleanup", true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	log.Debug(ctx, "Cleaning up orphaned policies")
+
+	cached, err := os.ReadDir(m.policiesCacheDir)
+	if err != nil {
+		return err
+	}
+
+	var active []string
+	for _, c := range cached {
+		pols, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, c.Name()))
+		if errors.Is(err, ErrCorruptedCache) {
+			// The corrupted cache was discarded: its object is not active until its policies are applied again.
+			log.Warning(ctx, err)
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := pols.Close(); err != nil {
+			return err
+		}
+
+		// The gdm database is generated from the machine policies.
+		if c.Name() == m.hostname {
+			active = append(active, "gdm")
+			continue
+		}
+
+		// Only users we know are gone are orphaned: a failing lookup, like when the directory is unreachable, keeps
+		// them active.
+		if _, err := m.userLookup(c.Name()); err != nil {
+			var unknownUser user.UnknownUserError
+			if errors.As(err, &unknownUser) {
+				log.Debugf(ctx, "User %q doesn't exist anymore: its policies are orphaned", c.Name())
+				continue
+			}
+			log.Warningf(ctx, "Can't look up user %q, its policies are kept: %v", c.Name(), err)
+		}
+		active = append(active, c.Name())
+	}
+
+	_, err = m.dconf.CleanupOrphaned(ctx, active)
+	return err
+}
+
 // DumpPolicies displays the currently applied policies and rules (since last update) for objectName.
-// It can in addition show the rules and overridden content.
-func (m *Manager) DumpPolicies(ctx context.Context, objectName string, computerOnly, withRules, withOverridden bool) (msg string, err error) {
+// It can in addition show the rules and overridden content, listing then dconf keys set differently by multiple GPOs.
+// withSources shows the rules resolved between all GPOs, with the GPOs they come from.
+func (m *Manager) DumpPolicies(ctx context.Context, objectName string, computerOnly, withRules, withOverridden, withSources bool) (msg string, err error) {
 	defer decorate.OnError(&err, gotext.Get("failed to dump policies for %q", objectName))
 
 	log.Infof(ctx, "Dumping policies for %s", objectName)
@@ -436,6 +899,13 @@ func (m *Manager) DumpPolicies(ctx context.Context, objectName string, computerO
 		for _, g := range policiesHost.GPOs {
 			alreadyProcessedRules = g.Format(&out, withRules, withOverridden, alreadyProcessedRules)
 		}
+		if withRules && withOverridden {
+			formatConflicts(&out, dconfConflicts(policiesHost.GPOs))
+		}
+		if withSources {
+			formatResolvedRules(&out, policiesHost)
+		}
+		m.formatLocalAdminsSuppression(ctx, &out)
 		fmt.Fprintln(&out, gotext.Get("Policies from user configuration:"))
 	}
 
@@ -448,10 +918,114 @@ func (m *Manager) DumpPolicies(ctx context.Context, objectName string, computerO
 	for _, g := range policiesTarget.GPOs {
 		alreadyProcessedRules = g.Format(&out, withRules, withOverridden, alreadyProcessedRules)
 	}
+	if withRules && withOverridden {
+		formatConflicts(&out, dconfConflicts(policiesTarget.GPOs))
+	}
+	if withSources {
+		formatResolvedRules(&out, policiesTarget)
+	}
+	if computerOnly {
+		m.formatLocalAdminsSuppression(ctx, &out)
+	}
+
+	return out.String(), nil
+}
+
+// formatLocalAdminsSuppression writes to w if local administrators are currently suppressed by the privilege policy.
+func (m *Manager) formatLocalAdminsSuppression(ctx context.Context, w io.Writer) {
+	s, suppressed, err := m.privilege.LocalAdminsSuppression()
+	if err != nil {
+		log.Warningf(ctx, "Local administrators state unknown: %v", err)
+		return
+	}
+	if !suppressed {
+		return
+	}
+	fmt.Fprintf(w, "!! %s\n", gotext.Get("Local administrators are suppressed since %s", s.Since.Format(time.RFC3339)))
+}
+
+// ScriptsLogs returns the output of the last count runs of scripts for objectName, or for the current machine.
+func (m *Manager) ScriptsLogs(ctx context.Context, objectName string, isMachine bool, count int) (msg string, err error) {
+	if isMachine {
+		objectName = m.hostname
+	}
+	return m.scripts.Logs(ctx, objectName, isMachine, count)
+}
+
+// CertificateStatus returns the certificates enrolled by the current machine.
+func (m *Manager) CertificateStatus(ctx context.Context) (msg string, err error) {
+	return m.certificate.Status(ctx)
+}
+
+// ExportPolicies returns a snapshot of the policies last applied to objectName, or to the current machine.
+func (m *Manager) ExportPolicies(ctx context.Context, objectName string, isMachine bool) (snapshot string, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to export policies for %q", objectName))
+
+	if isMachine {
+		objectName = m.hostname
+	}
+
+	log.Infof(ctx, "Exporting policies for %s", objectName)
+
+	pols, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, objectName))
+	if err != nil {
+		return "", errors.New(gotext.Get("no policy applied for %q: %v", objectName, err))
+	}
+	defer pols.Close()
+
+	s, err := pols.Snapshot(objectName, isMachine)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := s.Write(&out); err != nil {
+		return "", err
+	}
 
 	return out.String(), nil
 }
 
+// ImportPolicies stores the policies of snapshot for objectName, or for the current machine.
+// Those policies are only applied in place of the ones from AD if the daemon is configured to do so.
+func (m *Manager) ImportPolicies(ctx context.Context, objectName string, isMachine bool, snapshot string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to import policies for %q", objectName))
+
+	if isMachine {
+		objectName = m.hostname
+	}
+
+	log.Infof(ctx, "Importing policies for %s", objectName)
+
+	s, err := ReadSnapshot(strings.NewReader(snapshot))
+	if err != nil {
+		return err
+	}
+	if s.IsComputer != isMachine {
+		if s.IsComputer {
+			return errors.New(gotext.Get("snapshot of machine %q can't be imported for a user", s.Object))
+		}
+		return errors.New(gotext.Get("snapshot of user %q can't be imported for the machine", s.Object))
+	}
+
+	return s.Save(ctx, filepath.Join(m.snapshotsCacheDir, objectName))
+}
+
+// ImportedPolicies returns the policies imported for objectName, if there are some.
+func (m *Manager) ImportedPolicies(ctx context.Context, objectName string) (pols Policies, imported bool, err error) {
+	p := filepath.Join(m.snapshotsCacheDir, objectName)
+	if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+		return pols, false, nil
+	} else if err != nil {
+		return pols, false, err
+	}
+
+	pols, err = NewFromCache(ctx, p)
+	if err != nil {
+		return pols, false, errors.New(gotext.Get("invalid imported policies for %q: %v", objectName, err))
+	}
+	return pols, true, nil
+}
+
 // LastUpdateFor returns the last update time for object or current machine.
 func (m *Manager) LastUpdateFor(ctx context.Context, objectName string, isMachine bool) (t time.Time, err error) {
 	defer decorate.OnError(&err, gotext.Get("failed to get policy last update time %q (machine: %v)", objectName, isMachine))
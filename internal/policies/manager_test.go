@@ -3,12 +3,17 @@ package policies_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,8 +22,11 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
+	"github.com/ubuntu/adsys/internal/audit"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/metrics"
 	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/adsys/internal/testutils"
 )
 
@@ -109,10 +117,15 @@ func TestApplyPolicies(t *testing.T) {
 				policies.WithDconfDir(dconfDir),
 				policies.WithPolicyKitDir(policyKitDir),
 				policies.WithSudoersDir(sudoersDir),
+				policies.WithHostsFile(filepath.Join(t.TempDir(), "hosts")),
+				policies.WithChronyConfDir(filepath.Join(fakeRootDir, "etc", "chrony", "conf.d")),
+				policies.WithTimesyncdConfDir(filepath.Join(fakeRootDir, "etc", "systemd", "timesyncd.conf.d")),
 				policies.WithApparmorDir(apparmorDir),
 				policies.WithApparmorFsDir(filepath.Dir(loadedPoliciesFile)),
 				policies.WithApparmorParserCmd([]string{"/bin/true"}),
 				policies.WithCertAutoenrollCmd([]string{"/bin/true"}),
+				policies.WithSnapdSocket("/nonexistent/snapd.socket"),
+				policies.WithFlatpakDir("/nonexistent/flatpak"),
 				policies.WithSystemUnitDir(systemUnitDir),
 				policies.WithProxyApplier(&mockProxyApplier{wantApplyError: tc.noUbuntuProxyManager}),
 				policies.WithSystemdCaller(&testutils.MockSystemdCaller{}),
@@ -182,6 +195,810 @@ func TestApplyPolicies(t *testing.T) {
 	}
 }
 
+func TestApplyPoliciesOnly(t *testing.T) {
+	//t.Parallel()
+
+	bus := testutils.NewDbusConn(t)
+
+	// managerPaths are files generated by each manager from the all_entry_types policies.
+	managerPaths := map[string]string{
+		"dconf":     "etc/dconf/db/machine.d/adsys",
+		"gdm":       "etc/dconf/db/gdm.d/adsys",
+		"privilege": "etc/sudoers.d/99-adsys-privilege-enforcement",
+		"scripts":   "run/adsys/machine/scripts",
+		"mount":     "etc/systemd/system/adsys-nfs-example.com-nfs_share.mount",
+		"apparmor":  "etc/apparmor.d/adsys/machine",
+	}
+
+	tests := map[string]struct {
+		only                  []string
+		secondCallWithNoRules bool
+		makeDirReadOnly       string
+
+		wantManagers []string
+		wantErr      bool
+	}{
+		"Only dconf manager":                  {only: []string{"dconf"}},
+		"Only dconf and gdm managers":         {only: []string{"dconf", "gdm"}},
+		"Only scripts and privilege managers": {only: []string{"scripts", "privilege"}},
+
+		"Second call with no rules only cleans up selected managers": {only: []string{"privilege"}, secondCallWithNoRules: true,
+			wantManagers: []string{"dconf", "gdm", "scripts", "mount", "apparmor"}},
+
+		// Error cases
+		"Error on unknown manager":                            {only: []string{"dconf", "doesnotexist"}, wantErr: true},
+		"Error when applying selected manager fails":          {only: []string{"privilege"}, makeDirReadOnly: "etc/sudoers.d", wantErr: true},
+		"Error from the proxy manager when selected":          {only: []string{"proxy"}, wantErr: true},
+		"Error from the proxy manager when all managers run":  {wantErr: true},
+		"Error on gdm manager without machine dconf database": {only: []string{"gdm"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.wantManagers == nil {
+				tc.wantManagers = tc.only
+			}
+
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+
+			setSubscriptionState(t, bus, true)
+
+			fakeRootDir := t.TempDir()
+			cacheDir := filepath.Join(fakeRootDir, "var", "cache", "adsys")
+			// The proxy manager fails when it runs, to ensure it was skipped otherwise.
+			m, err := newTestManager(t, bus, fakeRootDir, policies.WithProxyApplier(&mockProxyApplier{wantApplyError: !tc.secondCallWithNoRules}))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			if tc.makeDirReadOnly != "" {
+				require.NoError(t, os.MkdirAll(filepath.Join(fakeRootDir, tc.makeDirReadOnly), 0750), "Setup: can not create directory")
+				testutils.MakeReadOnly(t, filepath.Join(fakeRootDir, tc.makeDirReadOnly))
+			}
+
+			if tc.secondCallWithNoRules {
+				err = m.ApplyPolicies(context.Background(), "hostname", true, &pols)
+				require.NoError(t, err, "Setup: first ApplyPolicies call should return no error but got one")
+				pols, err = policies.New(context.Background(), nil, "")
+				require.NoError(t, err, "Setup: can not empty policies before second call")
+			}
+
+			err = m.ApplyPoliciesOnly(context.Background(), "hostname", true, &pols, tc.only)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPoliciesOnly should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "ApplyPoliciesOnly should return no error but got one")
+
+			for manager, p := range managerPaths {
+				_, err := os.Stat(filepath.Join(fakeRootDir, p))
+				if slices.Contains(tc.wantManagers, manager) {
+					require.NoError(t, err, "%s manager policies should be applied", manager)
+					continue
+				}
+				require.ErrorIs(t, err, os.ErrNotExist, "%s manager policies should not be applied", manager)
+			}
+
+			// The cache is only saved when all managers ran.
+			_, err = os.Stat(filepath.Join(cacheDir, policies.PoliciesCacheBaseName, "hostname", "policies"))
+			if !tc.secondCallWithNoRules {
+				require.ErrorIs(t, err, os.ErrNotExist, "Policies cache should not be saved when only some managers ran")
+			}
+		})
+	}
+}
+
+func TestApplyPoliciesWithDisabledManagers(t *testing.T) {
+	//t.Parallel()
+
+	bus := testutils.NewDbusConn(t)
+
+	// managerPaths are files generated by each manager from the all_entry_types policies.
+	managerPaths := map[string]string{
+		"dconf":     "etc/dconf/db/machine.d/adsys",
+		"gdm":       "etc/dconf/db/gdm.d/adsys",
+		"privilege": "etc/sudoers.d/99-adsys-privilege-enforcement",
+		"scripts":   "run/adsys/machine/scripts",
+		"mount":     "etc/systemd/system/adsys-nfs-example.com-nfs_share.mount",
+		"apparmor":  "etc/apparmor.d/adsys/machine",
+	}
+	allManagers := []string{"dconf", "gdm", "privilege", "scripts", "mount", "apparmor"}
+
+	tests := map[string]struct {
+		disabledManagers []string
+		cleanupDisabled  bool
+		alreadyApplied   bool
+		only             []string
+		proxyFails       bool
+
+		wantManagers      []string
+		wantNewManagerErr bool
+		wantErr           bool
+	}{
+		"Disabled managers don't apply":          {disabledManagers: []string{"mount", "apparmor"}, wantManagers: []string{"dconf", "gdm", "privilege", "scripts"}},
+		"Disabled dconf and gdm managers":        {disabledManagers: []string{"dconf", "gdm"}, wantManagers: []string{"privilege", "scripts", "mount", "apparmor"}},
+		"Disabled failing manager doesn't run":   {disabledManagers: []string{"proxy"}, proxyFails: true, wantManagers: allManagers},
+		"Only selects managers not disabled":     {disabledManagers: []string{"mount"}, only: []string{"privilege"}, wantManagers: []string{"privilege"}},
+		"No disabled managers apply all of them": {wantManagers: allManagers},
+
+		"Disabled managers keep what they previously applied": {disabledManagers: []string{"mount", "apparmor"}, alreadyApplied: true, wantManagers: allManagers},
+		"Disabled managers clean up what they previously applied if requested": {disabledManagers: []string{"mount", "apparmor"}, cleanupDisabled: true, alreadyApplied: true,
+			wantManagers: []string{"dconf", "gdm", "privilege", "scripts"}},
+
+		// Error cases
+		"Error on unknown disabled manager":                {disabledManagers: []string{"dconf", "gdm", "doesnotexist"}, wantNewManagerErr: true},
+		"Error on disabled dconf manager without gdm":      {disabledManagers: []string{"dconf"}, wantNewManagerErr: true},
+		"Error when selecting a disabled manager":          {disabledManagers: []string{"mount"}, only: []string{"privilege", "mount"}, wantErr: true},
+		"Error when selecting a disabled manager to clean": {disabledManagers: []string{"mount"}, cleanupDisabled: true, only: []string{"mount"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+
+			setSubscriptionState(t, bus, true)
+
+			// Both managers apply policies on the same system.
+			fakeRootDir := t.TempDir()
+			hostsFile := policies.WithHostsFile(filepath.Join(t.TempDir(), "hosts"))
+
+			if tc.alreadyApplied {
+				m, err := newTestManager(t, bus, fakeRootDir, hostsFile)
+				require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+				err = m.ApplyPolicies(context.Background(), "hostname", true, &pols)
+				require.NoError(t, err, "Setup: first ApplyPolicies call should return no error but got one")
+				pols, err = policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+				require.NoError(t, err, "Setup: can not reload policies list")
+			}
+
+			m, err := newTestManager(t, bus, fakeRootDir, hostsFile,
+				policies.WithProxyApplier(&mockProxyApplier{wantApplyError: tc.proxyFails}),
+				policies.WithDisabledManagers(tc.disabledManagers, tc.cleanupDisabled))
+			if tc.wantNewManagerErr {
+				require.Error(t, err, "NewManager should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			err = m.ApplyPoliciesOnly(context.Background(), "hostname", true, &pols, tc.only)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPoliciesOnly should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "ApplyPoliciesOnly should return no error but got one")
+
+			for manager, p := range managerPaths {
+				_, err := os.Stat(filepath.Join(fakeRootDir, p))
+				if slices.Contains(tc.wantManagers, manager) {
+					require.NoError(t, err, "%s manager policies should be applied", manager)
+					continue
+				}
+				require.ErrorIs(t, err, os.ErrNotExist, "%s manager policies should not be applied", manager)
+			}
+		})
+	}
+}
+
+func TestApplyPoliciesRecordsTimings(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+
+	// step is how much the injected clock advances each time it is read.
+	const step = time.Second
+
+	tests := map[string]struct {
+		only         []string
+		proxyFails   bool
+		getForObject string
+
+		wantManagers []string
+		wantErr      bool
+	}{
+		"Records timings of all managers":       {wantManagers: policies.Managers},
+		"Records timings of selected managers":  {only: []string{"privilege", "dconf"}, wantManagers: []string{"dconf", "privilege"}},
+		"Records timings until a manager fails": {proxyFails: true, wantManagers: []string{"dconf", "privilege", "scripts", "mount", "apparmor", "proxy", "certificate", "hosts", "timesync"}, wantErr: true},
+
+		"No timings for object without policy applied": {getForObject: "doesnotexist"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+
+			setSubscriptionState(t, bus, true)
+
+			var mu sync.Mutex
+			now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+			clock := func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+				now = now.Add(step)
+				return now
+			}
+
+			m, err := newTestManager(t, bus, t.TempDir(),
+				policies.WithProxyApplier(&mockProxyApplier{wantApplyError: tc.proxyFails}),
+				policies.WithClock(clock))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			require.Empty(t, m.LastTimings(hostname, true), "No timings should be recorded before applying policies")
+
+			err = m.ApplyPoliciesOnly(context.Background(), hostname, true, &pols, tc.only)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPoliciesOnly should return an error but got none")
+			} else {
+				require.NoError(t, err, "ApplyPoliciesOnly should return no error but got one")
+			}
+
+			timings := m.LastTimings(hostname, true)
+			if tc.getForObject != "" {
+				timings = m.LastTimings(tc.getForObject, false)
+			}
+
+			var gotManagers []string
+			for _, timing := range timings {
+				gotManagers = append(gotManagers, timing.Manager)
+				// Other managers running in parallel can read the clock in between.
+				require.GreaterOrEqual(t, timing.Duration, step, "Duration of %s manager should be measured with the injected clock", timing.Manager)
+			}
+			require.Equal(t, tc.wantManagers, gotManagers, "Timings should be recorded for each manager which ran, in managers order")
+		})
+	}
+}
+
+func TestApplyPoliciesRecordsMetrics(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+
+	tests := map[string]struct {
+		proxyFails bool
+
+		wantMetrics []string
+	}{
+		"Records successful policy application": {wantMetrics: []string{
+			`adsys_policy_applies_total{object="machine",result="success"} 1`,
+			`adsys_policy_applies_total{object="machine",result="failure"} 0`,
+			`adsys_policy_last_success_timestamp_seconds{object="machine"} 1.7040672e+09`,
+			`adsys_policy_manager_duration_seconds_count{manager="dconf"} 1`,
+			`adsys_policy_manager_duration_seconds_count{manager="gdm"} 1`,
+			`adsys_policy_manager_errors_total{manager="proxy"} 0`,
+		}},
+		"Records failed policy application and manager error": {proxyFails: true, wantMetrics: []string{
+			`adsys_policy_applies_total{object="machine",result="success"} 0`,
+			`adsys_policy_applies_total{object="machine",result="failure"} 1`,
+			`adsys_policy_manager_duration_seconds_count{manager="proxy"} 1`,
+			`adsys_policy_manager_duration_seconds_count{manager="gdm"} 0`,
+			`adsys_policy_manager_errors_total{manager="proxy"} 1`,
+			`adsys_policy_manager_errors_total{manager="dconf"} 0`,
+		}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+
+			setSubscriptionState(t, bus, true)
+
+			now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+			clock := func() time.Time { return now }
+
+			recorder := metrics.New(policies.Managers...)
+			m, err := newTestManager(t, bus, t.TempDir(),
+				policies.WithProxyApplier(&mockProxyApplier{wantApplyError: tc.proxyFails}),
+				policies.WithClock(clock),
+				policies.WithMetrics(recorder))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			err = m.ApplyPolicies(context.Background(), hostname, true, &pols)
+			if tc.proxyFails {
+				require.Error(t, err, "ApplyPolicies should return an error but got none")
+			} else {
+				require.NoError(t, err, "ApplyPolicies should return no error but got one")
+			}
+
+			var b strings.Builder
+			_, err = recorder.WriteTo(&b)
+			require.NoError(t, err, "WriteTo should not fail")
+			for _, want := range tc.wantMetrics {
+				require.Contains(t, b.String(), want+"\n", "Metrics should be updated by the policy application")
+			}
+		})
+	}
+}
+
+func TestApplyPoliciesWritesAuditLog(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+
+	tests := map[string]struct {
+		proxyFails     bool
+		alreadyApplied bool
+
+		wantManagers []string
+		wantErr      bool
+	}{
+		"Records changes of the policy application": {wantManagers: []string{"dconf", "privilege", "scripts", "mount", "apparmor", "proxy", "certificate"}},
+		"Records no rule change when reapplying":    {alreadyApplied: true},
+
+		"Records failed policy application": {proxyFails: true, wantManagers: []string{"dconf", "privilege"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+
+			setSubscriptionState(t, bus, true)
+
+			now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+			clock := func() time.Time { return now }
+
+			fakeRootDir := t.TempDir()
+			auditLogPath := filepath.Join(fakeRootDir, "var", "log", "adsys", "audit.log")
+			auditLog, err := audit.New(auditLogPath)
+			require.NoError(t, err, "Setup: can not create audit log")
+
+			proxyApplier := &mockProxyApplier{}
+			m, err := newTestManager(t, bus, fakeRootDir,
+				policies.WithProxyApplier(proxyApplier),
+				policies.WithClock(clock),
+				policies.WithAuditLog(auditLog))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			if tc.alreadyApplied {
+				err = m.ApplyPolicies(context.Background(), hostname, true, &pols)
+				require.NoError(t, err, "Setup: first ApplyPolicies should return no error but got one")
+			}
+			proxyApplier.wantApplyError = tc.proxyFails
+
+			err = m.ApplyPolicies(context.Background(), hostname, true, &pols)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicies should return an error but got none")
+			} else {
+				require.NoError(t, err, "ApplyPolicies should return no error but got one")
+			}
+
+			data, err := os.ReadFile(auditLogPath)
+			require.NoError(t, err, "Audit log should be written")
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			wantEntries := 1
+			if tc.alreadyApplied {
+				wantEntries = 2
+			}
+			require.Len(t, lines, wantEntries, "Audit log should have one entry per policy application")
+
+			var e audit.Entry
+			require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &e), "Audit log entry should be valid JSON")
+
+			require.True(t, now.Equal(e.Time), "Audit entry should record the time of the policy application")
+			require.Equal(t, hostname, e.Target, "Audit entry should record the target")
+			require.True(t, e.IsComputer, "Audit entry should record a machine policy application")
+			require.Len(t, e.GPOs, len(pols.GPOs), "Audit entry should record the applied GPOs")
+			for i, g := range pols.GPOs {
+				require.Equal(t, audit.GPO{ID: g.ID, Name: g.Name}, e.GPOs[i], "Audit entry should record the GPOs in order")
+			}
+			if tc.wantErr {
+				require.NotEmpty(t, e.Error, "Audit entry should record the error of a failed policy application")
+			} else {
+				require.Empty(t, e.Error, "Audit entry should record no error on success")
+			}
+
+			managers := make(map[string]audit.ManagerChanges)
+			for _, c := range e.Managers {
+				managers[c.Manager] = c
+			}
+			if tc.alreadyApplied {
+				for _, c := range e.Managers {
+					require.Empty(t, c.Rules, "Audit entry should record no rule change for %s when reapplying", c.Manager)
+				}
+				return
+			}
+			for _, name := range tc.wantManagers {
+				require.Contains(t, managers, name, "Audit entry should record the changes of %s", name)
+				require.NotEmpty(t, managers[name].Rules, "Audit entry should record the rules changed by %s", name)
+			}
+			if tc.proxyFails {
+				require.Empty(t, managers["proxy"].Rules, "Audit entry should not record the rules of the failed manager as changed")
+			}
+			require.NotEmpty(t, managers["dconf"].Files, "Audit entry should record the files written by dconf")
+			for _, f := range managers["dconf"].Files {
+				require.True(t, strings.HasPrefix(f, filepath.Join(fakeRootDir, "etc", "dconf")), "dconf should only write in its directory: %s", f)
+			}
+			require.NotEmpty(t, managers["privilege"].Files, "Audit entry should record the files written by privilege")
+		})
+	}
+}
+
+func TestApplyPoliciesReportsProgress(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+
+	tests := map[string]struct {
+		only       []string
+		proxyFails bool
+
+		wantTotal    int
+		wantManagers []string
+		wantErr      bool
+	}{
+		"Reports progress of all managers":      {wantTotal: len(policies.Managers), wantManagers: policies.Managers},
+		"Reports progress of selected managers": {only: []string{"privilege", "dconf"}, wantTotal: 2, wantManagers: []string{"dconf", "privilege"}},
+		"Reports progress until a manager fails": {proxyFails: true, wantTotal: len(policies.Managers),
+			wantManagers: []string{"dconf", "privilege", "scripts", "mount", "apparmor", "certificate", "hosts", "timesync"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+
+			setSubscriptionState(t, bus, true)
+
+			m, err := newTestManager(t, bus, t.TempDir(), policies.WithProxyApplier(&mockProxyApplier{wantApplyError: tc.proxyFails}))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			var mu sync.Mutex
+			var progress []policies.Progress
+			ctx := policies.WithProgressReporter(context.Background(), func(p policies.Progress) {
+				mu.Lock()
+				defer mu.Unlock()
+				progress = append(progress, p)
+			})
+
+			err = m.ApplyPoliciesOnly(ctx, hostname, true, &pols, tc.only)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPoliciesOnly should return an error but got none")
+			} else {
+				require.NoError(t, err, "ApplyPoliciesOnly should return no error but got one")
+			}
+
+			require.NotEmpty(t, progress, "Progress should be reported")
+			require.Equal(t, policies.Progress{Target: hostname, Stage: policies.StageApply, Total: tc.wantTotal}, progress[0],
+				"First progress should report the start of the apply stage")
+
+			var gotManagers []string
+			for i, p := range progress[1:] {
+				require.Equal(t, hostname, p.Target, "Progress should report the object being applied")
+				require.Equal(t, policies.StageApply, p.Stage, "Progress should report the apply stage")
+				require.Equal(t, i+1, p.Completed, "Progress should report the managers completed so far")
+				require.Equal(t, tc.wantTotal, p.Total, "Progress should report the managers which run")
+				gotManagers = append(gotManagers, p.Manager)
+			}
+
+			if tc.only == nil && !tc.wantErr {
+				require.Equal(t, "gdm", gotManagers[len(gotManagers)-1], "gdm manager should complete last, after dconf")
+			}
+			// Managers run in parallel: only the set of completed managers is deterministic.
+			require.ElementsMatch(t, tc.wantManagers, gotManagers, "Progress should be reported for each manager which completed")
+		})
+	}
+}
+
+func TestApplyPoliciesWithDconfUserLayers(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+
+	unlocked := false
+	key := func(k string) string { return "com/ubuntu/category/" + k }
+
+	tests := map[string]struct {
+		layered bool
+
+		wantProfile string
+		wantDBs     map[string]string
+	}{
+		"One user database per GPO with dconf keys": {
+			layered:     true,
+			wantProfile: "user-db:user\nsystem-db:user@example.com\nsystem-db:user@example.com-layer2\nsystem-db:machine",
+			wantDBs: map[string]string{
+				"user@example.com":        "[com/ubuntu/category]\nkey-s='closest'\n",
+				"user@example.com-layer2": "[com/ubuntu/category]\nkey-i=2\n",
+			},
+		},
+		"Single user database without layers": {
+			wantProfile: "user-db:user\nsystem-db:user@example.com\nsystem-db:machine",
+			wantDBs: map[string]string{
+				"user@example.com": "[com/ubuntu/category]\nkey-i=2\nkey-s='closest'\n",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			fakeRootDir := t.TempDir()
+			dconfDir := filepath.Join(fakeRootDir, "etc", "dconf")
+			m, err := newTestManager(t, bus, fakeRootDir, policies.WithDconfUserLayers(tc.layered))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			machinePols := policies.Policies{GPOs: []policies.GPO{{ID: "{machine}", Name: "machine", Rules: map[string][]entry.Entry{
+				"dconf": {{Key: key("key-s"), Value: "'machine'", Meta: "s"}}}}}}
+			err = m.ApplyPoliciesOnly(context.Background(), hostname, true, &machinePols, []string{"dconf"})
+			require.NoError(t, err, "Setup: machine dconf policy should be applied")
+
+			// The closest GPO overrides the value of a key set by the furthest one.
+			userPols := policies.Policies{GPOs: []policies.GPO{
+				{ID: "{closest}", Name: "closest", Rules: map[string][]entry.Entry{
+					"dconf": {{Key: key("key-s"), Value: "'closest'", Lock: &unlocked, Meta: "s"}}}},
+				{ID: "{nodconf}", Name: "nodconf", Rules: map[string][]entry.Entry{
+					"privilege": {{Key: "allow-local-admins", Disabled: true}}}},
+				{ID: "{furthest}", Name: "furthest", Rules: map[string][]entry.Entry{
+					"dconf": {
+						{Key: key("key-s"), Value: "'furthest'", Meta: "s"},
+						{Key: key("key-i"), Value: "2", Meta: "i"},
+					}}},
+			}}
+			err = m.ApplyPoliciesOnly(context.Background(), "user@example.com", false, &userPols, []string{"dconf"})
+			require.NoError(t, err, "ApplyPoliciesOnly should apply the user dconf policy")
+
+			profile, err := os.ReadFile(filepath.Join(dconfDir, "profile", "user@example.com"))
+			require.NoError(t, err, "User profile should be written")
+			require.Equal(t, tc.wantProfile, string(profile), "User profile should stack the expected databases")
+
+			for db, want := range tc.wantDBs {
+				got, err := os.ReadFile(filepath.Join(dconfDir, "db", db+".d", "adsys"))
+				require.NoError(t, err, "Database %s should be written", db)
+				require.Equal(t, want, string(got), "Database %s should contain the keys of its GPO", db)
+			}
+			if !tc.layered {
+				require.NoDirExists(t, filepath.Join(dconfDir, "db", "user@example.com-layer2.d"), "No layer should be written")
+			}
+		})
+	}
+}
+
+func TestApplyPoliciesCancelledOnTimeout(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+	setSubscriptionState(t, bus, true)
+
+	pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+	require.NoError(t, err, "Setup: can not load policies list")
+	defer pols.Close()
+
+	fakeRootDir := t.TempDir()
+	cacheDir := filepath.Join(fakeRootDir, "var", "cache", "adsys")
+	// A slow apparmor parser, which has to be killed on timeout.
+	m, err := newTestManager(t, bus, fakeRootDir, policies.WithApparmorParserCmd([]string{"sh", "-c", "exec sleep 60"}))
+	require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = m.ApplyPolicies(ctx, hostname, true, &pols)
+	require.Error(t, err, "ApplyPolicies should return an error on timeout but got none")
+	require.Less(t, time.Since(start), 30*time.Second, "ApplyPolicies should return once the timeout is reached")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "ApplyPolicies should report the timeout")
+	require.ErrorContains(t, err, "policies were partially applied", "ApplyPolicies should report the partially applied policies")
+
+	_, err = os.Stat(filepath.Join(cacheDir, policies.PoliciesCacheBaseName, hostname))
+	require.ErrorIs(t, err, fs.ErrNotExist, "Policies partially applied should not be cached")
+}
+
+func TestApplyPoliciesSerialized(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+
+	tests := map[string]struct {
+		holdLock           bool
+		holdUserLock       bool
+		slowApparmorParser bool
+		lockTimeout        time.Duration
+
+		wantMachineErr bool
+		wantUserErr    bool
+	}{
+		"Concurrent machine and user applies succeed":                              {},
+		"Concurrent applies wait for the one in progress":                          {holdLock: true},
+		"User apply waits for the slow machine apply in progress":                  {slowApparmorParser: true},
+		"User apply fails if the machine apply does not complete in time":          {slowApparmorParser: true, lockTimeout: 100 * time.Millisecond, wantUserErr: true},
+		"Concurrent applies fail if the one in progress does not complete in time": {holdLock: true, lockTimeout: 100 * time.Millisecond, wantMachineErr: true, wantUserErr: true},
+		"User apply does not wait for other user applies in progress":              {holdUserLock: true, lockTimeout: 100 * time.Millisecond, wantMachineErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+			userPols, err := policies.New(context.Background(), nil, "")
+			require.NoError(t, err, "Setup: can not create empty user policies")
+
+			var opts []policies.Option
+			if tc.slowApparmorParser {
+				opts = append(opts, policies.WithApparmorParserCmd([]string{"sh", "-c", "exec sleep 1"}))
+			}
+			if tc.lockTimeout > 0 {
+				opts = append(opts, policies.WithApplyLockTimeout(tc.lockTimeout))
+			}
+			m, err := newTestManager(t, bus, t.TempDir(), opts...)
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			var unlock func()
+			if tc.holdLock || tc.holdUserLock {
+				unlock, err = m.LockApply(context.Background(), tc.holdLock)
+				require.NoError(t, err, "Setup: can not hold the apply lock")
+			}
+
+			userDone := make(chan error, 1)
+			if tc.holdUserLock {
+				// Apply the user policies before the machine ones add a pending exclusive lock.
+				userDone <- m.ApplyPolicies(context.Background(), "user@example.com", false, &userPols)
+			}
+			machineDone := make(chan error)
+			go func() { machineDone <- m.ApplyPolicies(context.Background(), hostname, true, &pols) }()
+			if tc.slowApparmorParser {
+				// Ensure the user apply starts while the machine one is in progress.
+				require.Eventually(t, m.ApplyLocked, 5*time.Second, 10*time.Millisecond, "Setup: machine apply should hold the apply lock")
+			}
+			if !tc.holdUserLock {
+				go func() { userDone <- m.ApplyPolicies(context.Background(), "user@example.com", false, &userPols) }()
+			}
+
+			if tc.holdLock && tc.lockTimeout == 0 {
+				select {
+				case <-machineDone:
+					t.Fatal("Machine apply should wait for the apply lock to be released")
+				case <-userDone:
+					t.Fatal("User apply should wait for the apply lock to be released")
+				case <-time.After(500 * time.Millisecond):
+				}
+				unlock()
+			}
+
+			var machineErr, userErr error
+			var machineCompleted, userCompletedFirst bool
+			for range 2 {
+				select {
+				case machineErr = <-machineDone:
+					machineCompleted = true
+				case userErr = <-userDone:
+					userCompletedFirst = !machineCompleted
+				}
+			}
+			if (tc.holdLock || tc.holdUserLock) && tc.lockTimeout > 0 {
+				unlock()
+			}
+			// Applies which timed out release the lock once they get it.
+			require.Eventually(t, func() bool { return !m.ApplyLocked() }, 5*time.Second, 10*time.Millisecond,
+				"Apply lock should be released once all applies completed")
+
+			if tc.wantMachineErr {
+				require.ErrorIs(t, machineErr, policies.ErrApplyInProgress, "Machine apply should fail as another apply is in progress")
+			} else {
+				require.NoError(t, machineErr, "Machine apply should succeed")
+			}
+			if tc.wantUserErr {
+				require.ErrorIs(t, userErr, policies.ErrApplyInProgress, "User apply should fail as another apply is in progress")
+				require.ErrorContains(t, userErr, "apply in progress", "User apply error should be explicit")
+				return
+			}
+			require.NoError(t, userErr, "User apply should succeed")
+			if tc.slowApparmorParser {
+				require.False(t, userCompletedFirst, "User apply should complete after the machine apply in progress")
+			}
+		})
+	}
+}
+
+func TestApplyPoliciesEmitsAppliedSignal(t *testing.T) {
+	//t.Parallel()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	bus := testutils.NewDbusConn(t)
+
+	tests := map[string]struct {
+		only           []string
+		proxyFails     bool
+		alreadyApplied bool
+
+		wantChanged   []string
+		wantUnchanged []string
+		wantErr       bool
+	}{
+		"Reports managers with new rules as changed":          {wantChanged: []string{"dconf", "privilege", "scripts", "mount", "apparmor", "proxy", "certificate"}},
+		"Reports only selected managers as changed":           {only: []string{"privilege", "dconf"}, wantChanged: []string{"dconf", "privilege"}},
+		"Reports no change when reapplying the same policies": {alreadyApplied: true, wantChanged: []string{}, wantUnchanged: []string{"dconf", "privilege", "scripts", "mount", "apparmor", "proxy", "certificate"}},
+
+		"Reports failure and completed managers which changed": {proxyFails: true, wantChanged: []string{"dconf", "privilege", "scripts", "mount", "apparmor", "certificate"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pols, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "all_entry_types"))
+			require.NoError(t, err, "Setup: can not load policies list")
+			defer pols.Close()
+
+			setSubscriptionState(t, bus, true)
+
+			proxyApplier := &mockProxyApplier{}
+			m, err := newTestManager(t, bus, t.TempDir(), policies.WithProxyApplier(proxyApplier))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			if tc.alreadyApplied {
+				err = m.ApplyPolicies(context.Background(), hostname, true, &pols)
+				require.NoError(t, err, "Setup: first ApplyPolicies should return no error but got one")
+			}
+			proxyApplier.wantApplyError = tc.proxyFails
+
+			listener := testutils.NewDbusConn(t)
+			err = listener.AddMatchSignal(
+				dbus.WithMatchObjectPath(consts.AdsysDbusObjectPath),
+				dbus.WithMatchInterface(consts.AdsysDbusPoliciesInterface),
+				dbus.WithMatchMember(consts.AdsysDbusAppliedSignal))
+			require.NoError(t, err, "Setup: can not subscribe to Applied signal")
+			signals := make(chan *dbus.Signal, 10)
+			listener.Signal(signals)
+
+			err = m.ApplyPoliciesOnly(context.Background(), hostname, true, &pols, tc.only)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPoliciesOnly should return an error but got none")
+			} else {
+				require.NoError(t, err, "ApplyPoliciesOnly should return no error but got one")
+			}
+
+			var s *dbus.Signal
+			select {
+			case s = <-signals:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Applied signal should be emitted once policies are applied")
+			}
+
+			require.Equal(t, consts.AdsysDbusPoliciesInterface+"."+consts.AdsysDbusAppliedSignal, s.Name, "Signal name should be the Applied one")
+			require.Len(t, s.Body, 5, "Applied signal should have 5 arguments")
+			require.Equal(t, hostname, s.Body[0], "Applied signal should report the target")
+			require.Equal(t, true, s.Body[1], "Applied signal should report a machine update")
+			require.Equal(t, !tc.wantErr, s.Body[2], "Applied signal should report the update outcome")
+			if tc.wantErr {
+				require.NotEmpty(t, s.Body[3], "Applied signal should report the error of a failed update")
+			} else {
+				require.Empty(t, s.Body[3], "Applied signal should report no error on success")
+			}
+			require.Equal(t, tc.wantChanged, s.Body[4], "Applied signal should report the managers which changed, in managers order")
+
+			status, err := m.Status(context.Background(), hostname, true)
+			require.NoError(t, err, "Status should return no error but got one")
+			for _, ms := range status.Machine.Managers {
+				switch {
+				case slices.Contains(tc.wantUnchanged, ms.Name):
+					require.Equal(t, policies.ManagerUnchanged, ms.State, "Status should report manager %s as unchanged", ms.Name)
+				case slices.Contains(tc.wantChanged, ms.Name):
+					require.Equal(t, policies.ManagerApplied, ms.State, "Status should report manager %s as applied", ms.Name)
+				}
+			}
+		})
+	}
+}
+
 func TestDumpPolicies(t *testing.T) {
 	t.Parallel()
 
@@ -197,6 +1014,7 @@ func TestDumpPolicies(t *testing.T) {
 		computerOnly       bool
 		withRules          bool
 		withOverridden     bool
+		withSources        bool
 
 		wantErr bool
 	}{
@@ -241,6 +1059,26 @@ func TestDumpPolicies(t *testing.T) {
 			withOverridden:    true,
 		},
 
+		// Show rules with their sources
+		"Multiple GPOs with sources": {
+			cachePoliciesUser: "two_gpos_with_overrides",
+			withSources:       true,
+		},
+		"Multiple GPOs with sources and rules": {
+			cachePoliciesUser: "two_gpos_with_overrides",
+			withRules:         true,
+			withSources:       true,
+		},
+		"Enforced GPO with sources of appended values": {
+			cachePoliciesUser: "enforced_gpo_with_append",
+			withSources:       true,
+		},
+		"Machine and user GPOs with sources": {
+			cachePoliciesUser:  "one_gpo",
+			cachePolicyMachine: "two_gpos_override_one_gpo",
+			withSources:        true,
+		},
+
 		// machine and user GPO with overrides between machine and user
 		"Overrides between machine and user GPOs, hidden": {
 			cachePoliciesUser:  "one_gpo",
@@ -312,7 +1150,7 @@ func TestDumpPolicies(t *testing.T) {
 			if tc.target == "" {
 				tc.target = "user"
 			}
-			got, err := m.DumpPolicies(context.Background(), tc.target, tc.computerOnly, tc.withRules, tc.withOverridden)
+			got, err := m.DumpPolicies(context.Background(), tc.target, tc.computerOnly, tc.withRules, tc.withOverridden, tc.withSources)
 			if tc.wantErr {
 				require.Error(t, err, "DumpPolicies should return an error but got none")
 				return
@@ -325,6 +1163,224 @@ func TestDumpPolicies(t *testing.T) {
 	}
 }
 
+func TestListCache(t *testing.T) {
+	t.Parallel()
+
+	bus := testutils.NewDbusConn(t)
+
+	tests := map[string]struct {
+		cachedSizes   map[string][]int
+		noCacheDir    bool
+		withStrayFile bool
+		wantErr       bool
+	}{
+		"Lists cached objects with their size": {cachedSizes: map[string][]int{
+			"bob@example.com":   {512},
+			"alice@example.com": {1024 * 1024, 5 * 512 * 1024},
+			"myhost":            {1024, 1024},
+		}},
+		"Ignores files in cache directory": {cachedSizes: map[string][]int{"bob@example.com": {512}}, withStrayFile: true},
+		"No policies cached":               {},
+
+		// Error cases
+		"Error on missing policies cache directory": {noCacheDir: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeRootDir := t.TempDir()
+			m, err := newTestManager(t, bus, fakeRootDir)
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			policiesCacheDir := filepath.Join(fakeRootDir, "var", "cache", "adsys", policies.PoliciesCacheBaseName)
+
+			lastUpdate := time.Date(2023, time.April, 5, 6, 7, 8, 0, time.Local)
+			for objectName, sizes := range tc.cachedSizes {
+				objectCacheDir := filepath.Join(policiesCacheDir, objectName)
+				require.NoError(t, os.MkdirAll(objectCacheDir, 0750), "Setup: can not create object cache directory")
+				for i, size := range sizes {
+					err := os.WriteFile(filepath.Join(objectCacheDir, fmt.Sprintf("file%d", i)), make([]byte, size), 0600)
+					require.NoError(t, err, "Setup: can not create cache file")
+				}
+				require.NoError(t, os.Chtimes(objectCacheDir, lastUpdate, lastUpdate), "Setup: can not set cache update time")
+			}
+			if tc.withStrayFile {
+				require.NoError(t, os.WriteFile(filepath.Join(policiesCacheDir, "stray"), []byte("stray"), 0600), "Setup: can not create stray file")
+			}
+			if tc.noCacheDir {
+				require.NoError(t, os.RemoveAll(policiesCacheDir), "Setup: can not remove policies cache directory")
+			}
+
+			got, err := m.ListCache(context.Background())
+			if tc.wantErr {
+				require.Error(t, err, "ListCache should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "ListCache should return no error but got one")
+
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "ListCache returned expected output")
+		})
+	}
+}
+
+func TestPurgeCache(t *testing.T) {
+	t.Parallel()
+
+	bus := testutils.NewDbusConn(t)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		objectName string
+		isMachine  bool
+		all        bool
+
+		wantPurged []string
+		wantErr    bool
+	}{
+		"Purge user cache":    {objectName: "bob@example.com", wantPurged: []string{"bob@example.com"}},
+		"Purge machine cache": {isMachine: true, wantPurged: []string{hostname}},
+		"Purge all caches":    {all: true, wantPurged: []string{"alice@example.com", "bob@example.com", hostname}},
+
+		// Error cases
+		"Error on user without cache": {objectName: "doesnotexist@example.com", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeRootDir := t.TempDir()
+			m, err := newTestManager(t, bus, fakeRootDir)
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			policiesCacheDir := filepath.Join(fakeRootDir, "var", "cache", "adsys", policies.PoliciesCacheBaseName)
+			cached := []string{"alice@example.com", "bob@example.com", hostname}
+			for _, objectName := range cached {
+				err := shutil.CopyTree(filepath.Join("testdata", "cache", "policies", "one_gpo"), filepath.Join(policiesCacheDir, objectName), nil)
+				require.NoError(t, err, "Setup: couldn’t copy policies cache")
+			}
+
+			purged, err := m.PurgeCache(context.Background(), tc.objectName, tc.isMachine, tc.all)
+			if tc.wantErr {
+				require.Error(t, err, "PurgeCache should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "PurgeCache should return no error but got one")
+
+			slices.Sort(purged)
+			slices.Sort(tc.wantPurged)
+			require.Equal(t, tc.wantPurged, purged, "PurgeCache should return the purged objects")
+
+			for _, objectName := range cached {
+				_, err := os.Stat(filepath.Join(policiesCacheDir, objectName))
+				if slices.Contains(tc.wantPurged, objectName) {
+					require.ErrorIs(t, err, os.ErrNotExist, "Policies cache of %q should be purged", objectName)
+					continue
+				}
+				require.NoError(t, err, "Policies cache of %q should be kept", objectName)
+				_, err = m.LastUpdateFor(context.Background(), objectName, false)
+				require.NoError(t, err, "Kept policies cache of %q should still be usable", objectName)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	bus := testutils.NewDbusConn(t)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		cachePoliciesUser  string
+		cachePolicyMachine string
+		target             string
+		computerOnly       bool
+		disabledManagers   []string
+
+		wantErr bool
+	}{
+		"User with conflicting keys": {cachePoliciesUser: "two_gpos_with_overrides"},
+		"Machine only":               {cachePolicyMachine: "one_gpo", target: hostname, computerOnly: true},
+		"Machine and user GPOs":      {cachePoliciesUser: "one_gpo", cachePolicyMachine: "two_gpos_override_one_gpo"},
+		"Machine and user GPOs with disabled managers": {cachePoliciesUser: "one_gpo", cachePolicyMachine: "two_gpos_override_one_gpo",
+			disabledManagers: []string{"dconf", "gdm", "scripts"}},
+
+		// Error cases
+		"Error on missing target cache":                      {wantErr: true},
+		"Error on missing machine cache when targeting user": {cachePoliciesUser: "one_gpo", cachePolicyMachine: "-", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeRootDir := t.TempDir()
+			cacheDir := filepath.Join(fakeRootDir, "var", "cache", "adsys")
+			m, err := newTestManager(t, bus, fakeRootDir, policies.WithDisabledManagers(tc.disabledManagers, false))
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			if tc.cachePoliciesUser != "" {
+				err := shutil.CopyTree(filepath.Join("testdata", "cache", "policies", tc.cachePoliciesUser), filepath.Join(cacheDir, policies.PoliciesCacheBaseName, "user"), nil)
+				require.NoError(t, err, "Setup: couldn’t copy user policies cache")
+			}
+			if tc.cachePolicyMachine == "" {
+				machinePolicyCache := filepath.Join(cacheDir, policies.PoliciesCacheBaseName, hostname)
+				err = os.MkdirAll(machinePolicyCache, 0750)
+				require.NoError(t, err, "Setup: cant not create machine policies cache directory")
+				f, err := os.Create(filepath.Join(machinePolicyCache, "policies"))
+				require.NoError(t, err, "Setup: failed to create empty machine policies cache")
+				f.Close()
+			} else if tc.cachePolicyMachine != "-" {
+				err := shutil.CopyTree(filepath.Join("testdata", "cache", "policies", tc.cachePolicyMachine), filepath.Join(cacheDir, policies.PoliciesCacheBaseName, hostname), nil)
+				require.NoError(t, err, "Setup: couldn’t copy machine policies cache")
+			}
+
+			if tc.target == "" {
+				tc.target = "user"
+			}
+			status, err := m.Status(context.Background(), tc.target, tc.computerOnly)
+			if tc.wantErr {
+				require.Error(t, err, "Status should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "Status should return no error but got one")
+
+			d, err := json.MarshalIndent(status, "", "  ")
+			require.NoError(t, err, "Status should be serializable to JSON")
+
+			// The document should only contain the fields of the schema, with all the objects updated.
+			var got policies.Status
+			dec := json.NewDecoder(bytes.NewReader(d))
+			dec.DisallowUnknownFields()
+			require.NoError(t, dec.Decode(&got), "JSON status should match the status schema")
+			require.NotNil(t, got.Machine, "JSON status should always contain the machine policies")
+			require.Equal(t, tc.computerOnly, got.User == nil, "JSON status should contain the user policies only when targeting a user")
+			for _, o := range []*policies.ObjectStatus{got.Machine, got.User} {
+				if o == nil {
+					continue
+				}
+				require.NotNil(t, o.LastApplied, "JSON status should contain the last applied time of %q", o.Name)
+				require.WithinDuration(t, time.Now(), *o.LastApplied, time.Minute, "Last applied time should be the cache one")
+			}
+
+			// Make machine name and update times suitable for golden recording and comparison
+			doc := strings.ReplaceAll(string(d), fmt.Sprintf("%q", hostname), `"HOSTNAME"`)
+			doc = regexp.MustCompile(`("last_applied": )"[^"]*"`).ReplaceAllString(doc, `$1"LAST_APPLIED"`)
+
+			want := testutils.LoadWithUpdateFromGolden(t, doc)
+			require.Equal(t, want, doc, "Status returned expected JSON document")
+		})
+	}
+}
+
 func TestLastUpdateFor(t *testing.T) {
 	t.Parallel()
 
@@ -382,6 +1438,170 @@ func TestLastUpdateFor(t *testing.T) {
 	}
 }
 
+func TestExportImportPolicies(t *testing.T) {
+	t.Parallel()
+
+	bus := testutils.NewDbusConn(t)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname")
+
+	tests := map[string]struct {
+		exportTarget    string
+		exportIsMachine bool
+		importTarget    string
+		importIsMachine bool
+		snapshot        string
+
+		wantExportErr bool
+		wantImportErr bool
+	}{
+		"Export and import user policies":               {exportTarget: "user", importTarget: "otheruser"},
+		"Export and import machine policies":            {exportIsMachine: true, importIsMachine: true},
+		"Target is ignored for machine export":          {exportTarget: "does_not_exist", exportIsMachine: true, importIsMachine: true},
+		"Import policies of a user for the same user":   {exportTarget: "user", importTarget: "user"},
+		"Import snapshot with current format version":   {snapshot: "version: 1\nobject: user\n", importTarget: "user"},
+		"Error on exporting policies of unknown object": {exportTarget: "does_not_exist", wantExportErr: true},
+		"Error on importing machine snapshot for user":  {exportIsMachine: true, importTarget: "user", wantImportErr: true},
+		"Error on importing user snapshot for machine":  {exportTarget: "user", importIsMachine: true, wantImportErr: true},
+		"Error on importing incompatible snapshot":      {snapshot: "version: 42\nobject: user\n", importTarget: "user", wantImportErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fakeRootDir := t.TempDir()
+			cacheDir := filepath.Join(fakeRootDir, "var", "cache", "adsys")
+			m, err := newTestManager(t, bus, fakeRootDir)
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			for _, object := range []string{"user", hostname} {
+				testutils.Copy(t, filepath.Join("testdata", "cache", "policies", "with_assets"), filepath.Join(cacheDir, policies.PoliciesCacheBaseName, object))
+			}
+
+			snapshot := tc.snapshot
+			if snapshot == "" {
+				snapshot, err = m.ExportPolicies(context.Background(), tc.exportTarget, tc.exportIsMachine)
+				if tc.wantExportErr {
+					require.Error(t, err, "ExportPolicies should return an error but got none")
+					return
+				}
+				require.NoError(t, err, "ExportPolicies should return no error but got one")
+			}
+
+			err = m.ImportPolicies(context.Background(), tc.importTarget, tc.importIsMachine, snapshot)
+			if tc.wantImportErr {
+				require.Error(t, err, "ImportPolicies should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "ImportPolicies should return no error but got one")
+
+			importTarget := tc.importTarget
+			if tc.importIsMachine {
+				importTarget = hostname
+			}
+			got, imported, err := m.ImportedPolicies(context.Background(), importTarget)
+			require.NoError(t, err, "ImportedPolicies should return no error but got one")
+			require.True(t, imported, "ImportedPolicies should report imported policies")
+			defer got.Close()
+
+			_, imported, err = m.ImportedPolicies(context.Background(), "not_imported")
+			require.NoError(t, err, "ImportedPolicies should return no error but got one")
+			require.False(t, imported, "ImportedPolicies should report no policies for objects without snapshot")
+
+			if tc.snapshot != "" {
+				require.Empty(t, got.GPOs, "Imported policies should have the GPOs of the snapshot")
+				return
+			}
+			want, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", "with_assets"))
+			require.NoError(t, err, "Setup: can't load exported policies")
+			defer want.Close()
+			require.Equal(t, want.GPOs, got.GPOs, "Imported policies should have the GPOs of the exported ones")
+			require.True(t, got.HasAssets(), "Imported policies should have the assets of the exported ones")
+		})
+	}
+}
+
+func TestSimulatePolicies(t *testing.T) {
+	//t.Parallel()
+
+	bus := testutils.NewDbusConn(t)
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	pols := policies.Policies{GPOs: []policies.GPO{
+		{ID: "{closest}", Name: "closest", Rules: map[string][]entry.Entry{
+			"dconf": {{Key: "com/ubuntu/category/key-s", Value: "'closest'", Meta: "s"}},
+			"gdm":   {{Key: "dconf/org/gnome/login-screen/banner-message-text", Value: "'%COMPUTERNAME%'", Meta: "s"}},
+		}},
+		{ID: "{furthest}", Name: "furthest", Rules: map[string][]entry.Entry{
+			"dconf":     {{Key: "com/ubuntu/category/key-s", Value: "'furthest'", Meta: "s"}},
+			"privilege": {{Key: "allow-local-admins", Disabled: true}, {Key: "client-admins", Value: "%USERNAME%@%DOMAIN%"}},
+		}},
+	}}
+
+	tests := map[string]struct {
+		objectName   string
+		isComputer   bool
+		subscription bool
+
+		want    []string
+		notWant []string
+	}{
+		"User policies": {
+			objectName: "user@example.com",
+			want: []string{
+				"* dconf\n** {{dconfDir}}/db/user@example.com.d/adsys\n[com/ubuntu/category]\nkey-s='closest'\n",
+				"** {{dconfDir}}/db/user@example.com.d/locks/adsys\n/com/ubuntu/category/key-s\n",
+				"* privilege\n** filtered out as the machine is not enrolled to Ubuntu Pro\n",
+				"* scripts\n** no policy\n",
+			},
+			notWant: []string{"* gdm", "furthest"},
+		},
+		"Machine policies include gdm": {
+			objectName: hostname,
+			isComputer: true,
+			want: []string{
+				"** {{dconfDir}}/db/machine.d/adsys\n[com/ubuntu/category]\nkey-s='closest'\n",
+				"* gdm\n** {{dconfDir}}/db/gdm.d/adsys\n[org/gnome/login-screen]\nbanner-message-text='" + hostname + "'\n",
+			},
+		},
+		"Pro policies are shown with their variables expanded when subscribed": {
+			objectName:   "user@example.com",
+			subscription: true,
+			want: []string{
+				"* privilege\n***+ allow-local-admins\n*** client-admins: user@example.com\n",
+			},
+			notWant: []string{"filtered out"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			setSubscriptionState(t, bus, tc.subscription)
+
+			fakeRootDir := t.TempDir()
+			dconfDir := filepath.Join(fakeRootDir, "etc", "dconf")
+			m, err := newTestManager(t, bus, fakeRootDir)
+			require.NoError(t, err, "Setup: couldn’t get a new policy manager")
+
+			got, err := m.SimulatePolicies(context.Background(), tc.objectName, tc.isComputer, &pols)
+			require.NoError(t, err, "SimulatePolicies should return no error but got one")
+
+			for _, want := range tc.want {
+				require.Contains(t, got, strings.ReplaceAll(want, "{{dconfDir}}", dconfDir), "SimulatePolicies should print the expected output")
+			}
+			for _, notWant := range tc.notWant {
+				require.NotContains(t, got, notWant, "SimulatePolicies should not print unexpected output")
+			}
+			require.NoDirExists(t, dconfDir, "SimulatePolicies should not write any dconf database")
+			require.NoDirExists(t, filepath.Join(fakeRootDir, "var", "cache", "adsys", policies.PoliciesCacheBaseName, tc.objectName), "SimulatePolicies should not cache any policy")
+		})
+	}
+}
+
 func TestGetSubscriptionState(t *testing.T) {
 	//t.Parallel()
 
@@ -421,6 +1641,70 @@ func TestGetSubscriptionState(t *testing.T) {
 	}
 }
 
+// newTestManager returns a policy manager applying policies in fakeRootDir with mocked system interactions and
+// the policies cache directory created. opts are applied after the default ones, which they can override.
+func newTestManager(t *testing.T, bus *dbus.Conn, fakeRootDir string, opts ...policies.Option) (*policies.Manager, error) {
+	t.Helper()
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err, "Setup: failed to get hostname for tests.")
+
+	loadedPoliciesFile := filepath.Join(fakeRootDir, "sys", "kernel", "security", "apparmor", "profiles")
+	err = os.MkdirAll(filepath.Dir(loadedPoliciesFile), 0700)
+	require.NoError(t, err, "Setup: can not create loadedPoliciesFile dir")
+	err = os.WriteFile(loadedPoliciesFile, []byte("someprofile (enforce)\n"), 0600)
+	require.NoError(t, err, "Setup: can not create loadedPoliciesFile")
+
+	cacheDir := filepath.Join(fakeRootDir, "var", "cache", "adsys")
+	m, err := policies.NewManager(bus,
+		hostname,
+		mockBackend{},
+		append([]policies.Option{
+			policies.WithCacheDir(cacheDir),
+			policies.WithStateDir(filepath.Join(fakeRootDir, "var", "lib", "adsys")),
+			policies.WithRunDir(filepath.Join(fakeRootDir, "run", "adsys")),
+			policies.WithShareDir(filepath.Join(fakeRootDir, "usr", "share", "adsys")),
+			policies.WithDconfDir(filepath.Join(fakeRootDir, "etc", "dconf")),
+			policies.WithPolicyKitDir(filepath.Join(fakeRootDir, "etc", "polkit-1")),
+			policies.WithSudoersDir(filepath.Join(fakeRootDir, "etc", "sudoers.d")),
+			policies.WithHostsFile(filepath.Join(t.TempDir(), "hosts")),
+			policies.WithChronyConfDir(filepath.Join(fakeRootDir, "etc", "chrony", "conf.d")),
+			policies.WithTimesyncdConfDir(filepath.Join(fakeRootDir, "etc", "systemd", "timesyncd.conf.d")),
+			policies.WithApparmorDir(filepath.Join(fakeRootDir, "etc", "apparmor.d", "adsys")),
+			policies.WithApparmorFsDir(filepath.Dir(loadedPoliciesFile)),
+			policies.WithApparmorParserCmd([]string{"/bin/true"}),
+			policies.WithCertAutoenrollCmd([]string{"/bin/true"}),
+			policies.WithSnapdSocket("/nonexistent/snapd.socket"),
+			policies.WithFlatpakDir("/nonexistent/flatpak"),
+			policies.WithSystemUnitDir(filepath.Join(fakeRootDir, "etc", "systemd", "system")),
+			policies.WithProxyApplier(&mockProxyApplier{}),
+			policies.WithSystemdCaller(&testutils.MockSystemdCaller{}),
+		}, opts...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(filepath.Join(cacheDir, policies.PoliciesCacheBaseName), 0750)
+	require.NoError(t, err, "Setup: cannot create policies cache directory")
+
+	return m, nil
+}
+
+// setSubscriptionState changes the subscription status returned on bus until the end of the test.
+// Tests calling it can't run in parallel.
+func setSubscriptionState(t *testing.T, bus *dbus.Conn, attached bool) {
+	t.Helper()
+
+	subscriptionDbus := bus.Object(consts.SubscriptionDbusRegisteredName,
+		dbus.ObjectPath(consts.SubscriptionDbusObjectPath))
+
+	require.NoError(t, subscriptionDbus.SetProperty(consts.SubscriptionDbusInterface+".Attached", attached), "Setup: can not set subscription status to %v", attached)
+	t.Cleanup(func() {
+		require.NoError(t, subscriptionDbus.SetProperty(consts.SubscriptionDbusInterface+".Attached", false), "Teardown: can not restore subscription status")
+	})
+}
+
 // mockProxyApplier is a mock for the proxy apply object.
 type mockProxyApplier struct {
 	wantApplyError bool
@@ -18,6 +18,8 @@ protocol//bad.com/badpath
 
 	"entry with kerberos auth tag": {Value: "[krb5]protocol://kerberos.com/auth_mount"},
 
+	"entry with kerberos auth tagged smb value": {Value: "[krb5]smb://kerberos.com/smb_share"},
+
 	"entry with multiple values": {Value: `
 protocol://domain.com/mountpath2
 smb://otherdomain.com/mount/path
@@ -83,6 +85,64 @@ protocol://domain.com/mountpath
 `,
 	},
 
+	"entry with mount options": {Value: "smb://domain.com/share?vers=3.1.1&noperm&uid=1000&gid=1000"},
+
+	"entry with kerberos auth tag and mount options": {Value: "[krb5]smb://kerberos.com/share?vers=3.1.1&seal"},
+
+	"entry with file system type": {Value: "nfs://domain.com/share?fstype=nfs4&soft&timeo=100"},
+
+	"entry with empty mount options": {Value: "smb://domain.com/share?"},
+
+	"entry with same location and different mount options": {Value: `
+smb://domain.com/share?vers=3.1.1
+smb://domain.com/share?vers=2.1
+`,
+	},
+
+	"entry with automount": {Value: "smb://domain.com/share?vers=3.1.1&automount=10m"},
+
+	"entry with automount and always mounted values": {Value: `
+[krb5]smb://domain.com/on-demand?automount=90s
+nfs://domain.com/always-mounted
+`,
+	},
+
+	"entry with dfs namespaces": {Value: `
+smb://example.com/dfs/share
+[krb5]smb://example.com/dfs/projects?vers=3.1.1
+smb://example.com/dfs/inodes?serverino
+smb://example.com/dfs/noreferrals?nodfs
+smb://fileserver.example.com/share
+nfs://example.com/export
+`,
+	},
+
+	"entry with invalid automount idle timeout": {Value: "smb://domain.com/share?automount=1.5s"},
+
+	"entry with dangerous mount option": {Value: "smb://domain.com/share?vers=3.1.1&suid"},
+
+	"entry with unknown mount option": {Value: "smb://domain.com/share?unknown=value"},
+
+	"entry with injected mount option": {Value: "smb://domain.com/share?uid=1000,suid"},
+
+	"entry with unsupported file system type": {Value: "smb://domain.com/share?fstype=ext4"},
+
+	"entry with authentication modes": {Value: `
+[anonymous]smb://domain.com/anonymous
+[krb5]smb://domain.com/kerberos
+[keyring]smb://domain.com/keyring
+smb://domain.com/untagged
+`,
+	},
+
+	"entry with explicitly anonymous value": {Value: "[anonymous]smb://domain.com/share"},
+
+	"entry with keyring authentication": {Value: "[keyring]smb://domain.com/share"},
+
+	"entry with keyring authentication on nfs": {Value: "[keyring]nfs://domain.com/share"},
+
+	"entry with unknown authentication tag": {Value: "[password]smb://domain.com/share"},
+
 	"errored entry": {Value: "protocol://domain.com/mountpath", Err: fmt.Errorf("some error")},
 
 	"entry with badly formatted value": {Value: "protocol//domain.com/mountpath"},
@@ -1,6 +1,7 @@
 package mount
 
 import (
+	"context"
 	"os/user"
 )
 
@@ -11,9 +12,41 @@ func WithUserLookup(f func(string) (*user.User, error)) Option {
 	}
 }
 
+// WithKrb5CCDir defines a custom directory where the credential caches are looked up for tests.
+func WithKrb5CCDir(p string) Option {
+	return func(o *options) {
+		o.krb5CCDir = p
+	}
+}
+
 // SetSystemdCaller allows to override the systemdCaller of the Manager for the tests.
 // This is used instead of a option function because we need to control the
 // behavior of the mock in multiple occasions during tests.
 func (m *Manager) SetSystemdCaller(systemdCaller systemdCaller) {
 	m.systemdCaller = systemdCaller
 }
+
+// WithMounter allows to mock the mount operations of the user mount locations.
+func WithMounter(f func(ctx context.Context, locations []string) []error) Option {
+	return func(o *options) {
+		o.mounter = func(ctx context.Context, entries []mountEntry) []error {
+			var locations []string
+			for _, e := range entries {
+				locations = append(locations, e.path)
+			}
+			return f(ctx, locations)
+		}
+	}
+}
+
+// WithDFSResolver allows to mock the lookup of the hosts serving DFS namespaces.
+func WithDFSResolver(f func(ctx context.Context, host string) (bool, error)) Option {
+	return func(o *options) {
+		o.dfsResolver = f
+	}
+}
+
+// NewPermanentError returns a mount failure which is not retried.
+func NewPermanentError(err error) error {
+	return permanentError{err}
+}
@@ -21,6 +21,31 @@ static inline GFile* to_g_file(GObject *obj) {
 	return G_FILE(obj);
 }
 
+static inline void set_auth_mode(GMountOperation *op, int mode) {
+	g_object_set_data(G_OBJECT(op), "adsys-auth-mode", GINT_TO_POINTER(mode));
+}
+
+static inline int get_auth_mode(GMountOperation *op) {
+	return GPOINTER_TO_INT(g_object_get_data(G_OBJECT(op), "adsys-auth-mode"));
+}
+
+static inline void set_entry_index(GFile *file, int index) {
+	g_object_set_data(G_OBJECT(file), "adsys-entry-index", GINT_TO_POINTER(index));
+}
+
+static inline int get_entry_index(GFile *file) {
+	return GPOINTER_TO_INT(g_object_get_data(G_OBJECT(file), "adsys-entry-index"));
+}
+
+// is_permanent_error returns if the mount would fail the same way if retried, like with bad credentials.
+static inline gboolean is_permanent_error(GError *err) {
+	return g_error_matches(err, G_IO_ERROR, G_IO_ERROR_PERMISSION_DENIED) ||
+		g_error_matches(err, G_IO_ERROR, G_IO_ERROR_FAILED_HANDLED) ||
+		g_error_matches(err, G_IO_ERROR, G_IO_ERROR_NOT_SUPPORTED) ||
+		g_error_matches(err, G_IO_ERROR, G_IO_ERROR_INVALID_ARGUMENT) ||
+		g_error_matches(err, G_IO_ERROR, G_IO_ERROR_ALREADY_MOUNTED);
+}
+
 extern void askPassword(GMountOperation*, char*, char*, char*, GAskPasswordFlags);
 extern void mountDone(GObject*, GAsyncResult*, gpointer);
 */
@@ -29,24 +54,25 @@ import "C"
 import (
 	"context"
 	"errors"
-	"fmt"
 	"os"
 	"strings"
 	"unsafe"
 
+	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 )
 
 // mountEntry represents a parsed entry to be mounted.
 type mountEntry struct {
-	path    string
-	krbAuth bool
+	path string
+	auth authMode
 }
 
 // msg struct is the message structure that will be used to communicate in the mountsChan channel.
 type msg struct {
-	path string
-	err  error
+	index int
+	path  string
+	err   error
 }
 
 // mountsChan is the channel through which the async mount operations will communicate with the main
@@ -60,17 +86,42 @@ var mountsChan chan msg
 
 // RunMountForCurrentUser reads the specified file and tries to mount the parsed entries for the
 // current user.
-func RunMountForCurrentUser(ctx context.Context, filepath string) error {
+// Mounts failing with a transient error are retried with an exponential backoff.
+func RunMountForCurrentUser(ctx context.Context, filepath string, opts ...Option) error {
+	// defaults
+	args := options{
+		retries:    consts.DefaultMountRetries,
+		retryDelay: consts.DefaultMountRetryDelay,
+		mounter:    gioMount,
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
 	log.Debugf(ctx, "Reading mount entries from %q", filepath)
-	entries, err := parseEntries(filepath)
+	entries, krb5CCName, err := parseEntries(filepath)
 	if err != nil || len(entries) == 0 {
 		return err
 	}
 
+	// Kerberos mounts use the ticket referenced by the policy if the session doesn't provide one.
+	if krb5CCName != "" && os.Getenv("KRB5CCNAME") == "" {
+		log.Debugf(ctx, "Using kerberos ticket %q for mounts", krb5CCName)
+		if err := os.Setenv("KRB5CCNAME", krb5CCName); err != nil {
+			return err
+		}
+	}
+
+	return mountWithRetries(ctx, entries, args.mounter, args.retries, args.retryDelay)
+}
+
+// gioMount mounts all entries at once with gio and returns the error of each of them, in the same order.
+func gioMount(ctx context.Context, entries []mountEntry) []error {
 	mountsChan = make(chan msg, len(entries))
 
-	for _, entry := range entries {
-		cleanup := setupMountOperation(entry)
+	for i, entry := range entries {
+		cleanup := setupMountOperation(entry, i)
 		// We need to defer the cleanup function in order to avoid memory leaks.
 		defer cleanup()
 	}
@@ -83,30 +134,26 @@ func RunMountForCurrentUser(ctx context.Context, filepath string) error {
 	}()
 
 	// watches the mountsChan channel for the results of the mount operations.
+	errs := make([]error, len(entries))
 	for range entries {
 		m := <-mountsChan
-		logMsg := fmt.Sprintf("Successfully mounted %q", m.path)
-		if m.err != nil {
-			logMsg = fmt.Sprintf("Failed to mount %q: %v", m.path, m.err)
-			err = errors.Join(err, fmt.Errorf("failed to mount %q: %w", m.path, m.err))
-		}
-		log.Debugf(ctx, logMsg)
+		log.Debugf(ctx, "Mount operation of %q completed", m.path)
+		errs[m.index] = m.err
 	}
 
 	C.g_main_loop_quit(mainLoop)
 	<-doneMain
 
 	C.g_main_loop_unref(mainLoop)
-	return err
+	return errs
 }
 
-// parseEntries reads the specified file and parses the listed mount locations from it.
-func parseEntries(filepath string) ([]mountEntry, error) {
-	var entries []mountEntry
-
+// parseEntries reads the specified file and parses the listed mount locations from it, along with
+// the kerberos credential cache to use, if any.
+func parseEntries(filepath string) (entries []mountEntry, krb5CCName string, err error) {
 	content, err := os.ReadFile(filepath)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	lines := strings.Split(string(content), "\n")
@@ -114,27 +161,36 @@ func parseEntries(filepath string) ([]mountEntry, error) {
 		if line == "" {
 			continue
 		}
+		if v, ok := strings.CutPrefix(line, Krb5CCNameKey); ok {
+			krb5CCName = v
+			continue
+		}
 
-		line, krb := strings.CutPrefix(line, "[krb5]")
-		entries = append(entries, mountEntry{path: line, krbAuth: krb})
+		auth, line, err := parseAuthMode(line)
+		if err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, mountEntry{path: line, auth: auth})
 	}
 
-	return entries, nil
+	return entries, krb5CCName, nil
 }
 
-// setupMountOperation creates and starts a gio mount operation for the specified location.
+// setupMountOperation creates and starts a gio mount operation for the specified location, the entry at index.
 // It returns a cleanup function to clean all the allocated C resources.
-func setupMountOperation(entry mountEntry) func() {
+func setupMountOperation(entry mountEntry, index int) func() {
 	path := C.CString(entry.path)
 	file := C.g_file_new_for_uri(path)
+	C.set_entry_index(file, C.int(index))
 
 	op := C.g_mount_operation_new()
 
 	var isAnonymous C.int = C.TRUE
-	if entry.krbAuth {
+	if entry.auth != authAnonymous {
 		isAnonymous = C.FALSE
 	}
 	C.g_mount_operation_set_anonymous(op, isAnonymous)
+	C.set_auth_mode(op, C.int(entry.auth))
 
 	sig := C.CString("ask_password")
 	C.connect_signal(op, sig, C.to_g_callback(C.askPassword), nil)
@@ -162,14 +218,19 @@ func setupMountOperation(entry mountEntry) func() {
 func askPassword(op *C.GMountOperation, unused1, unused2, unused3 *C.char, flags C.GAskPasswordFlags) {
 	rCode := C.GMountOperationResult(C.G_MOUNT_OPERATION_ABORTED)
 
-	// Checks if the mount operation is anonymous and if the anonymous flag is supported.
-	if C.g_mount_operation_get_anonymous(op) == C.TRUE {
+	switch authMode(C.get_auth_mode(op)) {
+	case authAnonymous:
+		// Checks if the anonymous flag is supported.
 		if flags&C.G_ASK_PASSWORD_ANONYMOUS_SUPPORTED == C.G_ASK_PASSWORD_ANONYMOUS_SUPPORTED {
 			rCode = C.GMountOperationResult(C.G_MOUNT_OPERATION_HANDLED)
 		}
-	} else if os.Getenv("KRB5CCNAME") != "" {
-		// Otherwise, checks if the kerberos ticket is available.
-		rCode = C.GMountOperationResult(C.G_MOUNT_OPERATION_HANDLED)
+	case authKerberos:
+		// Checks if the kerberos ticket is available.
+		if os.Getenv("KRB5CCNAME") != "" {
+			rCode = C.GMountOperationResult(C.G_MOUNT_OPERATION_HANDLED)
+		}
+	case authKeyring:
+		// gvfs only asks for the password if none is saved in the keyring: never prompt for it.
 	}
 	C.g_mount_operation_reply(op, rCode)
 }
@@ -185,10 +246,13 @@ func mountDone(sourceObject *C.GObject, res *C.GAsyncResult, _ C.gpointer) {
 	var err *C.GError
 	C.g_file_mount_enclosing_volume_finish(f, res, &err)
 
-	doneMsg := msg{path: C.GoString(uri)}
+	doneMsg := msg{index: int(C.get_entry_index(f)), path: C.GoString(uri)}
 	if err != nil {
 		defer C.g_error_free(err)
 		doneMsg.err = errors.New(C.GoString(err.message))
+		if C.is_permanent_error(err) != C.FALSE {
+			doneMsg.err = permanentError{doneMsg.err}
+		}
 	}
 	mountsChan <- doneMsg
 }
@@ -9,3 +9,123 @@ RunMountForCurrentUser function and its callbacks.
 Right now, the tests would be very similar to the integration tests in
 cmd/adsysd/integration_tests/adsysd_mount_test.go.
 */
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/mount"
+)
+
+func TestRunMountForCurrentUserRetries(t *testing.T) {
+	t.Parallel()
+
+	const share, other = "smb://example.com/share", "smb://example.com/other"
+
+	tests := map[string]struct {
+		locations []string
+		// failures is the number of times the mount of each location fails before succeeding.
+		failures  map[string]int
+		permanent bool
+		retries   int
+
+		wantAttempts map[string]int
+		wantErr      bool
+	}{
+		"Successful mount is not retried":             {locations: []string{share}, wantAttempts: map[string]int{share: 1}},
+		"Failed mount is retried until it succeeds":   {locations: []string{share}, failures: map[string]int{share: 2}, wantAttempts: map[string]int{share: 3}},
+		"Failed mount succeeding on last retry":       {locations: []string{share}, failures: map[string]int{share: 3}, wantAttempts: map[string]int{share: 4}},
+		"Only failed mounts are retried":              {locations: []string{share, other}, failures: map[string]int{other: 1}, wantAttempts: map[string]int{share: 1, other: 2}},
+		"Mounts are retried with their own failures":  {locations: []string{share, other}, failures: map[string]int{share: 1, other: 2}, wantAttempts: map[string]int{share: 2, other: 3}},
+		"Failed mount is not retried without retries": {locations: []string{share}, failures: map[string]int{share: 1}, retries: -1, wantAttempts: map[string]int{share: 1}, wantErr: true},
+		"No mount locations does not mount anything":  {wantAttempts: map[string]int{}},
+		"Error on failed mount once retries are exhausted": {
+			locations: []string{share}, failures: map[string]int{share: 4}, wantAttempts: map[string]int{share: 4}, wantErr: true},
+		"Error on permanent failure, which is not retried": {
+			locations: []string{share, other}, failures: map[string]int{share: 1}, permanent: true, wantAttempts: map[string]int{share: 1, other: 1}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mountsFile := filepath.Join(t.TempDir(), "mounts")
+			var content string
+			for _, l := range tc.locations {
+				content += l + "\n"
+			}
+			require.NoError(t, os.WriteFile(mountsFile, []byte(content), 0600), "Setup: can't write mounts file")
+
+			// The mounter fails each location the requested number of times, then succeeds.
+			var mu sync.Mutex
+			attempts := make(map[string]int)
+			mounter := func(_ context.Context, locations []string) []error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				errs := make([]error, len(locations))
+				for i, l := range locations {
+					attempts[l]++
+					if attempts[l] > tc.failures[l] {
+						continue
+					}
+					errs[i] = errors.New("host is unreachable")
+					if tc.permanent {
+						errs[i] = mount.NewPermanentError(errors.New("permission denied"))
+					}
+				}
+				return errs
+			}
+
+			retries := 3
+			if tc.retries != 0 {
+				retries = max(tc.retries, 0)
+			}
+			err := mount.RunMountForCurrentUser(context.Background(), mountsFile,
+				mount.WithMounter(mounter),
+				mount.WithRetries(retries),
+				mount.WithRetryDelay(time.Millisecond))
+			if tc.wantErr {
+				require.Error(t, err, "RunMountForCurrentUser should have failed but didn't")
+				for l, n := range tc.failures {
+					if attempts[l] <= n {
+						require.ErrorContains(t, err, l, "Error should report the failed mount")
+					}
+				}
+			} else {
+				require.NoError(t, err, "RunMountForCurrentUser failed but shouldn't have")
+			}
+
+			require.Equal(t, tc.wantAttempts, attempts, "Unexpected number of mount attempts")
+		})
+	}
+}
+
+func TestRunMountForCurrentUserStopsRetryingWhenCancelled(t *testing.T) {
+	t.Parallel()
+
+	mountsFile := filepath.Join(t.TempDir(), "mounts")
+	require.NoError(t, os.WriteFile(mountsFile, []byte("smb://example.com/share\n"), 0600), "Setup: can't write mounts file")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts int
+	mounter := func(_ context.Context, locations []string) []error {
+		attempts++
+		cancel()
+		return []error{errors.New("host is unreachable")}
+	}
+
+	err := mount.RunMountForCurrentUser(ctx, mountsFile,
+		mount.WithMounter(mounter),
+		mount.WithRetries(3),
+		mount.WithRetryDelay(time.Hour))
+	require.ErrorIs(t, err, context.Canceled, "RunMountForCurrentUser should report the cancellation")
+	require.ErrorContains(t, err, "smb://example.com/share", "Error should report the failed mount")
+	require.Equal(t, 1, attempts, "Mount should not be retried once cancelled")
+}
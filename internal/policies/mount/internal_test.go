@@ -31,11 +31,21 @@ func TestParseEntryValues(t *testing.T) {
 		"Parse values trimming sequential linebreaks": {entry: "entry with multiple linebreaks"},
 
 		// Special cases.
-		"Parse values from entry with kerberos auth tags": {entry: "entry with kerberos auth tags"},
-		"Returns empty slice if the entry is empty":       {entry: "entry with no value"},
+		"Parse values from entry with kerberos auth tags":            {entry: "entry with kerberos auth tags"},
+		"Parse values from entry with mount options":                 {entry: "entry with mount options"},
+		"Parse values from entry with authentication modes":          {entry: "entry with authentication modes"},
+		"Parse values keeping the first mount options of a location": {entry: "entry with same location and different mount options"},
+		"Returns empty slice if the entry is empty":                  {entry: "entry with no value"},
 
 		// Error cases
-		"Error when parsing entry with badly formatted values": {entry: "entry with badly formatted value", wantErr: true},
+		"Error when parsing entry with badly formatted values":         {entry: "entry with badly formatted value", wantErr: true},
+		"Error when parsing entry with dangerous mount option":         {entry: "entry with dangerous mount option", wantErr: true},
+		"Error when parsing entry with unknown mount option":           {entry: "entry with unknown mount option", wantErr: true},
+		"Error when parsing entry with injected mount option":          {entry: "entry with injected mount option", wantErr: true},
+		"Error when parsing entry with unsupported file system type":   {entry: "entry with unsupported file system type", wantErr: true},
+		"Error when parsing entry with invalid automount idle timeout": {entry: "entry with invalid automount idle timeout", wantErr: true},
+		"Error when parsing entry with unsupported authentication":     {entry: "entry with keyring authentication on nfs", wantErr: true},
+		"Error when parsing entry with unknown authentication tag":     {entry: "entry with unknown authentication tag", wantErr: true},
 	}
 
 	for name, tc := range tests {
@@ -56,6 +66,59 @@ func TestParseEntryValues(t *testing.T) {
 	}
 }
 
+func TestCheckValue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value string
+
+		wantErr bool
+	}{
+		"Anonymous smb":                       {value: "[anonymous]smb://domain.com/share"},
+		"Anonymous nfs":                       {value: "[anonymous]nfs://domain.com/share"},
+		"Anonymous ftp":                       {value: "[anonymous]ftp://domain.com/share"},
+		"Untagged is anonymous":               {value: "nfs://domain.com/share"},
+		"Kerberos smb":                        {value: "[krb5]smb://domain.com/share"},
+		"Kerberos cifs":                       {value: "[krb5]cifs://domain.com/share"},
+		"Kerberos nfs":                        {value: "[krb5]nfs://domain.com/share"},
+		"Kerberos nfs4":                       {value: "[krb5]nfs4://domain.com/share"},
+		"Keyring smb":                         {value: "[keyring]smb://domain.com/share"},
+		"Keyring ftp":                         {value: "[keyring]ftp://domain.com/share"},
+		"Keyring sftp":                        {value: "[keyring]sftp://domain.com/share"},
+		"Keyring dav":                         {value: "[keyring]dav://domain.com/share"},
+		"Tag with mount options":              {value: "[krb5]smb://domain.com/share?vers=3.1.1"},
+		"Unknown protocols are not validated": {value: "[krb5]protocol://domain.com/share"},
+		"Automount with idle timeout":         {value: "smb://domain.com/share?automount=10m"},
+		"DFS options":                         {value: "smb://domain.com/dfs/share?nodfs&noserverino"},
+
+		// Error cases
+		"Error on kerberos ftp":                           {value: "[krb5]ftp://domain.com/share", wantErr: true},
+		"Error on kerberos sftp":                          {value: "[krb5]sftp://domain.com/share", wantErr: true},
+		"Error on keyring nfs":                            {value: "[keyring]nfs://domain.com/share", wantErr: true},
+		"Error on keyring nfs4":                           {value: "[keyring]nfs4://domain.com/share", wantErr: true},
+		"Error on unknown tag":                            {value: "[password]smb://domain.com/share", wantErr: true},
+		"Error on tag not closed":                         {value: "[krb5smb://domain.com/share", wantErr: true},
+		"Error on automount without idle timeout":         {value: "smb://domain.com/share?automount", wantErr: true},
+		"Error on automount with negative idle timeout":   {value: "smb://domain.com/share?automount=-10s", wantErr: true},
+		"Error on automount with zero idle timeout":       {value: "smb://domain.com/share?automount=0s", wantErr: true},
+		"Error on automount with sub second idle timeout": {value: "smb://domain.com/share?automount=500ms", wantErr: true},
+		"Error on automount with unitless idle timeout":   {value: "smb://domain.com/share?automount=600", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkValue(tc.value)
+			if tc.wantErr {
+				require.Error(t, err, "checkValue should have returned an error but didn't")
+				return
+			}
+			require.NoError(t, err, "checkValue should not have returned an error but did")
+		})
+	}
+}
+
 func TestWriteFileWithUIDGID(t *testing.T) {
 	t.Parallel()
 
@@ -117,7 +180,7 @@ func TestWriteFileWithUIDGID(t *testing.T) {
 				})
 			}
 
-			err = writeFileWithUIDGID(filePath, iUID, iGID, "testing writeFileWithUIDGID file")
+			_, err = writeFileWithUIDGID(filePath, iUID, iGID, "testing writeFileWithUIDGID file")
 			if tc.wantErr {
 				require.Error(t, err, "writeFileWithUIDGID should have returned an error but didn't")
 				return
@@ -132,11 +195,22 @@ func TestCreateUnits(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		entry string
+		entry    string
+		dfsHosts map[string]bool
 	}{
-		"Write single unit":      {entry: "entry with one value"},
-		"Write multiple units":   {entry: "entry with multiple values"},
-		"Write krb5 tagged unit": {entry: "entry with kerberos auth tag"},
+		"Write single unit":                               {entry: "entry with one value"},
+		"Write multiple units":                            {entry: "entry with multiple values"},
+		"Write krb5 tagged unit":                          {entry: "entry with kerberos auth tag"},
+		"Write krb5 tagged smb unit":                      {entry: "entry with kerberos auth tagged smb value"},
+		"Write unit with mount options":                   {entry: "entry with mount options"},
+		"Write krb5 tagged unit with mount options":       {entry: "entry with kerberos auth tag and mount options"},
+		"Write unit with file system type and options":    {entry: "entry with file system type"},
+		"Write unit with defaults on empty mount options": {entry: "entry with empty mount options"},
+		"Write explicitly anonymous unit":                 {entry: "entry with explicitly anonymous value"},
+		"Write automount unit with idle timeout":          {entry: "entry with automount"},
+		"Write automount unit along always mounted unit":  {entry: "entry with automount and always mounted values"},
+		"Write DFS namespace units":                       {entry: "entry with dfs namespaces", dfsHosts: map[string]bool{"example.com": true}},
+		"Write DFS namespace units as plain shares":       {entry: "entry with dfs namespaces"},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -146,7 +220,7 @@ func TestCreateUnits(t *testing.T) {
 			require.NoError(t, err, "Setup: failed to parse entries for TestCreateUnits.")
 
 			unitPath := t.TempDir()
-			units := createUnits(parsedValues)
+			units := createUnits(parsedValues, "/run/adsys/krb5cc/machine", tc.dfsHosts)
 
 			for name, content := range units {
 				err := os.WriteFile(filepath.Join(unitPath, name), []byte(content), 0600)
@@ -154,6 +228,7 @@ func TestCreateUnits(t *testing.T) {
 			}
 
 			testutils.CompareTreesWithFiltering(t, unitPath, testutils.GoldenPath(t), testutils.UpdateEnabled())
+			testutils.ValidateSystemdUnits(t, unitPath)
 		})
 	}
 }
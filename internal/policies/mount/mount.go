@@ -6,6 +6,46 @@
 //   - User mounts:   The policy values are parsed into a mounts file that will handled by a
 //     helper binary that will mount the shared locations using gio.
 //
+// Each scope only applies its own policy: system mounts in a user policy, or user mounts in the machine
+// policy, are ignored. Options which are only available in a user session, like keyring authentication,
+// are refused for system mounts.
+//
+// The authentication mode of a mount is set by tagging its value:
+//   - [anonymous]: the default when no tag is set, the share is mounted as guest;
+//   - [krb5]:      the share is mounted with the Kerberos ticket of the object, for smb and nfs shares;
+//   - [keyring]:   the share is mounted with the user password saved in the keyring, for user mounts only.
+//
+// A mode which isn't supported by the protocol fails the policy, so that shares never silently fall back to being
+// mounted anonymously.
+//
+// Kerberos authenticated mounts use the ticket of the object they are mounted for without prompting: system mount
+// units point to the machine credential cache while the user mounts file references the user one, exported by the
+// helper binary before mounting.
+//
+// System mounts accept mount options as a query string suffix, like smb://host/share?vers=3.1.1&noperm. Only known
+// options are accepted, and those which would expose the system, like suid or dev, are rejected. The fstype option
+// selects an alternate file system type of the same family for the mount.
+//
+// A system mount set with the automount option, like smb://host/share?automount=10m, is mounted on demand: an
+// automount unit mounts the share on first access and unmounts it once idle for the given time. Other shares stay
+// mounted for the whole session.
+//
+// Shares of an Active Directory domain, like smb://example.com/dfs/share, are domain-based DFS namespaces: the kernel
+// cifs client follows their referrals to the servers actually hosting the files. Their system mount units are set
+// with noserverino, as the inode numbers of those servers could collide in the same mount. Looking up whether a host
+// is a domain is done in DNS: shares of other hosts are mounted as plain SMB shares. Hosts which can't be looked up
+// keep the decision of the previously generated units, or are mounted as plain SMB shares if there are none. The
+// nodfs option disables DFS referrals of a share. User mounts are left as is, as gio follows the
+// referrals itself.
+//
+// The system mount units generated by the manager are tracked in the state directory, so that only those are
+// stopped, disabled and removed once they are not part of the policy anymore. Units created by other tools are
+// never touched.
+//
+// User mounts failing with a transient error, like an unreachable server, are retried a bounded number of times
+// with an exponential backoff. Permanent errors, like bad credentials, are not retried. The failures remaining once
+// the retries are exhausted are reported.
+//
 // Should the manager fail to write the required assets, an error will be returned.
 // However, if the manager setup all the required steps, it's up to the correctness of the specified
 // entries values and gvfs to mount the requested shared drives.
@@ -18,15 +58,20 @@ import (
 	_ "embed"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/user"
 	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-systemd/v22/unit"
 	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/audit"
+	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/fileutils"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/decorate"
@@ -35,24 +80,140 @@ import (
 type options struct {
 	userLookup    func(string) (*user.User, error)
 	systemUnitDir string
+	stateDir      string
+	krb5CCDir     string
+
+	retries    int
+	retryDelay time.Duration
+	mounter    mounter
+
+	dfsResolver dfsResolver
+}
+
+// WithStateDir overrides the default state directory, where the generated system mount units are tracked.
+func WithStateDir(p string) Option {
+	return func(o *options) {
+		o.stateDir = p
+	}
+}
+
+// WithRetries overrides the default number of times a user mount failing with a transient error is retried.
+// 0 disables the retries.
+func WithRetries(n int) Option {
+	return func(o *options) {
+		o.retries = n
+	}
+}
+
+// WithRetryDelay overrides the default time to wait before retrying a failed user mount for the first time.
+// This delay doubles on each retry.
+func WithRetryDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.retryDelay = d
+	}
 }
 
 // Option represents an optional function that is able to alter a default behavior used in mount.
 type Option func(*options)
 
+// dfsResolver returns true if the shares of host are DFS namespaces.
+type dfsResolver func(ctx context.Context, host string) (bool, error)
+
+// dfsLookupTimeout is the maximum time to wait for a host to be looked up as a DFS namespace.
+const dfsLookupTimeout = 5 * time.Second
+
 //go:embed adsys-mount-template.mount
 var systemdUnitTemplate string
 
-const krbTag string = "[krb5]"
+//go:embed adsys-automount-template.automount
+var systemdAutomountTemplate string
+
 const defaultMountTimeoutSec int = 30
 
+// systemUnitsStateFile lists the system mount units generated by the manager, relative to the state directory.
+var systemUnitsStateFile = filepath.Join("mount", "system-units")
+
+// authMode is how a share is authenticated against.
+type authMode int
+
+const (
+	authAnonymous authMode = iota
+	authKerberos
+	authKeyring
+)
+
+// authTags are the tags prefixing a value to select its authentication mode.
+var authTags = map[string]authMode{
+	"[anonymous]": authAnonymous,
+	"[krb5]":      authKerberos,
+	"[keyring]":   authKeyring,
+}
+
+// protocolAuthModes are the authentication modes supported by known protocol types.
+// Other protocols are passed as is to the mount command, which validates them.
+var protocolAuthModes = map[string][]authMode{
+	"cifs": {authAnonymous, authKerberos, authKeyring},
+	"nfs":  {authAnonymous, authKerberos},
+	"nfs4": {authAnonymous, authKerberos},
+	"fuse": {authAnonymous, authKeyring},
+	"sftp": {authAnonymous, authKeyring},
+	"dav":  {authAnonymous, authKeyring},
+	"davs": {authAnonymous, authKeyring},
+}
+
+func (a authMode) String() string {
+	switch a {
+	case authKerberos:
+		return "kerberos"
+	case authKeyring:
+		return "keyring"
+	}
+	return "anonymous"
+}
+
+// mountOptions are the mount options which can be set on a location.
+var mountOptions = map[string]struct{}{
+	"vers": {}, "nfsvers": {}, "port": {}, "proto": {},
+	"uid": {}, "gid": {}, "forceuid": {}, "forcegid": {}, "file_mode": {}, "dir_mode": {}, "noperm": {},
+	"ro": {}, "rw": {}, "noexec": {}, "nosuid": {}, "nodev": {}, "noatime": {}, "relatime": {},
+	"soft": {}, "hard": {}, "timeo": {}, "retrans": {}, "rsize": {}, "wsize": {}, "nconnect": {}, "actimeo": {},
+	"seal": {}, "cache": {}, "mfsymlinks": {}, "nobrl": {}, "iocharset": {}, "domain": {},
+	"nodfs": {}, "serverino": {}, "noserverino": {},
+}
+
+// dangerousMountOptions are the mount options which are rejected, with the reason why.
+var dangerousMountOptions = map[string]string{
+	"suid":        "setuid programs would be trusted from the share",
+	"dev":         "device files would be usable from the share",
+	"exec":        "it would override noexec",
+	"password":    "the mount units are world-readable",
+	"pass":        "the mount units are world-readable",
+	"credentials": "it would read any local file as root",
+	"sec":         "kerberos authentication is set with the [krb5] tag",
+}
+
+// automountOption is the option mounting a location on demand, and unmounting it once idle for its value.
+const automountOption = "automount"
+
+// fsTypes are the file system types which can be selected with the fstype option, per protocol.
+var fsTypes = map[string][]string{
+	"cifs": {"cifs", "smb3"},
+	"nfs":  {"nfs", "nfs4"},
+}
+
+// Krb5CCNameKey prefixes the line of the user mounts file referencing the user credential cache.
+const Krb5CCNameKey string = "KRB5CCNAME="
+
 // Manager holds information needed for handling the mount policies.
 type Manager struct {
 	runDir        string
 	systemUnitDir string
+	stateDir      string
+	krb5CCDir     string
 	systemdCaller systemdCaller
 
-	userLookup func(string) (*user.User, error)
+	userLookup  func(string) (*user.User, error)
+	dfsResolver dfsResolver
 }
 
 type systemdCaller interface {
@@ -70,6 +231,10 @@ func New(runDir string, systemUnitDir string, systemdCaller systemdCaller, opts
 	o := options{
 		userLookup:    user.Lookup,
 		systemUnitDir: systemUnitDir,
+		stateDir:      consts.DefaultStateDir,
+		// Credential caches are kept up to date in the run directory by the AD backend.
+		krb5CCDir:   filepath.Join(runDir, "krb5cc"),
+		dfsResolver: isDomainDFSNamespace,
 	}
 
 	for _, opt := range opts {
@@ -94,9 +259,12 @@ func New(runDir string, systemUnitDir string, systemdCaller systemdCaller, opts
 	return &Manager{
 		runDir:        runDir,
 		systemUnitDir: systemUnitDir,
+		stateDir:      o.stateDir,
+		krb5CCDir:     o.krb5CCDir,
 		systemdCaller: systemdCaller,
 
-		userLookup: o.userLookup,
+		userLookup:  o.userLookup,
+		dfsResolver: o.dfsResolver,
 	}, nil
 }
 
@@ -110,9 +278,16 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		return m.cleanup(ctx, objectName, isComputer)
 	}
 
-	key := "user"
+	key, otherKey := "user", "system"
 	if isComputer {
-		key = "system"
+		key, otherKey = "system", "user"
+	}
+
+	// Machine mounts are done at boot for all users, while user mounts are done in the user session: never mix them.
+	if slices.ContainsFunc(entries, func(e entry.Entry) bool {
+		return e.Key == otherKey+"-mounts" && !e.Disabled && strings.TrimSpace(e.Value) != ""
+	}) {
+		log.Warning(ctx, gotext.Get("%s mounts can't be applied to %s %q, ignoring them", otherKey, key, objectName))
 	}
 
 	i := slices.IndexFunc(entries, func(e entry.Entry) bool {
@@ -165,6 +340,14 @@ func (m *Manager) applyUserMountsPolicy(ctx context.Context, username string, en
 		return err
 	}
 
+	// gio mounts can't be given mount options.
+	for i, v := range parsedValues {
+		if location, _, found := strings.Cut(v, "?"); found {
+			log.Warning(ctx, gotext.Get("Mount options are not supported for user mounts, mounting %q without them", location))
+			parsedValues[i] = location
+		}
+	}
+
 	s := strings.Join(parsedValues, "\n")
 	if s == "" {
 		if err = m.cleanupMountsFile(ctx, u.Uid); err != nil {
@@ -173,9 +356,26 @@ func (m *Manager) applyUserMountsPolicy(ctx context.Context, username string, en
 		return nil
 	}
 
-	if err = writeFileWithUIDGID(mountsPath, uid, gid, s); err != nil {
+	// Kerberos mounts are done with the ticket the user logged in with.
+	if slices.ContainsFunc(parsedValues, func(v string) bool {
+		mode, _, _ := parseAuthMode(v)
+		return mode == authKerberos
+	}) {
+		krb5CCName, err := os.Readlink(filepath.Join(m.krb5CCDir, "tracking", username))
+		if err != nil {
+			log.Warning(ctx, gotext.Get("Could not find the kerberos ticket of %q, kerberos mounts will rely on the session one: %v", username, err))
+		} else {
+			s = Krb5CCNameKey + "FILE:" + krb5CCName + "\n" + s
+		}
+	}
+
+	changed, err := writeFileWithUIDGID(mountsPath, uid, gid, s)
+	if err != nil {
 		return err
 	}
+	if changed {
+		audit.RecordFile(ctx, mountsPath)
+	}
 
 	return nil
 }
@@ -189,13 +389,22 @@ func (m *Manager) applySystemMountsPolicy(ctx context.Context, machineName strin
 	if err != nil {
 		return err
 	}
-	newUnits := createUnits(parsedValues)
+	// There is no user session, and so no keyring, to read the password from.
+	for _, v := range parsedValues {
+		if mode, _, _ := parseAuthMode(v); mode == authKeyring {
+			return errors.New(gotext.Get("entry %q uses keyring authentication, which is only supported for user mounts", v))
+		}
+	}
+	newUnits := createUnits(parsedValues, filepath.Join(m.krb5CCDir, machineName), m.dfsNamespaces(ctx, parsedValues))
 
 	// Marks shares to write as new units and removes from map units that shouldn't change
 	needsReload := false
 	var unitsToEnable []string
 
-	prevUnits := m.currentSystemMountUnits()
+	prevUnits, err := m.currentSystemMountUnits()
+	if err != nil {
+		return err
+	}
 
 	// Removes from the map all the units that are supposed to be written or updated.
 	for name := range newUnits {
@@ -210,13 +419,25 @@ func (m *Manager) applySystemMountsPolicy(ctx context.Context, machineName strin
 	if err := m.cleanupMountUnits(ctx, unitsToClean); err != nil {
 		return err
 	}
+	needsReload = len(unitsToClean) > 0
+
+	// Track the units before writing them, so that they are cleaned up even if we fail in between.
+	var names []string
+	for name := range newUnits {
+		names = append(names, name)
+	}
+	if err := m.saveSystemMountUnits(names); err != nil {
+		return err
+	}
 
 	for name, content := range newUnits {
-		written, err := writeIfChanged(filepath.Join(m.systemUnitDir, name), content)
+		//nolint:gosec // G306 - This asset needs to be world-readable.
+		written, err := fileutils.WriteIfChanged(filepath.Join(m.systemUnitDir, name), []byte(content), 0644)
 		if err != nil {
 			return err
 		}
 		if written {
+			audit.RecordFile(ctx, filepath.Join(m.systemUnitDir, name))
 			unitsToEnable = append(unitsToEnable, name)
 		}
 		needsReload = needsReload || written
@@ -233,6 +454,13 @@ func (m *Manager) applySystemMountsPolicy(ctx context.Context, machineName strin
 
 	// Enables and starts new units.
 	for _, name := range unitsToEnable {
+		// Mounts done on demand are started by their automount: disable them in case they were always mounted before.
+		if isOnDemandMount(name, newUnits) {
+			if err := m.systemdCaller.DisableUnit(ctx, name); err != nil {
+				return err
+			}
+			continue
+		}
 		if err := m.systemdCaller.EnableUnit(ctx, name); err != nil {
 			return err
 		}
@@ -249,15 +477,120 @@ type mountInfo struct {
 	hostname   string
 	sharedPath string
 	protocol   string
+	fsType     string
 	options    []string
+	// idleTimeout is set for the locations mounted on demand.
+	idleTimeout time.Duration
+}
+
+// dfsNamespaces returns the hosts of the cifs locations of values which serve DFS namespaces.
+// Hosts which can't be looked up keep the decision recorded in the previously generated units, so that a transient
+// DNS failure doesn't change how their shares are mounted.
+func (m *Manager) dfsNamespaces(ctx context.Context, values []string) map[string]bool {
+	hosts := make(map[string]bool)
+	var previous map[string]bool
+	for _, v := range values {
+		mi := parseMountPath(v)
+		if _, done := hosts[mi.hostname]; done || !isDFSCandidate(mi) {
+			continue
+		}
+
+		isDFS, err := m.dfsResolver(ctx, mi.hostname)
+		if err != nil {
+			if previous == nil {
+				previous = m.previousDFSNamespaces(ctx)
+			}
+			isDFS = previous[mi.hostname]
+			if isDFS {
+				log.Warning(ctx, gotext.Get("Could not check if %q serves DFS namespaces, keeping its shares mounted as DFS namespaces: %v", mi.hostname, err))
+			} else {
+				log.Warning(ctx, gotext.Get("Could not check if %q serves DFS namespaces, mounting its shares as plain SMB shares: %v", mi.hostname, err))
+			}
+		}
+		if isDFS {
+			log.Debugf(ctx, "Shares of %q are DFS namespaces", mi.hostname)
+		}
+		hosts[mi.hostname] = isDFS
+	}
+	return hosts
+}
+
+// previousDFSNamespaces returns the hosts whose cifs locations were mounted without the server inode numbers by the
+// previously generated system mount units, as DFS namespaces are.
+func (m *Manager) previousDFSNamespaces(ctx context.Context) map[string]bool {
+	hosts := make(map[string]bool)
+
+	units, err := m.currentSystemMountUnits()
+	if err != nil {
+		log.Warning(ctx, gotext.Get("Could not read previously generated mount units: %v", err))
+		return hosts
+	}
+	for name := range units {
+		if !strings.HasSuffix(name, ".mount") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(m.systemUnitDir, name))
+		if err != nil {
+			log.Warning(ctx, gotext.Get("Could not read previously generated mount unit %q: %v", name, err))
+			continue
+		}
+
+		var host string
+		var noServerIno bool
+		for _, l := range strings.Split(string(content), "\n") {
+			if what, ok := strings.CutPrefix(l, "What=//"); ok {
+				host, _, _ = strings.Cut(what, "/")
+			} else if opts, ok := strings.CutPrefix(l, "Options="); ok {
+				noServerIno = slices.Contains(strings.Split(opts, ","), "noserverino")
+			}
+		}
+		if host != "" && noServerIno {
+			hosts[host] = true
+		}
+	}
+	return hosts
+}
+
+// isDFSCandidate returns true if mi is a cifs location which can follow DFS referrals.
+func isDFSCandidate(mi mountInfo) bool {
+	return mi.protocol == "cifs" && !slices.Contains(mi.options, "nodfs")
+}
+
+// isDomainDFSNamespace returns true if host is an Active Directory domain, whose shares are domain-based DFS
+// namespaces. The domain controllers of a domain are advertised in its DNS records.
+func isDomainDFSNamespace(ctx context.Context, host string) (bool, error) {
+	if net.ParseIP(host) != nil {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dfsLookupTimeout)
+	defer cancel()
+
+	_, addrs, err := net.DefaultResolver.LookupSRV(ctx, "ldap", "tcp", "dc._msdcs."+host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return len(addrs) > 0, nil
 }
 
 // createUnits formats the adsys-.mount template with the specified paths.
-func createUnits(mountPaths []string) map[string]string {
+// Kerberos cifs mounts are authenticated with the credential cache at krb5CCName.
+// cifs locations of the dfsHosts follow DFS referrals, which can span several servers: they don't use the server
+// inode numbers, unless set otherwise.
+// Locations mounted on demand get an automount unit too, while their mount unit has no install section, as it is
+// only started by the automount.
+func createUnits(mountPaths []string, krb5CCName string, dfsHosts map[string]bool) map[string]string {
 	units := make(map[string]string)
 
 	for _, mp := range mountPaths {
 		mi := parseMountPath(mp)
+		if isDFSCandidate(mi) && dfsHosts[mi.hostname] &&
+			!slices.Contains(mi.options, "serverino") && !slices.Contains(mi.options, "noserverino") {
+			mi.options = append(mi.options, "noserverino")
+		}
 
 		what := whatStringFromInfo(mi)
 		where := filepath.Join("/", "adsys", mi.protocol, mi.hostname, mi.sharedPath)
@@ -267,48 +600,76 @@ func createUnits(mountPaths []string) map[string]string {
 			opts = strings.Join(mi.options, ",")
 		}
 
+		// cifs.upcall reads the credential cache from the environment of the process requesting the mount.
+		var env string
+		if mi.protocol == "cifs" && slices.Contains(mi.options, "sec=krb5i") {
+			env = fmt.Sprintf("\nEnvironment=%sFILE:%s", Krb5CCNameKey, krb5CCName)
+		}
+
 		content := fmt.Sprintf(systemdUnitTemplate,
 			mp,                     // Description
 			what,                   // What
 			where,                  // Where
-			mi.protocol,            // Type
+			mi.fsType,              // Type
 			opts,                   // Options
+			env,                    // Environment
 			defaultMountTimeoutSec, // TimeoutSec
 		)
 
-		n := fmt.Sprintf("%s.mount", unit.UnitNameEscape(where[1:]))
-		units[n] = content
+		n := unit.UnitNameEscape(where[1:])
+		if mi.idleTimeout > 0 {
+			content, _, _ = strings.Cut(content, "\n\n[Install]")
+			content += "\n"
+			units[n+".automount"] = fmt.Sprintf(systemdAutomountTemplate,
+				mp,                            // Description
+				where,                         // Where
+				int(mi.idleTimeout.Seconds()), // TimeoutIdleSec
+			)
+		}
+		units[n+".mount"] = content
 	}
 
 	return units
 }
 
+// isOnDemandMount returns true if name is a mount unit started by an automount unit of units.
+func isOnDemandMount(name string, units map[string]string) bool {
+	base, isMount := strings.CutSuffix(name, ".mount")
+	if !isMount {
+		return false
+	}
+	_, ok := units[base+".automount"]
+	return ok
+}
+
 // parseMountPath takes a mount path <protocol>://<hostname>/<shared_path> and parses it
 // into the richer type mountInfo.
 func parseMountPath(path string) mountInfo {
 	var info mountInfo
 
-	// path = [krb5]protocol://hostname/shared_path
-	krb5 := strings.HasPrefix(path, krbTag)
-	if krb5 {
-		path = strings.TrimPrefix(path, krbTag)
+	// path = [tag]protocol://hostname/shared_path
+	// The tag was validated when parsing the entry.
+	mode, path, _ := parseAuthMode(path)
+	if mode == authKerberos {
 		// Using krb5i since it's supported by both cifs and nfs, while krb5p is only supported by nfs.
 		info.options = append(info.options, "sec=krb5i")
 	}
 
+	// path = protocol://hostname/shared_path?options
+	path, query, _ := strings.Cut(path, "?")
+
 	// path = protocol://hostname/shared_path
 	protocol, path, _ := strings.Cut(path, ":")
+	info.protocol = protocolType(protocol)
+	info.fsType = info.protocol
 
-	// Some aliases for common mounts protocols, as they need to be converted to a type
-	// recognized by systemd and the mount command.
-	switch protocol {
-	case "smb":
-		info.protocol = "cifs"
-	case "ftp":
-		info.protocol = "fuse"
-	default:
-		info.protocol = protocol
+	// The options were validated when parsing the entry.
+	fsType, idleTimeout, options, _ := parseMountOptions(info.protocol, query)
+	if fsType != "" {
+		info.fsType = fsType
 	}
+	info.idleTimeout = idleTimeout
+	info.options = append(info.options, options...)
 
 	// path = //hostname/shared_path
 	path = path[2:]
@@ -319,6 +680,94 @@ func parseMountPath(path string) mountInfo {
 	return info
 }
 
+// parseAuthMode returns the authentication mode tagging value and the value without its tag.
+// Untagged values are anonymous.
+func parseAuthMode(value string) (mode authMode, location string, err error) {
+	if !strings.HasPrefix(value, "[") {
+		return authAnonymous, value, nil
+	}
+	i := strings.Index(value, "]")
+	if i == -1 {
+		return authAnonymous, value, errors.New(gotext.Get("authentication tag of %q is not closed", value))
+	}
+	mode, ok := authTags[value[:i+1]]
+	if !ok {
+		return authAnonymous, value, errors.New(gotext.Get("unknown authentication tag %q", value[:i+1]))
+	}
+	return mode, value[i+1:], nil
+}
+
+// checkAuthMode checks that the authentication mode is supported by the protocol type, if known.
+func checkAuthMode(mode authMode, protocol string) error {
+	modes, known := protocolAuthModes[protocol]
+	if !known || slices.Contains(modes, mode) {
+		return nil
+	}
+	return errors.New(gotext.Get("%s authentication is not supported for %s mounts", mode, protocol))
+}
+
+// protocolType converts some aliases for common mounts protocols to a type recognized by systemd and the mount command.
+func protocolType(protocol string) string {
+	switch protocol {
+	case "smb":
+		return "cifs"
+	case "ftp":
+		return "fuse"
+	}
+	return protocol
+}
+
+// parseMountOptions parses the query string of a location into mount options, in their declared order.
+// It returns the file system type selected with fstype and the idle timeout set with automount, if any.
+func parseMountOptions(protocol, query string) (fsType string, idleTimeout time.Duration, options []string, err error) {
+	if query == "" {
+		return "", 0, nil, nil
+	}
+
+	for _, opt := range strings.Split(query, "&") {
+		if opt == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(opt, "=")
+		if reason, ok := dangerousMountOptions[key]; ok {
+			return "", 0, nil, errors.New(gotext.Get("mount option %q is not allowed: %s", key, reason))
+		}
+		// Options are comma separated in the mount unit: prevent injecting other ones.
+		if strings.ContainsAny(value, ",\n") {
+			return "", 0, nil, errors.New(gotext.Get("mount option %q has an invalid value %q", key, value))
+		}
+
+		if key == "fstype" {
+			if !slices.Contains(fsTypes[protocol], value) {
+				return "", 0, nil, errors.New(gotext.Get("file system type %q is not supported for %s mounts", value, protocol))
+			}
+			fsType = value
+			continue
+		}
+		if key == automountOption {
+			if idleTimeout, err = parseIdleTimeout(value); err != nil {
+				return "", 0, nil, err
+			}
+			continue
+		}
+		if _, ok := mountOptions[key]; !ok {
+			return "", 0, nil, errors.New(gotext.Get("unknown mount option %q", key))
+		}
+		options = append(options, opt)
+	}
+
+	return fsType, idleTimeout, options, nil
+}
+
+// parseIdleTimeout parses the idle timeout of an automount, like 10m or 90s, in whole seconds of at least one second.
+func parseIdleTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < time.Second || d%time.Second != 0 {
+		return 0, errors.New(gotext.Get("idle timeout %q is invalid, expecting a whole number of seconds of at least 1s, like 90s or 10m", value))
+	}
+	return d, nil
+}
+
 // whatStringFromInfo creates the What value of a systemd mount unit from the
 // specified info as some protocols have quite different What values.
 // If the protocol is not recognized, the What string will be that of a partition
@@ -360,8 +809,9 @@ func parseEntryValues(ctx context.Context, e entry.Entry) (p []string, err error
 			continue
 		}
 
-		// Compares "normal" and prefixed values the same way, since the unit name will be the same.
-		tmp := strings.TrimPrefix(v, krbTag)
+		// Compares "normal", prefixed and values with options the same way, since the unit name will be the same.
+		_, tmp, _ := parseAuthMode(v)
+		tmp, _, _ = strings.Cut(tmp, "?")
 		if prev, ok := seen[tmp]; ok {
 			if prev == v {
 				log.Debug(ctx, gotext.Get("Value %q is duplicated.", v))
@@ -382,57 +832,57 @@ func parseEntryValues(ctx context.Context, e entry.Entry) (p []string, err error
 	return p, nil
 }
 
-// checkValue checks if the entry value respects the defined formatting directive: <protocol>://<hostname-or-ip>/<shared-path>.
+// checkValue checks if the entry value respects the defined formatting directive: [tag]<protocol>://<hostname-or-ip>/<shared-path>.
 func checkValue(value string) error {
-	// Removes the kerberos auth tag, if it exists
-	tmp := strings.TrimPrefix(value, krbTag)
+	// Removes the authentication tag, if it exists
+	mode, tmp, err := parseAuthMode(value)
+	if err != nil {
+		return errors.New(gotext.Get("entry %q is badly formatted: %v", value, err))
+	}
 
-	// Value left: protocol://<hostname-or-ip>/<shared-path>
-	if _, hostnameAndPath, found := strings.Cut(tmp, ":"); !found || !strings.HasPrefix(hostnameAndPath, "//") {
+	// Value left: protocol://<hostname-or-ip>/<shared-path>?<options>
+	tmp, query, _ := strings.Cut(tmp, "?")
+	protocol, hostnameAndPath, found := strings.Cut(tmp, ":")
+	if !found || !strings.HasPrefix(hostnameAndPath, "//") {
 		return errors.New(gotext.Get("entry %q is badly formatted", value))
 	}
 
-	return nil
-}
-
-// writeIfChanged will only write to path if content is different from current content.
-func writeIfChanged(path string, content string) (done bool, err error) {
-	defer decorate.OnError(&err, gotext.Get("can't save %s", path))
-
-	if oldContent, err := os.ReadFile(path); err == nil && string(oldContent) == content {
-		return false, nil
+	if err := checkAuthMode(mode, protocolType(protocol)); err != nil {
+		return errors.New(gotext.Get("entry %q has invalid authentication: %v", value, err))
 	}
 
-	//nolint:gosec // G306 - This asset needs to be world-readable.
-	if err := os.WriteFile(path+".new", []byte(content), 0644); err != nil {
-		return false, err
-	}
-	if err := os.Rename(path+".new", path); err != nil {
-		return false, err
+	if _, _, _, err := parseMountOptions(protocolType(protocol), query); err != nil {
+		return errors.New(gotext.Get("entry %q has invalid options: %v", value, err))
 	}
 
-	return true, nil
+	return nil
 }
 
 // writeFileWithUIDGID writes the content into the specified path and changes its ownership to the specified uid/gid.
-func writeFileWithUIDGID(path string, uid, gid int, content string) (err error) {
+// The file is left in place if it already has this content: it returns true only if the file was written.
+func writeFileWithUIDGID(path string, uid, gid int, content string) (changed bool, err error) {
 	defer decorate.OnError(&err, gotext.Get("failed when writing file %s", path))
 
 	// #nosec G306. This should be world-readable.
 	if err = os.WriteFile(path+".new", []byte(content+"\n"), 0600); err != nil {
-		return err
+		return false, err
 	}
 
 	// Fixes the file ownership before renaming it.
 	if err = chown(path+".new", nil, uid, gid); err != nil {
-		return err
+		return false, err
 	}
 
-	if err = os.Rename(path+".new", path); err != nil {
-		return err
+	changed, err = fileutils.ReplaceIfChanged(path+".new", path)
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		// Ownership is not compared, so we still fix it on the unchanged file.
+		return false, chown(path, nil, uid, gid)
 	}
 
-	return nil
+	return true, nil
 }
 
 // mkdirAllWithUIDGID create a directory and sets its ownership to the specified uid and gid.
@@ -476,11 +926,25 @@ func (m *Manager) cleanup(ctx context.Context, objectName string, isComputer boo
 		return m.cleanupMountsFile(ctx, u.Uid)
 	}
 
+	prevUnits, err := m.currentSystemMountUnits()
+	if err != nil {
+		return err
+	}
 	var units []string
-	for k := range m.currentSystemMountUnits() {
+	for k := range prevUnits {
 		units = append(units, k)
 	}
-	return m.cleanupMountUnits(ctx, units)
+	if err := m.cleanupMountUnits(ctx, units); err != nil {
+		return err
+	}
+	if err := m.saveSystemMountUnits(nil); err != nil {
+		return err
+	}
+
+	if len(units) == 0 {
+		return nil
+	}
+	return m.systemdCaller.DaemonReload(ctx)
 }
 
 // cleanupMountsFile removes the mounts file, if there is any, created for the user with the specified uid.
@@ -514,7 +978,8 @@ func (m *Manager) cleanupMountUnits(ctx context.Context, units []string) (err er
 			return err
 		}
 
-		if err := os.Remove(filepath.Join(m.systemUnitDir, unit)); err != nil {
+		// The unit may have already been removed.
+		if err := os.Remove(filepath.Join(m.systemUnitDir, unit)); err != nil && !errors.Is(err, os.ErrNotExist) {
 			return errors.New(gotext.Get("could not remove file %q: %v", unit, err))
 		}
 	}
@@ -522,14 +987,56 @@ func (m *Manager) cleanupMountUnits(ctx context.Context, units []string) (err er
 	return nil
 }
 
-// currentSystemMountUnits reads the unit directory and returns a map containing the adsys mount units found.
-func (m *Manager) currentSystemMountUnits() map[string]struct{} {
-	paths, _ := filepath.Glob(filepath.Join(m.systemUnitDir, "adsys-*.mount"))
+// currentSystemMountUnits returns a map containing the system mount units generated by the manager.
+// If they were not tracked yet, the units generated from our template are looked up in the unit directory.
+func (m *Manager) currentSystemMountUnits() (units map[string]struct{}, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't list generated mount units"))
 
-	units := make(map[string]struct{})
-	for _, path := range paths {
-		units[filepath.Base(path)] = struct{}{}
+	units = make(map[string]struct{})
+
+	d, err := os.ReadFile(filepath.Join(m.stateDir, systemUnitsStateFile))
+	if err == nil {
+		for _, name := range strings.Split(string(d), "\n") {
+			if name = strings.TrimSpace(name); name != "" {
+				units[name] = struct{}{}
+			}
+		}
+		return units, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
 	}
 
-	return units
+	for ext, template := range map[string]string{".mount": systemdUnitTemplate, ".automount": systemdAutomountTemplate} {
+		header, _, _ := strings.Cut(template, "\n")
+		paths, _ := filepath.Glob(filepath.Join(m.systemUnitDir, "adsys-*"+ext))
+		for _, path := range paths {
+			content, err := os.ReadFile(path)
+			if err != nil || !strings.HasPrefix(string(content), header+"\n") {
+				continue
+			}
+			units[filepath.Base(path)] = struct{}{}
+		}
+	}
+
+	return units, nil
+}
+
+// saveSystemMountUnits tracks units as the system mount units generated by the manager.
+func (m *Manager) saveSystemMountUnits(units []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save generated mount units"))
+
+	slices.Sort(units)
+	var content string
+	for _, name := range units {
+		content += name + "\n"
+	}
+
+	p := filepath.Join(m.stateDir, systemUnitsStateFile)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+".new", []byte(content), 0600); err != nil {
+		return err
+	}
+	return os.Rename(p+".new", p)
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -79,11 +80,15 @@ func TestApplyPolicy(t *testing.T) {
 		userReturnedUID   string
 		userReturnedGID   string
 		pathAlreadyExists bool
+		userKrb5CCName    string
 
 		// System specific
 		firstMockSystemdCaller      mockSystemdCaller
 		secondMockSystemdCaller     mockSystemdCaller
 		pathAlreadyExistsSecondCall bool
+		dfsHosts                    []string
+		dfsResolverFails            bool
+		dfsResolverFailsSecondCall  bool
 
 		wantErr           bool
 		wantErrSecondCall bool
@@ -95,11 +100,18 @@ func TestApplyPolicy(t *testing.T) {
 		"User, successfully apply policy for entry with repeated values":        {entries: []string{"entry with repeated values"}},
 		"User, successfully apply policy for entry with repeated tagged values": {entries: []string{"entry with repeated tagged values"}},
 		"User, successfully apply policy filtering out unsupported keys":        {entries: []string{"entry with multiple values", "entry with one value"}, keys: []string{"unsupported", "user-mounts"}},
+		"User, system mounts are not applied":                                   {keys: []string{"system-mounts"}},
+		"User, only user mounts are applied":                                    {entries: []string{"entry with multiple values", "entry with one value"}, keys: []string{"system-mounts", "user-mounts"}},
 
 		// Special cases.
 		"User, successfully apply policy with kerberos auth tags":                             {entries: []string{"entry with kerberos auth tags"}},
+		"User, successfully apply policy with kerberos auth tags and user ticket":             {entries: []string{"entry with kerberos auth tags"}, userKrb5CCName: "/tmp/krb5cc_4242_test"},
+		"User, does not reference the user ticket without kerberos auth tags":                 {userKrb5CCName: "/tmp/krb5cc_4242_test"},
 		"User, successfully apply policy prioritizing the first value found, despite the tag": {entries: []string{"entry with same values tagged and untagged"}},
 		"User, does nothing if the entry is disabled":                                         {isDisabled: true},
+		"User, mount options are removed":                                                     {entries: []string{"entry with mount options"}},
+		"User, successfully apply policy with authentication modes":                           {entries: []string{"entry with authentication modes"}},
+		"User, DFS namespaces are left as is":                                                 {entries: []string{"entry with dfs namespaces"}, dfsHosts: []string{"example.com"}},
 
 		// Badly formatted entries.
 		"User, successfully apply policy trimming whitespaces":           {entries: []string{"entry with spaces"}},
@@ -120,13 +132,20 @@ func TestApplyPolicy(t *testing.T) {
 		"System, successfully apply policy for entry with repeated values":        {entries: []string{"entry with repeated values"}, isComputer: true},
 		"System, successfully apply policy for entry with repeated tagged values": {entries: []string{"entry with repeated tagged values"}, isComputer: true},
 		"System, successfully apply policy filtering out unsupported keys":        {entries: []string{"entry with multiple values", "entry with one value"}, keys: []string{"unsupported", "system-mounts"}, isComputer: true},
+		"System, user mounts are not applied":                                     {keys: []string{"user-mounts"}, isComputer: true},
+		"System, only system mounts are applied":                                  {entries: []string{"entry with multiple values", "entry with one value"}, keys: []string{"user-mounts", "system-mounts"}, isComputer: true},
 
 		// Special cases.
 		"System, successfully apply policy with kerberos tagged values":                         {entries: []string{"entry with kerberos auth tags"}, isComputer: true},
 		"System, successfully apply policy prioritizing the first value found, despite the tag": {entries: []string{"entry with same values tagged and untagged"}, isComputer: true},
+		"System, successfully apply policy with automount and always mounted values":            {entries: []string{"entry with automount and always mounted values"}, isComputer: true},
 		"System, only emit a warning when starting new units fails":                             {isComputer: true, firstMockSystemdCaller: mockSystemdCaller{failOn: start}},
 		"System, only emit a warning when stopping previous units fails":                        {isComputer: true, secondCall: []string{"entry with multiple values"}, secondMockSystemdCaller: mockSystemdCaller{failOn: stop}},
 		"System, does nothing if the entry is disabled":                                         {isComputer: true, isDisabled: true},
+		"System, successfully apply policy with mount options":                                  {entries: []string{"entry with mount options"}, isComputer: true},
+		"System, successfully apply policy with DFS namespaces":                                 {entries: []string{"entry with dfs namespaces"}, dfsHosts: []string{"example.com"}, isComputer: true},
+		"System, DFS namespaces are mounted as plain shares if they can't be looked up":         {entries: []string{"entry with dfs namespaces"}, dfsHosts: []string{"example.com"}, dfsResolverFails: true, isComputer: true},
+		"System, DFS namespaces are kept if they can't be looked up anymore":                    {entries: []string{"entry with dfs namespaces"}, secondCall: []string{"entry with dfs namespaces"}, dfsHosts: []string{"example.com"}, dfsResolverFailsSecondCall: true, isComputer: true},
 
 		// Badly formatted entries.
 		"System, successfully apply policy trimming whitespaces":           {entries: []string{"entry with spaces"}, isComputer: true},
@@ -138,6 +157,7 @@ func TestApplyPolicy(t *testing.T) {
 		"System, mount units are added on refreshing policy with some matching values":            {entries: []string{"entry with multiple values"}, secondCall: []string{"entry with multiple matching values"}, isComputer: true},
 		"System, mount units are updated on refreshing policy with an entry with multiple values": {secondCall: []string{"entry with multiple values"}, isComputer: true},
 		"System, mount units are removed on refreshing policy with no entries":                    {secondCall: []string{"no entries"}, isComputer: true},
+		"System, automount units are removed on refreshing policy with always mounted values":     {entries: []string{"entry with automount"}, secondCall: []string{"entry with mount options"}, isComputer: true},
 		"System, mount units are removed on refreshing policy with an empty entry":                {secondCall: []string{"entry with no value"}, isComputer: true},
 		"System, mount units are removed on refreshing policy with disabled entry":                {secondCall: []string{"entry with one value"}, isDisabledSecondCall: true},
 
@@ -158,6 +178,7 @@ func TestApplyPolicy(t *testing.T) {
 		"Error when cleaning up user policy with no entries and path already exists as a directory":  {entries: []string{"no entries"}, pathAlreadyExists: true, wantErr: true},
 		"Error when cleaning up user policy with empty entry and path already exists as a directory": {entries: []string{"entry with no value"}, pathAlreadyExists: true, wantErr: true},
 		"Error when applying policy with entry containing badly formatted value":                     {entries: []string{"entry with badly formatted value"}, wantErr: true},
+		"Error when applying policy with entry containing unsupported authentication":                {entries: []string{"entry with keyring authentication on nfs"}, wantErr: true},
 
 		/**************************** SYSTEM ***************************/
 		// Error cases.
@@ -165,10 +186,14 @@ func TestApplyPolicy(t *testing.T) {
 		"Error when daemon-reload fails":                                         {firstMockSystemdCaller: mockSystemdCaller{failOn: daemonReload}, isComputer: true, wantErr: true},
 		"Error when disabling units for clean up fails":                          {secondCall: []string{"entry with multiple values"}, isComputer: true, secondMockSystemdCaller: mockSystemdCaller{failOn: disable}, wantErrSecondCall: true},
 		"Error when enabling new units fails":                                    {isComputer: true, firstMockSystemdCaller: mockSystemdCaller{failOn: enable}, wantErr: true},
+		"Error when disabling on demand mount units fails":                       {entries: []string{"entry with automount"}, isComputer: true, firstMockSystemdCaller: mockSystemdCaller{failOn: disable}, wantErr: true},
 		"Error when trying to update policy with badly formatted entry":          {secondCall: []string{"entry with badly formatted value"}, wantErrSecondCall: true, isComputer: true},
 		"Error when applying policy and system mount unit already exists as dir": {isComputer: true, pathAlreadyExists: true, wantErr: true},
 		"Error when updating policy and system mount unit to remove is a dir":    {secondCall: []string{"entry with multiple values"}, isComputer: true, pathAlreadyExistsSecondCall: true, wantErrSecondCall: true},
+		"Error when daemon-reload fails after removing units":                    {secondCall: []string{"no entries"}, isComputer: true, secondMockSystemdCaller: mockSystemdCaller{failOn: daemonReload}, wantErrSecondCall: true},
 		"Error when applying system policy and the entry is errored":             {entries: []string{"errored entry"}, isComputer: true, wantErr: true},
+		"Error when applying system policy with dangerous mount option":          {entries: []string{"entry with dangerous mount option"}, isComputer: true, wantErr: true},
+		"Error when applying system policy with keyring authentication":          {entries: []string{"entry with keyring authentication"}, isComputer: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -200,7 +225,26 @@ func TestApplyPolicy(t *testing.T) {
 				entries = append(entries, e)
 			}
 
-			opts := []mount.Option{}
+			// Credential caches are referenced by path in the generated files.
+			krb5CCDir := filepath.Join("/run", "adsys", "krb5cc")
+			if tc.userKrb5CCName != "" {
+				krb5CCDir = t.TempDir()
+				err := os.MkdirAll(filepath.Join(krb5CCDir, "tracking"), 0700)
+				require.NoError(t, err, "Setup: failed to create ticket tracking directory")
+				err = os.Symlink(tc.userKrb5CCName, filepath.Join(krb5CCDir, "tracking", "ubuntu"))
+				require.NoError(t, err, "Setup: failed to create user ticket symlink")
+			}
+			dfsResolverFails := tc.dfsResolverFails
+			opts := []mount.Option{
+				mount.WithKrb5CCDir(krb5CCDir),
+				mount.WithStateDir(filepath.Join(rootDir, "var", "lib", "adsys")),
+				mount.WithDFSResolver(func(_ context.Context, host string) (bool, error) {
+					if dfsResolverFails {
+						return false, errors.New("mock DFS resolver error")
+					}
+					return slices.Contains(tc.dfsHosts, host), nil
+				}),
+			}
 			if !tc.isComputer && tc.objectName == "" {
 				if tc.userReturnedUID == "" {
 					tc.userReturnedUID = u.Uid
@@ -253,6 +297,7 @@ func TestApplyPolicy(t *testing.T) {
 				}
 				// #nosec G601: This is fixed with Go 1.22.0 and is a false positive (https://github.com/securego/gosec/pull/1108)
 				m.SetSystemdCaller(&tc.secondMockSystemdCaller)
+				dfsResolverFails = tc.dfsResolverFailsSecondCall
 
 				if tc.pathAlreadyExistsSecondCall {
 					p := filepath.Join(systemUnitDir, "adsys-protocol-domain.com-mountpath.mount")
@@ -277,6 +322,56 @@ func TestApplyPolicy(t *testing.T) {
 	}
 }
 
+func TestSystemMountUnitsCleanup(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		legacyUnits  bool
+		cleanupCalls int
+	}{
+		"Units are removed once the policy is removed":   {cleanupCalls: 1},
+		"Removing the policy multiple times is a no-op":  {cleanupCalls: 3},
+		"Untracked units generated by adsys are removed": {legacyUnits: true, cleanupCalls: 1},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rootDir := t.TempDir()
+			runDir := filepath.Join(rootDir, "run", "adsys")
+			stateDir := filepath.Join(rootDir, "var", "lib", "adsys")
+			systemUnitDir := filepath.Join(rootDir, "etc", "systemd", "system")
+
+			// Units created by other tools, even with our prefix, must survive.
+			testutils.CreatePath(t, filepath.Join(systemUnitDir, "foreign.mount"))
+			require.NoError(t, os.WriteFile(filepath.Join(systemUnitDir, "adsys-foreign.mount"), []byte("[Mount]\nWhat=/dev/foreign\n"), 0600),
+				"Setup: failed to create foreign unit")
+
+			m, err := mount.New(runDir, systemUnitDir, &mockSystemdCaller{failOn: start}, mount.WithStateDir(stateDir),
+				mount.WithDFSResolver(func(context.Context, string) (bool, error) { return false, nil }))
+			require.NoError(t, err, "Setup: Failed to create manager for the tests.")
+
+			e := mount.EntriesForTests["entry with multiple values"]
+			e.Key = "system-mounts"
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, []entry.Entry{e})
+			require.NoError(t, err, "Setup: ApplyPolicy should not have returned an error but did")
+
+			if tc.legacyUnits {
+				// Units generated before being tracked.
+				require.NoError(t, os.RemoveAll(stateDir), "Setup: failed to remove tracked units")
+			}
+
+			for i := 0; i < tc.cleanupCalls; i++ {
+				err = m.ApplyPolicy(context.Background(), "ubuntu", true, nil)
+				require.NoError(t, err, "ApplyPolicy should not have returned an error on removing the policy but did")
+			}
+
+			testutils.CompareTreesWithFiltering(t, rootDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
+
 // makeIndependentOfCurrentUID renames any file or directory which exactly match uid in path and replace it with 4242.
 func makeIndependentOfCurrentUID(t *testing.T, path string, uid string) {
 	t.Helper()
@@ -0,0 +1,68 @@
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+// maxRetryDelay caps the exponential backoff between two attempts to mount the failed locations.
+const maxRetryDelay = time.Minute
+
+// mounter mounts entries and returns the error of each of them, in the same order. Successful mounts have a nil error.
+type mounter func(ctx context.Context, entries []mountEntry) []error
+
+// permanentError is a mount failure which would fail the same way if retried, like with bad credentials.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// mountWithRetries mounts entries with mount, retrying up to retries times the ones failing with a transient error.
+// The delay before the first retry is delay and doubles on each retry.
+// It returns the errors of the mounts which failed once permanently or once all the retries are exhausted.
+func mountWithRetries(ctx context.Context, entries []mountEntry, mount mounter, retries int, delay time.Duration) (err error) {
+	for attempt := 0; ; attempt++ {
+		errs := mount(ctx, entries)
+
+		var failed []mountEntry
+		for i, e := range entries {
+			if errs[i] == nil {
+				log.Debugf(ctx, "Successfully mounted %q", e.path)
+				continue
+			}
+
+			if errors.As(errs[i], &permanentError{}) || attempt >= retries {
+				log.Debugf(ctx, "Failed to mount %q: %v", e.path, errs[i])
+				err = errors.Join(err, fmt.Errorf("failed to mount %q: %w", e.path, errs[i]))
+				continue
+			}
+			log.Infof(ctx, "Failed to mount %q, retrying in %s: %v", e.path, delay, errs[i])
+			failed = append(failed, e)
+		}
+		if len(failed) == 0 {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			for _, e := range failed {
+				err = errors.Join(err, fmt.Errorf("failed to mount %q: %w", e.path, ctx.Err()))
+			}
+			return err
+		}
+		delay = min(2*delay, maxRetryDelay)
+		entries = failed
+	}
+}
@@ -6,14 +6,20 @@ package policies
 
 import (
 	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/leonelquinteros/gotext"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
@@ -25,11 +31,18 @@ import (
 
 const (
 	// PoliciesCacheBaseName is the base directory where we want to cache policies.
-	PoliciesCacheBaseName  = "policies"
+	PoliciesCacheBaseName = "policies"
+	// SnapshotsCacheBaseName is the base directory where we store imported policies snapshots.
+	SnapshotsCacheBaseName = "snapshots"
 	policiesFileName       = "policies"
 	policiesAssetsFileName = "assets.db"
+	// policiesChecksumsFileName lists the checksums of the other cache files, in sha256sum format.
+	policiesChecksumsFileName = "checksums"
 )
 
+// ErrCorruptedCache is returned when the cached policies don't match their recorded checksums.
+var ErrCorruptedCache = errors.New(gotext.Get("policies cache is corrupted"))
+
 type assetsFromMMAP struct {
 	*zip.Reader
 	filemmap   *mmap.ReaderAt
@@ -38,8 +51,11 @@ type assetsFromMMAP struct {
 
 // Policies is the list of GPOs applied to a particular object, with the global data cache.
 type Policies struct {
-	GPOs   []GPO
-	assets *assetsFromMMAP `yaml:"-"`
+	GPOs []GPO
+	// FetchedAt is when the GPOs were fetched from the directory service, if they were. It is kept in the cache, so
+	// that policies applied from the cache keep the time of their last online update.
+	FetchedAt time.Time       `yaml:",omitempty"`
+	assets    *assetsFromMMAP `yaml:"-"`
 }
 
 // New returns new policies with GPOs and assets loaded from DB.
@@ -70,6 +86,10 @@ func NewFromCache(ctx context.Context, p string) (pols Policies, err error) {
 
 	log.Debugf(ctx, "Loading policies from cache using %s", p)
 
+	if err := VerifyCache(ctx, p); err != nil {
+		return pols, err
+	}
+
 	d, err := os.ReadFile(filepath.Join(p, policiesFileName))
 	if err != nil {
 		return pols, err
@@ -94,6 +114,81 @@ func NewFromCache(ctx context.Context, p string) (pols Policies, err error) {
 	return pols, nil
 }
 
+// VerifyCache checks the policies cached in p against their recorded checksums.
+// A corrupted cache is discarded and ErrCorruptedCache is returned, so that policies are fetched again instead of
+// applying corrupted data. Absent caches, or caches saved before checksums were recorded, are not checked.
+func VerifyCache(ctx context.Context, p string) (err error) {
+	d, err := os.ReadFile(filepath.Join(p, policiesChecksumsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := verifyChecksums(p, d); err != nil {
+		log.Warningf(ctx, "Discarding policies cache %s: %v", p, err)
+		for _, f := range []string{policiesChecksumsFileName, policiesFileName, policiesAssetsFileName} {
+			if err := os.Remove(filepath.Join(p, f)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return fmt.Errorf("%w: %v", ErrCorruptedCache, err)
+	}
+
+	return nil
+}
+
+// verifyChecksums checks that the files in p match the checksums listed in d.
+// The policies file is always listed, and the assets are only listed if they are cached.
+func verifyChecksums(p string, d []byte) error {
+	want := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(d))
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			return errors.New(gotext.Get("invalid checksum line %q", scanner.Text()))
+		}
+		want[name] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if _, ok := want[policiesFileName]; !ok {
+		return errors.New(gotext.Get("no checksum for %s", policiesFileName))
+	}
+
+	for _, name := range []string{policiesFileName, policiesAssetsFileName} {
+		sum, err := fileChecksum(filepath.Join(p, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			if _, ok := want[name]; ok {
+				return errors.New(gotext.Get("%s is missing", name))
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		if want[name] != sum {
+			return errors.New(gotext.Get("checksum mismatch for %s", name))
+		}
+	}
+	return nil
+}
+
+// fileChecksum returns the hex encoded sha256 checksum of the file at p.
+func fileChecksum(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // openAssetsInMemory opens assetsDB into memory.
 // It’s up to the caller to close the opened file.
 func openAssetsInMemory(assetsDB string) (assets *assetsFromMMAP, err error) {
@@ -118,6 +213,8 @@ func openAssetsInMemory(assetsDB string) (assets *assetsFromMMAP, err error) {
 
 // Save serializes in p policies.
 // Do not save again if p is already the origin. We don’t allow modifying GPOs or assets on the object.
+// Each file is written atomically, and the checksums of the cache are recorded last: an interrupted save is detected
+// as a corrupted cache on next load.
 func (pols *Policies) Save(p string) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't save policies to %s", p))
 
@@ -130,14 +227,27 @@ func (pols *Policies) Save(p string) (err error) {
 	if err != nil {
 		return err
 	}
-	if err := os.WriteFile(filepath.Join(p, policiesFileName), d, 0600); err != nil {
+	policiesPath := filepath.Join(p, policiesFileName)
+	if err := os.WriteFile(policiesPath+".new", d, 0600); err != nil {
 		return err
 	}
+	if err := os.Rename(policiesPath+".new", policiesPath); err != nil {
+		return err
+	}
+
+	if err := pols.saveAssets(p); err != nil {
+		return err
+	}
+
+	return saveChecksums(p)
+}
 
+// saveAssets saves the assets of the policies in p, or removes them from p if there are none.
+func (pols *Policies) saveAssets(p string) (err error) {
 	assetPath := filepath.Join(p, policiesAssetsFileName)
 	if pols.assets == nil {
 		// delete assetPath and ignore if it doesn't exist
-		if err := os.Remove(assetPath); !errors.Is(err, fs.ErrNotExist) {
+		if err := os.Remove(assetPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return err
 		}
 		return nil
@@ -184,6 +294,26 @@ func (pols *Policies) Save(p string) (err error) {
 	return nil
 }
 
+// saveChecksums records the checksums of the policies and assets cached in p.
+func saveChecksums(p string) error {
+	var checksums strings.Builder
+	for _, name := range []string{policiesFileName, policiesAssetsFileName} {
+		sum, err := fileChecksum(filepath.Join(p, name))
+		if errors.Is(err, fs.ErrNotExist) && name == policiesAssetsFileName {
+			continue
+		} else if err != nil {
+			return err
+		}
+		fmt.Fprintf(&checksums, "%s  %s\n", sum, name)
+	}
+
+	checksumsPath := filepath.Join(p, policiesChecksumsFileName)
+	if err := os.WriteFile(checksumsPath+".new", []byte(checksums.String()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(checksumsPath+".new", checksumsPath)
+}
+
 // Close closes underlying mmaped file.
 func (pols *Policies) Close() (err error) {
 	if pols.assets == nil {
@@ -362,21 +492,62 @@ func CompressAssets(ctx context.Context, p string) (err error) {
 	return err
 }
 
+// Source is a GPO a resolved entry comes from.
+type Source struct {
+	ID   string
+	Name string
+}
+
+// ResolvedEntry is an entry resolved between the GPOs, along with the GPOs it comes from, closest first.
+// Only entries with an append strategy come from multiple GPOs.
+type ResolvedEntry struct {
+	entry.Entry
+	Sources []Source
+}
+
 // GetUniqueRules return order rules, with one entry per key for a given type.
 // Returned file is a map of type to its entries.
 func (pols Policies) GetUniqueRules() map[string][]entry.Entry {
 	r := make(map[string][]entry.Entry)
+	for t, resolved := range pols.ResolveRules() {
+		var entries []entry.Entry
+		for _, e := range resolved {
+			entries = append(entries, e.Entry)
+		}
+		r[t] = entries
+	}
+	return r
+}
+
+// ResolveRules return order rules, with one entry per key for a given type and the GPOs setting it.
+// Returned file is a map of type to its entries.
+// Keys deleted by a GPO are not set by the ones with lower precedence.
+func (pols Policies) ResolveRules() map[string][]ResolvedEntry {
+	r := make(map[string][]ResolvedEntry)
 	keys := make(map[string][]string)
 
 	// Dedup entries, first GPO wins for a given type + key
-	dedup := make(map[string]map[string]entry.Entry)
+	dedup := make(map[string]map[string]ResolvedEntry)
 	seen := make(map[string]struct{})
+	deleted := make(map[string]struct{})
 	for _, gpo := range pols.GPOs {
+		source := Source{ID: gpo.ID, Name: gpo.Name}
+		// Deletions only apply to the next GPOs, the GPO can set the key again.
+		var gpoDeleted []string
 		for t, entries := range gpo.Rules {
 			if dedup[t] == nil {
-				dedup[t] = make(map[string]entry.Entry)
+				dedup[t] = make(map[string]ResolvedEntry)
 			}
 			for _, e := range entries {
+				if e.Delete {
+					gpoDeleted = append(gpoDeleted, t+e.Key)
+					continue
+				}
+				if _, ok := deleted[t+e.Key]; ok {
+					continue
+				}
+
+				sources := []Source{source}
 				switch e.Strategy {
 				case entry.StrategyAppend:
 					// We skip disabled keys as we only append enabled one.
@@ -387,15 +558,18 @@ func (pols Policies) GetUniqueRules() map[string][]entry.Entry {
 					// If there is an existing value, prepend new value to it. We are analyzing GPOs in reverse order (closest first).
 					if _, exists := seen[t+e.Key]; exists {
 						keyAlreadySeen = true
+						closest := dedup[t][e.Key]
 						// We have seen a closest key which is an override. We don’t append furthest append values.
-						if dedup[t][e.Key].Strategy != entry.StrategyAppend {
+						if closest.Strategy != entry.StrategyAppend {
 							continue
 						}
-						e.Value = e.Value + "\n" + dedup[t][e.Key].Value
-						// Keep closest meta value.
-						e.Meta = dedup[t][e.Key].Meta
+						e.Value = e.Value + "\n" + closest.Value
+						// Keep closest meta and lock values.
+						e.Meta = closest.Meta
+						e.Lock = closest.Lock
+						sources = append(closest.Sources, source)
 					}
-					dedup[t][e.Key] = e
+					dedup[t][e.Key] = ResolvedEntry{Entry: e, Sources: sources}
 					if keyAlreadySeen {
 						continue
 					}
@@ -405,18 +579,21 @@ func (pols Policies) GetUniqueRules() map[string][]entry.Entry {
 					if _, exists := seen[t+e.Key]; exists {
 						continue
 					}
-					dedup[t][e.Key] = e
+					dedup[t][e.Key] = ResolvedEntry{Entry: e, Sources: sources}
 				}
 
 				keys[t] = append(keys[t], e.Key)
 				seen[t+e.Key] = struct{}{}
 			}
 		}
+		for _, k := range gpoDeleted {
+			deleted[k] = struct{}{}
+		}
 	}
 
 	// For each t, order entries by ascii order
 	for t := range dedup {
-		var entries []entry.Entry
+		var entries []ResolvedEntry
 		sort.Strings(keys[t])
 		for _, k := range keys[t] {
 			entries = append(entries, dedup[t][k])
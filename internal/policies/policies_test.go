@@ -130,6 +130,101 @@ func TestNewFromCache(t *testing.T) {
 	}
 }
 
+func TestNewFromCacheCorrupted(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cacheSrc string
+		corrupt  func(t *testing.T, p string)
+
+		wantErr bool
+	}{
+		"Cache with matching checksums is loaded": {cacheSrc: "with_assets"},
+		"Cache without checksums is loaded": {
+			cacheSrc: "with_assets",
+			corrupt: func(t *testing.T, p string) {
+				t.Helper()
+				require.NoError(t, os.Remove(filepath.Join(p, policies.PoliciesChecksumsFileName)), "Setup: can't remove checksums")
+			},
+		},
+
+		// Error cases
+		"Error on modified policies": {
+			cacheSrc: "with_assets",
+			corrupt: func(t *testing.T, p string) {
+				t.Helper()
+				f, err := os.OpenFile(filepath.Join(p, policies.PoliciesFileName), os.O_APPEND|os.O_WRONLY, 0600)
+				require.NoError(t, err, "Setup: can't open policies")
+				defer f.Close()
+				_, err = f.WriteString("- id: '{GPOId}'\n")
+				require.NoError(t, err, "Setup: can't modify policies")
+			},
+			wantErr: true,
+		},
+		"Error on truncated assets": {
+			cacheSrc: "with_assets",
+			corrupt: func(t *testing.T, p string) {
+				t.Helper()
+				require.NoError(t, os.Truncate(filepath.Join(p, policies.PoliciesAssetsFileName), 10), "Setup: can't truncate assets")
+			},
+			wantErr: true,
+		},
+		"Error on missing assets": {
+			cacheSrc: "with_assets",
+			corrupt: func(t *testing.T, p string) {
+				t.Helper()
+				require.NoError(t, os.Remove(filepath.Join(p, policies.PoliciesAssetsFileName)), "Setup: can't remove assets")
+			},
+			wantErr: true,
+		},
+		"Error on unexpected assets": {
+			cacheSrc: "simple",
+			corrupt: func(t *testing.T, p string) {
+				t.Helper()
+				testutils.Copy(t, filepath.Join("testdata", "cache", "policies", "with_assets", policies.PoliciesAssetsFileName), filepath.Join(p, policies.PoliciesAssetsFileName))
+			},
+			wantErr: true,
+		},
+		"Error on invalid checksums": {
+			cacheSrc: "simple",
+			corrupt: func(t *testing.T, p string) {
+				t.Helper()
+				require.NoError(t, os.WriteFile(filepath.Join(p, policies.PoliciesChecksumsFileName), []byte("garbage\n"), 0600), "Setup: can't corrupt checksums")
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", tc.cacheSrc))
+			require.NoError(t, err, "Setup: NewFromCache should return no error but got one")
+			defer src.Close()
+
+			p := t.TempDir()
+			require.NoError(t, src.Save(p), "Setup: Save should return no error but got one")
+			if tc.corrupt != nil {
+				tc.corrupt(t, p)
+			}
+
+			got, err := policies.NewFromCache(context.Background(), p)
+			if tc.wantErr {
+				require.ErrorIs(t, err, policies.ErrCorruptedCache, "NewFromCache should reject the corrupted cache")
+				for _, f := range []string{policies.PoliciesFileName, policies.PoliciesAssetsFileName, policies.PoliciesChecksumsFileName} {
+					require.NoFileExists(t, filepath.Join(p, f), "Corrupted cache should be discarded")
+				}
+				return
+			}
+			require.NoError(t, err, "NewFromCache should return no error but got one")
+			defer got.Close()
+
+			require.Equal(t, src.GPOs, got.GPOs, "NewFromCache should load the saved GPOs")
+		})
+	}
+}
+
 func TestSave(t *testing.T) {
 	t.Parallel()
 
@@ -282,6 +377,7 @@ func TestCachePolicies(t *testing.T) {
 					{Key: "C", Value: "standardC"},
 				}}},
 		},
+		FetchedAt: time.Date(2024, time.May, 1, 10, 0, 0, 0, time.UTC),
 	}
 
 	p := filepath.Join(t.TempDir(), "policies-cache")
@@ -869,6 +965,81 @@ func TestGetUniqueRules(t *testing.T) {
 					{Key: "A", Value: "closest value", Strategy: entry.StrategyAppend},
 				},
 			}},
+
+		// Delete cases
+		"Deleted key is removed from furthest GPOs": {
+			gpos: []policies.GPO{
+				{ID: "closest", Name: "closest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Delete: true},
+						{Key: "B", Value: "closest B"},
+					}}},
+				{ID: "furthest", Name: "furthest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "furthest A"},
+						{Key: "B", Value: "furthest B"},
+						{Key: "C", Value: "furthest C"},
+					}}},
+			},
+			want: map[string][]entry.Entry{
+				"domain": {
+					{Key: "B", Value: "closest B"},
+					{Key: "C", Value: "furthest C"},
+				},
+			}},
+		"Deleted key can be set again by the same GPO": {
+			gpos: []policies.GPO{
+				{ID: "closest", Name: "closest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Delete: true},
+						{Key: "A", Value: "closest A"},
+					}}},
+				{ID: "furthest", Name: "furthest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "furthest A"},
+					}}},
+			},
+			want: map[string][]entry.Entry{
+				"domain": {
+					{Key: "A", Value: "closest A"},
+				},
+			}},
+		"Deleted key does not remove the value of closest GPOs": {
+			gpos: []policies.GPO{
+				{ID: "closest", Name: "closest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "closest A"},
+					}}},
+				{ID: "furthest", Name: "furthest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Delete: true},
+					}}},
+			},
+			want: map[string][]entry.Entry{
+				"domain": {
+					{Key: "A", Value: "closest A"},
+				},
+			}},
+		"Deleted key stops appending furthest values": {
+			gpos: []policies.GPO{
+				{ID: "closest", Name: "closest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "closest value", Strategy: entry.StrategyAppend},
+					}}},
+				{ID: "middle", Name: "middle-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Delete: true},
+					}}},
+				{ID: "furthest", Name: "furthest-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Value: "furthest value", Strategy: entry.StrategyAppend},
+					}}},
+			},
+			want: map[string][]entry.Entry{
+				"domain": {
+					{Key: "A", Value: "closest value", Strategy: entry.StrategyAppend},
+				},
+			}},
 	}
 
 	for name, tc := range tests {
@@ -884,6 +1055,75 @@ func TestGetUniqueRules(t *testing.T) {
 	}
 }
 
+func TestResolveRules(t *testing.T) {
+	t.Parallel()
+
+	enforcedGPO := policies.GPO{ID: "enforced", Name: "enforced-name", Enforced: true, Rules: map[string][]entry.Entry{
+		"domain": {
+			{Key: "A", Value: "enforcedA"},
+			{Key: "B", Value: "enforcedB", Strategy: entry.StrategyAppend},
+		}}}
+	standardGPO := policies.GPO{ID: "standard", Name: "standard-name", Rules: map[string][]entry.Entry{
+		"domain": {
+			{Key: "A", Value: "standardA"},
+			{Key: "B", Value: "standardB", Strategy: entry.StrategyAppend},
+			{Key: "C", Value: "standardC"},
+		}}}
+	enforced := policies.Source{ID: "enforced", Name: "enforced-name"}
+	standard := policies.Source{ID: "standard", Name: "standard-name"}
+
+	tests := map[string]struct {
+		gpos []policies.GPO
+
+		want map[string][]policies.ResolvedEntry
+	}{
+		"One GPO": {
+			gpos: []policies.GPO{standardGPO},
+			want: map[string][]policies.ResolvedEntry{
+				"domain": {
+					{Entry: entry.Entry{Key: "A", Value: "standardA"}, Sources: []policies.Source{standard}},
+					{Entry: entry.Entry{Key: "B", Value: "standardB", Strategy: entry.StrategyAppend}, Sources: []policies.Source{standard}},
+					{Entry: entry.Entry{Key: "C", Value: "standardC"}, Sources: []policies.Source{standard}},
+				},
+			}},
+		"Enforced GPO wins over the closest one": {
+			gpos: []policies.GPO{enforcedGPO, standardGPO},
+			want: map[string][]policies.ResolvedEntry{
+				"domain": {
+					{Entry: entry.Entry{Key: "A", Value: "enforcedA"}, Sources: []policies.Source{enforced}},
+					{Entry: entry.Entry{Key: "B", Value: "standardB\nenforcedB", Strategy: entry.StrategyAppend}, Sources: []policies.Source{enforced, standard}},
+					{Entry: entry.Entry{Key: "C", Value: "standardC"}, Sources: []policies.Source{standard}},
+				},
+			}},
+		"Deleted key has no source": {
+			gpos: []policies.GPO{
+				{ID: "deleting", Name: "deleting-name", Rules: map[string][]entry.Entry{
+					"domain": {
+						{Key: "A", Delete: true},
+					}}},
+				standardGPO,
+			},
+			want: map[string][]policies.ResolvedEntry{
+				"domain": {
+					{Entry: entry.Entry{Key: "B", Value: "standardB", Strategy: entry.StrategyAppend}, Sources: []policies.Source{standard}},
+					{Entry: entry.Entry{Key: "C", Value: "standardC"}, Sources: []policies.Source{standard}},
+				},
+			}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pols := policies.Policies{
+				GPOs: tc.gpos,
+			}
+			got := pols.ResolveRules()
+			require.Equal(t, tc.want, got, "ResolveRules returns expected policy entries with their sources")
+		})
+	}
+}
+
 // equalPoliciesToGolden compares the policies to the given file.
 func equalPoliciesToGolden(t *testing.T, got policies.Policies, golden string, update bool) {
 	t.Helper()
@@ -892,6 +1132,9 @@ func equalPoliciesToGolden(t *testing.T, got policies.Policies, golden string, u
 	compareDir := t.TempDir()
 	err := got.Save(compareDir)
 	require.NoError(t, err, "Teardown: saving gpo should work")
+	// Checksums are covered by TestSave and depend on the assets database.
+	err = os.Remove(filepath.Join(compareDir, policies.PoliciesChecksumsFileName))
+	require.NoError(t, err, "Teardown: cleaning up checksums file")
 	if got.HasAssets() {
 		err = got.SaveAssetsTo(context.Background(), ".", filepath.Join(compareDir, "assets.db.uncompressed"), -1, -1)
 		require.NoError(t, err, "Teardown: deserializing assets should work")
@@ -0,0 +1,10 @@
+package privilege
+
+import "time"
+
+// WithClock overrides the clock used to check the client administrators time windows.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
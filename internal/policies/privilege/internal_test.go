@@ -14,7 +14,8 @@ func TestSplitAndNormalizeUsersAndGroups(t *testing.T) {
 	tests := map[string]struct {
 		input string
 
-		want []string
+		want    []string
+		wantErr bool
 	}{
 		// string cases
 		"Simple one value":                            {input: "user@domain", want: []string{"user@domain"}},
@@ -50,13 +51,35 @@ func TestSplitAndNormalizeUsersAndGroups(t *testing.T) {
 		"Strip any *":                    {input: `u*s*er@domain`, want: []string{`user@domain`}},
 		"Strip any %":                    {input: `u%s%er@domain`, want: []string{`user@domain`}},
 		"Don’t strip first % but others": {input: `%g%r%oup@domain`, want: []string{`%group@domain`}},
+
+		// netgroups
+		"Netgroup one value":                        {input: "+netgroup", want: []string{"+netgroup"}},
+		"Netgroup with surrounding spaces":          {input: " +netgroup ", want: []string{"+netgroup"}},
+		"Netgroup keeps characters forbidden in AD": {input: `+net:gr=oup!`, want: []string{`+net:gr=oup!`}},
+		"Netgroup does not convert domain":          {input: `+domain\netgroup`, want: []string{`+domain\netgroup`}},
+		"Mixed users, groups and netgroups":         {input: "user@domain,%group@domain\n+netgroup", want: []string{"user@domain", "%group@domain", "+netgroup"}},
+		"Plus character inside a user name is kept": {input: "us+er@domain", want: []string{"us+er@domain"}},
+
+		// error cases
+		"Error on empty netgroup name":             {input: "user@domain,+", wantErr: true},
+		"Error on netgroup name with space":        {input: "+net group", wantErr: true},
+		"Error on netgroup name with parenthesis":  {input: "+net(group)", wantErr: true},
+		"Error on netgroup name with double quote": {input: `+net"group`, wantErr: true},
+		"Error on netgroup name with group prefix": {input: "+%netgroup", wantErr: true},
+		"Error on netgroup name with semicolon":    {input: "+net;group", wantErr: true},
+		"Error on nested netgroup prefix":          {input: "++netgroup", wantErr: true},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			got := splitAndNormalizeUsersAndGroups(context.Background(), tc.input)
+			got, err := splitAndNormalizeUsersAndGroups(context.Background(), tc.input)
+			if tc.wantErr {
+				require.Error(t, err, "splitAndNormalizeUsersAndGroups should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "splitAndNormalizeUsersAndGroups failed but shouldn't have")
 			assert.Equal(t, tc.want, got, "splitAndNormalizeUsersAndGroups returned expected value")
 		})
 	}
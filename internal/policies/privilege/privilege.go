@@ -6,29 +6,55 @@
 //   - /etc/sudoers.d/99-adsys-privilege-enforcement
 //   - /etc/polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement
 //
+// If polkit actions are configured, client administrators are also granted those actions, authenticating
+// as themselves, by a polkit rules file:
+//   - /etc/polkit-1/rules.d/99-adsys-privilege-enforcement.rules
+//
 // This is an all or nothing type of policy and, therefore, requires a lot of attention during setup.
 // If the policy is setup improperly, users could end up with too much (or too little) privilege,
 // which could compromise the safety and/or usability of the machine until the policy gets updated.
 // If the policy is set without any value (or it's disabled) the files are removed and the default
 // privilege configuration is restored.
+// When local administrators are suppressed, the suppression is recorded in the state directory, so that it can
+// be reported while suppressed. Removing our files is enough to allow them again.
 // Should the manager fail to create the files with the requested values, it will return an error and
 // authentication will be prevented.
+// The generated sudoers file is checked with visudo before being installed: if it is invalid, the previous
+// sudoers and polkit files are kept and an error is returned.
+//
+// Client administrators can be restricted to a list of commands, one per line, each one being an absolute
+// path optionally followed by its arguments. They are then granted those commands only through a sudoers
+// command alias, instead of any command, and are not polkit administrators.
+//
+// Client administrators can also be restricted to time windows, one per line, like "Mon-Fri 08:00-18:00", in
+// local time. Outside of those windows, they are not granted anything. A systemd timer refreshes the privilege
+// policy at the boundaries of the windows, so that they are granted and revoked on time:
+//   - /etc/systemd/system/adsys-privilege-time-window.timer
 package privilege
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/audit"
 	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/fileutils"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/smbsafe"
 	"github.com/ubuntu/decorate"
 	"gopkg.in/ini.v1"
 )
@@ -50,17 +76,93 @@ import (
 
 const adsysBaseConfName = "99-adsys-privilege-enforcement"
 
+// clientAdminsCmndAlias is the sudoers command alias listing the commands client administrators are restricted to.
+const clientAdminsCmndAlias = "ADSYS_CLIENT_ADMINS_CMNDS"
+
+const (
+	// sudoersWordSpecialChars must be escaped with a backslash in sudoers words, like user or netgroup names.
+	sudoersWordSpecialChars = `\!=:,()`
+	// sudoersCommandSpecialChars must be escaped with a backslash in sudoers commands and their arguments.
+	sudoersCommandSpecialChars = `\,:=`
+)
+
 // Manager prevents running multiple privilege update process in parallel while parsing policy in ApplyPolicy.
 type Manager struct {
-	sudoersDir   string
-	policyKitDir string
+	sudoersDir    string
+	policyKitDir  string
+	stateDir      string
+	visudoCmd     []string
+	systemUnitDir string
+	systemdCaller systemdCaller
+	now           func() time.Time
+}
+
+// systemdCaller is the interface to interact with systemd.
+type systemdCaller interface {
+	StartUnit(context.Context, string) error
+	StopUnit(context.Context, string) error
+	EnableUnit(context.Context, string) error
+	DisableUnit(context.Context, string) error
+	DaemonReload(context.Context) error
+}
+
+type options struct {
+	stateDir      string
+	visudoCmd     []string
+	systemUnitDir string
+	systemdCaller systemdCaller
+	now           func() time.Time
+}
+
+// Option represents an optional function to change the privilege manager.
+type Option func(*options)
+
+// WithVisudoCmd overrides the default visudo command used to validate the generated sudoers file.
+func WithVisudoCmd(cmd []string) Option {
+	return func(o *options) {
+		o.visudoCmd = cmd
+	}
+}
+
+// WithStateDir overrides the default state directory, where the local administrators state is saved.
+func WithStateDir(dir string) Option {
+	return func(o *options) {
+		o.stateDir = dir
+	}
+}
+
+// WithSystemUnitDir overrides the default systemd system unit directory, where the time windows timer is installed.
+func WithSystemUnitDir(dir string) Option {
+	return func(o *options) {
+		o.systemUnitDir = dir
+	}
+}
+
+// WithSystemdCaller sets the systemd caller used to schedule the client administrators time windows.
+// Without it, time windows are only evaluated on policy refresh.
+func WithSystemdCaller(c systemdCaller) Option {
+	return func(o *options) {
+		o.systemdCaller = c
+	}
 }
 
 // NewWithDirs creates a manager with a specific root directory.
-func NewWithDirs(sudoersDir, policyKitDir string) *Manager {
+func NewWithDirs(sudoersDir, policyKitDir string, opts ...Option) *Manager {
+	args := options{
+		now: time.Now,
+	}
+	for _, o := range opts {
+		o(&args)
+	}
+
 	return &Manager{
-		sudoersDir:   sudoersDir,
-		policyKitDir: policyKitDir,
+		sudoersDir:    sudoersDir,
+		policyKitDir:  policyKitDir,
+		stateDir:      args.stateDir,
+		visudoCmd:     args.visudoCmd,
+		systemUnitDir: args.systemUnitDir,
+		systemdCaller: args.systemdCaller,
+		now:           args.now,
 	}
 }
 
@@ -83,6 +185,7 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	}
 	sudoersConf := filepath.Join(sudoersDir, adsysBaseConfName)
 	policyKitConf := filepath.Join(policyKitDir, "localauthority.conf.d", adsysBaseConfName+".conf")
+	policyKitRules := filepath.Join(policyKitDir, "rules.d", adsysBaseConfName+".rules")
 
 	log.Debugf(ctx, "Applying privilege policy to %s", objectName)
 
@@ -94,10 +197,61 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		if err := os.Remove(policyKitConf); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return err
 		}
-		return nil
+		if err := os.Remove(policyKitRules); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := m.removeTimeWindows(ctx); err != nil {
+			return err
+		}
+		return m.removeSuppression(ctx, policyKitDir)
+	}
+
+	// Restricted commands apply to all client administrators, whatever the order of entries.
+	// So do polkit actions and time windows.
+	var clientAdminsCmnds, polkitActions []string
+	var timeWindows []timeWindow
+	var hasClientAdmins bool
+	for _, e := range entries {
+		if e.Disabled {
+			continue
+		}
+		switch e.Key {
+		case "client-admins":
+			hasClientAdmins = true
+		case "client-admins-time-window":
+			if timeWindows, err = parseTimeWindows(e.Value); err != nil {
+				return err
+			}
+		case "client-admins-commands":
+			if clientAdminsCmnds, err = splitAndNormalizeCommands(e.Value); err != nil {
+				return err
+			}
+		case "client-admins-polkit-actions":
+			if polkitActions, err = splitAndCheckPolkitActions(e.Value); err != nil {
+				return err
+			}
+		}
+	}
+	// Client administrators are not granted anything outside of their time windows.
+	inTimeWindow := true
+	if len(timeWindows) > 0 && hasClientAdmins {
+		if inTimeWindow = inTimeWindows(timeWindows, m.now()); !inTimeWindow {
+			log.Infof(ctx, "Outside of client administrators time windows: client administrators are not granted")
+		}
 	}
 
 	// Create our temp files and parent directories
+	// Never leave temp files behind on failure: the previous files are kept as is.
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, p := range []string{sudoersConf + ".new", policyKitConf + ".new", policyKitRules + ".new"} {
+			if errRemove := os.Remove(p); errRemove != nil && !errors.Is(errRemove, fs.ErrNotExist) {
+				log.Warningf(ctx, "Can't remove temporary file %s: %v", p, errRemove)
+			}
+		}
+	}()
 	// nolint:gosec // G301 match distribution permission
 	if err := os.MkdirAll(filepath.Dir(sudoersConf), 0755); err != nil {
 		return err
@@ -108,6 +262,10 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		return err
 	}
 	defer sudoersF.Close()
+	// sudo ignores sudoers files writable by others: enforce permissions even on a leftover file.
+	if err := sudoersF.Chmod(0440); err != nil {
+		return err
+	}
 	// nolint:gosec // G301 match distribution permission
 	if err := os.MkdirAll(filepath.Dir(policyKitConf), 0755); err != nil {
 		return err
@@ -133,7 +291,7 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 `
 
 	allowLocalAdmins := true
-	var polkitAdditionalUsersGroups []string
+	var polkitAdditionalUsersGroups, clientAdmins []string
 
 	for _, entry := range entries {
 		var contentSudo string
@@ -151,23 +309,48 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 			contentSudo += "%admin	ALL=(ALL) !ALL\n"
 			contentSudo += "%sudo	ALL=(ALL:ALL) !ALL\n"
 		case "client-admins":
-			if entry.Disabled {
+			if entry.Disabled || !inTimeWindow {
 				continue
 			}
 
+			elems, err := splitAndNormalizeUsersAndGroups(ctx, entry.Value)
+			if err != nil {
+				return err
+			}
+			clientAdmins = elems
+			// Restricted administrators can only run the allowed commands, and are not polkit administrators.
+			cmnds := "ALL"
+			if len(clientAdminsCmnds) > 0 && len(elems) > 0 {
+				cmnds = clientAdminsCmndAlias
+				contentSudo += fmt.Sprintf("Cmnd_Alias %s = %s\n", clientAdminsCmndAlias, strings.Join(clientAdminsCmnds, ", "))
+			}
+
 			var polkitElem []string
-			for _, e := range splitAndNormalizeUsersAndGroups(ctx, entry.Value) {
-				contentSudo += fmt.Sprintf("\"%s\"	ALL=(ALL:ALL) ALL\n", e)
-				polkitID := fmt.Sprintf("unix-user:%s", e)
-				if strings.HasPrefix(e, "%") {
+			for _, e := range elems {
+				var polkitID string
+				switch {
+				case strings.HasPrefix(e, "+"):
+					netgroup := strings.TrimPrefix(e, "+")
+					contentSudo += fmt.Sprintf("+%s	ALL=(ALL:ALL) %s\n", escapeSudoers(netgroup, sudoersWordSpecialChars), cmnds)
+					polkitID = fmt.Sprintf("unix-netgroup:%s", netgroup)
+				case strings.HasPrefix(e, "%"):
+					contentSudo += fmt.Sprintf("\"%s\"	ALL=(ALL:ALL) %s\n", e, cmnds)
 					polkitID = fmt.Sprintf("unix-group:%s", strings.TrimPrefix(e, "%"))
+				default:
+					contentSudo += fmt.Sprintf("\"%s\"	ALL=(ALL:ALL) %s\n", e, cmnds)
+					polkitID = fmt.Sprintf("unix-user:%s", e)
 				}
 				polkitElem = append(polkitElem, polkitID)
 			}
 			if len(polkitElem) < 1 {
 				continue
 			}
-			polkitAdditionalUsersGroups = polkitElem
+			if cmnds == "ALL" {
+				polkitAdditionalUsersGroups = polkitElem
+			}
+		case "client-admins-commands", "client-admins-polkit-actions", "client-admins-time-window":
+			// Already handled with client-admins.
+			continue
 		}
 
 		// Write to our files
@@ -193,27 +376,192 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		}
 	}
 
-	// Move temp files to their final destination
-	if err := os.Rename(sudoersConf+".new", sudoersConf); err != nil {
+	// Polkit rules are only needed if there are both actions and administrators to grant them to.
+	hasPolkitRules := len(polkitActions) > 0 && len(clientAdmins) > 0
+	if hasPolkitRules {
+		// nolint:gosec // G301 match distribution permission
+		if err := os.MkdirAll(filepath.Dir(policyKitRules), 0755); err != nil {
+			return err
+		}
+		// nolint:gosec // G306 match distribution permission
+		if err := os.WriteFile(policyKitRules+".new", []byte(polkitRules(clientAdmins, polkitActions)), 0644); err != nil {
+			return err
+		}
+	}
+
+	// An invalid sudoers file breaks sudo for everyone: only install it, and the matching polkit file, once validated.
+	if err := sudoersF.Close(); err != nil {
+		return err
+	}
+	// The installed sudoers file was already validated if it is unchanged.
+	sudoersUnchanged, err := fileutils.Same(sudoersConf+".new", sudoersConf)
+	if err != nil {
+		return err
+	}
+	if sudoersUnchanged {
+		log.Debugf(ctx, "Sudoers file %s is unchanged", sudoersConf)
+	} else if err := m.validateSudoers(ctx, sudoersConf+".new"); err != nil {
 		return err
 	}
-	if err := os.Rename(policyKitConf+".new", policyKitConf); err != nil {
+	if !allowLocalAdmins {
+		if err := m.recordSuppression(ctx, systemPolkitAdmins); err != nil {
+			return err
+		}
+	}
+
+	// Move temp files to their final destination, unless the installed ones are identical
+	toReplace := []string{sudoersConf, policyKitConf}
+	if !hasPolkitRules {
+		if err := os.Remove(policyKitRules); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	} else {
+		toReplace = append(toReplace, policyKitRules)
+	}
+	for _, p := range toReplace {
+		replaced, err := fileutils.ReplaceIfChanged(p+".new", p)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			audit.RecordFile(ctx, p)
+		}
+	}
+
+	// The timer only needs to grant or revoke client administrators, whether they are currently granted or not.
+	if len(timeWindows) > 0 && hasClientAdmins {
+		if err := m.scheduleTimeWindows(ctx, timeWindows); err != nil {
+			return err
+		}
+	} else if err := m.removeTimeWindows(ctx); err != nil {
 		return err
 	}
 
+	if allowLocalAdmins {
+		return m.removeSuppression(ctx, policyKitDir)
+	}
+	return nil
+}
+
+// polkitRules returns the polkit rules granting admins the actions, provided they authenticate as themselves.
+// The authentication is retained for a brief period, similarly to sudo.
+func polkitRules(admins, actions []string) string {
+	var subjects []string
+	for _, a := range admins {
+		switch {
+		case strings.HasPrefix(a, "+"):
+			subjects = append(subjects, fmt.Sprintf("subject.isInNetGroup(%s)", jsString(strings.TrimPrefix(a, "+"))))
+		case strings.HasPrefix(a, "%"):
+			subjects = append(subjects, fmt.Sprintf("subject.isInGroup(%s)", jsString(strings.TrimPrefix(a, "%"))))
+		default:
+			subjects = append(subjects, fmt.Sprintf("subject.user == %s", jsString(a)))
+		}
+	}
+	var quotedActions []string
+	for _, a := range actions {
+		quotedActions = append(quotedActions, jsString(a))
+	}
+
+	return fmt.Sprintf(`// This file is managed by adsys.
+// Do not edit this file manually.
+// Any changes will be overwritten.
+
+polkit.addRule(function(action, subject) {
+    var actions = [
+        %s
+    ];
+    if (actions.indexOf(action.id) < 0) {
+        return polkit.Result.NOT_HANDLED;
+    }
+    if (%s) {
+        return polkit.Result.AUTH_SELF_KEEP;
+    }
+    return polkit.Result.NOT_HANDLED;
+});
+`, strings.Join(quotedActions, ",\n        "), strings.Join(subjects, " ||\n        "))
+}
+
+// jsString returns s as a JavaScript string literal.
+func jsString(s string) string {
+	// JSON strings are valid JavaScript string literals.
+	b, err := json.Marshal(s)
+	if err != nil {
+		// Marshalling a string never fails.
+		panic(err)
+	}
+	return string(b)
+}
+
+// polkitActionRegexp matches valid polkit action ids, like org.freedesktop.udisks2.filesystem-mount.
+var polkitActionRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)+$`)
+
+// splitAndCheckPolkitActions returns the polkit action ids listed in v, one per line.
+// Empty lines are ignored. An error is returned if any action id is invalid.
+func splitAndCheckPolkitActions(v string) ([]string, error) {
+	var actions []string
+	for _, l := range strings.Split(v, "\n") {
+		a := strings.TrimSpace(l)
+		if a == "" {
+			continue
+		}
+		if !polkitActionRegexp.MatchString(a) {
+			return nil, errors.New(gotext.Get("invalid polkit action id %q", a))
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// validateSudoers checks the syntax of the sudoers file at p with visudo.
+// Validation is skipped if visudo is not installed, as sudo is then not installed either.
+func (m *Manager) validateSudoers(ctx context.Context, p string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("invalid sudoers file generated, previous one is kept"))
+
+	cmdArgs := m.visudoCmd
+	if cmdArgs == nil {
+		cmdArgs = []string{"visudo"}
+		if _, err := exec.LookPath(cmdArgs[0]); err != nil {
+			log.Warningf(ctx, "visudo is not available, sudoers file can't be validated: %v", err)
+			return nil
+		}
+	}
+	cmdArgs = append(slices.Clone(cmdArgs), "-c", "-f", p)
+
+	log.Debugf(ctx, "Validating sudoers file with %v", cmdArgs)
+
+	smbsafe.WaitExec()
+	// #nosec G204 - we control the input
+	out, err := exec.Command(cmdArgs[0], cmdArgs[1:]...).CombinedOutput()
+	smbsafe.DoneExec()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+
 	return nil
 }
 
 // splitAndNormalizeUsersAndGroups allow splitting on lines and ,.
 // We remove any invalid characters and empty elements.
-// All will have the form of user@domain.
-func splitAndNormalizeUsersAndGroups(ctx context.Context, v string) []string {
+// All will have the form of user@domain, except NIS netgroups, prefixed with +, which are kept as is.
+// An error is returned if any netgroup name is invalid.
+func splitAndNormalizeUsersAndGroups(ctx context.Context, v string) ([]string, error) {
 	var elems []string
 	elems = append(elems, strings.Split(v, "\n")...)
 	v = strings.Join(elems, ",")
 	elems = nil
 	for _, e := range strings.Split(v, ",") {
 		initialValue := e
+
+		// netgroups are not AD objects: they don’t follow the Windows naming rules.
+		if e = strings.TrimSpace(e); strings.HasPrefix(e, "+") {
+			if err := checkNetgroup(strings.TrimPrefix(e, "+")); err != nil {
+				return nil, errors.New(gotext.Get("invalid netgroup %q: %v", initialValue, err))
+			}
+			elems = append(elems, e)
+			continue
+		}
+		e = initialValue
+
 		// Invalid chars in Windows user names: '/[]:|<>+=;,?*%"
 		isgroup := strings.HasPrefix(e, "%")
 		for _, c := range []string{"/", "[", "]", ":", "|", "<", ">", "=", ";", "?", "*", "%"} {
@@ -240,7 +588,53 @@ func splitAndNormalizeUsersAndGroups(ctx context.Context, v string) []string {
 		elems = append(elems, e)
 	}
 
-	return elems
+	return elems, nil
+}
+
+// checkNetgroup returns an error if name can’t be a NIS netgroup name.
+func checkNetgroup(name string) error {
+	if name == "" {
+		return errors.New(gotext.Get("empty netgroup name"))
+	}
+	for _, c := range name {
+		// Those characters are separators in netgroup definitions and polkit identities, or sudoers prefixes.
+		if unicode.IsSpace(c) || unicode.IsControl(c) || strings.ContainsRune(`()";+%`, c) {
+			return errors.New(gotext.Get("forbidden character %q", c))
+		}
+	}
+	return nil
+}
+
+// splitAndNormalizeCommands returns the commands listed in v, one per line, escaped for sudoers.
+// Empty lines are ignored. An error is returned if any command is not an absolute path or sudoedit.
+func splitAndNormalizeCommands(v string) ([]string, error) {
+	var cmnds []string
+	for _, l := range strings.Split(v, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		if !filepath.IsAbs(fields[0]) && fields[0] != "sudoedit" {
+			return nil, errors.New(gotext.Get("invalid command %q: commands must be absolute paths or sudoedit", strings.TrimSpace(l)))
+		}
+		for i, f := range fields {
+			fields[i] = escapeSudoers(f, sudoersCommandSpecialChars)
+		}
+		cmnds = append(cmnds, strings.Join(fields, " "))
+	}
+	return cmnds, nil
+}
+
+// escapeSudoers escapes with a backslash the characters of specials found in w.
+func escapeSudoers(w, specials string) string {
+	var b strings.Builder
+	for _, c := range w {
+		if strings.ContainsRune(specials, c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
 }
 
 // getSystemPolkitAdminIdentities returns the list of configured system polkit admins as a string.
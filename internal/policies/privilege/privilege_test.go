@@ -2,9 +2,15 @@ package privilege_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
@@ -25,6 +31,7 @@ func TestApplyPolicy(t *testing.T) {
 		existingPolkitDir  string
 		makeReadOnly       string
 		destIsDir          string
+		visudoFail         bool
 
 		wantErr bool
 	}{
@@ -33,12 +40,61 @@ func TestApplyPolicy(t *testing.T) {
 		"Allow local admins with no other rules is a noop": {entries: []entry.Entry{{Key: "allow-local-admins", Disabled: false}}},
 
 		// client admins from AD
-		"Set client user admins":                       {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}}},
-		"Set client multiple users admins":             {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,domain\\bob,carole cosmic@otherdomain.com"}}},
-		"Set client group admins":                      {entries: []entry.Entry{{Key: "client-admins", Value: "%group@domain.com"}}},
-		"Set client mixed with users and group admins": {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}},
-		"Empty client AD admins":                       {entries: []entry.Entry{{Key: "client-admins", Value: ""}}},
-		"No client AD admins":                          {entries: []entry.Entry{{Key: "client-admins", Disabled: true}}},
+		"Set client user admins":                                     {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com"}}},
+		"Set client multiple users admins":                           {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,domain\\bob,carole cosmic@otherdomain.com"}}},
+		"Set client group admins":                                    {entries: []entry.Entry{{Key: "client-admins", Value: "%group@domain.com"}}},
+		"Set client mixed with users and group admins":               {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}}},
+		"Set client netgroup admins":                                 {entries: []entry.Entry{{Key: "client-admins", Value: "+netgroup"}}},
+		"Set client mixed with users, groups and netgroup admins":    {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,%group@domain.com\n+netgroup,domain\\bob,+othernetgroup"}}},
+		"Set client netgroup admins with sudoers special characters": {entries: []entry.Entry{{Key: "client-admins", Value: `+net:group=admins!,+domain\netgroup`}}},
+		// client admins restricted to commands
+		"Set client admins restricted to commands": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com,%group@domain.com,+netgroup"},
+			{Key: "client-admins-commands", Value: "/usr/bin/systemctl restart nginx.service\n/usr/bin/journalctl -u nginx.service"}}},
+		"Restricted commands apply whatever the entries order": {entries: []entry.Entry{
+			{Key: "client-admins-commands", Value: "/usr/bin/systemctl restart nginx.service"},
+			{Key: "client-admins", Value: "alice@domain.com"}}},
+		"Restricted commands are escaped and empty lines ignored": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-commands", Value: "\n  /usr/bin/env  FOO=bar,baz:qux \n\nsudoedit /etc/hosts\n/usr/bin/uptime \"\""}}},
+		"Disabled restricted commands grant any command": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-commands", Disabled: true}}},
+		"Empty restricted commands grant any command": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-commands", Value: "\n"}}},
+		"Restricted commands with local admins disallowed": {entries: []entry.Entry{
+			{Key: "allow-local-admins", Disabled: true},
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-commands", Value: "/usr/bin/systemctl restart nginx.service"}}},
+		"Restricted commands without client admins": {entries: []entry.Entry{
+			{Key: "client-admins-commands", Value: "/usr/bin/systemctl restart nginx.service"}}},
+		// polkit actions granted to client admins
+		"Set client admins polkit actions": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com,%group@domain.com,+netgroup"},
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.udisks2.filesystem-mount\n\n  org.freedesktop.login1.power-off  \n"}}},
+		"Set polkit actions for restricted client admins": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-commands", Value: "/usr/bin/systemctl restart nginx.service"},
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.login1.power-off"}}},
+		"Polkit actions names are escaped": {entries: []entry.Entry{
+			{Key: "client-admins", Value: `+domain\netgroup`},
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.login1.power-off"}}},
+		"Overwrite existing polkit rules file": {existingSudoersDir: "existing-polkit-rules", existingPolkitDir: "existing-polkit-rules", entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.login1.power-off"}}},
+		"Polkit actions without client admins do not create rules file": {existingPolkitDir: "existing-polkit-rules", entries: []entry.Entry{
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.login1.power-off"}}},
+		"Disabled polkit actions remove existing rules file": {existingPolkitDir: "existing-polkit-rules", entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-polkit-actions", Disabled: true}}},
+		"Empty polkit actions remove existing rules file": {existingPolkitDir: "existing-polkit-rules", entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-polkit-actions", Value: "\n"}}},
+		"No rules remove existing polkit rules file": {existingSudoersDir: "existing-polkit-rules", existingPolkitDir: "existing-polkit-rules"},
+
+		"Empty client AD admins": {entries: []entry.Entry{{Key: "client-admins", Value: ""}}},
+		"No client AD admins":    {entries: []entry.Entry{{Key: "client-admins", Disabled: true}}},
 
 		// Mixed rules
 		"Disallow local admins and set client admins": {entries: []entry.Entry{
@@ -79,11 +135,25 @@ func TestApplyPolicy(t *testing.T) {
 		"Not a computer": {notComputer: true, existingSudoersDir: "existing-other-files", existingPolkitDir: "existing-other-files"},
 
 		// Error cases
-		"Error on writing to sudoers file":                          {makeReadOnly: "sudoers.d/", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error on writing to polkit subdirectory creation":          {makeReadOnly: "polkit-1/", existingSudoersDir: "existing-files", existingPolkitDir: "only-base-polkit-dir", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error on writing to polkit conf file":                      {makeReadOnly: "polkit-1/localauthority.conf.d", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error on creating sudoers and polkit base directory":       {makeReadOnly: ".", entries: defaultLocalAdminDisabledRule, wantErr: true},
-		"Error if can’t rename to destination for sudoers file":     {destIsDir: "sudoers.d/99-adsys-privilege-enforcement", entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error on writing to sudoers file":                      {makeReadOnly: "sudoers.d/", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error on writing to polkit subdirectory creation":      {makeReadOnly: "polkit-1/", existingSudoersDir: "existing-files", existingPolkitDir: "only-base-polkit-dir", entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error on writing to polkit conf file":                  {makeReadOnly: "polkit-1/localauthority.conf.d", existingSudoersDir: "existing-files", existingPolkitDir: "existing-files", entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error on creating sudoers and polkit base directory":   {makeReadOnly: ".", entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error if can’t rename to destination for sudoers file": {destIsDir: "sudoers.d/99-adsys-privilege-enforcement", entries: defaultLocalAdminDisabledRule, wantErr: true},
+		"Error on relative restricted command": {entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-commands", Value: "/usr/bin/uptime\nsystemctl restart nginx.service"}}, wantErr: true},
+		"Error on invalid sudoers file keeps existing files": {existingSudoersDir: "existing-files", existingPolkitDir: "existing-files",
+			entries: []entry.Entry{{Key: "client-admins", Value: `alice"@domain.com`}}, wantErr: true},
+		"Error on invalid sudoers file does not create files": {entries: []entry.Entry{{Key: "client-admins", Value: `alice"@domain.com`}}, wantErr: true},
+		"Error on visudo failing":                             {entries: defaultLocalAdminDisabledRule, visudoFail: true, wantErr: true},
+		"Error on invalid polkit action id keeps existing files": {existingSudoersDir: "existing-polkit-rules", existingPolkitDir: "existing-polkit-rules", entries: []entry.Entry{
+			{Key: "client-admins", Value: "alice@domain.com"},
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.login1.power-off\norg.freedesktop.login1.power off"}}, wantErr: true},
+		"Error on invalid sudoers file keeps existing polkit rules file": {existingSudoersDir: "existing-polkit-rules", existingPolkitDir: "existing-polkit-rules", entries: []entry.Entry{
+			{Key: "client-admins", Value: `alice"@domain.com`},
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.login1.power-off"}}, wantErr: true},
+		"Error on invalid netgroup":                                 {entries: []entry.Entry{{Key: "client-admins", Value: "alice@domain.com,+net group"}}, wantErr: true},
 		"Error if can’t rename to destination for polkit conf file": {destIsDir: "polkit-1/localauthority.conf.d/99-adsys-privilege-enforcement.conf", entries: defaultLocalAdminDisabledRule, wantErr: true},
 	}
 
@@ -119,15 +189,321 @@ func TestApplyPolicy(t *testing.T) {
 				require.NoError(t, os.MkdirAll(filepath.Join(tempEtc, tc.destIsDir), 0750), "Setup: can't create fake unwritable file")
 			}
 
-			m := privilege.NewWithDirs(sudoersDir, policyKitDir)
+			m := privilege.NewWithDirs(sudoersDir, policyKitDir,
+				privilege.WithStateDir(t.TempDir()),
+				privilege.WithVisudoCmd(mockVisudoCmd(t, tc.visudoFail)))
 			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.notComputer, tc.entries)
 			if tc.wantErr {
 				require.NotNil(t, err, "ApplyPolicy should have failed but didn't")
+				if tc.makeReadOnly != "" || tc.destIsDir != "" {
+					return
+				}
+				// Previous files, if any, are left untouched.
+				testutils.CompareTreesWithFiltering(t, tempEtc, testutils.GoldenPath(t), testutils.UpdateEnabled())
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			testutils.CompareTreesWithFiltering(t, tempEtc, testutils.GoldenPath(t), testutils.UpdateEnabled())
+
+			// sudo ignores files which are not read only and owned by root, as the daemon runs.
+			fi, err := os.Stat(filepath.Join(sudoersDir, "99-adsys-privilege-enforcement"))
+			if errors.Is(err, fs.ErrNotExist) {
+				return
+			}
+			require.NoError(t, err, "Can't stat generated sudoers file")
+			require.Equal(t, fs.FileMode(0440), fi.Mode().Perm(), "Sudoers file should be read only")
+			stat, ok := fi.Sys().(*syscall.Stat_t)
+			require.True(t, ok, "Can't get sudoers file owner")
+			require.Equal(t, os.Geteuid(), int(stat.Uid), "Sudoers file should be owned by the daemon user")
+		})
+	}
+}
+
+func TestApplyPolicyUnchanged(t *testing.T) {
+	t.Parallel()
+
+	entries := []entry.Entry{
+		{Key: "allow-local-admins", Disabled: true},
+		{Key: "client-admins", Value: "alice@domain.com"},
+	}
+
+	tests := map[string]struct {
+		modifySudoers bool
+		removeSudoers bool
+
+		wantValidated bool
+	}{
+		"Unchanged files are not validated nor replaced": {},
+
+		// Drift correction
+		"Sudoers file modified out-of-band is validated and restored": {modifySudoers: true, wantValidated: true},
+		"Sudoers file removed out-of-band is validated and restored":  {removeSudoers: true, wantValidated: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempEtc := t.TempDir()
+			sudoersDir := filepath.Join(tempEtc, "sudoers.d")
+			policyKitDir := filepath.Join(tempEtc, "polkit-1")
+			sudoersConf := filepath.Join(sudoersDir, "99-adsys-privilege-enforcement")
+			stateDir := t.TempDir()
+
+			m := privilege.NewWithDirs(sudoersDir, policyKitDir,
+				privilege.WithStateDir(stateDir),
+				privilege.WithVisudoCmd(mockVisudoCmd(t, false)))
+			require.NoError(t, m.ApplyPolicy(context.Background(), "ubuntu", true, entries), "Setup: first ApplyPolicy failed")
+			want, err := os.ReadFile(sudoersConf)
+			require.NoError(t, err, "Setup: can't read generated sudoers file")
+			before, err := os.Stat(sudoersConf)
+			require.NoError(t, err, "Setup: can't stat generated sudoers file")
+
+			switch {
+			case tc.modifySudoers:
+				require.NoError(t, os.Chmod(sudoersConf, 0600), "Setup: can't make sudoers file writable")
+				require.NoError(t, os.WriteFile(sudoersConf, []byte("ALL ALL=(ALL:ALL) ALL\n"), 0600), "Setup: can't modify sudoers file")
+			case tc.removeSudoers:
+				require.NoError(t, os.Remove(sudoersConf), "Setup: can't remove sudoers file")
+			}
+
+			// A failing visudo detects any validation of the generated sudoers file.
+			failingM := privilege.NewWithDirs(sudoersDir, policyKitDir,
+				privilege.WithStateDir(stateDir),
+				privilege.WithVisudoCmd(mockVisudoCmd(t, true)))
+			err = failingM.ApplyPolicy(context.Background(), "ubuntu", true, entries)
+			if !tc.wantValidated {
+				require.NoError(t, err, "ApplyPolicy should not validate unchanged sudoers file")
+				after, err := os.Stat(sudoersConf)
+				require.NoError(t, err, "Can't stat sudoers file")
+				require.True(t, os.SameFile(before, after), "Unchanged sudoers file should not be replaced")
 				return
 			}
+			require.Error(t, err, "ApplyPolicy should validate modified sudoers file")
+
+			require.NoError(t, m.ApplyPolicy(context.Background(), "ubuntu", true, entries), "ApplyPolicy failed but shouldn't have")
+			got, err := os.ReadFile(sudoersConf)
+			require.NoError(t, err, "Can't read restored sudoers file")
+			require.Equal(t, string(want), string(got), "Sudoers file should be restored")
+			fi, err := os.Stat(sudoersConf)
+			require.NoError(t, err, "Can't stat restored sudoers file")
+			require.Equal(t, fs.FileMode(0440), fi.Mode().Perm(), "Restored sudoers file should be read only")
+		})
+	}
+}
+
+func TestLocalAdminsSuppression(t *testing.T) {
+	t.Parallel()
+
+	disallow := []entry.Entry{{Key: "allow-local-admins", Disabled: true}}
+	allow := []entry.Entry{{Key: "allow-local-admins", Disabled: false}, {Key: "client-admins", Value: "alice@domain.com"}}
+
+	tests := map[string]struct {
+		existingPolkitDir string
+		restoreWith       []entry.Entry
+
+		wantPolkitAdmins string
+	}{
+		"Suppression ends when the policy is removed":      {},
+		"Suppression ends when local admins are allowed":   {restoreWith: allow},
+		"Suppression ends when allowing local admins only": {restoreWith: []entry.Entry{{Key: "allow-local-admins", Value: "", Disabled: false}}},
+		"Suppression records overridden polkit admins":     {existingPolkitDir: "existing-previous-local-admins-one", wantPolkitAdmins: "unix-user:local50admin1;unix-user:local50admin2"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempEtc := t.TempDir()
+			if tc.existingPolkitDir != "" {
+				require.NoError(t,
+					shutil.CopyTree(
+						filepath.Join("testdata", tc.existingPolkitDir, "polkit-1"), filepath.Join(tempEtc, "polkit-1"),
+						&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+					"Setup: can't create initial polkit directory")
+			}
+			now := time.Date(2024, time.March, 4, 10, 0, 0, 0, time.UTC)
+			m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+				privilege.WithStateDir(t.TempDir()),
+				privilege.WithVisudoCmd(mockVisudoCmd(t, false)),
+				privilege.WithClock(func() time.Time { return now }))
+
+			_, suppressed, err := m.LocalAdminsSuppression()
+			require.NoError(t, err, "LocalAdminsSuppression failed but shouldn't have")
+			require.False(t, suppressed, "Local admins should not be suppressed before applying the policy")
+
+			// Apply
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, disallow)
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			s, suppressed, err := m.LocalAdminsSuppression()
+			require.NoError(t, err, "LocalAdminsSuppression failed but shouldn't have")
+			require.True(t, suppressed, "Local admins should be suppressed")
+			require.Equal(t, []string{"admin", "sudo"}, s.SudoersGroups, "Suppressed sudoers groups don't match")
+			require.Equal(t, tc.wantPolkitAdmins, s.PolkitAdminIdentities, "Suppressed polkit administrators don't match")
+			require.True(t, now.Equal(s.Since), "Suppression time should be the one of the first suppression")
+
+			// Re-apply keeps the record of the first suppression
+			now = now.Add(time.Hour)
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, disallow)
 			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			again, suppressed, err := m.LocalAdminsSuppression()
+			require.NoError(t, err, "LocalAdminsSuppression failed but shouldn't have")
+			require.True(t, suppressed, "Local admins should still be suppressed")
+			require.True(t, s.Since.Equal(again.Since), "Re-applying the policy should not change the suppression time")
+			require.Equal(t, s.PolkitAdminIdentities, again.PolkitAdminIdentities, "Re-applying the policy should keep the initial polkit administrators")
+
+			// End the suppression
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, tc.restoreWith)
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			_, suppressed, err = m.LocalAdminsSuppression()
+			require.NoError(t, err, "LocalAdminsSuppression failed but shouldn't have")
+			require.False(t, suppressed, "Local admins should not be suppressed anymore")
+		})
+	}
+}
+
+func TestApplyPolicyTimeWindow(t *testing.T) {
+	t.Parallel()
+
+	// 2026-01-05 is a Monday.
+	monday := func(hour, minute int) time.Time {
+		return time.Date(2026, time.January, 5, hour, minute, 0, 0, time.UTC)
+	}
+	saturday := time.Date(2026, time.January, 10, 10, 0, 0, 0, time.UTC)
+	sunday := time.Date(2026, time.January, 11, 10, 30, 0, 0, time.UTC)
+
+	admins := entry.Entry{Key: "client-admins", Value: "alice@domain.com,%group@domain.com"}
+	workingHours := entry.Entry{Key: "client-admins-time-window", Value: "Mon-Fri 08:00-18:00"}
+
+	tests := map[string]struct {
+		entries       []entry.Entry
+		now           time.Time
+		existingTimer bool
+
+		wantErr bool
+	}{
+		// Grants within the window
+		"Client admins are granted within the window":        {entries: []entry.Entry{admins, workingHours}, now: monday(10, 0)},
+		"Client admins are granted at the window start":      {entries: []entry.Entry{admins, workingHours}, now: monday(8, 0)},
+		"Client admins are granted within any of windows":    {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "Mon 08:00-12:00\n\n  Sat,Sun 10:00-11:00  "}}, now: sunday},
+		"Client admins are granted until midnight":           {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "20:00-24:00"}}, now: monday(23, 59)},
+		"Days ranges can wrap around the end of the week":    {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "Fri-Mon 08:00-18:00"}}, now: sunday},
+		"Time window applies whatever the entries order":     {entries: []entry.Entry{workingHours, admins}, now: monday(10, 0)},
+		"Disabled time window grants at any time":            {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Disabled: true}}, now: saturday},
+		"Empty time window grants at any time":               {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "\n"}}, now: saturday},
+		"Time window without client admins is not scheduled": {entries: []entry.Entry{workingHours}, now: monday(10, 0)},
+
+		// Revocation outside of the window
+		"Client admins are not granted before the window":    {entries: []entry.Entry{admins, workingHours}, now: monday(7, 59)},
+		"Client admins are not granted at the window end":    {entries: []entry.Entry{admins, workingHours}, now: monday(18, 0)},
+		"Client admins are not granted outside of the days":  {entries: []entry.Entry{admins, workingHours}, now: saturday},
+		"Disallowed local admins stay so outside the window": {entries: []entry.Entry{{Key: "allow-local-admins", Disabled: true}, admins, workingHours}, now: saturday},
+		"Restricted client admins are not granted outside the window": {now: saturday, entries: []entry.Entry{admins, workingHours,
+			{Key: "client-admins-commands", Value: "/usr/bin/systemctl restart nginx.service"}}},
+		"Polkit actions are not granted outside the window": {now: saturday, entries: []entry.Entry{admins, workingHours,
+			{Key: "client-admins-polkit-actions", Value: "org.freedesktop.login1.power-off"}}},
+
+		// Existing timer
+		"Update existing timer":                       {existingTimer: true, entries: []entry.Entry{admins, workingHours}, now: monday(10, 0)},
+		"Removed time window removes existing timer":  {existingTimer: true, entries: []entry.Entry{admins}, now: monday(10, 0)},
+		"No rules remove existing timer":              {existingTimer: true, now: monday(10, 0)},
+		"Removed client admins remove existing timer": {existingTimer: true, entries: []entry.Entry{workingHours}, now: monday(10, 0)},
+
+		// Error cases
+		"Error on unknown day":                         {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "Mon-Fry 08:00-18:00"}}, wantErr: true},
+		"Error on invalid hours":                       {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "Mon-Fri 8:00-18:00"}}, wantErr: true},
+		"Error on time after midnight":                 {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "20:00-24:30"}}, wantErr: true},
+		"Error on window starting at midnight end":     {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "24:00-24:00"}}, wantErr: true},
+		"Error on window ending before its start":      {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "22:00-06:00"}}, wantErr: true},
+		"Error on missing window end":                  {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "Mon 08:00"}}, wantErr: true},
+		"Error on too many fields":                     {entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "Mon Tue 08:00-18:00"}}, wantErr: true},
+		"Error on invalid window keeps existing timer": {existingTimer: true, entries: []entry.Entry{admins, {Key: "client-admins-time-window", Value: "Mon-Fri 18:00-08:00"}}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempEtc := t.TempDir()
+			systemUnitDir := filepath.Join(tempEtc, "systemd", "system")
+			if tc.existingTimer {
+				require.NoError(t,
+					shutil.CopyTree(
+						filepath.Join("testdata", "existing-time-window-timer", "systemd", "system"), systemUnitDir,
+						&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+					"Setup: can't create initial systemd unit directory")
+			}
+
+			m := privilege.NewWithDirs(filepath.Join(tempEtc, "sudoers.d"), filepath.Join(tempEtc, "polkit-1"),
+				privilege.WithStateDir(t.TempDir()),
+				privilege.WithVisudoCmd(mockVisudoCmd(t, false)),
+				privilege.WithSystemUnitDir(systemUnitDir),
+				privilege.WithSystemdCaller(testutils.MockSystemdCaller{}),
+				privilege.WithClock(func() time.Time { return tc.now }))
+			err := m.ApplyPolicy(context.Background(), "ubuntu", true, tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+			} else {
+				require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+			}
 
 			testutils.CompareTreesWithFiltering(t, tempEtc, testutils.GoldenPath(t), testutils.UpdateEnabled())
 		})
 	}
 }
+
+func mockVisudoCmd(t *testing.T, wantFail bool) []string {
+	t.Helper()
+
+	cmdArgs := []string{"env", "GO_WANT_HELPER_PROCESS=1", os.Args[0], "-test.run=TestMockVisudo", "--"}
+	if wantFail {
+		cmdArgs = append(cmdArgs, "-Exit1-")
+	}
+	return cmdArgs
+}
+
+// TestMockVisudo mocks visudo -c -f, rejecting quoted user names which are not directly followed by a space.
+func TestMockVisudo(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	args := os.Args
+	for len(args) > 0 {
+		if args[0] != "--" {
+			args = args[1:]
+			continue
+		}
+		args = args[1:]
+		break
+	}
+
+	if len(args) > 0 && args[0] == "-Exit1-" {
+		fmt.Fprintln(os.Stderr, "EXIT 1 requested in mock")
+		os.Exit(1)
+	}
+
+	if len(args) != 3 || args[0] != "-c" || args[1] != "-f" {
+		fmt.Fprintf(os.Stderr, "unexpected arguments: %v\n", args)
+		os.Exit(1)
+	}
+	p := args[2]
+
+	d, err := os.ReadFile(p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "visudo: unable to open %s: %v\n", p, err)
+		os.Exit(1)
+	}
+	for i, l := range strings.Split(string(d), "\n") {
+		if !strings.HasPrefix(l, `"`) {
+			continue
+		}
+		end := strings.Index(l[1:], `"`) + 1
+		if end == 0 || end+1 >= len(l) || (l[end+1] != ' ' && l[end+1] != '\t') {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: syntax error\n%s\n", p, i+1, end+2, l)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("%s: parsed OK\n", p)
+}
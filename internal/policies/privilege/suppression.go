@@ -0,0 +1,123 @@
+package privilege
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// localAdminsGroups are the distribution administrator groups denied sudo when local admins are suppressed.
+var localAdminsGroups = []string{"admin", "sudo"}
+
+// LocalAdminsSuppression records when and how local administrators are suppressed.
+// It is only a record: the system sudoers and polkit configuration is never modified, but overridden by our files,
+// so that removing them is what allows local administrators again.
+type LocalAdminsSuppression struct {
+	// Since is when local administrators were first suppressed.
+	Since time.Time `yaml:"since"`
+	// SudoersGroups are the administrator groups denied sudo.
+	SudoersGroups []string `yaml:"sudoers_groups"`
+	// PolkitAdminIdentities are the system polkit administrators which were overridden.
+	PolkitAdminIdentities string `yaml:"polkit_admin_identities,omitempty"`
+}
+
+// suppressionPath returns the path of the local administrators suppression record.
+func (m *Manager) suppressionPath() string {
+	stateDir := m.stateDir
+	if stateDir == "" {
+		stateDir = consts.DefaultStateDir
+	}
+	return filepath.Join(stateDir, "privilege", "local-admins-suppressed")
+}
+
+// LocalAdminsSuppression returns the record of the local administrators suppression.
+// suppressed is false if local administrators are currently allowed.
+func (m *Manager) LocalAdminsSuppression() (s LocalAdminsSuppression, suppressed bool, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read local administrators suppression state"))
+
+	d, err := os.ReadFile(m.suppressionPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return LocalAdminsSuppression{}, false, nil
+	} else if err != nil {
+		return LocalAdminsSuppression{}, false, err
+	}
+	if err := yaml.Unmarshal(d, &s); err != nil {
+		return LocalAdminsSuppression{}, false, err
+	}
+	return s, true, nil
+}
+
+// recordSuppression records that local administrators are suppressed, along with the system polkit administrators
+// our files override.
+// An existing record is kept as is, so that it always describes the first suppression.
+func (m *Manager) recordSuppression(ctx context.Context, systemPolkitAdmins string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't record local administrators suppression"))
+
+	p := m.suppressionPath()
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	d, err := yaml.Marshal(LocalAdminsSuppression{
+		Since:                 m.now(),
+		SudoersGroups:         localAdminsGroups,
+		PolkitAdminIdentities: systemPolkitAdmins,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+".new", d, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(p+".new", p); err != nil {
+		return err
+	}
+
+	log.Infof(ctx, "Suppressing local administrators: sudo denied to groups %v, polkit administrators %q overridden", localAdminsGroups, systemPolkitAdmins)
+	return nil
+}
+
+// removeSuppression removes the record of the local administrators suppression, once our files don’t suppress them
+// anymore. Nothing else needs to be restored, as the system configuration was never modified: the system polkit
+// administrators are only checked against the record so that any change made while suppressed is reported.
+func (m *Manager) removeSuppression(ctx context.Context, policyKitDir string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't remove local administrators suppression record"))
+
+	s, suppressed, err := m.LocalAdminsSuppression()
+	if err != nil {
+		return err
+	}
+	if !suppressed {
+		return nil
+	}
+
+	systemPolkitAdmins, err := getSystemPolkitAdminIdentities(ctx, policyKitDir)
+	if err != nil {
+		return err
+	}
+	if systemPolkitAdmins != s.PolkitAdminIdentities {
+		log.Warningf(ctx, "System polkit administrators changed while local administrators were suppressed: %q before, %q now", s.PolkitAdminIdentities, systemPolkitAdmins)
+	}
+
+	if err := os.Remove(m.suppressionPath()); err != nil {
+		return err
+	}
+
+	log.Infof(ctx, "Local administrators are not suppressed anymore since %s: sudo allowed to groups %v, polkit administrators %q", s.Since.Format(time.RFC3339), s.SudoersGroups, systemPolkitAdmins)
+	return nil
+}
@@ -0,0 +1,295 @@
+package privilege
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/fileutils"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+const timeWindowUnitName = "adsys-privilege-time-window"
+
+// timeWindowService refreshes the privilege policy, which grants or revokes client administrators.
+const timeWindowService = fileutils.ManagedHeader + `
+[Unit]
+Description=Update ADSys client administrators time windows
+
+[Service]
+Type=oneshot
+ExecStart=/sbin/adsysctl update --machine --only privilege
+`
+
+// timeWindowTimer starts the time window service at the boundaries of the client administrators time windows.
+// Persistent catches up on a boundary missed while the machine was off.
+const timeWindowTimer = fileutils.ManagedHeader + `
+[Unit]
+Description=Grant or revoke ADSys client administrators at their time windows boundaries
+
+[Timer]
+%s
+AccuracySec=1s
+Persistent=true
+
+[Install]
+WantedBy=timers.target
+`
+
+// weekdays are the day names of time windows, and of systemd calendar events, in time.Weekday order.
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// timeWindow is a period of the day, in minutes since midnight, on some days of the week.
+type timeWindow struct {
+	days  [7]bool
+	start int
+	end   int
+}
+
+// parseTimeWindows returns the time windows listed in v, one per line, like "Mon-Fri,Sun 08:00-18:00".
+// Days are optional, any day being allowed if they are omitted. The end of a window must be after its start,
+// and can be 24:00 for the end of the day. Empty lines are ignored.
+func parseTimeWindows(v string) (windows []timeWindow, err error) {
+	for _, l := range strings.Split(v, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+		w, err := parseTimeWindow(fields)
+		if err != nil {
+			return nil, errors.New(gotext.Get("invalid time window %q: %v", strings.TrimSpace(l), err))
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseTimeWindow(fields []string) (w timeWindow, err error) {
+	switch len(fields) {
+	case 1:
+		for i := range w.days {
+			w.days[i] = true
+		}
+	case 2:
+		if w.days, err = parseDays(fields[0]); err != nil {
+			return timeWindow{}, err
+		}
+		fields = fields[1:]
+	default:
+		return timeWindow{}, errors.New(gotext.Get("expecting optional days followed by hours, like Mon-Fri 08:00-18:00"))
+	}
+
+	start, end, found := strings.Cut(fields[0], "-")
+	if !found {
+		return timeWindow{}, errors.New(gotext.Get("expecting hours like 08:00-18:00"))
+	}
+	if w.start, err = parseClock(start); err != nil {
+		return timeWindow{}, err
+	}
+	if w.end, err = parseClock(end); err != nil {
+		return timeWindow{}, err
+	}
+	if w.start == 24*60 {
+		return timeWindow{}, errors.New(gotext.Get("a window can't start at 24:00"))
+	}
+	if w.end <= w.start {
+		return timeWindow{}, errors.New(gotext.Get("the end of a window must be after its start"))
+	}
+	return w, nil
+}
+
+// parseDays returns the days listed in v, separated by commas, each element being a day or a range of days,
+// like Mon-Fri. Ranges can wrap around the end of the week, like Fri-Mon.
+func parseDays(v string) (days [7]bool, err error) {
+	for _, e := range strings.Split(v, ",") {
+		first, last, isRange := strings.Cut(e, "-")
+		if !isRange {
+			last = first
+		}
+		f, err := parseDay(first)
+		if err != nil {
+			return days, err
+		}
+		l, err := parseDay(last)
+		if err != nil {
+			return days, err
+		}
+		for d := f; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == l {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseDay(v string) (int, error) {
+	for i, d := range weekdays {
+		if strings.EqualFold(v, d) {
+			return i, nil
+		}
+	}
+	return 0, errors.New(gotext.Get("unknown day %q, expecting one of %s", v, strings.Join(weekdays, ", ")))
+}
+
+// parseClock returns the minutes since midnight of v, formatted as HH:MM, up to 24:00.
+func parseClock(v string) (int, error) {
+	hh, mm, found := strings.Cut(v, ":")
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	if !found || len(hh) != 2 || len(mm) != 2 || errH != nil || errM != nil ||
+		h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return 0, errors.New(gotext.Get("invalid time %q, expecting HH:MM", v))
+	}
+	return h*60 + m, nil
+}
+
+// contains returns true if t, in its location, is within the window.
+// The window includes its start and excludes its end.
+func (w timeWindow) contains(t time.Time) bool {
+	minutes := t.Hour()*60 + t.Minute()
+	return w.days[t.Weekday()] && minutes >= w.start && minutes < w.end
+}
+
+// inTimeWindows returns true if t is within any of the windows.
+func inTimeWindows(windows []timeWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarEvents returns the systemd calendar events of the start and end of each window.
+func calendarEvents(windows []timeWindow) []string {
+	var events []string
+	seen := make(map[string]bool)
+	add := func(days [7]bool, minutes int) {
+		e := fmt.Sprintf("*-*-* %02d:%02d:00", minutes/60, minutes%60)
+		var names []string
+		for d, ok := range days {
+			if ok {
+				names = append(names, weekdays[d])
+			}
+		}
+		if len(names) != len(weekdays) {
+			e = strings.Join(names, ",") + " " + e
+		}
+		if seen[e] {
+			return
+		}
+		seen[e] = true
+		events = append(events, e)
+	}
+
+	for _, w := range windows {
+		add(w.days, w.start)
+		if w.end < 24*60 {
+			add(w.days, w.end)
+			continue
+		}
+		// A window ending at 24:00 ends on the next day, at midnight.
+		var next [7]bool
+		for d, ok := range w.days {
+			next[(d+1)%7] = ok
+		}
+		add(next, 0)
+	}
+	return events
+}
+
+// scheduleTimeWindows registers a systemd timer refreshing the privilege policy at the boundaries of the windows,
+// to grant or revoke client administrators.
+func (m *Manager) scheduleTimeWindows(ctx context.Context, windows []timeWindow) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't schedule client administrators time windows"))
+
+	if m.systemdCaller == nil {
+		log.Warning(ctx, gotext.Get("No systemd caller: client administrators time windows are only updated on policy refresh"))
+		return nil
+	}
+
+	var onCalendar []string
+	for _, e := range calendarEvents(windows) {
+		onCalendar = append(onCalendar, "OnCalendar="+e)
+	}
+	timer := fmt.Sprintf(timeWindowTimer, strings.Join(onCalendar, "\n"))
+
+	// nolint:gosec // G301 - systemd units directory is world-readable
+	if err := os.MkdirAll(m.unitDir(), 0755); err != nil {
+		return err
+	}
+	var changed bool
+	for name, content := range map[string]string{timeWindowUnitName + ".service": timeWindowService, timeWindowUnitName + ".timer": timer} {
+		//nolint:gosec // G306 - systemd units are world-readable.
+		written, err := fileutils.WriteIfChanged(filepath.Join(m.unitDir(), name), []byte(content), 0644)
+		if err != nil {
+			return err
+		}
+		changed = changed || written
+	}
+	if !changed {
+		return nil
+	}
+
+	log.Debugf(ctx, "Scheduling client administrators time windows on %s", strings.Join(calendarEvents(windows), ", "))
+	if err := m.systemdCaller.DaemonReload(ctx); err != nil {
+		return err
+	}
+	if err := m.systemdCaller.EnableUnit(ctx, timeWindowUnitName+".timer"); err != nil {
+		return err
+	}
+	if err := m.systemdCaller.StartUnit(ctx, timeWindowUnitName+".timer"); err != nil {
+		log.Warning(ctx, gotext.Get("failed to start unit %q: %v", timeWindowUnitName+".timer", err))
+	}
+	return nil
+}
+
+// removeTimeWindows stops and removes the client administrators time windows timer, if any.
+func (m *Manager) removeTimeWindows(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't remove client administrators time windows"))
+
+	timer := filepath.Join(m.unitDir(), timeWindowUnitName+".timer")
+	if _, err := os.Stat(timer); errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	log.Debug(ctx, "Removing client administrators time windows timer")
+	if m.systemdCaller != nil {
+		if err := m.systemdCaller.StopUnit(ctx, timeWindowUnitName+".timer"); err != nil {
+			log.Warning(ctx, gotext.Get("Failed to stop unit %q: %v", timeWindowUnitName+".timer", err))
+		}
+		if err := m.systemdCaller.DisableUnit(ctx, timeWindowUnitName+".timer"); err != nil {
+			return err
+		}
+	}
+	for _, p := range []string{timer, filepath.Join(m.unitDir(), timeWindowUnitName+".service")} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if m.systemdCaller == nil {
+		return nil
+	}
+	return m.systemdCaller.DaemonReload(ctx)
+}
+
+// unitDir returns the directory where the time windows systemd units are installed.
+func (m *Manager) unitDir() string {
+	if m.systemUnitDir == "" {
+		return consts.DefaultSystemUnitDir
+	}
+	return m.systemUnitDir
+}
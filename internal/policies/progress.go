@@ -0,0 +1,45 @@
+package policies
+
+import "context"
+
+// Policy update stages, as reported in the progress of an update.
+const (
+	// StageFetch is the stage of a policy update downloading the GPOs of the object.
+	StageFetch = "fetch"
+	// StageApply is the stage of a policy update running the policy managers.
+	StageApply = "apply"
+	// StageFlush is the stage of a policy update compiling the dconf databases, once all objects are applied.
+	StageFlush = "flush"
+)
+
+// Progress is the state of a policy update in progress.
+type Progress struct {
+	// Target is the object whose policies are updated, empty if the stage applies to all the updated objects.
+	Target string
+	Stage  string
+	// Manager is the policy manager which completed, during the apply stage.
+	Manager string
+	// Completed and Total are the number of policy managers which completed and which run, during the apply stage.
+	Completed int
+	Total     int
+}
+
+// ProgressReporter is called with the progress of the policy updates of its context.
+type ProgressReporter func(Progress)
+
+type progressReporterKey struct{}
+
+// WithProgressReporter returns a context whose policy updates report their progress to report.
+// report can be called concurrently when multiple objects are updated at once.
+func WithProgressReporter(ctx context.Context, report ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, report)
+}
+
+// ReportProgress reports p to the progress reporter of ctx, if any.
+func ReportProgress(ctx context.Context, p Progress) {
+	report, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	if !ok {
+		return
+	}
+	report(p)
+}
@@ -1,6 +1,7 @@
 // Package proxy provides a manager to apply system-wide proxy settings.
 //
-// The policy manager silently returns if there are no entries to apply.
+// The policy manager silently returns if there are no entries to apply, unless
+// settings were previously applied: they are then reverted.
 //
 // If there are entries and ubuntu-proxy-manager is not installed, it will log a
 // warning and return.
@@ -10,16 +11,47 @@
 //
 // Entry keys passed to the proxy manager that are not part of the supportedKeys
 // list will be ignored and logged as a warning.
+//
+// Each protocol proxy, the auto-configuration URL and the ignored hosts are
+// validated before being sent: any malformed value fails the whole policy, so
+// that the previously applied settings are kept.
+//
+// The settings applied by the manager are recorded in the state directory.
+// When the policy becomes empty, they are reverted by applying empty settings:
+// ubuntu-proxy-manager then removes its own configuration from each backend,
+// leaving any proxy configured locally outside of it untouched. Nothing is
+// reverted if the manager never applied any setting.
+//
+// An auto-configuration (PAC) URL configures backends in automatic mode. It
+// can't be combined with protocol proxies, as the manager won't guess which
+// one should prevail.
+//
+// Once applied by ubuntu-proxy-manager, the settings are also propagated to
+// snapd and flatpak, if they are installed, as their applications don't use the
+// system proxy settings. Only the HTTP, HTTPS and FTP proxies and the ignored
+// hosts are supported by them. The settings removed from the policy are removed
+// from them too. As the proxy URLs may contain credentials, they are never
+// passed on a command line: snapd is configured through its REST API and the
+// flatpak system-wide global overrides are written directly. Each of them is
+// only updated when its settings changed since the last time they were applied.
 package proxy
 
 import (
 	"context"
 	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/adsys/internal/policies/entry"
 	"github.com/ubuntu/decorate"
@@ -33,12 +65,26 @@ type Caller interface {
 // supportedKeys are the entry keys supported by the proxy manager.
 var supportedKeys = []string{"http", "https", "ftp", "socks", "no-proxy", "auto"}
 
+// manualKeys are the entry keys of the protocol proxies, exclusive with the auto-configuration URL.
+var manualKeys = []string{"http", "https", "ftp", "socks"}
+
+var (
+	// schemeRe matches a valid URL scheme.
+	schemeRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*$`)
+	// ignoredHostRe matches a host name, an IP address or network in CIDR notation, or a domain suffix with an
+	// optional wildcard.
+	ignoredHostRe = regexp.MustCompile(`^[A-Za-z0-9*._:\[\]-]+(/[0-9]{1,3})?$`)
+)
+
 // errDBusServiceUnknownName is the error name returned by D-Bus when the proxy manager service is not found.
 const errDBusServiceUnknownName = "org.freedesktop.DBus.Error.ServiceUnknown"
 
 // Manager prevents running multiple apparmor update processes in parallel while parsing policy in ApplyPolicy.
 type Manager struct {
 	proxyApplier Caller
+	stateDir     string
+	snapdSocket  string
+	flatpakDir   string
 }
 
 // WithProxyApplier overrides the default proxy applier.
@@ -48,11 +94,36 @@ func WithProxyApplier(c Caller) func(*options) {
 	}
 }
 
+// WithStateDir overrides the default state directory, where the applied proxy settings are recorded.
+func WithStateDir(p string) Option {
+	return func(a *options) {
+		a.stateDir = p
+	}
+}
+
+// WithSnapdSocket overrides the default snapd REST API socket, used to set the proxy settings of snapd.
+func WithSnapdSocket(p string) Option {
+	return func(a *options) {
+		a.snapdSocket = p
+	}
+}
+
+// WithFlatpakDir overrides the default flatpak system installation directory, where the proxy settings of flatpak
+// applications are set in the global overrides.
+func WithFlatpakDir(p string) Option {
+	return func(a *options) {
+		a.flatpakDir = p
+	}
+}
+
 type options struct {
 	proxyApplier Caller
+	stateDir     string
+	snapdSocket  string
+	flatpakDir   string
 }
 
-// Option reprents an optional function to change the proxy manager.
+// Option represents an optional function to change the proxy manager.
 type Option func(*options)
 
 // New returns a new proxy policy manager.
@@ -62,6 +133,9 @@ func New(bus *dbus.Conn, args ...Option) *Manager {
 	// Set default options
 	opts := options{
 		proxyApplier: proxyApplier,
+		stateDir:     consts.DefaultStateDir,
+		snapdSocket:  consts.DefaultSnapdSocket,
+		flatpakDir:   consts.DefaultFlatpakSystemDir,
 	}
 
 	// Apply given options
@@ -71,6 +145,9 @@ func New(bus *dbus.Conn, args ...Option) *Manager {
 
 	return &Manager{
 		proxyApplier: opts.proxyApplier,
+		stateDir:     opts.stateDir,
+		snapdSocket:  opts.snapdSocket,
+		flatpakDir:   opts.flatpakDir,
 	}
 }
 
@@ -83,9 +160,9 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		return nil
 	}
 
-	// Exit early if we don't have any entries to apply
+	// Exit early if we don't have any entries to apply, and nothing to revert
 	if len(entries) == 0 {
-		return nil
+		return m.revert(ctx, objectName)
 	}
 
 	args := make(map[string]string)
@@ -94,12 +171,66 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		if !slices.Contains(supportedKeys, key) {
 			log.Warning(ctx, gotext.Get("Encountered unsupported key '%s' while parsing proxy entries, skipping it", key))
 		}
+		if err := validateValue(key, e.Value); err != nil {
+			return errors.New(gotext.Get("invalid %s proxy setting %q: %v", key, redactCredentials(e.Value), err))
+		}
 		args[key] = e.Value
 	}
 
+	if args["auto"] != "" {
+		for _, key := range manualKeys {
+			if args[key] != "" {
+				return errors.New(gotext.Get("auto-configuration URL %q conflicts with %s proxy %q: only one of them can be set",
+					redactCredentials(args["auto"]), key, redactCredentials(args[key])))
+			}
+		}
+	}
+
+	previousKeys, err := m.appliedKeys()
+	if err != nil {
+		return err
+	}
+
 	// Idempotency is handled by the proxy manager service
 	log.Debugf(ctx, "Applying system proxy policy to %s", objectName)
 
+	applied, err := m.apply(ctx, args)
+	if err != nil || !applied {
+		return err
+	}
+
+	// The record is only updated once propagated, so that removed settings are removed again on failure.
+	if err := m.propagate(ctx, args, previousKeys); err != nil {
+		return err
+	}
+
+	return m.saveAppliedKeys(args)
+}
+
+// revert applies empty settings if the manager previously applied any, so that ubuntu-proxy-manager removes its
+// configuration from all backends, and removes them from snapd and flatpak.
+func (m *Manager) revert(ctx context.Context, objectName string) (err error) {
+	keys, err := m.appliedKeys()
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	log.Infof(ctx, "Reverting system proxy settings %v previously applied to %s", keys, objectName)
+	applied, err := m.apply(ctx, nil)
+	if err != nil || !applied {
+		return err
+	}
+
+	if err := m.propagate(ctx, nil, keys); err != nil {
+		return err
+	}
+
+	return m.saveAppliedKeys(nil)
+}
+
+// apply calls ubuntu-proxy-manager with the given settings.
+// applied is false if ubuntu-proxy-manager is not installed.
+func (m *Manager) apply(ctx context.Context, args map[string]string) (applied bool, err error) {
 	if err := m.proxyApplier.Call(
 		"com.ubuntu.ProxyManager.Apply",
 		dbus.FlagAllowInteractiveAuthorization,
@@ -112,10 +243,172 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		var dbusErr dbus.Error
 		if errors.As(err, &dbusErr) && dbusErr.Name == errDBusServiceUnknownName {
 			log.Warning(ctx, gotext.Get("Not applying proxy settings as ubuntu-proxy-manager is not installed: %s", dbusErr.Error()))
-			return nil
+			return false, nil
 		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// appliedKeysPath returns the path of the record of the applied proxy settings.
+func (m *Manager) appliedKeysPath() string {
+	return filepath.Join(m.stateDir, "proxy", "applied")
+}
+
+// appliedKeys returns the keys of the proxy settings which were last applied.
+func (m *Manager) appliedKeys() (keys []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read applied proxy settings"))
+
+	d, err := os.ReadFile(m.appliedKeysPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(d)), nil
+}
+
+// saveAppliedKeys records the keys of the non empty settings in args. The record is removed if there are none.
+func (m *Manager) saveAppliedKeys(args map[string]string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't record applied proxy settings"))
+
+	var keys []string
+	for _, key := range supportedKeys {
+		if args[key] != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	p := m.appliedKeysPath()
+	if len(keys) == 0 {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+".new", []byte(strings.Join(keys, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(p+".new", p)
+}
+
+// validateValue checks the syntax of the value of the proxy setting key.
+// Empty values, which remove the setting, are always valid.
+func validateValue(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	if strings.ContainsFunc(value, unicode.IsControl) {
+		return errors.New(gotext.Get("control characters are not allowed"))
+	}
+
+	switch key {
+	case "http", "https", "ftp", "socks":
+		return validateProxyURL(value)
+	case "auto":
+		return validateAutoConfigURL(value)
+	case "no-proxy":
+		return validateIgnoredHosts(value)
+	}
+	return nil
+}
+
+// redactCredentials returns value with the credentials of a proxy URL replaced, so that they don't end up in errors.
+// Like in validateProxyURL, credentials can contain unescaped special characters and end at the last @.
+func redactCredentials(value string) string {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok {
+		scheme, rest = "", value
+	} else {
+		scheme += "://"
+	}
+	i := strings.LastIndex(rest, "@")
+	if i == -1 {
+		return value
+	}
+	return scheme + "xxxxx" + rest[i:]
+}
+
+// validateProxyURL checks that value is of the form protocol://[username:password@]host[:port][/].
+// Special characters are allowed unescaped in the credentials, as ubuntu-proxy-manager escapes them.
+func validateProxyURL(value string) error {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok || !schemeRe.MatchString(scheme) {
+		return errors.New(gotext.Get("expected protocol://host[:port]"))
+	}
+
+	hostport := rest
+	if i := strings.LastIndex(rest, "@"); i != -1 {
+		hostport = rest[i+1:]
+	}
+	hostport, _, _ = strings.Cut(hostport, "/")
+	if strings.ContainsFunc(hostport, unicode.IsSpace) {
+		return errors.New(gotext.Get("spaces are not allowed in host"))
+	}
+
+	host := hostport
+	// Bracketed IPv6 addresses without port contain colons too
+	if strings.Contains(hostport, ":") && !(strings.HasPrefix(hostport, "[") && strings.HasSuffix(hostport, "]")) {
+		h, p, err := net.SplitHostPort(hostport)
+		if err != nil {
+			return err
+		}
+		if port, err := strconv.Atoi(p); err != nil || port < 1 || port > 65535 {
+			return errors.New(gotext.Get("invalid port %q", p))
+		}
+		host = h
+	}
+	if strings.Trim(host, "[]") == "" {
+		return errors.New(gotext.Get("missing host"))
+	}
+	return nil
+}
+
+// validateAutoConfigURL checks that value is a valid http, https or file URL.
+func validateAutoConfigURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
 		return err
 	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return errors.New(gotext.Get("missing host"))
+		}
+	case "file":
+		if u.Path == "" {
+			return errors.New(gotext.Get("missing path"))
+		}
+	default:
+		return errors.New(gotext.Get("unsupported protocol %q, expected http, https or file", u.Scheme))
+	}
+	return nil
+}
 
+// validateIgnoredHosts checks each host of the comma or space separated list value.
+// Hosts can be wrapped in single or double quotes.
+func validateIgnoredHosts(value string) error {
+	for _, h := range ignoredHosts(value) {
+		if !ignoredHostRe.MatchString(h) {
+			return errors.New(gotext.Get("invalid host %q", h))
+		}
+	}
 	return nil
 }
+
+// ignoredHosts returns the hosts of the comma or space separated list value, without their quotes.
+func ignoredHosts(value string) (hosts []string) {
+	for _, h := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		h = strings.Trim(h, `'"`)
+		if h == "" {
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
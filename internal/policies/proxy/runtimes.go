@@ -0,0 +1,370 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/audit"
+	"github.com/ubuntu/adsys/internal/fileutils"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+// snapdPollInterval is the interval between two checks of the completion of a snapd change.
+const snapdPollInterval = 100 * time.Millisecond
+
+// snapOptions are the snapd system options of each supported proxy setting, by entry key.
+// Snapd uses them for itself and the store.
+var snapOptions = map[string]string{
+	"http":     "proxy.http",
+	"https":    "proxy.https",
+	"ftp":      "proxy.ftp",
+	"no-proxy": "proxy.no-proxy",
+}
+
+// flatpakVariables are the environment variables of the flatpak global overrides of each supported proxy setting,
+// by entry key. Flatpak sets them in all of its applications.
+var flatpakVariables = map[string][]string{
+	"http":     {"http_proxy", "HTTP_PROXY"},
+	"https":    {"https_proxy", "HTTPS_PROXY"},
+	"ftp":      {"ftp_proxy", "FTP_PROXY"},
+	"no-proxy": {"no_proxy", "NO_PROXY"},
+}
+
+// propagate sets the proxy settings args in snapd and flatpak, if they are installed, and removes the ones of
+// previousKeys which are not set anymore. Neither supports SOCKS proxies nor auto-configuration URLs.
+// The settings are not passed to the snap and flatpak commands, whose arguments any user can list along with the
+// credentials of the proxy URLs: snapd is configured with its REST API and flatpak global overrides are written
+// directly. Each runtime is only updated if its settings changed, and all of them are updated, even if one fails.
+func (m *Manager) propagate(ctx context.Context, args map[string]string, previousKeys []string) error {
+	var errs []error
+	if err := m.propagateToSnap(ctx, args, previousKeys); err != nil {
+		errs = append(errs, err)
+	}
+	if err := m.propagateToFlatpak(ctx, args, previousKeys); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// propagateToSnap sets the snapd system options of args, and unsets the ones of previousKeys which are not set
+// anymore, unless they are the same as the last ones set.
+func (m *Manager) propagateToSnap(ctx context.Context, args map[string]string, previousKeys []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply proxy settings to snap applications"))
+
+	// A nil value unsets the option.
+	conf := make(map[string]any)
+	for _, key := range supportedKeys {
+		option, ok := snapOptions[key]
+		if !ok {
+			continue
+		}
+		if value := runtimeValue(key, args[key]); value != "" {
+			conf[option] = value
+		} else if slices.Contains(previousKeys, key) {
+			conf[option] = nil
+		}
+	}
+	if len(conf) == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(m.snapdSocket); errors.Is(err, fs.ErrNotExist) {
+		log.Debug(ctx, "snapd is not installed, not applying proxy settings to snap applications")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(body)
+	recordPath := filepath.Join(m.stateDir, "proxy", "snap")
+	if recorded, err := os.ReadFile(recordPath); err == nil && string(recorded) == hex.EncodeToString(hash[:]) {
+		log.Debug(ctx, "Proxy settings of snap applications are unchanged")
+		return nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	log.Debug(ctx, "Applying proxy settings to snap applications")
+	if err := setSnapdSystemConf(ctx, m.snapdSocket, body); err != nil {
+		return err
+	}
+
+	// Only a hash is recorded, as the settings contain credentials.
+	if err := os.MkdirAll(filepath.Dir(recordPath), 0700); err != nil {
+		return err
+	}
+	_, err = fileutils.WriteIfChanged(recordPath, []byte(hex.EncodeToString(hash[:])), 0600)
+	return err
+}
+
+// snapdResponse is a response of the snapd REST API.
+type snapdResponse struct {
+	Type   string          `json:"type"`
+	Change string          `json:"change"`
+	Result json.RawMessage `json:"result"`
+}
+
+// setSnapdSystemConf sets the snapd system options of conf, a JSON object, with the snapd REST API listening on
+// socket. It waits for the change to complete.
+func setSnapdSystemConf(ctx context.Context, socket string, conf []byte) error {
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	r, err := snapdRequest(ctx, client, http.MethodPut, "/v2/snaps/system/conf", conf)
+	if err != nil {
+		return err
+	}
+	if r.Type != "async" {
+		return nil
+	}
+
+	for {
+		r, err := snapdRequest(ctx, client, http.MethodGet, "/v2/changes/"+url.PathEscape(r.Change), nil)
+		if err != nil {
+			return err
+		}
+		var change struct {
+			Status string `json:"status"`
+			Ready  bool   `json:"ready"`
+			Err    string `json:"err"`
+		}
+		if err := json.Unmarshal(r.Result, &change); err != nil {
+			return err
+		}
+		if change.Ready {
+			if change.Err != "" {
+				return errors.New(change.Err)
+			}
+			if change.Status != "Done" {
+				return errors.New(gotext.Get("snapd change completed with status %s", change.Status))
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(snapdPollInterval):
+		}
+	}
+}
+
+// snapdRequest sends a request with body, if any, to path of the snapd REST API.
+// Error responses are returned as errors.
+func snapdRequest(ctx context.Context, client *http.Client, method, path string, body []byte) (r snapdResponse, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+path, bytes.NewReader(body))
+	if err != nil {
+		return snapdResponse{}, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return snapdResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return snapdResponse{}, errors.New(gotext.Get("invalid snapd response with status %q: %v", resp.Status, err))
+	}
+	if r.Type == "error" {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(r.Result, &e); err != nil || e.Message == "" {
+			return snapdResponse{}, errors.New(gotext.Get("snapd request failed with status %q", resp.Status))
+		}
+		return snapdResponse{}, errors.New(e.Message)
+	}
+	return r, nil
+}
+
+// propagateToFlatpak sets the environment variables of args in the flatpak global overrides, and removes the ones of
+// previousKeys which are not set anymore. Other overrides are kept as is.
+func (m *Manager) propagateToFlatpak(ctx context.Context, args map[string]string, previousKeys []string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply proxy settings to flatpak applications"))
+
+	// An empty value removes the variable.
+	var overrides [][2]string
+	for _, key := range supportedKeys {
+		value := runtimeValue(key, args[key])
+		if value == "" && !slices.Contains(previousKeys, key) {
+			continue
+		}
+		for _, v := range flatpakVariables[key] {
+			overrides = append(overrides, [2]string{v, value})
+		}
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(m.flatpakDir); errors.Is(err, fs.ErrNotExist) {
+		log.Debug(ctx, "flatpak is not installed, not applying proxy settings to flatpak applications")
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	p := filepath.Join(m.flatpakDir, "overrides", "global")
+	content, err := os.ReadFile(p)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	newContent := withEnvironment(string(content), overrides)
+	if newContent == "" {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	// Overrides must be readable by the users running the applications.
+	// #nosec G301
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	//nolint:gosec // G306 - Overrides must be readable by the users running the applications.
+	changed, err := fileutils.WriteIfChanged(p, []byte(newContent), 0644)
+	if err != nil {
+		return err
+	}
+	if changed {
+		log.Debug(ctx, "Applied proxy settings to flatpak applications")
+		audit.RecordFile(ctx, p)
+	} else {
+		log.Debug(ctx, "Proxy settings of flatpak applications are unchanged")
+	}
+	return nil
+}
+
+// withEnvironment returns content, a flatpak overrides keyfile, with each variable of overrides set to its value in
+// the Environment section, or removed from it if the value is empty. Other sections and keys are kept as is.
+// The section is added if missing, and removed once empty. An empty string is returned if nothing is left.
+func withEnvironment(content string, overrides [][2]string) string {
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	isOverridden := func(l string) bool {
+		k, _, ok := strings.Cut(l, "=")
+		return ok && slices.ContainsFunc(overrides, func(o [2]string) bool { return o[0] == strings.TrimSpace(k) })
+	}
+
+	var set []string
+	for _, o := range overrides {
+		if o[1] != "" {
+			set = append(set, o[0]+"="+strings.ReplaceAll(o[1], `\`, `\\`))
+		}
+	}
+
+	// Rewrite overridden variables at the end of the section.
+	var out []string
+	section, sectionEnd := -1, -1
+	inSection := false
+	for _, l := range lines {
+		if t := strings.TrimSpace(l); strings.HasPrefix(t, "[") {
+			inSection = t == "[Environment]" && section == -1
+			if inSection {
+				section = len(out)
+				sectionEnd = len(out) + 1
+			}
+		} else if inSection {
+			if isOverridden(l) {
+				continue
+			}
+			if t != "" {
+				sectionEnd = len(out) + 1
+			}
+		}
+		out = append(out, l)
+	}
+	if section == -1 {
+		if len(set) == 0 {
+			return content
+		}
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		section, sectionEnd = len(out), len(out)+1
+		out = append(out, "[Environment]")
+	}
+	out = slices.Insert(out, sectionEnd, set...)
+	sectionEnd += len(set)
+
+	// Remove the section once it has no key left, with the blank lines before the next one.
+	if sectionEnd == section+1 {
+		end := sectionEnd
+		for end < len(out) && strings.TrimSpace(out[end]) == "" {
+			end++
+		}
+		out = slices.Delete(out, section, end)
+	}
+
+	for len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// runtimeValue returns value of the proxy setting key in the form expected by the runtimes: credentials in proxy
+// URLs are escaped, and ignored hosts are separated by commas.
+func runtimeValue(key, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	switch key {
+	case "http", "https", "ftp":
+		return escapeCredentials(value)
+	case "no-proxy":
+		return strings.Join(ignoredHosts(value), ",")
+	}
+	return value
+}
+
+// escapeCredentials escapes the special characters of the credentials in the proxy URL value, which are allowed
+// unescaped in the settings.
+func escapeCredentials(value string) string {
+	scheme, rest, _ := strings.Cut(value, "://")
+	i := strings.LastIndex(rest, "@")
+	if i == -1 {
+		return value
+	}
+
+	user := url.User(rest[:i])
+	if username, password, ok := strings.Cut(rest[:i], ":"); ok {
+		user = url.UserPassword(username, password)
+	}
+	return fmt.Sprintf("%s://%s@%s", scheme, user.String(), rest[i+1:])
+}
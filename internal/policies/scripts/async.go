@@ -0,0 +1,209 @@
+package scripts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/fileutils"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	machineAsyncLifecycle = "startup-async"
+	userAsyncLifecycle    = "logon-async"
+
+	asyncStatusExt = ".status"
+)
+
+// asyncScriptsUnit runs the asynchronous scripts of a user or the machine, listed in their order file.
+// The unit is only started by adsys, once the policy is applied.
+const asyncScriptsUnit = fileutils.ManagedHeader + `
+[Unit]
+Description=ADSys %s scripts execution in the background
+ConditionPathExists=%s
+
+[Service]
+Type=oneshot
+ExecStart=/sbin/adsysd runscripts %s
+`
+
+// AsyncStatus is the state of the last run of the asynchronous scripts of a user or the machine.
+type AsyncStatus struct {
+	Started time.Time `json:"started"`
+	// Finished is unset while the scripts are running.
+	Finished *time.Time         `json:"finished,omitempty"`
+	Scripts  []AsyncScriptState `json:"scripts"`
+}
+
+// AsyncScriptState is the exit status of an asynchronous script which completed.
+type AsyncScriptState struct {
+	Name string `json:"name"`
+	// ExitCode is -1 if the script couldn't run or was terminated.
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// asyncLifecycle returns the lifecycle of the asynchronous scripts of a user or the machine.
+func asyncLifecycle(isComputer bool) string {
+	if isComputer {
+		return machineAsyncLifecycle
+	}
+	return userAsyncLifecycle
+}
+
+// isAsyncLifecycle returns if the scripts of lifecycle run in the background.
+func isAsyncLifecycle(lifecycle string) bool {
+	return lifecycle == machineAsyncLifecycle || lifecycle == userAsyncLifecycle
+}
+
+// asyncUnitName returns the name of the service running the asynchronous scripts of the machine or of the user with uid.
+func asyncUnitName(isComputer bool, uid string) string {
+	if isComputer {
+		return "adsys-machine-async-scripts.service"
+	}
+	return fmt.Sprintf("adsys-user-async-scripts-%s.service", uid)
+}
+
+// HasAsyncScripts returns if entries run asynchronous scripts for a user or the machine.
+func HasAsyncScripts(entries []entry.Entry, isComputer bool) bool {
+	for _, e := range entries {
+		if filepath.Base(e.Key) == asyncLifecycle(isComputer) && !e.Disabled && strings.TrimSpace(e.Value) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAsyncScripts generates the service running the asynchronous scripts of the machine or of the user with uid,
+// listed in scriptsPath, and starts it without waiting for the scripts to complete.
+// The service is removed if there is no asynchronous script to run.
+func (m *Manager) applyAsyncScripts(ctx context.Context, isComputer bool, uid, scriptsPath string, hasScripts bool) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply asynchronous scripts"))
+
+	unit := asyncUnitName(isComputer, uid)
+	unitPath := filepath.Join(m.systemUnitDir, unit)
+	if !hasScripts {
+		if err := os.Remove(unitPath); errors.Is(err, os.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		return m.systemdCaller.DaemonReload(ctx)
+	}
+
+	lifecycle := asyncLifecycle(isComputer)
+	order := filepath.Join(scriptsPath, lifecycle)
+	log.Debugf(ctx, "Writing %q to run %s scripts", unitPath, lifecycle)
+	changed, err := writeUnitFile(unitPath, fmt.Sprintf(asyncScriptsUnit, strings.TrimSuffix(lifecycle, "-async"), order, order))
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := m.systemdCaller.DaemonReload(ctx); err != nil {
+			return err
+		}
+	}
+
+	log.Infof(ctx, "Running %s scripts in the background", lifecycle)
+	go func() {
+		// The scripts outlive the policy application: don’t tie them to its context.
+		if err := m.systemdCaller.StartUnit(context.Background(), unit); err != nil {
+			log.Warningf(context.Background(), "%s scripts failed: %v", lifecycle, err)
+		}
+	}()
+	return nil
+}
+
+// AsyncStatus returns the state of the last run of asynchronous scripts for objectName.
+// It returns nil if those never ran.
+func (m *Manager) AsyncStatus(ctx context.Context, objectName string, isComputer bool) (s *AsyncStatus, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get asynchronous scripts status for %s", objectName))
+
+	log.Debugf(ctx, "Getting asynchronous scripts status for %s", objectName)
+
+	var uid string
+	if !isComputer {
+		user, err := m.userLookup(objectName)
+		if err != nil {
+			return nil, errors.New(gotext.Get("couldn't retrieve user for %q: %v", objectName, err))
+		}
+		uid = user.Uid
+	}
+
+	d, err := os.ReadFile(filepath.Join(objectLogsDir(m.stateDir, isComputer, uid), asyncLifecycle(isComputer)+asyncStatusExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s = &AsyncStatus{}
+	if err := json.Unmarshal(d, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// asyncTracker records the state of a run of asynchronous scripts in a status file.
+// A nil asyncTracker doesn’t record anything, for the other lifecycles.
+type asyncTracker struct {
+	path   string
+	status AsyncStatus
+}
+
+// newAsyncTracker starts recording the run of asynchronous scripts in path.
+func newAsyncTracker(ctx context.Context, path string) *asyncTracker {
+	t := &asyncTracker{path: path, status: AsyncStatus{Started: time.Now(), Scripts: []AsyncScriptState{}}}
+	t.save(ctx)
+	return t
+}
+
+// scriptDone records the exit status of script, which returned err.
+func (t *asyncTracker) scriptDone(ctx context.Context, script string, err error) {
+	if t == nil {
+		return
+	}
+
+	s := AsyncScriptState{Name: script}
+	if err != nil {
+		s.ExitCode, s.Error = -1, err.Error()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			s.ExitCode = exitErr.ExitCode()
+		}
+	}
+	t.status.Scripts = append(t.status.Scripts, s)
+	t.save(ctx)
+}
+
+// finish records the end of the run.
+func (t *asyncTracker) finish(ctx context.Context) {
+	if t == nil {
+		return
+	}
+
+	now := time.Now()
+	t.status.Finished = &now
+	t.save(ctx)
+}
+
+// save writes the current state of the run. Failing to do so doesn’t prevent the scripts from running.
+func (t *asyncTracker) save(ctx context.Context) {
+	d, err := json.Marshal(t.status)
+	if err == nil {
+		err = os.WriteFile(t.path, d, 0600)
+	}
+	if err != nil {
+		log.Warningf(ctx, "Can't save asynchronous scripts status: %v", err)
+	}
+}
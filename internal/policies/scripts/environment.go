@@ -0,0 +1,107 @@
+package scripts
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+)
+
+const environmentFile = ".environment"
+
+// Environment variables describing the context the scripts policy was applied in. They are set for every script, with
+// the same meaning for the machine and user ones.
+const (
+	// envDomain is the AD domain the machine is joined to.
+	envDomain = "ADSYS_DOMAIN"
+	// envComputer is the name of the machine, without its domain.
+	envComputer = "ADSYS_COMPUTER"
+	// envScope is "machine" or "user", depending on the policy the scripts are from.
+	envScope = "ADSYS_SCOPE"
+	// envGPONames are the names of the GPOs applied to the machine or user, separated by commas, from the highest
+	// precedence one to the lowest.
+	envGPONames = "ADSYS_GPO_NAMES"
+)
+
+// environmentKeys are the variables which can be read from the environment file. Any other one is ignored, so that
+// the environment file can't override what the scripts run with, like their PATH.
+var environmentKeys = []string{envDomain, envComputer, envScope, envGPONames}
+
+// applyEnvironment returns the environment variables describing the policy applied to objectName, the machine or a
+// user, from the GPOs named gpoNames.
+// Only the machine and domain names are exposed, never any credentials.
+func (m *Manager) applyEnvironment(objectName string, isComputer bool, gpoNames []string) []string {
+	scope := runAsUser
+	computer := m.hostname
+	if isComputer {
+		scope = runAsMachine
+		// The machine policy is applied to the machine object itself.
+		computer = objectName
+	}
+
+	// Each variable is stored on a single line.
+	names := make([]string, 0, len(gpoNames))
+	for _, n := range gpoNames {
+		names = append(names, strings.Join(strings.Fields(n), " "))
+	}
+
+	return []string{
+		envDomain + "=" + m.domain,
+		envComputer + "=" + computer,
+		envScope + "=" + scope,
+		envGPONames + "=" + strings.Join(names, ","),
+	}
+}
+
+// readEnvironment returns the variables stored in the environment file p.
+// Nothing is returned if the file doesn’t exist, and invalid or unknown variables are skipped.
+func readEnvironment(ctx context.Context, p string) (env []string) {
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		log.Warningf(ctx, "Can't read scripts environment, running scripts without it: %v", err)
+		return nil
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		k, _, found := strings.Cut(line, "=")
+		if !found || !slices.Contains(environmentKeys, k) {
+			log.Warningf(ctx, "Ignoring invalid scripts environment variable %q", line)
+			continue
+		}
+		env = append(env, line)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Warningf(ctx, "Can't read scripts environment, running scripts without it: %v", err)
+		return nil
+	}
+
+	return env
+}
+
+// withEnvironment returns env with the variables of applyEnv, replacing any previous value. A nil env stands for the
+// environment of the current process.
+func withEnvironment(env, applyEnv []string) []string {
+	if len(applyEnv) == 0 {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	env = slices.DeleteFunc(slices.Clone(env), func(e string) bool {
+		k, _, _ := strings.Cut(e, "=")
+		return slices.Contains(environmentKeys, k)
+	})
+	return append(env, applyEnv...)
+}
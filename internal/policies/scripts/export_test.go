@@ -1,7 +1,11 @@
 package scripts
 
 import (
+	"context"
+	"io"
 	"os/user"
+	"syscall"
+	"time"
 )
 
 const (
@@ -14,3 +18,31 @@ func WithUserLookup(userLookup func(string) (*user.User, error)) Option {
 		o.userLookup = userLookup
 	}
 }
+
+// WithKillGracePeriod allows to shorten the time given to timed out scripts to exit before being killed.
+func WithKillGracePeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.killGracePeriod = d
+	}
+}
+
+// WithScriptRunner allows to record scripts execution instead of running them.
+func WithScriptRunner(runner func(ctx context.Context, script string, cred *syscall.Credential, env []string, output io.Writer, timeout, killGracePeriod time.Duration) error) Option {
+	return func(o *options) {
+		o.runner = runner
+	}
+}
+
+// WithLogsKept allows to change the number of runs for which scripts output is kept.
+func WithLogsKept(n int) Option {
+	return func(o *options) {
+		o.logsKept = n
+	}
+}
+
+// WithUserLookupID allows to mock system user lookup by uid.
+func WithUserLookupID(userLookupID func(string) (*user.User, error)) Option {
+	return func(o *options) {
+		o.userLookupID = userLookupID
+	}
+}
@@ -0,0 +1,136 @@
+package scripts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/consts"
+	"github.com/ubuntu/adsys/internal/fileutils"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	shutdownGracePeriodKey = "shutdown-scripts-grace-period"
+	logoffGracePeriodKey   = "logoff-scripts-grace-period"
+
+	gracePeriodDropIn = "50-adsys-grace-period.conf"
+)
+
+// shutdownGracePeriodUnit lets machine shutdown scripts complete before the system goes down.
+// Units are stopped in the reverse order they are started: ordering the scripts service after the network and
+// remote file systems makes shutdown scripts run while those are still available.
+const shutdownGracePeriodUnit = fileutils.ManagedHeader + `
+[Unit]
+After=network.target remote-fs.target
+
+[Service]
+TimeoutStopSec=%d
+`
+
+// logoffGracePeriodUnit lets user logoff scripts complete before the user manager stops.
+// User units have no system service to be ordered against: only their stop timeout is set.
+const logoffGracePeriodUnit = fileutils.ManagedHeader + `
+[Service]
+TimeoutStopSec=%d
+`
+
+// isGracePeriodKey returns if key sets the grace period of a stopping scripts service.
+func isGracePeriodKey(key string) bool {
+	return key == shutdownGracePeriodKey || key == logoffGracePeriodKey
+}
+
+// applyGracePeriods configures how long systemd waits for the shutdown and logoff scripts to complete, from the
+// machine entries. Scripts services without grace period keep the default systemd behavior.
+func (m *Manager) applyGracePeriods(ctx context.Context, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply scripts grace periods"))
+
+	gracePeriods := make(map[string]int)
+	for _, e := range entries {
+		key := filepath.Base(e.Key)
+		if !isGracePeriodKey(key) || e.Disabled {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(e.Value))
+		if err != nil || seconds < 0 {
+			return errors.New(gotext.Get("invalid grace period %q for %s: expected a number of seconds", e.Value, key))
+		}
+		if seconds == 0 {
+			continue
+		}
+		gracePeriods[key] = seconds
+	}
+
+	dropIns := []struct {
+		key     string
+		path    string
+		content string
+	}{
+		{
+			key:     shutdownGracePeriodKey,
+			path:    filepath.Join(m.systemUnitDir, consts.AdysMachineScriptsServiceName+".d", gracePeriodDropIn),
+			content: shutdownGracePeriodUnit,
+		},
+		{
+			key:     logoffGracePeriodKey,
+			path:    filepath.Join(m.userUnitDir, consts.AdsysUserScriptsServiceName+".d", gracePeriodDropIn),
+			content: logoffGracePeriodUnit,
+		},
+	}
+
+	var machineChanged bool
+	for _, d := range dropIns {
+		seconds, ok := gracePeriods[d.key]
+		var changed bool
+		if ok {
+			log.Debugf(ctx, "Allowing %ds to %s scripts: writing %q", seconds, d.key, d.path)
+			changed, err = writeUnitFile(d.path, fmt.Sprintf(d.content, seconds))
+		} else {
+			changed, err = removeDropIn(d.path)
+		}
+		if err != nil {
+			return err
+		}
+		if changed && d.key == shutdownGracePeriodKey {
+			machineChanged = true
+		}
+	}
+
+	// User managers load the logoff scripts drop-in on next start.
+	if !machineChanged {
+		return nil
+	}
+	return m.systemdCaller.DaemonReload(ctx)
+}
+
+// writeUnitFile writes content to the unit or drop-in path, creating its directory. It returns true if the file changed.
+func writeUnitFile(path, content string) (changed bool, err error) {
+	// nolint:gosec // G301 - systemd units directories are world-readable
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	// nolint:gosec // G306 - systemd units are world-readable
+	return fileutils.WriteIfChanged(path, []byte(content), 0644)
+}
+
+// removeDropIn removes path and its drop-in directory if it is then empty. It returns true if the file existed.
+func removeDropIn(path string) (changed bool, err error) {
+	if err := os.Remove(path); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	// Only remove the drop-in directory if we were the last one using it.
+	if err := os.Remove(filepath.Dir(path)); err != nil && !errors.Is(err, syscall.ENOTEMPTY) && !errors.Is(err, syscall.EEXIST) {
+		return true, err
+	}
+	return true, nil
+}
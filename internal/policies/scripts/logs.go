@@ -0,0 +1,226 @@
+package scripts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	logsDir = "scripts-logs"
+
+	// defaultLogsKept is the number of runs for which scripts output is kept, per machine or user.
+	defaultLogsKept = 10
+
+	// logTimeFormat names log files so that their lexical order is the order of the runs.
+	logTimeFormat = "20060102T150405.000000000Z"
+
+	redacted = "***"
+)
+
+// secretEnvNames matches the environment variables whose values are redacted from scripts logs.
+var secretEnvNames = regexp.MustCompile(`(?i)(pass|secret|token|credential|private|_key$|^key$)`)
+
+// objectLogsDir returns the directory storing the scripts logs of the machine or of the user with uid.
+func objectLogsDir(stateDir string, isComputer bool, uid string) string {
+	if isComputer {
+		return filepath.Join(stateDir, logsDir, "machine")
+	}
+	return filepath.Join(stateDir, logsDir, "users", uid)
+}
+
+// createUserLogsDir creates the scripts logs directory of a user, so that their scripts can write into it.
+func createUserLogsDir(p string, uid, gid int) error {
+	//nolint:gosec // G301 - users need to traverse to their own subdirectory.
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(p, 0700); err != nil {
+		return err
+	}
+	return chown(p, nil, uid, gid)
+}
+
+// scriptsLogsDir returns the directory storing the scripts logs for the scripts directory baseDir, of the form
+// <runDir>/machine/scripts or <runDir>/users/<uid>/scripts.
+func scriptsLogsDir(stateDir, baseDir string) string {
+	objectDir := filepath.Dir(baseDir)
+	if filepath.Base(filepath.Dir(objectDir)) == "users" {
+		return objectLogsDir(stateDir, false, filepath.Base(objectDir))
+	}
+	return objectLogsDir(stateDir, true, "")
+}
+
+// createRunLogFile creates the log file of a run of lifecycle scripts in dir, and only keeps the logs of the last keep runs.
+func createRunLogFile(ctx context.Context, dir, lifecycle string, keep int) (f *os.File, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't create scripts log in %s", dir))
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	p := filepath.Join(dir, fmt.Sprintf("%s-%s.log", time.Now().UTC().Format(logTimeFormat), lifecycle))
+	f, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := listLogs(dir)
+	if err != nil {
+		log.Warningf(ctx, "Can't rotate scripts logs: %v", err)
+		return f, nil
+	}
+	for len(logs) > keep {
+		if err := os.Remove(filepath.Join(dir, logs[0])); err != nil {
+			log.Warningf(ctx, "Can't remove old scripts log: %v", err)
+		}
+		logs = logs[1:]
+	}
+
+	return f, nil
+}
+
+// listLogs returns the scripts log files in dir, from the oldest to the most recent run.
+func listLogs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var logs []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".log" {
+			continue
+		}
+		logs = append(logs, e.Name())
+	}
+	slices.Sort(logs)
+	return logs, nil
+}
+
+// Logs returns the output of the scripts of the last count runs for objectName, from the oldest to the most recent.
+func (m *Manager) Logs(ctx context.Context, objectName string, isComputer bool, count int) (msg string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't get scripts logs for %s", objectName))
+
+	log.Debugf(ctx, "Getting scripts logs for %s", objectName)
+
+	var uid string
+	if !isComputer {
+		user, err := m.userLookup(objectName)
+		if err != nil {
+			return "", errors.New(gotext.Get("couldn't retrieve user for %q: %v", objectName, err))
+		}
+		uid = user.Uid
+	}
+	dir := objectLogsDir(m.stateDir, isComputer, uid)
+
+	logs, err := listLogs(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if len(logs) == 0 {
+		return gotext.Get("No scripts logs for %s.", objectName) + "\n", nil
+	}
+	if count > 0 && len(logs) > count {
+		logs = logs[len(logs)-count:]
+	}
+
+	var out strings.Builder
+	for _, l := range logs {
+		d, err := os.ReadFile(filepath.Join(dir, l))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&out, "%s\n%s", gotext.Get("Scripts log %s:", strings.TrimSuffix(l, ".log")), d)
+	}
+	return out.String(), nil
+}
+
+// runLog writes the output of the scripts of a run to a log file, redacting secrets.
+// It is safe for concurrent use, so that it can collect both stdout and stderr.
+type runLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets []string
+	buf     []byte
+}
+
+// newRunLog returns a runLog writing to w, redacting the values of secret variables in env.
+func newRunLog(w io.Writer, env []string) *runLog {
+	var secrets []string
+	for _, e := range env {
+		k, v, ok := strings.Cut(e, "=")
+		// Short values would redact unrelated content.
+		if !ok || len(v) < 4 || !secretEnvNames.MatchString(k) {
+			continue
+		}
+		secrets = append(secrets, v)
+	}
+	return &runLog{w: w, secrets: secrets}
+}
+
+// Write buffers p and writes each complete line once redacted.
+func (l *runLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, p...)
+	i := bytes.LastIndexByte(l.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	if err := l.write(l.buf[:i+1]); err != nil {
+		return 0, err
+	}
+	l.buf = l.buf[i+1:]
+	return len(p), nil
+}
+
+// startScript flushes any pending output and writes the header of script.
+func (l *runLog) startScript(script string) error {
+	return l.writeLine(fmt.Sprintf("== %s ==", script))
+}
+
+// endScript flushes any pending output of script and writes its result.
+func (l *runLog) endScript(script string, err error) error {
+	result := "succeeded"
+	if err != nil {
+		result = err.Error()
+	}
+	return l.writeLine(fmt.Sprintf("== %s: %s ==", script, result))
+}
+
+// writeLine flushes any pending output and writes line.
+func (l *runLog) writeLine(line string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buf) > 0 {
+		l.buf = append(l.buf, '\n')
+		if err := l.write(l.buf); err != nil {
+			return err
+		}
+		l.buf = nil
+	}
+	return l.write([]byte(line + "\n"))
+}
+
+func (l *runLog) write(p []byte) error {
+	s := string(p)
+	for _, secret := range l.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	_, err := io.WriteString(l.w, s)
+	return err
+}
@@ -11,19 +11,44 @@
 // authentication will be prevented. ADSys ensures that the scripts will be executed at the correct
 // time and in the correct order, but it does not account for the correctness of the scripts.
 // If a script returns an error, it will be logged, but authentication will not be prevented.
+// Scripts whose name is prefixed with a numeric order key, like 10-mount.sh, run first, by increasing
+// order. Ties are broken by name, so that the same script can be listed multiple times, but two different
+// scripts declaring the same order are a conflict and fail the policy. Other scripts run afterwards, in
+// the order they are listed.
+// A timeout can be set for machine and user scripts: every script running longer than it is terminated,
+// along with the processes it spawned, and the failure is logged. Scripts run unbounded otherwise.
+// Machine policies can also grant shutdown and logoff scripts a grace period, blocking the shutdown or session
+// closing until they complete, up to that window. This is done with systemd drop-ins on the scripts services.
+// Startup and logon scripts can also run asynchronously, in the background: the manager generates a oneshot systemd
+// service for them, started and not waited on during the policy application. Their output is saved with the other
+// scripts logs and the exit status of their last run is reported in the policies status.
+// Each script can declare the context it runs in by prefixing its name with "user:" or "machine:". User scripts
+// are executed with the uid, gid and environment of the user, while machine scripts run as root. A script
+// declaring a context which doesn't match its policy is rejected, in particular machine scripts can't be
+// declared in user policies.
+// Every script runs with environment variables describing the context its policy was applied in: ADSYS_DOMAIN,
+// ADSYS_COMPUTER, ADSYS_SCOPE and ADSYS_GPO_NAMES.
+// The combined output of each run of scripts is saved in the state directory, only readable by the machine or user
+// running them, with the values of secret environment variables redacted. Only the last runs are kept.
 package scripts
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/user"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/leonelquinteros/gotext"
 	"github.com/ubuntu/adsys/internal/consts"
@@ -35,35 +60,112 @@ import (
 const (
 	inSessionFlag = ".running"
 	readyFlag     = ".ready"
+	timeoutFile   = ".timeout"
 	executableDir = "scripts"
+
+	// defaultKillGracePeriod is how long a timed out script has to exit once terminated before being killed.
+	defaultKillGracePeriod = 5 * time.Second
+)
+
+// orderPrefix matches the order key prefixing a script name, like 10 in 10-mount.sh.
+var orderPrefix = regexp.MustCompile(`^(\d+)-`)
+
+// Contexts a script can be run in.
+const (
+	runAsUser    = "user"
+	runAsMachine = "machine"
 )
 
+// timeoutKeys are the policy keys setting the maximum duration, in seconds, of each script.
+var timeoutKeys = map[string]struct{}{
+	"machine-scripts-timeout": {},
+	"user-scripts-timeout":    {},
+}
+
 // Manager prevents running multiple scripts update process in parallel while parsing policy in ApplyPolicy.
 type Manager struct {
-	runDir      string
-	unitStarter unitStarter
+	runDir        string
+	stateDir      string
+	systemUnitDir string
+	userUnitDir   string
+	systemdCaller systemdCaller
+	hostname      string
+	domain        string
 
 	userLookup func(string) (*user.User, error)
 }
 
-type unitStarter interface {
+type systemdCaller interface {
 	StartUnit(context.Context, string) error
+	DaemonReload(context.Context) error
 }
 
 type options struct {
-	userLookup func(string) (*user.User, error)
+	stateDir        string
+	systemUnitDir   string
+	userUnitDir     string
+	hostname        string
+	domain          string
+	userLookup      func(string) (*user.User, error)
+	killGracePeriod time.Duration
+	logsKept        int
+	userLookupID    func(string) (*user.User, error)
+	runner          scriptRunner
 }
 
-// Option reprents an optional function to change scripts manager.
+// scriptRunner executes a script with the credentials and environment of cred and env, copying its output to output
+// and terminating it if it runs longer than a non zero timeout.
+// A nil cred runs the script as the current process, with its environment.
+type scriptRunner func(ctx context.Context, script string, cred *syscall.Credential, env []string, output io.Writer, timeout, killGracePeriod time.Duration) error
+
+// Option represents an optional function to change scripts manager.
 type Option func(*options)
 
+// WithStateDir overrides the default state directory, where scripts output is saved.
+func WithStateDir(p string) Option {
+	return func(o *options) {
+		o.stateDir = p
+	}
+}
+
+// WithSystemUnitDir overrides the default directory of systemd system units.
+func WithSystemUnitDir(p string) Option {
+	return func(o *options) {
+		o.systemUnitDir = p
+	}
+}
+
+// WithUserUnitDir overrides the default directory of systemd user units.
+func WithUserUnitDir(p string) Option {
+	return func(o *options) {
+		o.userUnitDir = p
+	}
+}
+
+// WithHostname sets the machine name exposed to the scripts.
+func WithHostname(hostname string) Option {
+	return func(o *options) {
+		o.hostname = hostname
+	}
+}
+
+// WithDomain sets the AD domain name exposed to the scripts.
+func WithDomain(domain string) Option {
+	return func(o *options) {
+		o.domain = domain
+	}
+}
+
 // New creates a manager with a specific scripts directory.
-func New(runDir string, unitStarter unitStarter, opts ...Option) (m *Manager, err error) {
+func New(runDir string, systemdCaller systemdCaller, opts ...Option) (m *Manager, err error) {
 	defer decorate.OnError(&err, gotext.Get("can't create scripts manager"))
 
 	// defaults
 	args := options{
-		userLookup: user.Lookup,
+		stateDir:      consts.DefaultStateDir,
+		systemUnitDir: consts.DefaultSystemUnitDir,
+		userUnitDir:   consts.DefaultUserUnitDir,
+		userLookup:    user.Lookup,
 	}
 	// applied options
 	for _, o := range opts {
@@ -80,8 +182,13 @@ func New(runDir string, unitStarter unitStarter, opts ...Option) (m *Manager, er
 	}
 
 	return &Manager{
-		runDir:      runDir,
-		unitStarter: unitStarter,
+		runDir:        runDir,
+		stateDir:      args.stateDir,
+		systemUnitDir: args.systemUnitDir,
+		userUnitDir:   args.userUnitDir,
+		systemdCaller: systemdCaller,
+		hostname:      args.hostname,
+		domain:        args.domain,
 
 		userLookup: args.userLookup,
 	}, nil
@@ -90,14 +197,15 @@ func New(runDir string, unitStarter unitStarter, opts ...Option) (m *Manager, er
 // AssetsDumper is a function which uncompress policies assets to a directory.
 type AssetsDumper func(ctx context.Context, relSrc, dest string, uid int, gid int) (err error)
 
-// ApplyPolicy generates a privilege policy based on a list of entries.
-func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry, assetsDumper AssetsDumper) (err error) {
+// ApplyPolicy generates a scripts policy based on a list of entries, from the GPOs named gpoNames.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, gpoNames []string, entries []entry.Entry, assetsDumper AssetsDumper) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't apply scripts policy to %s", objectName))
 
 	log.Debugf(ctx, "Applying scripts policy to %s", objectName)
 
 	objectDir := "machine"
 	uid, gid := -1, -1
+	var userUID string
 	if !isComputer {
 		user, err := m.userLookup(objectName)
 		if err != nil {
@@ -111,11 +219,19 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		}
 
 		objectDir = filepath.Join("users", user.Uid)
+		userUID = user.Uid
 	}
 
 	objectPath := filepath.Join(m.runDir, objectDir)
 	scriptsPath := filepath.Join(objectPath, executableDir)
 
+	// Grace periods apply to next shutdown or logoff, even if a session is already running.
+	if isComputer {
+		if err := m.applyGracePeriods(ctx, entries); err != nil {
+			return err
+		}
+	}
+
 	// If exists: there is a "session" in progress:
 	// - machine already booted and startup scripts executed
 	// - user session in progress and login scripts executed
@@ -130,7 +246,7 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	}
 
 	if len(entries) == 0 {
-		return nil
+		return m.applyAsyncScripts(ctx, isComputer, userUID, scriptsPath, false)
 	}
 
 	// This creates objectDirPath and scriptsDir directory.
@@ -142,6 +258,14 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		return errors.New(gotext.Get("can't create scripts directory %q: %v", scriptsPath, err))
 	}
 
+	// User scripts are not executed by us, let them save their output.
+	if !isComputer {
+		logsPath := objectLogsDir(m.stateDir, false, userUID)
+		if err := createUserLogsDir(logsPath, uid, gid); err != nil {
+			return errors.New(gotext.Get("can't create scripts logs directory %q: %v", logsPath, err))
+		}
+	}
+
 	// Dump assets to scripts/scripts/ subdirectory with correct ownership. If no assets is present while entries != nil, we want to return an error.
 	dest := filepath.Join(scriptsPath, "scripts")
 	if err := assetsDumper(ctx, "scripts/", dest, uid, gid); err != nil {
@@ -151,13 +275,29 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	// create order files, check that the scripts existings in the destination
 	log.Debugf(ctx, "Creating script order file for user %q", objectName)
 	orderFilesContent := make(map[string][]string)
+	var timeout int
 	for _, e := range entries {
 		lifecycle := filepath.Base(e.Key)
+		if isGracePeriodKey(lifecycle) {
+			continue
+		}
+		if _, ok := timeoutKeys[lifecycle]; ok {
+			if e.Disabled {
+				continue
+			}
+			if timeout, err = strconv.Atoi(strings.TrimSpace(e.Value)); err != nil || timeout < 0 {
+				return errors.New(gotext.Get("invalid scripts timeout %q: expected a number of seconds", e.Value))
+			}
+			continue
+		}
 		for _, script := range strings.Split(e.Value, "\n") {
 			script = strings.TrimSpace(script)
 			if script == "" {
 				continue
 			}
+			if script, err = checkRunAs(script, isComputer); err != nil {
+				return err
+			}
 
 			// check that the script exists and make it executable
 			scriptFilePath := filepath.Join(scriptsPath, executableDir, script)
@@ -180,6 +320,10 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	}
 
 	for lifecycle, scripts := range orderFilesContent {
+		scripts, err := sortScripts(scripts)
+		if err != nil {
+			return err
+		}
 		orderFilePath := filepath.Join(scriptsPath, lifecycle)
 
 		log.Debugf(ctx, "Creating order file %q", orderFilePath)
@@ -203,11 +347,38 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 		}
 	}
 
+	if timeout > 0 {
+		timeoutFilePath := filepath.Join(scriptsPath, timeoutFile)
+		log.Debugf(ctx, "Setting scripts timeout to %ds in %q", timeout, timeoutFilePath)
+		// nolint:gosec // G306 - the timeout is read by the user running the scripts
+		if err := os.WriteFile(timeoutFilePath, []byte(strconv.Itoa(timeout)+"\n"), 0640); err != nil {
+			return err
+		}
+		if err := chown(timeoutFilePath, nil, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	environmentFilePath := filepath.Join(scriptsPath, environmentFile)
+	log.Debugf(ctx, "Setting scripts environment in %q", environmentFilePath)
+	// nolint:gosec // G306 - the environment is read by the user running the scripts
+	if err := os.WriteFile(environmentFilePath, []byte(strings.Join(m.applyEnvironment(objectName, isComputer, gpoNames), "\n")+"\n"), 0640); err != nil {
+		return err
+	}
+	if err := chown(environmentFilePath, nil, uid, gid); err != nil {
+		return err
+	}
+
 	// Create ready flag
 	if err := createFlagFile(ctx, filepath.Join(scriptsPath, readyFlag), uid, gid); err != nil {
 		return err
 	}
 
+	// Asynchronous scripts start right away, without blocking the session opening or the machine boot.
+	if err := m.applyAsyncScripts(ctx, isComputer, userUID, scriptsPath, len(orderFilesContent[asyncLifecycle(isComputer)]) > 0); err != nil {
+		return err
+	}
+
 	if !isComputer {
 		return nil
 	}
@@ -218,14 +389,27 @@ func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer
 	}
 
 	log.Info(ctx, "Running machine startup scripts")
-	return m.unitStarter.StartUnit(ctx, consts.AdysMachineScriptsServiceName)
+	return m.systemdCaller.StartUnit(ctx, consts.AdysMachineScriptsServiceName)
 }
 
 // RunScripts executes all scripts in directory if ready and not already executed.
 // allowOrderMissing will not require order to exists if we are ready to execute.
-func RunScripts(ctx context.Context, order string, allowOrderMissing bool) (err error) {
+func RunScripts(ctx context.Context, order string, allowOrderMissing bool, opts ...Option) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't run scripts listed in %s", order))
 
+	// defaults
+	args := options{
+		stateDir:        consts.DefaultStateDir,
+		killGracePeriod: defaultKillGracePeriod,
+		logsKept:        defaultLogsKept,
+		userLookupID:    user.LookupId,
+		runner:          runScript,
+	}
+	// applied options
+	for _, o := range opts {
+		o(&args)
+	}
+
 	log.Infof(ctx, "Calling RunScripts on %q", order)
 
 	baseDir := filepath.Dir(order)
@@ -275,6 +459,34 @@ func RunScripts(ctx context.Context, order string, allowOrderMissing bool) (err
 		return errors.New(gotext.Get("%q is a directory and not a file", order))
 	}
 
+	timeout := readTimeout(ctx, filepath.Join(baseDir, timeoutFile))
+
+	// User scripts run as the user owning the scripts directory, machine ones as root.
+	var cred *syscall.Credential
+	var env []string
+	if uid, isUser := scriptsUser(baseDir); isUser {
+		if cred, env, err = userContext(ctx, uid, args.userLookupID); err != nil {
+			return err
+		}
+	}
+	env = withEnvironment(env, readEnvironment(ctx, filepath.Join(baseDir, environmentFile)))
+
+	// Save the output of this run, rotating previous ones.
+	output := newRunLog(io.Discard, nil)
+	logFile, err := createRunLogFile(ctx, scriptsLogsDir(args.stateDir, baseDir), filepath.Base(order), args.logsKept)
+	if err != nil {
+		log.Warningf(ctx, "Scripts output will not be saved: %v", err)
+	} else {
+		defer logFile.Close()
+		output = newRunLog(logFile, os.Environ())
+	}
+
+	// Asynchronous scripts run in the background: keep track of their exit status for status queries.
+	var async *asyncTracker
+	if isAsyncLifecycle(filepath.Base(order)) {
+		async = newAsyncTracker(ctx, filepath.Join(scriptsLogsDir(args.stateDir, baseDir), filepath.Base(order)+asyncStatusExt))
+	}
+
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
 		scriptPath := strings.TrimSpace(scanner.Text())
@@ -283,20 +495,239 @@ func RunScripts(ctx context.Context, order string, allowOrderMissing bool) (err
 		}
 		script := filepath.Join(baseDir, scriptPath)
 		log.Debugf(ctx, "Running script %q", script)
-		// #nosec G204 - this variable is coming from concatenation of an order file.
-		// Permissions are restricted to the owner of the order file, which is the one executing
-		// this script.
-		cmd := exec.CommandContext(ctx, script)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
+		if err := output.startScript(scriptPath); err != nil {
+			log.Warningf(ctx, "Can't save output of %q: %v", script, err)
+		}
+		err := args.runner(ctx, script, cred, env, output, timeout, args.killGracePeriod)
+		if err != nil {
 			log.Warningf(ctx, "%q failed to run\n%v", script, err)
 		}
+		if err := output.endScript(scriptPath, err); err != nil {
+			log.Warningf(ctx, "Can't save output of %q: %v", script, err)
+		}
+		async.scriptDone(ctx, scriptPath, err)
 	}
+	async.finish(ctx)
 
 	return nil
 }
 
+// checkRunAs returns script without its context prefix, if any.
+// It fails if the declared context doesn't match the policy type.
+func checkRunAs(script string, isComputer bool) (string, error) {
+	runAs, name, found := strings.Cut(script, ":")
+	if !found || (runAs != runAsUser && runAs != runAsMachine) {
+		return script, nil
+	}
+	name = strings.TrimSpace(name)
+
+	if runAs == runAsMachine && !isComputer {
+		return "", errors.New(gotext.Get("script %q runs as machine and can't be declared in a user policy", name))
+	}
+	if runAs == runAsUser && isComputer {
+		return "", errors.New(gotext.Get("script %q runs as user and can't be declared in a machine policy", name))
+	}
+	return name, nil
+}
+
+// scriptsUser returns the uid of the user owning the scripts directory baseDir, of the form <runDir>/users/<uid>/scripts.
+// isUser is false for machine scripts.
+func scriptsUser(baseDir string) (uid string, isUser bool) {
+	objectDir := filepath.Dir(baseDir)
+	if filepath.Base(filepath.Dir(objectDir)) != "users" {
+		return "", false
+	}
+	return filepath.Base(objectDir), true
+}
+
+// userContext returns the credentials and environment user scripts of uid are executed with.
+func userContext(ctx context.Context, uid string, userLookupID func(string) (*user.User, error)) (cred *syscall.Credential, env []string, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't prepare context of user %s", uid))
+
+	u, err := userLookupID(uid)
+	if err != nil {
+		return nil, nil, errors.New(gotext.Get("couldn't retrieve user for %q: %v", uid, err))
+	}
+	uidN, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, nil, errors.New(gotext.Get("couldn't convert %q to a valid uid for %q", u.Uid, u.Username))
+	}
+	gidN, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, nil, errors.New(gotext.Get("couldn't convert %q to a valid gid for %q", u.Gid, u.Username))
+	}
+	cred = &syscall.Credential{Uid: uint32(uidN), Gid: uint32(gidN)}
+
+	// Only root can change the supplementary groups.
+	if os.Geteuid() != 0 {
+		cred.NoSetGroups = true
+	} else if groups, err := u.GroupIds(); err != nil {
+		log.Warningf(ctx, "Can't retrieve groups of %q, running its scripts with its primary group only: %v", u.Username, err)
+	} else {
+		for _, g := range groups {
+			if gid, err := strconv.ParseUint(g, 10, 32); err == nil {
+				cred.Groups = append(cred.Groups, uint32(gid))
+			}
+		}
+	}
+
+	env = slices.DeleteFunc(os.Environ(), func(e string) bool {
+		k, _, _ := strings.Cut(e, "=")
+		return k == "HOME" || k == "USER" || k == "LOGNAME"
+	})
+	env = append(env, "HOME="+u.HomeDir, "USER="+u.Username, "LOGNAME="+u.Username)
+
+	return cred, env, nil
+}
+
+// sortScripts returns scripts sorted by their order key, then by name.
+// Scripts without order key are kept in their listed order, after the ordered ones.
+// It fails if two different scripts declare the same order.
+func sortScripts(scripts []string) ([]string, error) {
+	type orderedScript struct {
+		path    string
+		order   int
+		ordered bool
+	}
+
+	declared := make(map[int]string)
+	var ordered []orderedScript
+	for _, p := range scripts {
+		s := orderedScript{path: p}
+		if m := orderPrefix.FindStringSubmatch(filepath.Base(p)); m != nil {
+			order, err := strconv.Atoi(m[1])
+			if err != nil {
+				return nil, errors.New(gotext.Get("invalid order for script %q: %v", p, err))
+			}
+			if other, ok := declared[order]; ok && other != p {
+				return nil, errors.New(gotext.Get("scripts %q and %q conflict: both declare order %d", other, p, order))
+			}
+			declared[order] = p
+			s.order, s.ordered = order, true
+		}
+		ordered = append(ordered, s)
+	}
+
+	slices.SortStableFunc(ordered, func(a, b orderedScript) int {
+		switch {
+		case a.ordered && b.ordered:
+			return cmp.Or(cmp.Compare(a.order, b.order), strings.Compare(a.path, b.path))
+		case a.ordered:
+			return -1
+		case b.ordered:
+			return 1
+		}
+		return 0
+	})
+
+	r := make([]string, 0, len(ordered))
+	for _, s := range ordered {
+		r = append(r, s.path)
+	}
+	return r, nil
+}
+
+// readTimeout returns the maximum duration of each script stored in p.
+// No timeout is returned if the file doesn’t exist or is invalid.
+func readTimeout(ctx context.Context, p string) time.Duration {
+	d, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	} else if err != nil {
+		log.Warningf(ctx, "Can't read scripts timeout, running scripts without timeout: %v", err)
+		return 0
+	}
+	timeout, err := strconv.Atoi(strings.TrimSpace(string(d)))
+	if err != nil || timeout < 0 {
+		log.Warningf(ctx, "Invalid scripts timeout %q, running scripts without timeout", strings.TrimSpace(string(d)))
+		return 0
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// runScript executes script in its own process group, which is terminated if it runs longer than timeout.
+// The script is given killGracePeriod to exit before the process group is killed.
+// A zero timeout lets the script run until it exits or ctx is cancelled.
+// The script is run with cred only if they differ from the current process ones.
+// Its output is written to output and to the standard output once it exited.
+func runScript(ctx context.Context, script string, cred *syscall.Credential, env []string, output io.Writer, timeout, killGracePeriod time.Duration) error {
+	// The script writes to a file rather than to pipes: processes it leaves running in the background, like on logon,
+	// can keep writing their output without us waiting for them or failing them once the script exited.
+	out, err := os.CreateTemp("", "adsys-script-*.log")
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if err := os.Remove(out.Name()); err != nil {
+		return err
+	}
+	defer func() {
+		if err := copyScriptOutput(out, io.MultiWriter(os.Stdout, output)); err != nil {
+			log.Warningf(ctx, "Can't read output of %q: %v", script, err)
+		}
+	}()
+
+	// #nosec G204 - this variable is coming from concatenation of an order file.
+	// Permissions are restricted to the owner of the order file, which is the one executing
+	// this script.
+	cmd := exec.Command(script)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if cred != nil && (int(cred.Uid) != os.Geteuid() || int(cred.Gid) != os.Getegid()) {
+		cmd.SysProcAttr.Credential = cred
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pgid := cmd.Process.Pid
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		<-done
+		return ctx.Err()
+	case <-expired:
+	}
+
+	log.Warningf(ctx, "%q timed out after %s, terminating it", script, timeout)
+	_ = syscall.Kill(-pgid, syscall.SIGTERM)
+	select {
+	case <-done:
+		// Kill any process of the group which may have outlived the script.
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	case <-time.After(killGracePeriod):
+		log.Warningf(ctx, "%q did not exit %s after being terminated, killing it", script, killGracePeriod)
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		<-done
+	}
+	return errors.New(gotext.Get("script %q timed out after %s", script, timeout))
+}
+
+// copyScriptOutput copies to w what a script wrote so far in out.
+// It doesn’t move the offset of out, which is shared with the processes the script left running.
+func copyScriptOutput(out *os.File, w io.Writer) error {
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, io.NewSectionReader(out, 0, info.Size()))
+	return err
+}
+
 func mkdirAllWithUIDGid(p string, uid, gid int) error {
 	if err := os.MkdirAll(p, 0750); err != nil {
 		return fmt.Errorf(gotext.Get("can't create scripts directory %q: %v", p, err))
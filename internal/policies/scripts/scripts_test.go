@@ -1,14 +1,22 @@
 package scripts_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/user"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
 	"github.com/ubuntu/adsys/internal/policies/entry"
@@ -84,10 +92,16 @@ func TestApplyPolicy(t *testing.T) {
 		"Subfolder with same script name":    {entries: []entry.Entry{{Key: "s", Value: "script1.sh\nsubfolder/script1.sh"}}},
 		"No entries is an empty folder":      {},
 		"Empty entries are discared":         {entries: []entry.Entry{{Key: "s", Value: "script3.sh\n\nscript1.sh"}}},
+		"Scripts with a timeout":             {entries: []entry.Entry{{Key: "s", Value: "script1.sh"}, {Key: "user-scripts-timeout", Value: "30"}}},
+		"Disabled timeout is not set":        {entries: []entry.Entry{{Key: "s", Value: "script1.sh"}, {Key: "user-scripts-timeout", Disabled: true}}},
+		"Zero timeout is not set":            {entries: []entry.Entry{{Key: "s", Value: "script1.sh"}, {Key: "user-scripts-timeout", Value: "0"}}},
+		"Scripts running as user":            {entries: []entry.Entry{{Key: "s", Value: "user:script1.sh\nscript2.sh"}}},
 
 		// Computer cases -> no setuid/setgid (should be -1)
 		"Computer, no systemctl with other directory than startup":       {computer: true, systemctlShouldFail: true, entries: defaultSingleScript},
+		"Computer scripts with a timeout":                                {computer: true, entries: []entry.Entry{{Key: "s", Value: "script1.sh"}, {Key: "machine-scripts-timeout", Value: "120"}}},
 		"Startup script for computer runs systemctl (systemctl success)": {computer: true, systemctlShouldFail: false, entries: []entry.Entry{{Key: "startup", Value: "script1.sh"}}},
+		"Computer scripts running as machine":                            {computer: true, entries: []entry.Entry{{Key: "s", Value: "machine:script1.sh\nscript2.sh"}}},
 
 		// Destination already exists. Using computer to be uid independent
 		"Destination is already running, no change":                   {destAlreadyExists: "already running", computer: true, entries: defaultSingleScript},
@@ -104,6 +118,10 @@ func TestApplyPolicy(t *testing.T) {
 		"Error on script does not exist":         {entries: []entry.Entry{{Key: "s", Value: "doestnotexists"}}, wantErr: true},
 		"Error on users run directory Read Only": {makeReadOnly: true, entries: defaultSingleScript, wantErr: true},
 		"Error on save assets dumping failing":   {entries: defaultSingleScript, saveAssetsError: true, wantErr: true},
+		"Error on invalid timeout":               {entries: []entry.Entry{{Key: "s", Value: "script1.sh"}, {Key: "user-scripts-timeout", Value: "abc"}}, wantErr: true},
+		"Error on negative timeout":              {entries: []entry.Entry{{Key: "s", Value: "script1.sh"}, {Key: "user-scripts-timeout", Value: "-1"}}, wantErr: true},
+		"Error on machine script in user policy": {entries: []entry.Entry{{Key: "s", Value: "script1.sh\nmachine:script2.sh"}}, wantErr: true},
+		"Error on user script in machine policy": {computer: true, entries: []entry.Entry{{Key: "s", Value: "user:script1.sh"}}, wantErr: true},
 
 		// User error cases only
 		"Error on invalid UID":         {userReturnedUID: "invalid", entries: defaultSingleScript, wantErr: true},
@@ -146,8 +164,12 @@ func TestApplyPolicy(t *testing.T) {
 
 			mockAssetsDumper := testutils.MockAssetsDumper{T: t, Err: tc.saveAssetsError, Path: "scripts/"}
 
+			stateDir := t.TempDir()
 			m, err := scripts.New(runDir, &mockUnitStarter{StartFailed: tc.systemctlShouldFail},
+				scripts.WithStateDir(stateDir),
 				scripts.WithUserLookup(userLookup),
+				scripts.WithHostname("myhost"),
+				scripts.WithDomain("example.com"),
 			)
 			require.NoError(t, err, "Setup: can't create scripts manager")
 
@@ -155,13 +177,20 @@ func TestApplyPolicy(t *testing.T) {
 				testutils.MakeReadOnly(t, filepath.Join(runDir, "users"))
 			}
 
-			err = m.ApplyPolicy(context.Background(), "ubuntu", tc.computer, tc.entries, mockAssetsDumper.SaveAssetsTo)
+			err = m.ApplyPolicy(context.Background(), "ubuntu", tc.computer, []string{"closest GPO", "furthest GPO"}, tc.entries, mockAssetsDumper.SaveAssetsTo)
 			if tc.wantErr {
 				require.NotNil(t, err, "ApplyPolicy should have failed but didn't")
 				return
 			}
 			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
 
+			// User scripts can save their output
+			if !tc.computer && len(tc.entries) > 0 && tc.destAlreadyExists == "" {
+				fi, err := os.Stat(filepath.Join(stateDir, "scripts-logs", "users", u.Uid))
+				require.NoError(t, err, "User scripts logs directory should have been created")
+				require.Equal(t, fs.FileMode(0700), fi.Mode().Perm(), "User scripts logs directory should only be accessible to the user")
+			}
+
 			makeIndependentOfCurrentUID(t, runDir, u.Uid)
 
 			testutils.CompareTreesWithFiltering(t, runDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
@@ -197,6 +226,9 @@ func makeIndependentOfCurrentUID(t *testing.T, path string, uid string) {
 func TestRunScripts(t *testing.T) {
 	t.Parallel()
 
+	u, err := user.Current()
+	require.NoError(t, err, "Setup: failed to get current user")
+
 	tests := map[string]struct {
 		stageDir          string
 		allowOrderMissing bool
@@ -226,6 +258,14 @@ func TestRunScripts(t *testing.T) {
 		"allow order file missing":           {allowOrderMissing: true},
 		"spaces and empty lines are skipped": {},
 
+		// timeout cases
+		"script running longer than timeout is terminated": {},
+		"script ignoring termination is killed":            {},
+		"scripts shorter than timeout are not impacted":    {},
+		"invalid timeout runs scripts without timeout":     {},
+
+		"script leaving background processes does not break them": {},
+
 		// Error cases
 		"error on order file not existing": {wantErr: true},
 		"error on not ready for execution": {wantErr: true},
@@ -245,7 +285,11 @@ func TestRunScripts(t *testing.T) {
 			if tc.scriptObjectName == "" {
 				tc.scriptObjectName = "users"
 			}
-			scriptRootParentDir := filepath.Join(scriptDir, tc.scriptObjectName, "foo")
+			objectName := "foo"
+			if tc.scriptObjectName == "users" {
+				objectName = u.Uid
+			}
+			scriptRootParentDir := filepath.Join(scriptDir, tc.scriptObjectName, objectName)
 			scriptParentDir := filepath.Join(scriptRootParentDir, "scripts")
 			scriptDir = filepath.Join(scriptParentDir, tc.stageDir)
 
@@ -258,7 +302,9 @@ func TestRunScripts(t *testing.T) {
 					"Setup: can't create script dir")
 			}
 
-			err := scripts.RunScripts(context.Background(), scriptDir, tc.allowOrderMissing)
+			err := scripts.RunScripts(context.Background(), scriptDir, tc.allowOrderMissing,
+				scripts.WithStateDir(t.TempDir()),
+				scripts.WithKillGracePeriod(100*time.Millisecond))
 			if tc.wantErr {
 				require.NotNil(t, err, "RunScripts should have failed but didn't")
 				_, err = os.Stat(filepath.Dir(scriptDir))
@@ -281,15 +327,680 @@ func TestRunScripts(t *testing.T) {
 	}
 }
 
+func TestScriptsOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		entries []entry.Entry
+
+		want    []string
+		wantErr bool
+	}{
+		"Scripts are sorted by order":                      {entries: []entry.Entry{{Key: "s", Value: "20-mount.sh\n10-network.sh\n5-early.sh"}}, want: []string{"5-early.sh", "10-network.sh", "20-mount.sh"}},
+		"Scripts in subfolders are sorted by their name":   {entries: []entry.Entry{{Key: "s", Value: "20-mount.sh\nsubfolder/15-sub.sh\n10-network.sh"}}, want: []string{"10-network.sh", "subfolder/15-sub.sh", "20-mount.sh"}},
+		"Scripts without order run last in listed order":   {entries: []entry.Entry{{Key: "s", Value: "unordered-b.sh\n20-mount.sh\nunordered-a.sh\n10-network.sh"}}, want: []string{"10-network.sh", "20-mount.sh", "unordered-b.sh", "unordered-a.sh"}},
+		"Same script listed multiple times runs each time": {entries: []entry.Entry{{Key: "s", Value: "10-network.sh\n5-early.sh\n10-network.sh"}}, want: []string{"5-early.sh", "10-network.sh", "10-network.sh"}},
+		"Scripts from multiple entries are sorted together": {entries: []entry.Entry{
+			{Key: "s", Value: "20-mount.sh\nunordered-a.sh"},
+			{Key: "s", Value: "10-network.sh"}}, want: []string{"10-network.sh", "20-mount.sh", "unordered-a.sh"}},
+
+		// Error cases
+		"Error on scripts declaring the same order":        {entries: []entry.Entry{{Key: "s", Value: "10-network.sh\n10-other.sh"}}, wantErr: true},
+		"Error on scripts declaring the same padded order": {entries: []entry.Entry{{Key: "s", Value: "5-early.sh\n05-early-padded.sh"}}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runDir := t.TempDir()
+			stateDir := t.TempDir()
+			m, err := scripts.New(runDir, &mockUnitStarter{}, scripts.WithStateDir(stateDir))
+			require.NoError(t, err, "Setup: can't create scripts manager")
+
+			assetsDumper := func(_ context.Context, _, dest string, _, _ int) error {
+				return shutil.CopyTree(filepath.Join(testutils.TestFamilyPath(t), "sysvol-scripts"), dest, nil)
+			}
+			err = m.ApplyPolicy(context.Background(), "ubuntu", true, nil, tc.entries, assetsDumper)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+				require.ErrorContains(t, err, "conflict", "ApplyPolicy should report the conflicting scripts")
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			scriptsDir := filepath.Join(runDir, "machine", "scripts", "scripts")
+			var got []string
+			recorder := func(_ context.Context, script string, _ *syscall.Credential, _ []string, _ io.Writer, _, _ time.Duration) error {
+				rel, err := filepath.Rel(scriptsDir, script)
+				require.NoError(t, err, "Setup: script is not in scripts directory")
+				got = append(got, rel)
+				return nil
+			}
+			err = scripts.RunScripts(context.Background(), filepath.Join(runDir, "machine", "scripts", "s"), false,
+				scripts.WithStateDir(stateDir),
+				scripts.WithScriptRunner(recorder))
+			require.NoError(t, err, "RunScripts failed but shouldn't have")
+
+			require.Equal(t, tc.want, got, "Scripts should have run in order")
+		})
+	}
+}
+
+func TestRunScriptsEnvironment(t *testing.T) {
+	t.Parallel()
+
+	u, err := user.Current()
+	require.NoError(t, err, "Setup: failed to get current user")
+
+	tests := map[string]struct {
+		notComputer      bool
+		gpoNames         []string
+		extraEnvironment string
+
+		want []string
+	}{
+		"Machine scripts": {gpoNames: []string{"closest", "furthest"}, want: []string{
+			"ADSYS_DOMAIN=example.com", "ADSYS_COMPUTER=myhost", "ADSYS_SCOPE=machine", "ADSYS_GPO_NAMES=closest,furthest"}},
+		"User scripts": {notComputer: true, gpoNames: []string{"closest", "furthest"}, want: []string{
+			"ADSYS_DOMAIN=example.com", "ADSYS_COMPUTER=myhost", "ADSYS_SCOPE=user", "ADSYS_GPO_NAMES=closest,furthest"}},
+		"GPO names are kept on a single line": {gpoNames: []string{"multi\nline  name", "other"}, want: []string{
+			"ADSYS_DOMAIN=example.com", "ADSYS_COMPUTER=myhost", "ADSYS_SCOPE=machine", "ADSYS_GPO_NAMES=multi line name,other"}},
+		"No GPO names": {want: []string{
+			"ADSYS_DOMAIN=example.com", "ADSYS_COMPUTER=myhost", "ADSYS_SCOPE=machine", "ADSYS_GPO_NAMES="}},
+		"Unknown variables of the environment file are ignored": {extraEnvironment: "PATH=/nowhere\nnotavariable\n", want: []string{
+			"ADSYS_DOMAIN=example.com", "ADSYS_COMPUTER=myhost", "ADSYS_SCOPE=machine", "ADSYS_GPO_NAMES="}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runDir := t.TempDir()
+			stateDir := t.TempDir()
+			m, err := scripts.New(runDir, &mockUnitStarter{},
+				scripts.WithStateDir(stateDir),
+				scripts.WithUserLookup(func(string) (*user.User, error) { return u, nil }),
+				scripts.WithHostname("myhost"),
+				scripts.WithDomain("example.com"))
+			require.NoError(t, err, "Setup: can't create scripts manager")
+
+			assetsDumper := func(_ context.Context, _, dest string, _, _ int) error {
+				return shutil.CopyTree(filepath.Join("testdata", "TestScriptsOrder", "sysvol-scripts"), dest, nil)
+			}
+			// The machine policy applies to the machine object.
+			objectName := "myhost"
+			if tc.notComputer {
+				objectName = "ubuntu"
+			}
+			err = m.ApplyPolicy(context.Background(), objectName, !tc.notComputer, tc.gpoNames, []entry.Entry{{Key: "s", Value: "10-network.sh"}}, assetsDumper)
+			require.NoError(t, err, "Setup: ApplyPolicy failed but shouldn't have")
+
+			scriptsDir := filepath.Join(runDir, "machine", "scripts")
+			if tc.notComputer {
+				scriptsDir = filepath.Join(runDir, "users", u.Uid, "scripts")
+			}
+			if tc.extraEnvironment != "" {
+				f, err := os.OpenFile(filepath.Join(scriptsDir, ".environment"), os.O_APPEND|os.O_WRONLY, 0)
+				require.NoError(t, err, "Setup: can't open scripts environment")
+				_, err = f.WriteString(tc.extraEnvironment)
+				require.NoError(t, err, "Setup: can't extend scripts environment")
+				require.NoError(t, f.Close(), "Setup: can't close scripts environment")
+			}
+
+			var ran int
+			recorder := func(_ context.Context, script string, _ *syscall.Credential, env []string, _ io.Writer, _, _ time.Duration) error {
+				ran++
+				var got []string
+				for _, e := range env {
+					if strings.HasPrefix(e, "ADSYS_") {
+						got = append(got, e)
+					}
+				}
+				require.ElementsMatch(t, tc.want, got, "Script %q should run with the variables describing the policy, once each", script)
+				require.NotContains(t, env, "PATH=/nowhere", "Script %q should not run with unknown variables of the environment file", script)
+				return nil
+			}
+			err = scripts.RunScripts(context.Background(), filepath.Join(scriptsDir, "s"), false,
+				scripts.WithStateDir(stateDir),
+				scripts.WithUserLookupID(func(string) (*user.User, error) { return u, nil }),
+				scripts.WithScriptRunner(recorder))
+			require.NoError(t, err, "RunScripts failed but shouldn't have")
+			require.Equal(t, 1, ran, "The script should have run")
+		})
+	}
+}
+
+func TestRunScriptsAs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		objectDir     string
+		lookupFailing bool
+
+		wantUID  uint32
+		wantGID  uint32
+		wantHome string
+		wantErr  bool
+	}{
+		"User scripts run as the user":  {objectDir: filepath.Join("users", "4242"), wantUID: 4242, wantGID: 4243, wantHome: "/home/bob"},
+		"Machine scripts run as root":   {objectDir: "machine"},
+		"User lookup is for the object": {objectDir: filepath.Join("users", "4343"), wantUID: 4343, wantGID: 4344, wantHome: "/home/alice"},
+
+		// Error cases
+		"Error on user lookup failing": {objectDir: filepath.Join("users", "4242"), lookupFailing: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			users := map[string]*user.User{
+				"4242": {Uid: "4242", Gid: "4243", Username: "bob", HomeDir: "/home/bob"},
+				"4343": {Uid: "4343", Gid: "4344", Username: "alice", HomeDir: "/home/alice"},
+			}
+			userLookupID := func(uid string) (*user.User, error) {
+				if tc.lookupFailing {
+					return nil, errors.New("user lookup error")
+				}
+				return users[uid], nil
+			}
+
+			scriptParentDir := filepath.Join(t.TempDir(), tc.objectDir, "scripts")
+			require.NoError(t,
+				shutil.CopyTree(
+					filepath.Join("testdata", "TestRunScripts", "scripts", "multiple scripts are run in order"), scriptParentDir,
+					&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+				"Setup: can't create script dir")
+
+			var ran int
+			recorder := func(_ context.Context, script string, cred *syscall.Credential, env []string, _ io.Writer, _, _ time.Duration) error {
+				ran++
+				if tc.wantUID == 0 {
+					require.Nil(t, cred, "Machine script %q should run as root", script)
+					require.Nil(t, env, "Machine script %q should run with the daemon environment", script)
+					return nil
+				}
+				require.NotNil(t, cred, "User script %q should run with the user credentials", script)
+				require.Equal(t, tc.wantUID, cred.Uid, "User script %q should run with the user uid", script)
+				require.Equal(t, tc.wantGID, cred.Gid, "User script %q should run with the user gid", script)
+				require.Contains(t, env, "HOME="+tc.wantHome, "User script %q should run with the user home", script)
+				return nil
+			}
+
+			err := scripts.RunScripts(context.Background(), filepath.Join(scriptParentDir, "s"), false,
+				scripts.WithStateDir(t.TempDir()),
+				scripts.WithUserLookupID(userLookupID),
+				scripts.WithScriptRunner(recorder))
+			if tc.wantErr {
+				require.Error(t, err, "RunScripts should have failed but didn't")
+				require.Zero(t, ran, "No script should have run")
+				return
+			}
+			require.NoError(t, err, "RunScripts failed but shouldn't have")
+			require.NotZero(t, ran, "Scripts should have run")
+		})
+	}
+}
+
+func TestRunScriptsReportsTimeout(t *testing.T) {
+	// capture log output (set to stderr, but captured when loading logrus)
+	r, w, err := os.Pipe()
+	require.NoError(t, err, "Setup: pipe shouldn’t fail")
+	orig := logrus.StandardLogger().Out
+	logrus.StandardLogger().SetOutput(w)
+
+	u, err := user.Current()
+	require.NoError(t, err, "Setup: failed to get current user")
+
+	scriptParentDir := filepath.Join(t.TempDir(), "users", u.Uid, "scripts")
+	require.NoError(t,
+		shutil.CopyTree(
+			filepath.Join("testdata", "TestRunScripts", "scripts", "script running longer than timeout is terminated"), scriptParentDir,
+			&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+		"Setup: can't create script dir")
+
+	err = scripts.RunScripts(context.Background(), filepath.Join(scriptParentDir, "s"), false,
+		scripts.WithStateDir(t.TempDir()),
+		scripts.WithKillGracePeriod(100*time.Millisecond))
+	require.NoError(t, err, "RunScripts should not fail on scripts timing out")
+
+	logrus.StandardLogger().SetOutput(orig)
+	w.Close()
+
+	var out bytes.Buffer
+	_, errCopy := io.Copy(&out, r)
+	require.NoError(t, errCopy, "Setup: Couldn't copy logs to buffer")
+
+	require.Contains(t, out.String(), "slow.sh\\\" timed out after 1s", "Should have logged the timed out script but didn't")
+}
+
+func TestGracePeriods(t *testing.T) {
+	t.Parallel()
+
+	u, err := user.Current()
+	require.NoError(t, err, "Setup: failed to get current user")
+
+	shutdown := entry.Entry{Key: "shutdown-scripts-grace-period", Value: "300"}
+	logoff := entry.Entry{Key: "logoff-scripts-grace-period", Value: "60"}
+
+	tests := map[string]struct {
+		entries          []entry.Entry
+		notComputer      bool
+		existingUnitsDir string
+		reloadFails      bool
+
+		wantErr bool
+	}{
+		"Shutdown scripts grace period":                      {entries: []entry.Entry{shutdown}},
+		"Logoff scripts grace period":                        {entries: []entry.Entry{logoff}},
+		"Both grace periods":                                 {entries: []entry.Entry{shutdown, logoff}},
+		"Grace periods with scripts":                         {entries: []entry.Entry{{Key: "shutdown", Value: "script1.sh"}, shutdown}},
+		"Zero grace period keeps default behavior":           {entries: []entry.Entry{{Key: "shutdown-scripts-grace-period", Value: "0"}}},
+		"No grace period keeps default behavior":             {entries: []entry.Entry{{Key: "shutdown", Value: "script1.sh"}}},
+		"Grace periods are not applied from user policies":   {entries: []entry.Entry{shutdown, logoff}, notComputer: true},
+		"Updates existing grace periods":                     {entries: []entry.Entry{shutdown, logoff}, existingUnitsDir: "grace periods"},
+		"Removes existing grace periods, keeping other ones": {existingUnitsDir: "grace periods"},
+		"Removes disabled grace periods": {entries: []entry.Entry{
+			{Key: "shutdown-scripts-grace-period", Disabled: true},
+			{Key: "logoff-scripts-grace-period", Disabled: true}}, existingUnitsDir: "grace periods"},
+		"Unchanged grace periods do not reload systemd": {entries: []entry.Entry{{Key: "shutdown-scripts-grace-period", Value: "120"}}, existingUnitsDir: "grace periods", reloadFails: true},
+
+		// Error cases
+		"Error on invalid grace period":   {entries: []entry.Entry{{Key: "shutdown-scripts-grace-period", Value: "soon"}}, wantErr: true},
+		"Error on negative grace period":  {entries: []entry.Entry{{Key: "logoff-scripts-grace-period", Value: "-1"}}, wantErr: true},
+		"Error on systemd reload failing": {entries: []entry.Entry{shutdown}, reloadFails: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			etcDir := t.TempDir()
+			if tc.existingUnitsDir != "" {
+				require.NoError(t, os.RemoveAll(etcDir), "Setup: can't remove etc dir before filing it")
+				require.NoError(t,
+					shutil.CopyTree(
+						filepath.Join(testutils.TestFamilyPath(t), tc.existingUnitsDir), etcDir,
+						&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+					"Setup: can't create initial units directory")
+			}
+
+			m, err := scripts.New(t.TempDir(), &mockUnitStarter{ReloadFailed: tc.reloadFails},
+				scripts.WithStateDir(t.TempDir()),
+				scripts.WithSystemUnitDir(filepath.Join(etcDir, "systemd", "system")),
+				scripts.WithUserUnitDir(filepath.Join(etcDir, "systemd", "user")),
+				scripts.WithUserLookup(func(string) (*user.User, error) { return u, nil }),
+			)
+			require.NoError(t, err, "Setup: can't create scripts manager")
+
+			mockAssetsDumper := testutils.MockAssetsDumper{T: t, Path: "scripts/"}
+			err = m.ApplyPolicy(context.Background(), "ubuntu", !tc.notComputer, nil, tc.entries, mockAssetsDumper.SaveAssetsTo)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			testutils.CompareTreesWithFiltering(t, etcDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
+
+func TestAsyncScripts(t *testing.T) {
+	t.Parallel()
+
+	u, err := user.Current()
+	require.NoError(t, err, "Setup: failed to get current user")
+
+	tests := map[string]struct {
+		entries      []entry.Entry
+		notComputer  bool
+		existingUnit bool
+		reloadFails  bool
+
+		wantStarted     []string
+		wantUnitRemoved bool
+		wantErr         bool
+	}{
+		"Machine asynchronous scripts are started in the background": {
+			entries:     []entry.Entry{{Key: "startup-async", Value: "script1.sh\nscript2.sh"}},
+			wantStarted: []string{"adsys-machine-async-scripts.service"}},
+		"User asynchronous scripts are started in the background": {
+			entries: []entry.Entry{{Key: "logon-async", Value: "script1.sh"}}, notComputer: true,
+			wantStarted: []string{"adsys-user-async-scripts-4242.service"}},
+		"Asynchronous scripts do not prevent startup scripts": {
+			entries:     []entry.Entry{{Key: "startup", Value: "script1.sh"}, {Key: "startup-async", Value: "script2.sh"}},
+			wantStarted: []string{"adsys-machine-async-scripts.service", "adsys-machine-scripts.service"}},
+		"Existing service is updated": {
+			entries: []entry.Entry{{Key: "startup-async", Value: "script1.sh"}}, existingUnit: true,
+			wantStarted: []string{"adsys-machine-async-scripts.service"}},
+		"Unchanged service does not reload systemd": {
+			entries: []entry.Entry{{Key: "startup-async", Value: "script2.sh"}}, existingUnit: true, reloadFails: true,
+			wantStarted: []string{"adsys-machine-async-scripts.service"}},
+		"Asynchronous scripts of the other object are not started": {
+			entries: []entry.Entry{{Key: "logon-async", Value: "script1.sh"}}},
+		"Disabled asynchronous scripts are not started": {
+			entries: []entry.Entry{{Key: "startup-async", Disabled: true}}},
+		"No asynchronous scripts removes the existing service": {
+			entries: []entry.Entry{{Key: "startup", Value: "script1.sh"}}, existingUnit: true,
+			wantStarted: []string{"adsys-machine-scripts.service"}, wantUnitRemoved: true},
+		"No entries removes the existing service": {existingUnit: true, wantUnitRemoved: true},
+
+		// Error cases
+		"Error on systemd reload failing": {entries: []entry.Entry{{Key: "startup-async", Value: "script1.sh"}}, reloadFails: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runDir, unitDir := t.TempDir(), t.TempDir()
+			unit := "adsys-machine-async-scripts.service"
+			if tc.notComputer {
+				unit = fmt.Sprintf("adsys-user-async-scripts-%s.service", u.Uid)
+			}
+			if tc.existingUnit {
+				orderFile := filepath.Join(runDir, "machine", "scripts", "startup-async")
+				content := fmt.Sprintf(`# This file is managed by adsys.
+# Do not edit this file manually.
+# Any changes will be overwritten.
+
+[Unit]
+Description=ADSys startup scripts execution in the background
+ConditionPathExists=%s
+
+[Service]
+Type=oneshot
+ExecStart=/sbin/adsysd runscripts %s
+`, orderFile, orderFile)
+				if !tc.reloadFails {
+					content = "previous version"
+				}
+				require.NoError(t, os.WriteFile(filepath.Join(unitDir, unit), []byte(content), 0600), "Setup: can't create existing service")
+			}
+
+			// Asynchronous services are only started once the test releases them.
+			started := make(chan string, 10)
+			release := make(chan struct{})
+			defer close(release)
+			m, err := scripts.New(runDir, &mockUnitStarter{ReloadFailed: tc.reloadFails, Started: started, Release: release},
+				scripts.WithStateDir(t.TempDir()),
+				scripts.WithSystemUnitDir(unitDir),
+				scripts.WithUserLookup(func(string) (*user.User, error) { return u, nil }),
+			)
+			require.NoError(t, err, "Setup: can't create scripts manager")
+
+			mockAssetsDumper := testutils.MockAssetsDumper{T: t, Path: "scripts/"}
+			applied := make(chan error)
+			go func() {
+				applied <- m.ApplyPolicy(context.Background(), "ubuntu", !tc.notComputer, nil, tc.entries, mockAssetsDumper.SaveAssetsTo)
+			}()
+			select {
+			case err = <-applied:
+			case <-time.After(5 * time.Second):
+				t.Fatal("ApplyPolicy should not wait for asynchronous scripts to complete")
+			}
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy failed but shouldn't have")
+
+			var got []string
+			for range tc.wantStarted {
+				select {
+				case s := <-started:
+					got = append(got, strings.Replace(s, "-"+u.Uid+".", "-4242.", 1))
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Services %v should have been started, got %v", tc.wantStarted, got)
+				}
+			}
+			require.ElementsMatch(t, tc.wantStarted, got, "ApplyPolicy should have started the expected services")
+
+			content, err := os.ReadFile(filepath.Join(unitDir, unit))
+			if tc.wantUnitRemoved || !slices.Contains(tc.wantStarted, strings.Replace(unit, "-"+u.Uid+".", "-4242.", 1)) {
+				require.ErrorIs(t, err, fs.ErrNotExist, "Asynchronous scripts service should not exist")
+				return
+			}
+			require.NoError(t, err, "Asynchronous scripts service should have been generated")
+
+			got = nil
+			for _, l := range strings.Split(string(content), "\n") {
+				got = append(got, strings.ReplaceAll(strings.ReplaceAll(l, runDir, "/run/adsys"), "/"+u.Uid+"/", "/4242/"))
+			}
+			want := testutils.LoadWithUpdateFromGolden(t, strings.Join(got, "\n"))
+			require.Equal(t, want, strings.Join(got, "\n"), "Asynchronous scripts service content doesn't match")
+		})
+	}
+}
+
+func TestAsyncStatus(t *testing.T) {
+	t.Parallel()
+
+	u, err := user.Current()
+	require.NoError(t, err, "Setup: failed to get current user")
+
+	tests := map[string]struct {
+		computer        bool
+		noRun           bool
+		userLookupError bool
+
+		wantErr bool
+	}{
+		"Status of machine asynchronous scripts": {computer: true},
+		"Status of user asynchronous scripts":    {},
+		"No status before any run":               {noRun: true},
+
+		"Error on user lookup failing": {userLookupError: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			objectDir, lifecycle := filepath.Join("users", u.Uid), "logon-async"
+			if tc.computer {
+				objectDir, lifecycle = "machine", "startup-async"
+			}
+			runDir, stateDir := t.TempDir(), t.TempDir()
+			scriptParentDir := filepath.Join(runDir, objectDir, "scripts")
+			require.NoError(t,
+				shutil.CopyTree(
+					filepath.Join(testutils.TestFamilyPath(t), "scripts"), scriptParentDir,
+					&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+				"Setup: can't create script dir")
+
+			if !tc.noRun {
+				err := scripts.RunScripts(context.Background(), filepath.Join(scriptParentDir, lifecycle), false,
+					scripts.WithStateDir(stateDir),
+					scripts.WithUserLookupID(func(string) (*user.User, error) { return u, nil }))
+				require.NoError(t, err, "Setup: RunScripts failed but shouldn't have")
+			}
+
+			userLookup := func(string) (*user.User, error) {
+				if tc.userLookupError {
+					return nil, errors.New("User error requested")
+				}
+				return u, nil
+			}
+			m, err := scripts.New(runDir, &mockUnitStarter{}, scripts.WithStateDir(stateDir), scripts.WithUserLookup(userLookup))
+			require.NoError(t, err, "Setup: can't create scripts manager")
+
+			got, err := m.AsyncStatus(context.Background(), "ubuntu", tc.computer)
+			if tc.wantErr {
+				require.Error(t, err, "AsyncStatus should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "AsyncStatus failed but shouldn't have")
+
+			if tc.noRun {
+				require.Nil(t, got, "AsyncStatus should report no status before any run")
+				return
+			}
+			require.NotNil(t, got, "AsyncStatus should report the last run")
+			require.NotNil(t, got.Finished, "Last run should be finished")
+			require.False(t, got.Finished.Before(got.Started), "Last run should finish after it started")
+			require.Equal(t, []scripts.AsyncScriptState{
+				{Name: "scripts/succeed.sh"},
+				{Name: "scripts/fail.sh", ExitCode: 3, Error: "exit status 3"},
+			}, got.Scripts, "AsyncStatus should report the exit status of each script")
+		})
+	}
+}
+
+func TestRunScriptsLogs(t *testing.T) {
+	// Secrets are passed to scripts through the environment
+	t.Setenv("ADSYS_TEST_PASSWORD", "supersecret")
+	t.Setenv("ADSYS_TEST_USER", "bob")
+
+	tests := map[string]struct {
+		runs     int
+		logsKept int
+		machine  bool
+
+		wantLogs int
+	}{
+		"Scripts output is saved":                {runs: 1, wantLogs: 1},
+		"Machine scripts output is saved":        {runs: 1, machine: true, wantLogs: 1},
+		"Logs are rotated to keep the last runs": {runs: 4, logsKept: 2, wantLogs: 2},
+		"Logs are kept up to the limit":          {runs: 2, logsKept: 2, wantLogs: 2},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if tc.logsKept == 0 {
+				tc.logsKept = 10
+			}
+			objectDir, logsDir := filepath.Join("users", "4242"), filepath.Join("scripts-logs", "users", "4242")
+			if tc.machine {
+				objectDir, logsDir = "machine", filepath.Join("scripts-logs", "machine")
+			}
+
+			scriptParentDir := filepath.Join(t.TempDir(), objectDir, "scripts")
+			require.NoError(t,
+				shutil.CopyTree(
+					filepath.Join(testutils.TestFamilyPath(t), "scripts"), scriptParentDir,
+					&shutil.CopyTreeOptions{Symlinks: true, CopyFunction: shutil.Copy}),
+				"Setup: can't create script dir")
+			stateDir := t.TempDir()
+
+			for i := 0; i < tc.runs; i++ {
+				err := scripts.RunScripts(context.Background(), filepath.Join(scriptParentDir, "s"), false,
+					scripts.WithStateDir(stateDir),
+					scripts.WithLogsKept(tc.logsKept),
+					scripts.WithUserLookupID(func(string) (*user.User, error) { return user.Current() }))
+				require.NoError(t, err, "RunScripts failed but shouldn't have")
+			}
+
+			logs, err := os.ReadDir(filepath.Join(stateDir, logsDir))
+			require.NoError(t, err, "Scripts logs directory should have been created")
+			require.Len(t, logs, tc.wantLogs, "Unexpected number of scripts logs kept")
+
+			for _, l := range logs {
+				require.True(t, strings.HasSuffix(l.Name(), "-s.log"), "Log file should be named after the run lifecycle")
+				fi, err := l.Info()
+				require.NoError(t, err, "Can't stat scripts log")
+				require.Equal(t, fs.FileMode(0600), fi.Mode().Perm(), "Scripts log should only be readable by its owner")
+
+				got, err := os.ReadFile(filepath.Join(stateDir, logsDir, l.Name()))
+				require.NoError(t, err, "Can't read scripts log")
+				want := testutils.LoadWithUpdateFromGolden(t, string(got))
+				require.Equal(t, want, string(got), "Scripts log doesn't match")
+			}
+		})
+	}
+}
+
+func TestLogs(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		objectName string
+		computer   bool
+		count      int
+		noLogs     bool
+
+		userLookupError bool
+
+		wantErr bool
+	}{
+		"Last machine log":              {computer: true, count: 1},
+		"Last machine logs up to count": {computer: true, count: 2},
+		"All machine logs":              {computer: true},
+		"Count higher than logs":        {computer: true, count: 10},
+		"User logs":                     {},
+		"No logs":                       {computer: true, noLogs: true},
+		"No user logs":                  {objectName: "alice", noLogs: true},
+
+		"Error on user lookup failing": {userLookupError: true, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.objectName == "" {
+				tc.objectName = "bob"
+			}
+			userLookup := func(name string) (*user.User, error) {
+				if tc.userLookupError {
+					return nil, errors.New("User error requested")
+				}
+				if name == "bob" {
+					return &user.User{Uid: "4242", Gid: "4242"}, nil
+				}
+				return &user.User{Uid: "4343", Gid: "4343"}, nil
+			}
+
+			stateDir := filepath.Join(testutils.TestFamilyPath(t), "state")
+			if tc.noLogs {
+				stateDir = t.TempDir()
+			}
+			m, err := scripts.New(t.TempDir(), &mockUnitStarter{}, scripts.WithStateDir(stateDir), scripts.WithUserLookup(userLookup))
+			require.NoError(t, err, "Setup: can't create scripts manager")
+
+			got, err := m.Logs(context.Background(), tc.objectName, tc.computer, tc.count)
+			if tc.wantErr {
+				require.Error(t, err, "Logs should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "Logs failed but shouldn't have")
+
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Logs returned unexpected content")
+		})
+	}
+}
+
 type mockUnitStarter struct {
 	testutils.MockSystemdCaller
 
-	StartFailed bool
+	StartFailed  bool
+	ReloadFailed bool
+
+	// Started receives the asynchronous scripts services, which are blocked until Release is closed.
+	Started chan<- string
+	Release <-chan struct{}
+}
+
+func (s mockUnitStarter) DaemonReload(_ context.Context) error {
+	if s.ReloadFailed {
+		return errors.New("failed to reload systemd")
+	}
+	return nil
 }
 
-func (s mockUnitStarter) StartUnit(_ context.Context, _ string) error {
+func (s mockUnitStarter) StartUnit(_ context.Context, unit string) error {
 	if s.StartFailed {
 		return errors.New("failed to start unit")
 	}
+	if s.Started == nil {
+		return nil
+	}
+	s.Started <- unit
+	if strings.Contains(unit, "async") {
+		<-s.Release
+	}
 	return nil
 }
@@ -0,0 +1 @@
+script 93
\ No newline at end of file
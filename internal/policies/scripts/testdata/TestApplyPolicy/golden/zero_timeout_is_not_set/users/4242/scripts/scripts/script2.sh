@@ -0,0 +1 @@
+script 2
\ No newline at end of file
@@ -0,0 +1 @@
+script subfolder/1
\ No newline at end of file
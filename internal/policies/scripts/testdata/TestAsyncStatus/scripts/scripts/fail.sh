@@ -0,0 +1,4 @@
+#!/bin/sh
+
+echo "background task failed" >&2
+exit 3
@@ -0,0 +1,3 @@
+#!/bin/sh
+
+echo "background task done"
@@ -0,0 +1,11 @@
+#!/bin/sh
+
+script=$(realpath $0)
+# Our scripts are in: user/foo/scripts/scripts.
+# We want to write our golden file in user/foo/.
+path=$(dirname $(dirname $(dirname ${script})))
+
+echo $(basename $0) >> "${path}/golden"
+
+# Keep writing to the script output once it exited.
+(sleep 0.5; echo "still running"; echo background >> "${path}/golden") &
@@ -0,0 +1,4 @@
+#!/bin/sh
+echo "some output"
+echo "on multiple lines"
+printf "without final newline"
@@ -0,0 +1,2 @@
+#!/bin/sh
+echo "connecting with password ${ADSYS_TEST_PASSWORD} as ${ADSYS_TEST_USER}"
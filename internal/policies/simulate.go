@@ -0,0 +1,110 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+// SimulatePolicies returns what applying pols would do for objectName, for each policy manager, without modifying
+// the system nor reading what is currently applied.
+// The dconf and gdm managers show the databases they would write. The other managers show the rules they would
+// apply, once resolved between GPOs and with their variables expanded. Rules of the policy types only available to
+// Ubuntu Pro subscribers are filtered out, as when applying them, if the machine is not enrolled.
+func (m *Manager) SimulatePolicies(ctx context.Context, objectName string, isComputer bool, pols *Policies) (msg string, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to simulate policies for %q", objectName))
+
+	log.Info(ctx, gotext.Get("Simulating policies for %s (machine: %v)", objectName, isComputer))
+
+	expanded, err := pols.expandVariables(variables(m.hostname, m.backend.Domain(), objectName, isComputer))
+	if err != nil {
+		return "", err
+	}
+	rules := expanded.GetUniqueRules()
+	for _, c := range dconfConflicts(expanded.GPOs) {
+		log.Warning(ctx, gotext.Get("dconf key %q is set differently by multiple GPOs: using %s from %q, overriding %s",
+			c.Key, conflictValue(c), c.GPO, strings.Join(c.Overridden, ", ")))
+	}
+
+	var filtered []string
+	if !m.GetSubscriptionState(ctx) {
+		filtered = filterRules(ctx, rules)
+	}
+
+	var out strings.Builder
+	for _, name := range Managers {
+		// gdm only applies to the machine.
+		if name == "gdm" && !isComputer {
+			continue
+		}
+
+		fmt.Fprintf(&out, "* %s\n", name)
+		if slices.Contains(filtered, name) {
+			fmt.Fprintf(&out, "** %s\n", gotext.Get("filtered out as the machine is not enrolled to Ubuntu Pro"))
+			continue
+		}
+
+		switch name {
+		case "dconf":
+			files, err := m.dconf.SimulatePolicy(ctx, objectName, isComputer, rules[name])
+			if err != nil {
+				return "", err
+			}
+			formatSimulatedFiles(&out, files)
+		case "gdm":
+			files, err := m.gdm.SimulatePolicy(ctx, rules[name])
+			if err != nil {
+				return "", err
+			}
+			formatSimulatedFiles(&out, files)
+		default:
+			formatSimulatedRules(&out, rules[name])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// formatSimulatedFiles writes to w the content of files, ordered by path.
+func formatSimulatedFiles(w io.Writer, files map[string]string) {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		fmt.Fprintf(w, "** %s\n", p)
+		content := files[p]
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		fmt.Fprint(w, content)
+	}
+}
+
+// formatSimulatedRules writes to w the rules, disabled ones prepended with +, as in the policies dump.
+func formatSimulatedRules(w io.Writer, rules []entry.Entry) {
+	if len(rules) == 0 {
+		fmt.Fprintf(w, "** %s\n", gotext.Get("no policy"))
+		return
+	}
+
+	for _, r := range rules {
+		if r.Disabled {
+			fmt.Fprintf(w, "***+ %s\n", r.Key)
+			continue
+		}
+		// Keep each value printed in one single line.
+		v := strings.ReplaceAll(strings.TrimSpace(r.Value), "\n", `\n`)
+		fmt.Fprintf(w, "*** %s: %s\n", r.Key, v)
+	}
+}
@@ -0,0 +1,122 @@
+package policies
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/decorate"
+	"gopkg.in/yaml.v3"
+)
+
+// snapshotFormatVersion is the version of the snapshot format. It is increased on any incompatible change.
+const snapshotFormatVersion = 1
+
+// Snapshot is a portable copy of the policies applied to an object, with the GPOs they come from and their assets.
+type Snapshot struct {
+	Version    int    `yaml:"version"`
+	Object     string `yaml:"object"`
+	IsComputer bool   `yaml:"computer"`
+	GPOs       []GPO  `yaml:"gpos"`
+	Assets     []byte `yaml:"assets,omitempty"`
+}
+
+// Snapshot returns a snapshot of the policies applied to objectName.
+func (pols Policies) Snapshot(objectName string, isComputer bool) (s Snapshot, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't create snapshot of policies for %q", objectName))
+
+	s = Snapshot{
+		Version:    snapshotFormatVersion,
+		Object:     objectName,
+		IsComputer: isComputer,
+		GPOs:       pols.GPOs,
+	}
+	if pols.assets == nil {
+		return s, nil
+	}
+
+	var assets bytes.Buffer
+	if _, err := io.Copy(&assets, &readerAtToReader{ReaderAt: pols.assets.filemmap}); err != nil {
+		return s, err
+	}
+	s.Assets = assets.Bytes()
+
+	return s, nil
+}
+
+// Write serializes the snapshot to w.
+func (s Snapshot) Write(w io.Writer) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't write policies snapshot"))
+
+	enc := yaml.NewEncoder(w)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ReadSnapshot deserializes a snapshot from r.
+// Snapshots written with another format version are refused.
+func ReadSnapshot(r io.Reader) (s Snapshot, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't read policies snapshot"))
+
+	d, err := io.ReadAll(r)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.Unmarshal(d, &s); err != nil {
+		return s, err
+	}
+
+	if s.Version != snapshotFormatVersion {
+		return Snapshot{}, errors.New(gotext.Get("incompatible snapshot format version %d, expected %d", s.Version, snapshotFormatVersion))
+	}
+	if s.Object == "" {
+		return Snapshot{}, errors.New(gotext.Get("snapshot is missing the object its policies apply to"))
+	}
+	if len(s.Assets) > 0 {
+		if _, err := zip.NewReader(bytes.NewReader(s.Assets), int64(len(s.Assets))); err != nil {
+			return Snapshot{}, errors.New(gotext.Get("invalid assets in snapshot: %v", err))
+		}
+	}
+
+	return s, nil
+}
+
+// Save stores the snapshot policies in p, from where they can be loaded with NewFromCache.
+func (s Snapshot) Save(ctx context.Context, p string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't save policies snapshot to %s", p))
+
+	log.Debugf(ctx, "Saving policies snapshot of %q to %s", s.Object, p)
+
+	if err := os.MkdirAll(p, 0700); err != nil {
+		return err
+	}
+
+	// Assets are loaded from their final location, so that they are not copied again when saving the policies.
+	assetPath := filepath.Join(p, policiesAssetsFileName)
+	var assetsDBPath string
+	if len(s.Assets) > 0 {
+		if err := os.WriteFile(assetPath+".new", s.Assets, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(assetPath+".new", assetPath); err != nil {
+			return err
+		}
+		assetsDBPath = assetPath
+	}
+
+	pols, err := New(ctx, s.GPOs, assetsDBPath)
+	if err != nil {
+		return err
+	}
+	defer pols.Close()
+
+	return pols.Save(p)
+}
@@ -0,0 +1,105 @@
+package policies_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		cacheSrc   string
+		isComputer bool
+	}{
+		"gpos only":              {cacheSrc: "simple"},
+		"With assets":            {cacheSrc: "with_assets"},
+		"Machine snapshot":       {cacheSrc: "with_assets", isComputer: true},
+		"Multiple GPOs snapshot": {cacheSrc: "two_gpos_with_overrides"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := policies.NewFromCache(context.Background(), filepath.Join("testdata", "cache", "policies", tc.cacheSrc))
+			require.NoError(t, err, "Setup: NewFromCache should return no error but got one")
+			defer src.Close()
+
+			snapshot, err := src.Snapshot("object", tc.isComputer)
+			require.NoError(t, err, "Snapshot should return no error but got one")
+
+			var b bytes.Buffer
+			require.NoError(t, snapshot.Write(&b), "Write should return no error but got one")
+
+			got, err := policies.ReadSnapshot(&b)
+			require.NoError(t, err, "ReadSnapshot should return no error but got one")
+			require.Equal(t, "object", got.Object, "ReadSnapshot should return the object of the snapshot")
+			require.Equal(t, tc.isComputer, got.IsComputer, "ReadSnapshot should return the object class of the snapshot")
+
+			dest := t.TempDir()
+			require.NoError(t, got.Save(context.Background(), dest), "Save should return no error but got one")
+
+			pols, err := policies.NewFromCache(context.Background(), dest)
+			require.NoError(t, err, "Imported snapshot should be loaded from cache")
+			defer pols.Close()
+
+			require.Equal(t, src.GPOs, pols.GPOs, "Imported snapshot should have the GPOs of the exported policies")
+			require.Equal(t, src.ResolveRules(), pols.ResolveRules(), "Imported snapshot should keep the GPOs the rules come from")
+			require.Equal(t, src.HasAssets(), pols.HasAssets(), "Imported snapshot should have the assets of the exported policies")
+			if !src.HasAssets() {
+				return
+			}
+
+			wantAssets, gotAssets := filepath.Join(t.TempDir(), "want"), filepath.Join(t.TempDir(), "got")
+			require.NoError(t, src.SaveAssetsTo(context.Background(), ".", wantAssets, -1, -1), "Setup: can't save exported assets")
+			require.NoError(t, pols.SaveAssetsTo(context.Background(), ".", gotAssets, -1, -1), "Setup: can't save imported assets")
+			testutils.CompareTreesWithFiltering(t, gotAssets, wantAssets, false)
+		})
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		snapshot string
+
+		wantErr bool
+	}{
+		"Snapshot without GPOs": {snapshot: "version: 1\nobject: user\n"},
+
+		// Error cases
+		"Error on incompatible version":    {snapshot: "version: 2\nobject: user\n", wantErr: true},
+		"Error on missing version":         {snapshot: "object: user\n", wantErr: true},
+		"Error on missing object":          {snapshot: "version: 1\n", wantErr: true},
+		"Error on invalid assets":          {snapshot: "version: 1\nobject: user\nassets: !!binary bm90IGEgemlw\n", wantErr: true},
+		"Error on invalid snapshot format": {snapshot: "version: [1\n", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := policies.ReadSnapshot(strings.NewReader(tc.snapshot))
+			if tc.wantErr {
+				require.Error(t, err, "ReadSnapshot should return an error but got none")
+				return
+			}
+			require.NoError(t, err, "ReadSnapshot should return no error but got one")
+
+			dest := t.TempDir()
+			require.NoError(t, got.Save(context.Background(), dest), "Save should return no error but got one")
+			_, err = os.Stat(filepath.Join(dest, policies.PoliciesAssetsFileName))
+			require.ErrorIs(t, err, os.ErrNotExist, "Snapshot without assets should not save any")
+		})
+	}
+}
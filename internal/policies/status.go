@@ -0,0 +1,175 @@
+package policies
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/scripts"
+	"github.com/ubuntu/decorate"
+)
+
+// Manager states, as reported in the status of an object.
+const (
+	// ManagerApplied is the state of a manager applying some rules.
+	ManagerApplied = "applied"
+	// ManagerUnchanged is the state of a manager whose rules were unchanged during the last policy application since
+	// the daemon started, which thus left the system as is.
+	ManagerUnchanged = "unchanged"
+	// ManagerFiltered is the state of a manager whose rules are not applied as the machine is not enrolled to Ubuntu Pro.
+	ManagerFiltered = "filtered"
+	// ManagerNoRules is the state of a manager without any rule to apply.
+	ManagerNoRules = "none"
+	// ManagerDisabled is the state of a manager disabled in the configuration, which doesn't apply any rule.
+	ManagerDisabled = "disabled"
+)
+
+// Status is the machine readable state of the policies applied to an object.
+// When the object is a user, the policies applied to the machine are reported too.
+type Status struct {
+	Machine                    *ObjectStatus `json:"machine,omitempty"`
+	User                       *ObjectStatus `json:"user,omitempty"`
+	LocalAdminsSuppressedSince *time.Time    `json:"local_admins_suppressed_since,omitempty"`
+	// Errors lists the problems preventing to report part of the status.
+	Errors []string `json:"errors"`
+}
+
+// ObjectStatus is the state of the policies applied to a user or the machine, since last update.
+type ObjectStatus struct {
+	Name        string           `json:"name"`
+	LastApplied *time.Time       `json:"last_applied,omitempty"`
+	GPOs        []GPOStatus      `json:"gpos"`
+	Managers    []ManagerStatus  `json:"managers"`
+	Conflicts   []ConflictStatus `json:"conflicts"`
+	// Timings are the durations of the policy managers during the last policy application since the daemon started.
+	Timings []ManagerTiming `json:"timings,omitempty"`
+	// AsyncScripts is the last run of the scripts running in the background, when the policies define some.
+	AsyncScripts *scripts.AsyncStatus `json:"async_scripts,omitempty"`
+}
+
+// GPOStatus is a GPO applied to an object, by decreasing precedence.
+type GPOStatus struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ManagerStatus is the state of a policy manager for an object.
+type ManagerStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Rules int    `json:"rules"`
+}
+
+// ConflictStatus is a dconf key set differently by multiple GPOs.
+type ConflictStatus struct {
+	Key        string   `json:"key"`
+	Value      string   `json:"value"`
+	Disabled   bool     `json:"disabled"`
+	GPO        string   `json:"gpo"`
+	Overridden []string `json:"overridden"`
+}
+
+// Status returns the state of the currently applied policies (since last update) for objectName.
+// As with DumpPolicies, the machine policies are reported in addition to the user ones if computerOnly is false.
+func (m *Manager) Status(ctx context.Context, objectName string, computerOnly bool) (s Status, err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to get policies status for %q", objectName))
+
+	log.Infof(ctx, "Getting policies status for %s", objectName)
+
+	s.Errors = []string{}
+	proEnabled := m.GetSubscriptionState(ctx)
+
+	machine := m.hostname
+	if computerOnly {
+		machine = objectName
+	}
+	if s.Machine, err = m.objectStatus(ctx, machine, true, proEnabled, &s.Errors); err != nil {
+		return Status{}, err
+	}
+	if !computerOnly {
+		if s.User, err = m.objectStatus(ctx, objectName, false, proEnabled, &s.Errors); err != nil {
+			return Status{}, err
+		}
+	}
+
+	suppression, suppressed, err := m.privilege.LocalAdminsSuppression()
+	if err != nil {
+		s.Errors = append(s.Errors, gotext.Get("local administrators state unknown: %v", err))
+	} else if suppressed {
+		s.LocalAdminsSuppressedSince = &suppression.Since
+	}
+
+	return s, nil
+}
+
+// objectStatus returns the state of the policies cached for objectName.
+// Parts of the state which can't be determined are reported in errs.
+func (m *Manager) objectStatus(ctx context.Context, objectName string, isComputer, proEnabled bool, errs *[]string) (*ObjectStatus, error) {
+	pols, err := NewFromCache(ctx, filepath.Join(m.policiesCacheDir, objectName))
+	if err != nil {
+		return nil, errors.New(gotext.Get("no policy applied for %q: %v", objectName, err))
+	}
+	defer pols.Close()
+
+	s := &ObjectStatus{
+		Name:      objectName,
+		GPOs:      []GPOStatus{},
+		Managers:  []ManagerStatus{},
+		Conflicts: []ConflictStatus{},
+	}
+
+	if t, err := m.LastUpdateFor(ctx, objectName, false); err != nil {
+		*errs = append(*errs, err.Error())
+	} else {
+		s.LastApplied = &t
+	}
+
+	for _, g := range pols.GPOs {
+		s.GPOs = append(s.GPOs, GPOStatus{ID: g.ID, Name: g.Name})
+	}
+
+	rules := pols.GetUniqueRules()
+	unchanged := m.lastUnchanged(objectName)
+	for _, name := range Managers {
+		// The gdm policy only applies to the machine.
+		if name == "gdm" && !isComputer {
+			continue
+		}
+		state := ManagerApplied
+		switch {
+		case m.isDisabled(name):
+			state = ManagerDisabled
+		case len(rules[name]) == 0:
+			state = ManagerNoRules
+		case !proEnabled && slices.Contains(ProOnlyRules, name):
+			state = ManagerFiltered
+		case slices.Contains(unchanged, name):
+			state = ManagerUnchanged
+		}
+		s.Managers = append(s.Managers, ManagerStatus{Name: name, State: state, Rules: len(rules[name])})
+	}
+
+	s.Timings = m.LastTimings(objectName, false)
+
+	if scripts.HasAsyncScripts(rules["scripts"], isComputer) {
+		if s.AsyncScripts, err = m.scripts.AsyncStatus(ctx, objectName, isComputer); err != nil {
+			*errs = append(*errs, err.Error())
+		}
+	}
+
+	for _, c := range dconfConflicts(pols.GPOs) {
+		s.Conflicts = append(s.Conflicts, ConflictStatus{
+			Key:        c.Key,
+			Value:      c.Value,
+			Disabled:   c.Disabled,
+			GPO:        c.GPO,
+			Overridden: c.Overridden,
+		})
+	}
+
+	return s, nil
+}
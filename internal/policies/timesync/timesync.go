@@ -0,0 +1,257 @@
+// Package timesync provides a manager to apply the NTP servers the machine synchronizes its time with.
+//
+// Kerberos authentication fails when the clock of the client drifts too far from the domain controller one, so the
+// NTP servers are pushed to the time synchronization daemon installed on the machine: chrony, or systemd-timesyncd
+// otherwise. They can't both run on the same machine as chrony conflicts with systemd-timesyncd.
+//
+// The servers are written to a configuration drop-in of the daemon, which is restarted for them to be taken into
+// account if it is running: a daemon stopped by the administrator is left stopped. The drop-in is removed, and the
+// daemon restarted likewise, when the policy becomes empty or is disabled.
+//
+// The policy only applies to the machine: user policies are ignored.
+package timesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/audit"
+	"github.com/ubuntu/adsys/internal/fileutils"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/netaddr"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+const (
+	// serversKey is the entry key of the NTP servers.
+	serversKey = "ntp-servers"
+
+	// dropInName is the name of the configuration drop-in written for the time synchronization daemon.
+	dropInName = "adsys.conf"
+)
+
+// daemon is a time synchronization daemon, configured with drop-ins.
+type daemon struct {
+	name string
+	unit string
+	// binary is installed with the daemon.
+	binary    string
+	dropInDir string
+	// config returns the content of the drop-in setting servers.
+	config func(servers []string) string
+}
+
+// Manager prevents running multiple timesync update processes in parallel while applying the policy.
+type Manager struct {
+	daemons       []daemon
+	systemdCaller systemdCaller
+	mu            sync.Mutex
+}
+
+type systemdCaller interface {
+	TryRestartUnit(context.Context, string) error
+}
+
+type options struct {
+	chronydPath      string
+	chronyConfDir    string
+	timesyncdPath    string
+	timesyncdConfDir string
+}
+
+// Option represents an optional function to change the timesync manager.
+type Option func(*options)
+
+// WithChronyConfDir overrides the default chrony configuration drop-in directory.
+func WithChronyConfDir(p string) Option {
+	return func(o *options) {
+		o.chronyConfDir = p
+	}
+}
+
+// WithTimesyncdConfDir overrides the default systemd-timesyncd configuration drop-in directory.
+func WithTimesyncdConfDir(p string) Option {
+	return func(o *options) {
+		o.timesyncdConfDir = p
+	}
+}
+
+// WithChronydPath overrides the default chronyd binary path, used to detect if chrony is installed.
+func WithChronydPath(p string) Option {
+	return func(o *options) {
+		o.chronydPath = p
+	}
+}
+
+// WithTimesyncdPath overrides the default systemd-timesyncd binary path, used to detect if it is installed.
+func WithTimesyncdPath(p string) Option {
+	return func(o *options) {
+		o.timesyncdPath = p
+	}
+}
+
+// New returns a new timesync policy manager.
+func New(systemdCaller systemdCaller, opts ...Option) *Manager {
+	args := options{
+		chronydPath:      "/usr/sbin/chronyd",
+		chronyConfDir:    "/etc/chrony/conf.d",
+		timesyncdPath:    "/lib/systemd/systemd-timesyncd",
+		timesyncdConfDir: "/etc/systemd/timesyncd.conf.d",
+	}
+	for _, o := range opts {
+		o(&args)
+	}
+
+	// chrony comes first, as it replaces systemd-timesyncd when installed.
+	return &Manager{
+		daemons: []daemon{
+			{
+				name:      "chrony",
+				unit:      "chrony.service",
+				binary:    args.chronydPath,
+				dropInDir: args.chronyConfDir,
+				config: func(servers []string) string {
+					var b strings.Builder
+					for _, s := range servers {
+						fmt.Fprintf(&b, "server %s iburst\n", s)
+					}
+					return b.String()
+				},
+			},
+			{
+				name:      "systemd-timesyncd",
+				unit:      "systemd-timesyncd.service",
+				binary:    args.timesyncdPath,
+				dropInDir: args.timesyncdConfDir,
+				config: func(servers []string) string {
+					return fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " "))
+				},
+			},
+		},
+		systemdCaller: systemdCaller,
+	}
+}
+
+// ApplyPolicy writes the NTP servers of entries to the configuration of the installed time synchronization daemon,
+// or removes them if there are none, and restarts the daemon if its configuration changed.
+func (m *Manager) ApplyPolicy(ctx context.Context, objectName string, isComputer bool, entries []entry.Entry) (err error) {
+	defer decorate.OnError(&err, gotext.Get("can't apply timesync policy to %s", objectName))
+
+	// Time synchronization only applies to the machine
+	if !isComputer {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var servers []string
+	for _, e := range entries {
+		if e.Key != serversKey {
+			log.Warning(ctx, gotext.Get("Encountered unsupported key '%s' while parsing timesync entries, skipping it", e.Key))
+			continue
+		}
+		if e.Disabled {
+			log.Debug(ctx, gotext.Get("The entry %q is disabled and will be skipped", e.Key))
+			continue
+		}
+		if servers, err = parseServers(e.Value); err != nil {
+			return err
+		}
+	}
+
+	log.Debugf(ctx, "Applying timesync policy to %s", objectName)
+
+	active := m.installedDaemon()
+	if active == nil && len(servers) > 0 {
+		log.Warning(ctx, gotext.Get("No supported time synchronization daemon is installed, NTP servers are not applied"))
+	}
+
+	// Drop-ins of a daemon which is not installed anymore are removed too, without restarting it.
+	for _, d := range m.daemons {
+		isActive := active != nil && d.name == active.name
+		var changed bool
+		if isActive && len(servers) > 0 {
+			changed, err = d.writeDropIn(ctx, servers)
+		} else {
+			changed, err = d.removeDropIn()
+		}
+		if err != nil {
+			return err
+		}
+
+		if !isActive || !changed {
+			continue
+		}
+		log.Infof(ctx, "Restarting %s to apply the NTP servers", d.name)
+		if err := m.systemdCaller.TryRestartUnit(ctx, d.unit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// installedDaemon returns the time synchronization daemon installed on the machine, or nil if there is none.
+func (m *Manager) installedDaemon() *daemon {
+	for i, d := range m.daemons {
+		if _, err := os.Stat(d.binary); err == nil {
+			return &m.daemons[i]
+		}
+	}
+	return nil
+}
+
+// writeDropIn writes the drop-in of d setting servers. It returns true if the drop-in was written.
+func (d daemon) writeDropIn(ctx context.Context, servers []string) (changed bool, err error) {
+	// The drop-in directory is not shipped by the daemons, and is world readable like the rest of their configuration.
+	// #nosec G301
+	if err := os.MkdirAll(d.dropInDir, 0755); err != nil {
+		return false, err
+	}
+
+	p := filepath.Join(d.dropInDir, dropInName)
+	changed, err = fileutils.WriteIfChanged(p, []byte(fileutils.ManagedHeader+"\n"+d.config(servers)), 0644)
+	if err != nil {
+		return false, err
+	}
+	if changed {
+		audit.RecordFile(ctx, p)
+	}
+	return changed, nil
+}
+
+// removeDropIn removes the drop-in of d, if any. It returns true if the drop-in was removed.
+func (d daemon) removeDropIn() (removed bool, err error) {
+	err = os.Remove(filepath.Join(d.dropInDir, dropInName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parseServers returns the NTP servers of the lines of value, in order and without duplicates. Each line can list
+// multiple servers, separated by spaces. Servers are IP addresses or host names.
+func parseServers(value string) (servers []string, err error) {
+	for _, server := range strings.Fields(value) {
+		if !netaddr.ValidHost(server) {
+			return nil, errors.New(gotext.Get("invalid NTP server %q: expected an IP address or a host name", server))
+		}
+		if slices.Contains(servers, server) {
+			continue
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
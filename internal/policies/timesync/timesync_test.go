@@ -0,0 +1,158 @@
+package timesync_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/policies/timesync"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestApplyPolicy(t *testing.T) {
+	t.Parallel()
+
+	defaultServers := []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com\n10.0.0.1"}}
+	timesyncd := []string{"timesyncd"}
+	chrony := []string{"chrony"}
+
+	tests := map[string]struct {
+		entries      []entry.Entry
+		isUser       bool
+		installed    []string
+		existing     string
+		restartFails bool
+
+		wantRestarted []string
+		wantErr       bool
+	}{
+		// systemd-timesyncd cases
+		"Set servers with systemd-timesyncd":    {entries: defaultServers, installed: timesyncd, wantRestarted: []string{"systemd-timesyncd.service"}},
+		"Update servers with systemd-timesyncd": {entries: defaultServers, installed: timesyncd, existing: "timesyncd_dropin", wantRestarted: []string{"systemd-timesyncd.service"}},
+		"Unchanged servers don't restart systemd-timesyncd": {
+			entries: []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com ntp2.example.com"}}, installed: timesyncd, existing: "timesyncd_dropin"},
+		"Remove servers with systemd-timesyncd": {installed: timesyncd, existing: "timesyncd_dropin", wantRestarted: []string{"systemd-timesyncd.service"}},
+
+		// chrony cases
+		"Set servers with chrony":    {entries: defaultServers, installed: chrony, wantRestarted: []string{"chrony.service"}},
+		"Update servers with chrony": {entries: defaultServers, installed: chrony, existing: "chrony_dropin", wantRestarted: []string{"chrony.service"}},
+		"Unchanged servers don't restart chrony": {
+			entries: []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com\nntp2.example.com"}}, installed: chrony, existing: "chrony_dropin"},
+		"Remove servers with chrony":             {installed: chrony, existing: "chrony_dropin", wantRestarted: []string{"chrony.service"}},
+		"Chrony is used when both are installed": {entries: defaultServers, installed: []string{"chrony", "timesyncd"}, wantRestarted: []string{"chrony.service"}},
+		"Remove drop-in of a daemon not installed anymore without restarting it": {
+			entries: defaultServers, installed: chrony, existing: "timesyncd_dropin", wantRestarted: []string{"chrony.service"}},
+
+		// Entries cases
+		"Set normalized and deduplicated servers": {
+			entries:   []entry.Entry{{Key: "ntp-servers", Value: "  ntp1.example.com\tntp2.example.com\n\nntp1.example.com\nfd00::1\nntp3.example.com.\n"}},
+			installed: timesyncd, wantRestarted: []string{"systemd-timesyncd.service"},
+		},
+		"Unsupported keys are ignored": {
+			entries:   []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com"}, {Key: "unsupported", Value: "ntp2.example.com"}},
+			installed: timesyncd, wantRestarted: []string{"systemd-timesyncd.service"},
+		},
+		"Remove drop-in when the entry is disabled": {
+			entries: []entry.Entry{{Key: "ntp-servers", Disabled: true}}, installed: timesyncd, existing: "timesyncd_dropin", wantRestarted: []string{"systemd-timesyncd.service"},
+		},
+		"Remove drop-in when the entry has no values": {
+			entries: []entry.Entry{{Key: "ntp-servers", Value: "\n  \n"}}, installed: timesyncd, existing: "timesyncd_dropin", wantRestarted: []string{"systemd-timesyncd.service"},
+		},
+		"No entries without drop-in doesn't restart the daemon": {installed: timesyncd},
+		"No daemon installed doesn't apply servers":             {entries: defaultServers},
+
+		// User cases
+		"User policy is ignored":                 {entries: defaultServers, installed: timesyncd, isUser: true},
+		"User policy doesn't remove the drop-in": {installed: timesyncd, existing: "timesyncd_dropin", isUser: true},
+
+		// Error cases
+		"Error on invalid server":                {entries: []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com\nntp_2.example.com"}}, installed: timesyncd, existing: "timesyncd_dropin", wantErr: true},
+		"Error on invalid IP address":            {entries: []entry.Entry{{Key: "ntp-servers", Value: "10.0.0.256"}}, installed: timesyncd, existing: "timesyncd_dropin", wantErr: true},
+		"Error on server with URL":               {entries: []entry.Entry{{Key: "ntp-servers", Value: "ntp://ntp1.example.com"}}, installed: timesyncd, existing: "timesyncd_dropin", wantErr: true},
+		"Error when restarting the daemon fails": {entries: defaultServers, installed: timesyncd, restartFails: true, wantErr: true},
+		"Error when drop-in directory is a file": {entries: defaultServers, installed: timesyncd, existing: "dropin_dir_is_file", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			binDir := t.TempDir()
+			for _, d := range tc.installed {
+				testutils.WriteFile(t, filepath.Join(binDir, d), []byte("#!/bin/sh\n"), 0600)
+			}
+
+			etcDir := filepath.Join(t.TempDir(), "etc")
+			if tc.existing != "" {
+				testutils.Copy(t, filepath.Join("testdata", "etc", tc.existing), etcDir)
+			} else {
+				require.NoError(t, os.MkdirAll(etcDir, 0750), "Setup: can't create etc directory")
+			}
+
+			systemd := &mockSystemdCaller{restartFails: tc.restartFails}
+			m := timesync.New(systemd,
+				timesync.WithChronydPath(filepath.Join(binDir, "chrony")),
+				timesync.WithChronyConfDir(filepath.Join(etcDir, "chrony", "conf.d")),
+				timesync.WithTimesyncdPath(filepath.Join(binDir, "timesyncd")),
+				timesync.WithTimesyncdConfDir(filepath.Join(etcDir, "systemd", "timesyncd.conf.d")),
+			)
+
+			err := m.ApplyPolicy(context.Background(), "ubuntu", !tc.isUser, tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "ApplyPolicy should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "ApplyPolicy should succeed but didn't")
+
+			require.Equal(t, tc.wantRestarted, systemd.restarted, "ApplyPolicy should restart the expected units")
+			testutils.CompareTreesWithFiltering(t, etcDir, testutils.GoldenPath(t), testutils.UpdateEnabled())
+		})
+	}
+}
+
+func TestApplyPolicyKeepsDropInOnError(t *testing.T) {
+	t.Parallel()
+
+	binDir := t.TempDir()
+	testutils.WriteFile(t, filepath.Join(binDir, "timesyncd"), []byte("#!/bin/sh\n"), 0600)
+	etcDir := filepath.Join(t.TempDir(), "etc")
+	testutils.Copy(t, filepath.Join("testdata", "etc", "timesyncd_dropin"), etcDir)
+	dropIn := filepath.Join(etcDir, "systemd", "timesyncd.conf.d", "adsys.conf")
+
+	original, err := os.ReadFile(dropIn)
+	require.NoError(t, err, "Setup: can't read drop-in")
+
+	systemd := &mockSystemdCaller{}
+	m := timesync.New(systemd,
+		timesync.WithChronydPath(filepath.Join(binDir, "chrony")),
+		timesync.WithChronyConfDir(filepath.Join(etcDir, "chrony", "conf.d")),
+		timesync.WithTimesyncdPath(filepath.Join(binDir, "timesyncd")),
+		timesync.WithTimesyncdConfDir(filepath.Dir(dropIn)),
+	)
+
+	err = m.ApplyPolicy(context.Background(), "ubuntu", true, []entry.Entry{{Key: "ntp-servers", Value: "ntp1.example.com\n-invalid"}})
+	require.Error(t, err, "ApplyPolicy should fail on an invalid server")
+
+	got, err := os.ReadFile(dropIn)
+	require.NoError(t, err, "Drop-in should still exist")
+	require.Equal(t, string(original), string(got), "Drop-in should not have been modified")
+	require.Empty(t, systemd.restarted, "No unit should have been restarted")
+}
+
+type mockSystemdCaller struct {
+	restartFails bool
+
+	restarted []string
+}
+
+func (s *mockSystemdCaller) TryRestartUnit(_ context.Context, unit string) error {
+	if s.restartFails {
+		return errors.New("failed to restart unit")
+	}
+	s.restarted = append(s.restarted, unit)
+	return nil
+}
@@ -0,0 +1,131 @@
+package policies
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonelquinteros/gotext"
+	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+)
+
+// ManagerTiming is the time a policy manager took to apply the policies of an object.
+type ManagerTiming struct {
+	Manager  string        `json:"manager"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// timingsRecorder records the time policy managers take to apply the policies of an object, and which of them
+// completed successfully, out of total, to report the progress of the update.
+// It is safe for concurrent use, as managers run in parallel.
+type timingsRecorder struct {
+	mu        sync.Mutex
+	timings   []ManagerTiming
+	completed []string
+	total     int
+}
+
+// timed runs the apply function of manager for objectName and records how long it took.
+func (m *Manager) timed(ctx context.Context, r *timingsRecorder, objectName, manager string, apply func() error) (err error) {
+	start := m.now()
+	defer func() {
+		elapsed := m.now().Sub(start)
+		log.Debugf(ctx, "%s policy manager took %s for %s", manager, elapsed, objectName)
+		m.metrics.RecordManager(manager, elapsed, err)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.timings = append(r.timings, ManagerTiming{Manager: manager, Duration: elapsed})
+		if err != nil {
+			return
+		}
+		r.completed = append(r.completed, manager)
+		// Reported with the lock held, so that progress is reported in order.
+		ReportProgress(ctx, Progress{Target: objectName, Stage: StageApply, Manager: manager, Completed: len(r.completed), Total: r.total})
+	}()
+	return apply()
+}
+
+// cancelledError returns err as is, unless ctx is done: the policies were then partially applied and the returned
+// error lists the policy managers which completed.
+func (r *timingsRecorder) cancelledError(ctx context.Context, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+
+	r.mu.Lock()
+	completed := slices.Clone(r.completed)
+	r.mu.Unlock()
+	slices.SortFunc(completed, func(a, b string) int {
+		return slices.Index(Managers, a) - slices.Index(Managers, b)
+	})
+
+	done := gotext.Get("none")
+	if len(completed) > 0 {
+		done = strings.Join(completed, ", ")
+	}
+	return fmt.Errorf("%w: %s", ctx.Err(), gotext.Get("policies were partially applied, completed policy managers: %s: %v", done, err))
+}
+
+// changedManagers returns, in the Managers order, the policy managers recorded by r as completed with different rules
+// than the previous ones, and those which completed with the same rules.
+func (r *timingsRecorder) changedManagers(previous, rules map[string][]entry.Entry) (changed, unchanged []string) {
+	r.mu.Lock()
+	completed := slices.Clone(r.completed)
+	r.mu.Unlock()
+
+	changed = []string{}
+	for _, name := range Managers {
+		if !slices.Contains(completed, name) {
+			continue
+		}
+		if reflect.DeepEqual(previous[name], rules[name]) {
+			unchanged = append(unchanged, name)
+			continue
+		}
+		changed = append(changed, name)
+	}
+	return changed, unchanged
+}
+
+// saveTimings stores the timings recorded by r as the last ones of objectName, in the Managers order, along with the
+// policy managers which completed with unchanged rules.
+func (m *Manager) saveTimings(objectName string, r *timingsRecorder, unchanged []string) {
+	r.mu.Lock()
+	timings := slices.Clone(r.timings)
+	r.mu.Unlock()
+
+	slices.SortFunc(timings, func(a, b ManagerTiming) int {
+		return slices.Index(Managers, a.Manager) - slices.Index(Managers, b.Manager)
+	})
+
+	m.timingsMu.Lock()
+	defer m.timingsMu.Unlock()
+	m.timings[objectName] = timings
+	m.unchanged[objectName] = unchanged
+}
+
+// LastTimings returns how long each policy manager took during the last policy application of objectName, or of
+// the current machine. Only the managers which ran are listed. Timings are not kept across daemon restarts.
+func (m *Manager) LastTimings(objectName string, isMachine bool) []ManagerTiming {
+	if isMachine {
+		objectName = m.hostname
+	}
+
+	m.timingsMu.Lock()
+	defer m.timingsMu.Unlock()
+	return slices.Clone(m.timings[objectName])
+}
+
+// lastUnchanged returns the policy managers which completed with unchanged rules, and thus without any effect, during
+// the last policy application of objectName.
+func (m *Manager) lastUnchanged(objectName string) []string {
+	m.timingsMu.Lock()
+	defer m.timingsMu.Unlock()
+	return slices.Clone(m.unchanged[objectName])
+}
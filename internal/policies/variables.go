@@ -0,0 +1,93 @@
+package policies
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/leonelquinteros/gotext"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/decorate"
+)
+
+// Variables which can be used in the values of policy entries, expanded when applying the policies.
+const (
+	// VarComputerName is the host name of the machine, without its domain.
+	VarComputerName = "COMPUTERNAME"
+	// VarDomain is the AD domain of the machine.
+	VarDomain = "DOMAIN"
+	// VarUsername is the name of the user the policies apply to, without its domain. It is only defined for users.
+	VarUsername = "USERNAME"
+)
+
+// variableRe matches the variables in entry values: upper case names surrounded by %, like Windows environment
+// variables. Single letters are not matched to leave alone the formats of strftime, as in "%H%M".
+var variableRe = regexp.MustCompile(`%([A-Z][A-Z0-9_]+)%`)
+
+// variables returns the values of the variables which can be expanded in the policies of objectName, on the machine
+// hostname joined to domain.
+func variables(hostname, domain, objectName string, isComputer bool) map[string]string {
+	vars := map[string]string{
+		VarComputerName: hostname,
+		VarDomain:       domain,
+	}
+	if !isComputer {
+		username, _, _ := strings.Cut(objectName, "@")
+		vars[VarUsername] = username
+	}
+	return vars
+}
+
+// expandVariables returns a copy of pols where the variables in the values of the entries are replaced by their
+// value in vars. Disabled and deletion entries, which don't apply their value, are left untouched.
+// An unknown variable is an error, rather than silently expanding to an empty value.
+func (pols Policies) expandVariables(vars map[string]string) (expanded Policies, err error) {
+	defer decorate.OnError(&err, gotext.Get("can't expand variables in policies"))
+
+	expanded = pols
+	expanded.GPOs = make([]GPO, 0, len(pols.GPOs))
+	for _, g := range pols.GPOs {
+		rules := make(map[string][]entry.Entry, len(g.Rules))
+		for t, entries := range g.Rules {
+			entries = slices.Clone(entries)
+			for i, e := range entries {
+				if e.Disabled || e.Delete {
+					continue
+				}
+				v, err := expandValue(e.Value, vars)
+				if err != nil {
+					return Policies{}, errors.New(gotext.Get("%s/%s in GPO %q: %v", t, e.Key, g.Name, err))
+				}
+				entries[i].Value = v
+			}
+			rules[t] = entries
+		}
+		g.Rules = rules
+		expanded.GPOs = append(expanded.GPOs, g)
+	}
+	return expanded, nil
+}
+
+// expandValue replaces the variables in v by their value in vars.
+func expandValue(v string, vars map[string]string) (string, error) {
+	var unknown []string
+	expanded := variableRe.ReplaceAllStringFunc(v, func(match string) string {
+		name := strings.Trim(match, "%")
+		value, ok := vars[name]
+		if !ok {
+			unknown = append(unknown, match)
+			return match
+		}
+		return value
+	})
+	if len(unknown) > 0 {
+		var known []string
+		for name := range vars {
+			known = append(known, name)
+		}
+		slices.Sort(known)
+		return "", errors.New(gotext.Get("unknown variable %s, expected one of: %%%s%%", strings.Join(unknown, ", "), strings.Join(known, "%, %")))
+	}
+	return expanded, nil
+}
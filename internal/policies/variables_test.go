@@ -0,0 +1,99 @@
+package policies_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/policies"
+	"github.com/ubuntu/adsys/internal/policies/entry"
+	"github.com/ubuntu/adsys/internal/testutils"
+)
+
+func TestExpandVariables(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		rules      map[string][]entry.Entry
+		objectName string
+		isComputer bool
+
+		wantErr bool
+	}{
+		"Expand variables in dconf entries": {rules: map[string][]entry.Entry{"dconf": {
+			{Key: "org/gnome/desktop/background/picture-uri", Value: "'file:///usr/share/backgrounds/%DOMAIN%.png'"},
+			{Key: "org/gnome/login-screen/banner-message-text", Value: "'Welcome on %COMPUTERNAME%, %USERNAME%'"},
+		}}},
+		"Expand variables in mount entries": {rules: map[string][]entry.Entry{"mount": {
+			{Key: "user-mounts", Value: "smb://fileserver.%DOMAIN%/home/%USERNAME%\nnfs://%COMPUTERNAME%.%DOMAIN%/data"},
+		}}},
+		"Expand variables in machine policies": {isComputer: true, rules: map[string][]entry.Entry{"mount": {
+			{Key: "system-mounts", Value: "smb://fileserver.%DOMAIN%/machines/%COMPUTERNAME%"},
+		}}},
+		"Expand variables repeated in one value": {rules: map[string][]entry.Entry{"dconf": {
+			{Key: "com/example/paths", Value: "['/srv/%USERNAME%', '/backup/%USERNAME%']"},
+		}}},
+		"Expand variables of user without domain": {objectName: "bob", rules: map[string][]entry.Entry{"dconf": {
+			{Key: "com/example/user", Value: "'%USERNAME%'"},
+		}}},
+		"Expand variables in all GPOs": {rules: map[string][]entry.Entry{"dconf": {
+			{Key: "com/example/domain", Value: "'%DOMAIN%'"},
+		}, "scripts": {
+			{Key: "s/logon", Value: "%USERNAME%.sh"},
+		}}},
+
+		"Values which aren't variables are left untouched": {rules: map[string][]entry.Entry{"dconf": {
+			{Key: "org/gnome/desktop/interface/clock-format", Value: "'%H:%M'"},
+			{Key: "com/example/date", Value: "'%Y%m%d'"},
+			{Key: "com/example/percent", Value: "'100%'"},
+			{Key: "com/example/lowercase", Value: "'%domain%'"},
+		}}},
+		"Disabled and deletion entries are left untouched": {rules: map[string][]entry.Entry{"dconf": {
+			{Key: "com/example/disabled", Value: "'%UNKNOWN%'", Disabled: true},
+			{Key: "com/example/deleted", Value: "'%UNKNOWN%'", Delete: true},
+		}}},
+
+		// Error cases
+		"Error on unknown variable": {rules: map[string][]entry.Entry{"mount": {
+			{Key: "user-mounts", Value: "smb://%FILESERVER%/home/%USERNAME%"},
+		}}, wantErr: true},
+		"Error on username variable in machine policies": {isComputer: true, rules: map[string][]entry.Entry{"dconf": {
+			{Key: "com/example/user", Value: "'%USERNAME%'"},
+		}}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.objectName == "" {
+				tc.objectName = "bob@example.com"
+			}
+			if tc.isComputer {
+				tc.objectName = "keypress"
+			}
+
+			var pols policies.Policies
+			for _, typ := range []string{"dconf", "mount", "scripts"} {
+				if _, ok := tc.rules[typ]; !ok {
+					continue
+				}
+				pols.GPOs = append(pols.GPOs, policies.GPO{
+					ID:    typ + "-id",
+					Name:  typ + "-name",
+					Rules: map[string][]entry.Entry{typ: tc.rules[typ]},
+				})
+			}
+			orig := pols.GetUniqueRules()
+
+			got, err := pols.ExpandVariables(policies.Variables("keypress", "example.com", tc.objectName, tc.isComputer))
+			if tc.wantErr {
+				require.Error(t, err, "ExpandVariables should have failed but didn't")
+				return
+			}
+			require.NoError(t, err, "ExpandVariables should not have failed")
+			require.Equal(t, orig, pols.GetUniqueRules(), "Original policies should not be modified")
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got.GetUniqueRules())
+			require.Equal(t, want, got.GetUniqueRules(), "ExpandVariables returned unexpected rules")
+		})
+	}
+}
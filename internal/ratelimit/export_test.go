@@ -0,0 +1,10 @@
+package ratelimit
+
+import "time"
+
+// WithClock sets the function returning the current time.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
@@ -0,0 +1,113 @@
+// Package ratelimit coalesces repeated requests for the same key, so that rapid requests trigger a single
+// underlying call and share its result.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter coalesces the calls for the same key:
+//   - calls requested within the coalescing window before the first one starts, or while it runs, join it;
+//   - calls requested within the minimum interval after the last one completed are served its result.
+//
+// A Limiter is safe for concurrent use.
+type Limiter struct {
+	minInterval time.Duration
+	window      time.Duration
+	now         func() time.Time
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// call is the last call for a key, which can be still in progress.
+type call struct {
+	done     chan struct{}
+	finished time.Time
+	err      error
+}
+
+type options struct {
+	now func() time.Time
+}
+
+// Option reconfigures the Limiter.
+type Option func(*options)
+
+// New returns a Limiter serving the result of the last call for a key during minInterval after it completed.
+// Each call is delayed by window to gather the calls requested meanwhile. A zero minInterval and window disable
+// the coalescing: every call then runs.
+func New(minInterval, window time.Duration, opts ...Option) *Limiter {
+	args := options{
+		now: time.Now,
+	}
+	for _, o := range opts {
+		o(&args)
+	}
+
+	return &Limiter{
+		minInterval: minInterval,
+		window:      window,
+		now:         args.now,
+		calls:       make(map[string]*call),
+	}
+}
+
+// Do runs f for key, unless a call for key is in progress or completed within the minimum interval: the result of
+// that call is returned instead and coalesced is true.
+// Waiting for a call run by another request stops with the error of ctx once it is done.
+func (l *Limiter) Do(ctx context.Context, key string, f func() error) (coalesced bool, err error) {
+	if l.minInterval <= 0 && l.window <= 0 {
+		return false, f()
+	}
+
+	l.mu.Lock()
+	if c, ok := l.calls[key]; ok {
+		select {
+		case <-c.done:
+			// The last call completed: reuse its result if recent enough.
+			if l.now().Sub(c.finished) < l.minInterval {
+				l.mu.Unlock()
+				return true, c.err
+			}
+		default:
+			l.mu.Unlock()
+			select {
+			case <-c.done:
+				return true, c.err
+			case <-ctx.Done():
+				return true, ctx.Err()
+			}
+		}
+	}
+	c := &call{done: make(chan struct{})}
+	l.calls[key] = c
+	l.mu.Unlock()
+
+	if l.window > 0 {
+		select {
+		case <-time.After(l.window):
+		case <-ctx.Done():
+			// Don't serve the cancellation of this request to the next ones.
+			l.mu.Lock()
+			if l.calls[key] == c {
+				delete(l.calls, key)
+			}
+			l.mu.Unlock()
+			c.err = ctx.Err()
+			close(c.done)
+			return false, c.err
+		}
+	}
+
+	err = f()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c.err = err
+	c.finished = l.now()
+	close(c.done)
+	return false, err
+}
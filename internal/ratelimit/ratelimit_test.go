@@ -0,0 +1,148 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/ubuntu/adsys/internal/ratelimit"
+)
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		minInterval time.Duration
+		window      time.Duration
+		keys        []string
+		concurrent  bool
+		// elapsed is how much time passes between sequential calls.
+		elapsed time.Duration
+		callErr error
+
+		wantCalls int
+	}{
+		"Rapid concurrent calls run once":                      {window: 50 * time.Millisecond, keys: []string{"user", "user", "user", "user", "user"}, concurrent: true, wantCalls: 1},
+		"Sequential calls within minimum interval run once":    {minInterval: time.Minute, keys: []string{"user", "user", "user"}, elapsed: time.Second, wantCalls: 1},
+		"Failed call result is served within minimum interval": {minInterval: time.Minute, keys: []string{"user", "user", "user"}, elapsed: time.Second, callErr: errors.New("fetch failed"), wantCalls: 1},
+
+		"Sequential calls after minimum interval run again": {minInterval: time.Minute, keys: []string{"user", "user", "user"}, elapsed: 2 * time.Minute, wantCalls: 3},
+		"Calls for different keys are not coalesced":        {minInterval: time.Minute, window: 10 * time.Millisecond, keys: []string{"user1", "user2", "user1", "user2"}, concurrent: true, wantCalls: 2},
+		"Disabled limiter runs every call":                  {keys: []string{"user", "user", "user"}, concurrent: true, wantCalls: 3},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+			clock := func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+				return now
+			}
+			l := ratelimit.New(tc.minInterval, tc.window, ratelimit.WithClock(clock))
+
+			var calls atomic.Int32
+			f := func() error {
+				calls.Add(1)
+				// Let the other concurrent calls join this one.
+				if tc.concurrent {
+					time.Sleep(10 * time.Millisecond)
+				}
+				return tc.callErr
+			}
+
+			var wg sync.WaitGroup
+			coalesced := make([]bool, len(tc.keys))
+			for i, key := range tc.keys {
+				do := func() {
+					c, err := l.Do(context.Background(), key, f)
+					coalesced[i] = c
+					require.ErrorIs(t, err, tc.callErr, "Do should return the result of the call")
+				}
+				if !tc.concurrent {
+					do()
+					mu.Lock()
+					now = now.Add(tc.elapsed)
+					mu.Unlock()
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					do()
+				}()
+			}
+			wg.Wait()
+
+			require.Equal(t, tc.wantCalls, int(calls.Load()), "Unexpected number of underlying calls")
+			var nCoalesced int
+			for _, c := range coalesced {
+				if c {
+					nCoalesced++
+				}
+			}
+			require.Equal(t, len(tc.keys)-tc.wantCalls, nCoalesced, "Calls which didn't run should be reported as coalesced")
+		})
+	}
+}
+
+func TestDoCancelled(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		withinWindow bool
+	}{
+		"Cancelled request waiting for a running call returns":                                      {},
+		"Cancelled request within the coalescing window returns and is not served to the next ones": {withinWindow: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			window := time.Duration(0)
+			if tc.withinWindow {
+				window = 50 * time.Millisecond
+			}
+			l := ratelimit.New(time.Minute, window)
+
+			release := make(chan struct{})
+			var calls atomic.Int32
+			f := func() error {
+				calls.Add(1)
+				<-release
+				return nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			if tc.withinWindow {
+				close(release)
+				_, err := l.Do(ctx, "user", f)
+				require.ErrorIs(t, err, context.Canceled, "Do should return the cancellation error")
+				require.Zero(t, calls.Load(), "Cancelled call should not run")
+
+				coalesced, err := l.Do(context.Background(), "user", f)
+				require.NoError(t, err, "Next request should run the call")
+				require.False(t, coalesced, "Next request should not be coalesced with the cancelled one")
+				require.Equal(t, int32(1), calls.Load(), "Next request should run the call")
+				return
+			}
+
+			defer close(release)
+			go func() { _, _ = l.Do(context.Background(), "user", f) }()
+			require.Eventually(t, func() bool { return calls.Load() == 1 }, 5*time.Second, 10*time.Millisecond, "Setup: first call should be running")
+
+			coalesced, err := l.Do(ctx, "user", f)
+			require.True(t, coalesced, "Do should join the running call")
+			require.ErrorIs(t, err, context.Canceled, "Do should return the cancellation error")
+			require.Equal(t, int32(1), calls.Load(), "Joining request should not run the call again")
+		})
+	}
+}
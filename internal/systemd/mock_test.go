@@ -42,6 +42,14 @@ func (s *systemdBus) StopUnit(name string, _ string) (dbus.ObjectPath, *dbus.Err
 	return s.emitJobSignals(name), nil
 }
 
+func (s *systemdBus) TryRestartUnit(name string, _ string) (dbus.ObjectPath, *dbus.Error) {
+	if name == absentUnit {
+		return dbus.ObjectPath("/"), errNoSuchUnit
+	}
+
+	return s.emitJobSignals(name), nil
+}
+
 func (s *systemdBus) EnableUnitFiles(names []string, _ bool, _ bool) (bool, [][]string, *dbus.Error) {
 	if len(names) != 1 {
 		panic("method is only expected to be called with a single name")
@@ -1,5 +1,5 @@
 // Package systemd provides a wrapper around systemd dbus API that allows basic
-// service operations (start/stop/enable/disable).
+// service operations (start/stop/try-restart/enable/disable).
 package systemd
 
 import (
@@ -60,6 +60,21 @@ func (s DefaultCaller) StopUnit(ctx context.Context, unit string) (err error) {
 	return nil
 }
 
+// TryRestartUnit restarts the given unit if it is running. A stopped unit is left as is.
+func (s DefaultCaller) TryRestartUnit(ctx context.Context, unit string) (err error) {
+	defer decorate.OnError(&err, gotext.Get("failed to restart unit %s", unit))
+
+	reschan := make(chan string)
+	if _, err = s.conn.TryRestartUnitContext(ctx, unit, "replace", reschan); err != nil {
+		return err
+	}
+
+	if job := <-reschan; job != jobDone {
+		return errors.New(gotext.Get("restart job failed"))
+	}
+	return nil
+}
+
 // EnableUnit enables the given unit.
 func (s DefaultCaller) EnableUnit(ctx context.Context, unit string) (err error) {
 	defer decorate.OnError(&err, gotext.Get("failed to enable unit %s", unit))
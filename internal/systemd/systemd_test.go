@@ -32,10 +32,11 @@ func TestManageUnit(t *testing.T) {
 
 		wantErr bool
 	}{
-		"Start unit that exists":   {action: "start"},
-		"Stop unit that exists":    {action: "stop"},
-		"Enable unit that exists":  {action: "enable"},
-		"Disable unit that exists": {action: "disable"},
+		"Start unit that exists":       {action: "start"},
+		"Stop unit that exists":        {action: "stop"},
+		"Try-restart unit that exists": {action: "try-restart"},
+		"Enable unit that exists":      {action: "enable"},
+		"Disable unit that exists":     {action: "disable"},
 
 		// Error cases
 		"Error when starting unit that doesn't exist": {unitName: absentUnit, action: "start", wantErr: true},
@@ -44,6 +45,9 @@ func TestManageUnit(t *testing.T) {
 		"Error when stopping unit that doesn't exist": {unitName: absentUnit, action: "stop", wantErr: true},
 		"Error when stopping failing unit":            {unitName: failingUnit, action: "stop", wantErr: true},
 
+		"Error when try-restarting unit that doesn't exist": {unitName: absentUnit, action: "try-restart", wantErr: true},
+		"Error when try-restarting failing unit":            {unitName: failingUnit, action: "try-restart", wantErr: true},
+
 		"Error when enabling unit that doesn't exist":  {unitName: absentUnit, action: "enable", wantErr: true},
 		"Error when disabling unit that doesn't exist": {unitName: absentUnit, action: "disable", wantErr: true},
 	}
@@ -62,6 +66,8 @@ func TestManageUnit(t *testing.T) {
 				err = systemdCaller.StartUnit(ctx, tc.unitName)
 			case "stop":
 				err = systemdCaller.StopUnit(ctx, tc.unitName)
+			case "try-restart":
+				err = systemdCaller.TryRestartUnit(ctx, tc.unitName)
 			case "enable":
 				err = systemdCaller.EnableUnit(ctx, tc.unitName)
 			case "disable":
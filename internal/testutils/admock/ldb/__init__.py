@@ -50,7 +50,7 @@ accounts = {}
 ##            -- RnDDep5 security access failed GPO                   <- security failed denied
 #  /example/RnD/RnDDep6                 <- RnDUserDep6
 ##            -- RnDDep6 security access denied GPO                   <- security access denied
-#  /example/RnD/RnDDep7                 <- RnDUserDep7
+#  /example/RnD/RnDDep7                 <- RnDUserDep7   <- hostnameRnDDep7
 ##            -- RnDDep7 machine only GPO                             <- user flag disabled
 #  /example/RnD/RnDDep8                 <- RnDUserDep8
 ##            -- RnDDep8 allow for one user only GPO  <- RnDUserDep8  <- nTSecurityDescriptor allowed for another user that our one
@@ -99,6 +99,9 @@ class OU:
 
         OUs[strdn] = self
 
+    def __str__(self):
+        return self.strdn
+
     def parent(self):
         ppath = path.dirname(self.strdn)
         if ppath == "":
@@ -245,6 +248,7 @@ o.addAccount("RnDUserDep6")
 o = OU("/example/RnD/RnDDep7")
 o.addGPO(GPO("RnDDep7 machine only GPO"))
 o.addAccount("RnDUserDep7")
+o.addAccount("hostnameRnDDep7")
 
 o = OU("/example/RnD/RnDDep8")
 o.addGPO(GPO("RnDDep8 allow for one user only GPO"))
@@ -2,6 +2,9 @@
 
 import os
 
+def get_supported_templates(_server):
+    return [b'Machine', b'Workstation']
+
 class gp_cert_auto_enroll_ext(object):
     def __init__(self, _lp, _credentials, _username, _store):
         pass
@@ -18,6 +21,7 @@ class gp_cert_auto_enroll_ext(object):
         print(f'guid: {guid}')
         print(f'trust_dir: {trust_dir}; mode: {oct(os.stat(trust_dir).st_mode)}')
         print(f'private_dir: {private_dir}; mode: {oct(os.stat(private_dir).st_mode)}')
+        print(f'templates: {[t.decode() for t in get_supported_templates("ca")]}')
 
         if entries == []:
             return
@@ -8,15 +8,20 @@ package testutils
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/termie/go-shutil"
+	"gopkg.in/yaml.v3"
 )
 
 // Chdir changes current directory to dir.
@@ -104,15 +109,108 @@ func MakeReadOnly(t *testing.T, dest string) {
 
 const fileForEmptyDir = ".empty"
 
+// IgnoreFunc reports if content, at path relative to the compared tree, is machine dependent and should not be
+// compared with the golden files.
+type IgnoreFunc func(path string, content []byte) bool
+
+// IgnoreDconfDB ignores the binary dconf databases, generated by dconf update.
+// This is the default file filter of CompareTreesWithFiltering.
+func IgnoreDconfDB(_ string, content []byte) bool {
+	return bytes.HasPrefix(content, []byte("GVariant"))
+}
+
+type compareTreesOptions struct {
+	ignoreFiles  []IgnoreFunc
+	ignoreLines  []IgnoreFunc
+	replacements []replacement
+	modes        modesComparison
+}
+
+// modesComparison is how file modes are compared with the golden files.
+type modesComparison int
+
+const (
+	// modesExecutable only compares the executable bit, which is the one git stores.
+	modesExecutable modesComparison = iota
+	// modesFull compares the permission bits, stored next to the golden files.
+	modesFull
+	// modesIgnored compares the content only.
+	modesIgnored
+)
+
+// goldenModesSuffix is the suffix of the file storing the modes of a golden file or directory.
+const goldenModesSuffix = ".modes"
+
+// replacement is a normalization of the content matching re.
+type replacement struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// CompareTreesOption represents an optional function that can be used to override
+// some of the tree comparison default values.
+type CompareTreesOption func(*compareTreesOptions)
+
+// WithIgnoredFiles replaces the default file filter: files for which any of ignore returns true are neither compared
+// nor committed in the golden directory.
+func WithIgnoredFiles(ignore ...IgnoreFunc) CompareTreesOption {
+	return func(o *compareTreesOptions) {
+		o.ignoreFiles = ignore
+	}
+}
+
+// WithIgnoredLines strips from each file the lines for which any of ignore returns true, before comparing it and
+// committing it in the golden directory.
+func WithIgnoredLines(ignore ...IgnoreFunc) CompareTreesOption {
+	return func(o *compareTreesOptions) {
+		o.ignoreLines = append(o.ignoreLines, ignore...)
+	}
+}
+
+// WithNormalizedContent replaces in each file the matches of re with repl, before comparing it and committing it in
+// the golden directory. This is useful for volatile content, like temporary paths, which can be replaced with a
+// placeholder. Replacements are applied in the order they are declared.
+func WithNormalizedContent(re *regexp.Regexp, repl string) CompareTreesOption {
+	return func(o *compareTreesOptions) {
+		o.replacements = append(o.replacements, replacement{re: re, repl: repl})
+	}
+}
+
+// WithFileModes compares the permission bits of the files and directories, and not only their executable bit.
+// As git doesn't store them, the modes are stored in a golden file suffixed with .modes, next to the golden path.
+func WithFileModes() CompareTreesOption {
+	return func(o *compareTreesOptions) {
+		o.modes = modesFull
+	}
+}
+
+// WithIgnoredFileModes only compares the content of the files, ignoring any mode difference.
+func WithIgnoredFileModes() CompareTreesOption {
+	return func(o *compareTreesOptions) {
+		o.modes = modesIgnored
+	}
+}
+
 // CompareTreesWithFiltering allows comparing a goldPath directory to p. Those can be updated via the dedicated flag.
 // It will filter dconf database and not commit it in the new golden directory.
-func CompareTreesWithFiltering(t *testing.T, p, goldPath string, update bool) {
+// Other machine dependent files and content can be filtered or normalized with opts.
+func CompareTreesWithFiltering(t *testing.T, p, goldPath string, update bool, opts ...CompareTreesOption) {
 	t.Helper()
 
+	args := compareTreesOptions{
+		ignoreFiles: []IgnoreFunc{IgnoreDconfDB},
+	}
+	for _, o := range opts {
+		o(&args)
+	}
+
 	// Update golden file
 	if update {
 		t.Logf("updating golden file %s", goldPath)
 		require.NoError(t, os.RemoveAll(goldPath), "Cannot remove target golden directory")
+		if args.modes == modesFull {
+			require.NoError(t, os.RemoveAll(goldPath+goldenModesSuffix), "Cannot remove target golden modes file")
+		}
 
 		// check the source directory exists before trying to copy it
 		info, err := os.Stat(p)
@@ -125,14 +223,17 @@ func CompareTreesWithFiltering(t *testing.T, p, goldPath string, update bool) {
 			// copy file
 			data, err := os.ReadFile(p)
 			require.NoError(t, err, "Cannot read new generated file file %s", p)
+			data = args.filterContent(filepath.Base(p), data)
+			require.NoError(t, os.MkdirAll(filepath.Dir(goldPath), 0750), "Cannot create golden directory")
 			require.NoError(t, os.WriteFile(goldPath, data, info.Mode()), "Cannot write golden file")
 		} else {
-			// Filter dconf generated DB files that are machine dependent
+			// Filter generated files that are machine dependent
 			require.NoError(t,
 				shutil.CopyTree(
 					p, goldPath,
-					&shutil.CopyTreeOptions{Symlinks: true, Ignore: ignoreDconfDB, CopyFunction: shutil.Copy}),
+					&shutil.CopyTreeOptions{Symlinks: true, Ignore: args.copyIgnore(p), CopyFunction: shutil.Copy}),
 				"Can’t update golden directory")
+			require.NoError(t, args.filterTreeContent(goldPath), "Cannot filter content of golden directory")
 			require.NoError(t, addEmptyMarker(goldPath), "Cannot create empty file in empty directories")
 		}
 	}
@@ -140,19 +241,16 @@ func CompareTreesWithFiltering(t *testing.T, p, goldPath string, update bool) {
 	var err error
 	var gotContent map[string]treeAttrs
 	if _, err := os.Stat(p); err == nil {
-		gotContent, err = treeContentAndAttrs(t, p, []byte("GVariant"))
+		gotContent, err = treeContentAndAttrs(t, p, args)
 		if err != nil {
 			t.Fatalf("No generated content: %v", err)
 		}
-	}
-
-	var goldContent map[string]treeAttrs
-	if _, err := os.Stat(goldPath); err == nil {
-		goldContent, err = treeContentAndAttrs(t, goldPath, nil)
-		if err != nil {
-			t.Fatalf("No golden directory found: %v", err)
+		if update && args.modes == modesFull {
+			require.NoError(t, writeGoldenModes(goldPath+goldenModesSuffix, gotContent), "Cannot write golden modes file")
 		}
 	}
+
+	goldContent := goldenTreeContentAndAttrs(t, goldPath, args)
 	assert.Equal(t, goldContent, gotContent, "got and expected content differs")
 
 	// No more verification on p if it doesn’t exists
@@ -236,11 +334,83 @@ type treeAttrs struct {
 	content    string
 	path       string
 	executable bool
+	mode       fs.FileMode
+}
+
+// goldenTreeContentAndAttrs builds the recursive file list of the golden goldPath, as treeContentAndAttrs, with the
+// modes stored next to it if they are compared.
+func goldenTreeContentAndAttrs(t *testing.T, goldPath string, opts compareTreesOptions) map[string]treeAttrs {
+	t.Helper()
+
+	if _, err := os.Stat(goldPath); err != nil {
+		return nil
+	}
+
+	// Golden files are already filtered and normalized.
+	goldContent, err := treeContentAndAttrs(t, goldPath, compareTreesOptions{ignoreLines: opts.ignoreLines, modes: opts.modes})
+	if err != nil {
+		t.Fatalf("No golden directory found: %v", err)
+	}
+	if opts.modes != modesFull {
+		return goldContent
+	}
+
+	modes, err := readGoldenModes(goldPath + goldenModesSuffix)
+	require.NoError(t, err, "Cannot read golden modes file")
+	for p, attrs := range goldContent {
+		attrs.mode = modes[p]
+		goldContent[p] = attrs
+	}
+	return goldContent
+}
+
+// readGoldenModes returns the modes stored in path by writeGoldenModes, indexed by path in the tree.
+// A missing modes file stores no mode.
+func readGoldenModes(path string) (map[string]fs.FileMode, error) {
+	d, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var stored map[string]string
+	if err := yaml.Unmarshal(d, &stored); err != nil {
+		return nil, err
+	}
+	modes := make(map[string]fs.FileMode)
+	for p, m := range stored {
+		mode, err := strconv.ParseUint(m, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mode %q for %q: %w", m, p, err)
+		}
+		modes[p] = fs.FileMode(mode)
+	}
+	return modes, nil
+}
+
+// writeGoldenModes stores in path the modes of content, in octal.
+func writeGoldenModes(path string, content map[string]treeAttrs) error {
+	stored := make(map[string]string)
+	for p, attrs := range content {
+		if attrs.mode == 0 {
+			continue
+		}
+		stored[p] = fmt.Sprintf("%04o", attrs.mode)
+	}
+	d, err := yaml.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, d, 0600)
 }
 
 // treeContentAndAttrs builds a recursive file list of dir with their content and other attributes.
-// It can ignore files starting with ignoreHeaders.
-func treeContentAndAttrs(t *testing.T, dir string, ignoreHeaders []byte) (map[string]treeAttrs, error) {
+// It ignores the files and lines filtered by opts, and only captures the modes compared by opts.
+func treeContentAndAttrs(t *testing.T, dir string, opts compareTreesOptions) (map[string]treeAttrs, error) {
 	t.Helper()
 
 	r := make(map[string]treeAttrs)
@@ -263,14 +433,32 @@ func treeContentAndAttrs(t *testing.T, dir string, ignoreHeaders []byte) (map[st
 			if err != nil {
 				return err
 			}
-			// ignore given header
-			if ignoreHeaders != nil && bytes.HasPrefix(d, ignoreHeaders) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			// A single file is filtered by its name.
+			if rel == "." {
+				rel = filepath.Base(path)
+			}
+			if opts.isIgnored(rel, d) {
 				return nil
 			}
-			content = string(d)
+			content = string(opts.filterContent(rel, d))
 		}
 		trimmedPath := strings.TrimPrefix(path, dir)
-		r[trimmedPath] = treeAttrs{content, strings.TrimPrefix(path, dir), info.Mode()&0111 != 0}
+		attrs := treeAttrs{content: content, path: trimmedPath}
+		switch opts.modes {
+		case modesExecutable:
+			attrs.executable = info.Mode()&0111 != 0
+		case modesFull:
+			// The root directory is created by the test or the golden update: its mode is not generated.
+			if trimmedPath != "" || !de.IsDir() {
+				attrs.mode = info.Mode().Perm()
+			}
+		case modesIgnored:
+		}
+		r[trimmedPath] = attrs
 		return nil
 	})
 	if err != nil {
@@ -280,21 +468,87 @@ func treeContentAndAttrs(t *testing.T, dir string, ignoreHeaders []byte) (map[st
 	return r, nil
 }
 
-// ignoreDconfDB is a utility function that returns the list of binary dconf db files to ignore during copy with shutils.CopyTree.
-func ignoreDconfDB(src string, entries []os.FileInfo) []string {
-	var r []string
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
+// isIgnored returns true if any of the file filters matches content at path.
+func (o compareTreesOptions) isIgnored(path string, content []byte) bool {
+	for _, ignore := range o.ignoreFiles {
+		if ignore(path, content) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterContent returns content at path normalized by the replacements and without the lines matched by any of the
+// line filters.
+func (o compareTreesOptions) filterContent(path string, content []byte) []byte {
+	for _, r := range o.replacements {
+		content = r.re.ReplaceAll(content, []byte(r.repl))
+	}
+	if len(o.ignoreLines) == 0 {
+		return content
+	}
+
+	var r [][]byte
+	for _, l := range bytes.SplitAfter(content, []byte("\n")) {
+		if !slices.ContainsFunc(o.ignoreLines, func(ignore IgnoreFunc) bool { return ignore(path, bytes.TrimSuffix(l, []byte("\n"))) }) {
+			r = append(r, l)
+		}
+	}
+	return bytes.Join(r, nil)
+}
+
+// filterTreeContent rewrites the regular files under dir with their filtered content.
+func (o compareTreesOptions) filterTreeContent(dir string) error {
+	if len(o.ignoreLines) == 0 && len(o.replacements) == 0 {
+		return nil
+	}
+
+	return filepath.WalkDir(dir, func(path string, de fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
-		d, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if !de.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
 		if err != nil {
-			continue
+			return err
 		}
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		d, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, o.filterContent(rel, d), info.Mode())
+	})
+}
 
-		if bytes.HasPrefix(d, []byte("GVariant")) {
-			r = append(r, e.Name())
+// copyIgnore returns a function listing the files filtered by opts to ignore during copy of root with shutils.CopyTree.
+func (o compareTreesOptions) copyIgnore(root string) func(src string, entries []os.FileInfo) []string {
+	return func(src string, entries []os.FileInfo) []string {
+		var r []string
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			p := filepath.Join(src, e.Name())
+			d, err := os.ReadFile(p)
+			if err != nil {
+				continue
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				continue
+			}
+
+			if o.isIgnored(rel, d) {
+				r = append(r, e.Name())
+			}
 		}
+		return r
 	}
-	return r
 }
@@ -0,0 +1,204 @@
+package testutils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareTreesWithFiltering(t *testing.T) {
+	t.Parallel()
+
+	ignoreTimestamps := func(_ string, line []byte) bool {
+		return strings.HasPrefix(string(line), "Generated at: ")
+	}
+	ignoreCache := func(path string, _ []byte) bool {
+		return strings.HasPrefix(path, "cache"+string(os.PathSeparator))
+	}
+
+	tests := map[string]struct {
+		files      map[string]string
+		modes      map[string]fs.FileMode
+		singleFile bool
+		// normalizeTempDir replaces the temporary directory, substituted to @TEMPDIR@ in files, with a placeholder.
+		normalizeTempDir bool
+		opts             []CompareTreesOption
+	}{
+		"Dconf databases are filtered by default": {files: map[string]string{
+			"db/machine":           "GVariant" + randomSuffix(),
+			"db/machine.d/keyfile": "[org/gnome/desktop/interface]\nclock-show-date=true\n",
+		}},
+		"Custom line filter strips timestamps": {
+			files: map[string]string{
+				"report": "Report header\nGenerated at: " + time.Now().Format(time.RFC3339Nano) + "\nReport content\n",
+			},
+			opts: []CompareTreesOption{WithIgnoredLines(ignoreTimestamps)},
+		},
+		"Custom line filter strips timestamps of a single file": {
+			files: map[string]string{
+				"report": "Generated at: " + time.Now().Format(time.RFC3339Nano) + "\nReport content\n",
+			},
+			singleFile: true,
+			opts:       []CompareTreesOption{WithIgnoredLines(ignoreTimestamps)},
+		},
+		"Custom file filter replaces the default one": {
+			files: map[string]string{
+				"cache/random-" + randomSuffix(): "machine dependent",
+				"db/machine":                     "GVariant binary DB compared as any other file",
+			},
+			opts: []CompareTreesOption{WithIgnoredFiles(ignoreCache)},
+		},
+		"Custom file and line filters are combined": {
+			files: map[string]string{
+				"cache/random-" + randomSuffix(): "machine dependent",
+				"report":                         "Generated at: " + time.Now().Format(time.RFC3339Nano) + "\nReport content\n",
+			},
+			opts: []CompareTreesOption{WithIgnoredFiles(ignoreCache, IgnoreDconfDB), WithIgnoredLines(ignoreTimestamps)},
+		},
+		"Temporary directory path is normalized": {
+			files: map[string]string{
+				"adsys-test.mount": "[Mount]\nWhat=@TEMPDIR@/share\nWhere=/mnt/share\n",
+			},
+			normalizeTempDir: true,
+		},
+		"Normalizations are applied in order": {
+			files: map[string]string{
+				"krb5cc": fmt.Sprintf("@TEMPDIR@/krb5cc_%d\nuid=%d\n", os.Getuid(), os.Getuid()),
+			},
+			normalizeTempDir: true,
+			opts: []CompareTreesOption{
+				WithNormalizedContent(regexp.MustCompile(`uid=\d+`), "uid=UID"),
+				WithNormalizedContent(regexp.MustCompile(`UID`), "@UID@"),
+				WithNormalizedContent(regexp.MustCompile(`krb5cc_\d+`), "krb5cc_@UID@"),
+			},
+		},
+		"Normalizations and line filters are combined": {
+			files: map[string]string{
+				"report": "Generated at: " + time.Now().Format(time.RFC3339Nano) + "\nReport of @TEMPDIR@\n",
+			},
+			normalizeTempDir: true,
+			opts:             []CompareTreesOption{WithIgnoredLines(ignoreTimestamps)},
+		},
+		"File modes are compared": {
+			files: map[string]string{
+				"sudoers.d/99-adsys-privilege-enforcement": "%admins ALL=(ALL:ALL) ALL\n",
+				"scripts/run": "#!/bin/sh\n",
+				"empty/.keep": "",
+			},
+			modes: map[string]fs.FileMode{
+				"sudoers.d/99-adsys-privilege-enforcement": 0440,
+				"scripts/run": 0750,
+				"empty":       0700,
+			},
+			opts: []CompareTreesOption{WithFileModes()},
+		},
+		"File modes of a single file are compared": {
+			files:      map[string]string{"report": "Report content\n"},
+			modes:      map[string]fs.FileMode{"report": 0440},
+			singleFile: true,
+			opts:       []CompareTreesOption{WithFileModes()},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			p := tempDir
+			for f, content := range tc.files {
+				content = strings.ReplaceAll(content, "@TEMPDIR@", tempDir)
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(p, f)), 0750), "Setup: can't create parent directory")
+				require.NoError(t, os.WriteFile(filepath.Join(p, f), []byte(content), 0600), "Setup: can't write file")
+			}
+			// Empty directories are tracked by their marker in the golden files.
+			require.NoError(t, os.RemoveAll(filepath.Join(p, "empty", ".keep")), "Setup: can't empty directory")
+			for f, mode := range tc.modes {
+				require.NoError(t, os.Chmod(filepath.Join(p, f), mode), "Setup: can't change mode")
+			}
+			opts := tc.opts
+			if tc.normalizeTempDir {
+				opts = append([]CompareTreesOption{WithNormalizedContent(regexp.MustCompile(regexp.QuoteMeta(tempDir)), "@TEMPDIR@")}, opts...)
+			}
+			if tc.singleFile {
+				p = filepath.Join(p, "report")
+			}
+
+			goldPath := GoldenPath(t)
+			CompareTreesWithFiltering(t, p, goldPath, UpdateEnabled(), opts...)
+
+			// Machine dependent content should never be committed in the golden files.
+			err := filepath.WalkDir(goldPath, func(path string, de fs.DirEntry, err error) error {
+				if err != nil || de.IsDir() {
+					return err
+				}
+				d, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				require.NotContains(t, string(d), "Generated at: ", "Golden file %s should not contain timestamps", path)
+				require.NotContains(t, string(d), tempDir, "Golden file %s should not contain the temporary directory", path)
+				require.NotContains(t, path, "random-", "Golden directory should not contain machine dependent file %s", path)
+				return nil
+			})
+			require.NoError(t, err, "Teardown: can't walk golden directory")
+		})
+	}
+}
+
+func TestCompareTreesWithFilteringModeChanges(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		newMode fs.FileMode
+		opts    []CompareTreesOption
+
+		wantDiff bool
+	}{
+		"Mode change is detected when modes are compared":          {newMode: 0400, opts: []CompareTreesOption{WithFileModes()}, wantDiff: true},
+		"Executable bit change is detected by default":             {newMode: 0550, wantDiff: true},
+		"Mode change is not detected by default":                   {newMode: 0400},
+		"Executable bit change is not detected when modes ignored": {newMode: 0550, opts: []CompareTreesOption{WithIgnoredFileModes()}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := t.TempDir()
+			sudoers := filepath.Join(p, "sudoers.d", "99-adsys-privilege-enforcement")
+			require.NoError(t, os.MkdirAll(filepath.Dir(sudoers), 0750), "Setup: can't create parent directory")
+			require.NoError(t, os.WriteFile(sudoers, []byte("%admins ALL=(ALL:ALL) ALL\n"), 0440), "Setup: can't write file")
+
+			// The golden files match the tree before the mode change.
+			goldPath := GoldenPath(t)
+			CompareTreesWithFiltering(t, p, goldPath, UpdateEnabled(), tc.opts...)
+
+			require.NoError(t, os.Chmod(sudoers, tc.newMode), "Setup: can't change mode")
+
+			var args compareTreesOptions
+			for _, o := range tc.opts {
+				o(&args)
+			}
+			got, err := treeContentAndAttrs(t, p, args)
+			require.NoError(t, err, "treeContentAndAttrs should not fail")
+			gold := goldenTreeContentAndAttrs(t, goldPath, args)
+
+			if tc.wantDiff {
+				require.NotEqual(t, gold, got, "Mode change should be detected")
+				return
+			}
+			require.Equal(t, gold, got, "Mode change should not be detected")
+		})
+	}
+}
+
+// randomSuffix returns a different suffix on each call, as a machine dependent generated content.
+func randomSuffix() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
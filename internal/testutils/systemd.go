@@ -0,0 +1,74 @@
+// TiCS: disabled // Test helpers.
+
+package testutils
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// systemdUnitExtensions are the extensions of the unit files validated by ValidateSystemdUnits.
+var systemdUnitExtensions = []string{".mount", ".automount", ".service", ".timer"}
+
+// ValidateSystemdUnits checks with systemd-analyze verify that the systemd units under dir are valid, failing the
+// test with the diagnostics otherwise.
+// This complements CompareTreesWithFiltering for the managers generating units. The validation is skipped when
+// systemd-analyze is not available.
+func ValidateSystemdUnits(t *testing.T, dir string) {
+	t.Helper()
+
+	if _, err := exec.LookPath("systemd-analyze"); err != nil {
+		t.Log("systemd-analyze is not available, skipping systemd units validation")
+		return
+	}
+
+	diagnostics, err := verifySystemdUnits(dir)
+	require.NoError(t, err, "Setup: can't verify systemd units in %s", dir)
+	require.Empty(t, diagnostics, "Systemd units in %s are invalid", dir)
+}
+
+// verifySystemdUnits runs systemd-analyze verify over the units under dir and returns its diagnostics about them.
+// The units run commands which are not installed in the test environment: the diagnostics about them are ignored.
+func verifySystemdUnits(dir string) (diagnostics string, err error) {
+	var units []string
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && slices.Contains(systemdUnitExtensions, filepath.Ext(p)) {
+			units = append(units, p)
+		}
+		return nil
+	})
+	if err != nil || len(units) == 0 {
+		return "", err
+	}
+
+	// #nosec G204 - units are the files of the test.
+	cmd := exec.Command("systemd-analyze", append([]string{"verify", "--man=no"}, units...)...)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return "", err
+	}
+
+	var lines []string
+	for _, l := range strings.Split(string(bytes.TrimSpace(out)), "\n") {
+		if l == "" || strings.Contains(l, "is not executable: No such file or directory") {
+			continue
+		}
+		if !slices.ContainsFunc(units, func(u string) bool { return strings.Contains(l, filepath.Base(u)) }) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,48 @@
+package testutils
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySystemdUnits(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("systemd-analyze"); err != nil {
+		t.Skip("systemd-analyze is not available, skipping...")
+	}
+
+	tests := map[string]struct {
+		wantInvalidUnit string
+	}{
+		"Valid units": {},
+		"No units":    {},
+
+		// Invalid units
+		"Invalid service":                  {wantInvalidUnit: "adsys-test.service"},
+		"Mount not matching its unit name": {wantInvalidUnit: "adsys-test.mount"},
+		"Unknown setting":                  {wantInvalidUnit: "adsys-test.service"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(TestFamilyPath(t), strings.ToLower(name))
+
+			diagnostics, err := verifySystemdUnits(dir)
+			require.NoError(t, err, "verifySystemdUnits should not fail")
+
+			if tc.wantInvalidUnit == "" {
+				require.Empty(t, diagnostics, "Units should be valid")
+				// The exported helper shouldn't fail either.
+				ValidateSystemdUnits(t, dir)
+				return
+			}
+			require.Contains(t, diagnostics, tc.wantInvalidUnit, "Diagnostics should mention the invalid unit")
+		})
+	}
+}
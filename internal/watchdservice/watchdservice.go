@@ -31,6 +31,7 @@ type options struct {
 	extraArgs   []string
 	name        string
 	userService bool
+	dryRun      bool
 }
 type option func(*options) error
 
@@ -52,6 +53,14 @@ func WithConfig(configFile string) func(o *options) error {
 	}
 }
 
+// WithDryRun makes the watcher log the version bumps it would do, without modifying any GPT.ini.
+func WithDryRun(dryRun bool) func(o *options) error {
+	return func(o *options) error {
+		o.dryRun = dryRun
+		return nil
+	}
+}
+
 // WithName allows setting a custom name to the service.
 func WithName(name string) func(o *options) error {
 	return func(o *options) error {
@@ -82,7 +91,7 @@ func New(ctx context.Context, opts ...option) (*WatchdService, error) {
 	var w *watcher.Watcher
 	var err error
 	if len(args.dirs) > 0 {
-		if w, err = watcher.New(ctx, args.dirs); err != nil {
+		if w, err = watcher.New(ctx, args.dirs, watcher.WithDryRun(args.dryRun)); err != nil {
 			return nil, err
 		}
 	}
@@ -1,6 +1,9 @@
 package watcher
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // WithRefreshDuration allows overriding default refresh duration on tests.
 func WithRefreshDuration(refreshDuration time.Duration) func(o *options) error {
@@ -14,3 +17,8 @@ func WithRefreshDuration(refreshDuration time.Duration) func(o *options) error {
 func (w Watcher) RefreshDuration() time.Duration {
 	return w.refreshDuration
 }
+
+// BumpVersion bumps the version of the GPT.ini file at path.
+func BumpVersion(ctx context.Context, path string) error {
+	return bumpVersion(ctx, path, false)
+}
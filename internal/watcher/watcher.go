@@ -6,6 +6,7 @@ package watcher
 import (
 	"context"
 	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -15,6 +16,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/kardianos/service"
 	"github.com/leonelquinteros/gotext"
+	adcommon "github.com/ubuntu/adsys/internal/ad/common"
 	log "github.com/ubuntu/adsys/internal/grpc/logstreamer"
 	"github.com/ubuntu/decorate"
 	"gopkg.in/ini.v1"
@@ -22,6 +24,8 @@ import (
 
 const (
 	gptFileName = "GPT.INI"
+	// gptTmpSuffix is the suffix of the file GPT.ini is written to, before replacing it.
+	gptTmpSuffix = ".new"
 )
 
 const (
@@ -35,6 +39,7 @@ type Watcher struct {
 	cmdErr chan error
 
 	refreshDuration time.Duration
+	dryRun          bool
 }
 
 type command struct {
@@ -46,9 +51,18 @@ type command struct {
 // options are the configurable functional options for the watcher.
 type options struct {
 	refreshDuration time.Duration
+	dryRun          bool
 }
 type option func(*options) error
 
+// WithDryRun only logs the changes detected and the version bumps they would trigger, without modifying any GPT.ini.
+func WithDryRun(dryRun bool) func(o *options) error {
+	return func(o *options) error {
+		o.dryRun = dryRun
+		return nil
+	}
+}
+
 func init() {
 	// Windows-generated files do not have spaces around the equals sign.
 	ini.PrettyFormat = false
@@ -77,6 +91,7 @@ func New(ctx context.Context, initialDirs []string, opts ...option) (*Watcher, e
 		cmdErr: cmdErr,
 
 		refreshDuration: args.refreshDuration,
+		dryRun:          args.dryRun,
 	}
 
 	go func() {
@@ -186,30 +201,59 @@ func (w *Watcher) UpdateDirs(ctx context.Context, dirs []string) (err error) {
 	return w.send(&ctx, startCmd, dirs)
 }
 
+// pendingRefresh is a root directory waiting for its grace period without changes to end before bumping its GPT.ini.
+type pendingRefresh struct {
+	timer *time.Timer
+	// generation identifies the last reset of timer, so that an expiry racing with a reset is ignored.
+	generation int
+}
+
+// rootExpired is sent when the grace period of a root directory ends.
+type rootExpired struct {
+	root       string
+	generation int
+}
+
 // watch is the main watch loop.
+// Each root directory is debounced independently: a burst of changes in one root results in a single bump of its
+// GPT.ini, without delaying the others. Directories which can't be watched are skipped, as long as one of them is.
 func (w *Watcher) watch(ctx context.Context, dirs []string, initError chan<- error) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't watch over %v", dirs))
 
 	fsWatcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		initError <- errors.New(gotext.Get("could not initialize fsnotify watcher: %v", err))
+		err = errors.New(gotext.Get("could not initialize fsnotify watcher: %v", err))
+		initError <- err
+		return err
 	}
 	defer fsWatcher.Close()
 
 	// Collect directories to watch.
+	var watchedDirs []string
+	var watchErrs []error
 	for _, dir := range dirs {
 		if err := watchSubDirs(ctx, fsWatcher, dir); err != nil {
-			initError <- errors.New(gotext.Get("failed to watch directory %q: %v", dir, err))
+			log.Warning(ctx, gotext.Get("Failed to watch directory %q, skipping it: %v", dir, err))
+			watchErrs = append(watchErrs, err)
+			continue
 		}
+		watchedDirs = append(watchedDirs, dir)
+	}
+	if len(watchedDirs) == 0 {
+		err = errors.New(gotext.Get("failed to watch any directory: %v", errors.Join(watchErrs...)))
+		initError <- err
+		return err
 	}
 
-	// We configure a timer for a grace period without changes before committing any changes.
-	refreshTimer := time.NewTimer(w.refreshDuration)
-	defer refreshTimer.Stop()
-	refreshTimer.Stop()
+	// We configure a timer per root directory for a grace period without changes before committing any changes.
+	pending := make(map[string]*pendingRefresh)
+	expired := make(chan rootExpired)
+	defer func() {
+		for _, p := range pending {
+			p.timer.Stop()
+		}
+	}()
 
-	// Collect directories to watch.
-	var modifiedRootDirs []string
 	initError <- nil
 	for {
 		select {
@@ -220,7 +264,7 @@ func (w *Watcher) watch(ctx context.Context, dirs []string, initError chan<- err
 			log.Debug(ctx, gotext.Get("Got event: %v", event))
 
 			// If the modified file is our own change, ignore it.
-			if strings.EqualFold(filepath.Base(event.Name), gptFileName) {
+			if base := filepath.Base(event.Name); strings.EqualFold(base, gptFileName) || strings.EqualFold(base, gptFileName+gptTmpSuffix) {
 				continue
 			}
 
@@ -256,35 +300,32 @@ func (w *Watcher) watch(ctx context.Context, dirs []string, initError chan<- err
 				continue
 			}
 
-			// Find and add matching root directory if not already present in the list to refresh.
-			rootDir, err := getRootDir(event.Name, dirs)
+			// Find the matching root directory and reset its grace period.
+			rootDir, err := getRootDir(event.Name, watchedDirs)
 			if err != nil {
 				log.Warning(ctx, err)
 				continue
 			}
-			var alreadyAdded bool
-			for _, modifiedRootDir := range modifiedRootDirs {
-				if rootDir != modifiedRootDir {
-					continue
-				}
-				alreadyAdded = true
-				break
+			if w.dryRun {
+				log.Infof(ctx, gotext.Get("Dry run: detected change on %s for %s", event.Name, rootDir))
 			}
-			if !alreadyAdded {
-				modifiedRootDirs = append(modifiedRootDirs, rootDir)
+			p, ok := pending[rootDir]
+			if !ok {
+				log.Debugf(ctx, gotext.Get("Change in %s, bumping its version after %s without other changes", rootDir, w.refreshDuration))
+				p = &pendingRefresh{}
+				pending[rootDir] = p
+			} else {
+				log.Debugf(ctx, gotext.Get("New change in %s, resetting its grace period to %s", rootDir, w.refreshDuration))
+				p.timer.Stop()
 			}
-
-			// Stop means that the timer expired, not that it was stopped, so
-			// drain the channel only if there is something to drain.
-			if !refreshTimer.Stop() {
+			p.generation++
+			generation := p.generation
+			p.timer = time.AfterFunc(w.refreshDuration, func() {
 				select {
-				case <-refreshTimer.C:
-				default:
+				case expired <- rootExpired{root: rootDir, generation: generation}:
+				case <-ctx.Done():
 				}
-			}
-
-			// We got a change, so reset the timer to the grace period.
-			refreshTimer.Reset(w.refreshDuration)
+			})
 
 		case err, ok := <-fsWatcher.Errors:
 			if ok {
@@ -292,16 +333,27 @@ func (w *Watcher) watch(ctx context.Context, dirs []string, initError chan<- err
 			}
 			continue
 
-		case <-refreshTimer.C:
-			// Update relevant GPT.ini files.
-			updateVersions(ctx, modifiedRootDirs)
+		case e := <-expired:
+			// The grace period was reset since this expiry.
+			if p, ok := pending[e.root]; !ok || p.generation != e.generation {
+				log.Debugf(ctx, gotext.Get("Ignoring outdated end of grace period for %s", e.root))
+				continue
+			}
+			delete(pending, e.root)
+			log.Debugf(ctx, gotext.Get("Grace period ended for %s", e.root))
+			// Update relevant GPT.ini file.
+			w.updateVersions(ctx, []string{e.root})
 
 		case <-ctx.Done():
 			log.Infof(ctx, gotext.Get("Watcher stopped"))
-			// Check if there was a timer in progress to not miss an update before exiting.
-			if refreshTimer.Stop() {
-				updateVersions(ctx, modifiedRootDirs)
+			// Don't miss the updates of the grace periods in progress before exiting.
+			var modifiedRootDirs []string
+			for _, dir := range watchedDirs {
+				if _, ok := pending[dir]; ok {
+					modifiedRootDirs = append(modifiedRootDirs, dir)
+				}
 			}
+			w.updateVersions(ctx, modifiedRootDirs)
 			return nil
 		}
 	}
@@ -350,48 +402,86 @@ func getRootDir(path string, rootDirs []string) (string, error) {
 }
 
 // updateVersions updates the GPT.ini files of the given directories.
-func updateVersions(ctx context.Context, modifiedRootDirs []string) {
+func (w *Watcher) updateVersions(ctx context.Context, modifiedRootDirs []string) {
 	for _, dir := range modifiedRootDirs {
 		gptIniPath := filepath.Join(dir, gptFileName)
-		if err := bumpVersion(ctx, gptIniPath); err != nil {
+		if err := bumpVersion(ctx, gptIniPath, w.dryRun); err != nil {
 			log.Warning(ctx, gotext.Get("Failed to bump %s version: %s", gptIniPath, err))
 		}
 	}
 }
 
 // bumpVersion does the actual bumping of the version in the given GPT.ini file.
-func bumpVersion(ctx context.Context, path string) (err error) {
+// The file is replaced atomically, so that clients never read a truncated file. Other sections and keys, and the
+// file permissions, are kept.
+// A missing or malformed file is recreated. In dry run mode, the bump is only logged.
+func bumpVersion(ctx context.Context, path string, dryRun bool) (err error) {
 	defer decorate.OnError(&err, gotext.Get("can't bump version for %s", path))
-	log.Info(ctx, gotext.Get("Bumping version for %s", path))
 
-	cfg, err := ini.Load(path)
+	// GPT.INI can be saved in UTF-16 by Windows tools: it is written back in UTF-8.
+	content, err := os.ReadFile(filepath.Clean(path))
+	var cfg *ini.File
+	if err == nil {
+		cfg, err = adcommon.LoadIni(content)
+	}
 
-	// If the file doesn't exist, create it and initialize the key to be updated.
+	// If the file doesn't exist or can't be parsed, create it from scratch.
 	if err != nil {
 		log.Info(ctx, gotext.Get("error loading ini contents: %v, creating a new file", err))
 		cfg = ini.Empty()
-		if _, err := cfg.Section("General").NewKey("Version", "0"); err != nil {
-			return err
-		}
 	}
 
+	hasVersion := cfg.Section("General").HasKey("Version")
 	v, err := cfg.Section("General").Key("Version").Int()
 
-	// Error out if the key is absent or malformed.
+	// Reset the version if the key is absent or malformed.
 	if err != nil {
-		return err
+		if hasVersion {
+			log.Warning(ctx, gotext.Get("Invalid version in %s, resetting it: %v", path, err))
+		}
+		v = 0
 	}
 
+	if dryRun {
+		log.Info(ctx, gotext.Get("Dry run: would bump version for %s from %d to %d", path, v, v+1))
+		return nil
+	}
+	log.Info(ctx, gotext.Get("Bumping version for %s", path))
+
 	// Increment the version and write it back to the file.
 	v++
 	cfg.Section("General").Key("Version").SetValue(strconv.Itoa(v))
 
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	// The new file keeps the permissions of the one it replaces.
+	perm := fs.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp := path + gptTmpSuffix
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = cfg.WriteTo(f)
+	// Neither the umask nor a leftover temporary file should change them.
+	err = f.Chmod(perm)
+	if err == nil {
+		_, err = cfg.WriteTo(f)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
 
-	return err
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
 }
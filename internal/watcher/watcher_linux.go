@@ -0,0 +1,13 @@
+package watcher
+
+import "os"
+
+// syncDir persists the entries of dir, like a file renamed in it.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
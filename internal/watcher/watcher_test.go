@@ -2,13 +2,17 @@ package watcher_test
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/kardianos/service"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/adsys/internal/config"
@@ -28,7 +32,6 @@ func TestWatchDirectory(t *testing.T) {
 
 		wantErrNew   bool
 		wantErrStart bool
-		wantErrBump  bool
 
 		wantVersions []int
 	}{
@@ -37,10 +40,11 @@ func TestWatchDirectory(t *testing.T) {
 		"No update, no gpt.ini": {existingDirs: []string{"no_gpt"}, wantVersions: []int{0}},
 
 		// with GPT.ini
-		"Update with existing gpt.ini": {filesToUpdate: []string{"one_file/new"}, existingDirs: []string{"one_file"}, wantVersions: []int{4}},
-		"No update, existing gpt.ini":  {existingDirs: []string{"one_file"}, wantVersions: []int{3}},
-		"Update existing file":         {filesToUpdate: []string{"one_file/alreadyexists"}, existingDirs: []string{"one_file"}, wantVersions: []int{4}},
-		"Updating gpt.ini is a no-op":  {filesToUpdate: []string{"one_file/GPT.INI"}, existingDirs: []string{"one_file"}, wantVersions: []int{3}},
+		"Update with existing gpt.ini":   {filesToUpdate: []string{"one_file/new"}, existingDirs: []string{"one_file"}, wantVersions: []int{4}},
+		"No update, existing gpt.ini":    {existingDirs: []string{"one_file"}, wantVersions: []int{3}},
+		"Update existing file":           {filesToUpdate: []string{"one_file/alreadyexists"}, existingDirs: []string{"one_file"}, wantVersions: []int{4}},
+		"Updating gpt.ini is a no-op":    {filesToUpdate: []string{"one_file/GPT.INI"}, existingDirs: []string{"one_file"}, wantVersions: []int{3}},
+		"Malformed GPT.ini is recreated": {filesToUpdate: []string{"malformed/new"}, existingDirs: []string{"malformed"}, wantVersions: []int{1}},
 
 		// remove / rename
 		"Remove root directory": {filesToRemove: []string{"one_file"}, existingDirs: []string{"one_file"}},
@@ -99,10 +103,15 @@ func TestWatchDirectory(t *testing.T) {
 			wantVersions:  []int{4, 3},
 		},
 
+		"Non existing directory does not prevent watching others": {
+			filesToUpdate: []string{"one_file/alreadyexists"},
+			existingDirs:  []string{"doesnotexist", "one_file"},
+			wantVersions:  []int{0, 4},
+		},
+
 		// Error cases
-		"Error on non existing directory":     {existingDirs: []string{"doesnotexist"}, wantErrStart: true},
-		"Error on listing no directory":       {wantErrNew: true},
-		"Error on updating malformed GPT.ini": {filesToUpdate: []string{"malformed/new"}, existingDirs: []string{"malformed"}, wantErrBump: true},
+		"Error on non existing directory": {existingDirs: []string{"doesnotexist"}, wantErrStart: true},
+		"Error on listing no directory":   {wantErrNew: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -179,10 +188,6 @@ func TestWatchDirectory(t *testing.T) {
 
 			if len(tc.wantVersions) > 0 {
 				for i, dir := range tc.existingDirs {
-					if tc.wantErrBump {
-						requireGPTVersionError(t, filepath.Join(temp, dir))
-						return
-					}
 					assertGPTVersionEquals(t, filepath.Join(temp, dir), tc.wantVersions[i])
 				}
 			}
@@ -238,6 +243,57 @@ func TestRefreshGracePeriod(t *testing.T) {
 	assertGPTVersionEquals(t, dest, 3)
 }
 
+func TestRefreshGracePeriodPerRootDirectory(t *testing.T) {
+	t.Parallel()
+
+	temp := t.TempDir()
+	first, second := filepath.Join(temp, "one_file"), filepath.Join(temp, "withsubdir")
+	testutils.Copy(t, filepath.Join("testdata", "one_file"), first)
+	testutils.Copy(t, filepath.Join("testdata", "withsubdir"), second)
+
+	// Instantiate the object
+	w, err := watcher.New(context.Background(), []string{first, second}, watcher.WithRefreshDuration(time.Second))
+	require.NoError(t, err, "Setup: Can't create watcher")
+
+	// Start it
+	err = w.Start(mockService{})
+	require.NoError(t, err, "Setup: Can't start watcher")
+	defer w.Stop(mockService{})
+
+	// Burst of writes in the first root directory
+	for range 3 {
+		err = os.WriteFile(filepath.Join(first, "alreadyexists"), []byte("new content"), 0600)
+		require.NoError(t, err, "Setup: Can't update file")
+	}
+	testutils.WaitForWrites(t)
+
+	// Wait for half of the grace period
+	time.Sleep(w.RefreshDuration()/2 - 100*time.Millisecond)
+
+	// GPT.ini versions were not changed
+	assertGPTVersionEquals(t, first, 3)
+	assertGPTVersionEquals(t, second, 2)
+
+	// Modify a file in the second root directory
+	err = os.WriteFile(filepath.Join(second, "alreadyexists"), []byte("new content"), 0600)
+	require.NoError(t, err, "Setup: Can't update file")
+	testutils.WaitForWrites(t)
+
+	// Wait for the grace period of the first root directory to end, but not the second one.
+	time.Sleep(time.Duration(float64(w.RefreshDuration())*0.75) - 100*time.Millisecond)
+
+	// Only the first GPT.ini version was updated, once for the whole burst
+	assertGPTVersionEquals(t, first, 4)
+	assertGPTVersionEquals(t, second, 2)
+
+	// Wait for the grace period of the second root directory to end.
+	time.Sleep(w.RefreshDuration() / 2)
+
+	// The second GPT.ini version was updated, without bumping the first one again
+	assertGPTVersionEquals(t, first, 4)
+	assertGPTVersionEquals(t, second, 3)
+}
+
 func TestUpdateDirs(t *testing.T) {
 	t.Parallel()
 
@@ -393,6 +449,136 @@ func TestUpdateDirsOnStoppedWatcher(t *testing.T) {
 	assertGPTVersionEquals(t, curDir, 3)
 }
 
+func TestBumpVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		perm     os.FileMode
+		tmpIsDir bool
+
+		wantErr bool
+	}{
+		"Existing file":                      {},
+		"Keeps other sections and keys":      {},
+		"Keeps permissions of existing file": {perm: 0640},
+		"Missing file":                       {},
+		"Malformed version":                  {},
+		"Missing version key":                {},
+		"Unparsable file":                    {},
+		"Leftover temporary file":            {},
+		"UTF-16LE file":                      {},
+		"UTF-16BE file":                      {},
+
+		// Error cases
+		"Error when temporary file can not be written keeps existing file": {tmpIsDir: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(t.TempDir(), "gpo")
+			src := filepath.Join(testutils.TestFamilyPath(t), strings.ToLower(name))
+			if _, err := os.Stat(src); err == nil {
+				testutils.Copy(t, src, dir)
+			} else {
+				require.NoError(t, os.Mkdir(dir, 0700), "Setup: can't create GPO directory")
+			}
+			path := filepath.Join(dir, "GPT.INI")
+			if tc.perm != 0 {
+				require.NoError(t, os.Chmod(path, tc.perm), "Setup: can't change GPT.ini permissions")
+			}
+			if tc.tmpIsDir {
+				require.NoError(t, os.Mkdir(path+".new", 0700), "Setup: can't create directory in place of temporary file")
+			}
+
+			before, errBefore := os.Stat(path)
+			orig, _ := os.ReadFile(path)
+
+			err := watcher.BumpVersion(context.Background(), path)
+			if tc.wantErr {
+				require.Error(t, err, "BumpVersion should have failed but hasn't")
+				got, err := os.ReadFile(path)
+				require.NoError(t, err, "GPT.ini should still exist")
+				assert.Equal(t, string(orig), string(got), "GPT.ini should not have been modified")
+				return
+			}
+			require.NoError(t, err, "BumpVersion should not have failed")
+
+			got, err := os.ReadFile(path)
+			require.NoError(t, err, "GPT.ini should have been written")
+			want := testutils.LoadWithUpdateFromGolden(t, string(got))
+			assert.Equal(t, want, string(got), "GPT.ini content is not the expected one")
+
+			require.NoFileExists(t, path+".new", "Temporary file should not be left behind")
+
+			after, err := os.Stat(path)
+			require.NoError(t, err, "Can't stat GPT.ini")
+			if errBefore != nil {
+				// Windows only reports the read-only attribute as permissions.
+				if runtime.GOOS != "windows" {
+					assert.Equal(t, os.FileMode(0600), after.Mode().Perm(), "Missing GPT.ini should be created readable by its owner only")
+				}
+				return
+			}
+			// The file is replaced, not rewritten in place.
+			assert.False(t, os.SameFile(before, after), "GPT.ini should have been replaced by a new file")
+			assert.Equal(t, before.Mode().Perm(), after.Mode().Perm(), "GPT.ini should keep its permissions")
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	// t.Parallel() The logs are captured on the global logger.
+
+	temp := t.TempDir()
+	withGPT, withoutGPT := filepath.Join(temp, "one_file"), filepath.Join(temp, "no_gpt")
+	testutils.Copy(t, filepath.Join("testdata", "one_file"), withGPT)
+	testutils.Copy(t, filepath.Join("testdata", "no_gpt"), withoutGPT)
+	gptBefore, err := os.ReadFile(filepath.Join(withGPT, "GPT.INI"))
+	require.NoError(t, err, "Setup: Can't read GPT.ini")
+
+	hook := &logrustest.Hook{}
+	previousHooks := logrus.StandardLogger().ReplaceHooks(logrus.LevelHooks{})
+	logrus.AddHook(hook)
+	t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(previousHooks) })
+
+	// Instantiate the object
+	w, err := watcher.New(context.Background(), []string{withGPT, withoutGPT},
+		watcher.WithRefreshDuration(200*time.Millisecond), watcher.WithDryRun(true))
+	require.NoError(t, err, "Setup: Can't create watcher")
+
+	// Start it
+	err = w.Start(mockService{})
+	require.NoError(t, err, "Setup: Can't start watcher")
+	defer w.Stop(mockService{})
+
+	updateFiles(t, []string{filepath.Join(withGPT, "alreadyexists"), filepath.Join(withoutGPT, "new")})
+
+	// Wait for the grace period to end
+	time.Sleep(2 * w.RefreshDuration())
+
+	err = w.Stop(mockService{})
+	require.NoError(t, err, "Can't stop watcher")
+
+	// No GPT.ini was modified or created
+	gptAfter, err := os.ReadFile(filepath.Join(withGPT, "GPT.INI"))
+	require.NoError(t, err, "Can't read GPT.ini")
+	assert.Equal(t, string(gptBefore), string(gptAfter), "GPT.ini should not be modified in dry run mode")
+	assertGPTVersionEquals(t, withoutGPT, 0)
+
+	// The changes and the bumps they would do are logged
+	var logs []string
+	for _, e := range hook.AllEntries() {
+		logs = append(logs, e.Message)
+	}
+	allLogs := strings.Join(logs, "\n")
+	assert.Contains(t, allLogs, "Dry run: detected change on "+filepath.Join(withGPT, "alreadyexists"), "Change should be logged")
+	assert.Contains(t, allLogs, "Dry run: detected change on "+filepath.Join(withoutGPT, "new"), "Change should be logged")
+	assert.Contains(t, allLogs, fmt.Sprintf("Dry run: would bump version for %s from 3 to 4", filepath.Join(withGPT, "GPT.INI")), "Bump should be logged")
+	assert.Contains(t, allLogs, fmt.Sprintf("Dry run: would bump version for %s from 0 to 1", filepath.Join(withoutGPT, "GPT.INI")), "Bump should be logged")
+	assert.Contains(t, allLogs, "Grace period ended for "+withGPT, "Debounce decisions should be logged")
+}
+
 func TestStopWithoutStart(t *testing.T) {
 	t.Parallel()
 
@@ -431,24 +617,6 @@ func assertGPTVersionEquals(t *testing.T, path string, version int) {
 	assert.Equal(t, version, v, "GPT.ini version is not equal to the expected one")
 }
 
-func requireGPTVersionError(t *testing.T, path string) {
-	t.Helper()
-
-	gptfile := filepath.Join(path, "GPT.INI")
-
-	var gptFileExists bool
-	if _, err := os.Stat(gptfile); err == nil {
-		gptFileExists = true
-	}
-	require.True(t, gptFileExists, "GPT.ini not created")
-
-	cfg, err := ini.Load(gptfile)
-	require.NoError(t, err, "Can't load GPT.ini")
-
-	_, err = cfg.Section("General").Key("Version").Int()
-	require.Error(t, err, "Version should be invalid")
-}
-
 func updateFiles(t *testing.T, files []string) {
 	t.Helper()
 
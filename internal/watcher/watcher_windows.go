@@ -0,0 +1,8 @@
+package watcher
+
+// syncDir persists the entries of dir, like a file renamed in it.
+//
+// Directories can't be flushed on Windows, where renames are journaled by the file system.
+func syncDir(_ string) error {
+	return nil
+}